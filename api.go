@@ -0,0 +1,931 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	apiTokenPrefix    = "bg_"
+	apiMaxBodyBytes   = 64 << 10
+	apiViewPathPrefix = "/api/v1/views/"
+)
+
+// API-level error codes, alongside the errCode* reasons game logic reports.
+const (
+	errCodeUnauthorized     = "unauthorized"
+	errCodeMethodNotAllowed = "method_not_allowed"
+	errCodeBadRequest       = "bad_request"
+)
+
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type apiErrorResponse struct {
+	OK    bool     `json:"ok"`
+	Error apiError `json:"error"`
+}
+
+type apiTokenResponse struct {
+	Token    string `json:"token"`
+	PlayerID string `json:"player_id"`
+	Name     string `json:"name"`
+}
+
+type apiPlayer struct {
+	ID                 string `json:"id"`
+	Name               string `json:"name"`
+	Title              string `json:"title"`
+	Gold               int    `json:"gold"`
+	Grain              int    `json:"grain"`
+	Rep                int    `json:"rep"`
+	Heat               int    `json:"heat"`
+	Rumors             int    `json:"rumors"`
+	CompletedContracts int    `json:"completed_contracts"`
+	RiteImmunityTicks  int    `json:"rite_immunity_ticks"`
+	BribeAccessTicks   int    `json:"bribe_access_ticks"`
+	LocationID         string `json:"location_id"`
+	TravelToID         string `json:"travel_to_id"`
+	TravelTicksLeft    int    `json:"travel_ticks_left"`
+	TravelTotalTicks   int    `json:"travel_total_ticks"`
+}
+
+type apiWorld struct {
+	DayNumber              int          `json:"day_number"`
+	Subphase               string       `json:"subphase"`
+	Calendar               CalendarView `json:"calendar"`
+	Tick                   int64        `json:"tick"`
+	GrainSupply            int          `json:"grain_supply"`
+	GrainTier              string       `json:"grain_tier"`
+	UnrestValue            int          `json:"unrest_value"`
+	UnrestTier             string       `json:"unrest_tier"`
+	RestrictedMarketsTicks int          `json:"restricted_markets_ticks"`
+	WardNetworkTicks       int          `json:"ward_network_ticks"`
+	Situation              string       `json:"situation"`
+}
+
+type apiPolicies struct {
+	TaxRatePct             int  `json:"tax_rate_pct"`
+	PermitRequiredHighRisk bool `json:"permit_required_high_risk"`
+	SmugglingEmbargoTicks  int  `json:"smuggling_embargo_ticks"`
+}
+
+type apiLocation struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Traveling   bool             `json:"traveling"`
+	Destination string           `json:"destination,omitempty"`
+	Options     []LocationOption `json:"options"`
+}
+
+type apiFieldwork struct {
+	Available      bool   `json:"available"`
+	Action         string `json:"action,omitempty"`
+	Label          string `json:"label,omitempty"`
+	Description    string `json:"description,omitempty"`
+	Disabled       bool   `json:"disabled"`
+	DisabledReason string `json:"disabled_reason,omitempty"`
+	SupplyCost     int    `json:"supply_cost"`
+}
+
+type apiDashboardView struct {
+	Player              apiPlayer        `json:"player"`
+	World               apiWorld         `json:"world"`
+	TickStatus          string           `json:"tick_status"`
+	Standing            StandingView     `json:"standing"`
+	HighImpactRemaining int              `json:"high_impact_remaining"`
+	HighImpactCap       int              `json:"high_impact_cap"`
+	Contracts           []ContractView   `json:"contracts"`
+	AcceptedContracts   int              `json:"accepted_contracts"`
+	VisibleContracts    int              `json:"visible_contracts"`
+	TotalContracts      int              `json:"total_contracts"`
+	Location            apiLocation      `json:"location"`
+	Fieldwork           apiFieldwork     `json:"fieldwork"`
+	Relics              []RelicView      `json:"relics"`
+	RelicAppraiseCost   int              `json:"relic_appraise_cost"`
+	Crisis              *CrisisView      `json:"crisis"`
+	Caravans            []CaravanView    `json:"caravans"`
+	Buildings           []BuildingView   `json:"buildings"`
+	BuildOptions        []BuildingOption `json:"build_options"`
+	Fight               *FightView       `json:"fight,omitempty"`
+}
+
+type apiEventsView struct {
+	Events []EventView `json:"events"`
+}
+
+type apiChatView struct {
+	Chat []ChatView `json:"chat"`
+}
+
+type apiDiplomacyView struct {
+	Messages                []MessageView  `json:"messages"`
+	Recipients              []PlayerOption `json:"recipients"`
+	SealMessageCost         int            `json:"seal_message_cost"`
+	SealMessageDisabled     bool           `json:"seal_message_disabled"`
+	SealMessageDisabledNote string         `json:"seal_message_disabled_note,omitempty"`
+}
+
+type apiPlayersView struct {
+	Players []PlayerSummary `json:"players"`
+}
+
+type apiInstitutionsView struct {
+	Seats          []SeatView          `json:"seats"`
+	Treasuries     []TreasuryView      `json:"treasuries"`
+	TreasuryLedger []TreasuryEntryView `json:"treasury_ledger"`
+	Policies       apiPolicies         `json:"policies"`
+	Permits        []PermitView        `json:"permits"`
+	Warrants       []WarrantView       `json:"warrants"`
+	Cases          []CaseView          `json:"cases"`
+	Projects       []ProjectView       `json:"projects"`
+	ProjectOptions []ProjectOption     `json:"project_options"`
+}
+
+type apiIntelView struct {
+	Rumors                []RumorView      `json:"rumors"`
+	Evidence              []EvidenceView   `json:"evidence"`
+	ScryReports           []ScryReportView `json:"scry_reports"`
+	Intercepts            []InterceptView  `json:"intercepts"`
+	InvestigateDisabled   bool             `json:"investigate_disabled"`
+	InvestigateLabel      string           `json:"investigate_label"`
+	ForgeEvidenceCost     int              `json:"forge_evidence_cost"`
+	ForgeEvidenceDisabled bool             `json:"forge_evidence_disabled"`
+	ForgeEvidenceReason   string           `json:"forge_evidence_reason,omitempty"`
+}
+
+type apiLedgerView struct {
+	Loans       []LoanView       `json:"loans"`
+	Obligations []ObligationView `json:"obligations"`
+}
+
+type apiMarketView struct {
+	BasePrice      int    `json:"base_price"`
+	BuyPrice       int    `json:"buy_price"`
+	SellPrice      int    `json:"sell_price"`
+	SupplySacks    int    `json:"supply_sacks"`
+	ControlsTicks  int    `json:"controls_ticks"`
+	ControlsActive bool   `json:"controls_active"`
+	Stockpile      int    `json:"stockpile"`
+	MaxBuy         int    `json:"max_buy"`
+	MaxSell        int    `json:"max_sell"`
+	BuyDisabled    bool   `json:"buy_disabled"`
+	SellDisabled   bool   `json:"sell_disabled"`
+	ReliefCost     int    `json:"relief_cost"`
+	ReliefDisabled bool   `json:"relief_disabled"`
+	ReliefLabel    string `json:"relief_label"`
+	// Location is where the player trades; Commodities lists what is
+	// traded there, grain included where it is.
+	Location    string          `json:"location"`
+	TaxNote     string          `json:"tax_note"`
+	Commodities []CommodityView `json:"commodities"`
+	// Orders are the player's open limit orders in every market.
+	Orders []MarketOrderView `json:"orders"`
+}
+
+// apiMarketHistory answers GET /api/v1/markets/history.
+type apiMarketHistory struct {
+	Location string                `json:"location"`
+	Tick     int64                 `json:"tick"`
+	Series   []MarketHistorySeries `json:"series"`
+}
+
+// apiMarketHistoryLocked reads ?location= (default: where p stands),
+// ?commodity= (default: every good traded there) and ?ticks= (default: all
+// retained history).
+func apiMarketHistoryLocked(store *Store, p *Player, q url.Values) (int, any) {
+	locationID := strings.TrimSpace(q.Get("location"))
+	if locationID == "" {
+		locationID = marketLocationID(p)
+	}
+	m, ok := locationMarket(locationID)
+	if !ok {
+		return apiErrorBody(errCodeNotFound, "no market at location "+strconv.Quote(locationID))
+	}
+	var ticks int64
+	if raw := strings.TrimSpace(q.Get("ticks")); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n <= 0 {
+			return apiErrorBody(errCodeInvalidInput, "ticks must be a positive integer")
+		}
+		ticks = n
+	}
+	commodity := strings.TrimSpace(q.Get("commodity"))
+	out := apiMarketHistory{Location: locationID, Tick: store.TickCount, Series: []MarketHistorySeries{}}
+	for _, good := range m.Goods {
+		if commodity == "" || commodity == good.Commodity {
+			out.Series = append(out.Series, marketHistorySeriesLocked(store, locationID, good.Commodity, ticks))
+		}
+	}
+	if commodity != "" && len(out.Series) == 0 {
+		return apiErrorBody(errCodeNotFound, fmt.Sprintf("%s is not traded at %s", commodity, locationID))
+	}
+	return http.StatusOK, out
+}
+
+// apiState is every view at once, as served by GET /api/v1/state.
+type apiState struct {
+	Dashboard    apiDashboardView    `json:"dashboard"`
+	Events       apiEventsView       `json:"events"`
+	Chat         apiChatView         `json:"chat"`
+	Diplomacy    apiDiplomacyView    `json:"diplomacy"`
+	Players      apiPlayersView      `json:"players"`
+	Institutions apiInstitutionsView `json:"institutions"`
+	Intel        apiIntelView        `json:"intel"`
+	Ledger       apiLedgerView       `json:"ledger"`
+	Market       apiMarketView       `json:"market"`
+}
+
+// apiActionRequest mirrors ActionInput; ActionInput itself keeps its field
+// names because journal entries are stored with them.
+type apiActionRequest struct {
+	Action       string `json:"action"`
+	ContractID   string `json:"contract_id"`
+	Stance       string `json:"stance"`
+	TargetID     string `json:"target_id"`
+	RelicID      string `json:"relic_id"`
+	Claim        string `json:"claim"`
+	Topic        string `json:"topic"`
+	LoanID       string `json:"loan_id"`
+	ObligationID string `json:"obligation_id"`
+	ProjectType  string `json:"project_type"`
+	LocationID   string `json:"location_id"`
+	Commodity    string `json:"commodity"`
+	OrderID      string `json:"order_id"`
+	CaravanID    string `json:"caravan_id"`
+	BuildingType string `json:"building_type"`
+	BuildingID   string `json:"building_id"`
+	Amount       int    `json:"amount"`
+	Price        int    `json:"price"`
+	Sacks        int    `json:"sacks"`
+	Reward       int    `json:"reward"`
+	Guards       int    `json:"guards"`
+}
+
+type apiChatRequest struct {
+	Text string `json:"text"`
+}
+
+type apiMissiveRequest struct {
+	TargetID string `json:"target_id"`
+	Subject  string `json:"subject"`
+	Body     string `json:"body"`
+	Sealed   bool   `json:"sealed"`
+}
+
+type apiChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+type apiContractChange struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// apiDelta is what a request changed, as seen by the caller.
+type apiDelta struct {
+	Player   map[string]apiChange `json:"player,omitempty"`
+	World    map[string]apiChange `json:"world,omitempty"`
+	Policies map[string]apiChange `json:"policies,omitempty"`
+	// Inventory and Supplies are keyed by commodity ID; Supplies covers the
+	// market where the player stands.
+	Inventory map[string]apiChange `json:"inventory,omitempty"`
+	Supplies  map[string]apiChange `json:"supplies,omitempty"`
+	Contracts []apiContractChange  `json:"contracts,omitempty"`
+	Events    []EventView          `json:"events,omitempty"`
+}
+
+// apiResult answers every mutating call. Message is the text the web UI
+// would have shown as a toast.
+type apiResult struct {
+	OK      bool      `json:"ok"`
+	Message string    `json:"message,omitempty"`
+	Error   *apiError `json:"error,omitempty"`
+	Delta   apiDelta  `json:"delta"`
+}
+
+// apiStatusByCode maps refusal codes to HTTP statuses; anything unlisted is
+// a 409 Conflict with the current game state.
+var apiStatusByCode = map[string]int{
+	errCodeCooldown:         http.StatusTooManyRequests,
+	errCodeInvalidInput:     http.StatusBadRequest,
+	errCodeUnknownAction:    http.StatusBadRequest,
+	errCodeBadRequest:       http.StatusBadRequest,
+	errCodeNotFound:         http.StatusNotFound,
+	errCodeUnauthorized:     http.StatusUnauthorized,
+	errCodeMethodNotAllowed: http.StatusMethodNotAllowed,
+}
+
+func apiStatusForCode(code string) int {
+	if status, ok := apiStatusByCode[code]; ok {
+		return status
+	}
+	return http.StatusConflict
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
+
+func writeAPIError(w http.ResponseWriter, code, message string) {
+	writeAPIJSON(w, apiStatusForCode(code), apiErrorResponse{Error: apiError{Code: code, Message: message}})
+}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newAPIToken() (string, error) {
+	raw, err := generateIDFromRandomBytes(32)
+	if err != nil {
+		return "", err
+	}
+	return apiTokenPrefix + raw, nil
+}
+
+func bearerToken(r *http.Request) string {
+	auth := strings.TrimSpace(r.Header.Get("Authorization"))
+	scheme, token, ok := strings.Cut(auth, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// apiPlayerLocked resolves the bearer token to its player, reviving it if the
+// daily cleanup had soft-deleted it. It needs the write lock.
+func apiPlayerLocked(store *Store, r *http.Request) *Player {
+	p := apiTokenPlayerLocked(store, r)
+	if p != nil {
+		store.dirty.mark(dirtyPlayers, p.ID)
+		p.SoftDeletedAt = time.Time{}
+	}
+	return p
+}
+
+// apiTokenPlayerLocked looks the bearer token up without changing anything
+// on a view; on the live store it may build the token index. Only the
+// token's hash is stored, on the player it was issued to.
+func apiTokenPlayerLocked(store *Store, r *http.Request) *Player {
+	token := bearerToken(r)
+	if !strings.HasPrefix(token, apiTokenPrefix) {
+		return nil
+	}
+	hash := hashAPIToken(token)
+	p := store.Players[apiTokenIndexLocked(store)[hash]]
+	if p == nil || !p.HardDeletedAt.IsZero() {
+		return nil
+	}
+	// The index may still name a player whose token has since rotated.
+	if subtle.ConstantTimeCompare([]byte(p.APITokenHash), []byte(hash)) != 1 {
+		return nil
+	}
+	return p
+}
+
+// apiTokenIndexLocked returns the store's token index, building it from the
+// players if the roster was replaced since it was last used.
+func apiTokenIndexLocked(store *Store) map[string]string {
+	if store.apiTokens == nil {
+		store.apiTokens = apiTokenIndex(store.Players)
+	}
+	return store.apiTokens
+}
+
+// setAPITokenHashLocked issues, rotates or (with an empty hash) revokes a
+// player's token, keeping the index in step. Callers journal the change so
+// rebuilds and rollbacks keep the tokens that were live at their tick.
+func setAPITokenHashLocked(store *Store, p *Player, hash string) {
+	store.dirty.mark(dirtyPlayers, p.ID)
+	store.dirty.markField(dirtyAPITokens)
+	if p.APITokenHash != "" {
+		delete(apiTokenIndexLocked(store), p.APITokenHash)
+	}
+	p.APITokenHash = hash
+	if hash != "" {
+		apiTokenIndexLocked(store)[hash] = p.ID
+	}
+}
+
+func apiTokenIndex(players map[string]*Player) map[string]string {
+	index := map[string]string{}
+	for id, p := range players {
+		if p.APITokenHash != "" {
+			index[p.APITokenHash] = id
+		}
+	}
+	return index
+}
+
+// decodeAPIBody reads a JSON request body, rejecting unknown fields so a
+// misspelt parameter fails loudly instead of being ignored.
+func decodeAPIBody(w http.ResponseWriter, r *http.Request, dst any) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, apiMaxBodyBytes)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeAPIError(w, errCodeBadRequest, "request body too large")
+			return false
+		}
+		writeAPIError(w, errCodeBadRequest, "invalid JSON body: "+err.Error())
+		return false
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		writeAPIError(w, errCodeBadRequest, "invalid JSON body: trailing data")
+		return false
+	}
+	return true
+}
+
+func apiPlayerFrom(p *Player) apiPlayer {
+	return apiPlayer{
+		ID:                 p.ID,
+		Name:               p.Name,
+		Title:              reputationTitle(p.Rep),
+		Gold:               p.Gold,
+		Grain:              p.Grain,
+		Rep:                p.Rep,
+		Heat:               p.Heat,
+		Rumors:             p.Rumors,
+		CompletedContracts: p.CompletedContracts,
+		RiteImmunityTicks:  p.RiteImmunityTicks,
+		BribeAccessTicks:   p.BribeAccessTicks,
+		LocationID:         p.LocationID,
+		TravelToID:         p.TravelToID,
+		TravelTicksLeft:    p.TravelTicksLeft,
+		TravelTotalTicks:   p.TravelTotalTicks,
+	}
+}
+
+func apiWorldFromLocked(store *Store) apiWorld {
+	w := store.World
+	return apiWorld{
+		DayNumber:              w.DayNumber,
+		Subphase:               w.Subphase,
+		Calendar:               calendarViewFor(w),
+		Tick:                   store.TickCount,
+		GrainSupply:            w.GrainSupply,
+		GrainTier:              w.GrainTier,
+		UnrestValue:            w.UnrestValue,
+		UnrestTier:             w.UnrestTier,
+		RestrictedMarketsTicks: w.RestrictedMarketsTicks,
+		WardNetworkTicks:       w.WardNetworkTicks,
+		Situation:              w.Situation,
+	}
+}
+
+func apiPoliciesFrom(ps PolicyState) apiPolicies {
+	return apiPolicies{
+		TaxRatePct:             ps.TaxRatePct,
+		PermitRequiredHighRisk: ps.PermitRequiredHighRisk,
+		SmugglingEmbargoTicks:  ps.SmugglingEmbargoTicks,
+	}
+}
+
+// apiViews builds each view from the same PageData the HTML fragments use,
+// so the API and the page never disagree.
+var apiViews = map[string]func(*Store, *Player, PageData) any{
+	"dashboard": func(store *Store, p *Player, d PageData) any {
+		return apiDashboardView{
+			Player:              apiPlayerFrom(p),
+			World:               apiWorldFromLocked(store),
+			TickStatus:          d.TickStatus,
+			Standing:            d.Standing,
+			HighImpactRemaining: d.HighImpactRemaining,
+			HighImpactCap:       d.HighImpactCap,
+			Contracts:           d.Contracts,
+			AcceptedContracts:   d.AcceptedCount,
+			VisibleContracts:    d.VisibleContractN,
+			TotalContracts:      d.TotalContractN,
+			Location: apiLocation{
+				Name:        d.LocationName,
+				Description: d.LocationDescription,
+				Traveling:   d.Traveling,
+				Destination: d.TravelDestination,
+				Options:     d.LocationOptions,
+			},
+			Fieldwork: apiFieldwork{
+				Available:      d.FieldworkAvailable,
+				Action:         d.FieldworkAction,
+				Label:          d.FieldworkLabel,
+				Description:    d.FieldworkDescription,
+				Disabled:       d.FieldworkDisabled,
+				DisabledReason: d.FieldworkDisabledReason,
+				SupplyCost:     d.FieldworkSupplyCost,
+			},
+			Relics:            d.Relics,
+			RelicAppraiseCost: d.RelicAppraiseCost,
+			Crisis:            d.Crisis,
+			Caravans:          d.Caravans,
+			Buildings:         d.Buildings,
+			BuildOptions:      d.BuildOptions,
+			Fight:             d.Fight,
+		}
+	},
+	"events": func(_ *Store, _ *Player, d PageData) any {
+		return apiEventsView{Events: d.Events}
+	},
+	"chat": func(_ *Store, _ *Player, d PageData) any {
+		return apiChatView{Chat: d.Chat}
+	},
+	"diplomacy": func(_ *Store, _ *Player, d PageData) any {
+		return apiDiplomacyView{
+			Messages:                d.Messages,
+			Recipients:              d.PlayerOptions,
+			SealMessageCost:         d.SealMessageCost,
+			SealMessageDisabled:     d.SealMessageDisabled,
+			SealMessageDisabledNote: d.SealMessageDisabledNote,
+		}
+	},
+	"players": func(_ *Store, _ *Player, d PageData) any {
+		return apiPlayersView{Players: d.Players}
+	},
+	"institutions": func(_ *Store, _ *Player, d PageData) any {
+		return apiInstitutionsView{
+			Seats:          d.Seats,
+			Treasuries:     d.Treasuries,
+			TreasuryLedger: d.TreasuryLedger,
+			Policies:       apiPoliciesFrom(d.Policies),
+			Permits:        d.Permits,
+			Warrants:       d.Warrants,
+			Cases:          d.Cases,
+			Projects:       d.Projects,
+			ProjectOptions: d.ProjectOptions,
+		}
+	},
+	"intel": func(_ *Store, _ *Player, d PageData) any {
+		return apiIntelView{
+			Rumors:                d.Rumors,
+			Evidence:              d.Evidence,
+			ScryReports:           d.ScryReports,
+			Intercepts:            d.Intercepts,
+			InvestigateDisabled:   d.InvestigateDisabled,
+			InvestigateLabel:      d.InvestigateLabel,
+			ForgeEvidenceCost:     d.ForgeEvidenceCost,
+			ForgeEvidenceDisabled: d.ForgeEvidenceDisabled,
+			ForgeEvidenceReason:   d.ForgeEvidenceReason,
+		}
+	},
+	"ledger": func(_ *Store, _ *Player, d PageData) any {
+		return apiLedgerView{Loans: d.Loans, Obligations: d.Obligations}
+	},
+	"market": func(_ *Store, _ *Player, d PageData) any {
+		return apiMarketView{
+			BasePrice:      d.MarketBasePrice,
+			BuyPrice:       d.MarketBuyPrice,
+			SellPrice:      d.MarketSellPrice,
+			SupplySacks:    d.MarketSupplySacks,
+			ControlsTicks:  d.MarketControlsTicks,
+			ControlsActive: d.MarketControlsActive,
+			Stockpile:      d.MarketStockpile,
+			MaxBuy:         d.MarketMaxBuy,
+			MaxSell:        d.MarketMaxSell,
+			BuyDisabled:    d.MarketBuyDisabled,
+			SellDisabled:   d.MarketSellDisabled,
+			ReliefCost:     d.ReliefCost,
+			ReliefDisabled: d.ReliefDisabled,
+			ReliefLabel:    d.ReliefLabel,
+			Location:       d.LocationName,
+			TaxNote:        d.MarketTaxNote,
+			Commodities:    d.Commodities,
+			Orders:         d.MarketOrders,
+		}
+	},
+}
+
+func apiStateLocked(store *Store, p *Player) apiState {
+	d := buildPageDataLocked(store, p.ID, false)
+	return apiState{
+		Dashboard:    apiViews["dashboard"](store, p, d).(apiDashboardView),
+		Events:       apiViews["events"](store, p, d).(apiEventsView),
+		Chat:         apiViews["chat"](store, p, d).(apiChatView),
+		Diplomacy:    apiViews["diplomacy"](store, p, d).(apiDiplomacyView),
+		Players:      apiViews["players"](store, p, d).(apiPlayersView),
+		Institutions: apiViews["institutions"](store, p, d).(apiInstitutionsView),
+		Intel:        apiViews["intel"](store, p, d).(apiIntelView),
+		Ledger:       apiViews["ledger"](store, p, d).(apiLedgerView),
+		Market:       apiViews["market"](store, p, d).(apiMarketView),
+	}
+}
+
+// apiBaseline captures what a request may change so the response can report
+// a delta instead of making the caller re-fetch and diff the state.
+type apiBaseline struct {
+	player      apiPlayer
+	world       apiWorld
+	policies    apiPolicies
+	inventory   map[string]int
+	supplies    map[string]int
+	contracts   map[string]string
+	lastEventID int64
+}
+
+func apiBaselineLocked(store *Store, p *Player) apiBaseline {
+	b := apiBaseline{
+		player:    apiPlayerFrom(p),
+		world:     apiWorldFromLocked(store),
+		policies:  apiPoliciesFrom(store.Policies),
+		inventory: map[string]int{},
+		supplies:  map[string]int{},
+		contracts: map[string]string{},
+	}
+	locationID := marketLocationID(p)
+	for _, def := range commodityDefinitions() {
+		b.inventory[def.ID] = holdingOf(p, def.ID)
+		if good, ok := localGood(locationID, def.ID); ok {
+			b.supplies[def.ID] = marketSupplyLocked(store, locationID, good)
+		}
+	}
+	for id, c := range store.Contracts {
+		b.contracts[id] = c.Status
+	}
+	if n := len(store.Events); n > 0 {
+		b.lastEventID = store.Events[n-1].ID
+	}
+	return b
+}
+
+func apiDeltaLocked(store *Store, p *Player, before apiBaseline) apiDelta {
+	after := apiBaselineLocked(store, p)
+	d := apiDelta{
+		Player:    diffAPIFields(before.player, after.player),
+		World:     diffAPIFields(before.world, after.world),
+		Policies:  diffAPIFields(before.policies, after.policies),
+		Inventory: diffAPICounts(before.inventory, after.inventory),
+		Supplies:  diffAPICounts(before.supplies, after.supplies),
+	}
+	ids := map[string]bool{}
+	for id := range before.contracts {
+		ids[id] = true
+	}
+	for id := range after.contracts {
+		ids[id] = true
+	}
+	for _, id := range sortedKeys(ids) {
+		from, to := before.contracts[id], after.contracts[id]
+		if from == to {
+			continue
+		}
+		change := apiContractChange{ID: id, From: from, To: to}
+		if c := store.Contracts[id]; c != nil {
+			change.Type = c.Type
+		}
+		d.Contracts = append(d.Contracts, change)
+	}
+	for _, e := range store.Events {
+		if e.ID > before.lastEventID {
+			d.Events = append(d.Events, EventView{DayNumber: e.DayNumber, Subphase: e.Subphase, Text: e.Text, At: e.At.Format("15:04:05")})
+		}
+	}
+	return d
+}
+
+// diffAPIFields compares two values of the same flat struct field by field,
+// keyed by JSON name.
+func diffAPIFields[T any](before, after T) map[string]apiChange {
+	bv, av := reflect.ValueOf(before), reflect.ValueOf(after)
+	var out map[string]apiChange
+	for i := 0; i < bv.NumField(); i++ {
+		from, to := bv.Field(i).Interface(), av.Field(i).Interface()
+		if from == to {
+			continue
+		}
+		if out == nil {
+			out = map[string]apiChange{}
+		}
+		name, _, _ := strings.Cut(bv.Type().Field(i).Tag.Get("json"), ",")
+		out[name] = apiChange{From: from, To: to}
+	}
+	return out
+}
+
+// diffAPICounts compares two sets of counts key by key; a missing key counts
+// as zero.
+func diffAPICounts(before, after map[string]int) map[string]apiChange {
+	var out map[string]apiChange
+	for _, id := range sortedKeys(after) {
+		if from, to := before[id], after[id]; from != to {
+			if out == nil {
+				out = map[string]apiChange{}
+			}
+			out[id] = apiChange{From: from, To: to}
+		}
+	}
+	return out
+}
+
+// apiResultLocked turns the outcome of a submit* call into the response.
+// The toast is consumed because the caller has now seen it.
+func apiResultLocked(store *Store, p *Player, before apiBaseline) (int, any) {
+	res := apiResult{OK: true, Message: popToastLocked(store, p.ID), Delta: apiDeltaLocked(store, p, before)}
+	status := http.StatusOK
+	if code := takeRejectionLocked(store, p.ID); code != "" {
+		res.OK = false
+		res.Error = &apiError{Code: code, Message: res.Message}
+		status = apiStatusForCode(code)
+	}
+	return status, res
+}
+
+func apiErrorBody(code, message string) (int, any) {
+	return apiStatusForCode(code), apiErrorResponse{Error: apiError{Code: code, Message: message}}
+}
+
+// apiReadHandler serves a GET from the read view; h builds the response
+// value from the store it is given. A token whose player has to be revived
+// is sent to the writer instead, and h then reads the live store.
+func apiReadHandler(store *Store, h func(store *Store, r *http.Request, p *Player) (int, any)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeAPIError(w, errCodeMethodNotAllowed, "use GET")
+			return
+		}
+		now := time.Now().UTC()
+		status, body := apiErrorBody(errCodeUnauthorized, "missing or invalid bearer token")
+		view := store.readView()
+		p := apiTokenPlayerLocked(view, r)
+		readable := p != nil && p.SoftDeletedAt.IsZero()
+		if readable {
+			status, body = h(view, r, p)
+		}
+
+		if readable {
+			store.touch(p.ID, now)
+		} else if p != nil {
+			store.writeTracked(func() {
+				if p := apiPlayerLocked(store, r); p != nil {
+					p.LastSeen = now
+					status, body = h(store, r, p)
+				}
+			})
+		}
+		writeAPIJSON(w, status, body)
+	}
+}
+
+// apiWriteHandler decodes a JSON body of type T and runs h on the writer.
+func apiWriteHandler[T any](store *Store, h func(req T, p *Player, now time.Time) (int, any)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeAPIError(w, errCodeMethodNotAllowed, "use POST")
+			return
+		}
+		known := apiTokenPlayerLocked(store.readView(), r) != nil
+		if !known {
+			writeAPIError(w, errCodeUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		var req T
+		if !decodeAPIBody(w, r, &req) {
+			return
+		}
+		// Checked again on the writer: the token may be revoked meanwhile.
+		status, body := apiErrorBody(errCodeUnauthorized, "missing or invalid bearer token")
+		store.writeTracked(func() {
+			p := apiPlayerLocked(store, r)
+			if p == nil {
+				return
+			}
+			now := time.Now().UTC()
+			p.LastSeen = now
+			status, body = h(req, p, now)
+		})
+		writeAPIJSON(w, status, body)
+	}
+}
+
+func registerAPIRoutes(mux *http.ServeMux, store *Store) {
+	// POST issues a token for the cookie's player, or for a new player when
+	// there is no cookie; issuing again rotates it. DELETE revokes the token
+	// presented in the Authorization header.
+	mux.HandleFunc("/api/v1/token", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			token, err := newAPIToken()
+			if err != nil {
+				http.Error(w, "failed to issue token", http.StatusInternalServerError)
+				return
+			}
+			var res apiTokenResponse
+			store.writeTracked(func() {
+				p := ensurePlayerLocked(store, w, r)
+				now := time.Now().UTC()
+				p.LastSeen = now
+				hash := hashAPIToken(token)
+				recordJournalLocked(store, JournalEntry{Kind: journalKindToken, PlayerID: p.ID, At: now, TokenHash: hash})
+				setAPITokenHashLocked(store, p, hash)
+				res = apiTokenResponse{Token: token, PlayerID: p.ID, Name: p.Name}
+			})
+			writeAPIJSON(w, http.StatusCreated, res)
+		case http.MethodDelete:
+			revoked := false
+			store.writeTracked(func() {
+				if p := apiPlayerLocked(store, r); p != nil {
+					recordJournalLocked(store, JournalEntry{Kind: journalKindToken, PlayerID: p.ID, At: time.Now().UTC()})
+					setAPITokenHashLocked(store, p, "")
+					revoked = true
+				}
+			})
+			if !revoked {
+				writeAPIError(w, errCodeUnauthorized, "missing or invalid bearer token")
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeAPIError(w, errCodeMethodNotAllowed, "use POST or DELETE")
+		}
+	})
+
+	mux.HandleFunc("/api/v1/state", apiReadHandler(store, func(store *Store, r *http.Request, p *Player) (int, any) {
+		return http.StatusOK, apiStateLocked(store, p)
+	}))
+
+	mux.HandleFunc(apiViewPathPrefix, apiReadHandler(store, func(store *Store, r *http.Request, p *Player) (int, any) {
+		name := strings.TrimPrefix(r.URL.Path, apiViewPathPrefix)
+		build, ok := apiViews[name]
+		if !ok {
+			return apiErrorBody(errCodeNotFound, "unknown view; expected one of "+strings.Join(sortedKeys(apiViews), ", "))
+		}
+		return http.StatusOK, build(store, p, buildPageDataLocked(store, p.ID, false))
+	}))
+
+	mux.HandleFunc("/api/v1/markets/history", apiReadHandler(store, func(store *Store, r *http.Request, p *Player) (int, any) {
+		return apiMarketHistoryLocked(store, p, r.URL.Query())
+	}))
+
+	mux.HandleFunc("/api/v1/actions", apiWriteHandler(store, func(req apiActionRequest, p *Player, now time.Time) (int, any) {
+		input := ActionInput{
+			Action:       strings.TrimSpace(req.Action),
+			ContractID:   strings.TrimSpace(req.ContractID),
+			Stance:       strings.TrimSpace(req.Stance),
+			TargetID:     strings.TrimSpace(req.TargetID),
+			RelicID:      strings.TrimSpace(req.RelicID),
+			Claim:        strings.TrimSpace(req.Claim),
+			Topic:        strings.TrimSpace(req.Topic),
+			LoanID:       strings.TrimSpace(req.LoanID),
+			ObligationID: strings.TrimSpace(req.ObligationID),
+			ProjectType:  strings.TrimSpace(req.ProjectType),
+			LocationID:   strings.TrimSpace(req.LocationID),
+			Commodity:    strings.TrimSpace(req.Commodity),
+			OrderID:      strings.TrimSpace(req.OrderID),
+			CaravanID:    strings.TrimSpace(req.CaravanID),
+			BuildingType: strings.TrimSpace(req.BuildingType),
+			BuildingID:   strings.TrimSpace(req.BuildingID),
+			Amount:       req.Amount,
+			Price:        req.Price,
+			Sacks:        req.Sacks,
+			Reward:       req.Reward,
+			Guards:       req.Guards,
+		}
+		takeRejectionLocked(store, p.ID)
+		before := apiBaselineLocked(store, p)
+		submitActionLocked(store, p, now, input)
+		return apiResultLocked(store, p, before)
+	}))
+
+	mux.HandleFunc("/api/v1/chat", apiWriteHandler(store, func(req apiChatRequest, p *Player, now time.Time) (int, any) {
+		msg := strings.TrimSpace(req.Text)
+		if msg == "" {
+			return apiErrorBody(errCodeInvalidInput, "text is required")
+		}
+		takeRejectionLocked(store, p.ID)
+		before := apiBaselineLocked(store, p)
+		submitChatLocked(store, p, now, msg)
+		return apiResultLocked(store, p, before)
+	}))
+
+	mux.HandleFunc("/api/v1/missives", apiWriteHandler(store, func(req apiMissiveRequest, p *Player, now time.Time) (int, any) {
+		in := MissiveInput{
+			TargetID: strings.TrimSpace(req.TargetID),
+			Subject:  strings.TrimSpace(req.Subject),
+			Body:     strings.TrimSpace(req.Body),
+			Sealed:   req.Sealed,
+		}
+		takeRejectionLocked(store, p.ID)
+		before := apiBaselineLocked(store, p)
+		submitMissiveLocked(store, p, now, in)
+		return apiResultLocked(store, p, before)
+	}))
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func doAPIReq(t *testing.T, mux http.Handler, method, target, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.RemoteAddr = "127.0.0.1:12345"
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	return rr
+}
+
+func decodeAPIResult(t *testing.T, rr *httptest.ResponseRecorder) apiResult {
+	t.Helper()
+	var res apiResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decode %q: %v", rr.Body.String(), err)
+	}
+	return res
+}
+
+func TestAPITokenAuthAndViews(t *testing.T) {
+	s := newTestStore()
+	mux := newMux(s, parseTemplates())
+
+	if rr := doAPIReq(t, mux, http.MethodGet, "/api/v1/state", "", ""); rr.Code != http.StatusUnauthorized || !strings.Contains(rr.Body.String(), `"code":"unauthorized"`) {
+		t.Fatalf("state without token: %d %s", rr.Code, rr.Body.String())
+	}
+
+	doReq(t, mux, http.MethodGet, "/", nil, "bot-owner", "")
+	rr := doReq(t, mux, http.MethodPost, "/api/v1/token", nil, "bot-owner", "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("issue token: %d %s", rr.Code, rr.Body.String())
+	}
+	var issued apiTokenResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &issued); err != nil {
+		t.Fatalf("decode token: %v", err)
+	}
+	if issued.PlayerID != "bot-owner" || !strings.HasPrefix(issued.Token, apiTokenPrefix) {
+		t.Fatalf("unexpected token response: %+v", issued)
+	}
+	if s.Players["bot-owner"].APITokenHash == issued.Token {
+		t.Fatalf("token must be stored hashed")
+	}
+
+	rr = doAPIReq(t, mux, http.MethodGet, "/api/v1/state", issued.Token, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("state: %d %s", rr.Code, rr.Body.String())
+	}
+	var state apiState
+	if err := json.Unmarshal(rr.Body.Bytes(), &state); err != nil {
+		t.Fatalf("decode state: %v", err)
+	}
+	humans := 0
+	for _, pl := range state.Players.Players {
+		if pl.NPC == "" {
+			humans++
+		}
+	}
+	if state.Dashboard.Player.ID != "bot-owner" || state.Dashboard.World.DayNumber != 1 || humans != 1 {
+		t.Fatalf("unexpected state: %+v", state.Dashboard)
+	}
+
+	rr = doAPIReq(t, mux, http.MethodGet, "/api/v1/views/market", issued.Token, "")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"buy_price"`) {
+		t.Fatalf("market view: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := doAPIReq(t, mux, http.MethodGet, "/api/v1/views/granary", issued.Token, ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("unknown view status = %d", rr.Code)
+	}
+
+	if rr := doAPIReq(t, mux, http.MethodDelete, "/api/v1/token", issued.Token, ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("revoke: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := doAPIReq(t, mux, http.MethodGet, "/api/v1/state", issued.Token, ""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("revoked token still accepted: %d", rr.Code)
+	}
+}
+
+func TestAPIActionsReportCodesAndDeltas(t *testing.T) {
+	s := newTestStore()
+	mux := newMux(s, parseTemplates())
+	doReq(t, mux, http.MethodGet, "/", nil, "bot", "")
+	rr := doReq(t, mux, http.MethodPost, "/api/v1/token", nil, "bot", "")
+	var issued apiTokenResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &issued)
+	p := s.Players["bot"]
+
+	act := func(body string) (int, apiResult) {
+		t.Helper()
+		delete(s.LastActionAt, p.ID)
+		rr := doAPIReq(t, mux, http.MethodPost, "/api/v1/actions", issued.Token, body)
+		return rr.Code, decodeAPIResult(t, rr)
+	}
+
+	goldBefore := p.Gold
+	code, res := act(`{"action":"buy_grain","amount":1}`)
+	if code != http.StatusOK || !res.OK || res.Delta.Player["grain"].To != float64(1) {
+		t.Fatalf("buy: %d %+v", code, res)
+	}
+	if res.Delta.Player["gold"].From != float64(goldBefore) {
+		t.Fatalf("gold delta should start at %d: %+v", goldBefore, res.Delta.Player["gold"])
+	}
+	if s.ToastByPlayer[p.ID] != "" {
+		t.Fatalf("API should consume the toast it reported")
+	}
+
+	p.Gold = 0
+	code, res = act(`{"action":"buy_grain","amount":1}`)
+	if code != http.StatusConflict || res.OK || res.Error == nil || res.Error.Code != errCodeInsufficientGold {
+		t.Fatalf("expected insufficient_gold: %d %+v", code, res)
+	}
+
+	doAPIReq(t, mux, http.MethodPost, "/api/v1/actions", issued.Token, `{"action":"investigate"}`)
+	rr = doAPIReq(t, mux, http.MethodPost, "/api/v1/actions", issued.Token, `{"action":"investigate"}`)
+	if res := decodeAPIResult(t, rr); rr.Code != http.StatusTooManyRequests || res.Error.Code != errCodeCooldown {
+		t.Fatalf("expected cooldown: %d %s", rr.Code, rr.Body.String())
+	}
+
+	p.TravelTicksLeft, p.TravelToID = 2, locationHarbor
+	if _, res = act(`{"action":"investigate"}`); res.Error == nil || res.Error.Code != errCodeTravelLockout {
+		t.Fatalf("expected travel_lockout: %+v", res)
+	}
+	p.TravelTicksLeft, p.TravelToID = 0, ""
+
+	s.DailyActionDate[p.ID] = time.Now().UTC().Format("2006-01-02")
+	s.DailyHighImpactN[p.ID] = highImpactDailyCap
+	other := addPlayerLocked(s, "mark", "Mark", p.LastSeen)
+	s.Evidence[1] = &Evidence{ID: 1, SourcePlayerID: p.ID, TargetPlayerID: other.ID, Strength: 3, ExpiryTick: s.TickCount + 10}
+	if _, res = act(`{"action":"publish_evidence","target_id":"mark"}`); res.Error == nil || res.Error.Code != errCodeHighImpactCap {
+		t.Fatalf("expected high_impact_cap: %+v", res)
+	}
+
+	if code, res = act(`{"action":"juggle"}`); code != http.StatusBadRequest || res.Error.Code != errCodeUnknownAction {
+		t.Fatalf("expected unknown_action: %d %+v", code, res)
+	}
+	if rr := doAPIReq(t, mux, http.MethodPost, "/api/v1/actions", issued.Token, `{"verb":"buy_grain"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("unknown fields should be rejected, got %d", rr.Code)
+	}
+
+	rr = doAPIReq(t, mux, http.MethodPost, "/api/v1/chat", issued.Token, `{"text":"hello from the bot"}`)
+	if res := decodeAPIResult(t, rr); rr.Code != http.StatusOK || !res.OK || s.Chat[len(s.Chat)-1].Text != "hello from the bot" {
+		t.Fatalf("chat: %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = doAPIReq(t, mux, http.MethodPost, "/api/v1/missives", issued.Token, `{"target_id":"nobody","subject":"hi","body":"there"}`)
+	if res := decodeAPIResult(t, rr); rr.Code != http.StatusNotFound || res.Error.Code != errCodeNotFound {
+		t.Fatalf("missive to unknown player: %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestAPIReportsFailedActionsAsRejections(t *testing.T) {
+	s := newTestStore()
+	mux := newMux(s, parseTemplates())
+	doReq(t, mux, http.MethodGet, "/", nil, "bot", "")
+	rr := doReq(t, mux, http.MethodPost, "/api/v1/token", nil, "bot", "")
+	var issued apiTokenResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &issued)
+	p := s.Players["bot"]
+	lender := addPlayerLocked(s, "lender", "Lender", p.LastSeen)
+
+	act := func(body string) apiResult {
+		t.Helper()
+		s.write(func() { delete(s.LastActionAt, p.ID) })
+		return decodeAPIResult(t, doAPIReq(t, mux, http.MethodPost, "/api/v1/actions", issued.Token, body))
+	}
+
+	s.write(func() {
+		lender.Gold = 0
+		s.Loans["L1"] = &Loan{ID: "L1", LenderPlayerID: lender.ID, BorrowerPlayerID: p.ID, Principal: 10, Remaining: 10, Status: "Offered"}
+	})
+	if res := act(`{"action":"loan_accept","loan_id":"L1"}`); res.OK || res.Error == nil || res.Error.Code != errCodeNotAllowed {
+		t.Fatalf("unfunded loan should be rejected: %+v", res)
+	}
+	if s.Loans["L1"].Status != "Cancelled" {
+		t.Fatalf("unfunded loan should still be cancelled, got %q", s.Loans["L1"].Status)
+	}
+
+	if res := act(`{"action":"counter_narrative","target_id":"lender"}`); res.OK || res.Error == nil || res.Error.Code != errCodeNotFound {
+		t.Fatalf("counter with no rumors should be rejected: %+v", res)
+	}
+
+	s.write(func() {
+		for id := range s.Contracts {
+			delete(s.Contracts, id)
+		}
+	})
+	gold := p.Gold
+	if res := act(`{"action":"broker_deal"}`); res.OK || res.Error == nil || res.Error.Code != errCodeNotFound {
+		t.Fatalf("broker with no contracts should be rejected: %+v", res)
+	}
+	if p.Gold != gold {
+		t.Fatalf("a refused broker deal should not charge, gold %d -> %d", gold, p.Gold)
+	}
+
+	s.write(func() { s.LastInvestigateAt[p.ID] = s.TickCount })
+	if res := act(`{"action":"investigate"}`); res.OK || res.Error == nil || res.Error.Code != errCodeCooldown {
+		t.Fatalf("investigating on cooldown should be rejected: %+v", res)
+	}
+}
+
+func TestAPITokenRotationRetiresTheOldToken(t *testing.T) {
+	s := newTestStore()
+	mux := newMux(s, parseTemplates())
+	doReq(t, mux, http.MethodGet, "/", nil, "bot", "")
+	issue := func() string {
+		t.Helper()
+		var issued apiTokenResponse
+		_ = json.Unmarshal(doReq(t, mux, http.MethodPost, "/api/v1/token", nil, "bot", "").Body.Bytes(), &issued)
+		return issued.Token
+	}
+	first := issue()
+	second := issue()
+	if rr := doAPIReq(t, mux, http.MethodGet, "/api/v1/state", first, ""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("rotated-out token still accepted: %d", rr.Code)
+	}
+	if rr := doAPIReq(t, mux, http.MethodGet, "/api/v1/state", second, ""); rr.Code != http.StatusOK {
+		t.Fatalf("current token rejected: %d %s", rr.Code, rr.Body.String())
+	}
+	if got := s.apiTokens[hashAPIToken(second)]; got != "bot" {
+		t.Fatalf("token index should map the current token to its player, got %q", got)
+	}
+	if _, ok := s.apiTokens[hashAPIToken(first)]; ok {
+		t.Fatalf("token index should drop the rotated-out token")
+	}
+}
+
+func TestAPITokenChangesReplayFromTheJournal(t *testing.T) {
+	s := newTestStore()
+	base := asJSON(snapshotStoreLocked(s))
+	mux := newMux(s, parseTemplates())
+	doReq(t, mux, http.MethodGet, "/", nil, "bot", "")
+	doReq(t, mux, http.MethodGet, "/", nil, "spare", "")
+	var issued apiTokenResponse
+	_ = json.Unmarshal(doReq(t, mux, http.MethodPost, "/api/v1/token", nil, "bot", "").Body.Bytes(), &issued)
+	_ = json.Unmarshal(doReq(t, mux, http.MethodPost, "/api/v1/token", nil, "spare", "").Body.Bytes(), &issued)
+	if rr := doAPIReq(t, mux, http.MethodDelete, "/api/v1/token", issued.Token, ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("revoke status=%d", rr.Code)
+	}
+	for _, e := range s.journalPending {
+		if e.Kind == journalKindToken && strings.Contains(asJSON(e), issued.Token) {
+			t.Fatalf("the journal should carry only the token hash: %+v", e)
+		}
+	}
+
+	replayed, err := restoreStoreSnapshot(base)
+	if err != nil {
+		t.Fatalf("restore snapshot: %v", err)
+	}
+	replayJournalLocked(replayed, s.journalPending, s.TickCount+1)
+	for _, id := range []string{"bot", "spare"} {
+		if got, want := replayed.Players[id].APITokenHash, s.Players[id].APITokenHash; got != want {
+			t.Fatalf("%s token hash after replay = %q, want %q", id, got, want)
+		}
+	}
+	if replayed.Players["bot"].APITokenHash == "" || replayed.Players["spare"].APITokenHash != "" {
+		t.Fatalf("replay should keep the issued token and drop the revoked one")
+	}
+}
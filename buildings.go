@@ -0,0 +1,548 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	buildingMaxPerPlayer = 4
+	// buildingHistoryTicks is how many ticks of output each building keeps.
+	buildingHistoryTicks = 24
+	// A building whose owner cannot cover its upkeep loses
+	// buildingNeglectDecay condition a tick.
+	buildingNeglectDecay = 5
+	// The city buys buildings back at buildingSalePct of their cost, scaled
+	// by condition; repairs cost buildingRepairPct of the cost per point of
+	// condition restored, in hundredths.
+	buildingSalePct   = 50
+	buildingRepairPct = 50
+)
+
+// BuildingDefinition is a kind of production building players can raise.
+type BuildingDefinition struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Locations are where it can be built.
+	Locations []string `json:"locations"`
+	CostGold  int      `json:"cost_gold"`
+	// Costs names further commodity costs by ID.
+	Costs      map[string]int `json:"costs,omitempty"`
+	BuildTicks int            `json:"build_ticks"`
+	// Output is produced every tick at full condition, once Inputs,
+	// UpkeepGold and LaborGrain (the workers' bread) have been paid.
+	Output     map[string]int `json:"output"`
+	Inputs     map[string]int `json:"inputs,omitempty"`
+	UpkeepGold int            `json:"upkeep_gold"`
+	LaborGrain int            `json:"labor_grain"`
+}
+
+// Building is a player's production building. OwnerPlayerID is empty while
+// the city holds it after a seizure; it is then for sale.
+type Building struct {
+	ID            string
+	Type          string
+	Name          string
+	OwnerPlayerID string
+	OwnerName     string
+	LocationID    string
+	// Condition is 0 to 100; output scales with it and a ruin produces
+	// nothing until repaired.
+	Condition      int
+	BuildTicksLeft int
+	BuiltTick      int64
+	// ForSalePrice is the asking price when listed, or 0.
+	ForSalePrice int
+	History      []BuildingOutput
+	TotalOutput  map[string]int `json:",omitempty"`
+	// Definition is the building as defined when it was raised, so content
+	// reloads do not change a building already standing.
+	Definition *BuildingDefinition `json:",omitempty"`
+}
+
+// BuildingOutput is one tick of a building's production. Note says why it
+// produced less than its full output.
+type BuildingOutput struct {
+	Tick     int64
+	Produced int
+	Note     string `json:",omitempty"`
+}
+
+type BuildingView struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	Location       string `json:"location"`
+	OwnerName      string `json:"owner_name"`
+	Mine           bool   `json:"mine"`
+	Status         string `json:"status"`
+	Condition      int    `json:"condition"`
+	OutputLabel    string `json:"output_label"`
+	UpkeepLabel    string `json:"upkeep_label"`
+	LastNote       string `json:"last_note,omitempty"`
+	HistoryPoints  string `json:"-"`
+	HistoryTicks   int    `json:"history_ticks"`
+	RecentOutput   int    `json:"recent_output"`
+	TotalLabel     string `json:"total_label"`
+	ForSalePrice   int    `json:"for_sale_price"`
+	SaleValue      int    `json:"sale_value"`
+	RepairCost     int    `json:"repair_cost"`
+	CanRepair      bool   `json:"can_repair"`
+	CanBuy         bool   `json:"can_buy"`
+	CanSeize       bool   `json:"can_seize"`
+	BuyDisabledFor string `json:"buy_disabled_reason,omitempty"`
+}
+
+// BuildingOption is a building the player could raise where they stand.
+type BuildingOption struct {
+	Type           string `json:"type"`
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	CostLabel      string `json:"cost_label"`
+	OutputLabel    string `json:"output_label"`
+	UpkeepLabel    string `json:"upkeep_label"`
+	BuildTicks     int    `json:"build_ticks"`
+	Disabled       bool   `json:"disabled"`
+	DisabledReason string `json:"disabled_reason,omitempty"`
+}
+
+func buildingDefinitions() []BuildingDefinition {
+	return currentContent().Buildings
+}
+
+func buildingDefinitionByType(buildingType string) (BuildingDefinition, bool) {
+	for _, def := range buildingDefinitions() {
+		if def.Type == buildingType {
+			return def, true
+		}
+	}
+	return BuildingDefinition{}, false
+}
+
+// buildingDefinitionFor returns the definition a building was raised under,
+// falling back to current content.
+func buildingDefinitionFor(b *Building) (BuildingDefinition, bool) {
+	if b.Definition != nil {
+		return *b.Definition, true
+	}
+	return buildingDefinitionByType(b.Type)
+}
+
+// buildingSeq orders IDs numerically so "b-10" sorts after "b-9".
+func buildingSeq(id string) int64 {
+	n, _ := strconv.ParseInt(strings.TrimPrefix(id, "b-"), 10, 64)
+	return n
+}
+
+func sortedBuildingIDs(buildings map[string]*Building) []string {
+	ids := sortedKeys(buildings)
+	slices.SortFunc(ids, func(a, b string) int { return int(buildingSeq(a) - buildingSeq(b)) })
+	return ids
+}
+
+func ownedBuildingCount(store *Store, playerID string) int {
+	n := 0
+	for _, b := range store.Buildings {
+		if b.OwnerPlayerID == playerID {
+			n++
+		}
+	}
+	return n
+}
+
+func buildingUpkeepLabel(def BuildingDefinition) string {
+	return costLabel(def.UpkeepGold, commodityCostList(def.LaborGrain, def.Inputs))
+}
+
+// buildingSaleValue is what the city pays for b.
+func buildingSaleValue(b *Building) int {
+	def, _ := buildingDefinitionFor(b)
+	return def.CostGold * buildingSalePct / 100 * b.Condition / 100
+}
+
+func buildingRepairCost(b *Building) int {
+	def, _ := buildingDefinitionFor(b)
+	missing := 100 - b.Condition
+	return (def.CostGold*buildingRepairPct*missing + 9999) / 10000
+}
+
+func buildingStatus(b *Building) string {
+	switch {
+	case b.BuildTicksLeft > 0:
+		return fmt.Sprintf("Under construction (%dt)", b.BuildTicksLeft)
+	case b.OwnerPlayerID == "":
+		return "Held by the city"
+	case b.Condition == 0:
+		return "Ruined"
+	case b.ForSalePrice > 0:
+		return fmt.Sprintf("For sale at %dg", b.ForSalePrice)
+	default:
+		return "Working"
+	}
+}
+
+func buildBuildingLocked(store *Store, p *Player, now time.Time, buildingType string) {
+	def, ok := buildingDefinitionByType(strings.TrimSpace(buildingType))
+	if !ok {
+		rejectLocked(store, p.ID, errCodeNotFound, "That building is unknown.")
+		return
+	}
+	here := marketLocationID(p)
+	if !slices.Contains(def.Locations, here) {
+		rejectLocked(store, p.ID, errCodeTravelLockout, fmt.Sprintf("No %s can be built in %s.", def.Name, locationName(here)))
+		return
+	}
+	if ownedBuildingCount(store, p.ID) >= buildingMaxPerPlayer {
+		rejectLocked(store, p.ID, errCodeNotAllowed, fmt.Sprintf("You already hold %d buildings.", buildingMaxPerPlayer))
+		return
+	}
+	costs := commodityCostList(0, def.Costs)
+	if code, need := costShortfall(p, def.CostGold, costs); code != "" {
+		rejectLocked(store, p.ID, code, need+" to build.")
+		return
+	}
+	payCosts(p, def.CostGold, costs)
+	store.NextBuildingID++
+	b := &Building{
+		ID:             fmt.Sprintf("b-%d", store.NextBuildingID),
+		Type:           def.Type,
+		Name:           def.Name,
+		OwnerPlayerID:  p.ID,
+		OwnerName:      p.Name,
+		LocationID:     here,
+		Condition:      100,
+		BuildTicksLeft: def.BuildTicks,
+		BuiltTick:      store.TickCount,
+		Definition:     &def,
+	}
+	store.dirty.mark(dirtyBuildings, b.ID)
+	store.Buildings[b.ID] = b
+	addEventLocked(store, Event{Type: "Holding", Severity: 1, Text: fmt.Sprintf("[%s] breaks ground on the %s in %s.", p.Name, def.Name, locationName(here)), At: now})
+	setToastLocked(store, p.ID, fmt.Sprintf("%s under construction (%dt).", def.Name, def.BuildTicks))
+}
+
+// ownBuildingLocked returns the building p owns with id, rejecting the
+// action otherwise.
+func ownBuildingLocked(store *Store, p *Player, id string) *Building {
+	b := store.Buildings[strings.TrimSpace(id)]
+	if b == nil || b.OwnerPlayerID != p.ID {
+		rejectLocked(store, p.ID, errCodeNotFound, "You hold no such building.")
+		return nil
+	}
+	return b
+}
+
+// sellBuildingLocked lists b for sale at price, or sells it to the city
+// when price is 0.
+func sellBuildingLocked(store *Store, p *Player, now time.Time, id string, price int) {
+	b := ownBuildingLocked(store, p, id)
+	if b == nil {
+		return
+	}
+	if price < 0 {
+		rejectLocked(store, p.ID, errCodeInvalidInput, "Choose an asking price.")
+		return
+	}
+	if price > 0 {
+		store.dirty.mark(dirtyBuildings, b.ID)
+		b.ForSalePrice = price
+		addEventLocked(store, Event{Type: "Holding", Severity: 1, Text: fmt.Sprintf("[%s] offers the %s in %s for %dg.", p.Name, b.Name, locationName(b.LocationID), price), At: now})
+		setToastLocked(store, p.ID, fmt.Sprintf("%s listed at %dg.", b.Name, price))
+		return
+	}
+	value := buildingSaleValue(b)
+	p.Gold += value
+	store.dirty.mark(dirtyBuildings, b.ID)
+	delete(store.Buildings, b.ID)
+	addEventLocked(store, Event{Type: "Holding", Severity: 1, Text: fmt.Sprintf("[%s] sells the %s in %s to the city.", p.Name, b.Name, locationName(b.LocationID)), At: now})
+	setToastLocked(store, p.ID, fmt.Sprintf("Sold the %s for %dg.", b.Name, value))
+}
+
+func delistBuildingLocked(store *Store, p *Player, id string) {
+	b := ownBuildingLocked(store, p, id)
+	if b == nil {
+		return
+	}
+	store.dirty.mark(dirtyBuildings, b.ID)
+	b.ForSalePrice = 0
+	setToastLocked(store, p.ID, fmt.Sprintf("%s taken off the market.", b.Name))
+}
+
+func buyBuildingLocked(store *Store, p *Player, now time.Time, id string) {
+	b := store.Buildings[strings.TrimSpace(id)]
+	switch {
+	case b == nil || b.ForSalePrice <= 0:
+		rejectLocked(store, p.ID, errCodeNotFound, "That building is not for sale.")
+		return
+	case b.OwnerPlayerID == p.ID:
+		rejectLocked(store, p.ID, errCodeNotAllowed, "You already own it.")
+		return
+	case marketLocationID(p) != b.LocationID:
+		rejectLocked(store, p.ID, errCodeTravelLockout, fmt.Sprintf("Travel to %s to inspect the %s.", locationName(b.LocationID), b.Name))
+		return
+	case ownedBuildingCount(store, p.ID) >= buildingMaxPerPlayer:
+		rejectLocked(store, p.ID, errCodeNotAllowed, fmt.Sprintf("You already hold %d buildings.", buildingMaxPerPlayer))
+		return
+	case p.Gold < b.ForSalePrice:
+		rejectLocked(store, p.ID, errCodeInsufficientGold, fmt.Sprintf("Need %dg.", b.ForSalePrice))
+		return
+	}
+	price := b.ForSalePrice
+	p.Gold -= price
+	seller := "the city"
+	if prev := store.Players[b.OwnerPlayerID]; prev != nil {
+		store.dirty.mark(dirtyPlayers, prev.ID)
+		prev.Gold += price
+		seller = "[" + prev.Name + "]"
+		setToastLocked(store, prev.ID, fmt.Sprintf("%s bought your %s for %dg.", p.Name, b.Name, price))
+	}
+	store.dirty.mark(dirtyBuildings, b.ID)
+	b.OwnerPlayerID, b.OwnerName, b.ForSalePrice = p.ID, p.Name, 0
+	addEventLocked(store, Event{Type: "Holding", Severity: 1, Text: fmt.Sprintf("[%s] buys the %s in %s from %s for %dg.", p.Name, b.Name, locationName(b.LocationID), seller, price), At: now})
+	setToastLocked(store, p.ID, fmt.Sprintf("You now own the %s.", b.Name))
+}
+
+func repairBuildingLocked(store *Store, p *Player, now time.Time, id string) {
+	b := ownBuildingLocked(store, p, id)
+	if b == nil {
+		return
+	}
+	if b.Condition >= 100 {
+		rejectLocked(store, p.ID, errCodeNotAllowed, "It needs no repairs.")
+		return
+	}
+	if marketLocationID(p) != b.LocationID {
+		rejectLocked(store, p.ID, errCodeTravelLockout, fmt.Sprintf("Travel to %s to oversee repairs.", locationName(b.LocationID)))
+		return
+	}
+	cost := buildingRepairCost(b)
+	if p.Gold < cost {
+		rejectLocked(store, p.ID, errCodeInsufficientGold, fmt.Sprintf("Need %dg for repairs.", cost))
+		return
+	}
+	p.Gold -= cost
+	store.dirty.mark(dirtyBuildings, b.ID)
+	b.Condition = 100
+	addEventLocked(store, Event{Type: "Holding", Severity: 1, Text: fmt.Sprintf("[%s] repairs the %s in %s.", p.Name, b.Name, locationName(b.LocationID)), At: now})
+	setToastLocked(store, p.ID, fmt.Sprintf("%s repaired for %dg.", b.Name, cost))
+}
+
+// seizeBuildingLocked lets the Commander of the Watch confiscate a building
+// from a player under warrant. The city lists it at its sale value.
+func seizeBuildingLocked(store *Store, p *Player, now time.Time, id string) {
+	if !playerHoldsSeatLocked(store, p.ID, "watch_commander") {
+		rejectLocked(store, p.ID, errCodeNotAllowed, "Only the Commander of the Watch can seize property.")
+		return
+	}
+	b := store.Buildings[strings.TrimSpace(id)]
+	if b == nil || b.OwnerPlayerID == "" {
+		rejectLocked(store, p.ID, errCodeNotFound, "There is no such holding to seize.")
+		return
+	}
+	if !hasActiveWarrantLocked(store, b.OwnerPlayerID) {
+		rejectLocked(store, p.ID, errCodeNotAllowed, "Its owner is not under warrant.")
+		return
+	}
+	if !consumeHighImpactBudgetLocked(store, p.ID, now) {
+		rejectLocked(store, p.ID, errCodeHighImpactCap, "Daily cap reached for high-impact actions.")
+		return
+	}
+	prev := b.OwnerPlayerID
+	owner := b.OwnerName
+	store.dirty.mark(dirtyBuildings, b.ID)
+	b.OwnerPlayerID, b.OwnerName = "", ""
+	b.ForSalePrice = maxInt(1, buildingSaleValue(b))
+	addEventLocked(store, Event{Type: "Law", Severity: 3, Text: fmt.Sprintf("[%s] seizes [%s]'s %s in %s for the city.", p.Name, owner, b.Name, locationName(b.LocationID)), At: now})
+	setToastLocked(store, p.ID, fmt.Sprintf("The %s is now city property.", b.Name))
+	setToastLocked(store, prev, fmt.Sprintf("The Watch has seized your %s.", b.Name))
+}
+
+// crisisBuildingDamageLocked is how much condition buildings at locationID
+// lose to the active crisis this tick.
+func crisisBuildingDamageLocked(store *Store, locationID string) int {
+	crisis := store.ActiveCrisis
+	if crisis == nil || crisis.Mitigated {
+		return 0
+	}
+	def, ok := crisisDefinitionFor(crisis)
+	if !ok || def.BuildingDamage == 0 {
+		return 0
+	}
+	if len(def.DamageLocations) > 0 && !slices.Contains(def.DamageLocations, locationID) {
+		return 0
+	}
+	return def.BuildingDamage
+}
+
+// processBuildingTickLocked finishes construction, applies crisis damage,
+// collects upkeep and labor and pays each working building's output to its
+// owner.
+func processBuildingTickLocked(store *Store, now time.Time) {
+	for _, id := range sortedBuildingIDs(store.Buildings) {
+		b := store.Buildings[id]
+		if b.BuildTicksLeft > 0 {
+			b.BuildTicksLeft--
+			if b.BuildTicksLeft == 0 {
+				addEventLocked(store, Event{Type: "Holding", Severity: 1, Text: fmt.Sprintf("[%s]'s %s in %s opens for work.", b.OwnerName, b.Name, locationName(b.LocationID)), At: now})
+			}
+			continue
+		}
+		if damage := crisisBuildingDamageLocked(store, b.LocationID); damage > 0 && b.Condition > 0 {
+			b.Condition = maxInt(0, b.Condition-damage)
+			if b.Condition == 0 {
+				addEventLocked(store, Event{Type: "Holding", Severity: 3, Text: fmt.Sprintf("%s guts [%s]'s %s in %s.", store.ActiveCrisis.Name, b.OwnerName, b.Name, locationName(b.LocationID)), At: now})
+				setToastLocked(store, b.OwnerPlayerID, fmt.Sprintf("Your %s lies in ruins.", b.Name))
+			}
+		}
+		recordBuildingOutput(b, runBuildingLocked(store, b))
+	}
+}
+
+// runBuildingLocked works b for one tick and returns what it produced.
+func runBuildingLocked(store *Store, b *Building) BuildingOutput {
+	out := BuildingOutput{Tick: store.TickCount}
+	owner := store.Players[b.OwnerPlayerID]
+	def, ok := buildingDefinitionFor(b)
+	switch {
+	case owner == nil || !ok:
+		out.Note = "Idle"
+		return out
+	case b.Condition == 0:
+		out.Note = "Ruined"
+		return out
+	}
+	inputs := commodityCostList(def.LaborGrain, def.Inputs)
+	if code, _ := costShortfall(owner, def.UpkeepGold, inputs); code != "" {
+		b.Condition = maxInt(0, b.Condition-buildingNeglectDecay)
+		switch code {
+		case errCodeInsufficientGold:
+			out.Note = "Upkeep unpaid"
+		case errCodeInsufficientGrain:
+			out.Note = "No bread for workers"
+		default:
+			out.Note = "Out of inputs"
+		}
+		return out
+	}
+	payCosts(owner, def.UpkeepGold, inputs)
+	for _, id := range sortedKeys(def.Output) {
+		n := (def.Output[id]*b.Condition + 50) / 100
+		if id == commodityGrain {
+			n = n * currentSeason(store.World).HarvestPct / 100
+		}
+		if n <= 0 {
+			continue
+		}
+		addHolding(owner, id, n)
+		out.Produced += n
+		if b.TotalOutput == nil {
+			b.TotalOutput = map[string]int{}
+		}
+		b.TotalOutput[id] += n
+	}
+	if b.Condition < 100 {
+		out.Note = fmt.Sprintf("Damaged (%d%%)", b.Condition)
+	}
+	return out
+}
+
+func recordBuildingOutput(b *Building, out BuildingOutput) {
+	b.History = append(b.History, out)
+	if over := len(b.History) - buildingHistoryTicks; over > 0 {
+		b.History = append([]BuildingOutput(nil), b.History[over:]...)
+	}
+}
+
+// buildingViewsLocked lists p's holdings, then buildings p could buy or, as
+// Commander of the Watch, seize.
+func buildingViewsLocked(store *Store, p *Player) []BuildingView {
+	here := marketLocationID(p)
+	commander := playerHoldsSeatLocked(store, p.ID, "watch_commander")
+	mine, others := []BuildingView{}, []BuildingView{}
+	for _, id := range sortedBuildingIDs(store.Buildings) {
+		b := store.Buildings[id]
+		def, _ := buildingDefinitionFor(b)
+		v := BuildingView{
+			ID:           b.ID,
+			Name:         b.Name,
+			Location:     locationName(b.LocationID),
+			OwnerName:    b.OwnerName,
+			Mine:         b.OwnerPlayerID == p.ID,
+			Status:       buildingStatus(b),
+			Condition:    b.Condition,
+			OutputLabel:  costLabel(0, commodityCostList(0, def.Output)),
+			UpkeepLabel:  buildingUpkeepLabel(def),
+			HistoryTicks: len(b.History),
+			TotalLabel:   costLabel(0, commodityCostList(0, b.TotalOutput)),
+			ForSalePrice: b.ForSalePrice,
+			SaleValue:    buildingSaleValue(b),
+			RepairCost:   buildingRepairCost(b),
+		}
+		if v.OwnerName == "" {
+			v.OwnerName = "The city"
+		}
+		produced := make([]int, len(b.History))
+		for i, h := range b.History {
+			produced[i] = h.Produced
+			v.RecentOutput += h.Produced
+		}
+		v.HistoryPoints = sparklinePoints(produced, 120, 24)
+		if n := len(b.History); n > 0 {
+			v.LastNote = b.History[n-1].Note
+		}
+		if v.Mine {
+			v.CanRepair = b.Condition < 100 && b.BuildTicksLeft == 0 && here == b.LocationID
+			mine = append(mine, v)
+			continue
+		}
+		if b.ForSalePrice > 0 {
+			switch {
+			case here != b.LocationID:
+				v.BuyDisabledFor = fmt.Sprintf("Travel to %s to buy.", v.Location)
+			case p.Gold < b.ForSalePrice:
+				v.BuyDisabledFor = fmt.Sprintf("Need %dg.", b.ForSalePrice)
+			case ownedBuildingCount(store, p.ID) >= buildingMaxPerPlayer:
+				v.BuyDisabledFor = fmt.Sprintf("You already hold %d buildings.", buildingMaxPerPlayer)
+			default:
+				v.CanBuy = true
+			}
+		}
+		v.CanSeize = commander && b.OwnerPlayerID != "" && hasActiveWarrantLocked(store, b.OwnerPlayerID)
+		if b.ForSalePrice > 0 || v.CanSeize {
+			others = append(others, v)
+		}
+	}
+	return append(mine, others...)
+}
+
+// buildingOptionsLocked lists what p can build where they stand.
+func buildingOptionsLocked(store *Store, p *Player) []BuildingOption {
+	here := marketLocationID(p)
+	full := ownedBuildingCount(store, p.ID) >= buildingMaxPerPlayer
+	options := []BuildingOption{}
+	for _, def := range buildingDefinitions() {
+		if !slices.Contains(def.Locations, here) {
+			continue
+		}
+		costs := commodityCostList(0, def.Costs)
+		opt := BuildingOption{
+			Type:        def.Type,
+			Name:        def.Name,
+			Description: def.Description,
+			CostLabel:   costLabel(def.CostGold, costs),
+			OutputLabel: costLabel(0, commodityCostList(0, def.Output)),
+			UpkeepLabel: buildingUpkeepLabel(def),
+			BuildTicks:  def.BuildTicks,
+		}
+		if full {
+			opt.Disabled, opt.DisabledReason = true, fmt.Sprintf("You already hold %d buildings.", buildingMaxPerPlayer)
+		} else if code, need := costShortfall(p, def.CostGold, costs); code != "" {
+			opt.Disabled, opt.DisabledReason = true, need+"."
+		}
+		options = append(options, opt)
+	}
+	return options
+}
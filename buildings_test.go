@@ -0,0 +1,149 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildingProducesWithUpkeepAndLabor(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	p := &Player{ID: "p1", Name: "Ash Crow (Guest)", Gold: 40, Grain: 2, LocationID: locationCapital, LastSeen: now, Inventory: map[string]int{"iron_ore": 2}}
+	s.Players[p.ID] = p
+	def, _ := buildingDefinitionByType("sawmill")
+
+	handleActionInputLocked(s, p, now, ActionInput{Action: "build", BuildingType: "sawmill"})
+	if s.rejections[p.ID] != errCodeTravelLockout {
+		t.Fatalf("sawmills stand on the frontier, got %q", s.rejections[p.ID])
+	}
+	p.LocationID = locationFrontier
+	handleActionInputLocked(s, p, now, ActionInput{Action: "build", BuildingType: "sawmill"})
+	b := s.Buildings["b-1"]
+	if b == nil || p.Gold != 40-def.CostGold || holdingOf(p, "iron_ore") != 0 || b.BuildTicksLeft != def.BuildTicks {
+		t.Fatalf("build should pay and start construction: %+v gold %d", b, p.Gold)
+	}
+
+	for range def.BuildTicks {
+		processBuildingTickLocked(s, now)
+	}
+	if b.BuildTicksLeft != 0 || holdingOf(p, "timber") != 0 || len(b.History) != 0 {
+		t.Fatalf("construction should finish without output: %+v", b)
+	}
+	processBuildingTickLocked(s, now)
+	if holdingOf(p, "timber") != def.Output["timber"] || p.Gold != 40-def.CostGold-def.UpkeepGold || p.Grain != 2-def.LaborGrain {
+		t.Fatalf("a working sawmill should pay upkeep and labor and make timber: timber %d gold %d grain %d", holdingOf(p, "timber"), p.Gold, p.Grain)
+	}
+
+	p.Grain = 0
+	processBuildingTickLocked(s, now)
+	last := b.History[len(b.History)-1]
+	if last.Produced != 0 || last.Note != "No bread for workers" || b.Condition != 100-buildingNeglectDecay {
+		t.Fatalf("without bread the workers stay home: %+v condition %d", last, b.Condition)
+	}
+
+	views := buildPageDataLocked(s, p.ID, false).Buildings
+	if len(views) != 1 || !views[0].Mine || views[0].HistoryTicks != 2 || views[0].HistoryPoints == "" || !views[0].CanRepair {
+		t.Fatalf("holdings view should chart output and offer repairs: %+v", views)
+	}
+	for range buildingHistoryTicks {
+		processBuildingTickLocked(s, now)
+	}
+	if len(b.History) != buildingHistoryTicks {
+		t.Fatalf("history should keep %d ticks, got %d", buildingHistoryTicks, len(b.History))
+	}
+}
+
+func TestCrisisDamagesBuildingsUntilRepaired(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	p := &Player{ID: "p1", Name: "Ash Crow (Guest)", Gold: 100, Grain: 20, LocationID: locationHarbor, LastSeen: now}
+	s.Players[p.ID] = p
+	def, _ := buildingDefinitionByType("saltworks")
+	b := &Building{ID: "b-1", Type: def.Type, Name: def.Name, OwnerPlayerID: p.ID, OwnerName: p.Name, LocationID: locationHarbor, Condition: 100, Definition: &def}
+	farm, _ := buildingDefinitionByType("farm")
+	frontier := &Building{ID: "b-2", Type: farm.Type, Name: farm.Name, OwnerPlayerID: p.ID, OwnerName: p.Name, LocationID: locationFrontier, Condition: 100, Definition: &farm}
+	s.Buildings[b.ID], s.Buildings[frontier.ID] = b, frontier
+
+	fire, _ := crisisDefinitionByType("fire")
+	startCrisisLocked(s, fire, now)
+	for b.Condition > 0 {
+		processBuildingTickLocked(s, now)
+	}
+	if frontier.Condition != 100 {
+		t.Fatalf("the fire should not reach the frontier, condition %d", frontier.Condition)
+	}
+	if last := s.Events[len(s.Events)-1]; !strings.Contains(last.Text, "guts [Ash Crow (Guest)]'s Saltworks") {
+		t.Fatalf("a ruined building should be logged, got %q", last.Text)
+	}
+	salt := holdingOf(p, "salt")
+	processBuildingTickLocked(s, now)
+	if holdingOf(p, "salt") != salt || b.History[len(b.History)-1].Note != "Ruined" {
+		t.Fatalf("a ruin should produce nothing: %+v", b.History[len(b.History)-1])
+	}
+
+	s.ActiveCrisis.Mitigated = true
+	cost, gold := buildingRepairCost(b), p.Gold
+	handleActionInputLocked(s, p, now, ActionInput{Action: "repair_building", BuildingID: b.ID})
+	if b.Condition != 100 || p.Gold != gold-cost || cost <= 0 {
+		t.Fatalf("repair should restore condition for %dg: condition %d gold %d -> %d", cost, b.Condition, gold, p.Gold)
+	}
+	processBuildingTickLocked(s, now)
+	if holdingOf(p, "salt") != salt+def.Output["salt"] {
+		t.Fatalf("a mitigated crisis should leave the repaired saltworks working")
+	}
+}
+
+func TestBuildingsChangeHandsBySaleAndSeizure(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	owner := &Player{ID: "p1", Name: "Ash Crow (Guest)", Gold: 10, LocationID: locationHarbor, LastSeen: now}
+	buyer := &Player{ID: "p2", Name: "Bram Vale (Guest)", Gold: 60, LocationID: locationHarbor, LastSeen: now}
+	commander := &Player{ID: "p3", Name: "Cass Reed (Guest)", LocationID: locationCapital, LastSeen: now}
+	s.Players[owner.ID], s.Players[buyer.ID], s.Players[commander.ID] = owner, buyer, commander
+	def, _ := buildingDefinitionByType("saltworks")
+	b := &Building{ID: "b-1", Type: def.Type, Name: def.Name, OwnerPlayerID: owner.ID, OwnerName: owner.Name, LocationID: locationHarbor, Condition: 100, Definition: &def}
+	s.Buildings[b.ID] = b
+
+	handleActionInputLocked(s, buyer, now, ActionInput{Action: "buy_building", BuildingID: b.ID})
+	if s.rejections[buyer.ID] != errCodeNotFound {
+		t.Fatalf("an unlisted building cannot be bought, got %q", s.rejections[buyer.ID])
+	}
+	handleActionInputLocked(s, owner, now, ActionInput{Action: "sell_building", BuildingID: b.ID, Price: 40})
+	if got := buildPageDataLocked(s, buyer.ID, false).Buildings; len(got) != 1 || !got[0].CanBuy {
+		t.Fatalf("a listed building should be offered to players on site: %+v", got)
+	}
+	handleActionInputLocked(s, buyer, now, ActionInput{Action: "buy_building", BuildingID: b.ID})
+	if b.OwnerPlayerID != buyer.ID || buyer.Gold != 20 || owner.Gold != 50 || b.ForSalePrice != 0 {
+		t.Fatalf("sale should move gold and title: %+v buyer %d owner %d", b, buyer.Gold, owner.Gold)
+	}
+
+	s.Seats["watch_commander"].HolderPlayerID = commander.ID
+	handleActionInputLocked(s, commander, now, ActionInput{Action: "seize_building", BuildingID: b.ID})
+	if s.rejections[commander.ID] != errCodeNotAllowed || b.OwnerPlayerID != buyer.ID {
+		t.Fatalf("seizure needs a warrant, got %q", s.rejections[commander.ID])
+	}
+	s.Warrants[buyer.ID] = &Warrant{PlayerID: buyer.ID, PlayerName: buyer.Name, TicksLeft: 3, TotalTicks: 3}
+	if got := buildPageDataLocked(s, commander.ID, false).Buildings; len(got) != 1 || !got[0].CanSeize {
+		t.Fatalf("the commander should see the warranted holding: %+v", got)
+	}
+	handleActionInputLocked(s, commander, now, ActionInput{Action: "seize_building", BuildingID: b.ID})
+	if b.OwnerPlayerID != "" || b.ForSalePrice != buildingSaleValue(b) {
+		t.Fatalf("a seized building should be listed by the city: %+v", b)
+	}
+	processBuildingTickLocked(s, now)
+	if b.History[len(b.History)-1].Note != "Idle" {
+		t.Fatalf("a city-held building should stand idle: %+v", b.History)
+	}
+
+	owner.Gold = b.ForSalePrice
+	handleActionInputLocked(s, owner, now, ActionInput{Action: "buy_building", BuildingID: b.ID})
+	if b.OwnerPlayerID != owner.ID || owner.Gold != 0 {
+		t.Fatalf("the city should sell seized property: %+v gold %d", b, owner.Gold)
+	}
+	value := buildingSaleValue(b)
+	handleActionInputLocked(s, owner, now, ActionInput{Action: "sell_building", BuildingID: b.ID})
+	if len(s.Buildings) != 0 || owner.Gold != value {
+		t.Fatalf("selling to the city should pay %dg, got %d", value, owner.Gold)
+	}
+}
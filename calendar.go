@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// The calendar is derived from World.DayNumber: day 1 is the first day of
+// spring in year 1.
+const (
+	calendarDaysPerSeason = 7
+	calendarDaysPerYear   = calendarDaysPerSeason * 4
+)
+
+// Season shapes the economy while it lasts. Percentages default to 100 and
+// chance adjustments are in percentage points.
+type Season struct {
+	Name        string
+	Description string
+	// ConsumptionPct scales the city's grain consumption each tick.
+	ConsumptionPct int
+	// HarvestPct scales production in the frontier markets and grain from
+	// farms.
+	HarvestPct int
+	// TravelTicks are added to every journey.
+	TravelTicks int
+	// CrisisChance is added to the per-tick chance of a crisis erupting.
+	CrisisChance int
+	// FulfillChance is added to contract fulfillment and delivery chances.
+	FulfillChance int
+	// PricePct scales base market prices by commodity.
+	PricePct map[string]int
+}
+
+var seasons = []Season{
+	{
+		Name:           "Spring",
+		Description:    "Thaw water fills the canals and the fields are sown.",
+		ConsumptionPct: 100,
+		HarvestPct:     100,
+	},
+	{
+		Name:           "Summer",
+		Description:    "Long days on dry roads; the warehouses bake.",
+		ConsumptionPct: 95,
+		HarvestPct:     130,
+		CrisisChance:   2,
+		FulfillChance:  5,
+	},
+	{
+		Name:           "Autumn",
+		Description:    "The frontier harvest rolls in by the cartload.",
+		ConsumptionPct: 100,
+		HarvestPct:     200,
+		FulfillChance:  5,
+		PricePct:       map[string]int{commodityGrain: 85},
+	},
+	{
+		Name:           "Winter",
+		Description:    "Snow closes the passes and the city eats its stores.",
+		ConsumptionPct: 130,
+		HarvestPct:     50,
+		TravelTicks:    1,
+		CrisisChance:   3,
+		FulfillChance:  -10,
+		PricePct:       map[string]int{commodityGrain: 120, "medicine": 125, "timber": 115},
+	},
+}
+
+// Festival is a fixed day in the calendar, observed every year.
+type Festival struct {
+	Season      string
+	Day         int
+	Name        string
+	Text        string
+	UnrestDelta int
+	GrainDelta  int
+}
+
+var festivals = []Festival{
+	{Season: "Spring", Day: 2, Name: "Planting Rites", Text: "Curates bless the seed grain at the granary steps.", UnrestDelta: -4},
+	{Season: "Summer", Day: 4, Name: "Midsummer Fair", Text: "Stalls and jugglers crowd the market lanes.", UnrestDelta: -6},
+	{Season: "Autumn", Day: 7, Name: "Harvest Home", Text: "The last carts are cheered through the gates and the granary takes its tithe.", UnrestDelta: -8, GrainDelta: 30},
+	{Season: "Winter", Day: 4, Name: "Longest Night", Text: "Lanterns burn till dawn and the wards share their bread.", UnrestDelta: -5, GrainDelta: -10},
+}
+
+// CalendarView is the date as the header shows it.
+type CalendarView struct {
+	Year         int    `json:"year"`
+	Season       string `json:"season"`
+	Day          int    `json:"day"`
+	DaysInSeason int    `json:"days_in_season"`
+	Description  string `json:"description"`
+	NextFestival string `json:"next_festival,omitempty"`
+	FestivalIn   int    `json:"festival_in_days"`
+}
+
+// calendarDate splits a day number into its year, season and day of the
+// season, all counted from 1.
+func calendarDate(dayNumber int) (year, seasonIdx, day int) {
+	d := maxInt(0, dayNumber-1)
+	return d/calendarDaysPerYear + 1, d % calendarDaysPerYear / calendarDaysPerSeason, d%calendarDaysPerSeason + 1
+}
+
+func currentSeason(w WorldState) Season {
+	_, idx, _ := calendarDate(w.DayNumber)
+	return seasons[idx]
+}
+
+func seasonPricePct(w WorldState, commodityID string) int {
+	if pct, ok := currentSeason(w).PricePct[commodityID]; ok {
+		return pct
+	}
+	return 100
+}
+
+// travelTicksLocked is how long the road from one place to another takes in
+// the current season.
+func travelTicksLocked(store *Store, from, to string) int {
+	ticks := travelTicksBetween(from, to)
+	if ticks == 0 {
+		return 0
+	}
+	return ticks + currentSeason(store.World).TravelTicks
+}
+
+func festivalOn(dayNumber int) (Festival, bool) {
+	_, idx, day := calendarDate(dayNumber)
+	for _, f := range festivals {
+		if f.Season == seasons[idx].Name && f.Day == day {
+			return f, true
+		}
+	}
+	return Festival{}, false
+}
+
+func calendarViewFor(w WorldState) CalendarView {
+	year, idx, day := calendarDate(w.DayNumber)
+	view := CalendarView{Year: year, Season: seasons[idx].Name, Day: day, DaysInSeason: calendarDaysPerSeason, Description: seasons[idx].Description}
+	for ahead := 0; ahead < calendarDaysPerYear; ahead++ {
+		if f, ok := festivalOn(w.DayNumber + ahead); ok {
+			view.NextFestival, view.FestivalIn = f.Name, ahead
+			break
+		}
+	}
+	return view
+}
+
+// processCalendarDayLocked marks the start of a new day: the turn of a
+// season and any festival that falls on it.
+func processCalendarDayLocked(store *Store, now time.Time) {
+	w := &store.World
+	year, idx, day := calendarDate(w.DayNumber)
+	if day == 1 {
+		s := seasons[idx]
+		addEventLocked(store, Event{Type: "Calendar", Severity: 1, Text: fmt.Sprintf("%s of year %d begins. %s", s.Name, year, s.Description), At: now})
+	}
+	f, ok := festivalOn(w.DayNumber)
+	if !ok {
+		return
+	}
+	if f.UnrestDelta != 0 {
+		w.UnrestValue = clampInt(w.UnrestValue+f.UnrestDelta, 0, 100)
+		w.UnrestTier = unrestTierFromValue(w.UnrestValue)
+	}
+	if f.GrainDelta != 0 {
+		applyGrainSupplyDeltaLocked(store, now, f.GrainDelta)
+	}
+	addEventLocked(store, Event{Type: "Calendar", Severity: 2, Text: fmt.Sprintf("%s: %s", f.Name, f.Text), At: now})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendarDates(t *testing.T) {
+	for _, tc := range []struct {
+		day                   int
+		year, seasonIdx, date int
+	}{
+		{1, 1, 0, 1},
+		{7, 1, 0, 7},
+		{8, 1, 1, 1},
+		{28, 1, 3, 7},
+		{29, 2, 0, 1},
+	} {
+		year, idx, date := calendarDate(tc.day)
+		if year != tc.year || idx != tc.seasonIdx || date != tc.date {
+			t.Fatalf("day %d = year %d season %d day %d, want %d %d %d", tc.day, year, idx, date, tc.year, tc.seasonIdx, tc.date)
+		}
+	}
+	view := calendarViewFor(WorldState{DayNumber: 1})
+	if view.Season != "Spring" || view.NextFestival != "Planting Rites" || view.FestivalIn != 1 {
+		t.Fatalf("day one calendar = %+v", view)
+	}
+}
+
+func TestSeasonsShapeTravelPricesAndHarvest(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	medicine, _ := commodityDefinitionByID("medicine")
+	capital, _ := localGood(locationCapital, "medicine")
+	frontier, _ := localGood(locationFrontier, "timber")
+	timber, _ := commodityDefinitionByID("timber")
+
+	springTicks := travelTicksLocked(s, locationCapital, locationHarbor)
+	springPrice, _, _ := marketPricesLocked(s, locationCapital, medicine, capital)
+	s.World.Markets = map[string]map[string]int{locationFrontier: {"timber": frontier.Stock}}
+	processMarketTickLocked(s, now)
+	springTimber := marketSupplyLocked(s, locationFrontier, frontier)
+
+	s.World.DayNumber = 3*calendarDaysPerSeason + 1
+	if got := currentSeason(s.World).Name; got != "Winter" {
+		t.Fatalf("day %d should be winter, got %s", s.World.DayNumber, got)
+	}
+	if got := travelTicksLocked(s, locationCapital, locationHarbor); got != springTicks+1 {
+		t.Fatalf("winter roads should be slower: %d -> %d", springTicks, got)
+	}
+	if got := travelTicksLocked(s, locationCapital, locationCapital); got != 0 {
+		t.Fatalf("staying put takes no time, got %d", got)
+	}
+	if price, _, _ := marketPricesLocked(s, locationCapital, medicine, capital); price <= springPrice {
+		t.Fatalf("winter medicine should cost more: %d -> %d", springPrice, price)
+	}
+
+	s.World.DayNumber = 2*calendarDaysPerSeason + 1
+	s.World.Markets[locationFrontier]["timber"] = frontier.Stock
+	processMarketTickLocked(s, now)
+	if got := marketSupplyLocked(s, locationFrontier, frontier); got <= springTimber {
+		t.Fatalf("the autumn harvest should swell frontier stocks: spring %d, autumn %d", springTimber, got)
+	}
+	if _, _, sell := marketPricesLocked(s, locationFrontier, timber, frontier); sell <= 0 {
+		t.Fatalf("timber should still have a price")
+	}
+}
+
+func TestCalendarTurnsSeasonsAndHoldsFestivals(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	s.World.DayNumber = 2*calendarDaysPerSeason + 6
+	s.World.Subphase = "Evening"
+	s.World.UnrestValue = 40
+	s.Events = nil
+
+	runWorldTickLocked(s, now)
+	found := false
+	for _, e := range s.Events {
+		if e.Type == "Calendar" && strings.HasPrefix(e.Text, "Harvest Home:") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Harvest Home should be held on the last day of autumn: %+v", s.Events)
+	}
+
+	s.Events = nil
+	s.World.Subphase = "Evening"
+	runWorldTickLocked(s, now)
+	if len(s.Events) == 0 || !strings.HasPrefix(s.Events[0].Text, "Winter of year 1 begins.") {
+		t.Fatalf("the turn of the season should be announced: %+v", s.Events)
+	}
+
+	mux := newMux(s, parseTemplates())
+	rr := doReq(t, mux, http.MethodGet, "/", nil, "", "127.0.0.1:1111")
+	if body := rr.Body.String(); !strings.Contains(body, "Winter, day 1 of 7, year 1") || !strings.Contains(body, "Longest Night in 3 days") {
+		t.Fatalf("header should show the date and next festival")
+	}
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	caravanMaxCargo    = 60
+	caravanMaxGuards   = 5
+	caravanMaxEscorts  = 3
+	caravanMaxPerOwner = 3
+	caravanGuardCost   = 3
+	// caravanMaxEscortFee bounds the pay offered to each escort, so the
+	// escrow for caravanMaxEscorts of them stays far from overflowing.
+	caravanMaxEscortFee = 1000
+	// Each guard and each escort takes this much off the per-tick ambush
+	// risk, in percent.
+	caravanGuardRiskPct  = 3
+	caravanEscortRiskPct = 4
+	caravanMaxRiskPct    = 60
+	// caravanInterceptPctPerHeat is the chance, per point of the owner's
+	// heat, that an incident on the road is the Watch rather than bandits.
+	caravanInterceptPctPerHeat = 5
+	// A raid starts at caravanRaidBasePct and each guard or escort defends
+	// against it.
+	caravanRaidBasePct   = 60
+	caravanRaidGuardPct  = 8
+	caravanRaidEscortPct = 12
+	// Bandit activity rises with every caravan bandits take and fades a
+	// point a tick.
+	banditActivityPerAmbush = 4
+	banditActivityMax       = 20
+)
+
+// Caravan is a shipment on the road. Its cargo is sold into the destination
+// market on arrival; until then it can be lost, impounded or raided.
+type Caravan struct {
+	ID            string
+	OwnerPlayerID string
+	OwnerName     string
+	FromID        string
+	ToID          string
+	Cargo         map[string]int
+	Guards        int
+	// Escorts are players riding along. Each is paid EscortFee on arrival
+	// from gold the owner escrowed for caravanMaxEscorts at dispatch.
+	Escorts      []string
+	EscortFee    int
+	TicksLeft    int
+	TotalTicks   int
+	DepartedTick int64
+	RaidedBy     []string
+}
+
+// CaravanGood is a commodity the player could load onto a caravan.
+type CaravanGood struct {
+	ID   string
+	Name string
+	Held int
+}
+
+type CaravanView struct {
+	ID          string `json:"id"`
+	OwnerName   string `json:"owner_name"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	CargoLabel  string `json:"cargo_label"`
+	Guards      int    `json:"guards"`
+	Escorts     int    `json:"escorts"`
+	EscortFee   int    `json:"escort_fee"`
+	TicksLeft   int    `json:"ticks_left"`
+	TotalTicks  int    `json:"total_ticks"`
+	RiskPct     int    `json:"risk_pct"`
+	Mine        bool   `json:"mine"`
+	CanEscort   bool   `json:"can_escort"`
+	CanRaid     bool   `json:"can_raid"`
+	RaidChance  int    `json:"raid_chance"`
+	Unavailable string `json:"unavailable,omitempty"`
+}
+
+func locationDanger(id string) int {
+	def, _ := locationByID(id)
+	return def.Danger
+}
+
+// caravanRiskLocked is the chance, in percent, that c meets trouble this
+// tick: the more dangerous end of its route, the owner's heat, an unchecked
+// crisis and bandit activity, less its guards and escorts.
+func caravanRiskLocked(store *Store, c *Caravan) int {
+	risk := maxInt(locationDanger(c.FromID), locationDanger(c.ToID)) + store.World.BanditActivity
+	if owner := store.Players[c.OwnerPlayerID]; owner != nil {
+		risk += owner.Heat / 2
+	}
+	if crisis := store.ActiveCrisis; crisis != nil && !crisis.Mitigated {
+		risk += crisis.Severity * 2
+	}
+	risk -= c.Guards*caravanGuardRiskPct + len(c.Escorts)*caravanEscortRiskPct
+	return clampInt(risk, 0, caravanMaxRiskPct)
+}
+
+func caravanRaidChance(c *Caravan) int {
+	return clampInt(caravanRaidBasePct-c.Guards*caravanRaidGuardPct-len(c.Escorts)*caravanRaidEscortPct, 5, 90)
+}
+
+// caravanSeq orders IDs numerically so "cv-10" sorts after "cv-9".
+func caravanSeq(id string) int64 {
+	n, _ := strconv.ParseInt(strings.TrimPrefix(id, "cv-"), 10, 64)
+	return n
+}
+
+func sortedCaravanIDs(caravans map[string]*Caravan) []string {
+	ids := sortedKeys(caravans)
+	slices.SortFunc(ids, func(a, b string) int { return int(caravanSeq(a) - caravanSeq(b)) })
+	return ids
+}
+
+func cargoLabel(cargo map[string]int) string {
+	costs := commodityCostList(0, cargo)
+	return costLabel(0, costs)
+}
+
+func sendCaravanLocked(store *Store, p *Player, now time.Time, in ActionInput) {
+	fromID := marketLocationID(p)
+	toID := strings.TrimSpace(in.LocationID)
+	if _, ok := locationByID(toID); !ok || toID == fromID {
+		rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a destination for the caravan.")
+		return
+	}
+	def, ok := commodityDefinitionByID(strings.TrimSpace(in.Commodity))
+	if !ok {
+		rejectLocked(store, p.ID, errCodeNotFound, "That commodity is unknown.")
+		return
+	}
+	if _, ok := localGood(toID, def.ID); !ok {
+		rejectLocked(store, p.ID, errCodeNotFound, fmt.Sprintf("%s is not traded in %s.", def.Name, locationName(toID)))
+		return
+	}
+	if in.Amount <= 0 || in.Amount > caravanMaxCargo {
+		rejectLocked(store, p.ID, errCodeInvalidInput, fmt.Sprintf("A caravan carries 1 to %d %s.", caravanMaxCargo, def.Unit))
+		return
+	}
+	if in.Guards < 0 || in.Guards > caravanMaxGuards {
+		rejectLocked(store, p.ID, errCodeInvalidInput, fmt.Sprintf("Hire 0 to %d guards.", caravanMaxGuards))
+		return
+	}
+	if in.Reward < 0 || in.Reward > caravanMaxEscortFee {
+		rejectLocked(store, p.ID, errCodeInvalidInput, fmt.Sprintf("Offer escorts 0g to %dg each.", caravanMaxEscortFee))
+		return
+	}
+	owned := 0
+	for _, c := range store.Caravans {
+		if c.OwnerPlayerID == p.ID {
+			owned++
+		}
+	}
+	if owned >= caravanMaxPerOwner {
+		rejectLocked(store, p.ID, errCodeNotAllowed, fmt.Sprintf("You already have %d caravans on the road.", caravanMaxPerOwner))
+		return
+	}
+	if held := holdingOf(p, def.ID); held < in.Amount {
+		rejectLocked(store, p.ID, insufficientCodeFor(def.ID), fmt.Sprintf("You only hold %d %s.", held, def.Unit))
+		return
+	}
+	upfront, ok := caravanUpfront(in.Guards, in.Reward)
+	if !ok {
+		rejectLocked(store, p.ID, errCodeInvalidInput, "That escort pay is too large to escrow.")
+		return
+	}
+	if p.Gold < upfront {
+		rejectLocked(store, p.ID, errCodeInsufficientGold, fmt.Sprintf("Need %dg for guards and escort pay.", upfront))
+		return
+	}
+
+	p.Gold -= upfront
+	addHolding(p, def.ID, -in.Amount)
+	ticks := travelTicksLocked(store, fromID, toID)
+	store.NextCaravanID++
+	c := &Caravan{
+		ID:            fmt.Sprintf("cv-%d", store.NextCaravanID),
+		OwnerPlayerID: p.ID,
+		OwnerName:     p.Name,
+		FromID:        fromID,
+		ToID:          toID,
+		Cargo:         map[string]int{def.ID: in.Amount},
+		Guards:        in.Guards,
+		EscortFee:     in.Reward,
+		TicksLeft:     ticks,
+		TotalTicks:    ticks,
+		DepartedTick:  store.TickCount,
+	}
+	store.dirty.mark(dirtyCaravans, c.ID)
+	store.Caravans[c.ID] = c
+	addEventLocked(store, Event{Type: "Caravan", Severity: 1, Text: fmt.Sprintf("[%s] sends a caravan of %s from %s to %s.", p.Name, cargoLabel(c.Cargo), locationName(fromID), locationName(toID)), At: now})
+	setToastLocked(store, p.ID, fmt.Sprintf("Caravan departs for %s (%dt).", locationName(toID), ticks))
+}
+
+// caravanUpfront is what a caravan costs at dispatch: the guards plus escort
+// pay escrowed for caravanMaxEscorts. It reports false if that would
+// overflow.
+func caravanUpfront(guards, reward int) (int, bool) {
+	if guards < 0 || reward < 0 || reward > (math.MaxInt-guards*caravanGuardCost)/caravanMaxEscorts {
+		return 0, false
+	}
+	return guards*caravanGuardCost + reward*caravanMaxEscorts, true
+}
+
+// refundEscortPayLocked returns the escort pay not owed to anyone.
+func refundEscortPayLocked(store *Store, c *Caravan, paid int) {
+	if owner := store.Players[c.OwnerPlayerID]; owner != nil {
+		store.dirty.mark(dirtyPlayers, owner.ID)
+		owner.Gold += c.EscortFee * (caravanMaxEscorts - paid)
+	}
+}
+
+func escortCaravanLocked(store *Store, p *Player, now time.Time, c *Caravan) {
+	switch {
+	case c.OwnerPlayerID == p.ID:
+		rejectLocked(store, p.ID, errCodeNotAllowed, "Hire guards for your own caravan instead.")
+		return
+	case slices.Contains(c.Escorts, p.ID):
+		rejectLocked(store, p.ID, errCodeNotAllowed, "You already ride with this caravan.")
+		return
+	case marketLocationID(p) != c.FromID:
+		rejectLocked(store, p.ID, errCodeTravelLockout, fmt.Sprintf("Catch the caravan at %s.", locationName(c.FromID)))
+		return
+	case len(c.Escorts) >= caravanMaxEscorts:
+		rejectLocked(store, p.ID, errCodeNotAllowed, "The caravan has all the escorts it will pay.")
+		return
+	}
+	store.dirty.mark(dirtyCaravans, c.ID)
+	c.Escorts = append(c.Escorts, p.ID)
+	p.TravelToID = c.ToID
+	p.TravelTicksLeft = c.TicksLeft
+	p.TravelTotalTicks = c.TicksLeft
+	addEventLocked(store, Event{Type: "Caravan", Severity: 1, Text: fmt.Sprintf("[%s] rides out to escort [%s]'s caravan to %s.", p.Name, c.OwnerName, locationName(c.ToID)), At: now})
+	setToastLocked(store, p.ID, fmt.Sprintf("You ride with the caravan to %s for %dg.", locationName(c.ToID), c.EscortFee))
+}
+
+func raidCaravanLocked(store *Store, p *Player, now time.Time, c *Caravan) {
+	here := marketLocationID(p)
+	switch {
+	case c.OwnerPlayerID == p.ID || slices.Contains(c.Escorts, p.ID):
+		rejectLocked(store, p.ID, errCodeNotAllowed, "You cannot raid a caravan you ride with.")
+		return
+	case here != c.FromID && here != c.ToID:
+		rejectLocked(store, p.ID, errCodeTravelLockout, fmt.Sprintf("Lie in wait at %s or %s.", locationName(c.FromID), locationName(c.ToID)))
+		return
+	case slices.Contains(c.RaidedBy, p.ID):
+		rejectLocked(store, p.ID, errCodeNotAllowed, "The caravan is watching for you now.")
+		return
+	}
+	if !consumeHighImpactBudgetLocked(store, p.ID, now) {
+		rejectLocked(store, p.ID, errCodeHighImpactCap, "Daily cap reached for high-impact actions.")
+		return
+	}
+	store.dirty.mark(dirtyCaravans, c.ID)
+	c.RaidedBy = append(c.RaidedBy, p.ID)
+	if rngStreamLocked(store, rngStreamCaravan).Intn(100) >= caravanRaidChance(c) {
+		p.Heat = clampInt(p.Heat+2, 0, 20)
+		p.Rep = clampInt(p.Rep-2, -100, 100)
+		addEventLocked(store, Event{Type: "Caravan", Severity: 2, Text: fmt.Sprintf("[%s]'s caravan drives off raiders on the road to %s.", c.OwnerName, locationName(c.ToID)), At: now})
+		setToastLocked(store, p.ID, "The guards drive you off.")
+		return
+	}
+	taken := map[string]int{}
+	for _, id := range sortedKeys(c.Cargo) {
+		n := (c.Cargo[id] + 1) / 2
+		taken[id] = n
+		addHolding(p, id, n)
+		if c.Cargo[id] -= n; c.Cargo[id] == 0 {
+			delete(c.Cargo, id)
+		}
+	}
+	p.Heat = clampInt(p.Heat+3, 0, 20)
+	p.Rep = clampInt(p.Rep-3, -100, 100)
+	addEventLocked(store, Event{Type: "Caravan", Severity: 3, Text: fmt.Sprintf("[%s] raids [%s]'s caravan and makes off with %s.", p.Name, c.OwnerName, cargoLabel(taken)), At: now})
+	setToastLocked(store, p.ID, fmt.Sprintf("You seize %s.", cargoLabel(taken)))
+	setToastLocked(store, c.OwnerPlayerID, fmt.Sprintf("Raiders took %s from your caravan.", cargoLabel(taken)))
+	if len(c.Cargo) == 0 {
+		refundEscortPayLocked(store, c, 0)
+		delete(store.Caravans, c.ID)
+	}
+}
+
+// processCaravanTickLocked rolls each caravan's road risk, then moves the
+// survivors a tick closer to their market.
+func processCaravanTickLocked(store *Store, now time.Time) {
+	for _, id := range sortedCaravanIDs(store.Caravans) {
+		c := store.Caravans[id]
+		if rngStreamLocked(store, rngStreamCaravan).Intn(100) < caravanRiskLocked(store, c) {
+			ambushCaravanLocked(store, c, now)
+			continue
+		}
+		c.TicksLeft--
+		if c.TicksLeft <= 0 {
+			arriveCaravanLocked(store, c, now)
+		}
+	}
+	if store.World.BanditActivity > 0 {
+		store.World.BanditActivity--
+	}
+}
+
+// ambushCaravanLocked ends c on the road. A wanted owner's caravan is more
+// likely to be stopped by the Watch, which impounds the cargo into the
+// capital's stores; otherwise bandits take it and grow bolder.
+func ambushCaravanLocked(store *Store, c *Caravan, now time.Time) {
+	heat := 0
+	if owner := store.Players[c.OwnerPlayerID]; owner != nil {
+		heat = owner.Heat
+	}
+	refundEscortPayLocked(store, c, 0)
+	delete(store.Caravans, c.ID)
+	if rngStreamLocked(store, rngStreamCaravan).Intn(100) < heat*caravanInterceptPctPerHeat {
+		for _, id := range sortedKeys(c.Cargo) {
+			def, ok := commodityDefinitionByID(id)
+			good, traded := localGood(locationCapital, id)
+			if ok && traded {
+				applyMarketSupplyDeltaLocked(store, now, locationCapital, def, good, c.Cargo[id]*def.PoolPerUnit)
+			}
+		}
+		addEventLocked(store, Event{Type: "Caravan", Severity: 3, Text: fmt.Sprintf("[City Watch] impounds [%s]'s caravan of %s on the road to %s.", c.OwnerName, cargoLabel(c.Cargo), locationName(c.ToID)), At: now})
+		setToastLocked(store, c.OwnerPlayerID, "The Watch impounded your caravan.")
+		return
+	}
+	store.World.BanditActivity = minInt(banditActivityMax, store.World.BanditActivity+banditActivityPerAmbush)
+	addEventLocked(store, Event{Type: "Caravan", Severity: 3, Text: fmt.Sprintf("Bandits ambush [%s]'s caravan on the road to %s; %s lost.", c.OwnerName, locationName(c.ToID), cargoLabel(c.Cargo)), At: now})
+	setToastLocked(store, c.OwnerPlayerID, "Bandits took your caravan.")
+}
+
+// arriveCaravanLocked sells c's cargo into the destination market at its
+// sell price and pays the escorts.
+func arriveCaravanLocked(store *Store, c *Caravan, now time.Time) {
+	delete(store.Caravans, c.ID)
+	owner := store.Players[c.OwnerPlayerID]
+	proceeds := 0
+	for _, id := range sortedKeys(c.Cargo) {
+		def, ok := commodityDefinitionByID(id)
+		good, traded := localGood(c.ToID, id)
+		if !ok || !traded {
+			// Content no longer trades it here; the owner keeps the goods.
+			if owner != nil {
+				addHolding(owner, id, c.Cargo[id])
+			}
+			continue
+		}
+		_, _, sell := marketPricesLocked(store, c.ToID, def, good)
+		_, sellTax := marketTaxPerUnitLocked(store, c.ToID, def, good)
+		proceeds += sell * c.Cargo[id]
+		collectMarketTaxLocked(store, now, c.ToID, sellTax*c.Cargo[id], owner)
+		applyMarketSupplyDeltaLocked(store, now, c.ToID, def, good, c.Cargo[id]*def.PoolPerUnit)
+		recordTradeVolumeLocked(store, c.ToID, id, c.Cargo[id])
+	}
+	if owner != nil {
+		owner.Gold += proceeds
+	}
+	for _, escortID := range c.Escorts {
+		if escort := store.Players[escortID]; escort != nil {
+			escort.Gold += c.EscortFee
+			escort.Rep = clampInt(escort.Rep+1, -100, 100)
+		}
+	}
+	refundEscortPayLocked(store, c, len(c.Escorts))
+	addEventLocked(store, Event{Type: "Caravan", Severity: 1, Text: fmt.Sprintf("[%s]'s caravan reaches %s and sells %s for %dg.", c.OwnerName, locationName(c.ToID), cargoLabel(c.Cargo), proceeds), At: now})
+	setToastLocked(store, c.OwnerPlayerID, fmt.Sprintf("Caravan sold in %s for %dg.", locationName(c.ToID), proceeds))
+}
+
+func caravanGoods(p *Player) []CaravanGood {
+	goods := []CaravanGood{}
+	for _, def := range commodityDefinitions() {
+		if held := holdingOf(p, def.ID); held > 0 {
+			goods = append(goods, CaravanGood{ID: def.ID, Name: def.Name, Held: held})
+		}
+	}
+	return goods
+}
+
+// caravanViewsLocked lists every caravan on the road, the player's own
+// first.
+func caravanViewsLocked(store *Store, p *Player) []CaravanView {
+	here := marketLocationID(p)
+	views := []CaravanView{}
+	for _, id := range sortedCaravanIDs(store.Caravans) {
+		c := store.Caravans[id]
+		v := CaravanView{
+			ID:         c.ID,
+			OwnerName:  c.OwnerName,
+			From:       locationName(c.FromID),
+			To:         locationName(c.ToID),
+			CargoLabel: cargoLabel(c.Cargo),
+			Guards:     c.Guards,
+			Escorts:    len(c.Escorts),
+			EscortFee:  c.EscortFee,
+			TicksLeft:  c.TicksLeft,
+			TotalTicks: c.TotalTicks,
+			RiskPct:    caravanRiskLocked(store, c),
+			Mine:       c.OwnerPlayerID == p.ID,
+			RaidChance: caravanRaidChance(c),
+		}
+		riding := slices.Contains(c.Escorts, p.ID)
+		switch {
+		case v.Mine || riding:
+		case p.TravelTicksLeft > 0:
+			v.Unavailable = "You are on the road."
+		default:
+			v.CanEscort = here == c.FromID && len(c.Escorts) < caravanMaxEscorts
+			v.CanRaid = (here == c.FromID || here == c.ToID) && !slices.Contains(c.RaidedBy, p.ID)
+		}
+		views = append(views, v)
+	}
+	slices.SortStableFunc(views, func(a, b CaravanView) int {
+		switch {
+		case a.Mine == b.Mine:
+			return 0
+		case a.Mine:
+			return -1
+		default:
+			return 1
+		}
+	})
+	return views
+}
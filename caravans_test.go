@@ -0,0 +1,185 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCaravanHaulsCargoToDestinationMarket(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	p := &Player{ID: "p1", Name: "Ash Crow (Guest)", Gold: 40, LocationID: locationCapital, LastSeen: now, Inventory: map[string]int{"salt": 10}}
+	s.Players[p.ID] = p
+	salt, _ := commodityDefinitionByID("salt")
+	good, _ := localGood(locationFrontier, "salt")
+	_, _, sell := marketPricesLocked(s, locationFrontier, salt, good)
+	supply := marketSupplyLocked(s, locationFrontier, good)
+
+	handleActionInputLocked(s, p, now, ActionInput{Action: "send_caravan", LocationID: locationFrontier, Commodity: "salt", Amount: 8, Guards: 5, Reward: 2})
+	c := s.Caravans["cv-1"]
+	if c == nil || holdingOf(p, "salt") != 2 || p.Gold != 40-5*caravanGuardCost-2*caravanMaxEscorts {
+		t.Fatalf("dispatch should load cargo and escrow pay: %+v salt %d gold %d", c, holdingOf(p, "salt"), p.Gold)
+	}
+	if c.TicksLeft != travelTicksBetween(locationCapital, locationFrontier) {
+		t.Fatalf("caravan should take the route's travel time, got %d", c.TicksLeft)
+	}
+	if risk := caravanRiskLocked(s, c); risk != 0 {
+		t.Fatalf("five guards should cover the frontier road, risk %d%%", risk)
+	}
+
+	gold := p.Gold
+	for range c.TotalTicks {
+		processCaravanTickLocked(s, now)
+	}
+	if len(s.Caravans) != 0 {
+		t.Fatalf("caravan should have arrived: %+v", s.Caravans)
+	}
+	if want := gold + 8*sell + 2*caravanMaxEscorts; p.Gold != want {
+		t.Fatalf("arrival should sell the cargo and refund unused pay: gold %d, want %d", p.Gold, want)
+	}
+	if got := marketSupplyLocked(s, locationFrontier, good); got <= supply {
+		t.Fatalf("frontier salt pool should grow on arrival: %d -> %d", supply, got)
+	}
+	if last := s.Events[len(s.Events)-1]; last.Type != "Caravan" || !strings.Contains(last.Text, "reaches Frontier Village") {
+		t.Fatalf("arrival should be logged, got %+v", last)
+	}
+
+	handleActionInputLocked(s, p, now, ActionInput{Action: "send_caravan", LocationID: locationRuins, Commodity: "salt", Amount: 2})
+	if s.rejections[p.ID] != errCodeNotFound {
+		t.Fatalf("ruins do not trade salt, got %q", s.rejections[p.ID])
+	}
+}
+
+func TestCaravanEscortAndRaid(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	owner := &Player{ID: "p1", Name: "Ash Crow (Guest)", Gold: 30, LocationID: locationCapital, LastSeen: now, Inventory: map[string]int{"timber": 6}}
+	escort := &Player{ID: "p2", Name: "Bram Vale (Guest)", LocationID: locationCapital, LastSeen: now}
+	raider := &Player{ID: "p3", Name: "Cass Reed (Guest)", LocationID: locationHarbor, LastSeen: now}
+	s.Players[owner.ID], s.Players[escort.ID], s.Players[raider.ID] = owner, escort, raider
+
+	handleActionInputLocked(s, owner, now, ActionInput{Action: "send_caravan", LocationID: locationHarbor, Commodity: "timber", Amount: 6, Reward: 4})
+	c := s.Caravans["cv-1"]
+	if c == nil {
+		t.Fatalf("caravan should depart, got %q", s.rejections[owner.ID])
+	}
+	handleActionInputLocked(s, owner, now, ActionInput{Action: "escort_caravan", CaravanID: c.ID})
+	if s.rejections[owner.ID] != errCodeNotAllowed {
+		t.Fatalf("owners cannot escort their own caravan, got %q", s.rejections[owner.ID])
+	}
+	handleActionInputLocked(s, escort, now, ActionInput{Action: "escort_caravan", CaravanID: c.ID})
+	if len(c.Escorts) != 1 || escort.TravelToID != locationHarbor || escort.TravelTicksLeft != c.TicksLeft {
+		t.Fatalf("escort should ride with the caravan: %+v %+v", c, escort)
+	}
+	if got := buildPageDataLocked(s, raider.ID, false).Caravans; len(got) != 1 || !got[0].CanRaid || got[0].CanEscort {
+		t.Fatalf("a player at the destination may raid but not escort: %+v", got)
+	}
+
+	s.DailyActionDate[raider.ID] = now.Format("2006-01-02")
+	s.DailyHighImpactN[raider.ID] = highImpactDailyCap
+	handleActionInputLocked(s, raider, now, ActionInput{Action: "raid_caravan", CaravanID: c.ID})
+	if s.rejections[raider.ID] != errCodeHighImpactCap || len(c.RaidedBy) != 0 {
+		t.Fatalf("a raid should spend the daily high-impact budget, got %q", s.rejections[raider.ID])
+	}
+	s.DailyHighImpactN[raider.ID] = 0
+
+	chance := caravanRaidChance(c)
+	handleActionInputLocked(s, raider, now, ActionInput{Action: "raid_caravan", CaravanID: c.ID})
+	switch {
+	case holdingOf(raider, "timber") == 3:
+		if c.Cargo["timber"] != 3 || raider.Heat != 3 {
+			t.Fatalf("a successful raid takes half the cargo: cargo %+v heat %d", c.Cargo, raider.Heat)
+		}
+	case raider.Heat == 2 && c.Cargo["timber"] == 6:
+	default:
+		t.Fatalf("raid at %d%% left cargo %+v, raider timber %d heat %d", chance, c.Cargo, holdingOf(raider, "timber"), raider.Heat)
+	}
+	handleActionInputLocked(s, raider, now, ActionInput{Action: "raid_caravan", CaravanID: c.ID})
+	if s.rejections[raider.ID] != errCodeNotAllowed {
+		t.Fatalf("a caravan can be raided once per player, got %q", s.rejections[raider.ID])
+	}
+
+	// The last tick ends the trip one way or the other; the escort is paid
+	// only on arrival and the owner gets back whatever is not owed.
+	c.TicksLeft = 1
+	gold := owner.Gold
+	processCaravanTickLocked(s, now)
+	if _, ok := s.Caravans[c.ID]; ok {
+		t.Fatalf("caravan should leave the road after its last tick")
+	}
+	switch escort.Gold {
+	case 4:
+		if owner.Gold < gold+2*4 {
+			t.Fatalf("arrival should refund the unused escort pay: owner %d -> %d", gold, owner.Gold)
+		}
+	case 0:
+		if owner.Gold != gold+caravanMaxEscorts*4 {
+			t.Fatalf("a lost caravan should refund all escort pay: owner %d -> %d", gold, owner.Gold)
+		}
+	default:
+		t.Fatalf("escort paid %dg", escort.Gold)
+	}
+}
+
+func TestCaravanAmbushOutcomes(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	p := &Player{ID: "p1", Name: "Ash Crow (Guest)", LocationID: locationFrontier, LastSeen: now}
+	s.Players[p.ID] = p
+	c := &Caravan{ID: "cv-1", OwnerPlayerID: p.ID, OwnerName: p.Name, FromID: locationFrontier, ToID: locationRuins, Cargo: map[string]int{"medicine": 4}, EscortFee: 2, TicksLeft: 2}
+	s.Caravans[c.ID] = c
+
+	base := caravanRiskLocked(s, c)
+	if base != locationDanger(locationRuins) {
+		t.Fatalf("an unguarded caravan should face the ruins' danger, got %d%%", base)
+	}
+	p.Heat = 8
+	s.World.BanditActivity = 4
+	if got := caravanRiskLocked(s, c); got != base+4+4 {
+		t.Fatalf("heat and bandit activity should add risk: %d%%", got)
+	}
+
+	p.Heat = 0
+	ambushCaravanLocked(s, c, now)
+	if len(s.Caravans) != 0 || p.Gold != 2*caravanMaxEscorts || s.World.BanditActivity != 4+banditActivityPerAmbush {
+		t.Fatalf("bandits should take the caravan: caravans %d gold %d activity %d", len(s.Caravans), p.Gold, s.World.BanditActivity)
+	}
+
+	// At 20 heat the Watch always gets there first.
+	p.Heat = 20
+	capital, _ := localGood(locationCapital, "medicine")
+	supply := marketSupplyLocked(s, locationCapital, capital)
+	s.Caravans[c.ID] = c
+	ambushCaravanLocked(s, c, now)
+	if got := marketSupplyLocked(s, locationCapital, capital); got <= supply {
+		t.Fatalf("impounded cargo should go to the capital: %d -> %d", supply, got)
+	}
+	if last := s.Events[len(s.Events)-1]; !strings.Contains(last.Text, "City Watch") {
+		t.Fatalf("interception should be logged, got %q", last.Text)
+	}
+}
+
+func TestCaravanEscortPayCannotOverflowEscrow(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	p := &Player{ID: "p1", Name: "Ash Crow (Guest)", Gold: 20, LocationID: locationCapital, LastSeen: now, Inventory: map[string]int{"salt": 10}}
+	s.Players[p.ID] = p
+
+	// 3 * 6148914691236517206 wraps to a small positive int64.
+	handleActionInputLocked(s, p, now, ActionInput{Action: "send_caravan", LocationID: locationFrontier, Commodity: "salt", Amount: 8, Reward: 6148914691236517206})
+	if s.rejections[p.ID] != errCodeInvalidInput {
+		t.Fatalf("an absurd escort fee should be refused, got %q", s.rejections[p.ID])
+	}
+	if p.Gold != 20 || holdingOf(p, "salt") != 10 || len(s.Caravans) != 0 {
+		t.Fatalf("a refused caravan must not leave: gold %d, salt %d, %d caravans", p.Gold, holdingOf(p, "salt"), len(s.Caravans))
+	}
+
+	if _, ok := caravanUpfront(0, math.MaxInt/2); ok {
+		t.Fatalf("caravanUpfront should report overflow")
+	}
+	if upfront, ok := caravanUpfront(caravanMaxGuards, caravanMaxEscortFee); !ok || upfront != caravanMaxGuards*caravanGuardCost+caravanMaxEscortFee*caravanMaxEscorts {
+		t.Fatalf("caravanUpfront(max, max) = %d, %v", upfront, ok)
+	}
+}
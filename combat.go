@@ -0,0 +1,584 @@
+package main
+
+import (
+	"fmt"
+	mathrand "math/rand"
+	"strings"
+	"time"
+)
+
+const (
+	fightDuel     = "duel"
+	fightAmbush   = "ambush"
+	fightGuardian = "guardian"
+	fightBandits  = "bandits"
+
+	tacticPress  = "press"
+	tacticDefend = "defend"
+	tacticFlee   = "flee"
+	tacticYield  = "yield"
+
+	// combatBasePower is every fighter's strength before relics and wounds;
+	// held relics add up to combatRelicPowerMax and an injury costs
+	// combatInjuredPower. Watch officers are combatWatchPower stronger.
+	combatBasePower     = 10
+	combatRelicPowerMax = 4
+	combatInjuredPower  = 3
+	combatWatchPower    = 3
+	combatGuardianPower = 12
+	combatBanditPower   = 8
+	// combatVigor is how much punishment a fighter takes before falling.
+	combatVigor = 4
+	// A fight that has not ended after combatMaxRounds rounds, one a tick,
+	// is broken off.
+	combatMaxRounds = 4
+	// combatHitPct is the chance a pressing fighter lands a blow, shifted by
+	// combatPowerStepPct per point of power over the opponent and reduced
+	// by combatDefendPct against a defender. A defender counters a press on
+	// combatCounterPct; a fleeing fighter gets away on combatFleePct, plus
+	// combatDefendPct when the opponent is only defending.
+	combatHitPct       = 50
+	combatPowerStepPct = 4
+	combatDefendPct    = 20
+	combatCounterPct   = 30
+	combatFleePct      = 40
+	// The loser is injured for combatInjuryTicks, or combatGrievousTicks
+	// when beaten well past their vigor, and loses combatLootPct of what
+	// they carry.
+	combatInjuryTicks   = 4
+	combatGrievousTicks = 8
+	combatLootPct       = 30
+	// combatUnlawfulHeat is added to whoever starts an ambush, or a duel
+	// where the city keeps the peace.
+	combatUnlawfulHeat = 3
+	combatDuelRep      = 2
+	// A duel challenge lapses if the challenged player has not accepted it
+	// within combatChallengeTicks.
+	combatChallengeTicks = 3
+	// combatBanditDivisor scales a traveler's chance of meeting bandits:
+	// the road's danger and bandit activity over this, in percent a tick.
+	combatBanditDivisor = 3
+	// combatHealCost is the Temple's fee to dress wounds.
+	combatHealCost = 8
+)
+
+var combatTactics = []string{tacticPress, tacticDefend, tacticFlee, tacticYield}
+
+// Fighter is one side of a fight. PlayerID is empty for the ruin guardians
+// and bandits.
+type Fighter struct {
+	PlayerID string `json:",omitempty"`
+	Name     string
+	Power    int
+	Vigor    int
+	Tactic   string
+}
+
+// Fight is a combat encounter resolved one round per tick, each side using
+// the tactic it last chose.
+type Fight struct {
+	ID          string
+	Kind        string
+	LocationID  string
+	Attacker    Fighter
+	Defender    Fighter
+	Round       int
+	Unlawful    bool
+	StartedTick int64
+	// Pending marks a duel challenge the defender has not accepted yet; no
+	// rounds are fought until they do.
+	Pending bool `json:",omitempty"`
+	// Log keeps a line per round for the dashboard.
+	Log []string `json:",omitempty"`
+}
+
+type FightView struct {
+	ID           string   `json:"id"`
+	Kind         string   `json:"kind"`
+	OpponentName string   `json:"opponent_name"`
+	LocationName string   `json:"location_name"`
+	Round        int      `json:"round"`
+	MaxRounds    int      `json:"max_rounds"`
+	Vigor        int      `json:"vigor"`
+	OpponentVig  int      `json:"opponent_vigor"`
+	Tactic       string   `json:"tactic"`
+	Tactics      []string `json:"tactics"`
+	Log          []string `json:"log"`
+	// Pending is an unanswered duel challenge; Challenged is set for the
+	// player who may accept it.
+	Pending    bool `json:"pending"`
+	Challenged bool `json:"challenged"`
+	ExpiresIn  int  `json:"expires_in,omitempty"`
+}
+
+func fightKindLabel(kind string) string {
+	switch kind {
+	case fightAmbush:
+		return "Ambush"
+	case fightGuardian:
+		return "Ruin guardian"
+	case fightBandits:
+		return "Bandits"
+	}
+	return "Duel"
+}
+
+// combatPowerLocked is p's strength in a fight.
+func combatPowerLocked(store *Store, p *Player) int {
+	relics := 0
+	for _, relic := range store.Relics {
+		if relic.OwnerPlayerID == p.ID {
+			relics += relic.Power
+		}
+	}
+	power := combatBasePower + minInt(relics, combatRelicPowerMax)
+	if p.NPCRole == npcRoleWatch {
+		power += combatWatchPower
+	}
+	if hasStatus(p, statusInjured) {
+		power -= combatInjuredPower
+	}
+	return power
+}
+
+func fighterFor(store *Store, p *Player, tactic string) Fighter {
+	return Fighter{PlayerID: p.ID, Name: p.Name, Power: combatPowerLocked(store, p), Vigor: combatVigor, Tactic: tactic}
+}
+
+// fightForLocked is the fight p is in, if any.
+func fightForLocked(store *Store, playerID string) *Fight {
+	for _, id := range sortedKeys(store.Fights) {
+		if f := store.Fights[id]; f.Attacker.PlayerID == playerID || f.Defender.PlayerID == playerID {
+			return f
+		}
+	}
+	return nil
+}
+
+func parseTactic(stance, fallback string) string {
+	stance = strings.ToLower(strings.TrimSpace(stance))
+	if containsString(combatTactics, stance) {
+		return stance
+	}
+	return fallback
+}
+
+func startFightLocked(store *Store, now time.Time, kind, locationID string, attacker, defender Fighter, unlawful bool) *Fight {
+	store.NextFightID++
+	f := &Fight{
+		ID:          fmt.Sprintf("fight-%d", store.NextFightID),
+		Kind:        kind,
+		LocationID:  locationID,
+		Attacker:    attacker,
+		Defender:    defender,
+		Unlawful:    unlawful,
+		StartedTick: store.TickCount,
+	}
+	store.dirty.mark(dirtyFights, f.ID)
+	store.Fights[f.ID] = f
+	if p := store.Players[attacker.PlayerID]; p != nil && unlawful {
+		store.dirty.mark(dirtyPlayers, p.ID)
+		p.Heat = clampInt(p.Heat+combatUnlawfulHeat, 0, 20)
+	}
+	addEventLocked(store, Event{Type: "Combat", Severity: 3, Text: fmt.Sprintf("%s in %s: [%s] against [%s].", fightKindLabel(kind), locationName(locationID), attacker.Name, defender.Name), At: now})
+	if defender.PlayerID != "" {
+		setToastLocked(store, defender.PlayerID, fmt.Sprintf("%s attacks you! Choose to press, defend, flee or yield.", attacker.Name))
+	}
+	if attacker.PlayerID != "" {
+		setToastLocked(store, attacker.PlayerID, fmt.Sprintf("You engage %s.", defender.Name))
+	}
+	return f
+}
+
+// challengeDuelLocked challenges a player at the same location to a duel.
+// Nothing is fought until they accept with acceptDuelLocked.
+func challengeDuelLocked(store *Store, p *Player, now time.Time, in ActionInput) {
+	target := store.Players[in.TargetID]
+	if target == nil || target.ID == p.ID {
+		rejectLocked(store, p.ID, errCodeInvalidInput, "Choose someone to duel.")
+		return
+	}
+	if target.TravelTicksLeft > 0 || marketLocationID(target) != marketLocationID(p) {
+		rejectLocked(store, p.ID, errCodeNotAllowed, fmt.Sprintf("%s is not in %s.", target.Name, locationName(marketLocationID(p))))
+		return
+	}
+	if !combatReadyLocked(store, p, target) {
+		return
+	}
+	if !consumeHighImpactBudgetLocked(store, p.ID, now) {
+		rejectLocked(store, p.ID, errCodeHighImpactCap, "Daily cap reached for high-impact actions.")
+		return
+	}
+	store.NextFightID++
+	f := &Fight{
+		ID:          fmt.Sprintf("fight-%d", store.NextFightID),
+		Kind:        fightDuel,
+		LocationID:  marketLocationID(p),
+		Attacker:    fighterFor(store, p, parseTactic(in.Stance, tacticPress)),
+		Defender:    fighterFor(store, target, tacticDefend),
+		StartedTick: store.TickCount,
+		Pending:     true,
+	}
+	store.dirty.mark(dirtyFights, f.ID)
+	store.Fights[f.ID] = f
+	setToastLocked(store, target.ID, fmt.Sprintf("%s challenges you to a duel. Accept or decline within %d ticks.", p.Name, combatChallengeTicks))
+	setToastLocked(store, p.ID, fmt.Sprintf("You challenge %s to a duel.", target.Name))
+}
+
+// acceptDuelLocked takes up the duel p was challenged to. It is fought
+// where the challenge was made, and is unlawful where the city keeps the
+// peace.
+func acceptDuelLocked(store *Store, p *Player, now time.Time, in ActionInput) {
+	f := fightForLocked(store, p.ID)
+	if f == nil || !f.Pending || f.Defender.PlayerID != p.ID {
+		rejectLocked(store, p.ID, errCodeNotFound, "No one has challenged you.")
+		return
+	}
+	store.dirty.mark(dirtyFights, f.ID)
+	delete(store.Fights, f.ID)
+	challenger := store.Players[f.Attacker.PlayerID]
+	if challenger == nil || challenger.TravelTicksLeft > 0 || marketLocationID(challenger) != f.LocationID || marketLocationID(p) != f.LocationID {
+		rejectLocked(store, p.ID, errCodeNotAllowed, "Your challenger is no longer here.")
+		return
+	}
+	if !combatReadyLocked(store, p, challenger) {
+		return
+	}
+	unlawful := marketTreasuryID(f.LocationID) == "city_authority"
+	startFightLocked(store, now, fightDuel, f.LocationID, fighterFor(store, challenger, f.Attacker.Tactic), fighterFor(store, p, parseTactic(in.Stance, tacticDefend)), unlawful)
+}
+
+// declineDuelLocked lets the challenged player refuse a duel, or the
+// challenger withdraw it.
+func declineDuelLocked(store *Store, p *Player) {
+	f := fightForLocked(store, p.ID)
+	if f == nil || !f.Pending {
+		rejectLocked(store, p.ID, errCodeNotFound, "There is no challenge to decline.")
+		return
+	}
+	store.dirty.mark(dirtyFights, f.ID)
+	delete(store.Fights, f.ID)
+	other := f.Attacker
+	if other.PlayerID == p.ID {
+		other = f.Defender
+	}
+	setToastLocked(store, other.PlayerID, fmt.Sprintf("%s turns down the duel.", p.Name))
+	setToastLocked(store, p.ID, fmt.Sprintf("The duel with %s is off.", other.Name))
+}
+
+// ambushLocked waylays a traveler on a road leading to or from p's
+// location. Ambushes are always unlawful.
+func ambushLocked(store *Store, p *Player, now time.Time, in ActionInput) {
+	target := store.Players[in.TargetID]
+	if target == nil || target.ID == p.ID {
+		rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a traveler to ambush.")
+		return
+	}
+	here := marketLocationID(p)
+	if target.TravelTicksLeft <= 0 || (target.LocationID != here && target.TravelToID != here) {
+		rejectLocked(store, p.ID, errCodeNotAllowed, fmt.Sprintf("%s is not on a road through %s.", target.Name, locationName(here)))
+		return
+	}
+	if !combatReadyLocked(store, p, target) {
+		return
+	}
+	if !consumeHighImpactBudgetLocked(store, p.ID, now) {
+		rejectLocked(store, p.ID, errCodeHighImpactCap, "Daily cap reached for high-impact actions.")
+		return
+	}
+	startFightLocked(store, now, fightAmbush, here, fighterFor(store, p, parseTactic(in.Stance, tacticPress)), fighterFor(store, target, tacticDefend), true)
+}
+
+// combatReadyLocked refuses a fight when either side is already in one or
+// the target is jailed or nursing wounds.
+func combatReadyLocked(store *Store, p, target *Player) bool {
+	switch {
+	case fightForLocked(store, p.ID) != nil:
+		rejectLocked(store, p.ID, errCodeNotAllowed, "You are already in a fight.")
+	case fightForLocked(store, target.ID) != nil:
+		rejectLocked(store, p.ID, errCodeNotAllowed, fmt.Sprintf("%s is already in a fight.", target.Name))
+	case hasStatus(target, statusJailed):
+		rejectLocked(store, p.ID, errCodeNotAllowed, fmt.Sprintf("%s is in the city jail.", target.Name))
+	case hasStatus(target, statusInjured):
+		rejectLocked(store, p.ID, errCodeNotAllowed, fmt.Sprintf("%s is nursing wounds.", target.Name))
+	default:
+		return true
+	}
+	return false
+}
+
+// setTacticLocked changes p's tactic for the next round of their fight.
+func setTacticLocked(store *Store, p *Player, in ActionInput) {
+	f := fightForLocked(store, p.ID)
+	if f == nil {
+		rejectLocked(store, p.ID, errCodeNotFound, "You are not in a fight.")
+		return
+	}
+	if f.Pending {
+		rejectLocked(store, p.ID, errCodeNotAllowed, "The duel has not been accepted.")
+		return
+	}
+	tactic := parseTactic(in.Stance, "")
+	if tactic == "" {
+		rejectLocked(store, p.ID, errCodeInvalidInput, "Choose press, defend, flee or yield.")
+		return
+	}
+	store.dirty.mark(dirtyFights, f.ID)
+	if f.Attacker.PlayerID == p.ID {
+		f.Attacker.Tactic = tactic
+	} else {
+		f.Defender.Tactic = tactic
+	}
+	setToastLocked(store, p.ID, fmt.Sprintf("Next round you %s.", tactic))
+}
+
+// startGuardianFightLocked sets a ruin guardian on an explorer.
+func startGuardianFightLocked(store *Store, p *Player, now time.Time) {
+	guardian := Fighter{Name: "Ruin Guardian", Power: combatGuardianPower, Vigor: combatVigor, Tactic: tacticPress}
+	startFightLocked(store, now, fightGuardian, p.LocationID, guardian, fighterFor(store, p, tacticDefend), false)
+}
+
+// rollBanditAmbushesLocked rarely sets bandits on players on the road; the
+// chance grows with the road's danger and bandit activity.
+func rollBanditAmbushesLocked(store *Store, now time.Time) {
+	rng := rngStreamLocked(store, rngStreamCombat)
+	for _, id := range sortedKeys(store.Players) {
+		p := store.Players[id]
+		if p.TravelTicksLeft <= 0 || fightForLocked(store, p.ID) != nil {
+			continue
+		}
+		chance := (maxInt(locationDanger(p.LocationID), locationDanger(p.TravelToID)) + store.World.BanditActivity) / combatBanditDivisor
+		if !rollPercent(rng, chance) {
+			continue
+		}
+		bandits := Fighter{Name: "Road Bandits", Power: combatBanditPower + store.World.BanditActivity/3, Vigor: combatVigor, Tactic: tacticPress}
+		startFightLocked(store, now, fightBandits, p.TravelToID, bandits, fighterFor(store, p, tacticDefend), false)
+	}
+}
+
+// processCombatTickLocked fights one round of every fight in progress.
+func processCombatTickLocked(store *Store, now time.Time) {
+	rollBanditAmbushesLocked(store, now)
+	rng := rngStreamLocked(store, rngStreamCombat)
+	for _, id := range sortedKeys(store.Fights) {
+		f := store.Fights[id]
+		if !fighterPresentLocked(store, f.Attacker) || !fighterPresentLocked(store, f.Defender) {
+			delete(store.Fights, id)
+			continue
+		}
+		if f.Pending {
+			if store.TickCount-f.StartedTick >= combatChallengeTicks {
+				delete(store.Fights, id)
+				setToastLocked(store, f.Attacker.PlayerID, fmt.Sprintf("%s lets your challenge lapse.", f.Defender.Name))
+			}
+			continue
+		}
+		fightRoundLocked(store, f, rng, now)
+	}
+}
+
+func fighterPresentLocked(store *Store, f Fighter) bool {
+	return f.PlayerID == "" || store.Players[f.PlayerID] != nil
+}
+
+// fightRoundLocked resolves a round: yields end the fight first, then
+// flight, then blows. A fighter brought to nothing loses.
+func fightRoundLocked(store *Store, f *Fight, rng *mathrand.Rand, now time.Time) {
+	f.Round++
+	a, d := &f.Attacker, &f.Defender
+	switch {
+	case a.Tactic == tacticYield && d.Tactic == tacticYield:
+		endFightLocked(store, f, nil, nil, "Both sides lower their weapons.", now)
+		return
+	case a.Tactic == tacticYield:
+		endFightLocked(store, f, d, a, fmt.Sprintf("[%s] yields.", a.Name), now)
+		return
+	case d.Tactic == tacticYield:
+		endFightLocked(store, f, a, d, fmt.Sprintf("[%s] yields.", d.Name), now)
+		return
+	}
+	for _, pair := range [][2]*Fighter{{a, d}, {d, a}} {
+		runner, chaser := pair[0], pair[1]
+		if runner.Tactic != tacticFlee {
+			continue
+		}
+		chance := combatFleePct + (runner.Power-chaser.Power)*combatPowerStepPct
+		if chaser.Tactic == tacticDefend {
+			chance += combatDefendPct
+		}
+		if rollPercent(rng, clampInt(chance, 10, 90)) {
+			endFightLocked(store, f, nil, nil, fmt.Sprintf("[%s] escapes.", runner.Name), now)
+			return
+		}
+	}
+	var lines []string
+	for _, pair := range [][2]*Fighter{{a, d}, {d, a}} {
+		striker, target := pair[0], pair[1]
+		diff := (striker.Power - target.Power) * combatPowerStepPct
+		switch {
+		case striker.Tactic == tacticPress:
+			chance := combatHitPct + diff
+			damage := 2
+			if target.Tactic == tacticDefend {
+				chance -= combatDefendPct
+				damage = 1
+			}
+			if rollPercent(rng, clampInt(chance, 10, 90)) {
+				target.Vigor -= damage
+				lines = append(lines, fmt.Sprintf("%s lands a blow", striker.Name))
+			}
+		case striker.Tactic == tacticDefend && target.Tactic == tacticPress:
+			if rollPercent(rng, clampInt(combatCounterPct+diff, 5, 75)) {
+				target.Vigor--
+				lines = append(lines, fmt.Sprintf("%s counters", striker.Name))
+			}
+		}
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "no blow lands")
+	}
+	f.Log = append(f.Log, fmt.Sprintf("Round %d: %s.", f.Round, strings.Join(lines, "; ")))
+	switch {
+	case a.Vigor <= 0 && d.Vigor <= 0:
+		endFightLocked(store, f, nil, nil, "Both fighters fall.", now)
+		injureLocked(store, a, now)
+		injureLocked(store, d, now)
+	case d.Vigor <= 0:
+		endFightLocked(store, f, a, d, fmt.Sprintf("[%s] is beaten.", d.Name), now)
+	case a.Vigor <= 0:
+		endFightLocked(store, f, d, a, fmt.Sprintf("[%s] is beaten.", a.Name), now)
+	case f.Round >= combatMaxRounds:
+		endFightLocked(store, f, nil, nil, "The fight is broken off.", now)
+	}
+}
+
+// endFightLocked closes f. A loser who fell is injured; any loser gives up
+// part of what they carry, to the winner if the winner is a player.
+func endFightLocked(store *Store, f *Fight, winner, loser *Fighter, summary string, now time.Time) {
+	store.dirty.mark(dirtyFights, f.ID)
+	delete(store.Fights, f.ID)
+	for _, side := range []Fighter{f.Attacker, f.Defender} {
+		if side.PlayerID != "" {
+			store.dirty.mark(dirtyPlayers, side.PlayerID)
+		}
+	}
+	text := fmt.Sprintf("%s in %s: %s", fightKindLabel(f.Kind), locationName(f.LocationID), summary)
+	if loser != nil {
+		if loser.Vigor <= 0 {
+			injureLocked(store, loser, now)
+		}
+		if taken := lootLoserLocked(store, f, winner, loser, now); taken != "" {
+			text += fmt.Sprintf(" [%s] loses %s.", loser.Name, taken)
+		}
+		if w := store.Players[winner.PlayerID]; w != nil && f.Kind == fightDuel && !f.Unlawful {
+			w.Rep = clampInt(w.Rep+combatDuelRep, -100, 100)
+		}
+	}
+	addEventLocked(store, Event{Type: "Combat", Severity: 3, Text: text, At: now})
+	for _, side := range []Fighter{f.Attacker, f.Defender} {
+		if side.PlayerID != "" {
+			setToastLocked(store, side.PlayerID, text)
+		}
+	}
+}
+
+// injureLocked lays a fallen player up; a fighter beaten well past their
+// vigor takes longer to mend.
+func injureLocked(store *Store, f *Fighter, now time.Time) {
+	p := store.Players[f.PlayerID]
+	if p == nil {
+		return
+	}
+	ticks := combatInjuryTicks
+	if f.Vigor < 0 {
+		ticks = combatGrievousTicks
+	}
+	addStatusLocked(p, statusInjured, "", ticks, "wounded in a fight")
+}
+
+// lootLoserLocked takes combatLootPct of the loser's gold and goods. A ruin
+// guardian's defeat yields a relic instead; bandits who win grow bolder.
+func lootLoserLocked(store *Store, f *Fight, winner, loser *Fighter, now time.Time) string {
+	to := store.Players[winner.PlayerID]
+	if f.Kind == fightGuardian && to != nil {
+		if relic := addRelicLocked(store, to, randomRelicDefinition(rngStreamLocked(store, rngStreamCombatLoot)), now); relic != nil {
+			return "the relic it guarded"
+		}
+		return ""
+	}
+	from := store.Players[loser.PlayerID]
+	if from == nil {
+		return ""
+	}
+	if f.Kind == fightBandits && to == nil {
+		store.World.BanditActivity = minInt(banditActivityMax, store.World.BanditActivity+banditActivityPerAmbush)
+	}
+	var parts []string
+	if gold := from.Gold * combatLootPct / 100; gold > 0 {
+		from.Gold -= gold
+		if to != nil {
+			to.Gold += gold
+		}
+		parts = append(parts, fmt.Sprintf("%dg", gold))
+	}
+	ids := append([]string{commodityGrain}, sortedKeys(from.Inventory)...)
+	for _, id := range ids {
+		if n := holdingOf(from, id) * combatLootPct / 100; n > 0 {
+			addHolding(from, id, -n)
+			if to != nil {
+				addHolding(to, id, n)
+			}
+			parts = append(parts, fmt.Sprintf("%d %s", n, commodityLootName(id)))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// templeHealLocked dresses p's wounds at the Temple for a fee paid into the
+// Temple treasury.
+func templeHealLocked(store *Store, p *Player, now time.Time) {
+	if !hasStatus(p, statusInjured) {
+		rejectLocked(store, p.ID, errCodeNotAllowed, "You have no wounds to dress.")
+		return
+	}
+	if p.LocationID != locationCapital {
+		rejectLocked(store, p.ID, errCodeTravelLockout, "The Temple is in the Capital.")
+		return
+	}
+	if p.Gold < combatHealCost {
+		rejectLocked(store, p.ID, errCodeInsufficientGold, fmt.Sprintf("The Temple asks %dg.", combatHealCost))
+		return
+	}
+	p.Gold -= combatHealCost
+	depositTreasuryLocked(store, now, "temple", combatHealCost, "Temple healing", p)
+	clearStatus(p, statusInjured)
+	addEventLocked(store, Event{Type: "Doctrine", Severity: 1, Text: fmt.Sprintf("The Temple tends [%s]'s wounds.", p.Name), At: now})
+	setToastLocked(store, p.ID, fmt.Sprintf("Your wounds are dressed (%dg).", combatHealCost))
+}
+
+func fightViewLocked(store *Store, p *Player) *FightView {
+	f := fightForLocked(store, p.ID)
+	if f == nil {
+		return nil
+	}
+	me, them := f.Attacker, f.Defender
+	if f.Defender.PlayerID == p.ID {
+		me, them = f.Defender, f.Attacker
+	}
+	return &FightView{
+		ID:           f.ID,
+		Kind:         fightKindLabel(f.Kind),
+		OpponentName: them.Name,
+		LocationName: locationName(f.LocationID),
+		Round:        f.Round,
+		MaxRounds:    combatMaxRounds,
+		Vigor:        maxInt(0, me.Vigor),
+		OpponentVig:  maxInt(0, them.Vigor),
+		Tactic:       me.Tactic,
+		Tactics:      combatTactics,
+		Log:          f.Log,
+		Pending:      f.Pending,
+		Challenged:   f.Pending && f.Defender.PlayerID == p.ID,
+		ExpiresIn:    int(maxInt64(0, f.StartedTick+combatChallengeTicks-store.TickCount)),
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDuelsAreFoughtInRoundsWithTactics(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	a := &Player{ID: "p1", Name: "Ash Crow (Guest)", Gold: 100, LocationID: locationCapital, LastSeen: now}
+	b := &Player{ID: "p2", Name: "Bram Vale (Guest)", Gold: 100, Grain: 10, LocationID: locationCapital, LastSeen: now}
+	s.Players[a.ID], s.Players[b.ID] = a, b
+
+	handleActionInputLocked(s, a, now, ActionInput{Action: "challenge_duel", TargetID: b.ID})
+	f := fightForLocked(s, b.ID)
+	if f == nil || !f.Pending || a.Heat != 0 {
+		t.Fatalf("a challenge should wait for an answer without drawing heat: %+v heat %d", f, a.Heat)
+	}
+	handleActionInputLocked(s, b, now, ActionInput{Action: "challenge_duel", TargetID: a.ID})
+	if s.rejections[b.ID] != errCodeNotAllowed {
+		t.Fatalf("one fight at a time, got %q", s.rejections[b.ID])
+	}
+	handleActionInputLocked(s, b, now, ActionInput{Action: "combat_tactic", Stance: tacticYield})
+	if s.rejections[b.ID] != errCodeNotAllowed {
+		t.Fatalf("no tactics before the duel is accepted, got %q", s.rejections[b.ID])
+	}
+	handleActionInputLocked(s, b, now, ActionInput{Action: "accept_duel", Stance: tacticYield})
+	f = fightForLocked(s, b.ID)
+	if f == nil || f.Pending || f.Kind != fightDuel || !f.Unlawful || a.Heat != combatUnlawfulHeat {
+		t.Fatalf("an accepted duel in the Capital should start and draw heat: %+v heat %d", f, a.Heat)
+	}
+	processCombatTickLocked(s, now)
+	if len(s.Fights) != 0 || b.Gold != 70 || b.Grain != 7 || a.Gold != 130 || a.Grain != 3 {
+		t.Fatalf("a yield should hand over part of what the loser carries: a=%dg/%d b=%dg/%d", a.Gold, a.Grain, b.Gold, b.Grain)
+	}
+	if hasStatus(b, statusInjured) || !strings.Contains(lastEventText(s), "[Bram Vale (Guest)] yields.") {
+		t.Fatalf("a yield should not wound: %+v %q", b.Statuses, lastEventText(s))
+	}
+
+	a.LocationID, b.LocationID = locationHarbor, locationHarbor
+	heat := a.Heat
+	// Seed 13 opens the combat stream at tick 3 with 40, 56, 39, 57: Ash
+	// lands both blows and Bram neither.
+	s.TickCount = 3
+	handleActionInputLocked(s, a, now, ActionInput{Action: "challenge_duel", TargetID: b.ID, Stance: tacticPress})
+	handleActionInputLocked(s, b, now, ActionInput{Action: "accept_duel", Stance: tacticPress})
+	for round := 0; round < combatMaxRounds && len(s.Fights) > 0; round++ {
+		processCombatTickLocked(s, now)
+	}
+	if hasStatus(a, statusInjured) || !hasStatus(b, statusInjured) || !strings.Contains(lastEventText(s), "[Bram Vale (Guest)] is beaten.") {
+		t.Fatalf("pressing duels should end with the loser wounded: a=%+v b=%+v %q", a.Statuses, b.Statuses, lastEventText(s))
+	}
+	if a.Heat != heat || len(s.Fights) != 0 {
+		t.Fatalf("a duel where the city does not keep the peace is lawful: heat %d", a.Heat)
+	}
+}
+
+func TestDuelChallengesCanBeDeclinedOrLapse(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	a := &Player{ID: "p1", Name: "Ash Crow (Guest)", LocationID: locationHarbor, LastSeen: now}
+	b := &Player{ID: "p2", Name: "Bram Vale (Guest)", LocationID: locationHarbor, LastSeen: now}
+	s.Players[a.ID], s.Players[b.ID] = a, b
+
+	handleActionInputLocked(s, a, now, ActionInput{Action: "accept_duel"})
+	if s.rejections[a.ID] != errCodeNotFound {
+		t.Fatalf("there is nothing to accept yet, got %q", s.rejections[a.ID])
+	}
+	handleActionInputLocked(s, a, now, ActionInput{Action: "challenge_duel", TargetID: b.ID})
+	handleActionInputLocked(s, a, now, ActionInput{Action: "accept_duel"})
+	if s.rejections[a.ID] != errCodeNotFound {
+		t.Fatalf("a challenger cannot accept their own challenge, got %q", s.rejections[a.ID])
+	}
+	if v := fightViewLocked(s, b); v == nil || !v.Pending || !v.Challenged || v.ExpiresIn != combatChallengeTicks {
+		t.Fatalf("the challenged player should be offered the duel: %+v", v)
+	}
+	handleActionInputLocked(s, b, now, ActionInput{Action: "decline_duel"})
+	if len(s.Fights) != 0 || hasStatus(b, statusInjured) {
+		t.Fatalf("a declined duel should be off: %+v", s.Fights)
+	}
+
+	delete(s.DailyHighImpactN, a.ID)
+	handleActionInputLocked(s, a, now, ActionInput{Action: "challenge_duel", TargetID: b.ID})
+	for i := 0; i < combatChallengeTicks; i++ {
+		processCombatTickLocked(s, now)
+		s.TickCount++
+	}
+	processCombatTickLocked(s, now)
+	if len(s.Fights) != 0 || b.Statuses != nil {
+		t.Fatalf("an unanswered challenge should lapse without a fight: %+v", s.Fights)
+	}
+}
+
+func TestInjuriesCloseTheRoadUntilTheTempleHeals(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	p := &Player{ID: "p1", Name: "Ash Crow (Guest)", Gold: 20, LocationID: locationCapital, LastSeen: now}
+	s.Players[p.ID] = p
+
+	handleActionInputLocked(s, p, now, ActionInput{Action: "temple_heal"})
+	if s.rejections[p.ID] != errCodeNotAllowed {
+		t.Fatalf("the Temple only dresses wounds, got %q", s.rejections[p.ID])
+	}
+	injureLocked(s, &Fighter{PlayerID: p.ID, Vigor: -1}, now)
+	if st := statusFor(p, statusInjured); st == nil || st.TicksLeft != combatGrievousTicks {
+		t.Fatalf("a fighter beaten past their vigor should be grievously hurt: %+v", p.Statuses)
+	}
+	handleActionInputLocked(s, p, now, ActionInput{Action: "travel", LocationID: nearestLocation(locationCapital)})
+	if s.rejections[p.ID] != errCodeRestricted {
+		t.Fatalf("the injured cannot travel, got %q", s.rejections[p.ID])
+	}
+	handleActionInputLocked(s, p, now, ActionInput{Action: "temple_heal"})
+	if hasStatus(p, statusInjured) || p.Gold != 20-combatHealCost {
+		t.Fatalf("the Temple should heal for its fee: %+v gold %d", p.Statuses, p.Gold)
+	}
+}
+
+func TestAmbushesWaylayTravelersOnTheRoad(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	p := &Player{ID: "p1", Name: "Ash Crow (Guest)", Gold: 100, LocationID: locationHarbor, LastSeen: now}
+	v := &Player{ID: "p2", Name: "Bram Vale (Guest)", Gold: 100, LocationID: locationHarbor, LastSeen: now}
+	s.Players[p.ID], s.Players[v.ID] = p, v
+
+	handleActionInputLocked(s, p, now, ActionInput{Action: "ambush", TargetID: v.ID})
+	if s.rejections[p.ID] != errCodeNotAllowed || len(s.Fights) != 0 {
+		t.Fatalf("only travelers can be ambushed, got %q", s.rejections[p.ID])
+	}
+	v.LocationID, v.TravelToID, v.TravelTicksLeft = locationCapital, locationHarbor, 2
+	handleActionInputLocked(s, p, now, ActionInput{Action: "ambush", TargetID: v.ID})
+	f := fightForLocked(s, v.ID)
+	if f == nil || f.Kind != fightAmbush || !f.Unlawful || p.Heat != combatUnlawfulHeat {
+		t.Fatalf("an ambush should start and draw heat: %+v heat %d", f, p.Heat)
+	}
+	handleActionInputLocked(s, v, now, ActionInput{Action: "combat_tactic", Stance: "dance"})
+	if s.rejections[v.ID] != errCodeInvalidInput {
+		t.Fatalf("unknown tactics should be refused, got %q", s.rejections[v.ID])
+	}
+	if view := fightViewLocked(s, v); view == nil || view.OpponentName != p.Name || view.Tactic != tacticDefend {
+		t.Fatalf("the defender should see the fight: %+v", view)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// commodityGrain is the one commodity the rest of the game refers to by ID:
+// its pool is World.GrainSupply, players hold it in Player.Grain, and its tier
+// drives unrest, contracts and the city situation.
+const commodityGrain = "grain"
+
+// commodityTierOrder lists the supply tiers from plentiful to exhausted.
+var commodityTierOrder = []string{"Stable", "Tight", "Scarce", "Critical"}
+
+// CommodityTiers are pool levels at or below which a commodity enters each
+// tier; above Tight it is Stable.
+type CommodityTiers struct {
+	Tight    int `json:"tight"`
+	Scarce   int `json:"scarce"`
+	Critical int `json:"critical"`
+}
+
+// CommodityPrices is the base market price per unit in each tier.
+type CommodityPrices struct {
+	Stable   int `json:"stable"`
+	Tight    int `json:"tight"`
+	Scarce   int `json:"scarce"`
+	Critical int `json:"critical"`
+}
+
+type CommodityDefinition struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Unit is the plural a player trades in, e.g. "sacks" or "vials of
+	// medicine".
+	Unit string `json:"unit"`
+	// PoolPerUnit is how many pool points one traded unit is worth.
+	PoolPerUnit int `json:"pool_per_unit"`
+	// Tiers are set for a market stocked at ReferenceStock; a location's
+	// tiers scale with its own stock level.
+	ReferenceStock int             `json:"reference_stock"`
+	Tiers          CommodityTiers  `json:"tiers"`
+	Prices         CommodityPrices `json:"prices"`
+}
+
+// CommodityView is one row of a location's market.
+type CommodityView struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Unit         string `json:"unit"`
+	Tier         string `json:"tier"`
+	BasePrice    int    `json:"base_price"`
+	BuyPrice     int    `json:"buy_price"`
+	SellPrice    int    `json:"sell_price"`
+	Supply       int    `json:"supply"`
+	Held         int    `json:"held"`
+	MaxBuy       int    `json:"max_buy"`
+	MaxSell      int    `json:"max_sell"`
+	BuyDisabled  bool   `json:"buy_disabled"`
+	SellDisabled bool   `json:"sell_disabled"`
+	// Bids and Asks are the best price levels players have on the book.
+	Bids []OrderBookLevel `json:"bids"`
+	Asks []OrderBookLevel `json:"asks"`
+}
+
+// commodityCost is an amount of one commodity owed by a project or a crisis
+// response.
+type commodityCost struct {
+	ID     string
+	Amount int
+}
+
+func commodityDefinitions() []CommodityDefinition {
+	return currentContent().Commodities
+}
+
+func commodityDefinitionByID(id string) (CommodityDefinition, bool) {
+	for _, def := range commodityDefinitions() {
+		if def.ID == id {
+			return def, true
+		}
+	}
+	return CommodityDefinition{}, false
+}
+
+// grainCommodity is always present; content validation requires it.
+func grainCommodity() CommodityDefinition {
+	def, _ := commodityDefinitionByID(commodityGrain)
+	return def
+}
+
+func commodityTier(def CommodityDefinition, supply int) string {
+	switch {
+	case supply > def.Tiers.Tight:
+		return "Stable"
+	case supply > def.Tiers.Scarce:
+		return "Tight"
+	case supply > def.Tiers.Critical:
+		return "Scarce"
+	default:
+		return "Critical"
+	}
+}
+
+func commodityBasePrice(def CommodityDefinition, tier string) int {
+	switch tier {
+	case "Tight":
+		return def.Prices.Tight
+	case "Scarce":
+		return def.Prices.Scarce
+	case "Critical":
+		return def.Prices.Critical
+	default:
+		return def.Prices.Stable
+	}
+}
+
+// commodityUnit names amounts of id, falling back to the ID for commodities
+// a content reload has since removed.
+func commodityUnit(id string) string {
+	if def, ok := commodityDefinitionByID(id); ok {
+		return def.Unit
+	}
+	return id
+}
+
+func holdingOf(p *Player, id string) int {
+	if id == commodityGrain {
+		return p.Grain
+	}
+	return p.Inventory[id]
+}
+
+func addHolding(p *Player, id string, delta int) {
+	if id == commodityGrain {
+		p.Grain += delta
+		return
+	}
+	if p.Inventory == nil {
+		p.Inventory = map[string]int{}
+	}
+	p.Inventory[id] += delta
+	if p.Inventory[id] == 0 {
+		delete(p.Inventory, id)
+	}
+}
+
+func insufficientCodeFor(id string) string {
+	if id == commodityGrain {
+		return errCodeInsufficientGrain
+	}
+	return errCodeInsufficientGoods
+}
+
+// commodityCostList merges a grain cost with costs named by commodity, in
+// registry order, then any commodity content no longer defines.
+func commodityCostList(grain int, named map[string]int) []commodityCost {
+	amounts := map[string]int{}
+	if grain > 0 {
+		amounts[commodityGrain] = grain
+	}
+	for id, n := range named {
+		if n > 0 {
+			amounts[id] += n
+		}
+	}
+	out := make([]commodityCost, 0, len(amounts))
+	for _, def := range commodityDefinitions() {
+		if n := amounts[def.ID]; n > 0 {
+			out = append(out, commodityCost{ID: def.ID, Amount: n})
+			delete(amounts, def.ID)
+		}
+	}
+	for _, id := range sortedKeys(amounts) {
+		out = append(out, commodityCost{ID: id, Amount: amounts[id]})
+	}
+	return out
+}
+
+// costShortfall returns the error code and the "Need ..." clause for the
+// first cost p cannot cover, or empty strings when p can pay everything.
+func costShortfall(p *Player, gold int, costs []commodityCost) (code, need string) {
+	if p.Gold < gold {
+		return errCodeInsufficientGold, fmt.Sprintf("Need %dg", gold)
+	}
+	for _, c := range costs {
+		if holdingOf(p, c.ID) < c.Amount {
+			return insufficientCodeFor(c.ID), fmt.Sprintf("Need %d %s", c.Amount, commodityUnit(c.ID))
+		}
+	}
+	return "", ""
+}
+
+func payCosts(p *Player, gold int, costs []commodityCost) {
+	p.Gold -= gold
+	for _, c := range costs {
+		addHolding(p, c.ID, -c.Amount)
+	}
+}
+
+// costLabel lists gold and commodity costs, e.g. "4g · 2 sacks", or "" when
+// there are none.
+func costLabel(gold int, costs []commodityCost) string {
+	parts := []string{}
+	if gold > 0 {
+		parts = append(parts, fmt.Sprintf("%dg", gold))
+	}
+	for _, c := range costs {
+		parts = append(parts, fmt.Sprintf("%d %s", c.Amount, commodityUnit(c.ID)))
+	}
+	return strings.Join(parts, " · ")
+}
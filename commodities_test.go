@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommodityMarketTradesAnyCommodity(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	p := &Player{ID: "p1", Name: "Ash Crow (Guest)", Gold: 40, LastSeen: now}
+	s.Players[p.ID] = p
+	timber, ok := commodityDefinitionByID("timber")
+	good, traded := localGood(locationCapital, "timber")
+	if !ok || !traded {
+		t.Fatalf("expected timber in the capital market")
+	}
+	supply := marketSupplyLocked(s, locationCapital, good)
+	_, buy, sell := marketPricesLocked(s, locationCapital, timber, good)
+
+	handleActionInputLocked(s, p, now, ActionInput{Action: "buy", Commodity: "timber", Amount: 3})
+	if holdingOf(p, "timber") != 3 || p.Gold != 40-3*buy {
+		t.Fatalf("buy timber: holding %d gold %d", holdingOf(p, "timber"), p.Gold)
+	}
+	if got := marketSupplyLocked(s, locationCapital, good); got != supply-3*timber.PoolPerUnit {
+		t.Fatalf("timber pool = %d, want %d", got, supply-3*timber.PoolPerUnit)
+	}
+	if p.Grain != 0 {
+		t.Fatalf("buying timber must not touch grain, got %d", p.Grain)
+	}
+
+	handleActionInputLocked(s, p, now, ActionInput{Action: "sell", Commodity: "timber", Amount: 1})
+	if holdingOf(p, "timber") != 2 || p.Gold != 40-3*buy+sell {
+		t.Fatalf("sell timber: holding %d gold %d", holdingOf(p, "timber"), p.Gold)
+	}
+
+	handleActionInputLocked(s, p, now, ActionInput{Action: "sell", Commodity: "timber", Amount: 5})
+	if s.rejections[p.ID] != errCodeInsufficientGoods {
+		t.Fatalf("overselling should be refused with insufficient_goods, got %q", s.rejections[p.ID])
+	}
+	handleActionInputLocked(s, p, now, ActionInput{Action: "buy", Commodity: "dragonbone", Amount: 1})
+	if s.rejections[p.ID] != errCodeNotFound {
+		t.Fatalf("unknown commodity should be refused, got %q", s.rejections[p.ID])
+	}
+	handleActionInputLocked(s, p, now, ActionInput{Action: "buy", Amount: 1})
+	if p.Grain != 1 {
+		t.Fatalf("a buy without a commodity should mean grain, got %d sacks", p.Grain)
+	}
+
+	data := buildPageDataLocked(s, p.ID, false)
+	if len(data.Commodities) != len(commodityDefinitions()) || data.Commodities[0].ID != commodityGrain {
+		t.Fatalf("market should list every commodity, grain first: %+v", data.Commodities)
+	}
+	p.Inventory["timber"] = 99
+	if data.Player.Inventory["timber"] != 2 {
+		t.Fatalf("page data should not alias the live inventory")
+	}
+}
+
+func TestCrisisAndProjectCostsNameCommodities(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	p := &Player{ID: "p1", Name: "Ash Crow (Guest)", Gold: 50, LastSeen: now}
+	s.Players[p.ID] = p
+	def, ok := crisisDefinitionByType("collapse")
+	if !ok || def.Costs["timber"] == 0 {
+		t.Fatalf("expected the collapse response to cost timber: %+v", def)
+	}
+	startCrisisLocked(s, def, now)
+
+	if got := buildPageDataLocked(s, p.ID, false).Crisis; !got.ResponseDisabled || !strings.Contains(got.ResponseCost, "logs of timber") {
+		t.Fatalf("crisis view should price and require timber: %+v", got)
+	}
+	handleActionInputLocked(s, p, now, ActionInput{Action: "respond_crisis"})
+	if s.rejections[p.ID] != errCodeInsufficientGoods || s.ActiveCrisis.Mitigated {
+		t.Fatalf("response without timber should be refused, got %q", s.rejections[p.ID])
+	}
+
+	addHolding(p, "timber", def.Costs["timber"])
+	handleActionInputLocked(s, p, now, ActionInput{Action: "respond_crisis"})
+	if s.ActiveCrisis != nil && !s.ActiveCrisis.Mitigated {
+		t.Fatalf("response with timber should go through")
+	}
+	if holdingOf(p, "timber") != 0 || p.Gold != 50-def.GoldCost {
+		t.Fatalf("response should spend gold and timber: gold %d timber %d", p.Gold, holdingOf(p, "timber"))
+	}
+
+	costs := commodityCostList(2, map[string]int{"salt": 1, "grain": 1})
+	if label := costLabel(3, costs); label != "3g · 3 sacks · 1 crates of salt" {
+		t.Fatalf("cost label = %q", label)
+	}
+}
+
+func TestCommodityContentIsValidated(t *testing.T) {
+	dir := writeContentDir(t, map[string]func(string) string{
+		contentCommoditiesFile: func(s string) string {
+			s = strings.Replace(s, `"id": "grain"`, `"id": "barley"`, 1)
+			return strings.Replace(s, `"tiers": {"tight": 100, "scarce": 50, "critical": 20}`, `"tiers": {"tight": 10, "scarce": 50, "critical": 20}`, 1)
+		},
+		contentLocationsFile: func(s string) string {
+			s = strings.Replace(s, `"commodity": "salt", "stock": 150`, `"commodity": "saltpeter", "stock": 150`, 1)
+			return strings.Replace(s, `"jurisdiction": "local",
+        "tax_pct": 5`, `"jurisdiction": "guild",
+        "tax_pct": 5`, 1)
+		},
+		contentProjectsFile: func(s string) string {
+			return strings.Replace(s, `"cost_grain": 1,`, `"cost_grain": 1, "costs": {"dragonbone": 2},`, 1)
+		},
+	})
+	_, err := loadGameContent(os.DirFS(dir), dir)
+	if err == nil {
+		t.Fatalf("expected validation errors")
+	}
+	for _, want := range []string{
+		`commodities.json: commodities: required commodity "grain" is missing`,
+		`commodities.json: commodities[1] (timber): tiers must fall`,
+		`projects.json: projects[3] (ward_lanterns): costs name unknown commodity "dragonbone"`,
+		`locations.json: locations[1] (harbor): market trades unknown commodity "saltpeter"`,
+		`locations.json: locations[1] (harbor): market jurisdiction "guild"`,
+		`locations.json: locations[0] (capital): market trades unknown commodity "grain"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("missing %q in:\n%v", want, err)
+		}
+	}
+}
+
+func TestAPIMarketReportsCommodityDeltas(t *testing.T) {
+	s := newTestStore()
+	mux := newMux(s, parseTemplates())
+	doReq(t, mux, http.MethodGet, "/", nil, "bot", "")
+	var issued apiTokenResponse
+	_ = json.Unmarshal(doReq(t, mux, http.MethodPost, "/api/v1/token", nil, "bot", "").Body.Bytes(), &issued)
+	token := issued.Token
+
+	rr := doAPIReq(t, mux, http.MethodGet, "/api/v1/views/market", token, "")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"id":"holy_silver"`) {
+		t.Fatalf("market view should list commodities: %d %s", rr.Code, rr.Body.String())
+	}
+	rr = doAPIReq(t, mux, http.MethodPost, "/api/v1/actions", token, `{"action":"buy","commodity":"salt","amount":2}`)
+	res := decodeAPIResult(t, rr)
+	if !res.OK || res.Delta.Inventory["salt"].To != float64(2) || res.Delta.Supplies["salt"].From == nil {
+		t.Fatalf("buy salt: %d %s", rr.Code, rr.Body.String())
+	}
+}
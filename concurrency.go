@@ -0,0 +1,457 @@
+package main
+
+import (
+	"maps"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Concurrency model: every mutation runs as a command on the store's writer
+// goroutine, which takes store.mu for a batch of queued commands. Before
+// releasing it the writer copies the world into a read-only view and
+// publishes it; readers build their PageData (or another plain value) from
+// the latest view without taking any lock, so a long tick never stalls a
+// GET. The save for the batch is taken from the same view and runs after
+// the lock is gone. Polling readers record presence in a side table instead
+// of writing LastSeen, so a GET never needs the writer. A view copies only
+// what its batch marked dirty and shares the rest with the view before it;
+// see dirty.go.
+
+const (
+	// writeQueueDepth is how many commands may wait for the writer before
+	// submitters block.
+	writeQueueDepth = 256
+	// writeBatchMax caps how many queued commands share one lock hold and
+	// one save.
+	writeBatchMax = 64
+)
+
+type writeCommand struct {
+	fn func()
+	// tracked commands mark what they change; others mark everything.
+	tracked bool
+	// done receives whatever fn panicked with, or nil.
+	done chan any
+}
+
+type storeWriter struct {
+	start sync.Once
+	// mu is held for reading while submitting and for writing by close, so
+	// nothing is sent on a closed queue.
+	mu      sync.RWMutex
+	closed  bool
+	queue   chan writeCommand
+	stopped chan struct{}
+}
+
+// presenceTable holds LastSeen bumps from read-only requests until the
+// writer folds them into the players.
+type presenceTable struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// write runs fn on the writer goroutine with the write lock held and returns
+// once fn has run and its changes are saved. fn must not call write itself.
+// A panic in fn is re-raised in the caller so it surfaces in the request that
+// caused it rather than killing the writer.
+//
+// Once the writer is closed, fn runs on the caller under the write lock and
+// is saved before write returns.
+//
+// Everything fn could have touched is copied and compared afterwards, which
+// suits ticks and admin commands. Request handlers use writeTracked.
+func (s *Store) write(fn func()) {
+	s.submit(writeCommand{fn: fn})
+}
+
+// writeTracked is write for fn that marks every change it makes, so the
+// batch's view and save cover only those.
+func (s *Store) writeTracked(fn func()) {
+	s.submit(writeCommand{fn: fn, tracked: true})
+}
+
+func (s *Store) submit(cmd writeCommand) {
+	s.writer.mu.RLock()
+	if s.writer.closed {
+		s.writer.mu.RUnlock()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		mergePresenceLocked(s)
+		runWriteCommand(s, cmd)
+		publishViewLocked(s)
+		s.persistLocked()
+		return
+	}
+	s.writer.start.Do(func() {
+		s.writer.queue = make(chan writeCommand, writeQueueDepth)
+		s.writer.stopped = make(chan struct{})
+		go s.runWriter()
+	})
+	cmd.done = make(chan any, 1)
+	s.writer.queue <- cmd
+	s.writer.mu.RUnlock()
+	if p := <-cmd.done; p != nil {
+		panic(p)
+	}
+}
+
+// closeWriter stops the writer after every queued command has run and been
+// saved.
+func (s *Store) closeWriter() {
+	s.writer.mu.Lock()
+	if s.writer.closed {
+		s.writer.mu.Unlock()
+		return
+	}
+	s.writer.closed = true
+	queue, stopped := s.writer.queue, s.writer.stopped
+	s.writer.mu.Unlock()
+	if queue != nil {
+		close(queue)
+		<-stopped
+	}
+}
+
+func (s *Store) runWriter() {
+	defer close(s.writer.stopped)
+	for cmd := range s.writer.queue {
+		batch := []writeCommand{cmd}
+	drain:
+		for len(batch) < writeBatchMax {
+			select {
+			case next := <-s.writer.queue:
+				batch = append(batch, next)
+			default:
+				break drain
+			}
+		}
+
+		results := make([]any, len(batch))
+		s.mu.Lock()
+		markPokedLocked(s)
+		mergePresenceLocked(s)
+		for i, c := range batch {
+			results[i] = runWriteCommand(s, c)
+		}
+		commit := s.beginSaveLocked(publishViewLocked(s))
+		s.mu.Unlock()
+		commit()
+
+		for i, c := range batch {
+			c.done <- results[i]
+		}
+	}
+}
+
+// runWriteCommand runs cmd and returns whatever it panicked with. A command
+// that panics may have changed anything.
+func runWriteCommand(s *Store, cmd writeCommand) (recovered any) {
+	defer func() {
+		if recovered = recover(); recovered != nil {
+			s.dirty.markAll()
+		}
+	}()
+	if !cmd.tracked {
+		s.dirty.markAll()
+		cmd.fn()
+		return nil
+	}
+	runMarkedLocked(s, cmd.fn)
+	return nil
+}
+
+// flush waits until every change made so far is saved.
+func (s *Store) flush() {
+	s.write(func() {})
+}
+
+// touch records that playerID was seen at now without taking the store lock.
+func (s *Store) touch(playerID string, now time.Time) {
+	s.presence.mu.Lock()
+	defer s.presence.mu.Unlock()
+	if s.presence.seen == nil {
+		s.presence.seen = map[string]time.Time{}
+	}
+	if now.After(s.presence.seen[playerID]) {
+		s.presence.seen[playerID] = now
+	}
+}
+
+// mergePresenceLocked folds presence into LastSeen. A player is only marked
+// dirty, and so copied and saved, when their LastSeen moves across a
+// lastSeenPersistGranularity boundary; until then readers get the finer
+// time from the presence entry, which publishViewLocked keeps.
+func mergePresenceLocked(s *Store) {
+	s.presence.mu.Lock()
+	defer s.presence.mu.Unlock()
+	for id, at := range s.presence.seen {
+		p := s.Players[id]
+		if p == nil {
+			delete(s.presence.seen, id)
+			continue
+		}
+		if !at.After(p.LastSeen) {
+			continue
+		}
+		if !at.Truncate(lastSeenPersistGranularity).Equal(p.LastSeen.Truncate(lastSeenPersistGranularity)) {
+			s.dirty.mark(dirtyPlayers, id)
+		}
+		p.LastSeen = at
+	}
+}
+
+// prunePresence drops presence entries the view's players already show.
+func prunePresence(v *Store) {
+	v.presence.mu.Lock()
+	defer v.presence.mu.Unlock()
+	for id, at := range v.presence.seen {
+		if p := v.Players[id]; p == nil || !at.After(p.LastSeen) {
+			delete(v.presence.seen, id)
+		}
+	}
+}
+
+// lastSeenLocked is p.LastSeen including presence not yet merged. Callers
+// reading a view use it to decide who is online.
+func lastSeenLocked(s *Store, p *Player) time.Time {
+	s.presence.mu.Lock()
+	at := s.presence.seen[p.ID]
+	s.presence.mu.Unlock()
+	if at.After(p.LastSeen) {
+		return at
+	}
+	return p.LastSeen
+}
+
+// readablePlayerLocked returns the cookie's player when serving them needs no
+// write: the cookie is valid and the player exists and is active. Anything
+// else goes through ensurePlayerLocked on the writer.
+func readablePlayerLocked(store *Store, r *http.Request) *Player {
+	c, err := r.Cookie(cookieName)
+	if err != nil {
+		return nil
+	}
+	pid, ok := parsePlayerCookieValue(c.Value)
+	if !ok {
+		return nil
+	}
+	p := store.Players[pid]
+	if p == nil || !p.SoftDeletedAt.IsZero() || !p.HardDeletedAt.IsZero() || p.LocationID == "" {
+		return nil
+	}
+	return p
+}
+
+// readPageData builds the page for a GET. Known players are served from the
+// read view; a request that has to create or revive a player is sent to the
+// writer.
+func readPageData(store *Store, w http.ResponseWriter, r *http.Request) PageData {
+	now := time.Now().UTC()
+	view := store.readView()
+	if p := readablePlayerLocked(view, r); p != nil {
+		store.touch(p.ID, now)
+		return buildPageDataLocked(view, p.ID, false)
+	}
+
+	var data PageData
+	store.writeTracked(func() {
+		p := ensurePlayerLocked(store, w, r)
+		p.LastSeen = now
+		data = buildPageDataLocked(store, p.ID, false)
+	})
+	return data
+}
+
+// readView returns the world as of the writer's last batch. It is shared by
+// every reader and must never be written. Before the writer has published
+// one, a private copy is taken under the read lock.
+func (s *Store) readView() *Store {
+	if v := s.view.Load(); v != nil {
+		return v
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return viewOfLocked(s)
+}
+
+// publishViewLocked replaces the readers' view with a copy of the world as
+// it stands now and returns it. Only what the batch marked is copied; the
+// rest is shared with the previous view.
+func publishViewLocked(s *Store) *Store {
+	changes := s.dirty.take()
+	var v *Store
+	if prev := s.view.Load(); prev == nil || changes.all {
+		v = viewOfLocked(s)
+	} else {
+		v = nextViewLocked(prev, s, changes)
+	}
+	v.changes = changes
+	prunePresence(v)
+	notifyChangesLocked(s.push, s.view.Load(), v)
+	s.view.Store(v)
+	return v
+}
+
+// nextViewLocked builds the view after prev by copying the map entries and
+// slices in changes, and every other field, which are small, from s.
+// Everything else is shared with prev; neither view is ever written.
+func nextViewLocked(prev, s *Store, changes *dirtySet) *Store {
+	v := &Store{presence: s.presence, rng: s.rng.clone()}
+	src, old, dst := reflect.ValueOf(s).Elem(), reflect.ValueOf(prev).Elem(), reflect.ValueOf(v).Elem()
+	for i := 0; i < src.NumField(); i++ {
+		f := src.Type().Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		kind := f.Type.Kind()
+		if kind != reflect.Map && kind != reflect.Slice {
+			dst.Field(i).Set(deepCopy(src.Field(i)))
+			continue
+		}
+		keys, whole := changes.field(f.Name)
+		switch {
+		case whole || old.Field(i).IsNil() && !src.Field(i).IsNil():
+			dst.Field(i).Set(deepCopy(src.Field(i)))
+		case keys == nil || kind == reflect.Slice:
+			dst.Field(i).Set(old.Field(i))
+		default:
+			m := reflect.MakeMapWithSize(f.Type, old.Field(i).Len())
+			for it := old.Field(i).MapRange(); it.Next(); {
+				m.SetMapIndex(it.Key(), it.Value())
+			}
+			for key := range keys {
+				k := reflect.ValueOf(key)
+				if val := src.Field(i).MapIndex(k); val.IsValid() {
+					m.SetMapIndex(k, deepCopy(val))
+				} else {
+					m.SetMapIndex(k, reflect.Value{})
+				}
+			}
+			dst.Field(i).Set(m)
+		}
+	}
+	switch {
+	case changes.has(dirtyAPITokens, nil) && s.apiTokens != nil:
+		v.apiTokens = maps.Clone(s.apiTokens)
+	case changes.has(dirtyAPITokens, nil):
+		v.apiTokens = apiTokenIndex(v.Players)
+	default:
+		v.apiTokens = prev.apiTokens
+	}
+	return v
+}
+
+// viewOfLocked deep-copies every exported field of s, which is all of the
+// game state, into a repo-less Store. The presence table is shared so a view
+// still sees who is online, and the view gets its own API token index since
+// readers may not build one.
+func viewOfLocked(s *Store) *Store {
+	v := &Store{presence: s.presence, rng: s.rng.clone()}
+	src, dst := reflect.ValueOf(s).Elem(), reflect.ValueOf(v).Elem()
+	for i := 0; i < src.NumField(); i++ {
+		if src.Type().Field(i).IsExported() {
+			dst.Field(i).Set(deepCopy(src.Field(i)))
+		}
+	}
+	if s.apiTokens != nil {
+		v.apiTokens = maps.Clone(s.apiTokens)
+	} else {
+		v.apiTokens = apiTokenIndex(v.Players)
+	}
+	return v
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// flatTypes caches which types hold no maps, slices or pointers and so are
+// copied whole by assignment. time.Time counts as flat: its location is
+// never changed in place.
+var flatTypes sync.Map
+
+func isFlat(t reflect.Type) bool {
+	if t == timeType {
+		return true
+	}
+	if flat, ok := flatTypes.Load(t); ok {
+		return flat.(bool)
+	}
+	flat := true
+	switch t.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Pointer, reflect.Interface, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		flat = false
+	case reflect.Array:
+		flat = isFlat(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField() && flat; i++ {
+			flat = isFlat(t.Field(i).Type)
+		}
+	}
+	flatTypes.Store(t, flat)
+	return flat
+}
+
+// deepCopy copies v so that nothing reachable through exported fields is
+// shared with the original.
+func deepCopy(v reflect.Value) reflect.Value {
+	t := v.Type()
+	if isFlat(t) {
+		return v
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(t.Elem())
+		c.Elem().Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(t).Elem()
+		c.Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(t, v.Len())
+		for it := v.MapRange(); it.Next(); {
+			c.SetMapIndex(it.Key(), deepCopy(it.Value()))
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(t, v.Len(), v.Len())
+		if isFlat(t.Elem()) {
+			reflect.Copy(c, v)
+			return c
+		}
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(t).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(t).Elem()
+		c.Set(v)
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.IsExported() && !isFlat(f.Type) {
+				c.Field(i).Set(deepCopy(v.Field(i)))
+			}
+		}
+		return c
+	}
+	return v
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestConcurrentClientsAreRaceFree drives a few hundred simulated clients
+// through page loads, polling, actions, chat and the API while the world
+// ticks, against a SQLite-backed store. Run it with -race.
+func TestConcurrentClientsAreRaceFree(t *testing.T) {
+	t.Setenv("DB_DIALECT", "sqlite")
+	t.Setenv("DB_SQLITE_PATH", filepath.Join(t.TempDir(), "concurrency.sqlite"))
+	s, err := newConfiguredStore()
+	if err != nil {
+		t.Fatalf("newConfiguredStore error: %v", err)
+	}
+	defer s.repo.db.Close()
+	mux := newMux(s, parseTemplates())
+
+	const clients = 200
+	const rounds = 3
+	frags := []string{"/frag/events", "/frag/chat", "/frag/market", "/frag/ledger", "/frag/intel", "/frag/dashboard"}
+
+	var failures atomic.Int64
+	var mu sync.Mutex
+	var firstFailure string
+	fail := func(format string, args ...any) {
+		if failures.Add(1) == 1 {
+			mu.Lock()
+			firstFailure = fmt.Sprintf(format, args...)
+			mu.Unlock()
+		}
+	}
+
+	const ticks = 20
+	ticked := make(chan struct{})
+	go func() {
+		defer close(ticked)
+		for n := 0; n < ticks; n++ {
+			time.Sleep(10 * time.Millisecond)
+			s.write(func() { advanceWorldLocked(s, time.Now().UTC(), false) })
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pid := fmt.Sprintf("client-%03d", i)
+			// A full page is the most expensive render, so only some clients
+			// start there; the rest join on their first request.
+			if i%10 == 0 {
+				if rr := doReq(t, mux, http.MethodGet, "/", nil, pid, ""); rr.Code != http.StatusOK {
+					fail("GET / for %s: %d", pid, rr.Code)
+					return
+				}
+			}
+			// Most clients play through the API, which skips the HTML
+			// rendering that dominates the run time.
+			token := ""
+			if i%4 != 0 {
+				rr := doReq(t, mux, http.MethodPost, "/api/v1/token", nil, pid, "")
+				var issued apiTokenResponse
+				if err := json.Unmarshal(rr.Body.Bytes(), &issued); err != nil || rr.Code != http.StatusCreated {
+					fail("token for %s: %d %s", pid, rr.Code, rr.Body.String())
+					return
+				}
+				token = issued.Token
+			}
+			for round := 0; round < rounds; round++ {
+				path := frags[(i+round)%len(frags)]
+				if rr := doReq(t, mux, http.MethodGet, path, nil, pid, ""); rr.Code != http.StatusOK {
+					fail("GET %s for %s: %d", path, pid, rr.Code)
+				}
+				if token != "" {
+					if rr := doAPIReq(t, mux, http.MethodGet, "/api/v1/views/market", token, ""); rr.Code != http.StatusOK {
+						fail("API view for %s: %d", pid, rr.Code)
+					}
+					body := `{"action":"buy_grain","amount":1}`
+					if round%2 == 1 {
+						body = `{"action":"investigate"}`
+					}
+					if rr := doAPIReq(t, mux, http.MethodPost, "/api/v1/actions", token, body); rr.Code == http.StatusUnauthorized {
+						fail("API action for %s unauthorized", pid)
+					}
+					continue
+				}
+				switch round % 3 {
+				case 0:
+					doReq(t, mux, http.MethodPost, "/chat", url.Values{"text": {fmt.Sprintf("%s round %d", pid, round)}}, pid, "")
+				case 1:
+					doReq(t, mux, http.MethodPost, "/action", url.Values{"action": {"buy_grain"}, "amount": {"1"}}, pid, "")
+				case 2:
+					doReq(t, mux, http.MethodPost, "/action", url.Values{"action": {"investigate"}}, pid, "")
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	<-ticked
+
+	if n := failures.Load(); n > 0 {
+		t.Fatalf("%d failed requests, first: %s", n, firstFailure)
+	}
+
+	s.flush()
+	s.mu.RLock()
+	players, total, chat, tickCount := 0, len(s.Players), len(s.Chat), s.TickCount
+	for _, p := range s.Players {
+		if p.NPCRole == "" {
+			players++
+		}
+	}
+	s.mu.RUnlock()
+	if players != clients {
+		t.Fatalf("players = %d, want %d", players, clients)
+	}
+	if chat == 0 {
+		t.Fatalf("expected chat lines from the clients")
+	}
+	if tickCount < ticks {
+		t.Fatalf("tick count %d is behind the %d ticks submitted", tickCount, ticks)
+	}
+
+	loaded := newStore()
+	if err := s.repo.LoadInto(t.Context(), loaded); err != nil {
+		t.Fatalf("LoadInto error: %v", err)
+	}
+	if len(loaded.Players) != total || loaded.TickCount != tickCount {
+		t.Fatalf("saved state lags memory: %d players at tick %d, want %d at %d", len(loaded.Players), loaded.TickCount, total, tickCount)
+	}
+}
+
+func TestWriterBatchesAndReportsPanics(t *testing.T) {
+	s := newTestStore()
+	s.Players["p1"] = &Player{ID: "p1", Name: "Ash Crow", LocationID: locationCapital}
+
+	seen := time.Now().UTC().Add(time.Minute)
+	s.touch("p1", seen)
+	s.mu.RLock()
+	if got := lastSeenLocked(s, s.Players["p1"]); !got.Equal(seen) {
+		s.mu.RUnlock()
+		t.Fatalf("read-side presence should be visible before the merge, got %v", got)
+	}
+	s.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.write(func() { s.Players["p1"].Gold++ })
+		}()
+	}
+	wg.Wait()
+	if got := s.Players["p1"].Gold; got != 50 {
+		t.Fatalf("gold = %d, want 50", got)
+	}
+	if got := s.Players["p1"].LastSeen; !got.Equal(seen) {
+		t.Fatalf("writer should merge presence into LastSeen, got %v", got)
+	}
+
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "boom") {
+			t.Fatalf("panic should reach the caller, got %v", r)
+		}
+		s.write(func() { s.Players["p1"].Gold = 0 })
+		if s.Players["p1"].Gold != 0 {
+			t.Fatalf("writer should keep running after a panic")
+		}
+	}()
+	s.write(func() { panic("boom") })
+}
+
+func TestReadsDoNotWaitForTheWriter(t *testing.T) {
+	s := newTestStore()
+	mux := newMux(s, parseTemplates())
+	doReq(t, mux, http.MethodGet, "/", nil, "p1", "")
+
+	started, release := make(chan struct{}), make(chan struct{})
+	go s.write(func() {
+		s.Players["p1"].Gold = 999
+		close(started)
+		<-release
+	})
+	<-started
+	done := make(chan string)
+	go func() {
+		done <- doReq(t, mux, http.MethodGet, "/frag/dashboard", nil, "p1", "").Body.String()
+	}()
+	select {
+	case body := <-done:
+		if strings.Contains(body, "999") {
+			t.Fatalf("a read during a batch should see the last published view")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("a GET waited for the writer's batch")
+	}
+	close(release)
+
+	s.flush()
+	if body := doReq(t, mux, http.MethodGet, "/frag/dashboard", nil, "p1", "").Body.String(); !strings.Contains(body, "999") {
+		t.Fatalf("the next view should carry the batch's changes")
+	}
+}
@@ -0,0 +1,539 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// contentDirEnvName points at a directory of content JSON files. When unset
+// the copies embedded in the binary are used.
+const contentDirEnvName = "CONTENT_DIR"
+
+const (
+	contentCrisesFile      = "crises.json"
+	contentProjectsFile    = "projects.json"
+	contentRelicsFile      = "relics.json"
+	contentLocationsFile   = "locations.json"
+	contentSeatsFile       = "seats.json"
+	contentCommoditiesFile = "commodities.json"
+	contentBuildingsFile   = "buildings.json"
+)
+
+//go:embed content/*.json
+var embeddedContentFS embed.FS
+
+// Locations and seats the game logic refers to by ID; content may add to
+// them but not drop them.
+var (
+	requiredLocationIDs = []string{locationCapital, locationHarbor, locationFrontier, locationRuins}
+	knownSeatIDs        = []string{"harbor_master", "master_of_coin", "watch_commander", "high_curate", "magistrate"}
+	knownRelicEffects   = []string{"heat", "rep", "gold", "rumor", "grain"}
+	knownInstitutionIDs = []string{"city_authority", "merchant_league", "temple"}
+)
+
+type TravelRoute struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Ticks int    `json:"ticks"`
+}
+
+// GameContent is one validated set of definitions. It is never mutated once
+// active; a reload swaps in a new value.
+type GameContent struct {
+	Crises            []CrisisDefinition
+	Projects          []ProjectDefinition
+	Relics            []RelicDefinition
+	Commodities       []CommodityDefinition
+	Buildings         []BuildingDefinition
+	Locations         []LocationDef
+	Routes            []TravelRoute
+	DefaultRouteTicks int
+	SeatHolders       map[string]string
+	FallbackHolder    string
+
+	Source   string
+	LoadedAt time.Time
+
+	travel map[string]int
+}
+
+type crisesFile struct {
+	Crises []CrisisDefinition `json:"crises"`
+}
+
+type projectsFile struct {
+	Projects []ProjectDefinition `json:"projects"`
+}
+
+type relicsFile struct {
+	Relics []RelicDefinition `json:"relics"`
+}
+
+type commoditiesFile struct {
+	Commodities []CommodityDefinition `json:"commodities"`
+}
+
+type buildingsFile struct {
+	Buildings []BuildingDefinition `json:"buildings"`
+}
+
+type locationsFile struct {
+	Locations         []LocationDef `json:"locations"`
+	Routes            []TravelRoute `json:"routes"`
+	DefaultRouteTicks int           `json:"default_route_ticks"`
+}
+
+type seatsFile struct {
+	DefaultHolders map[string]string `json:"default_holders"`
+	FallbackHolder string            `json:"fallback_holder"`
+}
+
+var (
+	activeContent       atomic.Pointer[GameContent]
+	embeddedContentOnce sync.Once
+	embeddedContent     *GameContent
+)
+
+// currentContent returns the active definitions, falling back to the
+// embedded set until loadContentFromEnv or a reload installs another.
+func currentContent() *GameContent {
+	if c := activeContent.Load(); c != nil {
+		return c
+	}
+	embeddedContentOnce.Do(func() {
+		c, err := loadGameContent(embeddedContentSubFS(), "embedded")
+		if err != nil {
+			panic(fmt.Sprintf("embedded content is invalid: %v", err))
+		}
+		embeddedContent = c
+	})
+	activeContent.CompareAndSwap(nil, embeddedContent)
+	return activeContent.Load()
+}
+
+func embeddedContentSubFS() fs.FS {
+	sub, err := fs.Sub(embeddedContentFS, "content")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// contentSourceFromEnv returns the filesystem and label to load content
+// from.
+func contentSourceFromEnv() (fs.FS, string) {
+	dir := strings.TrimSpace(os.Getenv(contentDirEnvName))
+	if dir == "" {
+		return embeddedContentSubFS(), "embedded"
+	}
+	return os.DirFS(dir), dir
+}
+
+// loadContentFromEnv validates and installs content at startup so a bad
+// file stops the server instead of surfacing mid-game.
+func loadContentFromEnv() error {
+	fsys, source := contentSourceFromEnv()
+	c, err := loadGameContent(fsys, source)
+	if err != nil {
+		return err
+	}
+	activeContent.Store(c)
+	return nil
+}
+
+// reloadContent re-reads the content source. On error the active content is
+// left untouched. In-flight crises and projects carry their own copy of the
+// definition they started with, and relics copy their effect on discovery,
+// so a reload only changes what happens next.
+func reloadContent() (*GameContent, error) {
+	fsys, source := contentSourceFromEnv()
+	c, err := loadGameContent(fsys, source)
+	if err != nil {
+		return nil, err
+	}
+	activeContent.Store(c)
+	return c, nil
+}
+
+func loadGameContent(fsys fs.FS, source string) (*GameContent, error) {
+	var (
+		crises    crisesFile
+		projects  projectsFile
+		relics    relicsFile
+		locations locationsFile
+		seats     seatsFile
+		goods     commoditiesFile
+		buildings buildingsFile
+	)
+	var errs []error
+	for _, f := range []struct {
+		name string
+		dst  any
+	}{
+		{contentCrisesFile, &crises},
+		{contentProjectsFile, &projects},
+		{contentRelicsFile, &relics},
+		{contentLocationsFile, &locations},
+		{contentSeatsFile, &seats},
+		{contentCommoditiesFile, &goods},
+		{contentBuildingsFile, &buildings},
+	} {
+		if err := decodeContentFile(fsys, f.name, f.dst); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("load content from %s: %w", source, errors.Join(errs...))
+	}
+
+	c := &GameContent{
+		Crises:            crises.Crises,
+		Projects:          projects.Projects,
+		Relics:            relics.Relics,
+		Commodities:       goods.Commodities,
+		Buildings:         buildings.Buildings,
+		Locations:         locations.Locations,
+		Routes:            locations.Routes,
+		DefaultRouteTicks: locations.DefaultRouteTicks,
+		SeatHolders:       seats.DefaultHolders,
+		FallbackHolder:    seats.FallbackHolder,
+		Source:            source,
+		LoadedAt:          time.Now().UTC(),
+	}
+	if err := validateGameContent(c); err != nil {
+		return nil, fmt.Errorf("invalid content in %s: %w", source, err)
+	}
+	c.indexRoutes()
+	return c, nil
+}
+
+// indexRoutes builds the travel lookup, which is not serialized, from
+// Routes.
+func (c *GameContent) indexRoutes() {
+	c.travel = map[string]int{}
+	for _, r := range c.Routes {
+		c.travel[r.From+":"+r.To] = r.Ticks
+		c.travel[r.To+":"+r.From] = r.Ticks
+	}
+}
+
+func decodeContentFile(fsys fs.FS, name string, dst any) error {
+	raw, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line := bytes.Count(raw[:syntaxErr.Offset], []byte("\n")) + 1
+			return fmt.Errorf("%s:%d: %w", name, line, err)
+		}
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// validateGameContent reports every problem at once, each prefixed with the
+// file and entry it came from.
+func validateGameContent(c *GameContent) error {
+	var errs []error
+	fail := func(file, entry, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s: %s", file, entry, fmt.Sprintf(format, args...)))
+	}
+
+	commodities := map[string]bool{}
+	for i, def := range c.Commodities {
+		entry := fmt.Sprintf("commodities[%d] (%s)", i, def.ID)
+		switch {
+		case def.ID == "":
+			fail(contentCommoditiesFile, entry, "id is required")
+		case commodities[def.ID]:
+			fail(contentCommoditiesFile, entry, "duplicate id")
+		}
+		commodities[def.ID] = true
+		if def.Name == "" || def.Unit == "" {
+			fail(contentCommoditiesFile, entry, "name and unit are required")
+		}
+		if def.PoolPerUnit <= 0 {
+			fail(contentCommoditiesFile, entry, "pool_per_unit must be positive")
+		}
+		if def.ReferenceStock <= 0 {
+			fail(contentCommoditiesFile, entry, "reference_stock must be positive")
+		}
+		if t := def.Tiers; !(t.Tight > t.Scarce && t.Scarce > t.Critical && t.Critical >= 0) {
+			fail(contentCommoditiesFile, entry, "tiers must fall from tight to scarce to critical")
+		}
+		if pr := def.Prices; pr.Stable <= 0 || pr.Tight < pr.Stable || pr.Scarce < pr.Tight || pr.Critical < pr.Scarce {
+			fail(contentCommoditiesFile, entry, "prices must be positive and rise from stable to critical")
+		}
+	}
+	if !commodities[commodityGrain] {
+		fail(contentCommoditiesFile, "commodities", "required commodity %q is missing", commodityGrain)
+	}
+	checkCosts := func(file, entry string, costs map[string]int) {
+		for _, id := range sortedKeys(costs) {
+			if !commodities[id] {
+				fail(file, entry, "costs name unknown commodity %q", id)
+			}
+			if costs[id] < 0 {
+				fail(file, entry, "cost of %q cannot be negative", id)
+			}
+		}
+	}
+
+	if len(c.Crises) == 0 {
+		fail(contentCrisesFile, "crises", "at least one crisis is required")
+	}
+	seen := map[string]bool{}
+	for i, def := range c.Crises {
+		entry := fmt.Sprintf("crises[%d] (%s)", i, def.Type)
+		switch {
+		case def.Type == "":
+			fail(contentCrisesFile, entry, "type is required")
+		case seen[def.Type]:
+			fail(contentCrisesFile, entry, "duplicate type")
+		}
+		seen[def.Type] = true
+		if def.Name == "" {
+			fail(contentCrisesFile, entry, "name is required")
+		}
+		if def.ResponseLabel == "" {
+			fail(contentCrisesFile, entry, "response_label is required")
+		}
+		if def.DurationTicks <= 0 {
+			fail(contentCrisesFile, entry, "duration_ticks must be positive")
+		}
+		if def.BaseSeverity <= 0 {
+			fail(contentCrisesFile, entry, "base_severity must be positive")
+		}
+		if def.GoldCost < 0 || def.GrainCost < 0 {
+			fail(contentCrisesFile, entry, "gold_cost and grain_cost cannot be negative")
+		}
+		checkCosts(contentCrisesFile, entry, def.Costs)
+		if def.BuildingDamage < 0 || def.BuildingDamage > 100 {
+			fail(contentCrisesFile, entry, "building_damage must be between 0 and 100")
+		}
+	}
+
+	seen = map[string]bool{}
+	for i, def := range c.Projects {
+		entry := fmt.Sprintf("projects[%d] (%s)", i, def.Type)
+		switch {
+		case def.Type == "":
+			fail(contentProjectsFile, entry, "type is required")
+		case seen[def.Type]:
+			fail(contentProjectsFile, entry, "duplicate type")
+		}
+		seen[def.Type] = true
+		if def.Name == "" {
+			fail(contentProjectsFile, entry, "name is required")
+		}
+		if def.DurationTicks <= 0 {
+			fail(contentProjectsFile, entry, "duration_ticks must be positive")
+		}
+		if def.CostGold < 0 || def.CostGrain < 0 {
+			fail(contentProjectsFile, entry, "cost_gold and cost_grain cannot be negative")
+		}
+		checkCosts(contentProjectsFile, entry, def.Costs)
+		if def.WardNetworkTicks < 0 {
+			fail(contentProjectsFile, entry, "ward_network_ticks cannot be negative")
+		}
+	}
+
+	if len(c.Relics) == 0 {
+		fail(contentRelicsFile, "relics", "at least one relic is required")
+	}
+	for i, def := range c.Relics {
+		entry := fmt.Sprintf("relics[%d] (%s)", i, def.Name)
+		if def.Name == "" {
+			fail(contentRelicsFile, entry, "name is required")
+		}
+		if !slices.Contains(knownRelicEffects, def.Effect) {
+			fail(contentRelicsFile, entry, "effect %q must be one of %s", def.Effect, strings.Join(knownRelicEffects, ", "))
+		}
+		if def.Power <= 0 {
+			fail(contentRelicsFile, entry, "power must be positive")
+		}
+	}
+
+	seen = map[string]bool{}
+	for i, def := range c.Locations {
+		entry := fmt.Sprintf("locations[%d] (%s)", i, def.ID)
+		switch {
+		case def.ID == "":
+			fail(contentLocationsFile, entry, "id is required")
+		case seen[def.ID]:
+			fail(contentLocationsFile, entry, "duplicate id")
+		}
+		seen[def.ID] = true
+		if def.Name == "" {
+			fail(contentLocationsFile, entry, "name is required")
+		}
+		if def.Danger < 0 || def.Danger > 100 {
+			fail(contentLocationsFile, entry, "danger must be between 0 and 100")
+		}
+		if def.Market != nil {
+			validateLocationMarket(def.Market, commodities, func(format string, args ...any) {
+				fail(contentLocationsFile, entry, format, args...)
+			})
+		}
+	}
+	for _, id := range requiredLocationIDs {
+		if !seen[id] {
+			fail(contentLocationsFile, "locations", "required location %q is missing", id)
+		}
+	}
+	if !slices.ContainsFunc(c.Locations, func(def LocationDef) bool {
+		return def.ID == locationCapital && def.Market != nil && slices.ContainsFunc(def.Market.Goods, func(g LocalGood) bool { return g.Commodity == commodityGrain })
+	}) {
+		fail(contentLocationsFile, "locations", "the %s market must trade %s", locationCapital, commodityGrain)
+	}
+	routes := map[string]bool{}
+	for i, r := range c.Routes {
+		entry := fmt.Sprintf("routes[%d] (%s-%s)", i, r.From, r.To)
+		if !seen[r.From] || !seen[r.To] {
+			fail(contentLocationsFile, entry, "route endpoints must be defined locations")
+		}
+		if r.From == r.To {
+			fail(contentLocationsFile, entry, "route must connect two different locations")
+		}
+		if r.Ticks <= 0 {
+			fail(contentLocationsFile, entry, "ticks must be positive")
+		}
+		if routes[r.From+":"+r.To] || routes[r.To+":"+r.From] {
+			fail(contentLocationsFile, entry, "duplicate route")
+		}
+		routes[r.From+":"+r.To] = true
+	}
+	if c.DefaultRouteTicks <= 0 {
+		fail(contentLocationsFile, "default_route_ticks", "must be positive")
+	}
+	for i, def := range c.Crises {
+		for _, id := range def.DamageLocations {
+			if !seen[id] {
+				fail(contentCrisesFile, fmt.Sprintf("crises[%d] (%s)", i, def.Type), "damage_locations names unknown location %q", id)
+			}
+		}
+	}
+
+	types := map[string]bool{}
+	for i, def := range c.Buildings {
+		entry := fmt.Sprintf("buildings[%d] (%s)", i, def.Type)
+		switch {
+		case def.Type == "":
+			fail(contentBuildingsFile, entry, "type is required")
+		case types[def.Type]:
+			fail(contentBuildingsFile, entry, "duplicate type")
+		}
+		types[def.Type] = true
+		if def.Name == "" {
+			fail(contentBuildingsFile, entry, "name is required")
+		}
+		if len(def.Locations) == 0 {
+			fail(contentBuildingsFile, entry, "at least one location is required")
+		}
+		for _, id := range def.Locations {
+			if !seen[id] {
+				fail(contentBuildingsFile, entry, "unknown location %q", id)
+			}
+		}
+		if def.BuildTicks <= 0 {
+			fail(contentBuildingsFile, entry, "build_ticks must be positive")
+		}
+		if def.CostGold < 0 || def.UpkeepGold < 0 || def.LaborGrain < 0 {
+			fail(contentBuildingsFile, entry, "cost_gold, upkeep_gold and labor_grain cannot be negative")
+		}
+		checkCosts(contentBuildingsFile, entry, def.Costs)
+		checkCosts(contentBuildingsFile, entry, def.Inputs)
+		if len(def.Output) == 0 {
+			fail(contentBuildingsFile, entry, "output is required")
+		}
+		for _, id := range sortedKeys(def.Output) {
+			if !commodities[id] {
+				fail(contentBuildingsFile, entry, "output names unknown commodity %q", id)
+			}
+			if def.Output[id] <= 0 {
+				fail(contentBuildingsFile, entry, "output of %q must be positive", id)
+			}
+		}
+	}
+
+	for id, name := range c.SeatHolders {
+		if !slices.Contains(knownSeatIDs, id) {
+			fail(contentSeatsFile, "default_holders", "unknown seat %q (known: %s)", id, strings.Join(knownSeatIDs, ", "))
+		}
+		if strings.TrimSpace(name) == "" {
+			fail(contentSeatsFile, "default_holders", "seat %q has an empty holder name", id)
+		}
+	}
+	if strings.TrimSpace(c.FallbackHolder) == "" {
+		fail(contentSeatsFile, "fallback_holder", "is required")
+	}
+	return errors.Join(errs...)
+}
+
+func validateLocationMarket(m *LocationMarket, commodities map[string]bool, fail func(format string, args ...any)) {
+	switch m.Jurisdiction {
+	case marketJurisdictionCity:
+		if m.TaxPct != 0 {
+			fail("a city market pays the city tax; tax_pct must be 0")
+		}
+		if m.Treasury != "" {
+			fail("a city market pays the City Authority; treasury must be empty")
+		}
+	case marketJurisdictionLocal:
+		if m.TaxPct < 0 || m.TaxPct > 100 {
+			fail("tax_pct must be between 0 and 100")
+		}
+		if m.Treasury != "" && !slices.Contains(knownInstitutionIDs, m.Treasury) {
+			fail("unknown treasury %q (known: %s)", m.Treasury, strings.Join(knownInstitutionIDs, ", "))
+		}
+	default:
+		fail("market jurisdiction %q must be %q or %q", m.Jurisdiction, marketJurisdictionCity, marketJurisdictionLocal)
+	}
+	traded := map[string]bool{}
+	for _, g := range m.Goods {
+		switch {
+		case !commodities[g.Commodity]:
+			fail("market trades unknown commodity %q", g.Commodity)
+		case traded[g.Commodity]:
+			fail("market lists %q twice", g.Commodity)
+		}
+		traded[g.Commodity] = true
+		if g.Stock < 0 || g.MaxStock < 0 || g.Production < 0 || g.Consumption < 0 {
+			fail("%s: stock, max_stock, production and consumption cannot be negative", g.Commodity)
+		}
+		if g.MaxStock > 0 && g.Stock > g.MaxStock {
+			fail("%s: stock cannot exceed max_stock", g.Commodity)
+		}
+		if g.ImportPct < 0 || g.ImportPct > 100 {
+			fail("%s: import_pct must be between 0 and 100", g.Commodity)
+		}
+		if g.PricePct <= 0 {
+			fail("%s: price_pct must be positive", g.Commodity)
+		}
+	}
+}
+
+// contentSummary is a one-line description for the admin page.
+func contentSummary(c *GameContent) string {
+	source := c.Source
+	if source != "embedded" {
+		source = filepath.Clean(source)
+	}
+	return fmt.Sprintf("%s · %d crises · %d projects · %d relics · %d locations · %d commodities · %d buildings · loaded %s",
+		source, len(c.Crises), len(c.Projects), len(c.Relics), len(c.Locations), len(c.Commodities), len(c.Buildings), c.LoadedAt.Format(time.RFC3339))
+}
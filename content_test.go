@@ -0,0 +1,161 @@
+package main
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeContentDir copies the embedded content into a temp dir, applying
+// edits keyed by file name, and points CONTENT_DIR at it. The active content
+// is restored when the test ends.
+func writeContentDir(t *testing.T, edits map[string]func(string) string) string {
+	t.Helper()
+	dir := t.TempDir()
+	entries, err := fs.ReadDir(embeddedContentSubFS(), ".")
+	if err != nil {
+		t.Fatalf("read embedded content: %v", err)
+	}
+	for _, e := range entries {
+		raw, err := fs.ReadFile(embeddedContentSubFS(), e.Name())
+		if err != nil {
+			t.Fatalf("read %s: %v", e.Name(), err)
+		}
+		data := string(raw)
+		if edit := edits[e.Name()]; edit != nil {
+			data = edit(data)
+		}
+		if err := os.WriteFile(filepath.Join(dir, e.Name()), []byte(data), 0o644); err != nil {
+			t.Fatalf("write %s: %v", e.Name(), err)
+		}
+	}
+	t.Setenv(contentDirEnvName, dir)
+	prev := currentContent()
+	t.Cleanup(func() { activeContent.Store(prev) })
+	return dir
+}
+
+func TestEmbeddedContentMatchesBuiltins(t *testing.T) {
+	c, err := loadGameContent(embeddedContentSubFS(), "embedded")
+	if err != nil {
+		t.Fatalf("embedded content invalid: %v", err)
+	}
+	if len(c.Crises) != 3 || len(c.Projects) != 4 || len(c.Relics) != 5 || len(c.Locations) != 4 || len(c.Commodities) != 7 || len(c.Buildings) != 5 {
+		t.Fatalf("unexpected embedded content sizes: %s", contentSummary(c))
+	}
+	if got := travelTicksBetween(locationCapital, locationRuins); got != 3 {
+		t.Fatalf("capital->ruins = %d, want 3", got)
+	}
+	if got := travelTicksBetween(locationRuins, locationHarbor); got != 3 {
+		t.Fatalf("routes should be bidirectional, ruins->harbor = %d", got)
+	}
+	if got := seatDefaultHolderName("master_of_coin"); got != "Clerk Marn (NPC)" {
+		t.Fatalf("master_of_coin holder = %q", got)
+	}
+	if got := seatDefaultHolderName("unknown"); got != "Appointee (NPC)" {
+		t.Fatalf("fallback holder = %q", got)
+	}
+}
+
+func TestLoadGameContentReportsProblems(t *testing.T) {
+	dir := writeContentDir(t, map[string]func(string) string{
+		contentRelicsFile: func(s string) string { return strings.Replace(s, `"effect": "heat"`, `"effect": "fire"`, 1) },
+		contentLocationsFile: func(s string) string {
+			return strings.Replace(s, `"id": "ruins",
+      "name": "Haunted Ruins",`, `"id": "tower",
+      "name": "Tower",`, 1)
+		},
+		contentSeatsFile: func(s string) string { return strings.Replace(s, `"high_curate"`, `"high_priest"`, 1) },
+	})
+	_, err := loadGameContent(os.DirFS(dir), dir)
+	if err == nil {
+		t.Fatalf("expected validation errors")
+	}
+	for _, want := range []string{
+		`relics.json: relics[0] (Warding Charm): effect "fire"`,
+		`locations.json: locations: required location "ruins" is missing`,
+		`locations.json: routes[3] (frontier-ruins): route endpoints must be defined locations`,
+		`seats.json: default_holders: unknown seat "high_priest"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("missing %q in:\n%v", want, err)
+		}
+	}
+
+	dir = writeContentDir(t, map[string]func(string) string{
+		contentCrisesFile:   func(s string) string { return strings.Replace(s, `"gold_cost": 4,`, `"gold_cost": 4`, 1) },
+		contentProjectsFile: func(s string) string { return strings.Replace(s, `"cost_gold": 10`, `"gold": 10`, 1) },
+	})
+	_, err = loadGameContent(os.DirFS(dir), dir)
+	if err == nil || !strings.Contains(err.Error(), "crises.json:10:") || !strings.Contains(err.Error(), `projects.json: json: unknown field "gold"`) {
+		t.Fatalf("expected syntax and unknown-field errors, got %v", err)
+	}
+}
+
+func TestAdminContentReloadKeepsInFlightDefinitions(t *testing.T) {
+	t.Setenv(adminLoopbackEnvName, "true")
+	t.Setenv(adminTokenEnvName, "test-secret")
+	s := newTestStore()
+	mux := newMux(s, parseTemplates())
+	now := time.Date(2026, 2, 12, 12, 0, 0, 0, time.UTC)
+
+	plague, _ := crisisDefinitionByType("plague")
+	startCrisisLocked(s, plague, now)
+	reinforce, _ := projectDefinitionByType("granary_reinforcement")
+	s.Projects["p-1"] = &Project{ID: "p-1", Type: reinforce.Type, Name: reinforce.Name, TicksLeft: 1, Definition: &reinforce}
+
+	reload := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin/content/reload", strings.NewReader(url.Values{}.Encode()))
+		req.RemoteAddr = "127.0.0.1:1111"
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(adminAuthHeaderName, "test-secret")
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+		return resp
+	}
+
+	writeContentDir(t, map[string]func(string) string{
+		contentCrisesFile: func(s string) string {
+			return strings.Replace(s, `"gold_cost": 4,`, `"gold_cost": 40,`, 1)
+		},
+		contentProjectsFile: func(s string) string {
+			s = strings.Replace(s, `"type": "granary_reinforcement"`, `"type": "granary_expansion"`, 1)
+			return strings.Replace(s, `"grain_delta": 60`, `"grain_delta": 1`, 1)
+		},
+	})
+	if resp := reload(); resp.Code != http.StatusSeeOther {
+		t.Fatalf("reload status=%d body=%s", resp.Code, resp.Body.String())
+	}
+	if def, _ := crisisDefinitionByType("plague"); def.GoldCost != 40 {
+		t.Fatalf("reload should change future definitions, gold_cost=%d", def.GoldCost)
+	}
+	if _, ok := projectDefinitionByType("granary_reinforcement"); ok {
+		t.Fatalf("removed project type should no longer be offered")
+	}
+	if def, ok := crisisDefinitionFor(s.ActiveCrisis); !ok || def.GoldCost != 4 {
+		t.Fatalf("in-flight crisis should keep its definition, got %+v", def)
+	}
+
+	before := s.World.GrainSupply
+	processProjectTickLocked(s, now)
+	if s.Projects["p-1"] != nil || s.World.GrainSupply != before+60 {
+		t.Fatalf("in-flight project should complete with its original effect: grain %d -> %d", before, s.World.GrainSupply)
+	}
+
+	writeContentDir(t, map[string]func(string) string{
+		contentCrisesFile: func(string) string { return `{"crises": []}` },
+	})
+	resp := reload()
+	if resp.Code != http.StatusBadRequest || !strings.Contains(resp.Body.String(), "at least one crisis is required") {
+		t.Fatalf("invalid reload should be rejected, got %d %s", resp.Code, resp.Body.String())
+	}
+	if def, _ := crisisDefinitionByType("plague"); def.GoldCost != 40 {
+		t.Fatalf("rejected reload must leave active content in place")
+	}
+}
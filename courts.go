@@ -0,0 +1,519 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// courtTrialTicks is how long a case stays open for evidence, jury
+	// votes and the magistrate's ruling before the verdict.
+	courtTrialTicks = 4
+	// courtConvictMargin is how far the prosecution's evidence must outweigh
+	// the defense's for the appointed magistrate to convict.
+	courtConvictMargin = 4
+	// Each forged exhibit is exposed at the verdict with courtExposePct
+	// chance, plus courtExposePerExhibit for every genuine exhibit the
+	// other side put up against it.
+	courtExposePct        = 40
+	courtExposePerExhibit = 15
+	// A forger exposed at trial pays for it.
+	courtPerjuryRep  = 6
+	courtPerjuryHeat = 3
+	courtPerjuryFine = 6
+	// An accuser whose case ends in acquittal loses courtAcquittalRep.
+	courtAcquittalRep = 2
+
+	courtFineGold      = 8
+	courtConfiscatePct = 50
+	courtJailTicks     = 3
+	courtExileTicks    = 6
+)
+
+const (
+	caseSideProsecution = "prosecution"
+	caseSideDefense     = "defense"
+
+	sentenceFine        = "fine"
+	sentenceConfiscate  = "confiscate"
+	sentenceHouseArrest = "house_arrest"
+	sentenceJail        = "jail"
+	sentenceExile       = "exile"
+	verdictAcquit       = "acquit"
+	verdictGuilty       = "guilty"
+)
+
+// courtSentences are the sentences a magistrate may hand down, lightest
+// first.
+var courtSentences = []string{sentenceFine, sentenceConfiscate, sentenceHouseArrest, sentenceJail, sentenceExile}
+
+// CourtCase is the file on an accused or arrested player. It stays open for
+// courtTrialTicks; the verdict is the magistrate's ruling if a player holds
+// the seat and has ruled, else the jury's majority, else the appointee's
+// reading of the evidence.
+type CourtCase struct {
+	ID            string
+	DefendantID   string
+	DefendantName string
+	// AccuserID is the player who issued the warrant or made the arrest;
+	// empty for the Watch appointee.
+	AccuserID    string
+	AccuserName  string
+	Charge       string
+	OpenedAtTick int64
+	TicksLeft    int
+	Exhibits     []Exhibit `json:",omitempty"`
+	// JuryVotes maps each juror to a guilty vote.
+	JuryVotes map[string]bool `json:",omitempty"`
+	// Ruling is the magistrate's sentence or verdictAcquit, applied when the
+	// trial ends.
+	Ruling    string `json:",omitempty"`
+	JudgeName string `json:",omitempty"`
+}
+
+// Exhibit is a piece of evidence entered into a case. Forged exhibits keep
+// their author so an exposure falls on the forger, not whoever submitted it.
+type Exhibit struct {
+	Side            string
+	SubmittedByID   string
+	SubmittedByName string
+	AuthorID        string
+	AuthorName      string
+	Topic           string
+	Strength        int
+	Forged          bool `json:",omitempty"`
+}
+
+type ExhibitView struct {
+	Side        string `json:"side"`
+	SubmittedBy string `json:"submitted_by"`
+	Topic       string `json:"topic"`
+	Strength    int    `json:"strength"`
+}
+
+type CaseView struct {
+	ID              string        `json:"id"`
+	DefendantName   string        `json:"defendant_name"`
+	AccuserName     string        `json:"accuser_name"`
+	Charge          string        `json:"charge"`
+	TicksLeft       int           `json:"ticks_left"`
+	Exhibits        []ExhibitView `json:"exhibits"`
+	JuryGuilty      int           `json:"jury_guilty"`
+	JuryAcquit      int           `json:"jury_acquit"`
+	Ruling          string        `json:"ruling,omitempty"`
+	IsDefendant     bool          `json:"is_defendant"`
+	CanProsecute    bool          `json:"can_prosecute"`
+	CanSitOnJury    bool          `json:"can_sit_on_jury"`
+	MyVote          string        `json:"my_vote,omitempty"`
+	CanRule         bool          `json:"can_rule"`
+	SentenceOptions []string      `json:"sentence_options,omitempty"`
+}
+
+// openCaseForLocked returns the open case against playerID, if any.
+func openCaseForLocked(store *Store, playerID string) *CourtCase {
+	for _, id := range sortedKeys(store.Cases) {
+		if cs := store.Cases[id]; cs.DefendantID == playerID {
+			return cs
+		}
+	}
+	return nil
+}
+
+// openCaseLocked files a case against defendant, or returns the one already
+// open. accuserID is empty for an appointee.
+func openCaseLocked(store *Store, now time.Time, defendant *Player, accuserID, accuserName, charge string) *CourtCase {
+	if cs := openCaseForLocked(store, defendant.ID); cs != nil {
+		return cs
+	}
+	store.NextCaseID++
+	cs := &CourtCase{
+		ID:            fmt.Sprintf("case-%d", store.NextCaseID),
+		DefendantID:   defendant.ID,
+		DefendantName: defendant.Name,
+		AccuserID:     accuserID,
+		AccuserName:   accuserName,
+		Charge:        charge,
+		OpenedAtTick:  store.TickCount,
+		TicksLeft:     courtTrialTicks,
+	}
+	store.dirty.mark(dirtyCases, cs.ID)
+	store.Cases[cs.ID] = cs
+	addEventLocked(store, Event{Type: "Law", Severity: 3, Text: fmt.Sprintf("[%s] files charges of %s against [%s]; trial in %d ticks.", accuserName, charge, defendant.Name, courtTrialTicks), At: now})
+	setToastLocked(store, defendant.ID, fmt.Sprintf("You stand accused of %s. Submit your defense within %d ticks.", charge, courtTrialTicks))
+	return cs
+}
+
+// enterExhibitLocked moves ev out of its holder's dossier into the case.
+func enterExhibitLocked(store *Store, cs *CourtCase, submitter *Player, side string, ev *Evidence) {
+	store.dirty.mark(dirtyEvidence, ev.ID)
+	delete(store.Evidence, ev.ID)
+	store.dirty.mark(dirtyCases, cs.ID)
+	cs.Exhibits = append(cs.Exhibits, Exhibit{
+		Side:            side,
+		SubmittedByID:   submitter.ID,
+		SubmittedByName: submitter.Name,
+		AuthorID:        ev.SourcePlayerID,
+		AuthorName:      ev.SourceName,
+		Topic:           ev.Topic,
+		Strength:        ev.Strength,
+		Forged:          ev.Forged,
+	})
+}
+
+// canProsecuteLocked reports whether p may put evidence against the
+// defendant: the accuser, the Commander of the Watch or a Watch officer.
+func canProsecuteLocked(store *Store, cs *CourtCase, p *Player) bool {
+	if p.ID == cs.DefendantID {
+		return false
+	}
+	return p.ID == cs.AccuserID || playerHoldsSeatLocked(store, p.ID, "watch_commander") || p.NPCRole == npcRoleWatch
+}
+
+// canSitOnJury is true for players online now who are not party to the
+// case.
+func canSitOnJury(store *Store, cs *CourtCase, p *Player, now time.Time) bool {
+	return p.ID != cs.DefendantID && p.ID != cs.AccuserID && now.Sub(lastSeenLocked(store, p)) <= onlineWindow
+}
+
+// seatedJuryVotesLocked counts the votes of jurors still online at the
+// verdict; a juror who has left is no longer on the panel.
+func seatedJuryVotesLocked(store *Store, cs *CourtCase, now time.Time) (guilty, total int) {
+	for id, v := range cs.JuryVotes {
+		if juror := store.Players[id]; juror == nil || !canSitOnJury(store, cs, juror, now) {
+			continue
+		}
+		total++
+		if v {
+			guilty++
+		}
+	}
+	return guilty, total
+}
+
+func courtCaseLocked(store *Store, p *Player, caseID string) *CourtCase {
+	cs := store.Cases[strings.TrimSpace(caseID)]
+	if cs == nil {
+		rejectLocked(store, p.ID, errCodeNotFound, "That case is closed.")
+	}
+	return cs
+}
+
+// submitEvidenceLocked enters p's strongest evidence into a case. The
+// prosecution submits evidence on the defendant; the defendant submits
+// evidence they hold on targetID, or their strongest on anyone.
+func submitEvidenceLocked(store *Store, p *Player, now time.Time, caseID, targetID string) {
+	cs := courtCaseLocked(store, p, caseID)
+	if cs == nil {
+		return
+	}
+	side := caseSideProsecution
+	var ev *Evidence
+	switch {
+	case p.ID == cs.DefendantID:
+		side = caseSideDefense
+		if targetID != "" {
+			ev = strongestEvidenceForLocked(store, p.ID, targetID)
+		} else {
+			ev = strongestHeldEvidenceLocked(store, p.ID)
+		}
+	case canProsecuteLocked(store, cs, p):
+		ev = strongestEvidenceForLocked(store, p.ID, cs.DefendantID)
+	default:
+		rejectLocked(store, p.ID, errCodeNotAllowed, "Only the Watch, the accuser and the accused may enter evidence.")
+		return
+	}
+	if ev == nil {
+		rejectLocked(store, p.ID, errCodeNotAllowed, "You hold no evidence to enter.")
+		return
+	}
+	enterExhibitLocked(store, cs, p, side, ev)
+	addEventLocked(store, Event{Type: "Law", Severity: 2, Text: fmt.Sprintf("[%s] enters evidence for the %s in the case against [%s].", p.Name, side, cs.DefendantName), At: now})
+	setToastLocked(store, p.ID, fmt.Sprintf("Evidence entered (strength %d).", ev.Strength))
+}
+
+func strongestHeldEvidenceLocked(store *Store, sourceID string) *Evidence {
+	var out *Evidence
+	for _, id := range sortedKeys(store.Evidence) {
+		ev := store.Evidence[id]
+		if ev.SourcePlayerID == sourceID && (out == nil || ev.Strength > out.Strength) {
+			out = ev
+		}
+	}
+	return out
+}
+
+func juryVoteLocked(store *Store, p *Player, now time.Time, caseID, vote string) {
+	cs := courtCaseLocked(store, p, caseID)
+	if cs == nil {
+		return
+	}
+	if !canSitOnJury(store, cs, p, now) {
+		rejectLocked(store, p.ID, errCodeNotAllowed, "Parties to a case cannot sit on its jury.")
+		return
+	}
+	if vote != verdictGuilty && vote != verdictAcquit {
+		rejectLocked(store, p.ID, errCodeInvalidInput, "Vote guilty or acquit.")
+		return
+	}
+	store.dirty.mark(dirtyCases, cs.ID)
+	if cs.JuryVotes == nil {
+		cs.JuryVotes = map[string]bool{}
+	}
+	cs.JuryVotes[p.ID] = vote == verdictGuilty
+	if vote == verdictGuilty {
+		setToastLocked(store, p.ID, fmt.Sprintf("You vote to convict %s.", cs.DefendantName))
+		return
+	}
+	setToastLocked(store, p.ID, fmt.Sprintf("You vote to acquit %s.", cs.DefendantName))
+}
+
+// ruleCaseLocked records the Magistrate's ruling; it may be changed until
+// the trial ends.
+func ruleCaseLocked(store *Store, p *Player, now time.Time, caseID, ruling string) {
+	if !playerHoldsSeatLocked(store, p.ID, "magistrate") {
+		rejectLocked(store, p.ID, errCodeNotAllowed, "Only the Magistrate can rule on a case.")
+		return
+	}
+	cs := courtCaseLocked(store, p, caseID)
+	if cs == nil {
+		return
+	}
+	if p.ID == cs.DefendantID {
+		rejectLocked(store, p.ID, errCodeNotAllowed, "You cannot judge your own case.")
+		return
+	}
+	if ruling != verdictAcquit && !containsString(courtSentences, ruling) {
+		rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a sentence or acquit.")
+		return
+	}
+	store.dirty.mark(dirtyCases, cs.ID)
+	cs.Ruling = ruling
+	cs.JudgeName = p.Name
+	setToastLocked(store, p.ID, fmt.Sprintf("Your ruling on %s stands when the trial ends in %d ticks.", cs.DefendantName, cs.TicksLeft))
+}
+
+// processCourtTickLocked runs each trial's clock and delivers the verdicts
+// that are due.
+func processCourtTickLocked(store *Store, now time.Time) {
+	for _, id := range sortedKeys(store.Cases) {
+		cs := store.Cases[id]
+		if store.Players[cs.DefendantID] == nil {
+			delete(store.Cases, id)
+			continue
+		}
+		cs.TicksLeft--
+		if cs.TicksLeft <= 0 {
+			renderVerdictLocked(store, cs, now)
+			delete(store.Cases, id)
+		}
+	}
+}
+
+// renderVerdictLocked tests the forged exhibits, weighs what is left and
+// applies the verdict and sentence.
+func renderVerdictLocked(store *Store, cs *CourtCase, now time.Time) {
+	defendant := store.Players[cs.DefendantID]
+	exposeForgeriesLocked(store, cs, now)
+	prosecution, defense := caseWeights(cs)
+	votes, jurors := seatedJuryVotesLocked(store, cs, now)
+
+	guilty, sentence, by := false, "", ""
+	switch {
+	case cs.Ruling != "":
+		guilty, sentence, by = cs.Ruling != verdictAcquit, cs.Ruling, fmt.Sprintf("Magistrate [%s]", cs.JudgeName)
+		if prosecution == 0 {
+			guilty, by = false, fmt.Sprintf("The case collapses before Magistrate [%s]", cs.JudgeName)
+		}
+	case jurors > 0:
+		guilty, by = 2*votes > jurors, fmt.Sprintf("The jury (%d of %d for guilty)", votes, jurors)
+		if prosecution == 0 {
+			guilty, by = false, "The case collapses before the jury"
+		}
+	default:
+		guilty, by = prosecution-defense >= courtConvictMargin, fmt.Sprintf("[%s]", store.Seats["magistrate"].HolderName)
+	}
+	accuser := store.Players[cs.AccuserID]
+	store.dirty.mark(dirtyWarrants, defendant.ID)
+	delete(store.Warrants, defendant.ID)
+	if !guilty {
+		defendant.Heat = maxInt(0, defendant.Heat-1)
+		if accuser != nil {
+			accuser.Rep = clampInt(accuser.Rep-courtAcquittalRep, -100, 100)
+		}
+		addEventLocked(store, Event{Type: "Law", Severity: 3, Text: fmt.Sprintf("%s acquits [%s] of %s.", by, cs.DefendantName, cs.Charge), At: now})
+		setToastLocked(store, defendant.ID, "The court acquits you.")
+		return
+	}
+	if !containsString(courtSentences, sentence) {
+		sentence = sentenceForWeight(prosecution - defense)
+	}
+	defendant.Heat = maxInt(0, defendant.Heat-clampInt(2+prosecution/2, 2, 6))
+	defendant.Rep = clampInt(defendant.Rep-clampInt(2+prosecution/3, 2, 6), -100, 100)
+	if accuser != nil {
+		accuser.Rep = clampInt(accuser.Rep+1, -100, 100)
+	}
+	note := applySentenceLocked(store, defendant, sentence, now)
+	addEventLocked(store, Event{Type: "Law", Severity: 4, Text: fmt.Sprintf("%s convicts [%s] of %s: %s.", by, cs.DefendantName, cs.Charge, note), At: now})
+	setToastLocked(store, defendant.ID, fmt.Sprintf("Convicted: %s.", note))
+}
+
+// exposeForgeriesLocked strikes forged exhibits the court sees through and
+// punishes their authors.
+func exposeForgeriesLocked(store *Store, cs *CourtCase, now time.Time) {
+	kept := cs.Exhibits[:0]
+	for _, ex := range cs.Exhibits {
+		if !ex.Forged {
+			kept = append(kept, ex)
+			continue
+		}
+		opposing := 0
+		for _, other := range cs.Exhibits {
+			if other.Side != ex.Side && !other.Forged {
+				opposing++
+			}
+		}
+		if !rollPercent(rngStreamLocked(store, rngStreamIntel), minInt(courtExposePct+courtExposePerExhibit*opposing, 90)) {
+			kept = append(kept, ex)
+			continue
+		}
+		addEventLocked(store, Event{Type: "Law", Severity: 4, Text: fmt.Sprintf("The court exposes [%s]'s %s dossier in the case against [%s] as a forgery.", ex.AuthorName, ex.Topic, cs.DefendantName), At: now})
+		if forger := store.Players[ex.AuthorID]; forger != nil {
+			forger.Rep = clampInt(forger.Rep-courtPerjuryRep, -100, 100)
+			forger.Heat = clampInt(forger.Heat+courtPerjuryHeat, 0, 20)
+			collectFineLocked(store, now, forger, courtPerjuryFine, "Perjury fine")
+			setToastLocked(store, forger.ID, "Your forgery was exposed in court.")
+		}
+	}
+	cs.Exhibits = kept
+}
+
+func caseWeights(cs *CourtCase) (prosecution, defense int) {
+	for _, ex := range cs.Exhibits {
+		if ex.Side == caseSideDefense {
+			defense += ex.Strength
+		} else {
+			prosecution += ex.Strength
+		}
+	}
+	return prosecution, defense
+}
+
+// sentenceForWeight is the sentence the appointee or a jury's conviction
+// carries for a case of the given weight.
+func sentenceForWeight(weight int) string {
+	switch {
+	case weight >= 13:
+		return sentenceExile
+	case weight >= 10:
+		return sentenceJail
+	case weight >= 8:
+		return sentenceHouseArrest
+	case weight >= 6:
+		return sentenceConfiscate
+	}
+	return sentenceFine
+}
+
+// applySentenceLocked carries out a sentence on p and describes it.
+func applySentenceLocked(store *Store, p *Player, sentence string, now time.Time) string {
+	switch sentence {
+	case sentenceConfiscate:
+		gold := collectFineLocked(store, now, p, p.Gold*courtConfiscatePct/100, "Confiscation")
+		grain := p.Grain
+		if grain > 0 {
+			p.Grain = 0
+			applyGrainSupplyDeltaLocked(store, now, grain*grainCommodity().PoolPerUnit)
+		}
+		return fmt.Sprintf("%dg and %d sacks confiscated", gold, grain)
+	case sentenceHouseArrest:
+		addStatusLocked(p, statusHouseArrest, "", statusHouseArrestTicks, "court sentence")
+		return fmt.Sprintf("%d ticks of house arrest", statusHouseArrestTicks)
+	case sentenceJail:
+		jailLocked(p, courtJailTicks, "court sentence")
+		return fmt.Sprintf("%d ticks in the city jail", courtJailTicks)
+	case sentenceExile:
+		from := p.LocationID
+		if p.TravelTicksLeft > 0 {
+			from = p.TravelToID
+		}
+		addStatusLocked(p, statusExiled, from, courtExileTicks, "court sentence")
+		// The exile is escorted out along the shortest road.
+		if to := nearestLocation(from); to != "" {
+			ticks := travelTicksLocked(store, from, to)
+			p.LocationID = from
+			p.TravelToID, p.TravelTicksLeft, p.TravelTotalTicks = to, ticks, ticks
+		}
+		return fmt.Sprintf("exiled from %s for %d ticks", locationName(from), courtExileTicks)
+	}
+	paid := collectFineLocked(store, now, p, courtFineGold, "Court fine")
+	return fmt.Sprintf("fined %dg", paid)
+}
+
+// nearestLocation is the closest other place to from by road.
+func nearestLocation(from string) string {
+	best, bestTicks := "", 0
+	for _, def := range locationDefinitions() {
+		ticks := travelTicksBetween(from, def.ID)
+		if def.ID == from || ticks <= 0 {
+			continue
+		}
+		if best == "" || ticks < bestTicks {
+			best, bestTicks = def.ID, ticks
+		}
+	}
+	return best
+}
+
+// courtStatusLocked describes p's standing with the court for the players
+// list and the dashboard.
+func courtStatusLocked(store *Store, p *Player) string {
+	if openCaseForLocked(store, p.ID) != nil {
+		return "On trial"
+	}
+	return ""
+}
+
+func caseViewsLocked(store *Store, p *Player, now time.Time) []CaseView {
+	judge := playerHoldsSeatLocked(store, p.ID, "magistrate")
+	views := make([]CaseView, 0, len(store.Cases))
+	for _, id := range sortedKeys(store.Cases) {
+		cs := store.Cases[id]
+		v := CaseView{
+			ID:            cs.ID,
+			DefendantName: cs.DefendantName,
+			AccuserName:   cs.AccuserName,
+			Charge:        cs.Charge,
+			TicksLeft:     cs.TicksLeft,
+			Exhibits:      make([]ExhibitView, 0, len(cs.Exhibits)),
+			IsDefendant:   cs.DefendantID == p.ID,
+			CanProsecute:  canProsecuteLocked(store, cs, p),
+			CanSitOnJury:  canSitOnJury(store, cs, p, now),
+			CanRule:       judge && cs.DefendantID != p.ID,
+		}
+		for _, ex := range cs.Exhibits {
+			v.Exhibits = append(v.Exhibits, ExhibitView{Side: ex.Side, SubmittedBy: ex.SubmittedByName, Topic: ex.Topic, Strength: ex.Strength})
+		}
+		for voter, guilty := range cs.JuryVotes {
+			if guilty {
+				v.JuryGuilty++
+			} else {
+				v.JuryAcquit++
+			}
+			if voter == p.ID {
+				v.MyVote = verdictAcquit
+				if guilty {
+					v.MyVote = verdictGuilty
+				}
+			}
+		}
+		if v.CanRule {
+			v.Ruling = cs.Ruling
+			v.SentenceOptions = append(append([]string{}, courtSentences...), verdictAcquit)
+		}
+		views = append(views, v)
+	}
+	sort.SliceStable(views, func(i, j int) bool { return views[i].TicksLeft < views[j].TicksLeft })
+	return views
+}
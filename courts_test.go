@@ -0,0 +1,197 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func runTrial(s *Store, now time.Time) {
+	for i := 0; i < courtTrialTicks; i++ {
+		processCourtTickLocked(s, now)
+	}
+}
+
+func TestWarrantCaseIsWeighedByTheAppointedMagistrate(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	commander := &Player{ID: "p1", Name: "Ash Crow (Guest)", LastSeen: now}
+	accused := &Player{ID: "p2", Name: "Bram Vale (Guest)", Gold: 40, Heat: 4, LastSeen: now}
+	bystander := &Player{ID: "p3", Name: "Cole Reed (Guest)", LastSeen: now}
+	for _, p := range []*Player{commander, accused, bystander} {
+		s.Players[p.ID] = p
+	}
+	s.Seats["watch_commander"].HolderPlayerID = commander.ID
+	city := s.Institutions["city_authority"]
+
+	issueWarrantLocked(s, now, commander.ID, commander.Name, accused)
+	cs := openCaseForLocked(s, accused.ID)
+	if cs == nil || cs.AccuserID != commander.ID || cs.TicksLeft != courtTrialTicks {
+		t.Fatalf("a warrant should open a case file: %+v", cs)
+	}
+	addEvidenceLocked(s, commander, accused, "smuggling", 9, 5, false)
+	addEvidenceLocked(s, bystander, accused, "fraud", 5, 5, false)
+	addEvidenceLocked(s, accused, commander, "bribery", 2, 5, false)
+
+	handleActionInputLocked(s, bystander, now, ActionInput{Action: "submit_evidence", ContractID: cs.ID})
+	if s.rejections[bystander.ID] != errCodeNotAllowed {
+		t.Fatalf("only the Watch and the parties may enter evidence, got %q", s.rejections[bystander.ID])
+	}
+	handleActionInputLocked(s, commander, now, ActionInput{Action: "submit_evidence", ContractID: cs.ID})
+	handleActionInputLocked(s, accused, now, ActionInput{Action: "submit_evidence", ContractID: cs.ID, TargetID: commander.ID})
+	if len(cs.Exhibits) != 2 || cs.Exhibits[1].Side != caseSideDefense || len(s.Evidence) != 1 {
+		t.Fatalf("entered evidence should move from the dossiers into the case: %+v", cs.Exhibits)
+	}
+
+	opening := city.Treasury
+	runTrial(s, now)
+	if len(s.Cases) != 0 || hasActiveWarrantLocked(s, accused.ID) {
+		t.Fatalf("the verdict should close the case and the warrant")
+	}
+	// 9 against 2 carries confiscation of half the accused's purse.
+	if accused.Gold != 20 || city.Treasury != opening+20 {
+		t.Fatalf("the conviction should confiscate into the treasury: gold %d treasury %d", accused.Gold, city.Treasury)
+	}
+	if got := lastEventText(s); !strings.Contains(got, "[Justice Orrin (NPC)] convicts [Bram Vale (Guest)]") {
+		t.Fatalf("the verdict should be published: %q", got)
+	}
+
+	rep := commander.Rep
+	issueWarrantLocked(s, now, commander.ID, commander.Name, bystander)
+	runTrial(s, now)
+	if commander.Rep != rep-courtAcquittalRep {
+		t.Fatalf("a case without evidence should cost the accuser, rep %d -> %d", rep, commander.Rep)
+	}
+	if !strings.Contains(lastEventText(s), "acquits [Cole Reed (Guest)]") {
+		t.Fatalf("a case without evidence should end in acquittal: %q", lastEventText(s))
+	}
+}
+
+func TestMagistrateRulingsAndJuries(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	judge := &Player{ID: "p1", Name: "Ash Crow (Guest)", LastSeen: now}
+	hunter := &Player{ID: "p2", Name: "Bram Vale (Guest)", LastSeen: now}
+	accused := &Player{ID: "p3", Name: "Cole Reed (Guest)", Gold: 10, LastSeen: now, LocationID: locationCapital}
+	juror := &Player{ID: "p4", Name: "Dara Fenn (Guest)", LastSeen: now}
+	for _, p := range []*Player{judge, hunter, accused, juror} {
+		s.Players[p.ID] = p
+	}
+	s.Seats["magistrate"].HolderPlayerID = judge.ID
+
+	addEvidenceLocked(s, hunter, accused, "fraud", 5, 5, false)
+	applyBountyResolutionLocked(s, hunter, accused, strongestEvidenceForLocked(s, hunter.ID, accused.ID), now)
+	cs := openCaseForLocked(s, accused.ID)
+	handleActionInputLocked(s, hunter, now, ActionInput{Action: "rule_case", ContractID: cs.ID, Stance: sentenceJail})
+	if s.rejections[hunter.ID] != errCodeNotAllowed {
+		t.Fatalf("only the Magistrate rules, got %q", s.rejections[hunter.ID])
+	}
+	handleActionInputLocked(s, judge, now, ActionInput{Action: "rule_case", ContractID: cs.ID, Stance: sentenceJail})
+	handleActionInputLocked(s, juror, now, ActionInput{Action: "jury_vote", ContractID: cs.ID, Stance: verdictAcquit})
+	runTrial(s, now)
+	if st := statusFor(accused, statusJailed); st == nil || st.TicksLeft != courtJailTicks {
+		t.Fatalf("the Magistrate's ruling should outweigh the jury: %+v", accused)
+	}
+	handleActionInputLocked(s, accused, now, ActionInput{Action: "investigate"})
+	if s.rejections[accused.ID] != errCodeRestricted {
+		t.Fatalf("jailed players cannot act, got %q", s.rejections[accused.ID])
+	}
+	for i := 0; i < courtJailTicks; i++ {
+		processPlayerTickLocked(s, now)
+	}
+	if hasStatus(accused, statusJailed) {
+		t.Fatalf("the sentence should be served")
+	}
+
+	s.Seats["magistrate"].HolderPlayerID = ""
+	addEvidenceLocked(s, hunter, accused, "fraud", 2, 5, false)
+	applyBountyResolutionLocked(s, hunter, accused, strongestEvidenceForLocked(s, hunter.ID, accused.ID), now)
+	cs = openCaseForLocked(s, accused.ID)
+	handleActionInputLocked(s, hunter, now, ActionInput{Action: "jury_vote", ContractID: cs.ID, Stance: verdictGuilty})
+	if s.rejections[hunter.ID] != errCodeNotAllowed {
+		t.Fatalf("the accuser cannot sit on the jury, got %q", s.rejections[hunter.ID])
+	}
+	handleActionInputLocked(s, juror, now, ActionInput{Action: "jury_vote", ContractID: cs.ID, Stance: verdictGuilty})
+	handleActionInputLocked(s, judge, now, ActionInput{Action: "jury_vote", ContractID: cs.ID, Stance: verdictGuilty})
+	runTrial(s, now)
+	if accused.Gold != 10-courtFineGold || !strings.Contains(lastEventText(s), "The jury (2 of 2 for guilty) convicts") {
+		t.Fatalf("a guilty jury should convict on thin evidence: gold %d, %q", accused.Gold, lastEventText(s))
+	}
+
+	applySentenceLocked(s, accused, sentenceExile, now)
+	if st := statusFor(accused, statusExiled); st == nil || st.LocationID != locationCapital || accused.TravelTicksLeft == 0 || accused.TravelToID == locationCapital {
+		t.Fatalf("an exile should be escorted out: %+v", accused)
+	}
+	accused.TravelTicksLeft, accused.LocationID = 0, accused.TravelToID
+	handleActionInputLocked(s, accused, now, ActionInput{Action: "travel", LocationID: locationCapital})
+	if s.rejections[accused.ID] != errCodeRestricted {
+		t.Fatalf("an exile cannot return, got %q", s.rejections[accused.ID])
+	}
+}
+
+func TestJuriesSitOnlineAndNeedAProsecution(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	commander := &Player{ID: "p1", Name: "Ash Crow (Guest)", LastSeen: now}
+	accused := &Player{ID: "p2", Name: "Bram Vale (Guest)", Gold: 40, LastSeen: now}
+	stayer := &Player{ID: "p3", Name: "Cole Reed (Guest)", LastSeen: now}
+	leaver := &Player{ID: "p4", Name: "Dara Fenn (Guest)", LastSeen: now}
+	away := &Player{ID: "p5", Name: "Eda Moor (Guest)", LastSeen: now.Add(-2 * onlineWindow)}
+	for _, p := range []*Player{commander, accused, stayer, leaver, away} {
+		s.Players[p.ID] = p
+	}
+
+	issueWarrantLocked(s, now, commander.ID, commander.Name, accused)
+	cs := openCaseForLocked(s, accused.ID)
+	handleActionInputLocked(s, away, now, ActionInput{Action: "jury_vote", ContractID: cs.ID, Stance: verdictGuilty})
+	if s.rejections[away.ID] != errCodeNotAllowed || len(cs.JuryVotes) != 0 {
+		t.Fatalf("an offline player cannot be empanelled, got %q", s.rejections[away.ID])
+	}
+	handleActionInputLocked(s, stayer, now, ActionInput{Action: "jury_vote", ContractID: cs.ID, Stance: verdictGuilty})
+	handleActionInputLocked(s, leaver, now, ActionInput{Action: "jury_vote", ContractID: cs.ID, Stance: verdictGuilty})
+	runTrial(s, now)
+	if got := lastEventText(s); !strings.Contains(got, "collapses before the jury") || accused.Gold != 40 {
+		t.Fatalf("a jury cannot convict without prosecution exhibits: %q", got)
+	}
+
+	issueWarrantLocked(s, now, commander.ID, commander.Name, accused)
+	cs = openCaseForLocked(s, accused.ID)
+	addEvidenceLocked(s, commander, accused, "smuggling", 3, 5, false)
+	handleActionInputLocked(s, commander, now, ActionInput{Action: "submit_evidence", ContractID: cs.ID})
+	handleActionInputLocked(s, stayer, now, ActionInput{Action: "jury_vote", ContractID: cs.ID, Stance: verdictGuilty})
+	handleActionInputLocked(s, leaver, now, ActionInput{Action: "jury_vote", ContractID: cs.ID, Stance: verdictAcquit})
+	leaver.LastSeen = now.Add(-2 * onlineWindow)
+	runTrial(s, now)
+	if got := lastEventText(s); !strings.Contains(got, "The jury (1 of 1 for guilty) convicts") {
+		t.Fatalf("a juror who left should not count: %q", got)
+	}
+}
+
+func TestForgedEvidenceBackfiresAtTrial(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	forger := &Player{ID: "p1", Name: "Ash Crow (Guest)", Gold: 100, Rep: 20, LastSeen: now}
+	accused := &Player{ID: "p2", Name: "Bram Vale (Guest)", Gold: 100, LastSeen: now}
+	s.Players[forger.ID], s.Players[accused.ID] = forger, accused
+
+	// Seed 13 opens the intel stream with 99 at tick 16 and 7 at tick 4.
+	s.TickCount = 16
+	addEvidenceLocked(s, forger, accused, "fraud", 5, 5, true)
+	applyBountyResolutionLocked(s, forger, accused, strongestEvidenceForLocked(s, forger.ID, accused.ID), now)
+	runTrial(s, now)
+	if forger.Rep != 20+1 {
+		t.Fatalf("an unexposed forgery should convict: rep %d, %q", forger.Rep, lastEventText(s))
+	}
+
+	s.TickCount = 4
+	addEvidenceLocked(s, forger, accused, "fraud", 5, 5, true)
+	applyBountyResolutionLocked(s, forger, accused, strongestEvidenceForLocked(s, forger.ID, accused.ID), now)
+	rep, gold := forger.Rep, forger.Gold
+	runTrial(s, now)
+	if forger.Rep != rep-courtPerjuryRep-courtAcquittalRep || forger.Gold != gold-courtPerjuryFine {
+		t.Fatalf("an exposed forgery should fine the forger: rep %d -> %d, gold %d -> %d", rep, forger.Rep, gold, forger.Gold)
+	}
+	if !strings.Contains(lastEventText(s), "acquits [Bram Vale (Guest)]") {
+		t.Fatalf("an exposed forgery should sink the case: %q", lastEventText(s))
+	}
+}
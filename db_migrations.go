@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	migrationNamePattern  = regexp.MustCompile(`^(\d{3})_[a-z0-9_]+\.sql$`)
+	migrationCreateTable  = regexp.MustCompile(`(?is)CREATE TABLE IF NOT EXISTS\s+(\w+)\s*\((.*?)\);`)
+	migrationAlterAdd     = regexp.MustCompile(`(?i)ALTER TABLE\s+(\w+)\s+ADD COLUMN\s+(\w+)`)
+	migrationCreateIndex  = regexp.MustCompile(`(?i)CREATE\s+(?:UNIQUE\s+)?INDEX\s+IF NOT EXISTS\s+(\w+)\s+ON\s+(\w+)\s*\(([^)]*)\)`)
+	migrationConstraintKw = map[string]bool{"PRIMARY": true, "UNIQUE": true, "CHECK": true, "FOREIGN": true, "CONSTRAINT": true}
+)
+
+type migrationFile struct {
+	Version  string
+	Path     string
+	SQL      string
+	Checksum string
+}
+
+// listMigrations returns the numbered migrations for one dialect in apply
+// order. Names must look like 001_init.sql and numbers may not repeat.
+func listMigrations(fsys fs.FS, dialect DBDialect) ([]migrationFile, error) {
+	files, err := fs.Glob(fsys, fmt.Sprintf("migrations/%s/*.sql", dialect))
+	if err != nil {
+		return nil, fmt.Errorf("glob migrations: %w", err)
+	}
+	sort.Strings(files)
+	out := make([]migrationFile, 0, len(files))
+	seen := map[string]string{}
+	for _, file := range files {
+		base := path.Base(file)
+		m := migrationNamePattern.FindStringSubmatch(base)
+		if m == nil {
+			return nil, fmt.Errorf("migration %s: name must match NNN_description.sql", file)
+		}
+		if prev, ok := seen[m[1]]; ok {
+			return nil, fmt.Errorf("migration %s: number %s already used by %s", file, m[1], prev)
+		}
+		seen[m[1]] = base
+		sqlBytes, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", file, err)
+		}
+		out = append(out, migrationFile{Version: base, Path: file, SQL: string(sqlBytes), Checksum: migrationChecksum(sqlBytes)})
+	}
+	return out, nil
+}
+
+func migrationChecksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// checkMigrationDrift refuses a tree where the SQLite and Postgres migrations
+// disagree on file names or on the tables, columns and indexes they define.
+// Column types are free to differ between dialects.
+func checkMigrationDrift(fsys fs.FS) error {
+	sqliteFiles, err := listMigrations(fsys, dialectSQLite)
+	if err != nil {
+		return err
+	}
+	postgresFiles, err := listMigrations(fsys, dialectPostgres)
+	if err != nil {
+		return err
+	}
+
+	pgByVersion := map[string]migrationFile{}
+	for _, f := range postgresFiles {
+		pgByVersion[f.Version] = f
+	}
+	problems := []string{}
+	for _, f := range sqliteFiles {
+		pg, ok := pgByVersion[f.Version]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s exists only for sqlite", f.Version))
+			continue
+		}
+		delete(pgByVersion, f.Version)
+		lite := migrationSchemaShape(f.SQL)
+		pgShape := migrationSchemaShape(pg.SQL)
+		for _, item := range lite {
+			if !containsString(pgShape, item) {
+				problems = append(problems, fmt.Sprintf("%s: %s missing for postgres", f.Version, item))
+			}
+		}
+		for _, item := range pgShape {
+			if !containsString(lite, item) {
+				problems = append(problems, fmt.Sprintf("%s: %s missing for sqlite", f.Version, item))
+			}
+		}
+	}
+	for version := range pgByVersion {
+		problems = append(problems, fmt.Sprintf("%s exists only for postgres", version))
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("migration trees have drifted: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// migrationSchemaShape reduces a migration to sorted "table.column" and
+// "index name(columns)" entries.
+func migrationSchemaShape(sqlText string) []string {
+	shape := []string{}
+	for _, m := range migrationCreateTable.FindAllStringSubmatch(sqlText, -1) {
+		table := strings.ToLower(m[1])
+		for _, line := range strings.Split(m[2], "\n") {
+			fields := strings.Fields(strings.TrimSpace(line))
+			if len(fields) == 0 || migrationConstraintKw[strings.ToUpper(fields[0])] {
+				continue
+			}
+			shape = append(shape, table+"."+strings.ToLower(fields[0]))
+		}
+	}
+	for _, m := range migrationAlterAdd.FindAllStringSubmatch(sqlText, -1) {
+		shape = append(shape, strings.ToLower(m[1])+"."+strings.ToLower(m[2]))
+	}
+	for _, m := range migrationCreateIndex.FindAllStringSubmatch(sqlText, -1) {
+		cols := strings.ReplaceAll(strings.ToLower(m[3]), " ", "")
+		shape = append(shape, fmt.Sprintf("index %s on %s(%s)", strings.ToLower(m[1]), strings.ToLower(m[2]), cols))
+	}
+	sort.Strings(shape)
+	return shape
+}
+
+func containsString(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *SQLRepository) applyMigrations(ctx context.Context) error {
+	return r.applyMigrationsFrom(ctx, migrationFS)
+}
+
+// applyMigrationsFrom applies every pending migration in its own transaction
+// together with its schema_migrations row. Already-applied migrations must
+// still match their recorded checksum.
+func (r *SQLRepository) applyMigrationsFrom(ctx context.Context, fsys fs.FS) error {
+	create := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version TEXT PRIMARY KEY,
+			checksum TEXT,
+			applied_at TIMESTAMP NOT NULL
+		)
+	`
+	if _, err := r.db.ExecContext(ctx, create); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	// Ledgers created before checksums were tracked lack the column.
+	if _, err := r.db.ExecContext(ctx, "SELECT checksum FROM schema_migrations WHERE 1 = 0"); err != nil {
+		if _, err := r.db.ExecContext(ctx, "ALTER TABLE schema_migrations ADD COLUMN checksum TEXT"); err != nil {
+			return fmt.Errorf("add schema_migrations checksum: %w", err)
+		}
+	}
+
+	applied := map[string]string{}
+	rows, err := r.db.QueryContext(ctx, "SELECT version, COALESCE(checksum, '') FROM schema_migrations")
+	if err != nil {
+		return fmt.Errorf("read schema_migrations: %w", err)
+	}
+	for rows.Next() {
+		var v, sum string
+		if err := rows.Scan(&v, &sum); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan schema migration: %w", err)
+		}
+		applied[v] = sum
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("iterate schema migrations: %w", err)
+	}
+	rows.Close()
+
+	files, err := listMigrations(fsys, r.dialect)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if sum, ok := applied[file.Version]; ok {
+			switch sum {
+			case file.Checksum:
+			case "":
+				q := fmt.Sprintf("UPDATE schema_migrations SET checksum = %s WHERE version = %s", r.bind(1), r.bind(2))
+				if _, err := r.db.ExecContext(ctx, q, file.Checksum, file.Version); err != nil {
+					return fmt.Errorf("backfill checksum %s: %w", file.Version, err)
+				}
+			default:
+				return fmt.Errorf("migration %s was modified after it was applied (checksum %s, recorded %s)", file.Path, shortID(file.Checksum), shortID(sum))
+			}
+			continue
+		}
+		tx, err := r.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("begin migration tx %s: %w", file.Path, err)
+		}
+		if _, err := tx.ExecContext(ctx, file.SQL); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("apply migration %s: %w", file.Path, err)
+		}
+		q := r.insertQuery("schema_migrations", []string{"version", "checksum", "applied_at"})
+		if _, err := tx.ExecContext(ctx, q, file.Version, file.Checksum, time.Now().UTC()); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("record migration %s: %w", file.Path, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit migration %s: %w", file.Path, err)
+		}
+	}
+	return nil
+}
+
+// payloadUpgrader rewrites a decoded JSON payload from Version-1 to Version.
+type payloadUpgrader struct {
+	Version int
+	Upgrade func(doc map[string]any) error
+}
+
+// Payload upgraders run on load, oldest first, for rows whose payload_version
+// is behind. Append a step whenever Player or Contract gains a field that
+// needs a non-zero default.
+var playerPayloadUpgraders = []payloadUpgrader{
+	{Version: 2, Upgrade: func(doc map[string]any) error {
+		if loc, _ := doc["LocationID"].(string); loc == "" {
+			doc["LocationID"] = locationCapital
+		}
+		return nil
+	}},
+	// v3 folds the court's jail and exile counters into Statuses.
+	{Version: 3, Upgrade: func(doc map[string]any) error {
+		var statuses []any
+		if ticks, _ := doc["JailTicksLeft"].(float64); ticks > 0 {
+			statuses = append(statuses, map[string]any{"Kind": statusJailed, "TicksLeft": ticks, "Reason": "court sentence"})
+		}
+		if ticks, _ := doc["ExileTicksLeft"].(float64); ticks > 0 {
+			from, _ := doc["ExiledFrom"].(string)
+			statuses = append(statuses, map[string]any{"Kind": statusExiled, "LocationID": from, "TicksLeft": ticks, "Reason": "court sentence"})
+		}
+		delete(doc, "JailTicksLeft")
+		delete(doc, "ExiledFrom")
+		delete(doc, "ExileTicksLeft")
+		if len(statuses) > 0 {
+			doc["Statuses"] = statuses
+		}
+		return nil
+	}},
+}
+
+var contractPayloadUpgraders = []payloadUpgrader{
+	{Version: 2, Upgrade: func(doc map[string]any) error {
+		status, _ := doc["Status"].(string)
+		if stance, _ := doc["Stance"].(string); stance == "" && status == "Accepted" {
+			doc["Stance"] = contractStanceCareful
+		}
+		return nil
+	}},
+}
+
+func currentPayloadVersion(steps []payloadUpgrader) int {
+	version := 1
+	for _, step := range steps {
+		if step.Version > version {
+			version = step.Version
+		}
+	}
+	return version
+}
+
+// upgradePayload applies every step newer than from and returns the new JSON.
+func upgradePayload(payload string, from int, steps []payloadUpgrader) (string, error) {
+	doc := map[string]any{}
+	if err := json.Unmarshal([]byte(payload), &doc); err != nil {
+		return "", err
+	}
+	for _, step := range steps {
+		if step.Version <= from {
+			continue
+		}
+		if err := step.Upgrade(doc); err != nil {
+			return "", fmt.Errorf("upgrade to v%d: %w", step.Version, err)
+		}
+	}
+	return asJSON(doc), nil
+}
+
+// loadVersionedRows streams payloads like loadMapRows but first upgrades rows
+// written by older builds, then rewrites those rows in place so the upgrade
+// runs once.
+func (r *SQLRepository) loadVersionedRows(ctx context.Context, table, keyCol string, steps []payloadUpgrader, fn func(payload string) error) error {
+	current := currentPayloadVersion(steps)
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf("SELECT %s, payload_version, payload FROM %s", keyCol, table))
+	if err != nil {
+		return err
+	}
+	type rewrite struct {
+		key     string
+		payload string
+	}
+	rewrites := []rewrite{}
+	for rows.Next() {
+		var key, payload string
+		var version int
+		if err := rows.Scan(&key, &version, &payload); err != nil {
+			rows.Close()
+			return err
+		}
+		if version < current {
+			upgraded, err := upgradePayload(payload, version, steps)
+			if err != nil {
+				rows.Close()
+				return fmt.Errorf("%s %s: %w", table, key, err)
+			}
+			payload = upgraded
+			rewrites = append(rewrites, rewrite{key: key, payload: payload})
+		}
+		if err := fn(payload); err != nil {
+			rows.Close()
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+	if len(rewrites) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin upgrade tx: %w", err)
+	}
+	q := fmt.Sprintf("UPDATE %s SET payload = %s, payload_version = %s WHERE %s = %s", table, r.bind(1), r.bind(2), keyCol, r.bind(3))
+	for _, rw := range rewrites {
+		if _, err := tx.ExecContext(ctx, q, rw.payload, current, rw.key); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("rewrite upgraded payload: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit upgrade tx: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestMigrationTreesAreAligned(t *testing.T) {
+	if err := checkMigrationDrift(migrationFS); err != nil {
+		t.Fatalf("embedded migrations drifted: %v", err)
+	}
+}
+
+func TestCheckMigrationDriftReportsDifferences(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/sqlite/001_init.sql":     {Data: []byte("CREATE TABLE IF NOT EXISTS things (\n    id INTEGER PRIMARY KEY,\n    name TEXT NOT NULL\n);\n")},
+		"migrations/postgres/001_init.sql":   {Data: []byte("CREATE TABLE IF NOT EXISTS things (\n    id BIGINT PRIMARY KEY\n);\n")},
+		"migrations/postgres/002_extra.sql":  {Data: []byte("ALTER TABLE things ADD COLUMN extra TEXT;\n")},
+		"migrations/sqlite/notes.sql":        {Data: []byte("-- stray")},
+		"migrations/postgres/003_second.sql": {Data: []byte("")},
+	}
+	err := checkMigrationDrift(fsys)
+	if err == nil || !strings.Contains(err.Error(), "NNN_description.sql") {
+		t.Fatalf("expected bad file name error, got %v", err)
+	}
+
+	delete(fsys, "migrations/sqlite/notes.sql")
+	err = checkMigrationDrift(fsys)
+	if err == nil {
+		t.Fatalf("expected drift error")
+	}
+	for _, want := range []string{"things.name missing for postgres", "002_extra.sql exists only for postgres", "003_second.sql exists only for postgres"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("drift error missing %q: %v", want, err)
+		}
+	}
+}
+
+func TestApplyMigrationsRecordsChecksumsAndDetectsEdits(t *testing.T) {
+	t.Setenv("DB_DIALECT", "sqlite")
+	t.Setenv("DB_SQLITE_PATH", filepath.Join(t.TempDir(), "migrations.sqlite"))
+
+	repo, err := openRepositoryFromEnv()
+	if err != nil {
+		t.Fatalf("openRepositoryFromEnv error: %v", err)
+	}
+	defer repo.db.Close()
+	ctx := context.Background()
+
+	files, err := listMigrations(migrationFS, dialectSQLite)
+	if err != nil {
+		t.Fatalf("listMigrations error: %v", err)
+	}
+	var recorded int
+	if err := repo.db.QueryRowContext(ctx, "SELECT COUNT(1) FROM schema_migrations WHERE checksum IS NOT NULL").Scan(&recorded); err != nil {
+		t.Fatalf("count ledger: %v", err)
+	}
+	if recorded != len(files) {
+		t.Fatalf("expected %d checksummed migrations, got %d", len(files), recorded)
+	}
+
+	if err := repo.applyMigrations(ctx); err != nil {
+		t.Fatalf("re-running migrations should be a no-op, got %v", err)
+	}
+
+	if _, err := repo.db.ExecContext(ctx, "UPDATE schema_migrations SET checksum = 'tampered' WHERE version = '001_init.sql'"); err != nil {
+		t.Fatalf("tamper ledger: %v", err)
+	}
+	if err := repo.applyMigrations(ctx); err == nil || !strings.Contains(err.Error(), "modified after it was applied") {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+
+	if _, err := repo.db.ExecContext(ctx, "UPDATE schema_migrations SET checksum = NULL"); err != nil {
+		t.Fatalf("clear checksums: %v", err)
+	}
+	if err := repo.applyMigrations(ctx); err != nil {
+		t.Fatalf("legacy ledger rows should be backfilled, got %v", err)
+	}
+}
+
+func TestLoadIntoUpgradesOlderPayloads(t *testing.T) {
+	t.Setenv("DB_DIALECT", "sqlite")
+	t.Setenv("DB_SQLITE_PATH", filepath.Join(t.TempDir(), "upgrade.sqlite"))
+
+	repo, err := openRepositoryFromEnv()
+	if err != nil {
+		t.Fatalf("openRepositoryFromEnv error: %v", err)
+	}
+	defer repo.db.Close()
+	ctx := context.Background()
+
+	if err := repo.Save(ctx, newStore()); err != nil {
+		t.Fatalf("seed save error: %v", err)
+	}
+	now := time.Date(2026, 2, 12, 12, 0, 0, 0, time.UTC)
+	if _, err := repo.db.ExecContext(ctx,
+		"INSERT INTO players (player_id, last_seen, payload, payload_version, created_at, updated_at) VALUES (?, ?, ?, 1, ?, ?)",
+		"old", now, `{"ID":"old","Name":"Old Timer","Gold":9}`, now, now,
+	); err != nil {
+		t.Fatalf("insert legacy player: %v", err)
+	}
+	if _, err := repo.db.ExecContext(ctx,
+		"INSERT INTO players (player_id, last_seen, payload, payload_version, created_at, updated_at) VALUES (?, ?, ?, 2, ?, ?)",
+		"jailed", now, `{"ID":"jailed","Name":"Jail Bird","LocationID":"capital","JailTicksLeft":2,"ExiledFrom":"capital","ExileTicksLeft":5}`, now, now,
+	); err != nil {
+		t.Fatalf("insert legacy jailed player: %v", err)
+	}
+	if _, err := repo.db.ExecContext(ctx,
+		"INSERT INTO contracts (contract_id, status, owner_player_id, issued_at_tick, deadline_ticks, payload, payload_version, created_at, updated_at) VALUES (?, 'Accepted', 'old', 0, 2, ?, 1, ?, ?)",
+		"c-old", `{"ID":"c-old","Type":"Emergency","Status":"Accepted","OwnerPlayerID":"old","DeadlineTicks":2}`, now, now,
+	); err != nil {
+		t.Fatalf("insert legacy contract: %v", err)
+	}
+
+	s := newStore()
+	if err := repo.LoadInto(ctx, s); err != nil {
+		t.Fatalf("LoadInto error: %v", err)
+	}
+	if got := s.Players["old"]; got == nil || got.LocationID != locationCapital || got.Gold != 9 {
+		t.Fatalf("expected upgraded player, got %+v", got)
+	}
+	if got := s.Players["jailed"]; got == nil || statusFor(got, statusJailed).TicksLeft != 2 || statusFor(got, statusExiled).LocationID != locationCapital {
+		t.Fatalf("expected jail and exile carried into statuses, got %+v", got)
+	}
+	if got := s.Contracts["c-old"]; got == nil || got.Stance != contractStanceCareful {
+		t.Fatalf("expected upgraded contract stance, got %+v", got)
+	}
+
+	var version int
+	var payload string
+	if err := repo.db.QueryRowContext(ctx, "SELECT payload_version, payload FROM players WHERE player_id = 'old'").Scan(&version, &payload); err != nil {
+		t.Fatalf("read upgraded player: %v", err)
+	}
+	if version != currentPayloadVersion(playerPayloadUpgraders) || !strings.Contains(payload, `"LocationID":"capital"`) {
+		t.Fatalf("upgraded player row not rewritten: version=%d payload=%s", version, payload)
+	}
+}
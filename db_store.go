@@ -7,12 +7,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
@@ -29,9 +30,44 @@ const (
 	dialectPostgres DBDialect = "postgres"
 )
 
+// lastSeenPersistGranularity bounds how stale a persisted LastSeen may be.
+// Finer movements are kept in the presence table only, so polling does not
+// write; see mergePresenceLocked.
+const lastSeenPersistGranularity = 30 * time.Second
+
 type SQLRepository struct {
 	dialect DBDialect
 	db      *sql.DB
+
+	// saved mirrors the rows committed by the last Save or LoadInto, keyed by
+	// table then primary key, so Save only writes what changed.
+	saved map[string]map[string]persistedRow
+	// savedView is the view those rows were rendered from, and unsaved is
+	// what views have changed since. Save compares only those entities of the
+	// next view against it and encodes the ones that differ.
+	savedView *Store
+	unsaved   dirtySet
+	lastSave  persistStats
+	// commits counts save transactions that reached the database.
+	commits int
+
+	// saveMu serializes saves. It is never taken while store.mu is held:
+	// the writer takes a batch's view and journal under the store lock and
+	// saves them after releasing it, one batch after another.
+	saveMu sync.Mutex
+	// retry holds the journal and snapshots of a batch whose commit failed;
+	// the next batch writes them ahead of anything newer.
+	retry *saveBatch
+}
+
+// saveBatch is one save's worth of writes, rendered from a view so the SQL
+// can run without the store lock.
+type saveBatch struct {
+	view      *Store
+	upserts   []persistRow
+	deletes   []persistRow
+	journal   []JournalEntry
+	snapshots []snapshotRow
 }
 
 type runtimeState struct {
@@ -47,6 +83,15 @@ type runtimeState struct {
 	NextObligationID int64
 	NextProjectID    int64
 	NextRelicID      int64
+	NextOrderID      int64
+	NextCaravanID    int64
+	NextBuildingID   int64
+	NextCaseID       int64
+	NextClueID       int64
+	NextFightID      int64
+	NextTreasuryID   int64
+	NextJournalID    int64
+	NextSnapshotID   int64
 
 	LastDailyTickDate string
 	LastTickAt        time.Time
@@ -64,6 +109,7 @@ type runtimeState struct {
 	DailyActionDate   map[string]string
 	DailyHighImpactN  map[string]int
 	LastCleanupDate   string
+	RNG               *worldRNG
 }
 
 func newConfiguredStore() (*Store, error) {
@@ -129,6 +175,10 @@ func openRepositoryFromEnv() (*SQLRepository, error) {
 		return nil, fmt.Errorf("ping %s database: %w", dialect, err)
 	}
 
+	if err := checkMigrationDrift(migrationFS); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
 	repo := &SQLRepository{dialect: dialect, db: db}
 	if err := repo.applyMigrations(ctx); err != nil {
 		_ = db.Close()
@@ -158,86 +208,131 @@ func (r *SQLRepository) insertQuery(table string, cols []string) string {
 	)
 }
 
-func (r *SQLRepository) applyMigrations(ctx context.Context) error {
-	create := `
-		CREATE TABLE IF NOT EXISTS schema_migrations (
-			version TEXT PRIMARY KEY,
-			applied_at TIMESTAMP NOT NULL
-		)
-	`
-	if _, err := r.db.ExecContext(ctx, create); err != nil {
-		return fmt.Errorf("create schema_migrations: %w", err)
+// persistLocked saves the store synchronously. The writer uses
+// beginSaveLocked instead so the SQL runs after the lock is released.
+func (store *Store) persistLocked() {
+	if store.repo == nil {
+		return
 	}
-
-	applied := map[string]bool{}
-	rows, err := r.db.QueryContext(ctx, "SELECT version FROM schema_migrations")
-	if err != nil {
-		return fmt.Errorf("read schema_migrations: %w", err)
+	if err := store.repo.Save(context.Background(), store); err != nil {
+		log.Printf("persist state failed: %v", err)
 	}
-	for rows.Next() {
-		var v string
-		if err := rows.Scan(&v); err != nil {
-			rows.Close()
-			return fmt.Errorf("scan schema migration: %w", err)
+}
+
+// beginSaveLocked takes the pending journal and snapshots while the store
+// lock is held and returns the function that saves them along with view, to
+// be called once the lock is gone.
+func (store *Store) beginSaveLocked(view *Store) func() {
+	r := store.repo
+	if r == nil {
+		return func() {}
+	}
+	journal, snapshots := takeJournalPendingLocked(store)
+	return func() {
+		r.saveMu.Lock()
+		defer r.saveMu.Unlock()
+		if err := r.saveView(context.Background(), view, journal, snapshots); err != nil {
+			log.Printf("persist state failed: %v", err)
 		}
-		applied[v] = true
 	}
-	if err := rows.Err(); err != nil {
-		rows.Close()
-		return fmt.Errorf("iterate schema migrations: %w", err)
-	}
-	rows.Close()
+}
 
-	pattern := fmt.Sprintf("migrations/%s/*.sql", r.dialect)
-	files, err := fs.Glob(migrationFS, pattern)
-	if err != nil {
-		return fmt.Errorf("glob migrations: %w", err)
+// Save writes the store as it stands. The caller must have it to itself.
+func (r *SQLRepository) Save(ctx context.Context, store *Store) error {
+	view := viewOfLocked(store)
+	journal, snapshots := takeJournalPendingLocked(store)
+	r.saveMu.Lock()
+	defer r.saveMu.Unlock()
+	return r.saveView(ctx, view, journal, snapshots)
+}
+
+func takeJournalPendingLocked(store *Store) ([]JournalEntry, []snapshotRow) {
+	journal, snapshots := store.journalPending, store.snapshotsPending
+	store.journalPending, store.snapshotsPending = nil, nil
+	return journal, snapshots
+}
+
+// saveView writes view and the journal taken with it. It needs saveMu but
+// not the store lock: view is a copy nobody writes.
+func (r *SQLRepository) saveView(ctx context.Context, view *Store, journal []JournalEntry, snapshots []snapshotRow) error {
+	batch, err := r.prepareSave(ctx, view, journal, snapshots)
+	if err != nil || batch == nil {
+		return err
 	}
-	sort.Strings(files)
-	for _, file := range files {
-		base := filepath.Base(file)
-		if applied[base] {
-			continue
-		}
-		sqlBytes, err := migrationFS.ReadFile(file)
-		if err != nil {
-			return fmt.Errorf("read migration %s: %w", file, err)
-		}
-		tx, err := r.db.BeginTx(ctx, nil)
-		if err != nil {
-			return fmt.Errorf("begin migration tx %s: %w", file, err)
-		}
-		if _, err := tx.ExecContext(ctx, string(sqlBytes)); err != nil {
-			_ = tx.Rollback()
-			return fmt.Errorf("apply migration %s: %w", file, err)
-		}
-		q := r.insertQuery("schema_migrations", []string{"version", "applied_at"})
-		if _, err := tx.ExecContext(ctx, q, base, time.Now().UTC()); err != nil {
-			_ = tx.Rollback()
-			return fmt.Errorf("record migration %s: %w", file, err)
-		}
-		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("commit migration %s: %w", file, err)
+	return r.commitSave(ctx, batch)
+}
+
+// prepareSave diffs view against the last committed rows and returns nil
+// when there is nothing to write.
+func (r *SQLRepository) prepareSave(ctx context.Context, view *Store, journal []JournalEntry, snapshots []snapshotRow) (*saveBatch, error) {
+	if r.retry != nil {
+		journal = append(r.retry.journal, journal...)
+		snapshots = append(r.retry.snapshots, snapshots...)
+		r.retry = nil
+	}
+	if r.saved == nil {
+		if err := r.loadPersistedKeys(ctx); err != nil {
+			r.retry = &saveBatch{journal: journal, snapshots: snapshots}
+			return nil, err
 		}
 	}
-	return nil
+
+	if r.savedView == nil {
+		r.unsaved.markAll()
+	} else {
+		r.unsaved.merge(view.changes)
+	}
+	rows, keys := collectPersistRows(r.savedView, view, &r.unsaved, time.Now().UTC())
+	upserts, deletes := r.diffPersistRows(rows, keys, &r.unsaved)
+	r.lastSave = persistStats{Upserts: len(upserts), Deletes: len(deletes)}
+	batch := &saveBatch{view: view, upserts: upserts, deletes: deletes, journal: journal, snapshots: snapshots}
+	if len(batch.upserts) == 0 && len(batch.deletes) == 0 && len(batch.journal) == 0 && len(batch.snapshots) == 0 {
+		r.savedView, r.unsaved = view, dirtySet{}
+		return nil, nil
+	}
+	return batch, nil
 }
 
-func (store *Store) persistLocked() {
-	if store.repo == nil {
-		return
+// commitSave writes a batch in one transaction. It needs saveMu but not the
+// store lock.
+func (r *SQLRepository) commitSave(ctx context.Context, batch *saveBatch) error {
+	if err := r.execSave(ctx, batch); err != nil {
+		r.retry = batch
+		return err
 	}
-	if err := store.repo.Save(context.Background(), store); err != nil {
-		log.Printf("persist state failed: %v", err)
+	r.commits++
+
+	// Only advance the saved snapshot once the rows are durable, so a failed
+	// commit is retried in full on the next save.
+	for _, row := range batch.upserts {
+		r.saved[row.Table][fmt.Sprint(row.Key)] = persistedRow{KeyCol: row.KeyCol, Key: row.Key}
+	}
+	for _, row := range batch.deletes {
+		delete(r.saved[row.Table], fmt.Sprint(row.Key))
 	}
+	r.savedView, r.unsaved = batch.view, dirtySet{}
+	return nil
 }
 
-func (r *SQLRepository) Save(ctx context.Context, store *Store) error {
+func (r *SQLRepository) execSave(ctx context.Context, batch *saveBatch) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("begin save tx: %w", err)
 	}
-	if err := r.saveWithTx(ctx, tx, store); err != nil {
+	for _, row := range batch.upserts {
+		if _, err := tx.ExecContext(ctx, r.upsertQuery(row.Table, row.KeyCol, row.Cols), row.Vals...); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("upsert %s: %w", row.Table, err)
+		}
+	}
+	for _, row := range batch.deletes {
+		q := fmt.Sprintf("DELETE FROM %s WHERE %s = %s", row.Table, row.KeyCol, r.bind(1))
+		if _, err := tx.ExecContext(ctx, q, row.Key); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("delete %s: %w", row.Table, err)
+		}
+	}
+	if err := r.flushJournalWithTx(ctx, tx, batch.journal, batch.snapshots); err != nil {
 		_ = tx.Rollback()
 		return err
 	}
@@ -247,196 +342,444 @@ func (r *SQLRepository) Save(ctx context.Context, store *Store) error {
 	return nil
 }
 
-func (r *SQLRepository) saveWithTx(ctx context.Context, tx *sql.Tx, store *Store) error {
-	clearTables := []string{
-		"world_state", "policy_state", "runtime_state", "players", "institutions", "seats", "contracts",
-		"permits", "warrants", "rumors", "evidence", "scry_reports", "intercepts", "loans",
-		"obligations", "projects", "active_crisis", "relics", "events", "chat_messages", "diplomatic_messages",
-	}
-	for _, tbl := range clearTables {
-		if _, err := tx.ExecContext(ctx, "DELETE FROM "+tbl); err != nil {
-			return fmt.Errorf("clear %s: %w", tbl, err)
-		}
-	}
+// persistTables lists every table written by Save along with its primary key
+// column and the Store field whose dirty marks say which rows a save must
+// revisit; tables without one are always revisited. Upserts run in this
+// order and deletes follow them.
+var persistTables = []struct {
+	Name   string
+	KeyCol string
+	Field  string
+}{
+	{"world_state", "id", ""},
+	{"policy_state", "id", ""},
+	{"runtime_state", "id", ""},
+	{"players", "player_id", dirtyPlayers},
+	{"institutions", "id", dirtyInstitutions},
+	{"seats", "id", dirtySeats},
+	{"contracts", "contract_id", dirtyContracts},
+	{"permits", "player_id", dirtyPermits},
+	{"warrants", "player_id", dirtyWarrants},
+	{"rumors", "id", dirtyRumors},
+	{"evidence", "id", dirtyEvidence},
+	{"scry_reports", "id", dirtyScryReports},
+	{"intercepts", "id", dirtyIntercepts},
+	{"loans", "id", dirtyLoans},
+	{"obligations", "id", dirtyObligations},
+	{"projects", "id", dirtyProjects},
+	{"active_crisis", "id", ""},
+	{"relics", "id", dirtyRelics},
+	{"market_orders", "id", dirtyOrders},
+	{"caravans", "id", dirtyCaravans},
+	{"buildings", "id", dirtyBuildings},
+	{"court_cases", "id", dirtyCases},
+	{"theft_clues", "id", dirtyClues},
+	{"fights", "id", dirtyFights},
+	{"events", "id", dirtyEvents},
+	{"treasury_ledger", "id", dirtyTreasuryLedger},
+	{"chat_messages", "id", dirtyChat},
+	{"diplomatic_messages", "id", dirtyMessages},
+	{"market_history", "tick", dirtyMarketHistory},
+}
+
+// persistRow is the desired state of one table row.
+type persistRow struct {
+	Table  string
+	KeyCol string
+	Key    any
+	Cols   []string
+	Vals   []any
+}
 
-	now := time.Now().UTC()
+// persistedRow is a key known to be in the database.
+type persistedRow struct {
+	KeyCol string
+	Key    any
+}
 
-	if err := r.insertJSONRow(ctx, tx, "world_state", []string{"id", "payload", "updated_at"}, []any{1, asJSON(store.World), now}); err != nil {
-		return err
+type persistStats struct {
+	Upserts int
+	Deletes int
+}
+
+func newPersistRow(table, keyCol string, cols []string, vals []any) persistRow {
+	return persistRow{Table: table, KeyCol: keyCol, Key: vals[0], Cols: cols, Vals: vals}
+}
+
+// persistKeys is the set of rows a view should hold in the database, keyed
+// by table then fmt.Sprint of the primary key.
+type persistKeys map[string]map[string]persistedRow
+
+func (k persistKeys) add(table, keyCol string, key any) {
+	if k[table] == nil {
+		k[table] = map[string]persistedRow{}
 	}
-	if err := r.insertJSONRow(ctx, tx, "policy_state", []string{"id", "payload", "updated_at"}, []any{1, asJSON(store.Policies), now}); err != nil {
-		return err
+	k[table][fmt.Sprint(key)] = persistedRow{KeyCol: keyCol, Key: key}
+}
+
+func indexByID[T any, K comparable](items []T, id func(T) K) map[K]T {
+	out := make(map[K]T, len(items))
+	for _, item := range items {
+		out[id(item)] = item
 	}
+	return out
+}
 
-	runtime := runtimeState{
-		NextEventID:       store.NextEventID,
-		NextContractID:    store.NextContractID,
-		NextChatID:        store.NextChatID,
-		NextMessageID:     store.NextMessageID,
-		NextRumorID:       store.NextRumorID,
-		NextEvidenceID:    store.NextEvidenceID,
-		NextScryID:        store.NextScryID,
-		NextInterceptID:   store.NextInterceptID,
-		NextLoanID:        store.NextLoanID,
-		NextObligationID:  store.NextObligationID,
-		NextProjectID:     store.NextProjectID,
-		NextRelicID:       store.NextRelicID,
-		LastDailyTickDate: store.LastDailyTickDate,
-		LastTickAt:        store.LastTickAt,
-		TickEveryNanos:    int64(store.TickEvery),
-		TickCount:         store.TickCount,
-		LastChatAt:        store.LastChatAt,
-		LastMessageAt:     store.LastMessageAt,
-		LastActionAt:      store.LastActionAt,
-		LastDeliverAt:     store.LastDeliverAt,
-		LastInvestigateAt: store.LastInvestigateAt,
-		LastSeatActionAt:  store.LastSeatActionAt,
-		LastIntelActionAt: store.LastIntelActionAt,
-		LastFieldworkAt:   store.LastFieldworkAt,
-		DailyActionDate:   store.DailyActionDate,
-		DailyHighImpactN:  store.DailyHighImpactN,
-		LastCleanupDate:   store.LastCleanupDate,
+// collectPersistRows renders the rows of every entity in changes that
+// differs from its copy in prev, the view last committed, and lists which of
+// those keys store should hold. Unchanged entities are compared but never
+// encoded, and unmarked ones are skipped. A nil prev renders everything and
+// nil changes revisits everything. It does not touch the database.
+func collectPersistRows(prev, store *Store, changes *dirtySet, now time.Time) ([]persistRow, persistKeys) {
+	if prev == nil {
+		prev = &Store{}
+	}
+	rows := []persistRow{}
+	keys := persistKeys{}
+
+	keys.add("world_state", "id", 1)
+	if !reflect.DeepEqual(prev.World, store.World) {
+		rows = append(rows, newPersistRow("world_state", "id", []string{"id", "payload", "updated_at"}, []any{1, asJSON(store.World), now}))
 	}
-	if err := r.insertJSONRow(ctx, tx, "runtime_state", []string{"id", "payload", "updated_at"}, []any{1, asJSON(runtime), now}); err != nil {
-		return err
+	keys.add("policy_state", "id", 1)
+	if !reflect.DeepEqual(prev.Policies, store.Policies) {
+		rows = append(rows, newPersistRow("policy_state", "id", []string{"id", "payload", "updated_at"}, []any{1, asJSON(store.Policies), now}))
+	}
+	keys.add("runtime_state", "id", 1)
+	if runtime := runtimeStateFromStore(store); !reflect.DeepEqual(runtimeStateFromStore(prev), runtime) {
+		rows = append(rows, newPersistRow("runtime_state", "id", []string{"id", "payload", "updated_at"}, []any{1, asJSON(runtime), now}))
 	}
 
-	for _, p := range store.Players {
-		if err := r.insertJSONRow(ctx, tx, "players",
-			[]string{"player_id", "last_seen", "payload", "created_at", "updated_at", "soft_deleted_at", "hard_deleted_at"},
-			[]any{p.ID, p.LastSeen, asJSON(p), p.LastSeen, now, p.SoftDeletedAt, p.HardDeletedAt},
-		); err != nil {
-			return err
+	for _, p := range changedEntries(store.Players, changes, dirtyPlayers) {
+		keys.add("players", "player_id", p.ID)
+		if reflect.DeepEqual(prev.Players[p.ID], p) {
+			continue
 		}
-	}
-	for _, inst := range store.Institutions {
-		if err := r.insertJSONRow(ctx, tx, "institutions", []string{"id", "payload", "created_at", "updated_at"}, []any{inst.ID, asJSON(inst), now, now}); err != nil {
-			return err
+		rows = append(rows, newPersistRow("players",
+			"player_id",
+			[]string{"player_id", "last_seen", "payload", "payload_version", "created_at", "updated_at", "soft_deleted_at", "hard_deleted_at"},
+			[]any{p.ID, p.LastSeen, asJSON(p), currentPayloadVersion(playerPayloadUpgraders), p.LastSeen, now, p.SoftDeletedAt, p.HardDeletedAt},
+		))
+	}
+	for _, inst := range changedEntries(store.Institutions, changes, dirtyInstitutions) {
+		keys.add("institutions", "id", inst.ID)
+		if !reflect.DeepEqual(prev.Institutions[inst.ID], inst) {
+			rows = append(rows, newPersistRow("institutions", "id", []string{"id", "payload", "created_at", "updated_at"}, []any{inst.ID, asJSON(inst), now, now}))
 		}
 	}
-	for _, seat := range store.Seats {
-		if err := r.insertJSONRow(ctx, tx, "seats", []string{"id", "payload", "created_at", "updated_at"}, []any{seat.ID, asJSON(seat), now, now}); err != nil {
-			return err
+	for _, seat := range changedEntries(store.Seats, changes, dirtySeats) {
+		keys.add("seats", "id", seat.ID)
+		if !reflect.DeepEqual(prev.Seats[seat.ID], seat) {
+			rows = append(rows, newPersistRow("seats", "id", []string{"id", "payload", "created_at", "updated_at"}, []any{seat.ID, asJSON(seat), now, now}))
 		}
 	}
-	for _, c := range store.Contracts {
+	for _, c := range changedEntries(store.Contracts, changes, dirtyContracts) {
 		if c.Status != "Issued" && c.Status != "Accepted" {
 			continue
 		}
-		if err := r.insertJSONRow(ctx, tx, "contracts",
-			[]string{"contract_id", "status", "owner_player_id", "issued_at_tick", "deadline_ticks", "payload", "created_at", "updated_at", "terminal_at"},
-			[]any{c.ID, c.Status, c.OwnerPlayerID, c.IssuedAtTick, c.DeadlineTicks, asJSON(c), now, now, nil},
-		); err != nil {
-			return err
+		keys.add("contracts", "contract_id", c.ID)
+		if reflect.DeepEqual(prev.Contracts[c.ID], c) {
+			continue
+		}
+		rows = append(rows, newPersistRow("contracts",
+			"contract_id",
+			[]string{"contract_id", "status", "owner_player_id", "issued_at_tick", "deadline_ticks", "payload", "payload_version", "created_at", "updated_at", "terminal_at"},
+			[]any{c.ID, c.Status, c.OwnerPlayerID, c.IssuedAtTick, c.DeadlineTicks, asJSON(c), currentPayloadVersion(contractPayloadUpgraders), now, now, nil},
+		))
+	}
+	// Permit, warrant and rumor rows carry an expiry computed from the tick,
+	// so they are rewritten whenever the tick moves.
+	tickMoved := prev.TickCount != store.TickCount
+	permits, warrants, rumors := changedEntries(store.Permits, changes, dirtyPermits), changedEntries(store.Warrants, changes, dirtyWarrants), changedEntries(store.Rumors, changes, dirtyRumors)
+	if tickMoved {
+		permits, warrants, rumors = store.Permits, store.Warrants, store.Rumors
+	}
+	for _, permit := range permits {
+		keys.add("permits", "player_id", permit.PlayerID)
+		if !tickMoved && reflect.DeepEqual(prev.Permits[permit.PlayerID], permit) {
+			continue
 		}
-	}
-	for _, permit := range store.Permits {
 		expires := store.TickCount + int64(maxInt(0, permit.TicksLeft))
-		if err := r.insertJSONRow(ctx, tx, "permits",
+		rows = append(rows, newPersistRow("permits",
+			"player_id",
 			[]string{"player_id", "expires_tick", "payload", "created_at", "updated_at"},
 			[]any{permit.PlayerID, expires, asJSON(permit), now, now},
-		); err != nil {
-			return err
-		}
+		))
 	}
-	for _, warrant := range store.Warrants {
+	for _, warrant := range warrants {
+		keys.add("warrants", "player_id", warrant.PlayerID)
+		if !tickMoved && reflect.DeepEqual(prev.Warrants[warrant.PlayerID], warrant) {
+			continue
+		}
 		expires := store.TickCount + int64(maxInt(0, warrant.TicksLeft))
-		if err := r.insertJSONRow(ctx, tx, "warrants",
+		rows = append(rows, newPersistRow("warrants",
+			"player_id",
 			[]string{"player_id", "expires_tick", "payload", "created_at", "updated_at"},
 			[]any{warrant.PlayerID, expires, asJSON(warrant), now, now},
-		); err != nil {
-			return err
-		}
+		))
 	}
-	for _, rumor := range store.Rumors {
-		expires := store.TickCount + int64(maxInt(0, rumor.Decay))
-		if err := r.insertJSONRow(ctx, tx, "rumors", []string{"id", "expires_tick", "payload", "created_at", "updated_at"}, []any{rumor.ID, expires, asJSON(rumor), now, now}); err != nil {
-			return err
+	for _, rumor := range rumors {
+		keys.add("rumors", "id", rumor.ID)
+		if !tickMoved && reflect.DeepEqual(prev.Rumors[rumor.ID], rumor) {
+			continue
 		}
+		expires := store.TickCount + int64(maxInt(0, rumor.Decay))
+		rows = append(rows, newPersistRow("rumors", "id", []string{"id", "expires_tick", "payload", "created_at", "updated_at"}, []any{rumor.ID, expires, asJSON(rumor), now, now}))
 	}
-	for _, ev := range store.Evidence {
-		if err := r.insertJSONRow(ctx, tx, "evidence", []string{"id", "expires_tick", "payload", "created_at", "updated_at"}, []any{ev.ID, ev.ExpiryTick, asJSON(ev), now, now}); err != nil {
-			return err
+	for _, ev := range changedEntries(store.Evidence, changes, dirtyEvidence) {
+		keys.add("evidence", "id", ev.ID)
+		if !reflect.DeepEqual(prev.Evidence[ev.ID], ev) {
+			rows = append(rows, newPersistRow("evidence", "id", []string{"id", "expires_tick", "payload", "created_at", "updated_at"}, []any{ev.ID, ev.ExpiryTick, asJSON(ev), now, now}))
 		}
 	}
-	for _, report := range store.ScryReports {
-		if err := r.insertJSONRow(ctx, tx, "scry_reports", []string{"id", "owner_player_id", "expires_tick", "payload", "created_at", "updated_at"}, []any{report.ID, report.OwnerPlayerID, report.ExpiryTick, asJSON(report), now, now}); err != nil {
-			return err
+	for _, report := range changedEntries(store.ScryReports, changes, dirtyScryReports) {
+		keys.add("scry_reports", "id", report.ID)
+		if !reflect.DeepEqual(prev.ScryReports[report.ID], report) {
+			rows = append(rows, newPersistRow("scry_reports", "id", []string{"id", "owner_player_id", "expires_tick", "payload", "created_at", "updated_at"}, []any{report.ID, report.OwnerPlayerID, report.ExpiryTick, asJSON(report), now, now}))
 		}
 	}
-	for _, intercept := range store.Intercepts {
-		if err := r.insertJSONRow(ctx, tx, "intercepts", []string{"id", "owner_player_id", "expires_tick", "payload", "created_at", "updated_at"}, []any{intercept.ID, intercept.OwnerPlayerID, intercept.ExpiryTick, asJSON(intercept), now, now}); err != nil {
-			return err
+	for _, intercept := range changedEntries(store.Intercepts, changes, dirtyIntercepts) {
+		keys.add("intercepts", "id", intercept.ID)
+		if !reflect.DeepEqual(prev.Intercepts[intercept.ID], intercept) {
+			rows = append(rows, newPersistRow("intercepts", "id", []string{"id", "owner_player_id", "expires_tick", "payload", "created_at", "updated_at"}, []any{intercept.ID, intercept.OwnerPlayerID, intercept.ExpiryTick, asJSON(intercept), now, now}))
 		}
 	}
-	for _, loan := range store.Loans {
-		if err := r.insertJSONRow(ctx, tx, "loans",
+	for _, loan := range changedEntries(store.Loans, changes, dirtyLoans) {
+		keys.add("loans", "id", loan.ID)
+		if reflect.DeepEqual(prev.Loans[loan.ID], loan) {
+			continue
+		}
+		rows = append(rows, newPersistRow("loans",
+			"id",
 			[]string{"id", "status", "due_tick", "terminal_at", "payload", "created_at", "updated_at"},
 			[]any{loan.ID, loan.Status, loan.DueTick, nullableTime(loan.TerminalAt), asJSON(loan), now, now},
-		); err != nil {
-			return err
-		}
+		))
 	}
-	for _, ob := range store.Obligations {
-		if err := r.insertJSONRow(ctx, tx, "obligations",
+	for _, ob := range changedEntries(store.Obligations, changes, dirtyObligations) {
+		keys.add("obligations", "id", ob.ID)
+		if reflect.DeepEqual(prev.Obligations[ob.ID], ob) {
+			continue
+		}
+		rows = append(rows, newPersistRow("obligations",
+			"id",
 			[]string{"id", "status", "due_tick", "terminal_at", "payload", "created_at", "updated_at"},
 			[]any{ob.ID, ob.Status, ob.DueTick, nullableTime(ob.TerminalAt), asJSON(ob), now, now},
-		); err != nil {
-			return err
-		}
+		))
 	}
-	for _, proj := range store.Projects {
-		if err := r.insertJSONRow(ctx, tx, "projects", []string{"id", "owner_player_id", "payload", "created_at", "updated_at"}, []any{proj.ID, proj.OwnerPlayerID, asJSON(proj), now, now}); err != nil {
-			return err
+	for _, proj := range changedEntries(store.Projects, changes, dirtyProjects) {
+		keys.add("projects", "id", proj.ID)
+		if !reflect.DeepEqual(prev.Projects[proj.ID], proj) {
+			rows = append(rows, newPersistRow("projects", "id", []string{"id", "owner_player_id", "payload", "created_at", "updated_at"}, []any{proj.ID, proj.OwnerPlayerID, asJSON(proj), now, now}))
 		}
 	}
 	if store.ActiveCrisis != nil {
-		if err := r.insertJSONRow(ctx, tx, "active_crisis", []string{"id", "payload", "updated_at"}, []any{1, asJSON(store.ActiveCrisis), now}); err != nil {
-			return err
+		keys.add("active_crisis", "id", 1)
+		if !reflect.DeepEqual(prev.ActiveCrisis, store.ActiveCrisis) {
+			rows = append(rows, newPersistRow("active_crisis", "id", []string{"id", "payload", "updated_at"}, []any{1, asJSON(store.ActiveCrisis), now}))
 		}
 	}
-	for _, relic := range store.Relics {
-		if err := r.insertJSONRow(ctx, tx, "relics", []string{"id", "owner_player_id", "payload", "created_at", "updated_at"}, []any{relic.ID, relic.OwnerPlayerID, asJSON(relic), now, now}); err != nil {
-			return err
+	for _, relic := range changedEntries(store.Relics, changes, dirtyRelics) {
+		keys.add("relics", "id", relic.ID)
+		if !reflect.DeepEqual(prev.Relics[relic.ID], relic) {
+			rows = append(rows, newPersistRow("relics", "id", []string{"id", "owner_player_id", "payload", "created_at", "updated_at"}, []any{relic.ID, relic.OwnerPlayerID, asJSON(relic), now, now}))
+		}
+	}
+	for _, o := range changedEntries(store.Orders, changes, dirtyOrders) {
+		keys.add("market_orders", "id", o.ID)
+		if !reflect.DeepEqual(prev.Orders[o.ID], o) {
+			rows = append(rows, newPersistRow("market_orders", "id", []string{"id", "player_id", "expires_tick", "payload", "created_at", "updated_at"}, []any{o.ID, o.PlayerID, o.ExpiresTick, asJSON(o), now, now}))
+		}
+	}
+	for _, c := range changedEntries(store.Caravans, changes, dirtyCaravans) {
+		keys.add("caravans", "id", c.ID)
+		if !reflect.DeepEqual(prev.Caravans[c.ID], c) {
+			rows = append(rows, newPersistRow("caravans", "id", []string{"id", "owner_player_id", "payload", "created_at", "updated_at"}, []any{c.ID, c.OwnerPlayerID, asJSON(c), now, now}))
+		}
+	}
+	for _, b := range changedEntries(store.Buildings, changes, dirtyBuildings) {
+		keys.add("buildings", "id", b.ID)
+		if !reflect.DeepEqual(prev.Buildings[b.ID], b) {
+			rows = append(rows, newPersistRow("buildings", "id", []string{"id", "owner_player_id", "location_id", "payload", "created_at", "updated_at"}, []any{b.ID, b.OwnerPlayerID, b.LocationID, asJSON(b), now, now}))
+		}
+	}
+	for _, cs := range changedEntries(store.Cases, changes, dirtyCases) {
+		keys.add("court_cases", "id", cs.ID)
+		if !reflect.DeepEqual(prev.Cases[cs.ID], cs) {
+			rows = append(rows, newPersistRow("court_cases", "id", []string{"id", "defendant_player_id", "payload", "created_at", "updated_at"}, []any{cs.ID, cs.DefendantID, asJSON(cs), now, now}))
+		}
+	}
+	for _, f := range changedEntries(store.Fights, changes, dirtyFights) {
+		keys.add("fights", "id", f.ID)
+		if !reflect.DeepEqual(prev.Fights[f.ID], f) {
+			rows = append(rows, newPersistRow("fights", "id", []string{"id", "location_id", "payload", "created_at", "updated_at"}, []any{f.ID, f.LocationID, asJSON(f), now, now}))
+		}
+	}
+	for _, clue := range changedEntries(store.Clues, changes, dirtyClues) {
+		keys.add("theft_clues", "id", clue.ID)
+		if !reflect.DeepEqual(prev.Clues[clue.ID], clue) {
+			rows = append(rows, newPersistRow("theft_clues", "id", []string{"id", "victim_player_id", "expiry_tick", "payload", "created_at", "updated_at"}, []any{clue.ID, clue.VictimID, clue.ExpiryTick, asJSON(clue), now, now}))
 		}
 	}
 
-	for _, event := range store.Events {
-		if err := r.insertJSONRow(ctx, tx, "events",
-			[]string{"id", "at_ts", "day_number", "subphase", "type", "severity", "text", "payload", "created_at"},
-			[]any{event.ID, event.At, event.DayNumber, event.Subphase, event.Type, event.Severity, event.Text, asJSON(event), event.At},
-		); err != nil {
-			return err
+	// History is appended far more than it changes, so only new or edited
+	// lines are encoded.
+	if _, whole := changes.field(dirtyEvents); whole {
+		prevEvents := indexByID(prev.Events, func(e Event) int64 { return e.ID })
+		for _, event := range store.Events {
+			keys.add("events", "id", event.ID)
+			if old, ok := prevEvents[event.ID]; ok && reflect.DeepEqual(old, event) {
+				continue
+			}
+			rows = append(rows, newPersistRow("events",
+				"id",
+				[]string{"id", "at_ts", "day_number", "subphase", "type", "severity", "text", "payload", "created_at"},
+				[]any{event.ID, event.At, event.DayNumber, event.Subphase, event.Type, event.Severity, event.Text, asJSON(event), event.At},
+			))
 		}
 	}
-	for _, msg := range store.Chat {
-		if err := r.insertJSONRow(ctx, tx, "chat_messages",
-			[]string{"id", "at_ts", "kind", "from_player_id", "to_player_id", "text", "payload", "created_at"},
-			[]any{msg.ID, msg.At, msg.Kind, msg.FromPlayerID, msg.ToPlayerID, msg.Text, asJSON(msg), msg.At},
-		); err != nil {
-			return err
+	if _, whole := changes.field(dirtyTreasuryLedger); whole {
+		prevLedger := indexByID(prev.TreasuryLedger, func(e TreasuryEntry) int64 { return e.ID })
+		for _, entry := range store.TreasuryLedger {
+			keys.add("treasury_ledger", "id", entry.ID)
+			if old, ok := prevLedger[entry.ID]; ok && reflect.DeepEqual(old, entry) {
+				continue
+			}
+			rows = append(rows, newPersistRow("treasury_ledger", "id", []string{"id", "institution_id", "at_ts", "payload", "created_at"}, []any{entry.ID, entry.InstitutionID, entry.At, asJSON(entry), entry.At}))
 		}
 	}
-	for _, msg := range store.Messages {
-		if err := r.insertJSONRow(ctx, tx, "diplomatic_messages",
-			[]string{"id", "at_ts", "from_player_id", "to_player_id", "subject", "payload", "created_at"},
-			[]any{msg.ID, msg.At, msg.FromPlayerID, msg.ToPlayerID, msg.Subject, asJSON(msg), msg.At},
-		); err != nil {
-			return err
+	if _, whole := changes.field(dirtyChat); whole {
+		prevChat := indexByID(prev.Chat, func(m ChatMessage) int64 { return m.ID })
+		for _, msg := range store.Chat {
+			keys.add("chat_messages", "id", msg.ID)
+			if old, ok := prevChat[msg.ID]; ok && reflect.DeepEqual(old, msg) {
+				continue
+			}
+			rows = append(rows, newPersistRow("chat_messages",
+				"id",
+				[]string{"id", "at_ts", "kind", "from_player_id", "to_player_id", "text", "payload", "created_at"},
+				[]any{msg.ID, msg.At, msg.Kind, msg.FromPlayerID, msg.ToPlayerID, msg.Text, asJSON(msg), msg.At},
+			))
 		}
 	}
+	if _, whole := changes.field(dirtyMessages); whole {
+		prevMessages := indexByID(prev.Messages, func(m DiplomaticMessage) int64 { return m.ID })
+		for _, msg := range store.Messages {
+			keys.add("diplomatic_messages", "id", msg.ID)
+			if old, ok := prevMessages[msg.ID]; ok && reflect.DeepEqual(old, msg) {
+				continue
+			}
+			rows = append(rows, newPersistRow("diplomatic_messages",
+				"id",
+				[]string{"id", "at_ts", "from_player_id", "to_player_id", "subject", "payload", "created_at"},
+				[]any{msg.ID, msg.At, msg.FromPlayerID, msg.ToPlayerID, msg.Subject, asJSON(msg), msg.At},
+			))
+		}
+	}
+	if _, whole := changes.field(dirtyMarketHistory); whole {
+		prevHistory := indexByID(prev.MarketHistory, func(t MarketTick) int64 { return t.Tick })
+		for _, entry := range store.MarketHistory {
+			keys.add("market_history", "tick", entry.Tick)
+			if old, ok := prevHistory[entry.Tick]; ok && reflect.DeepEqual(old, entry) {
+				continue
+			}
+			rows = append(rows, newPersistRow("market_history", "tick", []string{"tick", "at_ts", "payload", "created_at"}, []any{entry.Tick, entry.At, asJSON(entry), entry.At}))
+		}
+	}
+	return rows, keys
+}
 
-	return nil
+// diffPersistRows returns the rendered rows to write and the rows the
+// database holds that the view no longer wants.
+func (r *SQLRepository) diffPersistRows(rows []persistRow, keys persistKeys, changes *dirtySet) ([]persistRow, []persistRow) {
+	deletes := []persistRow{}
+	for _, tbl := range persistTables {
+		stale := make([]string, 0)
+		for key := range r.revisitedKeys(tbl.Name, tbl.Field, changes) {
+			if _, ok := keys[tbl.Name][key]; !ok {
+				stale = append(stale, key)
+			}
+		}
+		sort.Strings(stale)
+		for _, key := range stale {
+			prev := r.saved[tbl.Name][key]
+			deletes = append(deletes, persistRow{Table: tbl.Name, KeyCol: prev.KeyCol, Key: prev.Key})
+		}
+	}
+	return rows, deletes
 }
 
-func (r *SQLRepository) insertJSONRow(ctx context.Context, tx *sql.Tx, table string, cols []string, vals []any) error {
-	q := r.insertQuery(table, cols)
-	if _, err := tx.ExecContext(ctx, q, vals...); err != nil {
-		return fmt.Errorf("insert %s: %w", table, err)
+// revisitedKeys returns the saved keys of table that changes may have
+// removed.
+func (r *SQLRepository) revisitedKeys(table, field string, changes *dirtySet) map[string]persistedRow {
+	keys, whole := changes.field(field)
+	if field == "" || whole {
+		return r.saved[table]
+	}
+	out := make(map[string]persistedRow, len(keys))
+	for key := range keys {
+		if row, ok := r.saved[table][fmt.Sprint(key)]; ok {
+			out[fmt.Sprint(key)] = row
+		}
+	}
+	return out
+}
+
+// loadPersistedKeys seeds the saved snapshot with the keys already present in
+// the database. The saved view is dropped so every live row is rewritten once.
+func (r *SQLRepository) loadPersistedKeys(ctx context.Context) error {
+	r.saved = map[string]map[string]persistedRow{}
+	r.savedView = nil
+	for _, tbl := range persistTables {
+		r.saved[tbl.Name] = map[string]persistedRow{}
+		rows, err := r.db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s", tbl.KeyCol, tbl.Name))
+		if err != nil {
+			return fmt.Errorf("read %s keys: %w", tbl.Name, err)
+		}
+		for rows.Next() {
+			var key any
+			if err := rows.Scan(&key); err != nil {
+				rows.Close()
+				return fmt.Errorf("scan %s key: %w", tbl.Name, err)
+			}
+			if b, ok := key.([]byte); ok {
+				key = string(b)
+			}
+			r.saved[tbl.Name][fmt.Sprint(key)] = persistedRow{KeyCol: tbl.KeyCol, Key: key}
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return fmt.Errorf("iterate %s keys: %w", tbl.Name, err)
+		}
+		rows.Close()
 	}
 	return nil
 }
 
+// markPersisted records view as matching the database without writing it.
+// LoadInto uses it so the first save after a restart is a no-op.
+func (r *SQLRepository) markPersisted(view *Store) {
+	_, keys := collectPersistRows(view, view, nil, time.Now().UTC())
+	r.saved = map[string]map[string]persistedRow{}
+	for _, tbl := range persistTables {
+		r.saved[tbl.Name] = map[string]persistedRow{}
+		for key, row := range keys[tbl.Name] {
+			r.saved[tbl.Name][key] = row
+		}
+	}
+	r.savedView, r.unsaved = view, dirtySet{}
+}
+
+func (r *SQLRepository) upsertQuery(table, keyCol string, cols []string) string {
+	sets := make([]string, 0, len(cols))
+	for _, col := range cols {
+		if col == keyCol || col == "created_at" {
+			continue
+		}
+		sets = append(sets, fmt.Sprintf("%s = excluded.%s", col, col))
+	}
+	return fmt.Sprintf("%s ON CONFLICT (%s) DO UPDATE SET %s", r.insertQuery(table, cols), keyCol, strings.Join(sets, ", "))
+}
+
 func asJSON(v any) string {
 	b, _ := json.Marshal(v)
 	return string(b)
@@ -458,7 +801,7 @@ func (r *SQLRepository) LoadInto(ctx context.Context, store *Store) error {
 		if err := r.Save(ctx, store); err != nil {
 			return fmt.Errorf("seed initial state: %w", err)
 		}
-		return nil
+		return r.ensureBaselineSnapshot(ctx, store)
 	}
 
 	if err := r.loadWorldAndPolicy(ctx, store); err != nil {
@@ -470,7 +813,10 @@ func (r *SQLRepository) LoadInto(ctx context.Context, store *Store) error {
 	if err := r.loadCollections(ctx, store); err != nil {
 		return err
 	}
-	return nil
+	r.markPersisted(viewOfLocked(store))
+	// Seats added since the world was saved are written by the next Save.
+	ensureSeatsLocked(store)
+	return r.ensureBaselineSnapshot(ctx, store)
 }
 
 func (r *SQLRepository) loadWorldAndPolicy(ctx context.Context, store *Store) error {
@@ -515,6 +861,53 @@ func (r *SQLRepository) loadRuntime(ctx context.Context, store *Store) error {
 	if err := json.Unmarshal([]byte(payload), &runtime); err != nil {
 		return fmt.Errorf("decode runtime_state: %w", err)
 	}
+	applyRuntimeState(store, runtime)
+	return nil
+}
+
+func runtimeStateFromStore(store *Store) runtimeState {
+	return runtimeState{
+		NextEventID:       store.NextEventID,
+		NextContractID:    store.NextContractID,
+		NextChatID:        store.NextChatID,
+		NextMessageID:     store.NextMessageID,
+		NextRumorID:       store.NextRumorID,
+		NextEvidenceID:    store.NextEvidenceID,
+		NextScryID:        store.NextScryID,
+		NextInterceptID:   store.NextInterceptID,
+		NextLoanID:        store.NextLoanID,
+		NextObligationID:  store.NextObligationID,
+		NextProjectID:     store.NextProjectID,
+		NextRelicID:       store.NextRelicID,
+		NextOrderID:       store.NextOrderID,
+		NextCaravanID:     store.NextCaravanID,
+		NextBuildingID:    store.NextBuildingID,
+		NextCaseID:        store.NextCaseID,
+		NextClueID:        store.NextClueID,
+		NextFightID:       store.NextFightID,
+		NextTreasuryID:    store.NextTreasuryID,
+		NextJournalID:     store.NextJournalID,
+		NextSnapshotID:    store.NextSnapshotID,
+		LastDailyTickDate: store.LastDailyTickDate,
+		LastTickAt:        store.LastTickAt,
+		TickEveryNanos:    int64(store.TickEvery),
+		TickCount:         store.TickCount,
+		LastChatAt:        store.LastChatAt,
+		LastMessageAt:     store.LastMessageAt,
+		LastActionAt:      store.LastActionAt,
+		LastDeliverAt:     store.LastDeliverAt,
+		LastInvestigateAt: store.LastInvestigateAt,
+		LastSeatActionAt:  store.LastSeatActionAt,
+		LastIntelActionAt: store.LastIntelActionAt,
+		LastFieldworkAt:   store.LastFieldworkAt,
+		DailyActionDate:   store.DailyActionDate,
+		DailyHighImpactN:  store.DailyHighImpactN,
+		LastCleanupDate:   store.LastCleanupDate,
+		RNG:               store.rng,
+	}
+}
+
+func applyRuntimeState(store *Store, runtime runtimeState) {
 	store.NextEventID = runtime.NextEventID
 	store.NextContractID = runtime.NextContractID
 	store.NextChatID = runtime.NextChatID
@@ -527,6 +920,15 @@ func (r *SQLRepository) loadRuntime(ctx context.Context, store *Store) error {
 	store.NextObligationID = runtime.NextObligationID
 	store.NextProjectID = runtime.NextProjectID
 	store.NextRelicID = runtime.NextRelicID
+	store.NextOrderID = runtime.NextOrderID
+	store.NextCaravanID = runtime.NextCaravanID
+	store.NextBuildingID = runtime.NextBuildingID
+	store.NextCaseID = runtime.NextCaseID
+	store.NextClueID = runtime.NextClueID
+	store.NextFightID = runtime.NextFightID
+	store.NextTreasuryID = runtime.NextTreasuryID
+	store.NextJournalID = runtime.NextJournalID
+	store.NextSnapshotID = runtime.NextSnapshotID
 	store.LastDailyTickDate = runtime.LastDailyTickDate
 	store.LastTickAt = runtime.LastTickAt
 	if runtime.TickEveryNanos > 0 {
@@ -544,8 +946,19 @@ func (r *SQLRepository) loadRuntime(ctx context.Context, store *Store) error {
 	store.DailyActionDate = runtime.DailyActionDate
 	store.DailyHighImpactN = runtime.DailyHighImpactN
 	store.LastCleanupDate = runtime.LastCleanupDate
+	if runtime.RNG != nil {
+		// Keep the persisted seed and draw counters so rolls continue the
+		// same sequences across restarts.
+		store.rng = newWorldRNG(runtime.RNG.Seed)
+		store.rng.Tick = runtime.RNG.Tick
+		if runtime.RNG.Draws != nil {
+			store.rng.Draws = runtime.RNG.Draws
+		}
+		if runtime.RNG.History != nil {
+			store.rng.History = runtime.RNG.History
+		}
+	}
 	ensureRuntimeMaps(store)
-	return nil
 }
 
 func ensureRuntimeMaps(store *Store) {
@@ -582,7 +995,9 @@ func ensureRuntimeMaps(store *Store) {
 }
 
 func (r *SQLRepository) loadCollections(ctx context.Context, store *Store) error {
+	store.dirty.markAll()
 	store.Players = map[string]*Player{}
+	store.apiTokens = nil
 	store.Institutions = map[string]*Institution{}
 	store.Seats = map[string]*Seat{}
 	store.Contracts = map[string]*Contract{}
@@ -596,12 +1011,20 @@ func (r *SQLRepository) loadCollections(ctx context.Context, store *Store) error
 	store.Obligations = map[string]*Obligation{}
 	store.Projects = map[string]*Project{}
 	store.Relics = map[int64]*Relic{}
+	store.Orders = map[string]*MarketOrder{}
+	store.Caravans = map[string]*Caravan{}
+	store.Buildings = map[string]*Building{}
+	store.Cases = map[string]*CourtCase{}
+	store.Clues = map[string]*TheftClue{}
+	store.Fights = map[string]*Fight{}
 	store.Events = []Event{}
 	store.Chat = []ChatMessage{}
 	store.Messages = []DiplomaticMessage{}
+	store.MarketHistory = []MarketTick{}
+	store.TreasuryLedger = []TreasuryEntry{}
 	store.ActiveCrisis = nil
 
-	if err := loadMapRows(ctx, r.db, "SELECT payload FROM players", func(payload string) error {
+	if err := r.loadVersionedRows(ctx, "players", "player_id", playerPayloadUpgraders, func(payload string) error {
 		var p Player
 		if err := json.Unmarshal([]byte(payload), &p); err != nil {
 			return err
@@ -631,7 +1054,7 @@ func (r *SQLRepository) loadCollections(ctx context.Context, store *Store) error
 	}); err != nil {
 		return fmt.Errorf("load seats: %w", err)
 	}
-	if err := loadMapRows(ctx, r.db, "SELECT payload FROM contracts", func(payload string) error {
+	if err := r.loadVersionedRows(ctx, "contracts", "contract_id", contractPayloadUpgraders, func(payload string) error {
 		var c Contract
 		if err := json.Unmarshal([]byte(payload), &c); err != nil {
 			return err
@@ -741,6 +1164,66 @@ func (r *SQLRepository) loadCollections(ctx context.Context, store *Store) error
 	}); err != nil {
 		return fmt.Errorf("load relics: %w", err)
 	}
+	if err := loadMapRows(ctx, r.db, "SELECT payload FROM market_orders", func(payload string) error {
+		var o MarketOrder
+		if err := json.Unmarshal([]byte(payload), &o); err != nil {
+			return err
+		}
+		store.Orders[o.ID] = &o
+		return nil
+	}); err != nil {
+		return fmt.Errorf("load market_orders: %w", err)
+	}
+	if err := loadMapRows(ctx, r.db, "SELECT payload FROM caravans", func(payload string) error {
+		var c Caravan
+		if err := json.Unmarshal([]byte(payload), &c); err != nil {
+			return err
+		}
+		store.Caravans[c.ID] = &c
+		return nil
+	}); err != nil {
+		return fmt.Errorf("load caravans: %w", err)
+	}
+	if err := loadMapRows(ctx, r.db, "SELECT payload FROM buildings", func(payload string) error {
+		var b Building
+		if err := json.Unmarshal([]byte(payload), &b); err != nil {
+			return err
+		}
+		store.Buildings[b.ID] = &b
+		return nil
+	}); err != nil {
+		return fmt.Errorf("load buildings: %w", err)
+	}
+	if err := loadMapRows(ctx, r.db, "SELECT payload FROM court_cases", func(payload string) error {
+		var cs CourtCase
+		if err := json.Unmarshal([]byte(payload), &cs); err != nil {
+			return err
+		}
+		store.Cases[cs.ID] = &cs
+		return nil
+	}); err != nil {
+		return fmt.Errorf("load court cases: %w", err)
+	}
+	if err := loadMapRows(ctx, r.db, "SELECT payload FROM theft_clues", func(payload string) error {
+		var clue TheftClue
+		if err := json.Unmarshal([]byte(payload), &clue); err != nil {
+			return err
+		}
+		store.Clues[clue.ID] = &clue
+		return nil
+	}); err != nil {
+		return fmt.Errorf("load theft clues: %w", err)
+	}
+	if err := loadMapRows(ctx, r.db, "SELECT payload FROM fights", func(payload string) error {
+		var f Fight
+		if err := json.Unmarshal([]byte(payload), &f); err != nil {
+			return err
+		}
+		store.Fights[f.ID] = &f
+		return nil
+	}); err != nil {
+		return fmt.Errorf("load fights: %w", err)
+	}
 	if err := loadMapRows(ctx, r.db, "SELECT payload FROM events ORDER BY id", func(payload string) error {
 		var event Event
 		if err := json.Unmarshal([]byte(payload), &event); err != nil {
@@ -751,6 +1234,16 @@ func (r *SQLRepository) loadCollections(ctx context.Context, store *Store) error
 	}); err != nil {
 		return fmt.Errorf("load events: %w", err)
 	}
+	if err := loadMapRows(ctx, r.db, "SELECT payload FROM treasury_ledger ORDER BY id", func(payload string) error {
+		var entry TreasuryEntry
+		if err := json.Unmarshal([]byte(payload), &entry); err != nil {
+			return err
+		}
+		store.TreasuryLedger = append(store.TreasuryLedger, entry)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("load treasury ledger: %w", err)
+	}
 	if err := loadMapRows(ctx, r.db, "SELECT payload FROM chat_messages ORDER BY id", func(payload string) error {
 		var msg ChatMessage
 		if err := json.Unmarshal([]byte(payload), &msg); err != nil {
@@ -771,6 +1264,16 @@ func (r *SQLRepository) loadCollections(ctx context.Context, store *Store) error
 	}); err != nil {
 		return fmt.Errorf("load diplomatic_messages: %w", err)
 	}
+	if err := loadMapRows(ctx, r.db, "SELECT payload FROM market_history ORDER BY tick", func(payload string) error {
+		var entry MarketTick
+		if err := json.Unmarshal([]byte(payload), &entry); err != nil {
+			return err
+		}
+		store.MarketHistory = append(store.MarketHistory, entry)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("load market_history: %w", err)
+	}
 
 	var crisisPayload string
 	err := r.db.QueryRowContext(ctx, "SELECT payload FROM active_crisis WHERE id = 1").Scan(&crisisPayload)
@@ -805,21 +1308,41 @@ func loadMapRows(ctx context.Context, db *sql.DB, q string, fn func(payload stri
 	return rows.Err()
 }
 
-func startCleanupScheduler(store *Store) {
+// startCleanupScheduler runs the daily cleanup until ctx is done; the
+// returned channel closes once it has stopped.
+func startCleanupScheduler(ctx context.Context, store *Store) <-chan struct{} {
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
 		ticker := time.NewTicker(1 * time.Hour)
 		defer ticker.Stop()
-		for now := range ticker.C {
-			store.mu.Lock()
-			today := now.UTC().Format("2006-01-02")
-			if store.LastCleanupDate != today {
-				runDailyCleanupLocked(store, now.UTC())
+		for {
+			var now time.Time
+			select {
+			case <-ctx.Done():
+				return
+			case now = <-ticker.C:
+			}
+			now = now.UTC()
+			today := now.Format("2006-01-02")
+			ran := false
+			store.write(func() {
+				if store.LastCleanupDate == today {
+					return
+				}
+				recordJournalLocked(store, JournalEntry{Kind: journalKindCleanup, At: now})
+				runDailyCleanupLocked(store, now)
 				store.LastCleanupDate = today
-				store.persistLocked()
+				ran = true
+			})
+			if ran && store.repo != nil {
+				if err := store.repo.pruneJournal(ctx, now.Add(-journalRetention)); err != nil {
+					log.Printf("prune journal failed: %v", err)
+				}
 			}
-			store.mu.Unlock()
 		}
 	}()
+	return done
 }
 
 func runDailyCleanupLocked(store *Store, now time.Time) {
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"net/http"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -65,6 +66,23 @@ func TestRepositorySQLiteRoundTrip(t *testing.T) {
 	s1.Chat = append(s1.Chat, ChatMessage{ID: 1, FromPlayerID: p.ID, FromName: p.Name, Text: "hello", At: now, Kind: "global"})
 	s1.Messages = append(s1.Messages, DiplomaticMessage{ID: 1, FromPlayerID: p.ID, FromName: p.Name, ToPlayerID: p.ID, ToName: p.Name, Subject: "s", Body: "b", At: now})
 	s1.LastActionAt[p.ID] = now
+	s1.NextOrderID = 3
+	s1.MarketHistory = append(s1.MarketHistory, MarketTick{Tick: 42, At: now, Samples: []MarketSample{{Location: locationHarbor, Commodity: "salt", BasePrice: 2, Supply: 140, Volume: 6}}})
+	s1.Orders["o-3"] = &MarketOrder{ID: "o-3", PlayerID: p.ID, PlayerName: p.Name, LocationID: locationCapital, Commodity: "salt", Side: orderSideAsk, Price: 4, Amount: 5, ExpiresTick: 50}
+	s1.NextCaravanID = 2
+	s1.NextBuildingID = 1
+	s1.Buildings["b-1"] = &Building{ID: "b-1", Type: "farm", Name: "Farmstead", OwnerPlayerID: p.ID, OwnerName: p.Name, LocationID: locationFrontier, Condition: 85, History: []BuildingOutput{{Tick: 41, Produced: 3}}}
+	s1.Institutions["city_authority"] = &Institution{ID: "city_authority", Name: "City Authority", Treasury: 52}
+	s1.NextTreasuryID = 1
+	s1.TreasuryLedger = append(s1.TreasuryLedger, TreasuryEntry{ID: 1, Tick: 42, InstitutionID: "city_authority", Amount: -5, Balance: 52, Memo: embezzlementMemo, PlayerID: p.ID, PlayerName: p.Name, Embezzled: true, At: now})
+	s1.NextCaseID = 4
+	s1.Cases["case-4"] = &CourtCase{ID: "case-4", DefendantID: p.ID, DefendantName: p.Name, Charge: "fraud", TicksLeft: 2, Exhibits: []Exhibit{{Side: caseSideDefense, SubmittedByID: p.ID, Strength: 3, Forged: true}}, JuryVotes: map[string]bool{"p9": true}}
+	delete(s1.Seats, "magistrate")
+	s1.NextClueID = 2
+	s1.Clues["clue-2"] = &TheftClue{ID: "clue-2", Kind: theftBurglary, CulpritID: "p9", VictimID: p.ID, VictimName: p.Name, LocationID: locationHarbor, Loot: "3 Salt", Strength: 5, ExpiryTick: 50}
+	s1.NextFightID = 3
+	s1.Fights["fight-3"] = &Fight{ID: "fight-3", Kind: fightDuel, LocationID: locationHarbor, Attacker: Fighter{PlayerID: p.ID, Name: p.Name, Power: 12, Vigor: 3, Tactic: tacticPress}, Defender: Fighter{PlayerID: "p9", Name: "Bram", Power: 10, Vigor: 2, Tactic: tacticDefend}, Round: 1, Log: []string{"Round 1: no blow lands."}}
+	s1.Caravans["cv-2"] = &Caravan{ID: "cv-2", OwnerPlayerID: p.ID, OwnerName: p.Name, FromID: locationCapital, ToID: locationFrontier, Cargo: map[string]int{"salt": 4}, Guards: 1, TicksLeft: 3, TotalTicks: 4}
 
 	if err := repo.Save(context.Background(), s1); err != nil {
 		t.Fatalf("repo.Save error: %v", err)
@@ -84,6 +102,33 @@ func TestRepositorySQLiteRoundTrip(t *testing.T) {
 	if s2.TickCount != 42 || s2.NextContractID != 8 {
 		t.Fatalf("runtime counters mismatch: tick=%d nextContract=%d", s2.TickCount, s2.NextContractID)
 	}
+	if got := s2.Orders["o-3"]; got == nil || got.Side != orderSideAsk || got.Amount != 5 || s2.NextOrderID != 3 {
+		t.Fatalf("order mismatch after round-trip: got=%+v next=%d", got, s2.NextOrderID)
+	}
+	if got := s2.Caravans["cv-2"]; got == nil || got.Cargo["salt"] != 4 || got.TicksLeft != 3 || s2.NextCaravanID != 2 {
+		t.Fatalf("caravan mismatch after round-trip: got=%+v next=%d", got, s2.NextCaravanID)
+	}
+	if got := s2.Buildings["b-1"]; got == nil || got.Condition != 85 || len(got.History) != 1 || s2.NextBuildingID != 1 {
+		t.Fatalf("building mismatch after round-trip: got=%+v next=%d", got, s2.NextBuildingID)
+	}
+	if got := s2.Institutions["city_authority"]; got == nil || got.Treasury != 52 || len(s2.TreasuryLedger) != 1 || !s2.TreasuryLedger[0].Embezzled || s2.NextTreasuryID != 1 {
+		t.Fatalf("treasury mismatch after round-trip: got=%+v ledger=%+v", got, s2.TreasuryLedger)
+	}
+	if got := s2.Cases["case-4"]; got == nil || !got.Exhibits[0].Forged || !got.JuryVotes["p9"] || s2.NextCaseID != 4 {
+		t.Fatalf("court case mismatch after round-trip: got=%+v next=%d", got, s2.NextCaseID)
+	}
+	if got := s2.Clues["clue-2"]; got == nil || got.CulpritID != "p9" || got.Loot != "3 Salt" || s2.NextClueID != 2 {
+		t.Fatalf("theft clue mismatch after round-trip: got=%+v next=%d", got, s2.NextClueID)
+	}
+	if got := s2.Fights["fight-3"]; got == nil || got.Attacker.Vigor != 3 || got.Defender.Tactic != tacticDefend || len(got.Log) != 1 || s2.NextFightID != 3 {
+		t.Fatalf("fight mismatch after round-trip: got=%+v next=%d", got, s2.NextFightID)
+	}
+	if seat := s2.Seats["magistrate"]; seat == nil || seat.HolderName != seatDefaultHolderName("magistrate") {
+		t.Fatalf("a world saved without the magistrate's seat should gain it on load: %+v", seat)
+	}
+	if len(s2.MarketHistory) != 1 || s2.MarketHistory[0].Samples[0].Volume != 6 {
+		t.Fatalf("market history mismatch after round-trip: %+v", s2.MarketHistory)
+	}
 	if s2.LastCleanupDate != "2026-02-12" {
 		t.Fatalf("last cleanup mismatch: %q", s2.LastCleanupDate)
 	}
@@ -127,3 +172,120 @@ func TestNewConfiguredStoreWithSQLite(t *testing.T) {
 		t.Fatalf("expected initialized store maps")
 	}
 }
+
+func TestRepositorySaveWritesOnlyChangedRows(t *testing.T) {
+	t.Setenv("DB_DIALECT", "sqlite")
+	t.Setenv("DB_SQLITE_PATH", filepath.Join(t.TempDir(), "incremental.sqlite"))
+
+	repo, err := openRepositoryFromEnv()
+	if err != nil {
+		t.Fatalf("openRepositoryFromEnv sqlite error: %v", err)
+	}
+	defer repo.db.Close()
+
+	ctx := context.Background()
+	now := time.Date(2026, 2, 12, 12, 0, 0, 0, time.UTC)
+	s := newStore()
+	s.Players["p1"] = &Player{ID: "p1", Name: "Ash Crow", Gold: 10, LastSeen: now, LocationID: locationCapital}
+	s.Contracts["c1"] = &Contract{ID: "c1", Type: "Emergency", Status: "Issued", DeadlineTicks: 3}
+	if err := repo.Save(ctx, s); err != nil {
+		t.Fatalf("initial save error: %v", err)
+	}
+	if repo.lastSave.Upserts == 0 {
+		t.Fatalf("initial save should write rows")
+	}
+
+	if err := repo.Save(ctx, s); err != nil {
+		t.Fatalf("repeat save error: %v", err)
+	}
+	if repo.lastSave != (persistStats{}) {
+		t.Fatalf("unchanged store should not write, got %+v", repo.lastSave)
+	}
+
+	// A LastSeen bump that reaches the store is a real change; presence
+	// keeps the sub-granularity ones from getting that far.
+	s.Players["p1"].LastSeen = now.Add(5 * time.Second)
+	if err := repo.Save(ctx, s); err != nil {
+		t.Fatalf("last-seen save error: %v", err)
+	}
+	if repo.lastSave != (persistStats{Upserts: 1}) {
+		t.Fatalf("LastSeen bump should write the player, got %+v", repo.lastSave)
+	}
+
+	s.Players["p1"].Gold = 25
+	s.Contracts["c1"].Status = "Fulfilled"
+	if err := repo.Save(ctx, s); err != nil {
+		t.Fatalf("incremental save error: %v", err)
+	}
+	if repo.lastSave != (persistStats{Upserts: 1, Deletes: 1}) {
+		t.Fatalf("expected one upsert and one delete, got %+v", repo.lastSave)
+	}
+
+	var contracts int
+	if err := repo.db.QueryRowContext(ctx, "SELECT COUNT(1) FROM contracts").Scan(&contracts); err != nil {
+		t.Fatalf("count contracts: %v", err)
+	}
+	if contracts != 0 {
+		t.Fatalf("terminal contract row should be deleted, got %d", contracts)
+	}
+
+	loaded := newStore()
+	if err := repo.LoadInto(ctx, loaded); err != nil {
+		t.Fatalf("LoadInto error: %v", err)
+	}
+	if got := loaded.Players["p1"]; got == nil || got.Gold != 25 {
+		t.Fatalf("expected upserted player gold, got %+v", got)
+	}
+	if err := repo.Save(ctx, loaded); err != nil {
+		t.Fatalf("save after load error: %v", err)
+	}
+	if repo.lastSave != (persistStats{}) {
+		t.Fatalf("save right after load should not write, got %+v", repo.lastSave)
+	}
+}
+
+func TestCollectPersistRowsRendersOnlyChangedEntities(t *testing.T) {
+	now := time.Date(2026, 2, 12, 12, 0, 0, 0, time.UTC)
+	s := newStore()
+	s.Players["p1"] = &Player{ID: "p1", Name: "Ash Crow", Gold: 10, LastSeen: now, LocationID: locationCapital}
+	s.Players["p2"] = &Player{ID: "p2", Name: "Bell Reed", Gold: 10, LastSeen: now, LocationID: locationCapital}
+	prev := viewOfLocked(s)
+
+	s.Players["p2"].Gold = 40
+	rows, keys := collectPersistRows(prev, viewOfLocked(s), nil, now)
+	if len(rows) != 1 || rows[0].Table != "players" || rows[0].Key != "p2" {
+		t.Fatalf("expected only p2 to be rendered, got %+v", rows)
+	}
+	if _, ok := keys["players"]["p1"]; !ok {
+		t.Fatalf("unchanged p1 should still be a wanted key, got %+v", keys["players"])
+	}
+}
+
+func TestFragPollingDoesNotPersist(t *testing.T) {
+	t.Setenv("DB_DIALECT", "sqlite")
+	t.Setenv("DB_SQLITE_PATH", filepath.Join(t.TempDir(), "poll.sqlite"))
+
+	store, err := newConfiguredStore()
+	if err != nil {
+		t.Fatalf("newConfiguredStore error: %v", err)
+	}
+	defer store.repo.db.Close()
+	mux := newMux(store, parseTemplates())
+
+	first := doReq(t, mux, http.MethodGet, "/frag/events", nil, "", "127.0.0.1:1111")
+	pid := cookieFromResponse(first, cookieName)
+	if pid == "" {
+		t.Fatalf("expected pid cookie on first poll")
+	}
+	if store.repo.lastSave.Upserts == 0 {
+		t.Fatalf("new guest should be persisted")
+	}
+
+	commits := store.repo.commits
+	for _, path := range []string{"/frag/events", "/frag/players", "/frag/market", "/frag/ledger"} {
+		doReq(t, mux, http.MethodGet, path, nil, pid, "127.0.0.1:1111")
+		if store.repo.commits != commits {
+			t.Fatalf("GET %s should not write, got %+v", path, store.repo.lastSave)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Dirty tracking: the writer publishes a view and saves after every batch,
+// and both only revisit what the batch changed. Code that runs on a tracked
+// write (actions, chat, missives, page loads, tokens) marks each map entry
+// it adds, changes or deletes by Store field name and key, and each history
+// slice it appends to or edits. Ticks, admin commands and anything else run
+// through write mark everything, so a world tick copies and compares the
+// whole store as it always has.
+
+// Store fields that are marked by key.
+const (
+	dirtyPlayers           = "Players"
+	dirtyContracts         = "Contracts"
+	dirtyInstitutions      = "Institutions"
+	dirtySeats             = "Seats"
+	dirtyRumors            = "Rumors"
+	dirtyEvidence          = "Evidence"
+	dirtyScryReports       = "ScryReports"
+	dirtyIntercepts        = "Intercepts"
+	dirtyLoans             = "Loans"
+	dirtyObligations       = "Obligations"
+	dirtyPermits           = "Permits"
+	dirtyWarrants          = "Warrants"
+	dirtyRelics            = "Relics"
+	dirtyProjects          = "Projects"
+	dirtyOrders            = "Orders"
+	dirtyCaravans          = "Caravans"
+	dirtyBuildings         = "Buildings"
+	dirtyCases             = "Cases"
+	dirtyClues             = "Clues"
+	dirtyFights            = "Fights"
+	dirtyLastChatAt        = "LastChatAt"
+	dirtyLastMessageAt     = "LastMessageAt"
+	dirtyLastActionAt      = "LastActionAt"
+	dirtyLastDeliverAt     = "LastDeliverAt"
+	dirtyLastInvestigateAt = "LastInvestigateAt"
+	dirtyLastSeatActionAt  = "LastSeatActionAt"
+	dirtyLastIntelActionAt = "LastIntelActionAt"
+	dirtyLastFieldworkAt   = "LastFieldworkAt"
+	dirtyDailyActionDate   = "DailyActionDate"
+	dirtyDailyHighImpactN  = "DailyHighImpactN"
+	dirtyToastByPlayer     = "ToastByPlayer"
+)
+
+// Store fields that are marked whole.
+const (
+	dirtyEvents         = "Events"
+	dirtyChat           = "Chat"
+	dirtyMessages       = "Messages"
+	dirtyMarketHistory  = "MarketHistory"
+	dirtyTreasuryLedger = "TreasuryLedger"
+	// dirtyAPITokens is the unexported token index, which views copy too.
+	dirtyAPITokens = "apiTokens"
+)
+
+// dirtySet is what a batch changed. A field present with a nil key set
+// changed wholesale.
+type dirtySet struct {
+	all    bool
+	fields map[string]map[any]struct{}
+}
+
+// markAll records that anything may have changed.
+func (d *dirtySet) markAll() {
+	d.all = true
+	d.fields = nil
+}
+
+// mark records that the entry under key in a map field changed.
+func (d *dirtySet) mark(field string, key any) {
+	if d.all {
+		return
+	}
+	if d.fields == nil {
+		d.fields = map[string]map[any]struct{}{}
+	}
+	keys, ok := d.fields[field]
+	if ok && keys == nil {
+		return
+	}
+	if keys == nil {
+		keys = map[any]struct{}{}
+		d.fields[field] = keys
+	}
+	keys[key] = struct{}{}
+}
+
+// markField records that a whole field changed.
+func (d *dirtySet) markField(field string) {
+	if d.all {
+		return
+	}
+	if d.fields == nil {
+		d.fields = map[string]map[any]struct{}{}
+	}
+	d.fields[field] = nil
+}
+
+// has reports whether key in field, or the whole field, is marked. A nil
+// set has nothing marked.
+func (d *dirtySet) has(field string, key any) bool {
+	if d == nil {
+		return false
+	}
+	if d.all {
+		return true
+	}
+	keys, ok := d.fields[field]
+	if !ok {
+		return false
+	}
+	if keys == nil {
+		return true
+	}
+	_, ok = keys[key]
+	return ok
+}
+
+// field returns the marked keys of field, and whether the field changed
+// wholesale. A nil set counts as everything changed.
+func (d *dirtySet) field(field string) (keys map[any]struct{}, whole bool) {
+	if d == nil || d.all {
+		return nil, true
+	}
+	keys, ok := d.fields[field]
+	return keys, ok && keys == nil
+}
+
+// merge adds everything marked in o.
+func (d *dirtySet) merge(o *dirtySet) {
+	switch {
+	case o == nil || o.all:
+		d.markAll()
+		return
+	case d.all:
+		return
+	}
+	for field, keys := range o.fields {
+		if keys == nil {
+			d.markField(field)
+			continue
+		}
+		for key := range keys {
+			d.mark(field, key)
+		}
+	}
+}
+
+// take returns what has been marked and starts a fresh set.
+func (d *dirtySet) take() *dirtySet {
+	taken := *d
+	*d = dirtySet{}
+	return &taken
+}
+
+// changedEntries returns the entries of m a save must revisit: all of them
+// when the field changed wholesale, otherwise the marked keys still present.
+func changedEntries[K comparable, V any](m map[K]V, changes *dirtySet, field string) map[K]V {
+	keys, whole := changes.field(field)
+	if whole {
+		return m
+	}
+	out := make(map[K]V, len(keys))
+	for key := range keys {
+		if k, ok := key.(K); ok {
+			if v, ok := m[k]; ok {
+				out[k] = v
+			}
+		}
+	}
+	return out
+}
+
+// verifyDirtyMarks makes tracked commands check their marks: every entry
+// that differs afterwards must have been marked. Tests turn it on; it deep
+// copies the world twice per command.
+var verifyDirtyMarks bool
+
+// runMarkedLocked runs fn and, when verifyDirtyMarks is on, panics if fn
+// changed state it did not mark.
+func runMarkedLocked(s *Store, fn func()) {
+	if !verifyDirtyMarks {
+		fn()
+		return
+	}
+	outer := s.dirty
+	s.dirty = dirtySet{}
+	before := viewOfLocked(s)
+	defer func() {
+		outer.merge(&s.dirty)
+		s.dirty = outer
+	}()
+	fn()
+	if missed := unmarkedChanges(before, s, &s.dirty); len(missed) > 0 {
+		names := make([]string, 0, len(missed))
+		for _, m := range missed {
+			names = append(names, m.String())
+		}
+		panic(fmt.Sprintf("unmarked changes: %s", strings.Join(names, ", ")))
+	}
+}
+
+// markPokedLocked marks whatever differs between the live store and the
+// published view. Tests poke the store directly between requests, which
+// production code never does, so it only runs with verifyDirtyMarks.
+func markPokedLocked(s *Store) {
+	if !verifyDirtyMarks {
+		return
+	}
+	view := s.view.Load()
+	if view == nil {
+		return
+	}
+	for _, m := range unmarkedChanges(view, s, &dirtySet{}) {
+		if m.whole {
+			s.dirty.markField(m.field)
+		} else {
+			s.dirty.mark(m.field, m.key)
+		}
+	}
+}
+
+// dirtyEntry is one changed map entry, or a whole changed slice.
+type dirtyEntry struct {
+	field string
+	key   any
+	whole bool
+}
+
+func (e dirtyEntry) String() string {
+	if e.whole {
+		return e.field
+	}
+	return fmt.Sprintf("%s[%v]", e.field, e.key)
+}
+
+// unmarkedChanges lists the exported map entries and slices of after that
+// differ from before without being marked in d.
+func unmarkedChanges(before, after *Store, d *dirtySet) []dirtyEntry {
+	if d.all {
+		return nil
+	}
+	missed := []dirtyEntry{}
+	b, a := reflect.ValueOf(before).Elem(), reflect.ValueOf(after).Elem()
+	for i := 0; i < a.NumField(); i++ {
+		f := a.Type().Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		bf, af := b.Field(i), a.Field(i)
+		switch f.Type.Kind() {
+		case reflect.Map:
+			keys := map[any]struct{}{}
+			for _, k := range bf.MapKeys() {
+				keys[k.Interface()] = struct{}{}
+			}
+			for _, k := range af.MapKeys() {
+				keys[k.Interface()] = struct{}{}
+			}
+			for key := range keys {
+				kv := reflect.ValueOf(key)
+				if d.has(f.Name, key) {
+					continue
+				}
+				bv, av := bf.MapIndex(kv), af.MapIndex(kv)
+				if bv.IsValid() != av.IsValid() || (av.IsValid() && !reflect.DeepEqual(bv.Interface(), av.Interface())) {
+					missed = append(missed, dirtyEntry{field: f.Name, key: key})
+				}
+			}
+		case reflect.Slice:
+			if !d.has(f.Name, nil) && !reflect.DeepEqual(bf.Interface(), af.Interface()) {
+				missed = append(missed, dirtyEntry{field: f.Name, whole: true})
+			}
+		}
+	}
+	sort.Slice(missed, func(i, j int) bool { return missed[i].String() < missed[j].String() })
+	return missed
+}
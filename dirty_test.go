@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func init() {
+	// Every tracked command in the tests checks that it marked what it
+	// changed.
+	verifyDirtyMarks = true
+}
+
+func TestViewsShareWhatABatchLeftAlone(t *testing.T) {
+	s := newTestStore()
+	s.Players["p1"] = &Player{ID: "p1", Name: "Ash Crow", Gold: 10, LocationID: locationCapital}
+	s.Players["p2"] = &Player{ID: "p2", Name: "Bell Reed", Gold: 10, LocationID: locationCapital}
+	s.dirty.markAll()
+	first := publishViewLocked(s)
+
+	s.dirty.mark(dirtyPlayers, "p1")
+	s.Players["p1"].Gold = 30
+	next := publishViewLocked(s)
+
+	if next.Players["p2"] != first.Players["p2"] {
+		t.Fatalf("an unmarked player should be shared with the previous view")
+	}
+	if next.Players["p1"] == first.Players["p1"] || next.Players["p1"] == s.Players["p1"] {
+		t.Fatalf("a marked player should get a fresh copy")
+	}
+	if first.Players["p1"].Gold != 10 || next.Players["p1"].Gold != 30 {
+		t.Fatalf("views should hold gold 10 then 30, got %d and %d", first.Players["p1"].Gold, next.Players["p1"].Gold)
+	}
+}
+
+func TestPresenceMarksPlayersOnlyAcrossTheGranularity(t *testing.T) {
+	s := newTestStore()
+	base := time.Date(2026, 2, 12, 12, 0, 0, 0, time.UTC)
+	s.Players["p1"] = &Player{ID: "p1", Name: "Ash Crow", LastSeen: base, LocationID: locationCapital}
+
+	s.touch("p1", base.Add(5*time.Second))
+	mergePresenceLocked(s)
+	if s.dirty.has(dirtyPlayers, "p1") {
+		t.Fatalf("a bump inside the granularity should not mark the player")
+	}
+	view := publishViewLocked(s)
+	if got := lastSeenLocked(view, view.Players["p1"]); !got.Equal(base.Add(5 * time.Second)) {
+		t.Fatalf("readers should still see the finer LastSeen, got %v", got)
+	}
+
+	s.touch("p1", base.Add(lastSeenPersistGranularity))
+	mergePresenceLocked(s)
+	if !s.dirty.has(dirtyPlayers, "p1") {
+		t.Fatalf("a bump across the granularity should mark the player")
+	}
+}
+
+func TestWriterSavesOnlyMarkedRows(t *testing.T) {
+	t.Setenv("DB_DIALECT", "sqlite")
+	t.Setenv("DB_SQLITE_PATH", filepath.Join(t.TempDir(), "dirty.sqlite"))
+	repo, err := openRepositoryFromEnv()
+	if err != nil {
+		t.Fatalf("openRepositoryFromEnv sqlite error: %v", err)
+	}
+	defer repo.db.Close()
+
+	s := newTestStore()
+	s.repo = repo
+	defer s.closeWriter()
+	for _, id := range []string{"p1", "p2", "p3"} {
+		s.Players[id] = &Player{ID: id, Name: id, Gold: 10, LocationID: locationCapital}
+	}
+	s.write(func() {})
+	if repo.lastSave.Upserts == 0 {
+		t.Fatalf("the first save should write every row")
+	}
+
+	s.writeTracked(func() {
+		s.dirty.mark(dirtyPlayers, "p2")
+		s.Players["p2"].Gold = 40
+	})
+	if repo.lastSave != (persistStats{Upserts: 1}) {
+		t.Fatalf("a tracked write should save only its player, got %+v", repo.lastSave)
+	}
+
+	loaded := newStore()
+	if err := repo.LoadInto(context.Background(), loaded); err != nil {
+		t.Fatalf("LoadInto error: %v", err)
+	}
+	if got := loaded.Players["p2"]; got == nil || got.Gold != 40 {
+		t.Fatalf("expected the marked change to be saved, got %+v", got)
+	}
+}
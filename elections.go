@@ -0,0 +1,334 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// Voters need electionVoterMinRep standing unless they already sit on
+	// the seat's institution.
+	electionVoterMinRep = 5
+	// A ballot weighs one vote, one more per electionRepPerVote reputation
+	// and electionMemberVotes more from a member of the institution.
+	electionRepPerVote  = 25
+	electionMemberVotes = 2
+	// electionFilingFee is paid into the institution's treasury to stand.
+	electionFilingFee = 3
+	// Every electionGoldPerVote of campaign spending wins a vote.
+	electionGoldPerVote = 5
+	electionBribeMin    = 4
+	// electionBribeCatchPct is the chance a bought vote is found out and
+	// struck from the count.
+	electionBribeCatchPct = 30
+)
+
+// Candidate is a player standing in a seat's open election.
+type Candidate struct {
+	PlayerID       string
+	Name           string
+	Spending       int
+	RegisteredTick int64
+}
+
+// Ballot is one voter's choice. A ballot for a player who has not
+// registered is a write-in.
+type Ballot struct {
+	CandidateID   string
+	CandidateName string
+	Weight        int
+	WriteIn       bool `json:",omitempty"`
+	// BribedBy is the candidate who paid for this ballot.
+	BribedBy string `json:",omitempty"`
+}
+
+type CandidateView struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Spending int    `json:"spending"`
+	Mine     bool   `json:"mine"`
+	MyVote   bool   `json:"my_vote"`
+}
+
+// electionTally is one name's count when the polls close.
+type electionTally struct {
+	PlayerID   string
+	Name       string
+	Votes      int
+	Registered bool
+	Spending   int
+	Tick       int64
+}
+
+func seatCandidate(seat *Seat, playerID string) *Candidate {
+	for i := range seat.Candidates {
+		if seat.Candidates[i].PlayerID == playerID {
+			return &seat.Candidates[i]
+		}
+	}
+	return nil
+}
+
+// institutionMemberLocked reports whether p holds any seat of the
+// institution.
+func institutionMemberLocked(store *Store, p *Player, institutionID string) bool {
+	for _, seat := range store.Seats {
+		if seat.InstitutionID == institutionID && seat.HolderPlayerID == p.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// ballotWeightLocked is how many votes p casts in seat's election, or 0 when
+// p may not vote in it.
+func ballotWeightLocked(store *Store, seat *Seat, p *Player) int {
+	member := institutionMemberLocked(store, p, seat.InstitutionID)
+	if !member && p.Rep < electionVoterMinRep {
+		return 0
+	}
+	weight := 1 + maxInt(0, p.Rep)/electionRepPerVote
+	if member {
+		weight += electionMemberVotes
+	}
+	return weight
+}
+
+// openElectionSeatLocked finds seatID with its election open, rejecting the
+// action otherwise.
+func openElectionSeatLocked(store *Store, p *Player, seatID string) *Seat {
+	seat := store.Seats[strings.TrimSpace(seatID)]
+	if seat == nil || seat.ElectionWindowTicks <= 0 {
+		rejectLocked(store, p.ID, errCodeNotFound, "No election is open for that seat.")
+		return nil
+	}
+	return seat
+}
+
+func registerCandidateLocked(store *Store, p *Player, now time.Time, seatID string) {
+	seat := openElectionSeatLocked(store, p, seatID)
+	if seat == nil {
+		return
+	}
+	if seatCandidate(seat, p.ID) != nil {
+		rejectLocked(store, p.ID, errCodeNotAllowed, fmt.Sprintf("You are already standing for %s.", seat.Name))
+		return
+	}
+	if p.Gold < electionFilingFee {
+		rejectLocked(store, p.ID, errCodeInsufficientGold, fmt.Sprintf("Need %dg to file for %s.", electionFilingFee, seat.Name))
+		return
+	}
+	p.Gold -= electionFilingFee
+	depositTreasuryLocked(store, now, seat.InstitutionID, electionFilingFee, fmt.Sprintf("Filing fee, %s", seat.Name), p)
+	store.dirty.mark(dirtySeats, seat.ID)
+	seat.Candidates = append(seat.Candidates, Candidate{PlayerID: p.ID, Name: p.Name, RegisteredTick: store.TickCount})
+	addEventLocked(store, Event{Type: "Institution", Severity: 2, Text: fmt.Sprintf("[%s] stands for %s.", p.Name, seat.Name), At: now})
+	setToastLocked(store, p.ID, fmt.Sprintf("You are a candidate for %s.", seat.Name))
+}
+
+func castBallotLocked(store *Store, p *Player, now time.Time, seatID, candidateID string) {
+	seat := openElectionSeatLocked(store, p, seatID)
+	if seat == nil {
+		return
+	}
+	weight := ballotWeightLocked(store, seat, p)
+	if weight == 0 {
+		rejectLocked(store, p.ID, errCodeNotAllowed, fmt.Sprintf("Voting for %s needs %d reputation or a seat in the institution.", seat.Name, electionVoterMinRep))
+		return
+	}
+	choice := store.Players[candidateID]
+	if choice == nil {
+		rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a candidate or write in a player.")
+		return
+	}
+	store.dirty.mark(dirtySeats, seat.ID)
+	if seat.Ballots == nil {
+		seat.Ballots = map[string]Ballot{}
+	}
+	writeIn := seatCandidate(seat, choice.ID) == nil
+	seat.Ballots[p.ID] = Ballot{CandidateID: choice.ID, CandidateName: choice.Name, Weight: weight, WriteIn: writeIn}
+	if writeIn {
+		setToastLocked(store, p.ID, fmt.Sprintf("You write in %s for %s (%d votes).", choice.Name, seat.Name, weight))
+		return
+	}
+	setToastLocked(store, p.ID, fmt.Sprintf("You vote for %s for %s (%d votes).", choice.Name, seat.Name, weight))
+}
+
+func campaignSpendLocked(store *Store, p *Player, now time.Time, seatID string, amount int) {
+	seat := openElectionSeatLocked(store, p, seatID)
+	if seat == nil {
+		return
+	}
+	c := seatCandidate(seat, p.ID)
+	if c == nil {
+		rejectLocked(store, p.ID, errCodeNotAllowed, "Register as a candidate before campaigning.")
+		return
+	}
+	if amount <= 0 {
+		rejectLocked(store, p.ID, errCodeInvalidInput, "Choose an amount to spend.")
+		return
+	}
+	if p.Gold < amount {
+		rejectLocked(store, p.ID, errCodeInsufficientGold, fmt.Sprintf("Need %dg for that campaign.", amount))
+		return
+	}
+	p.Gold -= amount
+	c.Spending += amount
+	store.dirty.mark(dirtySeats, seat.ID)
+	addEventLocked(store, Event{Type: "Institution", Severity: 1, Text: fmt.Sprintf("[%s] campaigns across the wards for %s.", p.Name, seat.Name), At: now})
+	setToastLocked(store, p.ID, fmt.Sprintf("Campaign spending for %s: %dg.", seat.Name, c.Spending))
+}
+
+// bribeVoterLocked buys target's ballot for p. The voter keeps the gold
+// either way; a bribe that is found out voids the ballot and costs p
+// reputation.
+func bribeVoterLocked(store *Store, p *Player, now time.Time, seatID, targetID string, amount int) {
+	seat := openElectionSeatLocked(store, p, seatID)
+	if seat == nil {
+		return
+	}
+	if seatCandidate(seat, p.ID) == nil {
+		rejectLocked(store, p.ID, errCodeNotAllowed, "Only a candidate can buy votes.")
+		return
+	}
+	voter := store.Players[targetID]
+	if voter == nil || voter.ID == p.ID {
+		rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a voter to bribe.")
+		return
+	}
+	weight := ballotWeightLocked(store, seat, voter)
+	if weight == 0 {
+		rejectLocked(store, p.ID, errCodeNotAllowed, fmt.Sprintf("%s cannot vote for %s.", voter.Name, seat.Name))
+		return
+	}
+	if amount < electionBribeMin {
+		rejectLocked(store, p.ID, errCodeInvalidInput, fmt.Sprintf("A vote costs at least %dg.", electionBribeMin))
+		return
+	}
+	if p.Gold < amount {
+		rejectLocked(store, p.ID, errCodeInsufficientGold, fmt.Sprintf("Need %dg for the bribe.", amount))
+		return
+	}
+	p.Gold -= amount
+	voter.Gold += amount
+	p.Heat = clampInt(p.Heat+2, 0, 20)
+	store.dirty.mark(dirtyPlayers, voter.ID)
+	store.dirty.mark(dirtySeats, seat.ID)
+	if seat.Ballots == nil {
+		seat.Ballots = map[string]Ballot{}
+	}
+	if rollPercent(rngStreamLocked(store, rngStreamIntel), electionBribeCatchPct) {
+		delete(seat.Ballots, voter.ID)
+		p.Rep = clampInt(p.Rep-4, -100, 100)
+		addEventLocked(store, Event{Type: "Institution", Severity: 3, Text: fmt.Sprintf("[%s] is caught buying [%s]'s vote for %s; the ballot is struck.", p.Name, voter.Name, seat.Name), At: now})
+		setToastLocked(store, p.ID, "Your bribe was found out.")
+		return
+	}
+	seat.Ballots[voter.ID] = Ballot{CandidateID: p.ID, CandidateName: p.Name, Weight: weight, BribedBy: p.ID}
+	setToastLocked(store, p.ID, fmt.Sprintf("%s's %d votes are yours.", voter.Name, weight))
+	setToastLocked(store, voter.ID, fmt.Sprintf("%s pays you %dg for your vote for %s.", p.Name, amount, seat.Name))
+}
+
+// tallyElectionLocked counts the ballots and campaign spending, best first.
+// Ties go to a registered candidate over a write-in, then to the bigger
+// campaign, the earlier filing and finally the name.
+func tallyElectionLocked(store *Store, seat *Seat) []electionTally {
+	byID := map[string]*electionTally{}
+	for _, c := range seat.Candidates {
+		if store.Players[c.PlayerID] == nil {
+			continue
+		}
+		byID[c.PlayerID] = &electionTally{PlayerID: c.PlayerID, Name: c.Name, Votes: c.Spending / electionGoldPerVote, Registered: true, Spending: c.Spending, Tick: c.RegisteredTick}
+	}
+	for _, voterID := range sortedKeys(seat.Ballots) {
+		b := seat.Ballots[voterID]
+		if store.Players[voterID] == nil || store.Players[b.CandidateID] == nil {
+			continue
+		}
+		t := byID[b.CandidateID]
+		if t == nil {
+			t = &electionTally{PlayerID: b.CandidateID, Name: b.CandidateName, Tick: store.TickCount}
+			byID[b.CandidateID] = t
+		}
+		t.Votes += b.Weight
+	}
+	tallies := make([]electionTally, 0, len(byID))
+	for _, id := range sortedKeys(byID) {
+		tallies = append(tallies, *byID[id])
+	}
+	slices.SortFunc(tallies, func(a, b electionTally) int {
+		switch {
+		case a.Votes != b.Votes:
+			return b.Votes - a.Votes
+		case a.Registered != b.Registered:
+			if a.Registered {
+				return -1
+			}
+			return 1
+		case a.Spending != b.Spending:
+			return b.Spending - a.Spending
+		case a.Tick != b.Tick:
+			if a.Tick < b.Tick {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return tallies
+}
+
+// resolveElectionLocked closes the polls and publishes the count. With no
+// candidates and no write-ins the seat returns to its appointed holder.
+func resolveElectionLocked(store *Store, seat *Seat, now time.Time) {
+	tallies := tallyElectionLocked(store, seat)
+	seat.Candidates = nil
+	seat.Ballots = nil
+	seat.TenureTicksLeft = seatTenureTicks
+	if len(tallies) == 0 {
+		seat.HolderPlayerID = ""
+		seat.HolderName = seatDefaultHolderName(seat.ID)
+		addEventLocked(store, Event{
+			Type:     "Institution",
+			Severity: 1,
+			Text:     fmt.Sprintf("Nobody stands for %s; %s holds it by appointment.", seat.Name, seat.HolderName),
+			At:       now,
+		})
+		return
+	}
+	counts := make([]string, 0, len(tallies))
+	for _, t := range tallies {
+		label := fmt.Sprintf("[%s] %d", t.Name, t.Votes)
+		if !t.Registered {
+			label += " (write-in)"
+		}
+		counts = append(counts, label)
+	}
+	winner := tallies[0]
+	seat.HolderPlayerID = winner.PlayerID
+	seat.HolderName = winner.Name
+	addEventLocked(store, Event{
+		Type:     "Institution",
+		Severity: 2,
+		Text:     fmt.Sprintf("Election for %s: %s. [%s] takes the seat.", seat.Name, strings.Join(counts, ", "), winner.Name),
+		At:       now,
+	})
+	setToastLocked(store, winner.PlayerID, fmt.Sprintf("You are elected %s.", seat.Name))
+}
+
+func candidateViewsLocked(seat *Seat, p *Player) []CandidateView {
+	views := make([]CandidateView, 0, len(seat.Candidates))
+	vote := seat.Ballots[p.ID]
+	for _, c := range seat.Candidates {
+		views = append(views, CandidateView{
+			ID:       c.PlayerID,
+			Name:     c.Name,
+			Spending: c.Spending,
+			Mine:     c.PlayerID == p.ID,
+			MyVote:   vote.CandidateID == c.PlayerID,
+		})
+	}
+	return views
+}
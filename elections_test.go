@@ -0,0 +1,161 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func openTestElection(s *Store, seatID string) *Seat {
+	seat := s.Seats[seatID]
+	seat.ElectionWindowTicks = 1
+	seat.TenureTicksLeft = 0
+	return seat
+}
+
+func TestElectionCountsWeightedBallotsAndSpending(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	ash := &Player{ID: "p1", Name: "Ash Crow (Guest)", Gold: 30, Rep: 10, LastSeen: now}
+	bram := &Player{ID: "p2", Name: "Bram Vale (Guest)", Gold: 30, Rep: 10, LastSeen: now}
+	cole := &Player{ID: "p3", Name: "Cole Reed (Guest)", Rep: 50, LastSeen: now}
+	dara := &Player{ID: "p4", Name: "Dara Fenn (Guest)", Rep: 0, LastSeen: now}
+	for _, p := range []*Player{ash, bram, cole, dara} {
+		s.Players[p.ID] = p
+	}
+	s.Seats["harbor_master"].HolderPlayerID = dara.ID
+	seat := openTestElection(s, "master_of_coin")
+	city := s.Institutions["city_authority"]
+	opening := city.Treasury
+
+	handleActionInputLocked(s, ash, now, ActionInput{Action: "campaign_seat", ContractID: seat.ID})
+	handleActionInputLocked(s, bram, now, ActionInput{Action: "campaign_seat", ContractID: seat.ID})
+	if seat.HolderPlayerID != "" || len(seat.Candidates) != 2 {
+		t.Fatalf("campaigning should register candidates, not grant the seat: %+v", seat)
+	}
+	if ash.Gold != 30-electionFilingFee || city.Treasury != opening+2*electionFilingFee {
+		t.Fatalf("filing fees should be paid into the treasury: gold %d treasury %d", ash.Gold, city.Treasury)
+	}
+	handleActionInputLocked(s, ash, now, ActionInput{Action: "campaign_seat", ContractID: seat.ID})
+	if s.rejections[ash.ID] != errCodeNotAllowed {
+		t.Fatalf("a candidate cannot file twice, got %q", s.rejections[ash.ID])
+	}
+
+	if got := ballotWeightLocked(s, seat, dara); got != 0 {
+		t.Fatalf("a Merchant League seat should not carry a City Authority vote, weight %d", got)
+	}
+	handleActionInputLocked(s, dara, now, ActionInput{Action: "cast_ballot", ContractID: seat.ID, TargetID: ash.ID})
+	if s.rejections[dara.ID] != errCodeNotAllowed {
+		t.Fatalf("ineligible voters should be turned away, got %q", s.rejections[dara.ID])
+	}
+	s.Seats["watch_commander"].HolderPlayerID = dara.ID
+	if got := ballotWeightLocked(s, seat, dara); got != 1+electionMemberVotes {
+		t.Fatalf("members of the institution vote without standing, weight %d", got)
+	}
+
+	handleActionInputLocked(s, cole, now, ActionInput{Action: "cast_ballot", ContractID: seat.ID, TargetID: bram.ID})
+	handleActionInputLocked(s, ash, now, ActionInput{Action: "cast_ballot", ContractID: seat.ID, TargetID: ash.ID})
+	handleActionInputLocked(s, bram, now, ActionInput{Action: "cast_ballot", ContractID: seat.ID, TargetID: bram.ID})
+	handleActionInputLocked(s, ash, now, ActionInput{Action: "campaign_spend", ContractID: seat.ID, Amount: 25})
+	if got := seatCandidate(seat, ash.ID).Spending; got != 25 || ash.Gold != 2 {
+		t.Fatalf("campaign spending should be recorded: %d, gold %d", got, ash.Gold)
+	}
+	tally := tallyElectionLocked(s, seat)
+	// Cole's 50 rep casts three votes; Ash's 25g buys five.
+	if len(tally) != 2 || tally[0].PlayerID != ash.ID || tally[0].Votes != 6 || tally[1].Votes != 4 {
+		t.Fatalf("unexpected tally: %+v", tally)
+	}
+
+	s.Events = nil
+	runWorldTickLocked(s, now)
+	if seat.HolderPlayerID != ash.ID || seat.TenureTicksLeft != seatTenureTicks || seat.Candidates != nil || seat.Ballots != nil {
+		t.Fatalf("the winner should take the seat and the rolls be cleared: %+v", seat)
+	}
+	found := false
+	for _, e := range s.Events {
+		if strings.Contains(e.Text, "Election for Master of Coin: [Ash Crow (Guest)] 6, [Bram Vale (Guest)] 4. [Ash Crow (Guest)] takes the seat.") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("the result should be published with vote counts: %+v", s.Events)
+	}
+}
+
+func TestElectionTiesAndWriteIns(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	ash := &Player{ID: "p1", Name: "Ash Crow (Guest)", Gold: 10, Rep: 10, LastSeen: now}
+	bram := &Player{ID: "p2", Name: "Bram Vale (Guest)", Gold: 10, Rep: 10, LastSeen: now}
+	cole := &Player{ID: "p3", Name: "Cole Reed (Guest)", Rep: 10, LastSeen: now}
+	for _, p := range []*Player{ash, bram, cole} {
+		s.Players[p.ID] = p
+	}
+	seat := openTestElection(s, "high_curate")
+
+	handleActionInputLocked(s, ash, now, ActionInput{Action: "cast_ballot", ContractID: seat.ID, TargetID: cole.ID})
+	if b := seat.Ballots[ash.ID]; !b.WriteIn || b.CandidateID != cole.ID {
+		t.Fatalf("a vote for an unregistered player should be a write-in: %+v", b)
+	}
+	if tally := tallyElectionLocked(s, seat); len(tally) != 1 || tally[0].Registered {
+		t.Fatalf("a write-in should be counted: %+v", tally)
+	}
+
+	s.TickCount = 1
+	handleActionInputLocked(s, bram, now, ActionInput{Action: "campaign_seat", ContractID: seat.ID})
+	handleActionInputLocked(s, bram, now, ActionInput{Action: "cast_ballot", ContractID: seat.ID, TargetID: bram.ID})
+	if tally := tallyElectionLocked(s, seat); tally[0].PlayerID != bram.ID {
+		t.Fatalf("a registered candidate should win a tie against a write-in: %+v", tally)
+	}
+
+	s.TickCount = 2
+	handleActionInputLocked(s, ash, now, ActionInput{Action: "campaign_seat", ContractID: seat.ID})
+	handleActionInputLocked(s, ash, now, ActionInput{Action: "cast_ballot", ContractID: seat.ID, TargetID: ash.ID})
+	handleActionInputLocked(s, cole, now, ActionInput{Action: "cast_ballot", ContractID: seat.ID, TargetID: cole.ID})
+	if tally := tallyElectionLocked(s, seat); tally[0].PlayerID != bram.ID || tally[1].PlayerID != ash.ID {
+		t.Fatalf("the earlier filing should win a tied count: %+v", tally)
+	}
+	handleActionInputLocked(s, ash, now, ActionInput{Action: "campaign_spend", ContractID: seat.ID, Amount: 1})
+	if tally := tallyElectionLocked(s, seat); tally[0].PlayerID != ash.ID {
+		t.Fatalf("the bigger campaign should win a tied count: %+v", tally)
+	}
+}
+
+func TestBriberyBuysBallotsUnlessCaught(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	ash := &Player{ID: "p1", Name: "Ash Crow (Guest)", Gold: 500, Rep: 10, LastSeen: now}
+	voter := &Player{ID: "p2", Name: "Bram Vale (Guest)", Rep: 10, LastSeen: now}
+	s.Players[ash.ID], s.Players[voter.ID] = ash, voter
+	seat := openTestElection(s, "watch_commander")
+
+	handleActionInputLocked(s, ash, now, ActionInput{Action: "bribe_voter", ContractID: seat.ID, TargetID: voter.ID, Amount: electionBribeMin})
+	if s.rejections[ash.ID] != errCodeNotAllowed {
+		t.Fatalf("only candidates may buy votes, got %q", s.rejections[ash.ID])
+	}
+	handleActionInputLocked(s, ash, now, ActionInput{Action: "campaign_seat", ContractID: seat.ID})
+
+	// Seed 13 opens the intel stream with 99 at tick 16 and 7 at tick 4.
+	s.TickCount = 16
+	gold := voter.Gold
+	handleActionInputLocked(s, ash, now, ActionInput{Action: "bribe_voter", ContractID: seat.ID, TargetID: voter.ID, Amount: electionBribeMin})
+	if voter.Gold != gold+electionBribeMin {
+		t.Fatalf("the voter should be paid: %d -> %d", gold, voter.Gold)
+	}
+	if b, ok := seat.Ballots[voter.ID]; !ok || b.CandidateID != ash.ID || b.BribedBy != ash.ID || ash.Rep != 10 {
+		t.Fatalf("a bought ballot should go to the briber: %+v", seat.Ballots)
+	}
+
+	delete(seat.Ballots, voter.ID)
+	s.TickCount = 4
+	handleActionInputLocked(s, ash, now, ActionInput{Action: "bribe_voter", ContractID: seat.ID, TargetID: voter.ID, Amount: electionBribeMin})
+	if _, ok := seat.Ballots[voter.ID]; ok {
+		t.Fatalf("a caught bribe should strike the ballot: %+v", seat.Ballots)
+	}
+	if ash.Rep != 10-4 || !strings.Contains(s.Events[len(s.Events)-1].Text, "caught buying") {
+		t.Fatalf("a caught bribe should be public and cost standing: rep %d", ash.Rep)
+	}
+	if ash.Heat == 0 {
+		t.Fatalf("bribery should draw heat")
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// factionActEveryTicks is how often an institution's appointees decide on a
+// lever of their own.
+const factionActEveryTicks = 2
+
+// FactionGoals steer an institution while appointees rather than players
+// hold its seats.
+type FactionGoals struct {
+	// CalmUnrest is the unrest the institution tolerates; above it the
+	// appointees spend on calm, above twice it they cut taxes.
+	CalmUnrest int
+	// TaxPct is the rate the Master of Coin's appointee sets in calm times.
+	TaxPct int
+	// Reserve is treasury the appointees will not spend.
+	Reserve int
+	// MaxBandits is bandit activity tolerated before paying for patrols.
+	MaxBandits int
+	// PermitRep is the reputation a trader needs before the harbor sells
+	// them a permit.
+	PermitRep int
+	// Projects are the public works the institution funds, in preference
+	// order.
+	Projects []string
+}
+
+var factionGoals = map[string]FactionGoals{
+	"city_authority":  {CalmUnrest: 30, TaxPct: 20, Reserve: 15, MaxBandits: 8, Projects: []string{"granary_reinforcement", "civic_patrols"}},
+	"merchant_league": {CalmUnrest: 35, Reserve: 10, MaxBandits: 5, PermitRep: 5},
+	"temple":          {CalmUnrest: 20, Reserve: 5, Projects: []string{"public_festival", "ward_lanterns"}},
+}
+
+// processFactionTickLocked runs the institutions' standing orders, which
+// stand whoever holds the seats, then lets each institution's appointees
+// pull one lever on the seats no player holds. The levers are the ones a
+// player seat holder uses.
+func processFactionTickLocked(store *Store, now time.Time) {
+	processStandingOrdersLocked(store, now)
+	for _, def := range institutionDefinitions {
+		inst := store.Institutions[def.ID]
+		if inst == nil || store.TickCount-inst.LastActTick < factionActEveryTicks {
+			continue
+		}
+		if factionDecideLocked(store, inst, now) {
+			inst.LastActTick = store.TickCount
+		}
+	}
+}
+
+func processStandingOrdersLocked(store *Store, now time.Time) {
+	w := &store.World
+	if (w.UnrestTier == "Unstable" || w.UnrestTier == "Rioting" || w.GrainTier == "Critical") && !hasActiveContractLocked(store, "Emergency") {
+		issueContractLocked(store, "Emergency", 4)
+		addEventLocked(store, Event{Type: "Faction", Severity: 3, Text: "[City Authority] requisitions emergency shipments.", At: now})
+	}
+	if w.UnrestTier == "Rioting" && w.RestrictedMarketsTicks == 0 {
+		w.RestrictedMarketsTicks = 2
+		addEventLocked(store, Event{Type: "Faction", Severity: 4, Text: "[City Authority] imposes strict market controls.", At: now})
+	}
+	if (w.GrainTier == "Scarce" || w.GrainTier == "Critical") && !hasActiveContractLocked(store, "Smuggling") {
+		issueContractLocked(store, "Smuggling", 3)
+		addEventLocked(store, Event{Type: "Faction", Severity: 3, Text: "[Merchant League] issues smuggling orders.", At: now})
+	}
+	for _, target := range wantedPlayersLocked(store, now) {
+		if hasActiveBountyForTargetLocked(store, target.ID) {
+			continue
+		}
+		issueBountyContractLocked(store, target, bountyDeadlineTicks)
+		addEventLocked(store, Event{Type: "Law", Severity: 3, Text: fmt.Sprintf("[City Watch] posts a bounty on [%s].", target.Name), At: now})
+	}
+}
+
+// wantedPlayersLocked lists the active players whose heat makes them wanted.
+func wantedPlayersLocked(store *Store, now time.Time) []*Player {
+	var wanted []*Player
+	for _, id := range sortedKeys(store.Players) {
+		p := store.Players[id]
+		if p == nil || !isWantedHeat(p.Heat) || now.Sub(p.LastSeen) > inactiveWindow {
+			continue
+		}
+		wanted = append(wanted, p)
+	}
+	return wanted
+}
+
+// factionDecideLocked pulls the first lever inst's appointees want, seat by
+// seat, and reports whether it acted.
+func factionDecideLocked(store *Store, inst *Institution, now time.Time) bool {
+	goals := factionGoals[inst.ID]
+	for _, id := range sortedKeys(store.Seats) {
+		seat := store.Seats[id]
+		if seat.InstitutionID != inst.ID || seat.HolderPlayerID != "" || seat.ElectionWindowTicks > 0 {
+			continue
+		}
+		var acted bool
+		switch seat.ID {
+		case "master_of_coin":
+			acted = coinAppointeeLocked(store, inst, seat, goals, now)
+		case "watch_commander":
+			acted = watchAppointeeLocked(store, inst, seat, goals, now)
+		case "harbor_master":
+			acted = harborAppointeeLocked(store, inst, seat, goals, now)
+		case "high_curate":
+			acted = curateAppointeeLocked(store, inst, seat, goals, now)
+		}
+		if acted {
+			return true
+		}
+	}
+	return false
+}
+
+// affordLocked reports whether inst can pay cost and keep its reserve.
+func affordLocked(inst *Institution, goals FactionGoals, cost int) bool {
+	return inst.Treasury-cost >= goals.Reserve
+}
+
+func coinAppointeeLocked(store *Store, inst *Institution, seat *Seat, goals FactionGoals, now time.Time) bool {
+	w := store.World
+	switch {
+	case w.UnrestValue > 2*goals.CalmUnrest && store.Policies.TaxRatePct != 5:
+		setTaxRateLocked(store, now, seat.HolderName, 5)
+	case w.UnrestValue > goals.CalmUnrest && affordLocked(inst, goals, treasuryReliefCost):
+		fundReliefLocked(store, now, inst, seat.HolderName, nil)
+	case w.BanditActivity > goals.MaxBandits && affordLocked(inst, goals, treasuryPatrolCost):
+		fundPatrolLocked(store, now, inst, seat.HolderName, nil)
+	case w.UnrestValue <= goals.CalmUnrest && goals.TaxPct > 0 && store.Policies.TaxRatePct != goals.TaxPct:
+		setTaxRateLocked(store, now, seat.HolderName, goals.TaxPct)
+	default:
+		return fundFactionProjectLocked(store, inst, seat, goals, now)
+	}
+	return true
+}
+
+func watchAppointeeLocked(store *Store, inst *Institution, seat *Seat, goals FactionGoals, now time.Time) bool {
+	for _, target := range wantedPlayersLocked(store, now) {
+		if !hasActiveWarrantLocked(store, target.ID) {
+			issueWarrantLocked(store, now, "", seat.HolderName, target)
+			return true
+		}
+	}
+	if store.World.BanditActivity > goals.MaxBandits && affordLocked(inst, goals, treasuryPatrolCost) {
+		fundPatrolLocked(store, now, inst, seat.HolderName, nil)
+		return true
+	}
+	return false
+}
+
+// harborAppointeeLocked embargoes the docks while wanted smugglers are
+// about and the city can spare the smuggled grain, requires permits only
+// when the city is near riot, since permits slow trade, and sells them to
+// reputable traders meanwhile.
+func harborAppointeeLocked(store *Store, inst *Institution, seat *Seat, goals FactionGoals, now time.Time) bool {
+	unrest := store.World.UnrestValue
+	switch {
+	case store.Policies.SmugglingEmbargoTicks == 0 && store.World.GrainTier == "Stable" && len(wantedPlayersLocked(store, now)) > 0:
+		setEmbargoLocked(store, now, seat.HolderName, true)
+		return true
+	case !store.Policies.PermitRequiredHighRisk && unrest > 2*goals.CalmUnrest:
+		setPermitPolicyLocked(store, now, seat.HolderName, true)
+		return true
+	case store.Policies.PermitRequiredHighRisk && unrest <= goals.CalmUnrest:
+		setPermitPolicyLocked(store, now, seat.HolderName, false)
+		return true
+	}
+	if store.Policies.PermitRequiredHighRisk {
+		for _, id := range sortedKeys(store.Players) {
+			p := store.Players[id]
+			if now.Sub(p.LastSeen) > inactiveWindow || hasActivePermitLocked(store, p.ID) || p.Rep < goals.PermitRep || p.Gold < permitFeeGold {
+				continue
+			}
+			grantPermitLocked(store, now, "", seat.HolderName, p)
+			return true
+		}
+	}
+	if store.World.BanditActivity > goals.MaxBandits && affordLocked(inst, goals, treasuryPatrolCost) {
+		fundPatrolLocked(store, now, inst, seat.HolderName, nil)
+		return true
+	}
+	return fundFactionProjectLocked(store, inst, seat, goals, now)
+}
+
+func curateAppointeeLocked(store *Store, inst *Institution, seat *Seat, goals FactionGoals, now time.Time) bool {
+	if store.World.UnrestValue > goals.CalmUnrest && affordLocked(inst, goals, treasuryReliefCost) {
+		fundReliefLocked(store, now, inst, seat.HolderName, nil)
+		return true
+	}
+	return fundFactionProjectLocked(store, inst, seat, goals, now)
+}
+
+// fundFactionProjectLocked commissions the first of the institution's
+// preferred public works that is not already underway and that the
+// treasury and granary can spare.
+func fundFactionProjectLocked(store *Store, inst *Institution, seat *Seat, goals FactionGoals, now time.Time) bool {
+	if len(store.Projects) >= projectMaxActive {
+		return false
+	}
+	for _, projectType := range goals.Projects {
+		def, ok := projectDefinitionByType(projectType)
+		if !ok || len(def.Costs) > 0 || projectUnderwayLocked(store, def.Type) || !affordLocked(inst, goals, def.CostGold) {
+			continue
+		}
+		if def.WardNetworkTicks > 0 && store.World.WardNetworkTicks > 0 {
+			continue
+		}
+		if def.CostGrain > 0 && grainTierFromSupply(store.World.GrainSupply) != "Stable" {
+			continue
+		}
+		commissionProjectLocked(store, now, inst, def, seat.HolderName, nil)
+		return true
+	}
+	return false
+}
+
+func projectUnderwayLocked(store *Store, projectType string) bool {
+	for _, proj := range store.Projects {
+		if proj.Type == projectType {
+			return true
+		}
+	}
+	return false
+}
+
+// The levers below are shared by player seat holders and appointees; actor
+// is the holder's name for the event log.
+
+func setTaxRateLocked(store *Store, now time.Time, actor string, pct int) {
+	if pct < store.Policies.TaxRatePct {
+		addEventLocked(store, Event{Type: "Policy", Severity: 2, Text: fmt.Sprintf("[%s] lowers tax to %d%%.", actor, pct), At: now})
+	} else {
+		addEventLocked(store, Event{Type: "Policy", Severity: 3, Text: fmt.Sprintf("[%s] raises tax to %d%%.", actor, pct), At: now})
+	}
+	store.Policies.TaxRatePct = pct
+}
+
+func setPermitPolicyLocked(store *Store, now time.Time, actor string, required bool) {
+	store.Policies.PermitRequiredHighRisk = required
+	state := "lifted"
+	if required {
+		state = "required"
+	}
+	addEventLocked(store, Event{Type: "Policy", Severity: 2, Text: fmt.Sprintf("[%s] marks emergency permits as %s.", actor, state), At: now})
+}
+
+func setEmbargoLocked(store *Store, now time.Time, actor string, on bool) {
+	if on {
+		store.Policies.SmugglingEmbargoTicks = 3
+		addEventLocked(store, Event{Type: "Policy", Severity: 3, Text: fmt.Sprintf("[%s] imposes a smuggling embargo.", actor), At: now})
+		return
+	}
+	store.Policies.SmugglingEmbargoTicks = 0
+	addEventLocked(store, Event{Type: "Policy", Severity: 2, Text: fmt.Sprintf("[%s] lifts the smuggling embargo.", actor), At: now})
+}
+
+// grantPermitLocked sells target a permit, the fee going to the Harbor
+// Master's institution. issuerID is empty for an appointee.
+func grantPermitLocked(store *Store, now time.Time, issuerID, issuerName string, target *Player) {
+	store.dirty.mark(dirtyPlayers, target.ID)
+	target.Gold -= permitFeeGold
+	_, inst := institutionForSeatLocked(store, "harbor_master")
+	recordTreasuryLocked(store, now, inst, permitFeeGold, "Permit fee", target)
+	store.dirty.mark(dirtyPermits, target.ID)
+	store.Permits[target.ID] = &Permit{
+		PlayerID:     target.ID,
+		PlayerName:   target.Name,
+		IssuerID:     issuerID,
+		IssuerName:   issuerName,
+		TicksLeft:    permitDurationTicks,
+		TotalTicks:   permitDurationTicks,
+		IssuedAtTick: store.TickCount,
+	}
+	addEventLocked(store, Event{Type: "Policy", Severity: 2, Text: fmt.Sprintf("[%s] issues a permit to [%s].", issuerName, target.Name), At: now})
+	setToastLocked(store, target.ID, fmt.Sprintf("You pay a %dg permit fee.", permitFeeGold))
+}
+
+// issueWarrantLocked puts target under warrant, posts a bounty if none is
+// out and files a case for the court. issuerID is empty for an appointee.
+func issueWarrantLocked(store *Store, now time.Time, issuerID, issuerName string, target *Player) {
+	store.dirty.mark(dirtyWarrants, target.ID)
+	store.Warrants[target.ID] = &Warrant{
+		PlayerID:     target.ID,
+		PlayerName:   target.Name,
+		IssuerID:     issuerID,
+		IssuerName:   issuerName,
+		TicksLeft:    warrantDurationTicks,
+		TotalTicks:   warrantDurationTicks,
+		IssuedAtTick: store.TickCount,
+	}
+	store.dirty.mark(dirtyPlayers, target.ID)
+	target.Heat = clampInt(target.Heat+warrantHeatDelta, 0, 20)
+	target.Rep = clampInt(target.Rep-1, -100, 100)
+	addEventLocked(store, Event{Type: "Law", Severity: 3, Text: fmt.Sprintf("[%s] issues a warrant on [%s].", issuerName, target.Name), At: now})
+	if !hasActiveBountyForTargetLocked(store, target.ID) {
+		issueBountyContractLocked(store, target, bountyDeadlineTicks)
+		addEventLocked(store, Event{Type: "Law", Severity: 3, Text: fmt.Sprintf("[City Watch] posts a warrant bounty on [%s].", target.Name), At: now})
+	}
+	openCaseLocked(store, now, target, issuerID, issuerName, "crimes against the city")
+	setToastLocked(store, target.ID, "A warrant has been issued in your name.")
+}
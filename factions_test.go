@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func lastEventText(s *Store) string {
+	if len(s.Events) == 0 {
+		return ""
+	}
+	return s.Events[len(s.Events)-1].Text
+}
+
+func TestCityAuthorityAppointeesAnswerUnrest(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	city := s.Institutions["city_authority"]
+	city.Treasury = 60
+	s.Policies.TaxRatePct = 20
+	s.World.UnrestValue = 70
+
+	if !factionDecideLocked(s, city, now) || s.Policies.TaxRatePct != 5 || lastEventText(s) != "[Clerk Marn (NPC)] lowers tax to 5%." {
+		t.Fatalf("a rioting city should get a tax cut: %d%%, %q", s.Policies.TaxRatePct, lastEventText(s))
+	}
+	factionDecideLocked(s, city, now)
+	if city.Treasury != 60-treasuryReliefCost || s.World.UnrestValue != 64 {
+		t.Fatalf("the appointee should pay for relief: treasury %d unrest %d", city.Treasury, s.World.UnrestValue)
+	}
+	if got := treasuryLedgerViewsLocked(s, 1)[0]; got.Memo != "Relief wagons" {
+		t.Fatalf("relief should be on the public ledger: %+v", got)
+	}
+
+	s.World.UnrestValue = 10
+	factionDecideLocked(s, city, now)
+	if s.Policies.TaxRatePct != 20 {
+		t.Fatalf("a calm city should be taxed at the authority's rate, got %d%%", s.Policies.TaxRatePct)
+	}
+	factionDecideLocked(s, city, now)
+	if len(s.Projects) != 1 || city.Treasury != 60-treasuryReliefCost-10 {
+		t.Fatalf("spare treasury should fund public works: %+v treasury %d", s.Projects, city.Treasury)
+	}
+	for _, proj := range s.Projects {
+		if proj.Type != "granary_reinforcement" || proj.OwnerPlayerID != "" || proj.OwnerName != "Clerk Marn (NPC)" {
+			t.Fatalf("unexpected project %+v", proj)
+		}
+	}
+
+	city.Treasury = factionGoals["city_authority"].Reserve
+	s.World.UnrestValue = 50
+	if factionDecideLocked(s, city, now) {
+		t.Fatalf("appointees should keep the reserve: %q", lastEventText(s))
+	}
+}
+
+func TestPlayerHeldSeatsSilenceTheirAppointee(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	coin := &Player{ID: "p1", Name: "Ash Crow (Guest)", LastSeen: now}
+	wanted := &Player{ID: "p2", Name: "Bram Vale (Guest)", Heat: 12, LastSeen: now}
+	s.Players[coin.ID], s.Players[wanted.ID] = coin, wanted
+	s.Seats["master_of_coin"].HolderPlayerID = coin.ID
+	city := s.Institutions["city_authority"]
+	s.Policies.TaxRatePct = 20
+	s.World.UnrestValue = 70
+
+	factionDecideLocked(s, city, now)
+	if s.Policies.TaxRatePct != 20 {
+		t.Fatalf("a player-held seat should not be overridden")
+	}
+	if !hasActiveWarrantLocked(s, wanted.ID) || s.Warrants[wanted.ID].IssuerName != "Marshal Dain (NPC)" {
+		t.Fatalf("the Watch appointee should warrant wanted players: %+v", s.Warrants[wanted.ID])
+	}
+
+	city.LastActTick = s.TickCount
+	processFactionTickLocked(s, now)
+	if len(s.Warrants) != 1 || city.LastActTick != s.TickCount {
+		t.Fatalf("appointees should wait %d ticks between decisions", factionActEveryTicks)
+	}
+}
+
+func TestHarborAppointeeRunsTheDocks(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	trader := &Player{ID: "p1", Name: "Ash Crow (Guest)", Gold: 10, Rep: 20, LastSeen: now}
+	smuggler := &Player{ID: "p2", Name: "Bram Vale (Guest)", Heat: 12, LastSeen: now}
+	s.Players[trader.ID], s.Players[smuggler.ID] = trader, smuggler
+	league := s.Institutions["merchant_league"]
+	s.World.GrainTier = "Stable"
+	s.World.UnrestValue = 80
+
+	factionDecideLocked(s, league, now)
+	if s.Policies.SmugglingEmbargoTicks == 0 || !strings.HasPrefix(lastEventText(s), "[Captain Vey (NPC)] imposes") {
+		t.Fatalf("wanted smugglers should close the docks: %q", lastEventText(s))
+	}
+	factionDecideLocked(s, league, now)
+	if !s.Policies.PermitRequiredHighRisk {
+		t.Fatalf("a city near riot should require permits")
+	}
+	opening := league.Treasury
+	factionDecideLocked(s, league, now)
+	if !hasActivePermitLocked(s, trader.ID) || trader.Gold != 10-permitFeeGold || league.Treasury != opening+permitFeeGold {
+		t.Fatalf("the harbor should sell permits to reputable traders: gold %d treasury %d", trader.Gold, league.Treasury)
+	}
+	if hasActivePermitLocked(s, smuggler.ID) {
+		t.Fatalf("no permit for a penniless smuggler")
+	}
+
+	s.World.UnrestValue = 10
+	factionDecideLocked(s, league, now)
+	if s.Policies.PermitRequiredHighRisk {
+		t.Fatalf("permits should be lifted once the city calms")
+	}
+}
@@ -0,0 +1,617 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	journalKindAction   = "action"
+	journalKindChat     = "chat"
+	journalKindMissive  = "missive"
+	journalKindTick     = "tick"
+	journalKindJoin     = "join"
+	journalKindCleanup  = "cleanup"
+	journalKindReset    = "reset"
+	journalKindRollback = "rollback"
+	journalKindContent  = "content"
+	journalKindToken    = "token"
+
+	journalSnapshotEveryTicks = 24
+	journalRetention          = 30 * 24 * time.Hour
+	maxJournalPending         = 10000
+	maxSnapshotsPending       = 4
+	maxJournalListing         = 500
+)
+
+// MissiveInput is a diplomatic message as submitted through /message.
+type MissiveInput struct {
+	TargetID string
+	Subject  string
+	Body     string
+	Sealed   bool
+}
+
+// JournalEntry is one recorded input to the world. Entries are append-only
+// and carry everything needed to re-apply them to a restored snapshot.
+type JournalEntry struct {
+	ID       int64                `json:"id"`
+	Tick     int64                `json:"tick"`
+	At       time.Time            `json:"at"`
+	Kind     string               `json:"kind"`
+	PlayerID string               `json:"player_id,omitempty"`
+	Name     string               `json:"name,omitempty"`
+	Role     string               `json:"role,omitempty"`
+	Action   *ActionInput         `json:"action,omitempty"`
+	Text     string               `json:"text,omitempty"`
+	Missive  *MissiveInput        `json:"missive,omitempty"`
+	Daily    bool                 `json:"daily,omitempty"`
+	Seen     map[string]time.Time `json:"seen,omitempty"`
+	Seed     int64                `json:"seed,omitempty"`
+	Content  *GameContent         `json:"content,omitempty"`
+	// TokenHash is the player's new API token hash; empty when revoked. The
+	// token itself is never journaled.
+	TokenHash string `json:"token_hash,omitempty"`
+}
+
+// JournalFilter narrows an admin journal listing. Zero values match all.
+type JournalFilter struct {
+	FromTick int64
+	ToTick   int64
+	PlayerID string
+}
+
+// storeSnapshot is the full serializable world, including terminal contracts
+// that the row tables do not keep.
+type storeSnapshot struct {
+	World          WorldState
+	Policies       PolicyState
+	Runtime        runtimeState
+	Players        map[string]*Player
+	Contracts      map[string]*Contract
+	Institutions   map[string]*Institution
+	Seats          map[string]*Seat
+	Rumors         map[int64]*Rumor
+	Evidence       map[int64]*Evidence
+	ScryReports    map[int64]*ScryReport
+	Intercepts     map[int64]*InterceptedMessage
+	Loans          map[string]*Loan
+	Obligations    map[string]*Obligation
+	Permits        map[string]*Permit
+	Warrants       map[string]*Warrant
+	Relics         map[int64]*Relic
+	Projects       map[string]*Project
+	Orders         map[string]*MarketOrder
+	Caravans       map[string]*Caravan
+	Buildings      map[string]*Building
+	Cases          map[string]*CourtCase
+	Clues          map[string]*TheftClue
+	Fights         map[string]*Fight
+	ActiveCrisis   *Crisis
+	Content        *GameContent
+	Events         []Event
+	Chat           []ChatMessage
+	Messages       []DiplomaticMessage
+	MarketHistory  []MarketTick
+	TreasuryLedger []TreasuryEntry
+}
+
+type snapshotRow struct {
+	ID        int64
+	Tick      int64
+	JournalID int64
+	At        time.Time
+	Payload   string
+}
+
+func snapshotStoreLocked(store *Store) storeSnapshot {
+	return storeSnapshot{
+		World:          store.World,
+		Policies:       store.Policies,
+		Runtime:        runtimeStateFromStore(store),
+		Players:        store.Players,
+		Contracts:      store.Contracts,
+		Institutions:   store.Institutions,
+		Seats:          store.Seats,
+		Rumors:         store.Rumors,
+		Evidence:       store.Evidence,
+		ScryReports:    store.ScryReports,
+		Intercepts:     store.Intercepts,
+		Loans:          store.Loans,
+		Obligations:    store.Obligations,
+		Permits:        store.Permits,
+		Warrants:       store.Warrants,
+		Relics:         store.Relics,
+		Projects:       store.Projects,
+		Orders:         store.Orders,
+		Caravans:       store.Caravans,
+		Buildings:      store.Buildings,
+		Cases:          store.Cases,
+		Clues:          store.Clues,
+		Fights:         store.Fights,
+		ActiveCrisis:   store.ActiveCrisis,
+		Content:        currentContent(),
+		Events:         store.Events,
+		Chat:           store.Chat,
+		Messages:       store.Messages,
+		MarketHistory:  store.MarketHistory,
+		TreasuryLedger: store.TreasuryLedger,
+	}
+}
+
+// restoreStoreSnapshot decodes a snapshot payload into a fresh, repo-less
+// Store whose collections share nothing with the original.
+func restoreStoreSnapshot(payload string) (*Store, error) {
+	var snap storeSnapshot
+	if err := json.Unmarshal([]byte(payload), &snap); err != nil {
+		return nil, fmt.Errorf("decode snapshot: %w", err)
+	}
+	s := newStore()
+	s.World = snap.World
+	s.Policies = snap.Policies
+	applyRuntimeState(s, snap.Runtime)
+	s.Players = snap.Players
+	s.Contracts = snap.Contracts
+	s.Institutions = snap.Institutions
+	s.Seats = snap.Seats
+	s.Rumors = snap.Rumors
+	s.Evidence = snap.Evidence
+	s.ScryReports = snap.ScryReports
+	s.Intercepts = snap.Intercepts
+	s.Loans = snap.Loans
+	s.Obligations = snap.Obligations
+	s.Permits = snap.Permits
+	s.Warrants = snap.Warrants
+	s.Relics = snap.Relics
+	s.Projects = snap.Projects
+	s.Orders = snap.Orders
+	s.Caravans = snap.Caravans
+	s.Buildings = snap.Buildings
+	s.Cases = snap.Cases
+	s.Clues = snap.Clues
+	s.Fights = snap.Fights
+	s.ActiveCrisis = snap.ActiveCrisis
+	if snap.Content != nil {
+		snap.Content.indexRoutes()
+		s.content = snap.Content
+	}
+	s.Events = snap.Events
+	s.Chat = snap.Chat
+	s.Messages = snap.Messages
+	s.MarketHistory = snap.MarketHistory
+	s.TreasuryLedger = snap.TreasuryLedger
+	ensureCollectionMaps(s)
+	ensureSeatsLocked(s)
+	s.journalPending = nil
+	s.snapshotsPending = nil
+	return s, nil
+}
+
+// installStoreLocked replaces the live world with src while keeping the
+// journal and snapshot counters, which only ever move forward.
+func installStoreLocked(dst, src *Store) {
+	nextJournal, nextSnapshot := dst.NextJournalID, dst.NextSnapshotID
+	dst.World = src.World
+	dst.Policies = src.Policies
+	applyRuntimeState(dst, runtimeStateFromStore(src))
+	dst.dirty.markAll()
+	dst.Players = src.Players
+	dst.apiTokens = nil
+	dst.Contracts = src.Contracts
+	dst.Institutions = src.Institutions
+	dst.Seats = src.Seats
+	dst.Rumors = src.Rumors
+	dst.Evidence = src.Evidence
+	dst.ScryReports = src.ScryReports
+	dst.Intercepts = src.Intercepts
+	dst.Loans = src.Loans
+	dst.Obligations = src.Obligations
+	dst.Permits = src.Permits
+	dst.Warrants = src.Warrants
+	dst.Relics = src.Relics
+	dst.Projects = src.Projects
+	dst.Orders = src.Orders
+	dst.Caravans = src.Caravans
+	dst.Buildings = src.Buildings
+	dst.Cases = src.Cases
+	dst.Clues = src.Clues
+	dst.Fights = src.Fights
+	dst.ActiveCrisis = src.ActiveCrisis
+	dst.Events = src.Events
+	dst.Chat = src.Chat
+	dst.Messages = src.Messages
+	dst.MarketHistory = src.MarketHistory
+	dst.TreasuryLedger = src.TreasuryLedger
+	dst.ToastByPlayer = map[string]string{}
+	dst.NextJournalID, dst.NextSnapshotID = nextJournal, nextSnapshot
+	if src.content != nil {
+		activeContent.Store(src.content)
+	}
+	dst.push.notifyAll(pushPage)
+}
+
+func ensureCollectionMaps(s *Store) {
+	if s.Players == nil {
+		s.Players = map[string]*Player{}
+	}
+	if s.Contracts == nil {
+		s.Contracts = map[string]*Contract{}
+	}
+	if s.Institutions == nil {
+		s.Institutions = map[string]*Institution{}
+	}
+	if s.Seats == nil {
+		s.Seats = map[string]*Seat{}
+	}
+	if s.Rumors == nil {
+		s.Rumors = map[int64]*Rumor{}
+	}
+	if s.Evidence == nil {
+		s.Evidence = map[int64]*Evidence{}
+	}
+	if s.ScryReports == nil {
+		s.ScryReports = map[int64]*ScryReport{}
+	}
+	if s.Intercepts == nil {
+		s.Intercepts = map[int64]*InterceptedMessage{}
+	}
+	if s.Loans == nil {
+		s.Loans = map[string]*Loan{}
+	}
+	if s.Obligations == nil {
+		s.Obligations = map[string]*Obligation{}
+	}
+	if s.Permits == nil {
+		s.Permits = map[string]*Permit{}
+	}
+	if s.Warrants == nil {
+		s.Warrants = map[string]*Warrant{}
+	}
+	if s.Relics == nil {
+		s.Relics = map[int64]*Relic{}
+	}
+	if s.Projects == nil {
+		s.Projects = map[string]*Project{}
+	}
+	if s.Orders == nil {
+		s.Orders = map[string]*MarketOrder{}
+	}
+	if s.Caravans == nil {
+		s.Caravans = map[string]*Caravan{}
+	}
+	if s.Buildings == nil {
+		s.Buildings = map[string]*Building{}
+	}
+	if s.Cases == nil {
+		s.Cases = map[string]*CourtCase{}
+	}
+	if s.Clues == nil {
+		s.Clues = map[string]*TheftClue{}
+	}
+	if s.Fights == nil {
+		s.Fights = map[string]*Fight{}
+	}
+}
+
+// recordJournalLocked appends an entry for the next Save to flush. Tick
+// entries are stamped with the tick they produce; everything else with the
+// tick it happened during.
+func recordJournalLocked(store *Store, e JournalEntry) {
+	store.NextJournalID++
+	e.ID = store.NextJournalID
+	e.Tick = store.TickCount
+	if e.Kind == journalKindTick {
+		e.Tick++
+	}
+	store.journalPending = append(store.journalPending, e)
+	// With a repository every batch flushes the journal, so nothing is ever
+	// dropped. Without one the journal only feeds the admin listing.
+	if store.repo == nil && len(store.journalPending) > maxJournalPending {
+		store.journalPending = store.journalPending[len(store.journalPending)-maxJournalPending:]
+	}
+}
+
+// queueSnapshotLocked captures the world as of the latest journal entry.
+func queueSnapshotLocked(store *Store, now time.Time) {
+	store.NextSnapshotID++
+	store.snapshotsPending = append(store.snapshotsPending, snapshotRow{
+		ID:        store.NextSnapshotID,
+		Tick:      store.TickCount,
+		JournalID: store.NextJournalID,
+		At:        now,
+		Payload:   asJSON(snapshotStoreLocked(store)),
+	})
+	if store.repo == nil && len(store.snapshotsPending) > maxSnapshotsPending {
+		store.snapshotsPending = store.snapshotsPending[len(store.snapshotsPending)-maxSnapshotsPending:]
+	}
+}
+
+// recentlySeenLocked lists players whose LastSeen still matters to the tick
+// (inactive owners forfeit contracts), so replay can restore it.
+func recentlySeenLocked(store *Store, now time.Time) map[string]time.Time {
+	seen := map[string]time.Time{}
+	for id, p := range store.Players {
+		if now.Sub(p.LastSeen) <= inactiveWindow {
+			seen[id] = p.LastSeen
+		}
+	}
+	return seen
+}
+
+// advanceWorldLocked lets the NPCs act, then journals and runs one world
+// tick, taking a periodic snapshot so replays never start far from their
+// target.
+func advanceWorldLocked(store *Store, now time.Time, daily bool) {
+	runNPCAgentsLocked(store, now)
+	recordJournalLocked(store, JournalEntry{Kind: journalKindTick, At: now, Daily: daily, Seen: recentlySeenLocked(store, now)})
+	applyWorldTickLocked(store, now, daily)
+	if store.TickCount%journalSnapshotEveryTicks == 0 {
+		queueSnapshotLocked(store, now)
+	}
+}
+
+func applyWorldTickLocked(store *Store, now time.Time, daily bool) {
+	// A tick reaches every corner of the world, so it is copied and saved
+	// whole even when it runs inside a tracked write.
+	store.dirty.markAll()
+	runWorldTickLocked(store, now)
+	store.LastTickAt = now
+	if daily {
+		store.LastDailyTickDate = now.Format("2006-01-02")
+		addEventLocked(store, Event{Type: "Daily", Severity: 1, Text: "A new day dawns with fresh uncertainty.", At: now})
+	}
+	store.push.notifyAll(pushPage)
+}
+
+// applyJournalEntryLocked re-applies one recorded input. Reset and rollback
+// entries are boundaries covered by their own snapshots and are not replayed.
+func applyJournalEntryLocked(store *Store, e JournalEntry) {
+	switch e.Kind {
+	case journalKindJoin:
+		if store.Players[e.PlayerID] == nil {
+			p := addPlayerLocked(store, e.PlayerID, e.Name, e.At)
+			if ch, ok := npcCharacterByID(e.PlayerID); ok && e.Role != "" {
+				p.NPCRole, p.Gold = ch.Role, ch.Gold
+			}
+		}
+	case journalKindAction, journalKindChat, journalKindMissive:
+		p := store.Players[e.PlayerID]
+		if p == nil {
+			return
+		}
+		p.LastSeen = e.At
+		p.SoftDeletedAt = time.Time{}
+		p.HardDeletedAt = time.Time{}
+		switch {
+		case e.Kind == journalKindAction && e.Action != nil:
+			store.LastActionAt[p.ID] = e.At
+			handleActionInputLocked(store, p, e.At, *e.Action)
+		case e.Kind == journalKindChat:
+			store.LastChatAt[p.ID] = e.At
+			handleChatLocked(store, p, e.At, e.Text)
+		case e.Kind == journalKindMissive && e.Missive != nil:
+			handleMissiveLocked(store, p, e.At, *e.Missive)
+		}
+	case journalKindTick:
+		for id, seen := range e.Seen {
+			if p := store.Players[id]; p != nil {
+				p.LastSeen = seen
+			}
+		}
+		applyWorldTickLocked(store, e.At, e.Daily)
+	case journalKindCleanup:
+		runDailyCleanupLocked(store, e.At)
+		store.LastCleanupDate = e.At.Format("2006-01-02")
+	case journalKindToken:
+		if p := store.Players[e.PlayerID]; p != nil {
+			setAPITokenHashLocked(store, p, e.TokenHash)
+		}
+	case journalKindContent:
+		if e.Content != nil {
+			e.Content.indexRoutes()
+			activeContent.Store(e.Content)
+			store.content = e.Content
+		}
+	}
+}
+
+// replayJournalLocked applies entries in order until the world has just
+// resolved throughTick, stopping early at a reset or rollback boundary. It
+// returns how many entries were applied.
+func replayJournalLocked(store *Store, entries []JournalEntry, throughTick int64) int {
+	applied := 0
+	for _, e := range entries {
+		if e.Kind == journalKindReset || e.Kind == journalKindRollback || e.Tick > throughTick {
+			break
+		}
+		if e.Tick == throughTick && e.Kind != journalKindTick {
+			break
+		}
+		applyJournalEntryLocked(store, e)
+		applied++
+	}
+	return applied
+}
+
+func (r *SQLRepository) flushJournalWithTx(ctx context.Context, tx *sql.Tx, entries []JournalEntry, snapshots []snapshotRow) error {
+	for _, e := range entries {
+		q := r.insertQuery("journal", []string{"id", "tick", "at_ts", "kind", "player_id", "payload"})
+		if _, err := tx.ExecContext(ctx, q, e.ID, e.Tick, e.At, e.Kind, e.PlayerID, asJSON(e)); err != nil {
+			return fmt.Errorf("insert journal: %w", err)
+		}
+	}
+	for _, snap := range snapshots {
+		q := r.insertQuery("snapshots", []string{"id", "tick", "journal_id", "at_ts", "payload"})
+		if _, err := tx.ExecContext(ctx, q, snap.ID, snap.Tick, snap.JournalID, snap.At, snap.Payload); err != nil {
+			return fmt.Errorf("insert snapshot: %w", err)
+		}
+	}
+	return nil
+}
+
+// ensureBaselineSnapshot makes sure a database has at least one snapshot so
+// the journal that follows it can always be replayed.
+func (r *SQLRepository) ensureBaselineSnapshot(ctx context.Context, store *Store) error {
+	var n int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(1) FROM snapshots").Scan(&n); err != nil {
+		return fmt.Errorf("count snapshots: %w", err)
+	}
+	if n > 0 {
+		return nil
+	}
+	queueSnapshotLocked(store, time.Now().UTC())
+	if err := r.Save(ctx, store); err != nil {
+		return fmt.Errorf("save baseline snapshot: %w", err)
+	}
+	return nil
+}
+
+// RebuildAt reconstructs the world as it stood right after tick resolved,
+// from the closest earlier snapshot plus the journal. Entries replay under
+// the content that was active when they were recorded, which means swapping
+// the active content for the duration, so callers run it on the writer.
+func (r *SQLRepository) RebuildAt(ctx context.Context, tick int64) (*Store, error) {
+	var snap snapshotRow
+	q := fmt.Sprintf("SELECT id, tick, journal_id, payload FROM snapshots WHERE tick <= %s ORDER BY journal_id DESC, id DESC LIMIT 1", r.bind(1))
+	err := r.db.QueryRowContext(ctx, q, tick).Scan(&snap.ID, &snap.Tick, &snap.JournalID, &snap.Payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("no snapshot at or before tick %d", tick)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load snapshot: %w", err)
+	}
+	store, err := restoreStoreSnapshot(snap.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []JournalEntry{}
+	q = fmt.Sprintf("SELECT payload FROM journal WHERE id > %s ORDER BY id", r.bind(1))
+	rows, err := r.db.QueryContext(ctx, q, snap.JournalID)
+	if err != nil {
+		return nil, fmt.Errorf("load journal: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var payload string
+		if err := rows.Scan(&payload); err != nil {
+			return nil, fmt.Errorf("scan journal: %w", err)
+		}
+		var e JournalEntry
+		if err := json.Unmarshal([]byte(payload), &e); err != nil {
+			return nil, fmt.Errorf("decode journal: %w", err)
+		}
+		if e.Kind == journalKindReset || e.Kind == journalKindRollback || e.Tick > tick {
+			break
+		}
+		if want := snap.JournalID + int64(len(entries)) + 1; e.ID != want {
+			return nil, fmt.Errorf("journal is missing entry %d; cannot replay past it", want)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate journal: %w", err)
+	}
+
+	live := currentContent()
+	defer activeContent.Store(live)
+	if store.content != nil {
+		activeContent.Store(store.content)
+	}
+	replayJournalLocked(store, entries, tick)
+	store.content = currentContent()
+	return store, nil
+}
+
+// JournalEntries lists recorded entries oldest first for post-mortems.
+func (r *SQLRepository) JournalEntries(ctx context.Context, f JournalFilter) ([]JournalEntry, error) {
+	where := []string{}
+	args := []any{}
+	if f.FromTick > 0 {
+		args = append(args, f.FromTick)
+		where = append(where, "tick >= "+r.bind(len(args)))
+	}
+	if f.ToTick > 0 {
+		args = append(args, f.ToTick)
+		where = append(where, "tick <= "+r.bind(len(args)))
+	}
+	if f.PlayerID != "" {
+		args = append(args, f.PlayerID)
+		where = append(where, "player_id = "+r.bind(len(args)))
+	}
+	q := "SELECT payload FROM journal"
+	if len(where) > 0 {
+		q += " WHERE " + strings.Join(where, " AND ")
+	}
+	q += fmt.Sprintf(" ORDER BY id LIMIT %d", maxJournalListing)
+
+	out := []JournalEntry{}
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list journal: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var payload string
+		if err := rows.Scan(&payload); err != nil {
+			return nil, fmt.Errorf("scan journal: %w", err)
+		}
+		var e JournalEntry
+		if err := json.Unmarshal([]byte(payload), &e); err != nil {
+			return nil, fmt.Errorf("decode journal: %w", err)
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func filterJournalEntries(entries []JournalEntry, f JournalFilter) []JournalEntry {
+	out := []JournalEntry{}
+	for _, e := range entries {
+		if (f.FromTick > 0 && e.Tick < f.FromTick) || (f.ToTick > 0 && e.Tick > f.ToTick) || (f.PlayerID != "" && e.PlayerID != f.PlayerID) {
+			continue
+		}
+		out = append(out, e)
+		if len(out) >= maxJournalListing {
+			break
+		}
+	}
+	return out
+}
+
+// pruneJournal drops history older than cutoff while keeping the newest
+// snapshot before it, so every retained entry stays replayable.
+func (r *SQLRepository) pruneJournal(ctx context.Context, cutoff time.Time) error {
+	// Runs beside saves without saveMu: it only removes rows older than any
+	// a save writes, and holding a lock across the SQL would stall them.
+	var keepID, keepJournalID int64
+	q := fmt.Sprintf("SELECT id, journal_id FROM snapshots WHERE at_ts < %s ORDER BY journal_id DESC, id DESC LIMIT 1", r.bind(1))
+	err := r.db.QueryRowContext(ctx, q, cutoff).Scan(&keepID, &keepJournalID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("find prune snapshot: %w", err)
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin prune tx: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM snapshots WHERE id < "+r.bind(1), keepID); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("prune snapshots: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM journal WHERE id <= "+r.bind(1), keepJournalID); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("prune journal: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit prune tx: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// playJournalScript drives a store through joins, actions, chat, and ticks
+// the same way the handlers do, recording each input first.
+func playJournalScript(store *Store, start time.Time, ticks int) {
+	now := start
+	for _, id := range []string{"p1", "p2"} {
+		if store.Players[id] == nil {
+			recordJournalLocked(store, JournalEntry{Kind: journalKindJoin, PlayerID: id, Name: "Agent " + id, At: now})
+			addPlayerLocked(store, id, "Agent "+id, now)
+		}
+	}
+	for i := 0; i < ticks; i++ {
+		now = now.Add(time.Minute)
+		for _, id := range []string{"p1", "p2"} {
+			p := store.Players[id]
+			p.LastSeen = now
+			in := ActionInput{Action: "investigate"}
+			if i%2 == 1 {
+				in = ActionInput{Action: "seed_rumor", TargetID: "p2", Topic: "Hoarding"}
+			}
+			recordJournalLocked(store, JournalEntry{Kind: journalKindAction, PlayerID: id, At: now, Action: &in})
+			store.LastActionAt[id] = now
+			handleActionInputLocked(store, p, now, in)
+		}
+		recordJournalLocked(store, JournalEntry{Kind: journalKindChat, PlayerID: "p1", At: now, Text: "bread for all"})
+		store.LastChatAt["p1"] = now
+		handleChatLocked(store, store.Players["p1"], now, "bread for all")
+		advanceWorldLocked(store, now, i%4 == 3)
+	}
+}
+
+func worldFingerprint(t *testing.T, s *Store) string {
+	t.Helper()
+	return asJSON(map[string]any{
+		"tick":      s.TickCount,
+		"world":     s.World,
+		"players":   s.Players,
+		"contracts": s.Contracts,
+		"rumors":    s.Rumors,
+		"evidence":  s.Evidence,
+		"crisis":    s.ActiveCrisis,
+		"events":    s.Events,
+		"chat":      s.Chat,
+	})
+}
+
+func TestJournalReplayReproducesWorld(t *testing.T) {
+	start := time.Date(2026, 2, 12, 12, 0, 0, 0, time.UTC)
+	live := newTestStore()
+	base := asJSON(snapshotStoreLocked(live))
+
+	playJournalScript(live, start, 10)
+
+	replayed, err := restoreStoreSnapshot(base)
+	if err != nil {
+		t.Fatalf("restore snapshot: %v", err)
+	}
+	applied := replayJournalLocked(replayed, live.journalPending, live.TickCount)
+	if applied != len(live.journalPending) {
+		t.Fatalf("expected all %d entries to replay, applied %d", len(live.journalPending), applied)
+	}
+	if got, want := worldFingerprint(t, replayed), worldFingerprint(t, live); got != want {
+		t.Fatalf("replayed world diverged\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestReplayStopsAtResetBoundary(t *testing.T) {
+	s := newTestStore()
+	now := time.Date(2026, 2, 12, 12, 0, 0, 0, time.UTC)
+	entries := []JournalEntry{
+		{ID: 1, Kind: journalKindTick, Tick: 1, At: now},
+		{ID: 2, Kind: journalKindReset, Tick: 1, At: now},
+		{ID: 3, Kind: journalKindTick, Tick: 1, At: now},
+	}
+	if n := replayJournalLocked(s, entries, 10); n != 1 || s.TickCount != 1 {
+		t.Fatalf("expected replay to stop at reset, applied=%d tick=%d", n, s.TickCount)
+	}
+}
+
+func TestRepositoryRebuildAtMatchesHistory(t *testing.T) {
+	t.Setenv("DB_DIALECT", "sqlite")
+	t.Setenv("DB_SQLITE_PATH", filepath.Join(t.TempDir(), "journal.sqlite"))
+
+	repo, err := openRepositoryFromEnv()
+	if err != nil {
+		t.Fatalf("openRepositoryFromEnv error: %v", err)
+	}
+	defer repo.db.Close()
+	ctx := context.Background()
+
+	live := newTestStore()
+	if err := repo.LoadInto(ctx, live); err != nil {
+		t.Fatalf("LoadInto error: %v", err)
+	}
+	live.rng = newWorldRNG(13)
+	live.repo = repo
+	queueSnapshotLocked(live, time.Now().UTC())
+
+	start := time.Date(2026, 2, 12, 12, 0, 0, 0, time.UTC)
+	playJournalScript(live, start, 6)
+	midTick := live.TickCount
+	mid := worldFingerprint(t, live)
+	live.persistLocked()
+
+	playJournalScript(live, start.Add(6*time.Minute), 30)
+	live.persistLocked()
+	if len(live.journalPending) != 0 || len(live.snapshotsPending) != 0 {
+		t.Fatalf("journal should be flushed on save")
+	}
+
+	rebuilt, err := repo.RebuildAt(ctx, midTick)
+	if err != nil {
+		t.Fatalf("RebuildAt error: %v", err)
+	}
+	if got := worldFingerprint(t, rebuilt); got != mid {
+		t.Fatalf("rebuilt world diverged at tick %d\n got: %s\nwant: %s", midTick, got, mid)
+	}
+
+	latest, err := repo.RebuildAt(ctx, live.TickCount)
+	if err != nil {
+		t.Fatalf("RebuildAt latest error: %v", err)
+	}
+	if got, want := worldFingerprint(t, latest), worldFingerprint(t, live); got != want {
+		t.Fatalf("rebuilt latest world diverged\n got: %s\nwant: %s", got, want)
+	}
+
+	entries, err := repo.JournalEntries(ctx, JournalFilter{FromTick: 2, ToTick: 2, PlayerID: "p1"})
+	if err != nil {
+		t.Fatalf("JournalEntries error: %v", err)
+	}
+	if len(entries) == 0 {
+		t.Fatalf("expected journal entries for p1 at tick 2")
+	}
+	for _, e := range entries {
+		if e.PlayerID != "p1" || e.Tick != 2 {
+			t.Fatalf("filter leaked entry %+v", e)
+		}
+	}
+}
+
+func TestAdminJournalAndRollback(t *testing.T) {
+	t.Setenv(adminLoopbackEnvName, "true")
+	t.Setenv(adminTokenEnvName, "test-secret")
+	t.Setenv("DB_DIALECT", "sqlite")
+	t.Setenv("DB_SQLITE_PATH", filepath.Join(t.TempDir(), "rollback.sqlite"))
+
+	repo, err := openRepositoryFromEnv()
+	if err != nil {
+		t.Fatalf("openRepositoryFromEnv error: %v", err)
+	}
+	defer repo.db.Close()
+
+	s := newTestStore()
+	if err := repo.LoadInto(context.Background(), s); err != nil {
+		t.Fatalf("LoadInto error: %v", err)
+	}
+	s.repo = repo
+	mux := newMux(s, parseTemplates())
+
+	start := time.Date(2026, 2, 12, 12, 0, 0, 0, time.UTC)
+	playJournalScript(s, start, 4)
+	s.persistLocked()
+	atTwo, err := repo.RebuildAt(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("RebuildAt error: %v", err)
+	}
+	want := worldFingerprint(t, atTwo)
+
+	r := doReq(t, mux, http.MethodGet, "/admin/journal?player_id=p2", nil, "", "127.0.0.1:1111")
+	if r.Code != http.StatusOK {
+		t.Fatalf("GET /admin/journal status=%d body=%s", r.Code, r.Body.String())
+	}
+	var listing struct {
+		Entries []JournalEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(r.Body.Bytes(), &listing); err != nil {
+		t.Fatalf("decode journal: %v", err)
+	}
+	if len(listing.Entries) == 0 {
+		t.Fatalf("expected p2 journal entries")
+	}
+	for _, e := range listing.Entries {
+		if e.PlayerID != "p2" {
+			t.Fatalf("unexpected entry in filtered listing: %+v", e)
+		}
+	}
+
+	r = doReq(t, mux, http.MethodGet, "/admin/replay?tick=2", nil, "", "127.0.0.1:1111")
+	if r.Code != http.StatusOK {
+		t.Fatalf("GET /admin/replay status=%d body=%s", r.Code, r.Body.String())
+	}
+
+	r = doReq(t, mux, http.MethodPost, "/admin/rollback", url.Values{"tick": {"2"}}, "", "127.0.0.1:1111")
+	if r.Code != http.StatusForbidden {
+		t.Fatalf("rollback without csrf should be forbidden, got %d", r.Code)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/admin/rollback", strings.NewReader(url.Values{"tick": {"2"}}.Encode()))
+	req.RemoteAddr = "127.0.0.1:1111"
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(adminAuthHeaderName, "test-secret")
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	if resp.Code != http.StatusSeeOther {
+		t.Fatalf("POST /admin/rollback status=%d body=%s", resp.Code, resp.Body.String())
+	}
+	if s.TickCount != 2 {
+		t.Fatalf("rollback should rewind to tick 2, got %d", s.TickCount)
+	}
+	// The rollback notice is the only event that differs from the rebuild.
+	events := s.Events
+	s.Events = events[:len(events)-1]
+	got := worldFingerprint(t, s)
+	s.Events = events
+	if got != want {
+		t.Fatalf("rollback diverged from rebuild\n got: %s\nwant: %s", got, want)
+	}
+
+	// Play continues past the rollback and can itself be rebuilt.
+	playJournalScript(s, start.Add(10*time.Minute), 1)
+	s.persistLocked()
+	rebuilt, err := repo.RebuildAt(context.Background(), s.TickCount)
+	if err != nil {
+		t.Fatalf("RebuildAt after rollback error: %v", err)
+	}
+	if got, want := worldFingerprint(t, rebuilt), worldFingerprint(t, s); got != want {
+		t.Fatalf("post-rollback rebuild diverged\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestRebuildAtReplaysContentReloads(t *testing.T) {
+	t.Setenv("DB_DIALECT", "sqlite")
+	t.Setenv("DB_SQLITE_PATH", filepath.Join(t.TempDir(), "content.sqlite"))
+	repo, err := openRepositoryFromEnv()
+	if err != nil {
+		t.Fatalf("openRepositoryFromEnv error: %v", err)
+	}
+	defer repo.db.Close()
+	ctx := context.Background()
+
+	live := newTestStore()
+	if err := repo.LoadInto(ctx, live); err != nil {
+		t.Fatalf("LoadInto error: %v", err)
+	}
+	live.repo = repo
+	start := time.Date(2026, 2, 12, 12, 0, 0, 0, time.UTC)
+	playJournalScript(live, start, 2)
+
+	original := currentContent()
+	t.Cleanup(func() { activeContent.Store(original) })
+	slow := *original
+	slow.Routes = nil
+	slow.DefaultRouteTicks = 9
+	slow.indexRoutes()
+	activeContent.Store(&slow)
+	recordJournalLocked(live, JournalEntry{Kind: journalKindContent, At: start, Text: "slow", Content: &slow})
+
+	now := start.Add(3 * time.Minute)
+	in := ActionInput{Action: "travel", LocationID: locationFrontier}
+	recordJournalLocked(live, JournalEntry{Kind: journalKindAction, PlayerID: "p1", At: now, Action: &in})
+	handleActionInputLocked(live, live.Players["p1"], now, in)
+	advanceWorldLocked(live, now, false)
+	live.persistLocked()
+	if live.Players["p1"].TravelTotalTicks != 9 {
+		t.Fatalf("expected the reloaded routes to apply live, got %d ticks", live.Players["p1"].TravelTotalTicks)
+	}
+
+	activeContent.Store(original)
+	rebuilt, err := repo.RebuildAt(ctx, live.TickCount)
+	if err != nil {
+		t.Fatalf("RebuildAt error: %v", err)
+	}
+	if got := rebuilt.Players["p1"].TravelTotalTicks; got != 9 {
+		t.Fatalf("rebuild should replay under the reloaded content, got %d ticks", got)
+	}
+	if currentContent() != original {
+		t.Fatalf("rebuild should leave the live content active")
+	}
+}
+
+func TestRebuildAtRefusesAJournalGap(t *testing.T) {
+	t.Setenv("DB_DIALECT", "sqlite")
+	t.Setenv("DB_SQLITE_PATH", filepath.Join(t.TempDir(), "gap.sqlite"))
+	repo, err := openRepositoryFromEnv()
+	if err != nil {
+		t.Fatalf("openRepositoryFromEnv error: %v", err)
+	}
+	defer repo.db.Close()
+	ctx := context.Background()
+
+	live := newTestStore()
+	if err := repo.LoadInto(ctx, live); err != nil {
+		t.Fatalf("LoadInto error: %v", err)
+	}
+	live.repo = repo
+	playJournalScript(live, time.Date(2026, 2, 12, 12, 0, 0, 0, time.UTC), 3)
+	live.journalPending = append(live.journalPending[:2], live.journalPending[3:]...)
+	live.persistLocked()
+
+	if _, err := repo.RebuildAt(ctx, live.TickCount); err == nil || !strings.Contains(err.Error(), "missing entry") {
+		t.Fatalf("expected a journal gap to fail the rebuild, got %v", err)
+	}
+}
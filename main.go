@@ -1,6 +1,9 @@
 package main
 
 import (
+	"bytes"
+	"cmp"
+	"context"
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
@@ -12,16 +15,21 @@ import (
 	"html/template"
 	"io"
 	"log"
+	"maps"
 	"math"
 	mathrand "math/rand"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -60,7 +68,6 @@ const (
 	electionWindowTicks         = 2
 	highImpactDailyCap          = 3
 	loanDueTicks                = 4
-	grainUnitPerSack            = 6
 	marketMaxTrade              = 12
 	reliefSackCost              = 3
 	wantedHeatThreshold         = 10
@@ -109,10 +116,15 @@ var (
 )
 
 type WorldState struct {
-	DayNumber                    int
-	Subphase                     string
-	GrainSupply                  int
-	GrainTier                    string
+	DayNumber   int
+	Subphase    string
+	GrainSupply int
+	GrainTier   string
+	// Markets holds each location's commodity pools, keyed by location and
+	// then commodity. The capital's grain is GrainSupply. MarketVolume counts
+	// units traded in each market since the last history sample.
+	Markets                      map[string]map[string]int `json:",omitempty"`
+	MarketVolume                 map[string]map[string]int `json:",omitempty"`
 	UnrestValue                  int
 	UnrestTier                   string
 	RestrictedMarketsTicks       int
@@ -120,6 +132,9 @@ type WorldState struct {
 	CriticalTickStreak           int
 	CriticalStreakPenaltyApplied bool
 	Situation                    string
+	// BanditActivity rises as bandits take caravans and adds to every
+	// caravan's ambush risk until it fades.
+	BanditActivity int
 }
 
 type Player struct {
@@ -142,6 +157,17 @@ type Player struct {
 	LastSeen                time.Time
 	SoftDeletedAt           time.Time
 	HardDeletedAt           time.Time
+	// APITokenHash is the SHA-256 of the player's /api/v1 bearer token.
+	APITokenHash string `json:",omitempty"`
+	// Inventory holds every commodity other than grain, keyed by ID.
+	Inventory map[string]int `json:",omitempty"`
+	// NPCRole is set for the scripted characters of npcRoster.
+	NPCRole string `json:",omitempty"`
+	// Statuses restrict what the player may do until each is released.
+	Statuses []StatusEffect `json:",omitempty"`
+	// Embezzled is unexposed gold taken from each institution's treasury,
+	// kept here because the ledger line recording it may scroll off.
+	Embezzled map[string]int `json:",omitempty"`
 }
 
 type Contract struct {
@@ -162,6 +188,9 @@ type Contract struct {
 	RewardGold     int
 	SupplySacks    int
 	Warranted      bool
+	// TreasuryID names the institution that escrowed a bounty's reward; it
+	// gets the reward back if the bounty lapses.
+	TreasuryID string `json:",omitempty"`
 }
 
 type Event struct {
@@ -213,6 +242,20 @@ type InterceptedMessage struct {
 type Institution struct {
 	ID   string
 	Name string
+	// Treasury is the gold the institution's seat holders may spend; every
+	// movement is written to the public TreasuryLedger.
+	Treasury int
+	// LastActTick is when the institution's appointees last pulled a lever
+	// on their own; see processFactionTickLocked.
+	LastActTick int64 `json:",omitempty"`
+}
+
+// institutionDefinitions are the city's institutions with their opening
+// treasuries.
+var institutionDefinitions = []Institution{
+	{ID: "city_authority", Name: "City Authority", Treasury: 40},
+	{ID: "merchant_league", Name: "Merchant League", Treasury: 20},
+	{ID: "temple", Name: "Temple", Treasury: 15},
 }
 
 type Seat struct {
@@ -223,6 +266,8 @@ type Seat struct {
 	HolderName          string
 	TenureTicksLeft     int
 	ElectionWindowTicks int
+	Candidates          []Candidate       `json:",omitempty"`
+	Ballots             map[string]Ballot `json:",omitempty"`
 }
 
 type PolicyState struct {
@@ -330,9 +375,9 @@ type Relic struct {
 }
 
 type RelicDefinition struct {
-	Name   string
-	Effect string
-	Power  int
+	Name   string `json:"name"`
+	Effect string `json:"effect"`
+	Power  int    `json:"power"`
 }
 
 type Project struct {
@@ -345,20 +390,25 @@ type Project struct {
 	CostGrain     int
 	TicksLeft     int
 	TotalTicks    int
+	// Definition is the project as defined when it was funded, so content
+	// reloads do not change a project already underway.
+	Definition *ProjectDefinition `json:",omitempty"`
 }
 
 type ProjectDefinition struct {
-	Type             string
-	Name             string
-	Description      string
-	CostGold         int
-	CostGrain        int
-	DurationTicks    int
-	GrainDelta       int
-	UnrestDelta      int
-	RepDelta         int
-	HeatDelta        int
-	WardNetworkTicks int
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	CostGold    int    `json:"cost_gold"`
+	CostGrain   int    `json:"cost_grain"`
+	// Costs names further commodity costs by ID.
+	Costs            map[string]int `json:"costs,omitempty"`
+	DurationTicks    int            `json:"duration_ticks"`
+	GrainDelta       int            `json:"grain_delta"`
+	UnrestDelta      int            `json:"unrest_delta"`
+	RepDelta         int            `json:"rep_delta"`
+	HeatDelta        int            `json:"heat_delta"`
+	WardNetworkTicks int            `json:"ward_network_ticks"`
 }
 
 type Crisis struct {
@@ -369,35 +419,60 @@ type Crisis struct {
 	TicksLeft   int
 	TotalTicks  int
 	Mitigated   bool
+	// Definition is the crisis as defined when it erupted, so content
+	// reloads do not change a crisis already in progress.
+	Definition *CrisisDefinition `json:",omitempty"`
 }
 
 type CrisisDefinition struct {
-	Type               string
-	Name               string
-	Description        string
-	DurationTicks      int
-	BaseSeverity       int
-	GoldCost           int
-	GrainCost          int
-	ResponseLabel      string
-	TickUnrestDelta    int
-	TickGrainDelta     int
-	ResolveRepDelta    int
-	ResolveUnrestDelta int
-	FailureUnrestDelta int
-	FailureGrainDelta  int
+	Type          string `json:"type"`
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	DurationTicks int    `json:"duration_ticks"`
+	BaseSeverity  int    `json:"base_severity"`
+	GoldCost      int    `json:"gold_cost"`
+	GrainCost     int    `json:"grain_cost"`
+	// Costs names further commodity costs by ID.
+	Costs              map[string]int `json:"costs,omitempty"`
+	ResponseLabel      string         `json:"response_label"`
+	TickUnrestDelta    int            `json:"tick_unrest_delta"`
+	TickGrainDelta     int            `json:"tick_grain_delta"`
+	ResolveRepDelta    int            `json:"resolve_rep_delta"`
+	ResolveUnrestDelta int            `json:"resolve_unrest_delta"`
+	FailureUnrestDelta int            `json:"failure_unrest_delta"`
+	FailureGrainDelta  int            `json:"failure_grain_delta"`
+	// BuildingDamage is the condition, in percent, that buildings at
+	// DamageLocations (everywhere when empty) lose each tick the crisis
+	// goes unchecked.
+	BuildingDamage  int      `json:"building_damage,omitempty"`
+	DamageLocations []string `json:"damage_locations,omitempty"`
 }
 
 type LocationDef struct {
-	ID          string
-	Name        string
-	Description string
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Danger is the per-tick chance, in percent, that bandits strike a
+	// caravan on a road ending here.
+	Danger int `json:"danger"`
+	// Market is nil where nothing is traded.
+	Market *LocationMarket `json:"market,omitempty"`
 }
 
 type Store struct {
-	mu   sync.Mutex
+	mu   sync.RWMutex
 	repo *SQLRepository
 
+	writer   storeWriter
+	presence *presenceTable
+	// view is the latest read-only copy of the world, published by the
+	// writer after every batch. See readView.
+	view atomic.Pointer[Store]
+	// dirty is what the current batch changed; see dirty.go. On a view,
+	// changes is what its batch changed since the view before it.
+	dirty   dirtySet
+	changes *dirtySet
+
 	World        WorldState
 	Players      map[string]*Player
 	Contracts    map[string]*Contract
@@ -414,11 +489,20 @@ type Store struct {
 	Warrants     map[string]*Warrant
 	Relics       map[int64]*Relic
 	Projects     map[string]*Project
+	Orders       map[string]*MarketOrder
+	Caravans     map[string]*Caravan
+	Buildings    map[string]*Building
+	Cases        map[string]*CourtCase
+	Clues        map[string]*TheftClue
+	Fights       map[string]*Fight
 	ActiveCrisis *Crisis
 
-	Events   []Event
-	Chat     []ChatMessage
-	Messages []DiplomaticMessage
+	Events        []Event
+	Chat          []ChatMessage
+	Messages      []DiplomaticMessage
+	MarketHistory []MarketTick
+	// TreasuryLedger is the public record of every institution's treasury.
+	TreasuryLedger []TreasuryEntry
 
 	NextEventID      int64
 	NextContractID   int64
@@ -432,6 +516,15 @@ type Store struct {
 	NextObligationID int64
 	NextProjectID    int64
 	NextRelicID      int64
+	NextOrderID      int64
+	NextCaravanID    int64
+	NextBuildingID   int64
+	NextCaseID       int64
+	NextClueID       int64
+	NextFightID      int64
+	NextTreasuryID   int64
+	NextJournalID    int64
+	NextSnapshotID   int64
 
 	LastDailyTickDate string
 	LastTickAt        time.Time
@@ -451,7 +544,25 @@ type Store struct {
 	ToastByPlayer     map[string]string
 	LastCleanupDate   string
 
-	rng *mathrand.Rand
+	rng *worldRNG
+
+	// push fans fragment refreshes out to open /push streams.
+	push *pushHub
+
+	// rejections holds the error code of each player's last refused request.
+	rejections map[string]string
+
+	// Journal entries and snapshots recorded since the last successful Save.
+	journalPending   []JournalEntry
+	snapshotsPending []snapshotRow
+
+	// content is the content a rebuilt world was replayed under; nil on the
+	// live store, whose content is whatever is active.
+	content *GameContent
+
+	// apiTokens maps API token hashes to player IDs. Nil until first use
+	// and whenever the roster is replaced wholesale.
+	apiTokens map[string]string
 }
 
 type AdminDiagnostics struct {
@@ -487,243 +598,262 @@ type AdminDiagnostics struct {
 }
 
 type PlayerSummary struct {
-	Name      string
-	Rep       int
-	Title     string
-	Gold      int
-	Heat      int
-	HeatLabel string
-	Warrant   string
-	Online    bool
-	IconPath  string
-	IconTint  string
+	Name      string `json:"name"`
+	Rep       int    `json:"rep"`
+	Title     string `json:"title"`
+	Gold      int    `json:"gold"`
+	Heat      int    `json:"heat"`
+	HeatLabel string `json:"heat_label"`
+	Warrant   string `json:"warrant"`
+	Court     string `json:"court,omitempty"`
+	Status    string `json:"status,omitempty"`
+	Online    bool   `json:"online"`
+	// NPC labels a scripted character's role.
+	NPC      string `json:"npc,omitempty"`
+	IconPath string `json:"-"`
+	IconTint string `json:"-"`
 }
 
 type ContractView struct {
-	ID              string
-	Type            string
-	Status          string
-	DeadlineTicks   int
-	OwnerName       string
-	IssuerName      string
-	Stance          string
-	TargetName      string
-	UrgencyClass    string
-	CanAccept       bool
-	CanIgnore       bool
-	CanAbandon      bool
-	CanCancel       bool
-	CanDeliver      bool
-	DeliverLabel    string
-	DeliverDisabled bool
-	ShowOutcome     bool
-	OutcomeLabel    string
-	OutcomeNote     string
-	RequirementNote string
-	RewardNote      string
-	IsBounty        bool
-	IsSupply        bool
-	IconPath        string
-	IconTint        string
+	ID              string `json:"id"`
+	Type            string `json:"type"`
+	Status          string `json:"status"`
+	DeadlineTicks   int    `json:"deadline_ticks"`
+	OwnerName       string `json:"owner_name"`
+	IssuerName      string `json:"issuer_name"`
+	Stance          string `json:"stance"`
+	TargetName      string `json:"target_name"`
+	UrgencyClass    string `json:"urgency_class"`
+	CanAccept       bool   `json:"can_accept"`
+	CanIgnore       bool   `json:"can_ignore"`
+	CanAbandon      bool   `json:"can_abandon"`
+	CanCancel       bool   `json:"can_cancel"`
+	CanDeliver      bool   `json:"can_deliver"`
+	DeliverLabel    string `json:"deliver_label"`
+	DeliverDisabled bool   `json:"deliver_disabled"`
+	ShowOutcome     bool   `json:"show_outcome"`
+	OutcomeLabel    string `json:"outcome_label"`
+	OutcomeNote     string `json:"outcome_note"`
+	RequirementNote string `json:"requirement_note"`
+	RewardNote      string `json:"reward_note"`
+	IsBounty        bool   `json:"is_bounty"`
+	IsSupply        bool   `json:"is_supply"`
+	IconPath        string `json:"-"`
+	IconTint        string `json:"-"`
 }
 
 type StandingView struct {
-	ReputationValue int
-	ReputationLabel string
-	HeatValue       int
-	HeatLabel       string
-	WealthGold      int
-	GrainStockpile  int
-	CompletedToday  int
-	CompletedTotal  int
-	Rumors          int
-	PermitStatus    string
-	AccessStatus    string
-	WarrantStatus   string
+	ReputationValue int    `json:"reputation_value"`
+	ReputationLabel string `json:"reputation_label"`
+	HeatValue       int    `json:"heat_value"`
+	HeatLabel       string `json:"heat_label"`
+	WealthGold      int    `json:"wealth_gold"`
+	GrainStockpile  int    `json:"grain_stockpile"`
+	CompletedToday  int    `json:"completed_today"`
+	CompletedTotal  int    `json:"completed_total"`
+	Rumors          int    `json:"rumors"`
+	PermitStatus    string `json:"permit_status"`
+	AccessStatus    string `json:"access_status"`
+	WarrantStatus   string `json:"warrant_status"`
+	CourtStatus     string `json:"court_status"`
+	// Restrictions explain the statuses in force and when each ends.
+	Restrictions []StatusView `json:"restrictions"`
 }
 
 type EventView struct {
-	DayNumber int
-	Subphase  string
-	Text      string
-	At        string
+	DayNumber int    `json:"day_number"`
+	Subphase  string `json:"subphase"`
+	Text      string `json:"text"`
+	At        string `json:"at"`
 }
 
 type ChatView struct {
-	FromName  string
-	FromTitle string
-	ToName    string
-	Text      string
-	Kind      string
-	At        string
+	FromName  string `json:"from_name"`
+	FromTitle string `json:"from_title"`
+	ToName    string `json:"to_name"`
+	Text      string `json:"text"`
+	Kind      string `json:"kind"`
+	At        string `json:"at"`
 }
 
 type MessageView struct {
-	FromName  string
-	ToName    string
-	Subject   string
-	Body      string
-	Direction string
-	At        string
-	Sealed    bool
+	FromName  string `json:"from_name"`
+	ToName    string `json:"to_name"`
+	Subject   string `json:"subject"`
+	Body      string `json:"body"`
+	Direction string `json:"direction"`
+	At        string `json:"at"`
+	Sealed    bool   `json:"sealed"`
 }
 
 type SeatView struct {
-	ID                  string
-	Name                string
-	InstitutionName     string
-	HolderName          string
-	TenureTicksLeft     int
-	ElectionWindowTicks int
-	IsElectionOpen      bool
-	CanCampaign         bool
-	CanChallenge        bool
-	CanToggleTaxHigh    bool
-	CanToggleTaxLow     bool
-	CanTogglePermit     bool
-	CanToggleEmbargo    bool
-	CanIssuePermit      bool
-	CanConductInquest   bool
-	CanIssueWarrant     bool
+	ID                  string `json:"id"`
+	Name                string `json:"name"`
+	InstitutionName     string `json:"institution_name"`
+	HolderName          string `json:"holder_name"`
+	TenureTicksLeft     int    `json:"tenure_ticks_left"`
+	ElectionWindowTicks int    `json:"election_window_ticks"`
+	IsElectionOpen      bool   `json:"is_election_open"`
+	CanCampaign         bool   `json:"can_campaign"`
+	CanChallenge        bool   `json:"can_challenge"`
+	CanToggleTaxHigh    bool   `json:"can_toggle_tax_high"`
+	CanToggleTaxLow     bool   `json:"can_toggle_tax_low"`
+	CanTogglePermit     bool   `json:"can_toggle_permit"`
+	CanToggleEmbargo    bool   `json:"can_toggle_embargo"`
+	CanIssuePermit      bool   `json:"can_issue_permit"`
+	CanConductInquest   bool   `json:"can_conduct_inquest"`
+	CanIssueWarrant     bool   `json:"can_issue_warrant"`
+	// Treasury is the balance the holder may spend; CanSpendTreasury is set
+	// for the holder.
+	Treasury         int  `json:"treasury"`
+	CanSpendTreasury bool `json:"can_spend_treasury"`
+	// Candidates stand in the open election; CanVote is set when the player
+	// is eligible, casting VoteWeight votes, and VotedFor names their pick.
+	Candidates  []CandidateView `json:"candidates,omitempty"`
+	IsCandidate bool            `json:"is_candidate"`
+	CanVote     bool            `json:"can_vote"`
+	VoteWeight  int             `json:"vote_weight"`
+	VotedFor    string          `json:"voted_for,omitempty"`
 }
 
 type RumorView struct {
-	ID          int64
-	Claim       string
-	Topic       string
-	TargetName  string
-	SourceName  string
-	Credibility int
-	Spread      int
-	Decay       int
+	ID          int64  `json:"id"`
+	Claim       string `json:"claim"`
+	Topic       string `json:"topic"`
+	TargetName  string `json:"target_name"`
+	SourceName  string `json:"source_name"`
+	Credibility int    `json:"credibility"`
+	Spread      int    `json:"spread"`
+	Decay       int    `json:"decay"`
 }
 
 type EvidenceView struct {
-	ID         int64
-	Topic      string
-	TargetName string
-	SourceName string
-	Strength   int
-	ExpiryIn   int64
-	SourceNote string
+	ID         int64  `json:"id"`
+	Topic      string `json:"topic"`
+	TargetName string `json:"target_name"`
+	SourceName string `json:"source_name"`
+	Strength   int    `json:"strength"`
+	ExpiryIn   int64  `json:"expiry_in"`
+	SourceNote string `json:"source_note"`
 }
 
 type ScryReportView struct {
-	ID           int64
-	TargetName   string
-	LocationName string
-	TravelNote   string
-	Rep          int
-	Heat         int
-	Gold         int
-	Grain        int
-	ExpiryIn     int64
+	ID           int64  `json:"id"`
+	TargetName   string `json:"target_name"`
+	LocationName string `json:"location_name"`
+	TravelNote   string `json:"travel_note"`
+	Rep          int    `json:"rep"`
+	Heat         int    `json:"heat"`
+	Gold         int    `json:"gold"`
+	Grain        int    `json:"grain"`
+	ExpiryIn     int64  `json:"expiry_in"`
 }
 
 type InterceptView struct {
-	ID       int64
-	FromName string
-	ToName   string
-	Subject  string
-	Body     string
-	At       string
-	ExpiryIn int64
-	Sealed   bool
+	ID       int64  `json:"id"`
+	FromName string `json:"from_name"`
+	ToName   string `json:"to_name"`
+	Subject  string `json:"subject"`
+	Body     string `json:"body"`
+	At       string `json:"at"`
+	ExpiryIn int64  `json:"expiry_in"`
+	Sealed   bool   `json:"sealed"`
 }
 
 type LoanView struct {
-	ID           string
-	LenderName   string
-	BorrowerName string
-	Remaining    int
-	DueIn        int64
-	Status       string
+	ID           string `json:"id"`
+	LenderName   string `json:"lender_name"`
+	BorrowerName string `json:"borrower_name"`
+	Remaining    int    `json:"remaining"`
+	DueIn        int64  `json:"due_in"`
+	Status       string `json:"status"`
 }
 
 type ObligationView struct {
-	ID             string
-	CreditorName   string
-	DebtorName     string
-	Reason         string
-	Severity       int
-	DueIn          int64
-	Status         string
-	Cost           int
-	CanSettle      bool
-	SettleLabel    string
-	SettleDisabled bool
-	CanForgive     bool
+	ID             string `json:"id"`
+	CreditorName   string `json:"creditor_name"`
+	DebtorName     string `json:"debtor_name"`
+	Reason         string `json:"reason"`
+	Severity       int    `json:"severity"`
+	DueIn          int64  `json:"due_in"`
+	Status         string `json:"status"`
+	Cost           int    `json:"cost"`
+	CanSettle      bool   `json:"can_settle"`
+	SettleLabel    string `json:"settle_label"`
+	SettleDisabled bool   `json:"settle_disabled"`
+	CanForgive     bool   `json:"can_forgive"`
 }
 
 type PermitView struct {
-	PlayerName string
-	IssuerName string
-	TicksLeft  int
+	PlayerName string `json:"player_name"`
+	IssuerName string `json:"issuer_name"`
+	TicksLeft  int    `json:"ticks_left"`
 }
 
 type WarrantView struct {
-	PlayerName string
-	IssuerName string
-	TicksLeft  int
+	PlayerName string `json:"player_name"`
+	IssuerName string `json:"issuer_name"`
+	TicksLeft  int    `json:"ticks_left"`
 }
 
 type RelicView struct {
-	ID                     int64
-	Name                   string
-	Status                 string
-	EffectLabel            string
-	CanAppraise            bool
-	AppraiseDisabled       bool
-	AppraiseDisabledReason string
-	CanInvoke              bool
-	InvokeDisabled         bool
-	InvokeDisabledReason   string
+	ID                     int64  `json:"id"`
+	Name                   string `json:"name"`
+	Status                 string `json:"status"`
+	EffectLabel            string `json:"effect_label"`
+	CanAppraise            bool   `json:"can_appraise"`
+	AppraiseDisabled       bool   `json:"appraise_disabled"`
+	AppraiseDisabledReason string `json:"appraise_disabled_reason"`
+	CanInvoke              bool   `json:"can_invoke"`
+	InvokeDisabled         bool   `json:"invoke_disabled"`
+	InvokeDisabledReason   string `json:"invoke_disabled_reason"`
 }
 
 type ProjectView struct {
-	ID         string
-	Name       string
-	OwnerName  string
-	TicksLeft  int
-	EffectNote string
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	OwnerName  string `json:"owner_name"`
+	TicksLeft  int    `json:"ticks_left"`
+	EffectNote string `json:"effect_note"`
 }
 
 type ProjectOption struct {
-	Type           string
-	Name           string
-	Description    string
-	CostGold       int
-	CostGrain      int
-	DurationTicks  int
-	Disabled       bool
-	DisabledReason string
+	Type           string `json:"type"`
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	CostGold       int    `json:"cost_gold"`
+	CostGrain      int    `json:"cost_grain"`
+	CostLabel      string `json:"cost_label"`
+	DurationTicks  int    `json:"duration_ticks"`
+	Disabled       bool   `json:"disabled"`
+	DisabledReason string `json:"disabled_reason"`
 }
 
 type CrisisView struct {
-	Name                   string
-	Description            string
-	Severity               int
-	TicksLeft              int
-	TotalTicks             int
-	ResponseLabel          string
-	ResponseCost           string
-	ResponseDisabled       bool
-	ResponseDisabledReason string
+	Name                   string `json:"name"`
+	Description            string `json:"description"`
+	Severity               int    `json:"severity"`
+	TicksLeft              int    `json:"ticks_left"`
+	TotalTicks             int    `json:"total_ticks"`
+	ResponseLabel          string `json:"response_label"`
+	ResponseCost           string `json:"response_cost"`
+	ResponseDisabled       bool   `json:"response_disabled"`
+	ResponseDisabledReason string `json:"response_disabled_reason"`
 }
 
 type PlayerOption struct {
-	ID   string
-	Name string
+	ID   string `json:"id"`
+	Name string `json:"name"`
 }
 
 type LocationOption struct {
-	ID          string
-	Name        string
-	TravelTicks int
-	Disabled    bool
-	Reason      string
-	IconPath    string
-	IconTint    string
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	TravelTicks int    `json:"travel_ticks"`
+	Disabled    bool   `json:"disabled"`
+	Reason      string `json:"reason"`
+	IconPath    string `json:"-"`
+	IconTint    string `json:"-"`
 }
 
 type PageData struct {
@@ -732,6 +862,7 @@ type PageData struct {
 	PlayerTitle             string
 	Standing                StandingView
 	World                   WorldState
+	Calendar                CalendarView
 	Situation               string
 	HighImpactRemaining     int
 	HighImpactCap           int
@@ -748,6 +879,18 @@ type PageData struct {
 	MarketMaxSell           int
 	MarketBuyDisabled       bool
 	MarketSellDisabled      bool
+	Commodities             []CommodityView
+	MarketTaxNote           string
+	MarketOrders            []MarketOrderView
+	MarketHistory           []MarketHistoryView
+	Caravans                []CaravanView
+	CaravanGoods            []CaravanGood
+	CaravanGuardCost        int
+	CaravanMaxEscorts       int
+	CaravanMaxCargo         int
+	CaravanMaxGuards        int
+	Buildings               []BuildingView
+	BuildOptions            []BuildingOption
 	ReliefCost              int
 	ReliefDisabled          bool
 	ReliefLabel             string
@@ -770,6 +913,14 @@ type PageData struct {
 	VisibleContractN        int
 	TotalContractN          int
 	Seats                   []SeatView
+	Treasuries              []TreasuryView
+	TreasuryLedger          []TreasuryEntryView
+	TreasuryReliefCost      int
+	TreasuryPatrolCost      int
+	TreasuryBountyMin       int
+	TreasuryBountyMax       int
+	ElectionFilingFee       int
+	ElectionBribeMin        int
 	Policies                PolicyState
 	Rumors                  []RumorView
 	Evidence                []EvidenceView
@@ -782,6 +933,9 @@ type PageData struct {
 	Obligations             []ObligationView
 	Permits                 []PermitView
 	Warrants                []WarrantView
+	Cases                   []CaseView
+	TheftClues              []TheftClueView
+	Fight                   *FightView
 	Relics                  []RelicView
 	RelicAppraiseCost       int
 	Projects                []ProjectView
@@ -820,25 +974,37 @@ const (
 
 type DeliverOutcome struct {
 	RewardGold int
-	HeatDelta  int
-	RepDelta   int
-	Stance     string
+	// TaxGold is the city's cut of the reward, paid to the City Authority.
+	TaxGold   int
+	HeatDelta int
+	RepDelta  int
+	Stance    string
 }
 
 var nameFirst = []string{"Ash", "Bran", "Corin", "Dain", "Elow", "Fenn", "Garr", "Hale", "Ira", "Jory", "Kael", "Liora", "Mara", "Nell", "Orin", "Perrin", "Quill", "Rysa", "Sable", "Tarin"}
 var nameLast = []string{"Stone", "Vale", "Thorne", "Mire", "Brindle", "Hollow", "Reed", "Kestrel", "Cinder", "Rook", "Fen", "Crow", "Wick", "Hearth", "Barrow"}
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "simulate" {
+		os.Exit(runSimulateCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
 	loadDotEnv()
+	if err := loadContentFromEnv(); err != nil {
+		log.Fatal(err)
+	}
 	tmpl := parseTemplates()
 	store, err := newConfiguredStore()
 	if err != nil {
 		log.Fatal(err)
 	}
-	startTickScheduler(store)
-	startCleanupScheduler(store)
 	mux := newMux(store, tmpl)
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ln, err := net.Listen("tcp", serverAddr)
+	if err != nil {
+		log.Fatal(err)
+	}
 	log.Printf("listening on http://localhost%s", serverAddr)
 	server := &http.Server{
 		Addr:              serverAddr,
@@ -848,7 +1014,10 @@ func main() {
 		WriteTimeout:      30 * time.Second,
 		IdleTimeout:       60 * time.Second,
 	}
-	log.Fatal(server.ListenAndServe())
+	if err := runServer(ctx, server, ln, store); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("shut down cleanly")
 }
 
 func loadDotEnv() {
@@ -869,39 +1038,34 @@ func newMux(store *Store, tmpl *template.Template) *http.ServeMux {
 			return
 		}
 
-		// Concurrency model: lock for full handler to keep all reads/writes consistent and race-free.
-		store.mu.Lock()
-		defer store.mu.Unlock()
-		defer store.persistLocked()
+		// A full page load may run the daily tick and consumes the toast, so
+		// it goes through the writer; see concurrency.go.
+		var data PageData
+		store.writeTracked(func() {
+			p := ensurePlayerLocked(store, w, r)
+			now := time.Now().UTC()
+			p.LastSeen = now
 
-		p := ensurePlayerLocked(store, w, r)
-		now := time.Now().UTC()
-		p.LastSeen = now
-
-		// Daily bootstrap tick: first login in a UTC date runs exactly one tick.
-		today := now.Format("2006-01-02")
-		if store.LastDailyTickDate != today {
-			runWorldTickLocked(store, now)
-			store.LastTickAt = now
-			store.LastDailyTickDate = today
-			addEventLocked(store, Event{Type: "Daily", Severity: 1, Text: "A new day dawns with fresh uncertainty.", At: now})
-			setToastLocked(store, p.ID, "The city shifts with a new dawn.")
-		}
-
-		renderPage(w, tmpl, "base", buildPageDataLocked(store, p.ID, true))
+			// Daily bootstrap tick: first login in a UTC date runs exactly one tick.
+			today := now.Format("2006-01-02")
+			if store.LastDailyTickDate != today {
+				advanceWorldLocked(store, now, true)
+				setToastLocked(store, p.ID, "The city shifts with a new dawn.")
+			}
+			data = buildPageDataLocked(store, p.ID, true)
+		})
+		renderPage(w, tmpl, "base", data)
 	})
 
+	mux.HandleFunc("/push", handlePushStream(store, tmpl))
+	registerAPIRoutes(mux, store)
+
 	mux.HandleFunc("/frag/dashboard", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
-		store.mu.Lock()
-		defer store.mu.Unlock()
-		defer store.persistLocked()
-		p := ensurePlayerLocked(store, w, r)
-		p.LastSeen = time.Now().UTC()
-		renderActionLikeResponse(w, tmpl, buildPageDataLocked(store, p.ID, false), false)
+		renderActionLikeResponse(w, tmpl, readPageData(store, w, r), false)
 	})
 
 	mux.HandleFunc("/frag/events", func(w http.ResponseWriter, r *http.Request) {
@@ -909,12 +1073,7 @@ func newMux(store *Store, tmpl *template.Template) *http.ServeMux {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
-		store.mu.Lock()
-		defer store.mu.Unlock()
-		defer store.persistLocked()
-		p := ensurePlayerLocked(store, w, r)
-		p.LastSeen = time.Now().UTC()
-		renderPage(w, tmpl, "events_inner", buildPageDataLocked(store, p.ID, false))
+		renderPage(w, tmpl, "events_inner", readPageData(store, w, r))
 	})
 
 	mux.HandleFunc("/frag/chat", func(w http.ResponseWriter, r *http.Request) {
@@ -922,12 +1081,7 @@ func newMux(store *Store, tmpl *template.Template) *http.ServeMux {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
-		store.mu.Lock()
-		defer store.mu.Unlock()
-		defer store.persistLocked()
-		p := ensurePlayerLocked(store, w, r)
-		p.LastSeen = time.Now().UTC()
-		renderPage(w, tmpl, "chat_inner", buildPageDataLocked(store, p.ID, false))
+		renderPage(w, tmpl, "chat_inner", readPageData(store, w, r))
 	})
 
 	mux.HandleFunc("/frag/diplomacy", func(w http.ResponseWriter, r *http.Request) {
@@ -935,12 +1089,7 @@ func newMux(store *Store, tmpl *template.Template) *http.ServeMux {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
-		store.mu.Lock()
-		defer store.mu.Unlock()
-		defer store.persistLocked()
-		p := ensurePlayerLocked(store, w, r)
-		p.LastSeen = time.Now().UTC()
-		renderPage(w, tmpl, "diplomacy_inner", buildPageDataLocked(store, p.ID, false))
+		renderPage(w, tmpl, "diplomacy_inner", readPageData(store, w, r))
 	})
 
 	mux.HandleFunc("/frag/players", func(w http.ResponseWriter, r *http.Request) {
@@ -948,12 +1097,7 @@ func newMux(store *Store, tmpl *template.Template) *http.ServeMux {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
-		store.mu.Lock()
-		defer store.mu.Unlock()
-		defer store.persistLocked()
-		p := ensurePlayerLocked(store, w, r)
-		p.LastSeen = time.Now().UTC()
-		renderPage(w, tmpl, "players_inner", buildPageDataLocked(store, p.ID, false))
+		renderPage(w, tmpl, "players_inner", readPageData(store, w, r))
 	})
 
 	mux.HandleFunc("/frag/institutions", func(w http.ResponseWriter, r *http.Request) {
@@ -961,12 +1105,7 @@ func newMux(store *Store, tmpl *template.Template) *http.ServeMux {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
-		store.mu.Lock()
-		defer store.mu.Unlock()
-		defer store.persistLocked()
-		p := ensurePlayerLocked(store, w, r)
-		p.LastSeen = time.Now().UTC()
-		renderPage(w, tmpl, "institutions_inner", buildPageDataLocked(store, p.ID, false))
+		renderPage(w, tmpl, "institutions_inner", readPageData(store, w, r))
 	})
 
 	mux.HandleFunc("/frag/intel", func(w http.ResponseWriter, r *http.Request) {
@@ -974,12 +1113,7 @@ func newMux(store *Store, tmpl *template.Template) *http.ServeMux {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
-		store.mu.Lock()
-		defer store.mu.Unlock()
-		defer store.persistLocked()
-		p := ensurePlayerLocked(store, w, r)
-		p.LastSeen = time.Now().UTC()
-		renderPage(w, tmpl, "intel_inner", buildPageDataLocked(store, p.ID, false))
+		renderPage(w, tmpl, "intel_inner", readPageData(store, w, r))
 	})
 
 	mux.HandleFunc("/frag/ledger", func(w http.ResponseWriter, r *http.Request) {
@@ -987,12 +1121,7 @@ func newMux(store *Store, tmpl *template.Template) *http.ServeMux {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
-		store.mu.Lock()
-		defer store.mu.Unlock()
-		defer store.persistLocked()
-		p := ensurePlayerLocked(store, w, r)
-		p.LastSeen = time.Now().UTC()
-		renderPage(w, tmpl, "ledger_inner", buildPageDataLocked(store, p.ID, false))
+		renderPage(w, tmpl, "ledger_inner", readPageData(store, w, r))
 	})
 
 	mux.HandleFunc("/frag/market", func(w http.ResponseWriter, r *http.Request) {
@@ -1000,12 +1129,7 @@ func newMux(store *Store, tmpl *template.Template) *http.ServeMux {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
-		store.mu.Lock()
-		defer store.mu.Unlock()
-		defer store.persistLocked()
-		p := ensurePlayerLocked(store, w, r)
-		p.LastSeen = time.Now().UTC()
-		renderPage(w, tmpl, "market_inner", buildPageDataLocked(store, p.ID, false))
+		renderPage(w, tmpl, "market_inner", readPageData(store, w, r))
 	})
 
 	mux.HandleFunc("/action", func(w http.ResponseWriter, r *http.Request) {
@@ -1017,21 +1141,6 @@ func newMux(store *Store, tmpl *template.Template) *http.ServeMux {
 			return
 		}
 
-		store.mu.Lock()
-		defer store.mu.Unlock()
-		defer store.persistLocked()
-
-		p := ensurePlayerLocked(store, w, r)
-		now := time.Now().UTC()
-		p.LastSeen = now
-
-		if tooSoon(store.LastActionAt[p.ID], now, actionCooldown) {
-			setToastLocked(store, p.ID, "Slow down.")
-			renderActionLikeResponse(w, tmpl, buildPageDataLocked(store, p.ID, true), false)
-			return
-		}
-		store.LastActionAt[p.ID] = now
-
 		input := ActionInput{
 			Action:       strings.TrimSpace(r.FormValue("action")),
 			ContractID:   strings.TrimSpace(r.FormValue("contract_id")),
@@ -1044,19 +1153,37 @@ func newMux(store *Store, tmpl *template.Template) *http.ServeMux {
 			ObligationID: strings.TrimSpace(r.FormValue("obligation_id")),
 			ProjectType:  strings.TrimSpace(r.FormValue("project_type")),
 			LocationID:   strings.TrimSpace(r.FormValue("location_id")),
+			Commodity:    strings.TrimSpace(r.FormValue("commodity")),
+			OrderID:      strings.TrimSpace(r.FormValue("order_id")),
+			CaravanID:    strings.TrimSpace(r.FormValue("caravan_id")),
+			BuildingType: strings.TrimSpace(r.FormValue("building_type")),
+			BuildingID:   strings.TrimSpace(r.FormValue("building_id")),
 		}
 		if n, err := strconv.Atoi(strings.TrimSpace(r.FormValue("amount"))); err == nil {
 			input.Amount = n
 		}
+		if n, err := strconv.Atoi(strings.TrimSpace(r.FormValue("price"))); err == nil {
+			input.Price = n
+		}
 		if n, err := strconv.Atoi(strings.TrimSpace(r.FormValue("sacks"))); err == nil {
 			input.Sacks = n
 		}
 		if n, err := strconv.Atoi(strings.TrimSpace(r.FormValue("reward"))); err == nil {
 			input.Reward = n
 		}
+		if n, err := strconv.Atoi(strings.TrimSpace(r.FormValue("guards"))); err == nil {
+			input.Guards = n
+		}
 
-		handleActionInputLocked(store, p, now, input)
-		renderActionLikeResponse(w, tmpl, buildPageDataLocked(store, p.ID, true), false)
+		var data PageData
+		store.writeTracked(func() {
+			p := ensurePlayerLocked(store, w, r)
+			now := time.Now().UTC()
+			p.LastSeen = now
+			submitActionLocked(store, p, now, input)
+			data = buildPageDataLocked(store, p.ID, true)
+		})
+		renderActionLikeResponse(w, tmpl, data, false)
 	})
 
 	mux.HandleFunc("/chat", func(w http.ResponseWriter, r *http.Request) {
@@ -1068,33 +1195,19 @@ func newMux(store *Store, tmpl *template.Template) *http.ServeMux {
 			return
 		}
 
-		store.mu.Lock()
-		defer store.mu.Unlock()
-		defer store.persistLocked()
-
-		p := ensurePlayerLocked(store, w, r)
-		now := time.Now().UTC()
-		p.LastSeen = now
 		rawMsg := r.FormValue("text")
 		msg := strings.TrimSpace(rawMsg)
 
-		if tooSoon(store.LastChatAt[p.ID], now, chatCooldown) {
-			setToastLocked(store, p.ID, "Chat cooldown active.")
-			data := buildPageDataLocked(store, p.ID, true)
-			data.ChatDraft = rawMsg
-			renderChatResponse(w, tmpl, data, true)
-			return
-		}
-
-		if msg == "" {
-			renderChatResponse(w, tmpl, buildPageDataLocked(store, p.ID, true), true)
-			return
-		}
-
-		store.LastChatAt[p.ID] = now
-		accepted := handleChatLocked(store, p, now, msg)
-		data := buildPageDataLocked(store, p.ID, true)
-		if !accepted {
+		var data PageData
+		accepted := false
+		store.writeTracked(func() {
+			p := ensurePlayerLocked(store, w, r)
+			now := time.Now().UTC()
+			p.LastSeen = now
+			accepted = submitChatLocked(store, p, now, msg)
+			data = buildPageDataLocked(store, p.ID, true)
+		})
+		if !accepted && msg != "" {
 			data.ChatDraft = rawMsg
 		}
 		renderChatResponse(w, tmpl, data, true)
@@ -1109,80 +1222,29 @@ func newMux(store *Store, tmpl *template.Template) *http.ServeMux {
 			return
 		}
 
-		store.mu.Lock()
-		defer store.mu.Unlock()
-		defer store.persistLocked()
-
-		p := ensurePlayerLocked(store, w, r)
-		now := time.Now().UTC()
-		p.LastSeen = now
-
 		targetID := strings.TrimSpace(r.FormValue("target_id"))
 		subject := strings.TrimSpace(r.FormValue("subject"))
 		body := strings.TrimSpace(r.FormValue("body"))
 		sealed := strings.TrimSpace(r.FormValue("sealed")) != ""
 
-		data := buildPageDataLocked(store, p.ID, true)
-		data.MessageDraftTargetID = targetID
-		data.MessageDraftSubject = subject
-		data.MessageDraftBody = body
-		data.MessageDraftSealed = sealed
-
-		if tooSoon(store.LastMessageAt[p.ID], now, messageCooldown) {
-			setToastLocked(store, p.ID, "Couriers need more time to return.")
-			renderActionLikeResponse(w, tmpl, data, false)
-			return
-		}
-
-		if targetID == "" {
-			setToastLocked(store, p.ID, "Choose a recipient.")
-			renderActionLikeResponse(w, tmpl, data, false)
-			return
-		}
-		target := store.Players[targetID]
-		if target == nil || target.ID == p.ID {
-			setToastLocked(store, p.ID, "That recipient is unavailable.")
-			renderActionLikeResponse(w, tmpl, data, false)
-			return
-		}
-		if subject == "" || body == "" {
-			setToastLocked(store, p.ID, "Subject and message are required.")
-			renderActionLikeResponse(w, tmpl, data, false)
-			return
-		}
-		if len(subject) > messageSubjectMax {
-			setToastLocked(store, p.ID, fmt.Sprintf("Subject too long (max %d).", messageSubjectMax))
-			renderActionLikeResponse(w, tmpl, data, false)
-			return
-		}
-		if len(body) > messageBodyMax {
-			setToastLocked(store, p.ID, fmt.Sprintf("Message too long (max %d).", messageBodyMax))
-			renderActionLikeResponse(w, tmpl, data, false)
-			return
-		}
-		if sealed && p.Gold < sealedMessageCost {
-			setToastLocked(store, p.ID, fmt.Sprintf("Need %dg to seal a missive.", sealedMessageCost))
-			renderActionLikeResponse(w, tmpl, data, false)
-			return
-		}
-
-		store.LastMessageAt[p.ID] = now
-		if sealed {
-			p.Gold -= sealedMessageCost
-		}
-		addDiplomacyMessageLocked(store, DiplomaticMessage{
-			FromPlayerID: p.ID,
-			FromName:     p.Name,
-			ToPlayerID:   target.ID,
-			ToName:       target.Name,
-			Subject:      subject,
-			Body:         body,
-			At:           now,
-			Sealed:       sealed,
+		var data PageData
+		store.writeTracked(func() {
+			p := ensurePlayerLocked(store, w, r)
+			now := time.Now().UTC()
+			p.LastSeen = now
+
+			data = buildPageDataLocked(store, p.ID, true)
+			data.MessageDraftTargetID = targetID
+			data.MessageDraftSubject = subject
+			data.MessageDraftBody = body
+			data.MessageDraftSealed = sealed
+
+			in := MissiveInput{TargetID: targetID, Subject: subject, Body: body, Sealed: sealed}
+			if submitMissiveLocked(store, p, now, in) {
+				data = buildPageDataLocked(store, p.ID, true)
+			}
 		})
-		setToastLocked(store, p.ID, fmt.Sprintf("Courier dispatched to %s.", target.Name))
-		setToastLocked(store, target.ID, fmt.Sprintf("A courier arrives from %s.", p.Name))
-		renderActionLikeResponse(w, tmpl, buildPageDataLocked(store, p.ID, true), false)
+		renderActionLikeResponse(w, tmpl, data, false)
 	})
 
 	mux.HandleFunc("/admin", func(w http.ResponseWriter, r *http.Request) {
@@ -1200,8 +1262,9 @@ func newMux(store *Store, tmpl *template.Template) *http.ServeMux {
 			return
 		}
 
-		store.mu.Lock()
-		defer store.mu.Unlock()
+		// Render from the read view into a buffer and send it after.
+		store := store.readView()
+		page := &bytes.Buffer{}
 
 		openContracts := 0
 		acceptedContracts := 0
@@ -1232,49 +1295,55 @@ func newMux(store *Store, tmpl *template.Template) *http.ServeMux {
 		online := onlinePlayersLocked(store, time.Now().UTC())
 		diag := buildAdminDiagnosticsLocked(store, time.Now().UTC())
 
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		_, _ = io.WriteString(w, "<!DOCTYPE html><html><head><meta charset=\"utf-8\"><title>Admin</title><style>body{font-family:ui-sans-serif,system-ui;background:#0b0f14;color:#e5ecf4;padding:24px;line-height:1.45}.muted{color:#9aa8bb}.row{display:flex;gap:12px;flex-wrap:wrap;margin:12px 0}.card{background:#121923;border:1px solid #2a3442;padding:12px;border-radius:8px;min-width:140px}h1,h2,h3{margin:0 0 8px}pre{background:#121923;border:1px solid #2a3442;padding:12px;border-radius:8px;overflow:auto}button{background:#1f6feb;color:#fff;border:0;padding:8px 12px;border-radius:6px;margin-right:8px;cursor:pointer}button.warn{background:#a11f32}input,select{background:#0e141d;color:#e5ecf4;border:1px solid #2a3442;border-radius:6px;padding:6px 8px;margin-right:8px}table{border-collapse:collapse;width:100%;max-width:720px;background:#121923;border:1px solid #2a3442;border-radius:8px;overflow:hidden}th,td{border-bottom:1px solid #2a3442;padding:8px;text-align:left}th{background:#0e141d}</style></head><body>")
-		_, _ = fmt.Fprintf(w, "<h1>Admin</h1><p>Persistent realm state enabled via DB_DIALECT.</p>")
-		_, _ = fmt.Fprintf(w, "<p><a href=\"/admin/state\" style=\"color:#9fc1ff\">Download state snapshot (JSON)</a></p>")
-		_, _ = fmt.Fprintf(w, "<div class=\"row\"><div class=\"card\"><div class=\"muted\">Day</div><div>%d %s</div></div><div class=\"card\"><div class=\"muted\">Ticks</div><div>%d</div></div><div class=\"card\"><div class=\"muted\">Players</div><div>%d</div></div><div class=\"card\"><div class=\"muted\">Online</div><div>%d</div></div><div class=\"card\"><div class=\"muted\">Contracts</div><div>%d open / %d accepted</div></div><div class=\"card\"><div class=\"muted\">Failures</div><div>%d</div></div></div>",
+		_, _ = io.WriteString(page, "<!DOCTYPE html><html><head><meta charset=\"utf-8\"><title>Admin</title><style>body{font-family:ui-sans-serif,system-ui;background:#0b0f14;color:#e5ecf4;padding:24px;line-height:1.45}.muted{color:#9aa8bb}.row{display:flex;gap:12px;flex-wrap:wrap;margin:12px 0}.card{background:#121923;border:1px solid #2a3442;padding:12px;border-radius:8px;min-width:140px}h1,h2,h3{margin:0 0 8px}pre{background:#121923;border:1px solid #2a3442;padding:12px;border-radius:8px;overflow:auto}button{background:#1f6feb;color:#fff;border:0;padding:8px 12px;border-radius:6px;margin-right:8px;cursor:pointer}button.warn{background:#a11f32}input,select{background:#0e141d;color:#e5ecf4;border:1px solid #2a3442;border-radius:6px;padding:6px 8px;margin-right:8px}table{border-collapse:collapse;width:100%;max-width:720px;background:#121923;border:1px solid #2a3442;border-radius:8px;overflow:hidden}th,td{border-bottom:1px solid #2a3442;padding:8px;text-align:left}th{background:#0e141d}</style></head><body>")
+		_, _ = fmt.Fprintf(page, "<h1>Admin</h1><p>Persistent realm state enabled via DB_DIALECT.</p>")
+		_, _ = fmt.Fprintf(page, "<p><a href=\"/admin/state\" style=\"color:#9fc1ff\">Download state snapshot (JSON)</a></p>")
+		_, _ = fmt.Fprintf(page, "<p class=\"muted\">World seed %d. <a href=\"/admin/rng?stream=%s\" style=\"color:#9fc1ff\">Replay recent crisis rolls (JSON)</a></p>", store.rng.Seed, rngStreamCrisis)
+		_, _ = fmt.Fprintf(page, "<div class=\"row\"><div class=\"card\"><div class=\"muted\">Day</div><div>%d %s</div></div><div class=\"card\"><div class=\"muted\">Ticks</div><div>%d</div></div><div class=\"card\"><div class=\"muted\">Players</div><div>%d</div></div><div class=\"card\"><div class=\"muted\">Online</div><div>%d</div></div><div class=\"card\"><div class=\"muted\">Contracts</div><div>%d open / %d accepted</div></div><div class=\"card\"><div class=\"muted\">Failures</div><div>%d</div></div></div>",
 			store.World.DayNumber, template.HTMLEscapeString(store.World.Subphase), store.TickCount, len(store.Players), len(online), openContracts, acceptedContracts, failedContracts)
-		_, _ = fmt.Fprintf(w, "<h2>Controls</h2><form method=\"post\" action=\"/admin/tick\"><input type=\"hidden\" name=\"csrf_token\" value=\"%s\"><label for=\"tick_count\">Advance ticks:</label><input id=\"tick_count\" name=\"tick_count\" type=\"number\" min=\"1\" max=\"%d\" value=\"1\"><button type=\"submit\">Run</button></form>", template.HTMLEscapeString(csrfToken), adminMaxManualTicks)
-		_, _ = fmt.Fprintf(w, "<form method=\"post\" action=\"/admin/reset\" style=\"margin-top:10px\"><input type=\"hidden\" name=\"csrf_token\" value=\"%s\"><label for=\"confirm_reset\">Type %q:</label><input id=\"confirm_reset\" name=\"confirm\" type=\"text\" autocomplete=\"off\"><button class=\"warn\" type=\"submit\">Reset World</button></form>", template.HTMLEscapeString(csrfToken), adminResetConfirmPhrase)
-		_, _ = fmt.Fprintf(w, "<p class=\"muted\">Reset is destructive and clears players, contracts, intel, and history.</p>")
-		_, _ = fmt.Fprintf(w, "<h2>Anomaly &amp; Balance Summary</h2><pre>Total players: %d (online %d, traveling %d)\nEconomy: gold=%d grain=%d avg_gold=%d avg_grain=%d\nStanding: avg_rep=%d avg_heat=%d hottest=%s (%d)\nContracts: issued=%d accepted=%d fulfilled=%d failed=%d overdue_active=%d anomalies=%d\nDebt pressure: overdue_loans=%d overdue_obligations=%d\nWorld pressure: %s\nAlerts (%d):\n",
+		_, _ = fmt.Fprintf(page, "<h2>Controls</h2><form method=\"post\" action=\"/admin/tick\"><input type=\"hidden\" name=\"csrf_token\" value=\"%s\"><label for=\"tick_count\">Advance ticks:</label><input id=\"tick_count\" name=\"tick_count\" type=\"number\" min=\"1\" max=\"%d\" value=\"1\"><button type=\"submit\">Run</button></form>", template.HTMLEscapeString(csrfToken), adminMaxManualTicks)
+		_, _ = fmt.Fprintf(page, "<form method=\"post\" action=\"/admin/reset\" style=\"margin-top:10px\"><input type=\"hidden\" name=\"csrf_token\" value=\"%s\"><label for=\"confirm_reset\">Type %q:</label><input id=\"confirm_reset\" name=\"confirm\" type=\"text\" autocomplete=\"off\"><button class=\"warn\" type=\"submit\">Reset World</button></form>", template.HTMLEscapeString(csrfToken), adminResetConfirmPhrase)
+		_, _ = fmt.Fprintf(page, "<p class=\"muted\">Reset is destructive and clears players, contracts, intel, and history.</p>")
+		_, _ = fmt.Fprintf(page, "<form method=\"post\" action=\"/admin/rollback\" style=\"margin-top:10px\"><input type=\"hidden\" name=\"csrf_token\" value=\"%s\"><label for=\"rollback_tick\">Roll back to tick:</label><input id=\"rollback_tick\" name=\"tick\" type=\"number\" min=\"0\" max=\"%d\" value=\"%d\"><button class=\"warn\" type=\"submit\">Roll Back</button></form>", template.HTMLEscapeString(csrfToken), store.TickCount, store.TickCount)
+		_, _ = fmt.Fprintf(page, "<p class=\"muted\">Rollback replays the journal from the nearest snapshot. Inspect first via <a href=\"/admin/journal\" style=\"color:#9fc1ff\">/admin/journal</a> and <a href=\"/admin/replay?tick=%d\" style=\"color:#9fc1ff\">/admin/replay?tick=N</a>.</p>", store.TickCount)
+		_, _ = fmt.Fprintf(page, "<form method=\"post\" action=\"/admin/content/reload\" style=\"margin-top:10px\"><input type=\"hidden\" name=\"csrf_token\" value=\"%s\"><span class=\"muted\">Content: %s</span> <button type=\"submit\">Reload Content</button></form>", template.HTMLEscapeString(csrfToken), template.HTMLEscapeString(contentSummary(currentContent())))
+		_, _ = fmt.Fprintf(page, "<h2>Anomaly &amp; Balance Summary</h2><pre>Total players: %d (online %d, traveling %d)\nEconomy: gold=%d grain=%d avg_gold=%d avg_grain=%d\nStanding: avg_rep=%d avg_heat=%d hottest=%s (%d)\nContracts: issued=%d accepted=%d fulfilled=%d failed=%d overdue_active=%d anomalies=%d\nDebt pressure: overdue_loans=%d overdue_obligations=%d\nWorld pressure: %s\nAlerts (%d):\n",
 			diag.TotalPlayers, diag.OnlinePlayers, diag.TravelingPlayers,
 			diag.TotalGold, diag.TotalGrain, diag.AvgGoldPerPlayer, diag.AvgGrainPerPlayer,
 			diag.AvgRep, diag.AvgHeat, template.HTMLEscapeString(diag.HottestPlayer), diag.HottestHeat,
 			diag.ContractsIssued, diag.ContractsAccepted, diag.ContractsFulfilled, diag.ContractsFailed, diag.OverdueActiveContracts, diag.ContractStateAnomalies,
 			diag.OverdueActiveLoans, diag.OverdueOpenObligations, template.HTMLEscapeString(diag.WorldPressureLevel), diag.AlertCount)
 		for _, alert := range diag.Alerts {
-			_, _ = fmt.Fprintf(w, " - %s\n", template.HTMLEscapeString(alert))
+			_, _ = fmt.Fprintf(page, " - %s\n", template.HTMLEscapeString(alert))
 		}
-		_, _ = fmt.Fprintf(w, "</pre>")
-		_, _ = fmt.Fprintf(w, "<h2>Hot Players</h2><table><thead><tr><th>Name</th><th>Heat</th><th>Rep</th><th>Gold</th><th>Travel</th></tr></thead><tbody>")
+		_, _ = fmt.Fprintf(page, "</pre>")
+		_, _ = fmt.Fprintf(page, "<h2>Hot Players</h2><table><thead><tr><th>Name</th><th>Heat</th><th>Rep</th><th>Gold</th><th>Travel</th></tr></thead><tbody>")
 		for _, p := range players {
 			travel := "No"
 			if p.TravelTicksLeft > 0 {
 				travel = fmt.Sprintf("Yes (%d)", p.TravelTicksLeft)
 			}
-			_, _ = fmt.Fprintf(w, "<tr><td>%s</td><td>%d</td><td>%d</td><td>%d</td><td>%s</td></tr>",
+			_, _ = fmt.Fprintf(page, "<tr><td>%s</td><td>%d</td><td>%d</td><td>%d</td><td>%s</td></tr>",
 				template.HTMLEscapeString(p.Name), p.Heat, p.Rep, p.Gold, template.HTMLEscapeString(travel))
 		}
-		_, _ = fmt.Fprintf(w, "</tbody></table>")
+		_, _ = fmt.Fprintf(page, "</tbody></table>")
 
-		_, _ = fmt.Fprintf(w, "<h2>World</h2><pre>%+v</pre>", store.World)
-		_, _ = fmt.Fprintf(w, "<h2>Active Crisis</h2><pre>%+v</pre>", store.ActiveCrisis)
-		_, _ = fmt.Fprintf(w, "<h2>Active Contracts</h2><pre>")
+		_, _ = fmt.Fprintf(page, "<h2>World</h2><pre>%+v</pre>", store.World)
+		_, _ = fmt.Fprintf(page, "<h2>Active Crisis</h2><pre>%+v</pre>", store.ActiveCrisis)
+		_, _ = fmt.Fprintf(page, "<h2>Active Contracts</h2><pre>")
 		for _, c := range sortedContractsLocked(store) {
 			if c.Status == "Issued" || c.Status == "Accepted" {
-				_, _ = fmt.Fprintf(w, "%+v\n", *c)
+				_, _ = fmt.Fprintf(page, "%+v\n", *c)
 			}
 		}
-		_, _ = fmt.Fprintf(w, "</pre><h2>Online Players</h2><pre>")
+		_, _ = fmt.Fprintf(page, "</pre><h2>Online Players</h2><pre>")
 		for _, p := range online {
-			_, _ = fmt.Fprintf(w, "%s (%s) Gold:%d Rep:%d\n", p.Name, reputationTitle(p.Rep), p.Gold, p.Rep)
+			_, _ = fmt.Fprintf(page, "%s (%s) Gold:%d Rep:%d\n", p.Name, reputationTitle(p.Rep), p.Gold, p.Rep)
 		}
-		_, _ = fmt.Fprintf(w, "</pre></body></html>")
+		_, _ = fmt.Fprintf(page, "</pre></body></html>")
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(page.Bytes())
 	})
 
 	mux.HandleFunc("/admin/state", func(w http.ResponseWriter, r *http.Request) {
@@ -1286,16 +1355,18 @@ func newMux(store *Store, tmpl *template.Template) *http.ServeMux {
 			http.Error(w, "forbidden", http.StatusForbidden)
 			return
 		}
-		store.mu.Lock()
-		defer store.mu.Unlock()
-
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		w.Header().Set("Cache-Control", "no-store")
+		store := store.readView()
+		var crisis *Crisis
+		if store.ActiveCrisis != nil {
+			c := *store.ActiveCrisis
+			crisis = &c
+		}
 		payload := map[string]any{
 			"generated_at":  time.Now().UTC().Format(time.RFC3339),
 			"tick_count":    store.TickCount,
+			"rng_seed":      store.rng.Seed,
 			"world":         store.World,
-			"active_crisis": store.ActiveCrisis,
+			"active_crisis": crisis,
 			"diagnostics":   buildAdminDiagnosticsLocked(store, time.Now().UTC()),
 			"counts": map[string]int{
 				"players":      len(store.Players),
@@ -1314,6 +1385,9 @@ func newMux(store *Store, tmpl *template.Template) *http.ServeMux {
 				"intercepts":   len(store.Intercepts),
 			},
 		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
 		enc := json.NewEncoder(w)
 		enc.SetIndent("", "  ")
 		if err := enc.Encode(payload); err != nil {
@@ -1322,6 +1396,202 @@ func newMux(store *Store, tmpl *template.Template) *http.ServeMux {
 		}
 	})
 
+	mux.HandleFunc("/admin/rng", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !isAdmin(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		stream := strings.TrimSpace(r.URL.Query().Get("stream"))
+		known := false
+		for _, name := range knownRNGStreams {
+			if name == stream {
+				known = true
+			}
+		}
+		if !known {
+			http.Error(w, fmt.Sprintf("stream must be one of %s", strings.Join(knownRNGStreams, ", ")), http.StatusBadRequest)
+			return
+		}
+
+		store := store.readView()
+		to := store.TickCount
+		if raw := strings.TrimSpace(r.URL.Query().Get("to")); raw != "" {
+			if parsed, err := strconv.ParseInt(raw, 10, 64); err == nil {
+				to = parsed
+			}
+		}
+		from := to - 9
+		if raw := strings.TrimSpace(r.URL.Query().Get("from")); raw != "" {
+			if parsed, err := strconv.ParseInt(raw, 10, 64); err == nil {
+				from = parsed
+			}
+		}
+		from = maxInt64(from, to-rngHistoryTicks+1)
+		payload := map[string]any{
+			"seed":   store.rng.Seed,
+			"stream": stream,
+			"ticks":  replayRollsLocked(store, stream, from, to),
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(payload); err != nil {
+			http.Error(w, "failed to encode rolls", http.StatusInternalServerError)
+			return
+		}
+	})
+
+	mux.HandleFunc("/admin/journal", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !isAdmin(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		filter := JournalFilter{PlayerID: strings.TrimSpace(r.URL.Query().Get("player_id"))}
+		if n, err := strconv.ParseInt(strings.TrimSpace(r.URL.Query().Get("from")), 10, 64); err == nil {
+			filter.FromTick = n
+		}
+		if n, err := strconv.ParseInt(strings.TrimSpace(r.URL.Query().Get("to")), 10, 64); err == nil {
+			filter.ToTick = n
+		}
+
+		var entries []JournalEntry
+		if store.repo != nil {
+			store.flush()
+			var err error
+			entries, err = store.repo.JournalEntries(r.Context(), filter)
+			if err != nil {
+				http.Error(w, "failed to read journal", http.StatusInternalServerError)
+				return
+			}
+		} else {
+			store.mu.RLock()
+			entries = filterJournalEntries(store.journalPending, filter)
+			store.mu.RUnlock()
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(map[string]any{"entries": entries}); err != nil {
+			http.Error(w, "failed to encode journal", http.StatusInternalServerError)
+			return
+		}
+	})
+
+	mux.HandleFunc("/admin/replay", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !isAdmin(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		tick, err := strconv.ParseInt(strings.TrimSpace(r.URL.Query().Get("tick")), 10, 64)
+		if err != nil || tick < 0 {
+			http.Error(w, "tick is required", http.StatusBadRequest)
+			return
+		}
+
+		if store.repo == nil {
+			http.Error(w, "replay requires a database", http.StatusServiceUnavailable)
+			return
+		}
+		// The rebuild works on its own store but swaps the active content
+		// while it replays, so it runs on the writer once pending changes are
+		// saved.
+		store.flush()
+		var rebuilt *Store
+		store.write(func() {
+			rebuilt, err = store.repo.RebuildAt(r.Context(), tick)
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		players := make([]map[string]any, 0, len(rebuilt.Players))
+		for _, p := range rebuilt.Players {
+			players = append(players, map[string]any{"id": p.ID, "name": p.Name, "gold": p.Gold, "grain": p.Grain, "rep": p.Rep, "heat": p.Heat, "location": p.LocationID})
+		}
+		sort.Slice(players, func(i, j int) bool { return players[i]["id"].(string) < players[j]["id"].(string) })
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(map[string]any{
+			"tick_count":    rebuilt.TickCount,
+			"world":         rebuilt.World,
+			"policies":      rebuilt.Policies,
+			"active_crisis": rebuilt.ActiveCrisis,
+			"players":       players,
+		}); err != nil {
+			http.Error(w, "failed to encode replay", http.StatusInternalServerError)
+			return
+		}
+	})
+
+	mux.HandleFunc("/admin/rollback", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !isAdmin(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if !parsePostFormLimited(w, r, maxFormBodyBytes) {
+			return
+		}
+		if !hasValidAdminHeaderToken(r) && !validateAdminCSRF(r) {
+			http.Error(w, "invalid csrf token", http.StatusForbidden)
+			return
+		}
+		tick, err := strconv.ParseInt(strings.TrimSpace(r.FormValue("tick")), 10, 64)
+		if err != nil || tick < 0 {
+			http.Error(w, "tick is required", http.StatusBadRequest)
+			return
+		}
+
+		if store.repo == nil {
+			http.Error(w, "rollback requires a database", http.StatusServiceUnavailable)
+			return
+		}
+		current := store.readView().TickCount
+		if tick > current {
+			http.Error(w, "cannot roll forward past the current tick", http.StatusBadRequest)
+			return
+		}
+		store.flush()
+		store.write(func() {
+			var rebuilt *Store
+			rebuilt, err = store.repo.RebuildAt(r.Context(), tick)
+			if err != nil {
+				return
+			}
+			now := time.Now().UTC()
+			installStoreLocked(store, rebuilt)
+			recordJournalLocked(store, JournalEntry{Kind: journalKindRollback, At: now})
+			addEventLocked(store, Event{Type: "Admin", Severity: 2, Text: fmt.Sprintf("The chroniclers rewind the city to tick %d.", tick), At: now})
+			queueSnapshotLocked(store, now)
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Redirect(w, r, "/admin", http.StatusSeeOther)
+	})
+
 	mux.HandleFunc("/admin/tick", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -1338,9 +1608,6 @@ func newMux(store *Store, tmpl *template.Template) *http.ServeMux {
 			http.Error(w, "invalid csrf token", http.StatusForbidden)
 			return
 		}
-		store.mu.Lock()
-		defer store.mu.Unlock()
-		defer store.persistLocked()
 		n := 1
 		if raw := strings.TrimSpace(r.FormValue("tick_count")); raw != "" {
 			if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 {
@@ -1350,11 +1617,48 @@ func newMux(store *Store, tmpl *template.Template) *http.ServeMux {
 		if n > adminMaxManualTicks {
 			n = adminMaxManualTicks
 		}
-		now := time.Now().UTC()
-		for i := 0; i < n; i++ {
-			runWorldTickLocked(store, now)
+		store.write(func() {
+			now := time.Now().UTC()
+			for i := 0; i < n; i++ {
+				advanceWorldLocked(store, now, false)
+			}
+		})
+		http.Redirect(w, r, "/admin", http.StatusSeeOther)
+	})
+
+	mux.HandleFunc("/admin/content/reload", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !isAdmin(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if !parsePostFormLimited(w, r, maxFormBodyBytes) {
+			return
+		}
+		if !hasValidAdminHeaderToken(r) && !validateAdminCSRF(r) {
+			http.Error(w, "invalid csrf token", http.StatusForbidden)
+			return
+		}
+		// Swap content on the writer so no tick or action sees a mix of old
+		// and new definitions.
+		var err error
+		store.write(func() {
+			var c *GameContent
+			c, err = reloadContent()
+			if err != nil {
+				return
+			}
+			log.Printf("content reloaded: %s", contentSummary(c))
+			recordJournalLocked(store, JournalEntry{Kind: journalKindContent, At: c.LoadedAt, Text: c.Source, Content: c})
+		})
+		if err != nil {
+			log.Printf("content reload rejected: %v", err)
+			http.Error(w, "content reload failed; active content unchanged:\n"+err.Error(), http.StatusBadRequest)
+			return
 		}
-		store.LastTickAt = now
 		http.Redirect(w, r, "/admin", http.StatusSeeOther)
 	})
 
@@ -1374,14 +1678,16 @@ func newMux(store *Store, tmpl *template.Template) *http.ServeMux {
 			http.Error(w, "invalid csrf token", http.StatusForbidden)
 			return
 		}
-		store.mu.Lock()
-		defer store.mu.Unlock()
-		defer store.persistLocked()
 		if strings.TrimSpace(r.FormValue("confirm")) != adminResetConfirmPhrase {
 			http.Error(w, "missing reset confirmation phrase", http.StatusBadRequest)
 			return
 		}
-		resetStoreLocked(store)
+		store.write(func() {
+			resetStoreLocked(store)
+			now := time.Now().UTC()
+			recordJournalLocked(store, JournalEntry{Kind: journalKindReset, At: now, Seed: store.rng.Seed})
+			queueSnapshotLocked(store, now)
+		})
 		http.Redirect(w, r, "/admin", http.StatusSeeOther)
 	})
 	return mux
@@ -1395,6 +1701,7 @@ func parseTemplates() *template.Template {
 func newStore() *Store {
 	now := time.Now().UTC()
 	s := &Store{
+		presence: &presenceTable{},
 		World: WorldState{
 			DayNumber:              1,
 			Subphase:               "Morning",
@@ -1421,10 +1728,18 @@ func newStore() *Store {
 		Warrants:          map[string]*Warrant{},
 		Relics:            map[int64]*Relic{},
 		Projects:          map[string]*Project{},
+		Orders:            map[string]*MarketOrder{},
+		Caravans:          map[string]*Caravan{},
+		Buildings:         map[string]*Building{},
+		Cases:             map[string]*CourtCase{},
+		Clues:             map[string]*TheftClue{},
+		Fights:            map[string]*Fight{},
 		ActiveCrisis:      nil,
 		Events:            []Event{},
 		Chat:              []ChatMessage{},
 		Messages:          []DiplomaticMessage{},
+		MarketHistory:     []MarketTick{},
+		TreasuryLedger:    []TreasuryEntry{},
 		LastDailyTickDate: "",
 		LastTickAt:        now,
 		TickEvery:         60 * time.Second,
@@ -1440,7 +1755,8 @@ func newStore() *Store {
 		DailyHighImpactN:  map[string]int{},
 		ToastByPlayer:     map[string]string{},
 		LastCleanupDate:   "",
-		rng:               mathrand.New(mathrand.NewSource(now.UnixNano())),
+		rng:               newWorldRNG(worldSeedFromEnv(now)),
+		push:              newPushHub(),
 	}
 	initializeInstitutionsLocked(s)
 	addEventLocked(s, Event{Type: "Opening", Severity: 1, Text: "The granary gates creak open under a restless sky.", At: now})
@@ -1460,7 +1776,9 @@ func resetStoreLocked(s *Store) {
 		WardNetworkTicks:       0,
 		Situation:              deriveSituation("Stable", "Calm"),
 	}
+	s.dirty.markAll()
 	s.Players = map[string]*Player{}
+	s.apiTokens = nil
 	s.Contracts = map[string]*Contract{}
 	s.Institutions = map[string]*Institution{}
 	s.Seats = map[string]*Seat{}
@@ -1475,16 +1793,31 @@ func resetStoreLocked(s *Store) {
 	s.Warrants = map[string]*Warrant{}
 	s.Relics = map[int64]*Relic{}
 	s.Projects = map[string]*Project{}
+	s.Orders = map[string]*MarketOrder{}
+	s.Caravans = map[string]*Caravan{}
+	s.Buildings = map[string]*Building{}
+	s.Cases = map[string]*CourtCase{}
+	s.Clues = map[string]*TheftClue{}
+	s.Fights = map[string]*Fight{}
 	s.ActiveCrisis = nil
 	s.Events = []Event{}
 	s.Chat = []ChatMessage{}
 	s.Messages = []DiplomaticMessage{}
+	s.MarketHistory = []MarketTick{}
+	s.TreasuryLedger = []TreasuryEntry{}
 	s.NextEventID = 0
 	s.NextContractID = 0
 	s.NextChatID = 0
 	s.NextMessageID = 0
 	s.NextProjectID = 0
 	s.NextRelicID = 0
+	s.NextOrderID = 0
+	s.NextCaravanID = 0
+	s.NextBuildingID = 0
+	s.NextCaseID = 0
+	s.NextClueID = 0
+	s.NextFightID = 0
+	s.NextTreasuryID = 0
 	s.NextScryID = 0
 	s.NextInterceptID = 0
 	s.LastDailyTickDate = ""
@@ -1502,37 +1835,56 @@ func resetStoreLocked(s *Store) {
 	s.DailyHighImpactN = map[string]int{}
 	s.ToastByPlayer = map[string]string{}
 	s.LastCleanupDate = ""
+	s.rng = newWorldRNG(worldSeedFromEnv(now))
 	initializeInstitutionsLocked(s)
 	addEventLocked(s, Event{Type: "Reset", Severity: 1, Text: "The test realm is reset; old deals and names are gone.", At: now})
 }
 
 // Tick scheduler: checks every second, advances at fixed cadence only if someone is online.
 // This prevents per-player action time acceleration in the shared world.
-func startTickScheduler(store *Store) {
+// It stops when ctx is done; the returned channel closes once it has.
+func startTickScheduler(ctx context.Context, store *Store) <-chan struct{} {
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
 		ticker := time.NewTicker(1 * time.Second)
 		defer ticker.Stop()
-		for now := range ticker.C {
-			store.mu.Lock()
-			online := len(onlinePlayersLocked(store, now.UTC())) > 0
-			if online && now.UTC().Sub(store.LastTickAt) >= store.TickEvery {
-				runWorldTickLocked(store, now.UTC())
-				store.LastTickAt = now.UTC()
-				store.persistLocked()
+		for {
+			var now time.Time
+			select {
+			case <-ctx.Done():
+				return
+			case now = <-ticker.C:
+			}
+			now = now.UTC()
+			view := store.readView()
+			due := now.Sub(view.LastTickAt) >= view.TickEvery && humansOnlineLocked(view, now) > 0
+			if !due {
+				continue
 			}
-			store.mu.Unlock()
+			store.write(func() {
+				if now.Sub(store.LastTickAt) >= store.TickEvery {
+					advanceWorldLocked(store, now, false)
+				}
+			})
 		}
 	}()
+	return done
 }
 
 func runWorldTickLocked(store *Store, now time.Time) {
 	store.TickCount++
 	processInstitutionTickLocked(store, now)
+	processCourtTickLocked(store, now)
+	processCombatTickLocked(store, now)
 	processIntelTickLocked(store, now)
 	processFinanceTickLocked(store, now)
 	processProjectTickLocked(store, now)
+	processBuildingTickLocked(store, now)
 	processPlayerTickLocked(store, now)
 	processTravelTickLocked(store, now)
+	processCaravanTickLocked(store, now)
+	processMarketTickLocked(store, now)
 	w := &store.World
 	prevGrainTier := w.GrainTier
 	prevUnrestTier := w.UnrestTier
@@ -1542,23 +1894,27 @@ func runWorldTickLocked(store *Store, now time.Time) {
 	} else {
 		w.Subphase = "Morning"
 		w.DayNumber++
+		processCalendarDayLocked(store, now)
 	}
 
 	if w.RestrictedMarketsTicks > 0 {
 		w.RestrictedMarketsTicks--
 	}
 
-	w.GrainSupply -= 18 + store.rng.Intn(9)
+	if granary, ok := localGood(locationCapital, commodityGrain); ok {
+		w.GrainSupply += localGoodDrift(granary, w.GrainSupply)
+	}
+	w.GrainSupply -= (18 + rngStreamLocked(store, rngStreamMarketVolume).Intn(9)) * currentSeason(*w).ConsumptionPct / 100
 	if w.GrainSupply < 0 {
 		w.GrainSupply = 0
 	}
-	if store.rng.Float64() < 0.10 {
+	if rollPercent(rngStreamLocked(store, rngStreamMarket), 10) {
 		w.GrainSupply -= 25
 		if w.GrainSupply < 0 {
 			w.GrainSupply = 0
 		}
 	}
-	if store.rng.Float64() < 0.08 && grainTierFromSupply(w.GrainSupply) != "Stable" {
+	if rollPercent(rngStreamLocked(store, rngStreamMarket), 8) && grainTierFromSupply(w.GrainSupply) != "Stable" {
 		w.GrainSupply += 20
 	}
 
@@ -1600,7 +1956,13 @@ func runWorldTickLocked(store *Store, now time.Time) {
 						owner.Rep = clampInt(owner.Rep-3, -100, 100)
 					}
 				}
+				if c.TreasuryID != "" {
+					depositTreasuryLocked(store, now, c.TreasuryID, c.BountyReward, fmt.Sprintf("Bounty on %s lapses", c.TargetName), nil)
+				}
 				addEventLocked(store, Event{Type: "Law", Severity: 2, Text: fmt.Sprintf("A bounty on [%s] lapses without arrests.", c.TargetName), At: now})
+				if target := store.Players[c.TargetPlayerID]; target != nil && c.Warranted {
+					declareOutlawLocked(store, now, target, "the warrant's bounty went unclaimed")
+				}
 			}
 			continue
 		}
@@ -1619,11 +1981,11 @@ func runWorldTickLocked(store *Store, now time.Time) {
 			continue
 		}
 
-		chance := fulfillChanceForTier(w.GrainTier)
+		chance := clampInt(fulfillChanceForTier(w.GrainTier)+currentSeason(*w).FulfillChance, 5, 95)
 		if c.Status == "Accepted" {
 			chance = minInt(chance+15, 95)
 		}
-		if rollPercent(store.rng, chance) {
+		if rollPercent(rngStreamLocked(store, rngStreamWorld), chance) {
 			c.Status = "Fulfilled"
 			grainReward := 30
 			if c.Type == "Emergency" {
@@ -1677,34 +2039,7 @@ func runWorldTickLocked(store *Store, now time.Time) {
 	w.UnrestValue = clampInt(w.UnrestValue, 0, 100)
 	w.UnrestTier = unrestTierFromValue(w.UnrestValue)
 
-	if (w.UnrestTier == "Unstable" || w.UnrestTier == "Rioting" || w.GrainTier == "Critical") && !hasActiveContractLocked(store, "Emergency") {
-		issueContractLocked(store, "Emergency", 4)
-		addEventLocked(store, Event{Type: "Faction", Severity: 3, Text: "[City Authority] requisitions emergency shipments.", At: now})
-	}
-	if w.UnrestTier == "Rioting" && w.RestrictedMarketsTicks == 0 {
-		w.RestrictedMarketsTicks = 2
-		addEventLocked(store, Event{Type: "Faction", Severity: 4, Text: "[City Authority] imposes strict market controls.", At: now})
-	}
-	if (w.GrainTier == "Scarce" || w.GrainTier == "Critical") && !hasActiveContractLocked(store, "Smuggling") {
-		issueContractLocked(store, "Smuggling", 3)
-		addEventLocked(store, Event{Type: "Faction", Severity: 3, Text: "[Merchant League] issues smuggling orders.", At: now})
-	}
-	for _, target := range store.Players {
-		if target == nil {
-			continue
-		}
-		if !isWantedHeat(target.Heat) {
-			continue
-		}
-		if now.Sub(target.LastSeen) > inactiveWindow {
-			continue
-		}
-		if hasActiveBountyForTargetLocked(store, target.ID) {
-			continue
-		}
-		issueBountyContractLocked(store, target, bountyDeadlineTicks)
-		addEventLocked(store, Event{Type: "Law", Severity: 3, Text: fmt.Sprintf("[City Watch] posts a bounty on [%s].", target.Name), At: now})
-	}
+	processFactionTickLocked(store, now)
 
 	if w.GrainTier != prevGrainTier {
 		addEventLocked(store, Event{Type: "Grain", Severity: 2, Text: grainTierNarrative(prevGrainTier, w.GrainTier), At: now})
@@ -1715,10 +2050,12 @@ func runWorldTickLocked(store *Store, now time.Time) {
 
 	w.Situation = deriveSituation(w.GrainTier, w.UnrestTier)
 	if !addedTickNarrative(now, store.Events) {
-		if store.rng.Intn(100) < 15 {
+		if rngStreamLocked(store, rngStreamWorld).Intn(100) < 15 {
 			addEventLocked(store, Event{Type: "Atmosphere", Severity: 1, Text: "Lantern light flickers as rumors outrun the truth.", At: now})
 		}
 	}
+	processOrderBookTickLocked(store, now)
+	recordMarketHistoryLocked(store, now)
 }
 
 func addedTickNarrative(now time.Time, events []Event) bool {
@@ -1730,37 +2067,33 @@ func addedTickNarrative(now time.Time, events []Event) bool {
 }
 
 func initializeInstitutionsLocked(store *Store) {
-	store.Institutions["city_authority"] = &Institution{ID: "city_authority", Name: "City Authority"}
-	store.Institutions["merchant_league"] = &Institution{ID: "merchant_league", Name: "Merchant League"}
-	store.Institutions["temple"] = &Institution{ID: "temple", Name: "Temple"}
-
-	store.Seats["harbor_master"] = &Seat{
-		ID:              "harbor_master",
-		Name:            "Harbor Master",
-		InstitutionID:   "merchant_league",
-		HolderName:      "Captain Vey (NPC)",
-		TenureTicksLeft: seatTenureTicks,
-	}
-	store.Seats["master_of_coin"] = &Seat{
-		ID:              "master_of_coin",
-		Name:            "Master of Coin",
-		InstitutionID:   "city_authority",
-		HolderName:      "Clerk Marn (NPC)",
-		TenureTicksLeft: seatTenureTicks,
-	}
-	store.Seats["watch_commander"] = &Seat{
-		ID:              "watch_commander",
-		Name:            "Commander of the Watch",
-		InstitutionID:   "city_authority",
-		HolderName:      "Marshal Dain (NPC)",
-		TenureTicksLeft: seatTenureTicks,
-	}
-	store.Seats["high_curate"] = &Seat{
-		ID:              "high_curate",
-		Name:            "High Curate",
-		InstitutionID:   "temple",
-		HolderName:      "Sister Hal (NPC)",
-		TenureTicksLeft: seatTenureTicks,
+	for _, def := range institutionDefinitions {
+		inst := def
+		store.Institutions[inst.ID] = &inst
+	}
+	ensureSeatsLocked(store)
+}
+
+// seatDefinitions are the seats of the city's institutions.
+var seatDefinitions = []Seat{
+	{ID: "harbor_master", Name: "Harbor Master", InstitutionID: "merchant_league"},
+	{ID: "master_of_coin", Name: "Master of Coin", InstitutionID: "city_authority"},
+	{ID: "watch_commander", Name: "Commander of the Watch", InstitutionID: "city_authority"},
+	{ID: "high_curate", Name: "High Curate", InstitutionID: "temple"},
+	{ID: "magistrate", Name: "Magistrate", InstitutionID: "city_authority"},
+}
+
+// ensureSeatsLocked seats an appointee in every defined seat the store does
+// not have yet, so worlds saved before a seat was added gain it on load.
+func ensureSeatsLocked(store *Store) {
+	for _, def := range seatDefinitions {
+		if store.Seats[def.ID] != nil {
+			continue
+		}
+		seat := def
+		seat.HolderName = seatDefaultHolderName(seat.ID)
+		seat.TenureTicksLeft = seatTenureTicks
+		store.Seats[seat.ID] = &seat
 	}
 }
 
@@ -1777,7 +2110,8 @@ func processInstitutionTickLocked(store *Store, now time.Time) {
 			addEventLocked(store, Event{Type: "Doctrine", Severity: 1, Text: "Ward lanterns gutter; the veil thins.", At: now})
 		}
 	}
-	for playerID, permit := range store.Permits {
+	for _, playerID := range sortedKeys(store.Permits) {
+		permit := store.Permits[playerID]
 		if permit == nil {
 			delete(store.Permits, playerID)
 			continue
@@ -1788,7 +2122,8 @@ func processInstitutionTickLocked(store *Store, now time.Time) {
 			addEventLocked(store, Event{Type: "Policy", Severity: 1, Text: fmt.Sprintf("Permit for [%s] expires.", permit.PlayerName), At: now})
 		}
 	}
-	for playerID, warrant := range store.Warrants {
+	for _, playerID := range sortedKeys(store.Warrants) {
+		warrant := store.Warrants[playerID]
 		if warrant == nil {
 			delete(store.Warrants, playerID)
 			continue
@@ -1799,7 +2134,8 @@ func processInstitutionTickLocked(store *Store, now time.Time) {
 			addEventLocked(store, Event{Type: "Law", Severity: 1, Text: fmt.Sprintf("Warrant on [%s] expires.", warrant.PlayerName), At: now})
 		}
 	}
-	for _, seat := range store.Seats {
+	for _, id := range sortedKeys(store.Seats) {
+		seat := store.Seats[id]
 		if seat.ElectionWindowTicks > 0 {
 			seat.ElectionWindowTicks--
 			if seat.ElectionWindowTicks == 0 {
@@ -1821,49 +2157,12 @@ func processInstitutionTickLocked(store *Store, now time.Time) {
 	}
 }
 
-func resolveElectionLocked(store *Store, seat *Seat, now time.Time) {
-	var winner *Player
-	for _, p := range store.Players {
-		if winner == nil || p.Rep > winner.Rep || (p.Rep == winner.Rep && p.Name < winner.Name) {
-			winner = p
-		}
-	}
-	if winner == nil {
-		seat.HolderPlayerID = ""
-		seat.HolderName = seatDefaultHolderName(seat.ID)
-		seat.TenureTicksLeft = seatTenureTicks
-		addEventLocked(store, Event{
-			Type:     "Institution",
-			Severity: 1,
-			Text:     fmt.Sprintf("%s returns to appointment by default.", seat.Name),
-			At:       now,
-		})
-		return
-	}
-	seat.HolderPlayerID = winner.ID
-	seat.HolderName = winner.Name
-	seat.TenureTicksLeft = seatTenureTicks
-	addEventLocked(store, Event{
-		Type:     "Institution",
-		Severity: 2,
-		Text:     fmt.Sprintf("[%s] secures election as %s.", winner.Name, seat.Name),
-		At:       now,
-	})
-}
-
 func seatDefaultHolderName(seatID string) string {
-	switch seatID {
-	case "harbor_master":
-		return "Captain Vey (NPC)"
-	case "master_of_coin":
-		return "Clerk Marn (NPC)"
-	case "watch_commander":
-		return "Marshal Dain (NPC)"
-	case "high_curate":
-		return "Sister Hal (NPC)"
-	default:
-		return "Appointee (NPC)"
+	c := currentContent()
+	if name, ok := c.SeatHolders[seatID]; ok {
+		return name
 	}
+	return c.FallbackHolder
 }
 
 func playerHoldsSeatLocked(store *Store, playerID, seatID string) bool {
@@ -1897,7 +2196,8 @@ func hasActiveWarrantLocked(store *Store, playerID string) bool {
 
 func processIntelTickLocked(store *Store, now time.Time) {
 	warded := store.World.WardNetworkTicks > 0
-	for id, r := range store.Rumors {
+	for _, id := range sortedKeys(store.Rumors) {
+		r := store.Rumors[id]
 		spreadGain := maxInt(1, r.Credibility/3)
 		if warded {
 			spreadGain = maxInt(0, spreadGain-1)
@@ -1933,11 +2233,13 @@ func processIntelTickLocked(store *Store, now time.Time) {
 			delete(store.Intercepts, id)
 		}
 	}
+	expireTheftCluesLocked(store)
 }
 
 func processFinanceTickLocked(store *Store, now time.Time) {
 	defaultsThisTick := 0
-	for _, loan := range store.Loans {
+	for _, id := range sortedKeys(store.Loans) {
+		loan := store.Loans[id]
 		if loan.Status == "Active" && loan.DueTick <= store.TickCount && loan.Remaining > 0 {
 			processLoanDefaultLocked(store, loan, now)
 			defaultsThisTick++
@@ -1949,7 +2251,8 @@ func processFinanceTickLocked(store *Store, now time.Time) {
 	}
 
 	overdueThisTick := 0
-	for _, ob := range store.Obligations {
+	for _, id := range sortedKeys(store.Obligations) {
+		ob := store.Obligations[id]
 		if ob.Status != "Open" || ob.DueTick > store.TickCount {
 			continue
 		}
@@ -1982,12 +2285,13 @@ func processProjectTickLocked(store *Store, now time.Time) {
 	if len(store.Projects) == 0 {
 		return
 	}
-	for id, proj := range store.Projects {
+	for _, id := range sortedKeys(store.Projects) {
+		proj := store.Projects[id]
 		proj.TicksLeft--
 		if proj.TicksLeft > 0 {
 			continue
 		}
-		def, ok := projectDefinitionByType(proj.Type)
+		def, ok := projectDefinitionFor(proj)
 		if ok {
 			if def.GrainDelta != 0 {
 				applyGrainSupplyDeltaLocked(store, now, def.GrainDelta)
@@ -2035,6 +2339,7 @@ func startCrisisLocked(store *Store, def CrisisDefinition, now time.Time) {
 		Severity:    def.BaseSeverity,
 		TicksLeft:   def.DurationTicks,
 		TotalTicks:  def.DurationTicks,
+		Definition:  &def,
 	}
 	addEventLocked(store, Event{
 		Type:     "Crisis",
@@ -2048,21 +2353,21 @@ func maybeStartCrisisLocked(store *Store, now time.Time) {
 	if store.ActiveCrisis != nil {
 		return
 	}
-	chance := 4
+	chance := 4 + currentSeason(store.World).CrisisChance
 	if store.World.UnrestTier == "Rioting" || store.World.UnrestTier == "Unstable" {
 		chance += 8
 	}
 	if store.World.GrainTier == "Critical" || store.World.GrainTier == "Scarce" {
 		chance += 6
 	}
-	if store.rng.Intn(100) >= chance {
+	if rngStreamLocked(store, rngStreamCrisis).Intn(100) >= chance {
 		return
 	}
 	defs := crisisDefinitions()
 	if len(defs) == 0 {
 		return
 	}
-	def := defs[store.rng.Intn(len(defs))]
+	def := defs[rngStreamLocked(store, rngStreamCrisisPick).Intn(len(defs))]
 	startCrisisLocked(store, def, now)
 }
 
@@ -2108,7 +2413,7 @@ func processCrisisTickLocked(store *Store, now time.Time) {
 		return
 	}
 	crisis := store.ActiveCrisis
-	def, ok := crisisDefinitionByType(crisis.Type)
+	def, ok := crisisDefinitionFor(crisis)
 	if !ok {
 		store.ActiveCrisis = nil
 		return
@@ -2130,7 +2435,8 @@ func processCrisisTickLocked(store *Store, now time.Time) {
 }
 
 func processPlayerTickLocked(store *Store, now time.Time) {
-	for _, p := range store.Players {
+	for _, id := range sortedKeys(store.Players) {
+		p := store.Players[id]
 		if p.RiteImmunityTicks > 0 {
 			p.RiteImmunityTicks--
 		}
@@ -2140,11 +2446,13 @@ func processPlayerTickLocked(store *Store, now time.Time) {
 				addEventLocked(store, Event{Type: "Institution", Severity: 1, Text: fmt.Sprintf("Officials stop extending favors to [%s].", p.Name), At: now})
 			}
 		}
+		processStatusTickLocked(store, p, now)
 	}
 }
 
 func processTravelTickLocked(store *Store, now time.Time) {
-	for _, p := range store.Players {
+	for _, id := range sortedKeys(store.Players) {
+		p := store.Players[id]
 		if p.TravelTicksLeft <= 0 {
 			continue
 		}
@@ -2180,12 +2488,7 @@ func fieldworkCooldownRemaining(store *Store, playerID string) int {
 }
 
 func locationDefinitions() []LocationDef {
-	return []LocationDef{
-		{ID: locationCapital, Name: "Black Granary (Capital)", Description: "The granary citadel and its surrounding markets."},
-		{ID: locationHarbor, Name: "Harbor Ward", Description: "Salt air, cargo manifests, and merchant seals."},
-		{ID: locationFrontier, Name: "Frontier Village", Description: "Wind-scoured outpost clinging to the trade road."},
-		{ID: locationRuins, Name: "Haunted Ruins", Description: "A broken keep where relics and rumors linger."},
-	}
+	return currentContent().Locations
 }
 
 func locationByID(id string) (LocationDef, bool) {
@@ -2242,34 +2545,15 @@ func travelTicksBetween(from, to string) int {
 	if from == "" || to == "" || from == to {
 		return 0
 	}
-	travel := map[string]int{
-		locationCapital + ":" + locationHarbor:   1,
-		locationHarbor + ":" + locationCapital:   1,
-		locationCapital + ":" + locationFrontier: 2,
-		locationFrontier + ":" + locationCapital: 2,
-		locationHarbor + ":" + locationFrontier:  2,
-		locationFrontier + ":" + locationHarbor:  2,
-		locationFrontier + ":" + locationRuins:   2,
-		locationRuins + ":" + locationFrontier:   2,
-		locationCapital + ":" + locationRuins:    3,
-		locationRuins + ":" + locationCapital:    3,
-		locationHarbor + ":" + locationRuins:     3,
-		locationRuins + ":" + locationHarbor:     3,
-	}
-	if ticks, ok := travel[from+":"+to]; ok {
+	c := currentContent()
+	if ticks, ok := c.travel[from+":"+to]; ok {
 		return ticks
 	}
-	return 2
+	return c.DefaultRouteTicks
 }
 
 func relicDefinitions() []RelicDefinition {
-	return []RelicDefinition{
-		{Name: "Warding Charm", Effect: "heat", Power: 2},
-		{Name: "Oathstone Shard", Effect: "rep", Power: 2},
-		{Name: "Cinder Coin", Effect: "gold", Power: 6},
-		{Name: "Whispered Sigil", Effect: "rumor", Power: 2},
-		{Name: "Harvest Token", Effect: "grain", Power: 2},
-	}
+	return currentContent().Relics
 }
 
 func randomRelicDefinition(rng *mathrand.Rand) RelicDefinition {
@@ -2317,6 +2601,7 @@ func addRelicLocked(store *Store, owner *Player, def RelicDefinition, now time.T
 		FoundAtTick:     store.TickCount,
 		AppraisedAtTick: 0,
 	}
+	store.dirty.mark(dirtyRelics, id)
 	store.Relics[id] = relic
 	addEventLocked(store, Event{
 		Type:     "Fieldwork",
@@ -2330,6 +2615,7 @@ func addRelicLocked(store *Store, owner *Player, def RelicDefinition, now time.T
 func addRumorLocked(store *Store, r *Rumor, now time.Time) {
 	store.NextRumorID++
 	r.ID = store.NextRumorID
+	store.dirty.mark(dirtyRumors, r.ID)
 	store.Rumors[r.ID] = r
 	addEventLocked(store, Event{
 		Type:     "Intel",
@@ -2345,6 +2631,7 @@ func addEvidenceLocked(store *Store, source *Player, target *Player, topic strin
 	}
 	store.NextEvidenceID++
 	id := store.NextEvidenceID
+	store.dirty.mark(dirtyEvidence, id)
 	store.Evidence[id] = &Evidence{
 		ID:             id,
 		Topic:          topic,
@@ -2364,6 +2651,7 @@ func addScryReportLocked(store *Store, owner *Player, target *Player) {
 	}
 	store.NextScryID++
 	id := store.NextScryID
+	store.dirty.mark(dirtyScryReports, id)
 	store.ScryReports[id] = &ScryReport{
 		ID:              id,
 		OwnerPlayerID:   owner.ID,
@@ -2386,6 +2674,7 @@ func addInterceptLocked(store *Store, owner *Player, msg *DiplomaticMessage) {
 	}
 	store.NextInterceptID++
 	id := store.NextInterceptID
+	store.dirty.mark(dirtyIntercepts, id)
 	store.Intercepts[id] = &InterceptedMessage{
 		ID:            id,
 		OwnerPlayerID: owner.ID,
@@ -2432,6 +2721,7 @@ func addObligationLocked(store *Store, creditor, debtor *Player, reason string,
 	}
 	store.NextObligationID++
 	id := fmt.Sprintf("o-%d", store.NextObligationID)
+	store.dirty.mark(dirtyObligations, id)
 	store.Obligations[id] = &Obligation{
 		ID:               id,
 		CreditorPlayerID: creditor.ID,
@@ -2450,33 +2740,39 @@ func obligationCost(severity int) int {
 	return clampInt(severity*3, 3, 20)
 }
 
+// applyBountyResolutionLocked arrests a bounty's target: the hunter's
+// evidence goes before the court in the case against them, opening one if
+// no warrant already has.
 func applyBountyResolutionLocked(store *Store, hunter, target *Player, ev *Evidence, now time.Time) {
 	if store == nil || hunter == nil || target == nil {
 		return
 	}
-	strength := 0
-	if ev != nil {
-		strength = ev.Strength
-	}
-	heatDrop := clampInt(2+strength/2, 2, 6)
-	repDrop := clampInt(2+strength/3, 2, 6)
-	target.Heat = maxInt(0, target.Heat-heatDrop)
-	target.Rep = clampInt(target.Rep-repDrop, -100, 100)
 	addEventLocked(store, Event{
 		Type:     "Law",
 		Severity: 3,
 		Text:     fmt.Sprintf("[%s] delivers evidence; the Watch moves on [%s].", hunter.Name, target.Name),
 		At:       now,
 	})
+	charge := "corruption"
+	if ev != nil {
+		charge = ev.Topic
+	}
+	cs := openCaseLocked(store, now, target, hunter.ID, hunter.Name, charge)
+	if ev != nil {
+		enterExhibitLocked(store, cs, hunter, caseSideProsecution, ev)
+	}
 }
 
 func triggerInstitutionSanctionLocked(store *Store, target *Player, now time.Time) {
 	if target == nil {
 		return
 	}
+	store.dirty.mark(dirtyPlayers, target.ID)
 	target.Heat = clampInt(target.Heat+3, 0, 20)
+	collectFineLocked(store, now, target, sanctionFineGold, "Sanction fine")
 	store.Policies.PermitRequiredHighRisk = true
 	if seat := store.Seats["harbor_master"]; seat != nil && seat.HolderPlayerID == target.ID {
+		store.dirty.mark(dirtySeats, seat.ID)
 		seat.HolderPlayerID = ""
 		seat.HolderName = seatDefaultHolderName(seat.ID)
 		seat.TenureTicksLeft = seatTenureTicks
@@ -2493,15 +2789,18 @@ func processLoanDefaultLocked(store *Store, loan *Loan, now time.Time) {
 	if loan == nil || loan.Status != "Active" {
 		return
 	}
+	store.dirty.mark(dirtyLoans, loan.ID)
 	loan.Status = "Defaulted"
 	loan.TerminalAt = now
 	borrower := store.Players[loan.BorrowerPlayerID]
 	lender := store.Players[loan.LenderPlayerID]
 	if borrower != nil {
+		store.dirty.mark(dirtyPlayers, borrower.ID)
 		borrower.Rep = clampInt(borrower.Rep-6, -100, 100)
 		borrower.Heat = clampInt(borrower.Heat+2, 0, 20)
 	}
 	if lender != nil {
+		store.dirty.mark(dirtyPlayers, lender.ID)
 		lender.Rep = clampInt(lender.Rep-1, -100, 100)
 	}
 	store.Policies.SmugglingEmbargoTicks = maxInt(store.Policies.SmugglingEmbargoTicks, 2)
@@ -2516,12 +2815,15 @@ func processLoanDefaultLocked(store *Store, loan *Loan, now time.Time) {
 func consumeHighImpactBudgetLocked(store *Store, playerID string, now time.Time) bool {
 	today := now.UTC().Format("2006-01-02")
 	if store.DailyActionDate[playerID] != today {
+		store.dirty.mark(dirtyDailyActionDate, playerID)
+		store.dirty.mark(dirtyDailyHighImpactN, playerID)
 		store.DailyActionDate[playerID] = today
 		store.DailyHighImpactN[playerID] = 0
 	}
 	if store.DailyHighImpactN[playerID] >= highImpactDailyCap {
 		return false
 	}
+	store.dirty.mark(dirtyDailyHighImpactN, playerID)
 	store.DailyHighImpactN[playerID]++
 	return true
 }
@@ -2552,9 +2854,52 @@ type ActionInput struct {
 	ObligationID string
 	ProjectType  string
 	LocationID   string
+	Commodity    string
+	OrderID      string
+	CaravanID    string
+	BuildingType string
+	BuildingID   string
 	Amount       int
+	Price        int
 	Sacks        int
 	Reward       int
+	Guards       int
+}
+
+// submitActionLocked is the entry point for a player-submitted action from
+// any transport: it enforces the action cooldown, journals the input, applies
+// it. Publishing the batch tells open pages what it changed.
+func submitActionLocked(store *Store, p *Player, now time.Time, input ActionInput) {
+	if tooSoon(store.LastActionAt[p.ID], now, actionCooldown) {
+		rejectLocked(store, p.ID, errCodeCooldown, "Slow down.")
+		return
+	}
+	store.dirty.mark(dirtyLastActionAt, p.ID)
+	store.LastActionAt[p.ID] = now
+	recordJournalLocked(store, JournalEntry{Kind: journalKindAction, PlayerID: p.ID, At: now, Action: &input})
+	handleActionInputLocked(store, p, now, input)
+}
+
+// submitChatLocked is the chat counterpart of submitActionLocked. An empty
+// message is ignored without a toast.
+func submitChatLocked(store *Store, p *Player, now time.Time, msg string) bool {
+	if tooSoon(store.LastChatAt[p.ID], now, chatCooldown) {
+		rejectLocked(store, p.ID, errCodeCooldown, "Chat cooldown active.")
+		return false
+	}
+	if msg == "" {
+		return false
+	}
+	store.dirty.mark(dirtyLastChatAt, p.ID)
+	store.LastChatAt[p.ID] = now
+	recordJournalLocked(store, JournalEntry{Kind: journalKindChat, PlayerID: p.ID, At: now, Text: msg})
+	return handleChatLocked(store, p, now, msg)
+}
+
+// submitMissiveLocked journals and dispatches a missive.
+func submitMissiveLocked(store *Store, p *Player, now time.Time, in MissiveInput) bool {
+	recordJournalLocked(store, JournalEntry{Kind: journalKindMissive, PlayerID: p.ID, At: now, Missive: &in})
+	return handleMissiveLocked(store, p, now, in)
 }
 
 func handleActionLocked(store *Store, p *Player, now time.Time, action, contractID string, stanceInput ...string) {
@@ -2568,22 +2913,39 @@ func handleActionLocked(store *Store, p *Player, now time.Time, action, contract
 	handleActionInputLocked(store, p, now, in)
 }
 
+// handleActionInputLocked applies one action. Nearly every action changes
+// its actor, who is marked dirty up front; whatever else it changes is marked
+// where it happens.
 func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionInput) {
+	runMarkedLocked(store, func() {
+		store.dirty.mark(dirtyPlayers, p.ID)
+		applyActionInputLocked(store, p, now, in)
+	})
+}
+
+func applyActionInputLocked(store *Store, p *Player, now time.Time, in ActionInput) {
 	action := in.Action
 	contractID := in.ContractID
 	stance := in.Stance
 	c := store.Contracts[contractID]
+	// The contract actions all change the contract they name.
+	if c != nil {
+		store.dirty.mark(dirtyContracts, c.ID)
+	}
 
 	// Actions mutate local/player/contract state but never advance world time.
 	// Time progression is owned by fixed scheduler ticks for fair multi-player simulation.
-	if p.TravelTicksLeft > 0 && action != "travel" {
-		setToastLocked(store, p.ID, fmt.Sprintf("You are en route to %s.", locationName(p.TravelToID)))
+	if p.TravelTicksLeft > 0 && action != "travel" && action != "combat_tactic" {
+		rejectLocked(store, p.ID, errCodeTravelLockout, fmt.Sprintf("You are en route to %s.", locationName(p.TravelToID)))
+		return
+	}
+	if statusLockoutLocked(store, p, in) {
 		return
 	}
 	switch action {
 	case "accept":
 		if c == nil {
-			setToastLocked(store, p.ID, "That contract is unavailable.")
+			rejectLocked(store, p.ID, errCodeNotFound, "That contract is unavailable.")
 			return
 		}
 		if c.Status == "Accepted" {
@@ -2591,36 +2953,36 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 			if owner == "" {
 				owner = "another contractor"
 			}
-			setToastLocked(store, p.ID, fmt.Sprintf("Taken by %s.", owner))
+			rejectLocked(store, p.ID, errCodeNotAllowed, fmt.Sprintf("Taken by %s.", owner))
 			return
 		}
 		if c.Status != "Issued" {
-			setToastLocked(store, p.ID, "That contract is unavailable.")
+			rejectLocked(store, p.ID, errCodeNotFound, "That contract is unavailable.")
 			return
 		}
 		if c.IssuerPlayerID != "" && c.IssuerPlayerID == p.ID {
-			setToastLocked(store, p.ID, "You cannot accept your own contract.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "You cannot accept your own contract.")
 			return
 		}
 		hasBribedAccess := p.BribeAccessTicks > 0
 		if c.Type == "Smuggling" && p.Rep < -50 {
-			setToastLocked(store, p.ID, "Your reputation blocks smuggling contracts.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "Your reputation blocks smuggling contracts.")
 			return
 		}
 		if c.Type == "Smuggling" && store.Policies.SmugglingEmbargoTicks > 0 && !playerHoldsSeatLocked(store, p.ID, "harbor_master") && !hasBribedAccess {
-			setToastLocked(store, p.ID, "Smuggling is under embargo.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "Smuggling is under embargo.")
 			return
 		}
 		if c.Type == "Emergency" && store.Policies.PermitRequiredHighRisk && p.Rep < 20 && !playerHoldsSeatLocked(store, p.ID, "harbor_master") && !hasActivePermitLocked(store, p.ID) && !hasBribedAccess {
-			setToastLocked(store, p.ID, "Permit required for emergency contracts.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "Permit required for emergency contracts.")
 			return
 		}
 		if c.Type == "Bounty" && c.TargetPlayerID == p.ID {
-			setToastLocked(store, p.ID, "You are the target of that bounty.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "You are the target of that bounty.")
 			return
 		}
 		if playerAcceptedCountLocked(store, p.ID) >= 1 {
-			setToastLocked(store, p.ID, "You can hold only one active contract.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "You can hold only one active contract.")
 			return
 		}
 		c.Status = "Accepted"
@@ -2635,7 +2997,7 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 		setToastLocked(store, p.ID, "Contract accepted.")
 	case "ignore":
 		if c == nil || c.Status != "Issued" {
-			setToastLocked(store, p.ID, "Nothing to ignore here.")
+			rejectLocked(store, p.ID, errCodeNotFound, "Nothing to ignore here.")
 			return
 		}
 		c.Status = "Ignored"
@@ -2643,7 +3005,7 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 		setToastLocked(store, p.ID, "Ignored.")
 	case "abandon":
 		if c == nil || c.Status != "Accepted" || c.OwnerPlayerID != p.ID {
-			setToastLocked(store, p.ID, "You can only abandon your own accepted contract.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "You can only abandon your own accepted contract.")
 			return
 		}
 		p.Rep = clampInt(p.Rep-2, -100, 100)
@@ -2656,22 +3018,23 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 		setToastLocked(store, p.ID, "Contract abandoned.")
 	case "deliver":
 		if c == nil || c.OwnerPlayerID != p.ID || (c.Status != "Accepted" && c.Status != "Fulfilled") {
-			setToastLocked(store, p.ID, "You can only deliver your accepted or fulfilled contract.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "You can only deliver your accepted or fulfilled contract.")
 			return
 		}
 		if c.Type == "Supply" {
 			if c.SupplySacks <= 0 {
-				setToastLocked(store, p.ID, "Supply contract has no defined quantity.")
+				rejectLocked(store, p.ID, errCodeNotAllowed, "Supply contract has no defined quantity.")
 				return
 			}
 			if p.Grain < c.SupplySacks {
-				setToastLocked(store, p.ID, fmt.Sprintf("Need %d sacks to fulfill this contract.", c.SupplySacks))
+				rejectLocked(store, p.ID, errCodeInsufficientGrain, fmt.Sprintf("Need %d sacks to fulfill this contract.", c.SupplySacks))
 				return
 			}
 			p.Grain -= c.SupplySacks
-			applyGrainSupplyDeltaLocked(store, now, c.SupplySacks*grainUnitPerSack)
+			applyGrainSupplyDeltaLocked(store, now, c.SupplySacks*grainCommodity().PoolPerUnit)
 			finalizeDeliveredContractLocked(store, p, c, now)
 			if issuer := store.Players[c.IssuerPlayerID]; issuer != nil && issuer.ID != p.ID {
+				store.dirty.mark(dirtyPlayers, issuer.ID)
 				issuer.Rep = clampInt(issuer.Rep+1, -100, 100)
 			}
 			store.World.UnrestValue = clampInt(store.World.UnrestValue-4, 0, 100)
@@ -2683,7 +3046,7 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 		if c.Type == "Bounty" {
 			target := store.Players[c.TargetPlayerID]
 			if target == nil {
-				setToastLocked(store, p.ID, "Target no longer available.")
+				rejectLocked(store, p.ID, errCodeNotFound, "Target no longer available.")
 				return
 			}
 			ev := strongestEvidenceForLocked(store, p.ID, target.ID)
@@ -2692,10 +3055,9 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 				required = bountyEvidenceMin
 			}
 			if ev == nil || ev.Strength < required {
-				setToastLocked(store, p.ID, fmt.Sprintf("Need evidence strength %d+ on target.", required))
+				rejectLocked(store, p.ID, errCodeNotAllowed, fmt.Sprintf("Need evidence strength %d+ on target.", required))
 				return
 			}
-			delete(store.Evidence, ev.ID)
 			applyBountyResolutionLocked(store, p, target, ev, now)
 			finalizeDeliveredContractLocked(store, p, c, now)
 			setToastLocked(store, p.ID, "Bounty delivered.")
@@ -2708,18 +3070,19 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 		}
 		if c.Status == "Accepted" {
 			if p.Gold < 2 {
-				setToastLocked(store, p.ID, "You need 2g to attempt a delivery.")
+				rejectLocked(store, p.ID, errCodeInsufficientGold, "You need 2g to attempt a delivery.")
 				return
 			}
 			if tooSoon(store.LastDeliverAt[p.ID], now, deliverCooldown) {
-				setToastLocked(store, p.ID, "Delivery cooldown active.")
+				rejectLocked(store, p.ID, errCodeCooldown, "Delivery cooldown active.")
 				return
 			}
+			store.dirty.mark(dirtyLastDeliverAt, p.ID)
 			store.LastDeliverAt[p.ID] = now
 			p.Gold = maxInt(0, p.Gold-2)
 
-			chance := deliverChanceByTier(store.World.GrainTier)
-			if rollPercent(store.rng, chance) {
+			chance := clampInt(deliverChanceByTier(store.World.GrainTier)+currentSeason(store.World).FulfillChance, 5, 95)
+			if rollPercent(rngStreamLocked(store, rngStreamWorld), chance) {
 				finalizeDeliveredContractLocked(store, p, c, now)
 				setToastLocked(store, p.ID, "Delivery succeeded.")
 			} else {
@@ -2732,21 +3095,21 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 		}
 	case "post_supply":
 		if hasActiveSupplyFromIssuerLocked(store, p.ID) {
-			setToastLocked(store, p.ID, "You already have a supply contract active.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "You already have a supply contract active.")
 			return
 		}
 		if in.Sacks <= 0 {
-			setToastLocked(store, p.ID, "Choose a valid sack count.")
+			rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a valid sack count.")
 			return
 		}
 		if in.Reward <= 0 {
-			setToastLocked(store, p.ID, "Choose a valid reward.")
+			rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a valid reward.")
 			return
 		}
 		sacks := clampInt(in.Sacks, supplyContractMinSacks, supplyContractMaxSacks)
 		reward := clampInt(in.Reward, supplyContractMinReward, supplyContractMaxReward)
 		if p.Gold < reward {
-			setToastLocked(store, p.ID, "Insufficient gold to escrow that reward.")
+			rejectLocked(store, p.ID, errCodeInsufficientGold, "Insufficient gold to escrow that reward.")
 			return
 		}
 		p.Gold -= reward
@@ -2755,7 +3118,7 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 		setToastLocked(store, p.ID, "Supply contract posted.")
 	case "cancel_contract":
 		if c == nil || c.Status != "Issued" || c.IssuerPlayerID != p.ID {
-			setToastLocked(store, p.ID, "Only the issuer can cancel an open contract.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "Only the issuer can cancel an open contract.")
 			return
 		}
 		refund := c.RewardGold
@@ -2768,14 +3131,22 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 	case "investigate", "investigate_target":
 		lastTick, ok := store.LastInvestigateAt[p.ID]
 		if !ok || store.TickCount-lastTick >= 3 {
+			store.dirty.mark(dirtyLastInvestigateAt, p.ID)
 			store.LastInvestigateAt[p.ID] = store.TickCount
 			store.World.UnrestValue = clampInt(store.World.UnrestValue-5, 0, 100)
 			store.World.UnrestTier = unrestTierFromValue(store.World.UnrestValue)
 			p.Rep = clampInt(p.Rep+1, -100, 100)
 			p.Rumors += rumorInvestigateGain
 			addEventLocked(store, Event{Type: "Player", Severity: 2, Text: fmt.Sprintf("[%s] investigates rumors along the supply routes.", p.Name), At: now})
+			if followClueLocked(store, p, in.TargetID, now) {
+				break
+			}
 			if in.TargetID != "" {
 				if target := store.Players[in.TargetID]; target != nil && target.ID != p.ID {
+					if exposeEmbezzlementLocked(store, p, target, now) {
+						setToastLocked(store, p.ID, "Your investigation exposes embezzlement.")
+						break
+					}
 					addEvidenceLocked(store, p, target, chooseTopic(in.Topic, "corruption"), 5+maxInt(0, p.Rep/25), 5, false)
 					setToastLocked(store, p.ID, "Your investigation found evidence.")
 					break
@@ -2783,31 +3154,31 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 			}
 			setToastLocked(store, p.ID, "Your investigation calmed the streets.")
 		} else {
-			addEventLocked(store, Event{Type: "Player", Severity: 1, Text: fmt.Sprintf("[%s] investigates rumors along the supply routes.", p.Name), At: now})
-			setToastLocked(store, p.ID, "You find only fragments and gossip.")
+			rejectLocked(store, p.ID, errCodeCooldown, "Investigation cooldown active.")
 		}
 	case "forge_evidence":
 		target := store.Players[in.TargetID]
 		if target == nil || target.ID == p.ID {
-			setToastLocked(store, p.ID, "Choose a valid target to forge evidence.")
+			rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a valid target to forge evidence.")
 			return
 		}
 		if tooSoonTick(store.LastIntelActionAt[p.ID], store.TickCount, 1) {
-			setToastLocked(store, p.ID, "Forgery cooldown active.")
+			rejectLocked(store, p.ID, errCodeCooldown, "Forgery cooldown active.")
 			return
 		}
 		if !consumeHighImpactBudgetLocked(store, p.ID, now) {
-			setToastLocked(store, p.ID, "Daily cap reached for high-impact actions.")
+			rejectLocked(store, p.ID, errCodeHighImpactCap, "Daily cap reached for high-impact actions.")
 			return
 		}
 		if p.Gold < forgeEvidenceCost {
-			setToastLocked(store, p.ID, fmt.Sprintf("Need %dg to forge a dossier.", forgeEvidenceCost))
+			rejectLocked(store, p.ID, errCodeInsufficientGold, fmt.Sprintf("Need %dg to forge a dossier.", forgeEvidenceCost))
 			return
 		}
+		store.dirty.mark(dirtyLastIntelActionAt, p.ID)
 		store.LastIntelActionAt[p.ID] = store.TickCount
 		p.Gold -= forgeEvidenceCost
 		successChance := 55 + maxInt(0, p.Rep)/3
-		if rollPercent(store.rng, minInt(successChance, 90)) {
+		if rollPercent(rngStreamLocked(store, rngStreamIntel), minInt(successChance, 90)) {
 			strength := clampInt(2+maxInt(0, p.Rep)/35, 2, 5)
 			addEvidenceLocked(store, p, target, chooseTopic(in.Topic, "fraud"), strength, forgeEvidenceDurationTicks, true)
 			addEventLocked(store, Event{
@@ -2831,13 +3202,14 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 	case "seed_rumor":
 		target := store.Players[in.TargetID]
 		if target == nil || target.ID == p.ID {
-			setToastLocked(store, p.ID, "Choose a valid target for rumor seeding.")
+			rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a valid target for rumor seeding.")
 			return
 		}
 		if tooSoonTick(store.LastIntelActionAt[p.ID], store.TickCount, 1) {
-			setToastLocked(store, p.ID, "Rumor operation cooldown active.")
+			rejectLocked(store, p.ID, errCodeCooldown, "Rumor operation cooldown active.")
 			return
 		}
+		store.dirty.mark(dirtyLastIntelActionAt, p.ID)
 		store.LastIntelActionAt[p.ID] = store.TickCount
 		claim := in.Claim
 		if claim == "" {
@@ -2859,19 +3231,21 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 	case "publish_evidence":
 		target := store.Players[in.TargetID]
 		if target == nil || target.ID == p.ID {
-			setToastLocked(store, p.ID, "Choose a valid target to publish evidence.")
+			rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a valid target to publish evidence.")
 			return
 		}
 		ev := strongestEvidenceForLocked(store, p.ID, target.ID)
 		if ev == nil {
-			setToastLocked(store, p.ID, "You lack evidence on that target.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "You lack evidence on that target.")
 			return
 		}
 		if !consumeHighImpactBudgetLocked(store, p.ID, now) {
-			setToastLocked(store, p.ID, "Daily cap reached for high-impact actions.")
+			rejectLocked(store, p.ID, errCodeHighImpactCap, "Daily cap reached for high-impact actions.")
 			return
 		}
+		store.dirty.mark(dirtyEvidence, ev.ID)
 		delete(store.Evidence, ev.ID)
+		store.dirty.mark(dirtyPlayers, target.ID)
 		target.Rep = clampInt(target.Rep-ev.Strength, -100, 100)
 		target.Heat = clampInt(target.Heat+ev.Strength/2+1, 0, 20)
 		p.Rep = clampInt(p.Rep+2, -100, 100)
@@ -2888,7 +3262,7 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 	case "counter_narrative":
 		target := store.Players[in.TargetID]
 		if target == nil {
-			setToastLocked(store, p.ID, "Choose a rumor target to counter.")
+			rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a rumor target to counter.")
 			return
 		}
 		changed := false
@@ -2896,30 +3270,32 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 			if r.TargetPlayerID != target.ID {
 				continue
 			}
+			store.dirty.mark(dirtyRumors, r.ID)
 			r.Spread = maxInt(0, r.Spread-2)
 			r.Decay = maxInt(0, r.Decay-2)
 			changed = true
 		}
-		if changed {
-			p.Rep = clampInt(p.Rep+1, -100, 100)
-			setToastLocked(store, p.ID, "Counter-narrative slows rumor spread.")
-		} else {
-			setToastLocked(store, p.ID, "No major rumor wave found to counter.")
+		if !changed {
+			rejectLocked(store, p.ID, errCodeNotFound, "No major rumor wave found to counter.")
+			return
 		}
+		p.Rep = clampInt(p.Rep+1, -100, 100)
+		setToastLocked(store, p.ID, "Counter-narrative slows rumor spread.")
 	case "scry_target":
 		target := store.Players[in.TargetID]
 		if target == nil || target.ID == p.ID {
-			setToastLocked(store, p.ID, "Choose a valid target to scry.")
+			rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a valid target to scry.")
 			return
 		}
 		if tooSoonTick(store.LastIntelActionAt[p.ID], store.TickCount, 1) {
-			setToastLocked(store, p.ID, "Scrying cooldown active.")
+			rejectLocked(store, p.ID, errCodeCooldown, "Scrying cooldown active.")
 			return
 		}
 		if !consumeHighImpactBudgetLocked(store, p.ID, now) {
-			setToastLocked(store, p.ID, "Daily cap reached for high-impact actions.")
+			rejectLocked(store, p.ID, errCodeHighImpactCap, "Daily cap reached for high-impact actions.")
 			return
 		}
+		store.dirty.mark(dirtyLastIntelActionAt, p.ID)
 		store.LastIntelActionAt[p.ID] = store.TickCount
 		if target.RiteImmunityTicks > 0 {
 			p.Rep = clampInt(p.Rep-1, -100, 100)
@@ -2931,7 +3307,7 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 		if store.World.WardNetworkTicks > 0 {
 			successChance = maxInt(10, successChance-12)
 		}
-		if rollPercent(store.rng, minInt(successChance, 85)) {
+		if rollPercent(rngStreamLocked(store, rngStreamIntel), minInt(successChance, 85)) {
 			addScryReportLocked(store, p, target)
 			addEventLocked(store, Event{
 				Type:     "Intel",
@@ -2949,22 +3325,23 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 	case "intercept_courier":
 		target := store.Players[in.TargetID]
 		if target == nil || target.ID == p.ID {
-			setToastLocked(store, p.ID, "Choose a valid courier target.")
+			rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a valid courier target.")
 			return
 		}
 		msg := mostRecentDiplomaticMessageForTarget(store, target.ID)
 		if msg == nil {
-			setToastLocked(store, p.ID, "No courier traffic to intercept.")
+			rejectLocked(store, p.ID, errCodeNotFound, "No courier traffic to intercept.")
 			return
 		}
 		if tooSoonTick(store.LastIntelActionAt[p.ID], store.TickCount, 1) {
-			setToastLocked(store, p.ID, "Intercept cooldown active.")
+			rejectLocked(store, p.ID, errCodeCooldown, "Intercept cooldown active.")
 			return
 		}
 		if !consumeHighImpactBudgetLocked(store, p.ID, now) {
-			setToastLocked(store, p.ID, "Daily cap reached for high-impact actions.")
+			rejectLocked(store, p.ID, errCodeHighImpactCap, "Daily cap reached for high-impact actions.")
 			return
 		}
+		store.dirty.mark(dirtyLastIntelActionAt, p.ID)
 		store.LastIntelActionAt[p.ID] = store.TickCount
 		successChance := 45 + maxInt(0, p.Rep)/3 + target.Heat/2 - maxInt(0, target.Rep)/6
 		if store.World.WardNetworkTicks > 0 {
@@ -2973,7 +3350,7 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 		if msg.Sealed {
 			successChance = maxInt(10, successChance-sealedInterceptPenalty)
 		}
-		if rollPercent(store.rng, minInt(successChance, 85)) {
+		if rollPercent(rngStreamLocked(store, rngStreamIntel), minInt(successChance, 85)) {
 			addInterceptLocked(store, p, msg)
 			addEventLocked(store, Event{
 				Type:     "Intel",
@@ -2996,15 +3373,16 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 		target := store.Players[in.TargetID]
 		principal := clampInt(in.Amount, 1, 1000)
 		if target == nil || target.ID == p.ID {
-			setToastLocked(store, p.ID, "Choose a borrower.")
+			rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a borrower.")
 			return
 		}
 		if principal <= 0 || p.Gold < principal {
-			setToastLocked(store, p.ID, "Insufficient gold to issue loan.")
+			rejectLocked(store, p.ID, errCodeInsufficientGold, "Insufficient gold to issue loan.")
 			return
 		}
 		store.NextLoanID++
 		id := fmt.Sprintf("l-%d", store.NextLoanID)
+		store.dirty.mark(dirtyLoans, id)
 		store.Loans[id] = &Loan{
 			ID:               id,
 			LenderPlayerID:   p.ID,
@@ -3022,16 +3400,18 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 	case "loan_accept":
 		loan := store.Loans[in.LoanID]
 		if loan == nil || loan.Status != "Offered" || loan.BorrowerPlayerID != p.ID {
-			setToastLocked(store, p.ID, "No matching loan offer.")
+			rejectLocked(store, p.ID, errCodeNotFound, "No matching loan offer.")
 			return
 		}
+		store.dirty.mark(dirtyLoans, loan.ID)
 		lender := store.Players[loan.LenderPlayerID]
 		if lender == nil || lender.Gold < loan.Principal {
-			setToastLocked(store, p.ID, "Lender cannot fund this loan.")
 			loan.Status = "Cancelled"
 			loan.TerminalAt = now
+			rejectLocked(store, p.ID, errCodeNotAllowed, "Lender cannot fund this loan.")
 			return
 		}
+		store.dirty.mark(dirtyPlayers, lender.ID)
 		lender.Gold -= loan.Principal
 		p.Gold += loan.Principal
 		loan.Status = "Active"
@@ -3041,7 +3421,7 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 	case "repay":
 		loan := store.Loans[in.LoanID]
 		if loan == nil || loan.Status != "Active" || loan.BorrowerPlayerID != p.ID {
-			setToastLocked(store, p.ID, "No active loan to repay.")
+			rejectLocked(store, p.ID, errCodeNotFound, "No active loan to repay.")
 			return
 		}
 		amount := in.Amount
@@ -3049,13 +3429,15 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 			amount = loan.Remaining
 		}
 		if p.Gold < amount {
-			setToastLocked(store, p.ID, "Not enough gold to repay.")
+			rejectLocked(store, p.ID, errCodeInsufficientGold, "Not enough gold to repay.")
 			return
 		}
 		lender := store.Players[loan.LenderPlayerID]
+		store.dirty.mark(dirtyLoans, loan.ID)
 		p.Gold -= amount
 		loan.Remaining -= amount
 		if lender != nil {
+			store.dirty.mark(dirtyPlayers, lender.ID)
 			lender.Gold += amount
 			lender.Rep = clampInt(lender.Rep+1, -100, 100)
 		}
@@ -3069,7 +3451,7 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 	case "default":
 		loan := store.Loans[in.LoanID]
 		if loan == nil || loan.Status != "Active" || loan.BorrowerPlayerID != p.ID {
-			setToastLocked(store, p.ID, "No active loan to default.")
+			rejectLocked(store, p.ID, errCodeNotFound, "No active loan to default.")
 			return
 		}
 		processLoanDefaultLocked(store, loan, now)
@@ -3077,25 +3459,27 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 	case "settle_obligation":
 		ob := store.Obligations[in.ObligationID]
 		if ob == nil || (ob.Status != "Open" && ob.Status != "Overdue") {
-			setToastLocked(store, p.ID, "No outstanding obligation found.")
+			rejectLocked(store, p.ID, errCodeNotFound, "No outstanding obligation found.")
 			return
 		}
 		if ob.DebtorPlayerID != p.ID {
-			setToastLocked(store, p.ID, "Only the debtor can settle this.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "Only the debtor can settle this.")
 			return
 		}
 		cost := obligationCost(ob.Severity)
 		if p.Gold < cost {
-			setToastLocked(store, p.ID, fmt.Sprintf("Need %dg to settle.", cost))
+			rejectLocked(store, p.ID, errCodeInsufficientGold, fmt.Sprintf("Need %dg to settle.", cost))
 			return
 		}
 		p.Gold -= cost
 		if creditor := store.Players[ob.CreditorPlayerID]; creditor != nil {
+			store.dirty.mark(dirtyPlayers, creditor.ID)
 			creditor.Gold += cost
 			creditor.Rep = clampInt(creditor.Rep+1, -100, 100)
 		}
 		p.Rep = clampInt(p.Rep+2, -100, 100)
 		p.Heat = maxInt(0, p.Heat-1)
+		store.dirty.mark(dirtyObligations, ob.ID)
 		ob.Status = "Settled"
 		ob.TerminalAt = now
 		addEventLocked(store, Event{
@@ -3108,17 +3492,19 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 	case "forgive_obligation":
 		ob := store.Obligations[in.ObligationID]
 		if ob == nil || (ob.Status != "Open" && ob.Status != "Overdue") {
-			setToastLocked(store, p.ID, "No outstanding obligation found.")
+			rejectLocked(store, p.ID, errCodeNotFound, "No outstanding obligation found.")
 			return
 		}
 		if ob.CreditorPlayerID != p.ID {
-			setToastLocked(store, p.ID, "Only the creditor can forgive this.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "Only the creditor can forgive this.")
 			return
 		}
+		store.dirty.mark(dirtyObligations, ob.ID)
 		ob.Status = "Forgiven"
 		ob.TerminalAt = now
 		p.Rep = clampInt(p.Rep+2, -100, 100)
 		if debtor := store.Players[ob.DebtorPlayerID]; debtor != nil {
+			store.dirty.mark(dirtyPlayers, debtor.ID)
 			debtor.Rep = clampInt(debtor.Rep+1, -100, 100)
 		}
 		addEventLocked(store, Event{
@@ -3128,58 +3514,73 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 			At:       now,
 		})
 		setToastLocked(store, p.ID, "Obligation forgiven.")
-	case "buy_grain":
-		amount := clampInt(in.Amount, 1, marketMaxTrade)
-		if amount <= 0 {
-			setToastLocked(store, p.ID, "Choose a valid amount to buy.")
-			return
-		}
-		base := marketBasePrice(store.World.GrainTier)
-		buyPrice := marketBuyPrice(base, store.Policies.TaxRatePct, store.World.RestrictedMarketsTicks)
-		supplySacks := store.World.GrainSupply / grainUnitPerSack
-		if supplySacks <= 0 {
-			setToastLocked(store, p.ID, "Market stalls are empty.")
+	case "buy", "buy_grain":
+		def, ok := marketCommodityFor(in)
+		if !ok {
+			rejectLocked(store, p.ID, errCodeNotFound, "That commodity is not traded here.")
 			return
 		}
-		if amount > supplySacks {
-			setToastLocked(store, p.ID, fmt.Sprintf("Market can only supply %d sacks.", supplySacks))
+		amount := clampInt(in.Amount, 1, marketMaxTrade)
+		if amount <= 0 {
+			rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a valid amount to buy.")
 			return
 		}
-		totalCost := amount * buyPrice
-		if p.Gold < totalCost {
-			setToastLocked(store, p.ID, fmt.Sprintf("Need %dg to buy %d sacks.", totalCost, amount))
+		buyCommodityLocked(store, p, now, def, amount)
+	case "sell", "sell_grain":
+		def, ok := marketCommodityFor(in)
+		if !ok {
+			rejectLocked(store, p.ID, errCodeNotFound, "That commodity is not traded here.")
 			return
 		}
-		p.Gold -= totalCost
-		p.Grain += amount
-		applyGrainSupplyDeltaLocked(store, now, -amount*grainUnitPerSack)
-		addEventLocked(store, Event{Type: "Market", Severity: 1, Text: fmt.Sprintf("[%s] buys %d sacks from the market.", p.Name, amount), At: now})
-		setToastLocked(store, p.ID, fmt.Sprintf("Bought %d sacks for %dg.", amount, totalCost))
-	case "sell_grain":
 		amount := clampInt(in.Amount, 1, marketMaxTrade)
 		if amount <= 0 {
-			setToastLocked(store, p.ID, "Choose a valid amount to sell.")
+			rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a valid amount to sell.")
+			return
+		}
+		sellCommodityLocked(store, p, now, def, amount)
+	case "bid", "ask":
+		def, ok := marketCommodityFor(in)
+		if !ok {
+			rejectLocked(store, p.ID, errCodeNotFound, "That commodity is not traded here.")
 			return
 		}
-		if p.Grain < amount {
-			setToastLocked(store, p.ID, fmt.Sprintf("You only hold %d sacks.", p.Grain))
+		placeOrderLocked(store, p, now, def, action, in.Price, in.Amount)
+	case "cancel_order":
+		cancelOrderLocked(store, p, in.OrderID)
+	case "send_caravan":
+		sendCaravanLocked(store, p, now, in)
+	case "escort_caravan", "raid_caravan":
+		c := store.Caravans[strings.TrimSpace(in.CaravanID)]
+		if c == nil {
+			rejectLocked(store, p.ID, errCodeNotFound, "That caravan is no longer on the road.")
 			return
 		}
-		base := marketBasePrice(store.World.GrainTier)
-		sellPrice := marketSellPrice(base, store.Policies.TaxRatePct, store.World.RestrictedMarketsTicks)
-		totalGain := amount * sellPrice
-		p.Grain -= amount
-		p.Gold += totalGain
-		applyGrainSupplyDeltaLocked(store, now, amount*grainUnitPerSack)
-		addEventLocked(store, Event{Type: "Market", Severity: 1, Text: fmt.Sprintf("[%s] sells %d sacks into the market.", p.Name, amount), At: now})
-		setToastLocked(store, p.ID, fmt.Sprintf("Sold %d sacks for %dg.", amount, totalGain))
+		if action == "escort_caravan" {
+			escortCaravanLocked(store, p, now, c)
+		} else {
+			raidCaravanLocked(store, p, now, c)
+		}
+	case "build":
+		buildBuildingLocked(store, p, now, in.BuildingType)
+	case "sell_building":
+		sellBuildingLocked(store, p, now, in.BuildingID, in.Price)
+	case "delist_building":
+		delistBuildingLocked(store, p, in.BuildingID)
+	case "buy_building":
+		buyBuildingLocked(store, p, now, in.BuildingID)
+	case "repair_building":
+		repairBuildingLocked(store, p, now, in.BuildingID)
+	case "seize_building":
+		seizeBuildingLocked(store, p, now, in.BuildingID)
+	case "treasury_relief", "treasury_patrol", "treasury_bounty", "treasury_project", "embezzle":
+		handleTreasuryActionLocked(store, p, now, in)
 	case "donate_relief":
 		if p.Grain < reliefSackCost {
-			setToastLocked(store, p.ID, fmt.Sprintf("Need %d sacks to fund relief.", reliefSackCost))
+			rejectLocked(store, p.ID, errCodeInsufficientGrain, fmt.Sprintf("Need %d sacks to fund relief.", reliefSackCost))
 			return
 		}
 		p.Grain -= reliefSackCost
-		applyGrainSupplyDeltaLocked(store, now, reliefSackCost*grainUnitPerSack)
+		applyGrainSupplyDeltaLocked(store, now, reliefSackCost*grainCommodity().PoolPerUnit)
 		prevUnrest := store.World.UnrestTier
 		store.World.UnrestValue = clampInt(store.World.UnrestValue-6, 0, 100)
 		store.World.UnrestTier = unrestTierFromValue(store.World.UnrestValue)
@@ -3193,7 +3594,7 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 		targetSeat := store.Seats["harbor_master"]
 		cost := maxInt(2, in.Amount)
 		if p.Gold < cost {
-			setToastLocked(store, p.ID, "You cannot afford the bribe.")
+			rejectLocked(store, p.ID, errCodeInsufficientGold, "You cannot afford the bribe.")
 			return
 		}
 		p.Gold -= cost
@@ -3208,6 +3609,24 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 		}
 		addEventLocked(store, Event{Type: "Institution", Severity: 3, Text: fmt.Sprintf("[%s] bribes officials for temporary access.", p.Name), At: now})
 		setToastLocked(store, p.ID, fmt.Sprintf("Bribe executed: access secured for %d ticks.", p.BribeAccessTicks))
+	case "challenge_duel":
+		challengeDuelLocked(store, p, now, in)
+	case "ambush":
+		ambushLocked(store, p, now, in)
+	case "accept_duel":
+		acceptDuelLocked(store, p, now, in)
+	case "decline_duel":
+		declineDuelLocked(store, p)
+	case "combat_tactic":
+		setTacticLocked(store, p, in)
+	case "temple_heal":
+		templeHealLocked(store, p, now)
+	case theftPickpocket, theftBurglary, theftRelic:
+		attemptTheftLocked(store, p, now, action, in)
+	case "bribe_guard":
+		bribeGuardLocked(store, p, now)
+	case "plan_escape":
+		planEscapeLocked(store, p, now)
 	case "petition_institution":
 		if p.Rep >= 10 {
 			p.Heat = maxInt(0, p.Heat-1)
@@ -3221,19 +3640,20 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 	case "threaten_exposure":
 		target := store.Players[in.TargetID]
 		if target == nil || target.ID == p.ID {
-			setToastLocked(store, p.ID, "Choose a valid target.")
+			rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a valid target.")
 			return
 		}
 		ev := strongestEvidenceForLocked(store, p.ID, target.ID)
 		if ev == nil {
-			setToastLocked(store, p.ID, "You need evidence before threatening exposure.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "You need evidence before threatening exposure.")
 			return
 		}
 		if !consumeHighImpactBudgetLocked(store, p.ID, now) {
-			setToastLocked(store, p.ID, "Daily cap reached for high-impact actions.")
+			rejectLocked(store, p.ID, errCodeHighImpactCap, "Daily cap reached for high-impact actions.")
 			return
 		}
 		payout := minInt(6, maxInt(2, target.Gold/3))
+		store.dirty.mark(dirtyPlayers, target.ID)
 		if payout > 0 {
 			target.Gold -= payout
 			p.Gold += payout
@@ -3245,46 +3665,47 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 		}
 	case "broker_deal":
 		if p.Gold < 2 {
-			setToastLocked(store, p.ID, "Need 2g to broker a deal.")
+			rejectLocked(store, p.ID, errCodeInsufficientGold, "Need 2g to broker a deal.")
 			return
 		}
-		p.Gold -= 2
-		boosted := false
-		for _, c := range store.Contracts {
-			if c.Status == "Issued" {
-				c.DeadlineTicks++
-				boosted = true
+		var issued *Contract
+		for _, id := range sortedKeys(store.Contracts) {
+			if store.Contracts[id].Status == "Issued" {
+				issued = store.Contracts[id]
 				break
 			}
 		}
-		if boosted {
-			p.Rep = clampInt(p.Rep+1, -100, 100)
-			addEventLocked(store, Event{Type: "Player", Severity: 2, Text: fmt.Sprintf("[%s] brokers a multi-party deal to stabilize routes.", p.Name), At: now})
-			setToastLocked(store, p.ID, "Deal brokered; contract pressure eased.")
-		} else {
-			setToastLocked(store, p.ID, "No contract available to broker.")
+		if issued == nil {
+			rejectLocked(store, p.ID, errCodeNotFound, "No contract available to broker.")
+			return
 		}
+		p.Gold -= 2
+		store.dirty.mark(dirtyContracts, issued.ID)
+		issued.DeadlineTicks++
+		p.Rep = clampInt(p.Rep+1, -100, 100)
+		addEventLocked(store, Event{Type: "Player", Severity: 2, Text: fmt.Sprintf("[%s] brokers a multi-party deal to stabilize routes.", p.Name), At: now})
+		setToastLocked(store, p.ID, "Deal brokered; contract pressure eased.")
 	case "travel":
 		if p.TravelTicksLeft > 0 {
-			setToastLocked(store, p.ID, "You are already on the road.")
+			rejectLocked(store, p.ID, errCodeTravelLockout, "You are already on the road.")
 			return
 		}
 		targetID := strings.TrimSpace(in.LocationID)
 		if targetID == "" {
-			setToastLocked(store, p.ID, "Choose a destination.")
+			rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a destination.")
 			return
 		}
 		if targetID == p.LocationID {
-			setToastLocked(store, p.ID, "You are already there.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "You are already there.")
 			return
 		}
 		if _, ok := locationByID(targetID); !ok {
-			setToastLocked(store, p.ID, "Unknown destination.")
+			rejectLocked(store, p.ID, errCodeNotFound, "Unknown destination.")
 			return
 		}
-		ticks := travelTicksBetween(p.LocationID, targetID)
+		ticks := travelTicksLocked(store, p.LocationID, targetID)
 		if ticks <= 0 {
-			setToastLocked(store, p.ID, "No travel needed.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "No travel needed.")
 			return
 		}
 		p.TravelToID = targetID
@@ -3299,20 +3720,21 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 		setToastLocked(store, p.ID, fmt.Sprintf("You depart for %s (%dt).", locationName(targetID), ticks))
 	case "scavenge_frontier":
 		if p.LocationID != locationFrontier {
-			setToastLocked(store, p.ID, "Travel to the Frontier Village to scavenge.")
+			rejectLocked(store, p.ID, errCodeTravelLockout, "Travel to the Frontier Village to scavenge.")
 			return
 		}
 		if remaining := fieldworkCooldownRemaining(store, p.ID); remaining > 0 {
-			setToastLocked(store, p.ID, fmt.Sprintf("Fieldwork cooldown: %dt.", remaining))
+			rejectLocked(store, p.ID, errCodeCooldown, fmt.Sprintf("Fieldwork cooldown: %dt.", remaining))
 			return
 		}
 		if p.Gold < fieldworkSupplyCost {
-			setToastLocked(store, p.ID, fmt.Sprintf("Need %dg for supplies.", fieldworkSupplyCost))
+			rejectLocked(store, p.ID, errCodeInsufficientGold, fmt.Sprintf("Need %dg for supplies.", fieldworkSupplyCost))
 			return
 		}
 		p.Gold -= fieldworkSupplyCost
+		store.dirty.mark(dirtyLastFieldworkAt, p.ID)
 		store.LastFieldworkAt[p.ID] = store.TickCount
-		roll := store.rng.Intn(100)
+		roll := rngStreamLocked(store, rngStreamFieldwork).Intn(100)
 		switch {
 		case roll < 60:
 			p.Grain += 2
@@ -3330,24 +3752,25 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 		}
 	case "explore_ruins":
 		if p.LocationID != locationRuins {
-			setToastLocked(store, p.ID, "Travel to the Haunted Ruins to explore.")
+			rejectLocked(store, p.ID, errCodeTravelLockout, "Travel to the Haunted Ruins to explore.")
 			return
 		}
 		if remaining := fieldworkCooldownRemaining(store, p.ID); remaining > 0 {
-			setToastLocked(store, p.ID, fmt.Sprintf("Fieldwork cooldown: %dt.", remaining))
+			rejectLocked(store, p.ID, errCodeCooldown, fmt.Sprintf("Fieldwork cooldown: %dt.", remaining))
 			return
 		}
 		if p.Gold < fieldworkSupplyCost {
-			setToastLocked(store, p.ID, fmt.Sprintf("Need %dg for supplies.", fieldworkSupplyCost))
+			rejectLocked(store, p.ID, errCodeInsufficientGold, fmt.Sprintf("Need %dg for supplies.", fieldworkSupplyCost))
 			return
 		}
 		p.Gold -= fieldworkSupplyCost
+		store.dirty.mark(dirtyLastFieldworkAt, p.ID)
 		store.LastFieldworkAt[p.ID] = store.TickCount
-		roll := store.rng.Intn(100)
+		roll := rngStreamLocked(store, rngStreamFieldwork).Intn(100)
 		switch {
 		case roll < 45:
 			p.Rep = clampInt(p.Rep+1, -100, 100)
-			relic := addRelicLocked(store, p, randomRelicDefinition(store.rng), now)
+			relic := addRelicLocked(store, p, randomRelicDefinition(rngStreamLocked(store, rngStreamFieldworkLoot)), now)
 			name := "a sealed relic"
 			if relic != nil {
 				name = relic.Name
@@ -3357,40 +3780,43 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 			p.Rumors += 1
 			addEventLocked(store, Event{Type: "Fieldwork", Severity: 1, Text: fmt.Sprintf("[%s] unearths whispers in the ruins.", p.Name), At: now})
 			setToastLocked(store, p.ID, "Ruins rumors spread: +1 rumor.")
-		default:
+		case roll < 90:
 			p.Heat = clampInt(p.Heat+2, 0, 20)
 			p.Rep = clampInt(p.Rep-2, -100, 100)
 			addEventLocked(store, Event{Type: "Fieldwork", Severity: 3, Text: fmt.Sprintf("[%s] flees a hostile presence in the ruins.", p.Name), At: now})
 			setToastLocked(store, p.ID, "You escape, but the city hears of it.")
+		default:
+			startGuardianFightLocked(store, p, now)
 		}
 	case "appraise_relic":
 		if in.RelicID == "" {
-			setToastLocked(store, p.ID, "Choose a relic to appraise.")
+			rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a relic to appraise.")
 			return
 		}
 		relicID, err := strconv.ParseInt(in.RelicID, 10, 64)
 		if err != nil {
-			setToastLocked(store, p.ID, "Relic entry invalid.")
+			rejectLocked(store, p.ID, errCodeInvalidInput, "Relic entry invalid.")
 			return
 		}
 		relic := store.Relics[relicID]
 		if relic == nil || relic.OwnerPlayerID != p.ID {
-			setToastLocked(store, p.ID, "Relic not found.")
+			rejectLocked(store, p.ID, errCodeNotFound, "Relic not found.")
 			return
 		}
 		if relic.Status != relicStatusUnappraised {
-			setToastLocked(store, p.ID, "Relic already appraised.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "Relic already appraised.")
 			return
 		}
 		if p.LocationID != locationCapital {
-			setToastLocked(store, p.ID, "Appraisals are only performed in the Capital.")
+			rejectLocked(store, p.ID, errCodeTravelLockout, "Appraisals are only performed in the Capital.")
 			return
 		}
 		if p.Gold < relicAppraiseCost {
-			setToastLocked(store, p.ID, fmt.Sprintf("Need %dg to appraise.", relicAppraiseCost))
+			rejectLocked(store, p.ID, errCodeInsufficientGold, fmt.Sprintf("Need %dg to appraise.", relicAppraiseCost))
 			return
 		}
 		p.Gold -= relicAppraiseCost
+		store.dirty.mark(dirtyRelics, relic.ID)
 		relic.Status = relicStatusAppraised
 		relic.AppraisedAtTick = store.TickCount
 		addEventLocked(store, Event{
@@ -3402,21 +3828,21 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 		setToastLocked(store, p.ID, fmt.Sprintf("Relic appraised: %s.", relicEffectLabel(relic)))
 	case "invoke_relic":
 		if in.RelicID == "" {
-			setToastLocked(store, p.ID, "Choose a relic to invoke.")
+			rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a relic to invoke.")
 			return
 		}
 		relicID, err := strconv.ParseInt(in.RelicID, 10, 64)
 		if err != nil {
-			setToastLocked(store, p.ID, "Relic entry invalid.")
+			rejectLocked(store, p.ID, errCodeInvalidInput, "Relic entry invalid.")
 			return
 		}
 		relic := store.Relics[relicID]
 		if relic == nil || relic.OwnerPlayerID != p.ID {
-			setToastLocked(store, p.ID, "Relic not found.")
+			rejectLocked(store, p.ID, errCodeNotFound, "Relic not found.")
 			return
 		}
 		if relic.Status != relicStatusAppraised {
-			setToastLocked(store, p.ID, "Relic must be appraised first.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "Relic must be appraised first.")
 			return
 		}
 		switch relic.Effect {
@@ -3437,40 +3863,36 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 			Text:     fmt.Sprintf("[%s] invokes %s.", p.Name, relic.Name),
 			At:       now,
 		})
+		store.dirty.mark(dirtyRelics, relic.ID)
 		delete(store.Relics, relic.ID)
 		setToastLocked(store, p.ID, fmt.Sprintf("Relic invoked: %s.", relicEffectLabel(relic)))
 	case "launch_project":
 		def, ok := projectDefinitionByType(in.ProjectType)
 		if !ok {
-			setToastLocked(store, p.ID, "Project type not found.")
+			rejectLocked(store, p.ID, errCodeNotFound, "Project type not found.")
 			return
 		}
 		if len(store.Projects) >= projectMaxActive {
-			setToastLocked(store, p.ID, "City project capacity reached.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "City project capacity reached.")
 			return
 		}
 		if playerHasActiveProjectLocked(store, p.ID) {
-			setToastLocked(store, p.ID, "You already have a project underway.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "You already have a project underway.")
 			return
 		}
 		if !consumeHighImpactBudgetLocked(store, p.ID, now) {
-			setToastLocked(store, p.ID, "Daily cap reached for high-impact actions.")
+			rejectLocked(store, p.ID, errCodeHighImpactCap, "Daily cap reached for high-impact actions.")
 			return
 		}
-		if p.Gold < def.CostGold {
-			setToastLocked(store, p.ID, fmt.Sprintf("Need %dg to fund this project.", def.CostGold))
+		costs := commodityCostList(def.CostGrain, def.Costs)
+		if code, need := costShortfall(p, def.CostGold, costs); code != "" {
+			rejectLocked(store, p.ID, code, need+" to fund this project.")
 			return
 		}
-		if p.Grain < def.CostGrain {
-			setToastLocked(store, p.ID, fmt.Sprintf("Need %d sacks to fund this project.", def.CostGrain))
-			return
-		}
-		p.Gold -= def.CostGold
-		if def.CostGrain > 0 {
-			p.Grain -= def.CostGrain
-		}
+		payCosts(p, def.CostGold, costs)
 		store.NextProjectID++
 		id := fmt.Sprintf("p-%d", store.NextProjectID)
+		store.dirty.mark(dirtyProjects, id)
 		store.Projects[id] = &Project{
 			ID:            id,
 			Type:          def.Type,
@@ -3481,6 +3903,7 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 			CostGrain:     def.CostGrain,
 			TicksLeft:     def.DurationTicks,
 			TotalTicks:    def.DurationTicks,
+			Definition:    &def,
 		}
 		addEventLocked(store, Event{
 			Type:     "Civic",
@@ -3491,30 +3914,24 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 		setToastLocked(store, p.ID, fmt.Sprintf("%s funded.", def.Name))
 	case "respond_crisis":
 		if store.ActiveCrisis == nil {
-			setToastLocked(store, p.ID, "No active crisis to address.")
+			rejectLocked(store, p.ID, errCodeNotFound, "No active crisis to address.")
 			return
 		}
-		def, ok := crisisDefinitionByType(store.ActiveCrisis.Type)
+		def, ok := crisisDefinitionFor(store.ActiveCrisis)
 		if !ok {
-			setToastLocked(store, p.ID, "Crisis details unavailable.")
+			rejectLocked(store, p.ID, errCodeNotFound, "Crisis details unavailable.")
 			return
 		}
 		if !consumeHighImpactBudgetLocked(store, p.ID, now) {
-			setToastLocked(store, p.ID, "Daily cap reached for high-impact actions.")
-			return
-		}
-		if p.Gold < def.GoldCost {
-			setToastLocked(store, p.ID, fmt.Sprintf("Need %dg to mobilize a response.", def.GoldCost))
+			rejectLocked(store, p.ID, errCodeHighImpactCap, "Daily cap reached for high-impact actions.")
 			return
 		}
-		if def.GrainCost > 0 && p.Grain < def.GrainCost {
-			setToastLocked(store, p.ID, fmt.Sprintf("Need %d sacks to mobilize a response.", def.GrainCost))
+		costs := commodityCostList(def.GrainCost, def.Costs)
+		if code, need := costShortfall(p, def.GoldCost, costs); code != "" {
+			rejectLocked(store, p.ID, code, need+" to mobilize a response.")
 			return
 		}
-		p.Gold -= def.GoldCost
-		if def.GrainCost > 0 {
-			p.Grain -= def.GrainCost
-		}
+		payCosts(p, def.GoldCost, costs)
 		store.ActiveCrisis.Mitigated = true
 		if store.ActiveCrisis.Severity > 1 {
 			store.ActiveCrisis.Severity--
@@ -3545,11 +3962,11 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 	case "accuse_heresy":
 		target := store.Players[in.TargetID]
 		if target == nil || target.ID == p.ID {
-			setToastLocked(store, p.ID, "Choose a valid target.")
+			rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a valid target.")
 			return
 		}
 		if !consumeHighImpactBudgetLocked(store, p.ID, now) {
-			setToastLocked(store, p.ID, "Daily cap reached for high-impact actions.")
+			rejectLocked(store, p.ID, errCodeHighImpactCap, "Daily cap reached for high-impact actions.")
 			return
 		}
 		if target.RiteImmunityTicks > 0 {
@@ -3558,7 +3975,8 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 			return
 		}
 		successChance := 35 + maxInt(0, p.Rep)/3
-		if rollPercent(store.rng, minInt(successChance, 85)) {
+		if rollPercent(rngStreamLocked(store, rngStreamIntel), minInt(successChance, 85)) {
+			store.dirty.mark(dirtyPlayers, target.ID)
 			target.Rep = clampInt(target.Rep-6, -100, 100)
 			target.Heat = clampInt(target.Heat+2, 0, 20)
 			p.Rep = clampInt(p.Rep+1, -100, 100)
@@ -3570,21 +3988,26 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 		}
 	case "conduct_inquest":
 		if !playerHoldsSeatLocked(store, p.ID, "high_curate") {
-			setToastLocked(store, p.ID, "Only the High Curate can conduct inquests.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "Only the High Curate can conduct inquests.")
 			return
 		}
 		target := store.Players[in.TargetID]
 		if target == nil || target.ID == p.ID {
-			setToastLocked(store, p.ID, "Choose a valid inquest target.")
+			rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a valid inquest target.")
+			return
+		}
+		if !inquestHasWorkLocked(store, target.ID) {
+			rejectLocked(store, p.ID, errCodeNotFound, "Inquest finds no false testimony to purge.")
 			return
 		}
 		if !consumeHighImpactBudgetLocked(store, p.ID, now) {
-			setToastLocked(store, p.ID, "Daily cap reached for high-impact actions.")
+			rejectLocked(store, p.ID, errCodeHighImpactCap, "Daily cap reached for high-impact actions.")
 			return
 		}
 		removedEvidence := 0
 		for id, ev := range store.Evidence {
 			if ev.TargetPlayerID == target.ID && ev.Forged {
+				store.dirty.mark(dirtyEvidence, id)
 				delete(store.Evidence, id)
 				removedEvidence++
 			}
@@ -3594,6 +4017,7 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 			if r.TargetPlayerID != target.ID {
 				continue
 			}
+			store.dirty.mark(dirtyRumors, r.ID)
 			r.Credibility = maxInt(1, r.Credibility-inquestRumorCredibilityDrop)
 			r.Spread = maxInt(0, r.Spread-inquestRumorSpreadDrop)
 			r.Decay = maxInt(0, r.Decay-inquestRumorDecayDrop)
@@ -3605,53 +4029,45 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 			Text:     fmt.Sprintf("[%s] conducts an inquest over [%s].", p.Name, target.Name),
 			At:       now,
 		})
-		if removedEvidence == 0 && rumorAdjusted == 0 {
-			setToastLocked(store, p.ID, "Inquest finds no false testimony to purge.")
-			return
-		}
+		store.dirty.mark(dirtyPlayers, target.ID)
 		target.Rep = clampInt(target.Rep+1, -100, 100)
 		target.Heat = maxInt(0, target.Heat-1)
 		p.Rep = clampInt(p.Rep+1, -100, 100)
 		setToastLocked(store, p.ID, fmt.Sprintf("Inquest purges %d forged dossiers and dampens %d rumor lines.", removedEvidence, rumorAdjusted))
 		setToastLocked(store, target.ID, "An inquest clears your name with the Curate.")
 	case "campaign_seat":
-		seat := store.Seats[contractID]
-		if seat == nil || seat.ElectionWindowTicks <= 0 {
-			setToastLocked(store, p.ID, "No election is open for that seat.")
-			return
-		}
-		seat.HolderPlayerID = p.ID
-		seat.HolderName = p.Name
-		seat.ElectionWindowTicks = 0
-		seat.TenureTicksLeft = seatTenureTicks
-		addEventLocked(store, Event{
-			Type:     "Institution",
-			Severity: 2,
-			Text:     fmt.Sprintf("[%s] wins the seat of %s.", p.Name, seat.Name),
-			At:       now,
-		})
-		setToastLocked(store, p.ID, fmt.Sprintf("You now hold %s.", seat.Name))
+		registerCandidateLocked(store, p, now, contractID)
+	case "cast_ballot":
+		castBallotLocked(store, p, now, contractID, strings.TrimSpace(in.TargetID))
+	case "campaign_spend":
+		campaignSpendLocked(store, p, now, contractID, in.Amount)
+	case "bribe_voter":
+		bribeVoterLocked(store, p, now, contractID, strings.TrimSpace(in.TargetID), in.Amount)
 	case "challenge_seat":
 		seat := store.Seats[contractID]
 		if seat == nil {
-			setToastLocked(store, p.ID, "Seat not found.")
+			rejectLocked(store, p.ID, errCodeNotFound, "Seat not found.")
 			return
 		}
 		lastTick, ok := store.LastSeatActionAt[p.ID]
 		if ok && store.TickCount-lastTick < 2 {
-			setToastLocked(store, p.ID, "Institution challenge cooldown active.")
+			rejectLocked(store, p.ID, errCodeCooldown, "Institution challenge cooldown active.")
 			return
 		}
+		store.dirty.mark(dirtyLastSeatActionAt, p.ID)
 		store.LastSeatActionAt[p.ID] = store.TickCount
 		if seat.HolderPlayerID == p.ID {
-			setToastLocked(store, p.ID, "You already hold that seat.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "You already hold that seat.")
 			return
 		}
 		chance := 30 + maxInt(0, p.Rep)/2
-		if rollPercent(store.rng, minInt(chance, 85)) {
+		if rollPercent(rngStreamLocked(store, rngStreamWorld), minInt(chance, 85)) {
+			store.dirty.mark(dirtySeats, seat.ID)
 			seat.HolderPlayerID = p.ID
 			seat.HolderName = p.Name
 			seat.ElectionWindowTicks = 0
+			seat.Candidates = nil
+			seat.Ballots = nil
 			seat.TenureTicksLeft = seatTenureTicks
 			p.Rep = clampInt(p.Rep+2, -100, 100)
 			addEventLocked(store, Event{
@@ -3673,132 +4089,158 @@ func handleActionInputLocked(store *Store, p *Player, now time.Time, in ActionIn
 		}
 	case "set_tax_low":
 		if !playerHoldsSeatLocked(store, p.ID, "master_of_coin") {
-			setToastLocked(store, p.ID, "Only the Master of Coin can set taxes.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "Only the Master of Coin can set taxes.")
 			return
 		}
-		store.Policies.TaxRatePct = 5
-		addEventLocked(store, Event{Type: "Policy", Severity: 2, Text: fmt.Sprintf("[%s] lowers tax to 5%%.", p.Name), At: now})
+		setTaxRateLocked(store, now, p.Name, 5)
 		setToastLocked(store, p.ID, "Tax policy updated.")
 	case "set_tax_high":
 		if !playerHoldsSeatLocked(store, p.ID, "master_of_coin") {
-			setToastLocked(store, p.ID, "Only the Master of Coin can set taxes.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "Only the Master of Coin can set taxes.")
 			return
 		}
-		store.Policies.TaxRatePct = 20
-		addEventLocked(store, Event{Type: "Policy", Severity: 3, Text: fmt.Sprintf("[%s] raises tax to 20%%.", p.Name), At: now})
+		setTaxRateLocked(store, now, p.Name, 20)
 		setToastLocked(store, p.ID, "Tax policy updated.")
 	case "toggle_permit":
 		if !playerHoldsSeatLocked(store, p.ID, "harbor_master") {
-			setToastLocked(store, p.ID, "Only the Harbor Master can control permits.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "Only the Harbor Master can control permits.")
 			return
 		}
-		store.Policies.PermitRequiredHighRisk = !store.Policies.PermitRequiredHighRisk
-		state := "lifted"
-		if store.Policies.PermitRequiredHighRisk {
-			state = "required"
-		}
-		addEventLocked(store, Event{Type: "Policy", Severity: 2, Text: fmt.Sprintf("[%s] marks emergency permits as %s.", p.Name, state), At: now})
+		setPermitPolicyLocked(store, now, p.Name, !store.Policies.PermitRequiredHighRisk)
 		setToastLocked(store, p.ID, "Permit policy updated.")
 	case "issue_permit":
 		if !playerHoldsSeatLocked(store, p.ID, "harbor_master") {
-			setToastLocked(store, p.ID, "Only the Harbor Master can issue permits.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "Only the Harbor Master can issue permits.")
 			return
 		}
 		if !store.Policies.PermitRequiredHighRisk {
-			setToastLocked(store, p.ID, "Permits are currently open; no permit needed.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "Permits are currently open; no permit needed.")
 			return
 		}
 		target := store.Players[in.TargetID]
 		if target == nil {
-			setToastLocked(store, p.ID, "Select a valid permit recipient.")
+			rejectLocked(store, p.ID, errCodeInvalidInput, "Select a valid permit recipient.")
 			return
 		}
 		if hasActivePermitLocked(store, target.ID) {
-			setToastLocked(store, p.ID, "That player already holds a permit.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "That player already holds a permit.")
 			return
 		}
-		if !consumeHighImpactBudgetLocked(store, p.ID, now) {
-			setToastLocked(store, p.ID, "Daily cap reached for high-impact actions.")
+		if target.Gold < permitFeeGold {
+			rejectLocked(store, p.ID, errCodeInsufficientGold, fmt.Sprintf("%s cannot pay the %dg permit fee.", target.Name, permitFeeGold))
 			return
 		}
-		store.Permits[target.ID] = &Permit{
-			PlayerID:     target.ID,
-			PlayerName:   target.Name,
-			IssuerID:     p.ID,
-			IssuerName:   p.Name,
-			TicksLeft:    permitDurationTicks,
-			TotalTicks:   permitDurationTicks,
-			IssuedAtTick: store.TickCount,
+		if !consumeHighImpactBudgetLocked(store, p.ID, now) {
+			rejectLocked(store, p.ID, errCodeHighImpactCap, "Daily cap reached for high-impact actions.")
+			return
 		}
-		addEventLocked(store, Event{Type: "Policy", Severity: 2, Text: fmt.Sprintf("[%s] issues a permit to [%s].", p.Name, target.Name), At: now})
+		grantPermitLocked(store, now, p.ID, p.Name, target)
 		setToastLocked(store, p.ID, "Permit issued.")
 	case "issue_warrant":
 		if !playerHoldsSeatLocked(store, p.ID, "watch_commander") {
-			setToastLocked(store, p.ID, "Only the Commander of the Watch can issue warrants.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "Only the Commander of the Watch can issue warrants.")
 			return
 		}
 		target := store.Players[in.TargetID]
 		if target == nil || target.ID == p.ID {
-			setToastLocked(store, p.ID, "Choose a valid warrant target.")
+			rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a valid warrant target.")
 			return
 		}
 		if hasActiveWarrantLocked(store, target.ID) {
-			setToastLocked(store, p.ID, "That target is already under warrant.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "That target is already under warrant.")
 			return
 		}
 		if !consumeHighImpactBudgetLocked(store, p.ID, now) {
-			setToastLocked(store, p.ID, "Daily cap reached for high-impact actions.")
+			rejectLocked(store, p.ID, errCodeHighImpactCap, "Daily cap reached for high-impact actions.")
 			return
 		}
-		store.Warrants[target.ID] = &Warrant{
-			PlayerID:     target.ID,
-			PlayerName:   target.Name,
-			IssuerID:     p.ID,
-			IssuerName:   p.Name,
-			TicksLeft:    warrantDurationTicks,
-			TotalTicks:   warrantDurationTicks,
-			IssuedAtTick: store.TickCount,
-		}
-		target.Heat = clampInt(target.Heat+warrantHeatDelta, 0, 20)
-		target.Rep = clampInt(target.Rep-1, -100, 100)
-		addEventLocked(store, Event{Type: "Law", Severity: 3, Text: fmt.Sprintf("[%s] issues a warrant on [%s].", p.Name, target.Name), At: now})
-		if !hasActiveBountyForTargetLocked(store, target.ID) {
-			issueBountyContractLocked(store, target, bountyDeadlineTicks)
-			addEventLocked(store, Event{Type: "Law", Severity: 3, Text: fmt.Sprintf("[City Watch] posts a warrant bounty on [%s].", target.Name), At: now})
-		}
+		issueWarrantLocked(store, now, p.ID, p.Name, target)
 		setToastLocked(store, p.ID, fmt.Sprintf("Warrant issued for %s.", target.Name))
-		setToastLocked(store, target.ID, "A warrant has been issued in your name.")
+	case "submit_evidence":
+		submitEvidenceLocked(store, p, now, contractID, strings.TrimSpace(in.TargetID))
+	case "jury_vote":
+		juryVoteLocked(store, p, now, contractID, strings.TrimSpace(in.Stance))
+	case "rule_case":
+		ruleCaseLocked(store, p, now, contractID, strings.TrimSpace(in.Stance))
 	case "toggle_embargo":
 		if !playerHoldsSeatLocked(store, p.ID, "harbor_master") {
-			setToastLocked(store, p.ID, "Only the Harbor Master can set embargoes.")
+			rejectLocked(store, p.ID, errCodeNotAllowed, "Only the Harbor Master can set embargoes.")
 			return
 		}
-		if store.Policies.SmugglingEmbargoTicks > 0 {
-			store.Policies.SmugglingEmbargoTicks = 0
-			addEventLocked(store, Event{Type: "Policy", Severity: 2, Text: fmt.Sprintf("[%s] lifts the smuggling embargo.", p.Name), At: now})
-		} else {
-			store.Policies.SmugglingEmbargoTicks = 3
-			addEventLocked(store, Event{Type: "Policy", Severity: 3, Text: fmt.Sprintf("[%s] imposes a smuggling embargo.", p.Name), At: now})
-		}
+		setEmbargoLocked(store, now, p.Name, store.Policies.SmugglingEmbargoTicks == 0)
 		setToastLocked(store, p.ID, "Embargo policy updated.")
 	default:
-		setToastLocked(store, p.ID, "Unknown action.")
+		rejectLocked(store, p.ID, errCodeUnknownAction, "Unknown action.")
 	}
 
 	store.World.Situation = deriveSituation(store.World.GrainTier, store.World.UnrestTier)
 }
 
+// handleMissiveLocked validates and dispatches a diplomatic message, reporting
+// problems through the sender's toast.
+func handleMissiveLocked(store *Store, p *Player, now time.Time, in MissiveInput) bool {
+	if tooSoon(store.LastMessageAt[p.ID], now, messageCooldown) {
+		rejectLocked(store, p.ID, errCodeCooldown, "Couriers need more time to return.")
+		return false
+	}
+
+	if in.TargetID == "" {
+		rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a recipient.")
+		return false
+	}
+	target := store.Players[in.TargetID]
+	if target == nil || target.ID == p.ID {
+		rejectLocked(store, p.ID, errCodeNotFound, "That recipient is unavailable.")
+		return false
+	}
+	if in.Subject == "" || in.Body == "" {
+		rejectLocked(store, p.ID, errCodeInvalidInput, "Subject and message are required.")
+		return false
+	}
+	if len(in.Subject) > messageSubjectMax {
+		rejectLocked(store, p.ID, errCodeInvalidInput, fmt.Sprintf("Subject too long (max %d).", messageSubjectMax))
+		return false
+	}
+	if len(in.Body) > messageBodyMax {
+		rejectLocked(store, p.ID, errCodeInvalidInput, fmt.Sprintf("Message too long (max %d).", messageBodyMax))
+		return false
+	}
+	if in.Sealed && p.Gold < sealedMessageCost {
+		rejectLocked(store, p.ID, errCodeInsufficientGold, fmt.Sprintf("Need %dg to seal a missive.", sealedMessageCost))
+		return false
+	}
+
+	store.dirty.mark(dirtyLastMessageAt, p.ID)
+	store.LastMessageAt[p.ID] = now
+	if in.Sealed {
+		p.Gold -= sealedMessageCost
+	}
+	addDiplomacyMessageLocked(store, DiplomaticMessage{
+		FromPlayerID: p.ID,
+		FromName:     p.Name,
+		ToPlayerID:   target.ID,
+		ToName:       target.Name,
+		Subject:      in.Subject,
+		Body:         in.Body,
+		At:           now,
+		Sealed:       in.Sealed,
+	})
+	setToastLocked(store, p.ID, fmt.Sprintf("Courier dispatched to %s.", target.Name))
+	setToastLocked(store, target.ID, fmt.Sprintf("A courier arrives from %s.", p.Name))
+	return true
+}
+
 func handleChatLocked(store *Store, p *Player, now time.Time, msg string) bool {
 	if strings.HasPrefix(strings.ToLower(msg), "/w ") {
 		target, body := resolveWhisperTargetLocked(store, strings.TrimSpace(msg[3:]))
 		if body == "" {
 			addChatLocked(store, ChatMessage{FromPlayerID: p.ID, FromName: "System", ToPlayerID: p.ID, ToName: p.Name, Text: "Usage: /w <Name> <message>", At: now, Kind: "system"})
-			setToastLocked(store, p.ID, "Invalid whisper format.")
+			rejectLocked(store, p.ID, errCodeInvalidInput, "Invalid whisper format.")
 			return false
 		}
 		if target == nil {
 			addChatLocked(store, ChatMessage{FromPlayerID: p.ID, FromName: "System", ToPlayerID: p.ID, ToName: p.Name, Text: "Whisper target not found.", At: now, Kind: "system"})
-			setToastLocked(store, p.ID, "Player not found.")
+			rejectLocked(store, p.ID, errCodeNotFound, "Player not found.")
 			return false
 		}
 		addChatLocked(store, ChatMessage{FromPlayerID: p.ID, FromName: p.Name, ToPlayerID: target.ID, ToName: target.Name, Text: body, At: now, Kind: "whisper"})
@@ -3840,6 +4282,7 @@ func finalizeDeliveredContractLocked(store *Store, p *Player, c *Contract, now t
 
 	c.Status = "Completed"
 	p.Gold += outcome.RewardGold
+	depositTreasuryLocked(store, now, "city_authority", outcome.TaxGold, "Contract tax", p)
 	p.Rep = clampInt(p.Rep+outcome.RepDelta, -100, 100)
 	p.Heat = maxInt(0, p.Heat+outcome.HeatDelta)
 	if p.Rumors > 0 {
@@ -3903,11 +4346,13 @@ func ensurePlayerLocked(store *Store, w http.ResponseWriter, r *http.Request) *P
 
 	p := store.Players[pid]
 	if p == nil {
-		p = &Player{ID: pid, Name: uniqueGuestNameLocked(store), Gold: initialPlayerGold, Grain: 0, Rep: 0, LastSeen: time.Now().UTC()}
-		store.Players[pid] = p
+		now := time.Now().UTC()
+		p = addPlayerLocked(store, pid, uniqueGuestNameLocked(store), now)
+		recordJournalLocked(store, JournalEntry{Kind: journalKindJoin, PlayerID: p.ID, Name: p.Name, At: now})
 		setToastLocked(store, pid, fmt.Sprintf("You arrive as %s.", p.Name))
-		addEventLocked(store, Event{Type: "Join", Severity: 1, Text: fmt.Sprintf("[%s] enters the city under a borrowed name.", p.Name), At: time.Now().UTC()})
 	}
+	// Callers go on to stamp LastSeen, so the player is always marked.
+	store.dirty.mark(dirtyPlayers, p.ID)
 	p.SoftDeletedAt = time.Time{}
 	p.HardDeletedAt = time.Time{}
 	if p.LocationID == "" {
@@ -3916,6 +4361,14 @@ func ensurePlayerLocked(store *Store, w http.ResponseWriter, r *http.Request) *P
 	return p
 }
 
+func addPlayerLocked(store *Store, pid, name string, now time.Time) *Player {
+	p := &Player{ID: pid, Name: name, Gold: initialPlayerGold, Grain: 0, Rep: 0, LastSeen: now, LocationID: locationCapital}
+	store.Players[pid] = p
+	store.dirty.mark(dirtyPlayers, pid)
+	addEventLocked(store, Event{Type: "Join", Severity: 1, Text: fmt.Sprintf("[%s] enters the city under a borrowed name.", p.Name), At: now})
+	return p
+}
+
 func requestIsHTTPS(r *http.Request) bool {
 	if r == nil {
 		return false
@@ -3972,7 +4425,7 @@ func getPlayerCookieSecret() []byte {
 }
 
 func uniqueGuestNameLocked(store *Store) string {
-	base := fmt.Sprintf("%s %s (Guest)", randomFrom(store.rng, nameFirst), randomFrom(store.rng, nameLast))
+	base := fmt.Sprintf("%s %s (Guest)", randomFrom(rngStreamLocked(store, rngStreamNames), nameFirst), randomFrom(rngStreamLocked(store, rngStreamNames), nameLast))
 	if !playerNameExistsLocked(store, base) {
 		return base
 	}
@@ -3981,7 +4434,7 @@ func uniqueGuestNameLocked(store *Store) string {
 		return candidate
 	}
 	for {
-		candidate := fmt.Sprintf("%s %s (Guest) #%s", randomFrom(store.rng, nameFirst), randomFrom(store.rng, nameLast), randomSuffix())
+		candidate := fmt.Sprintf("%s %s (Guest) #%s", randomFrom(rngStreamLocked(store, rngStreamNames), nameFirst), randomFrom(rngStreamLocked(store, rngStreamNames), nameLast), randomSuffix())
 		if !playerNameExistsLocked(store, candidate) {
 			return candidate
 		}
@@ -4029,10 +4482,12 @@ func addEventLocked(store *Store, e Event) {
 	if e.At.IsZero() {
 		e.At = time.Now().UTC()
 	}
+	store.dirty.markField(dirtyEvents)
 	store.Events = append(store.Events, e)
 	if len(store.Events) > maxEvents {
 		store.Events = store.Events[len(store.Events)-maxEvents:]
 	}
+	store.push.notifyAll(pushEvents)
 }
 
 func addChatLocked(store *Store, msg ChatMessage) {
@@ -4041,10 +4496,16 @@ func addChatLocked(store *Store, msg ChatMessage) {
 	if msg.At.IsZero() {
 		msg.At = time.Now().UTC()
 	}
+	store.dirty.markField(dirtyChat)
 	store.Chat = append(store.Chat, msg)
 	if len(store.Chat) > maxChat {
 		store.Chat = store.Chat[len(store.Chat)-maxChat:]
 	}
+	if msg.ToPlayerID != "" {
+		store.push.notify(pushChat, msg.FromPlayerID, msg.ToPlayerID)
+	} else {
+		store.push.notifyAll(pushChat)
+	}
 }
 
 func addDiplomacyMessageLocked(store *Store, msg DiplomaticMessage) {
@@ -4053,21 +4514,24 @@ func addDiplomacyMessageLocked(store *Store, msg DiplomaticMessage) {
 	if msg.At.IsZero() {
 		msg.At = time.Now().UTC()
 	}
+	store.dirty.markField(dirtyMessages)
 	store.Messages = append(store.Messages, msg)
 	if len(store.Messages) > maxDiplomacyMessages {
 		store.Messages = store.Messages[len(store.Messages)-maxDiplomacyMessages:]
 	}
+	store.push.notify(pushDiplomacy, msg.FromPlayerID, msg.ToPlayerID)
 }
 
 func issueContractLocked(store *Store, ctype string, deadline int) {
 	store.NextContractID++
 	id := fmt.Sprintf("c-%d", store.NextContractID)
+	store.dirty.mark(dirtyContracts, id)
 	store.Contracts[id] = &Contract{ID: id, Type: ctype, DeadlineTicks: deadline, Status: "Issued", IssuedAtTick: store.TickCount}
 }
 
-func issueBountyContractLocked(store *Store, target *Player, deadline int) {
+func issueBountyContractLocked(store *Store, target *Player, deadline int) *Contract {
 	if store == nil || target == nil {
-		return
+		return nil
 	}
 	store.NextContractID++
 	id := fmt.Sprintf("c-%d", store.NextContractID)
@@ -4077,6 +4541,7 @@ func issueBountyContractLocked(store *Store, target *Player, deadline int) {
 		reward += warrantRewardBonus
 		warranted = true
 	}
+	store.dirty.mark(dirtyContracts, id)
 	store.Contracts[id] = &Contract{
 		ID:             id,
 		Type:           "Bounty",
@@ -4089,6 +4554,7 @@ func issueBountyContractLocked(store *Store, target *Player, deadline int) {
 		BountyEvidence: bountyEvidenceMin,
 		Warranted:      warranted,
 	}
+	return store.Contracts[id]
 }
 
 func issueSupplyContractLocked(store *Store, issuer *Player, sacks, reward, deadline int) *Contract {
@@ -4108,10 +4574,27 @@ func issueSupplyContractLocked(store *Store, issuer *Player, sacks, reward, dead
 		RewardGold:     reward,
 		SupplySacks:    sacks,
 	}
+	store.dirty.mark(dirtyContracts, id)
 	store.Contracts[id] = c
 	return c
 }
 
+// inquestHasWorkLocked reports whether an inquest over targetID would find
+// forged evidence to purge or rumors to dampen.
+func inquestHasWorkLocked(store *Store, targetID string) bool {
+	for _, ev := range store.Evidence {
+		if ev.TargetPlayerID == targetID && ev.Forged {
+			return true
+		}
+	}
+	for _, r := range store.Rumors {
+		if r.TargetPlayerID == targetID {
+			return true
+		}
+	}
+	return false
+}
+
 func hasActiveSupplyFromIssuerLocked(store *Store, issuerID string) bool {
 	for _, c := range store.Contracts {
 		if c.Type != "Supply" || c.IssuerPlayerID != issuerID {
@@ -4166,6 +4649,17 @@ func playerHasActiveProjectLocked(store *Store, playerID string) bool {
 	return false
 }
 
+// sortedKeys returns m's keys in order so world ticks visit entities the same
+// way on every run, which journal replay depends on.
+func sortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
 func sortedContractsLocked(store *Store) []*Contract {
 	out := make([]*Contract, 0, len(store.Contracts))
 	for _, c := range store.Contracts {
@@ -4183,7 +4677,7 @@ func sortedContractsLocked(store *Store) []*Contract {
 func onlinePlayersLocked(store *Store, now time.Time) []*Player {
 	var out []*Player
 	for _, p := range store.Players {
-		if now.Sub(p.LastSeen) <= onlineWindow {
+		if now.Sub(lastSeenLocked(store, p)) <= onlineWindow {
 			out = append(out, p)
 		}
 	}
@@ -4192,11 +4686,45 @@ func onlinePlayersLocked(store *Store, now time.Time) []*Player {
 }
 
 func setToastLocked(store *Store, pid, text string) {
+	store.dirty.mark(dirtyToastByPlayer, pid)
 	store.ToastByPlayer[pid] = text
+	store.push.notify(pushToast, pid)
+}
+
+// Machine-readable reasons a request was refused, reported by /api/v1.
+const (
+	errCodeCooldown          = "cooldown"
+	errCodeInsufficientGold  = "insufficient_gold"
+	errCodeInsufficientGrain = "insufficient_grain"
+	errCodeInsufficientGoods = "insufficient_goods"
+	errCodeTravelLockout     = "travel_lockout"
+	errCodeHighImpactCap     = "high_impact_cap"
+	errCodeNotAllowed        = "not_allowed"
+	errCodeNotFound          = "not_found"
+	errCodeInvalidInput      = "invalid_input"
+	errCodeUnknownAction     = "unknown_action"
+	errCodeRestricted        = "restricted"
+)
+
+// rejectLocked refuses a player request. The text reaches the player as a
+// toast like any other outcome; the code is kept until the API collects it.
+func rejectLocked(store *Store, pid, code, text string) {
+	setToastLocked(store, pid, text)
+	if store.rejections == nil {
+		store.rejections = map[string]string{}
+	}
+	store.rejections[pid] = code
+}
+
+func takeRejectionLocked(store *Store, pid string) string {
+	code := store.rejections[pid]
+	delete(store.rejections, pid)
+	return code
 }
 
 func popToastLocked(store *Store, pid string) string {
 	msg := store.ToastByPlayer[pid]
+	store.dirty.mark(dirtyToastByPlayer, pid)
 	delete(store.ToastByPlayer, pid)
 	return msg
 }
@@ -4207,10 +4735,18 @@ func peekToastLocked(store *Store, pid string) string {
 
 func buildPageDataLocked(store *Store, playerID string, consumeToast bool) PageData {
 	now := time.Now().UTC()
-	p := store.Players[playerID]
-	if p == nil {
+	live := store.Players[playerID]
+	if live == nil {
 		return PageData{}
 	}
+	// Build from a copy so a read never writes and the page does not alias
+	// live state once the lock is released.
+	snapshot := *live
+	snapshot.Inventory = maps.Clone(live.Inventory)
+	p := &snapshot
+	world := store.World
+	world.Markets = cloneMarkets(store.World.Markets)
+	world.MarketVolume = cloneMarkets(store.World.MarketVolume)
 	ensureTodayCounterLocked(p, now)
 	today := now.UTC().Format("2006-01-02")
 	highImpactRemaining := highImpactDailyCap
@@ -4496,7 +5032,7 @@ func buildPageDataLocked(store *Store, playerID string, consumeToast bool) PageD
 		if warrant := warrantForPlayerLocked(store, pl.ID); warrant != nil {
 			warrantLabel = fmt.Sprintf("Warrant (%dt)", warrant.TicksLeft)
 		}
-		isOnline := now.Sub(pl.LastSeen) <= onlineWindow
+		isOnline := now.Sub(lastSeenLocked(store, pl)) <= onlineWindow
 		iconTint := "blue"
 		if isOnline {
 			iconTint = "lime"
@@ -4509,7 +5045,10 @@ func buildPageDataLocked(store *Store, playerID string, consumeToast bool) PageD
 			Heat:      pl.Heat,
 			HeatLabel: standingHeatLabel(pl.Heat),
 			Warrant:   warrantLabel,
+			Court:     courtStatusLocked(store, pl),
+			Status:    statusSummary(pl),
 			Online:    isOnline,
+			NPC:       npcRoleLabel(pl.NPCRole),
 			IconPath:  iconAsset("delapouite", "meeple-circle"),
 			IconTint:  iconTint,
 		})
@@ -4570,20 +5109,26 @@ func buildPageDataLocked(store *Store, playerID string, consumeToast bool) PageD
 		sealMessageNote = fmt.Sprintf("Need %dg to seal a missive.", sealedMessageCost)
 	}
 
-	seatOrder := []string{"harbor_master", "master_of_coin", "watch_commander", "high_curate"}
+	seatOrder := []string{"harbor_master", "master_of_coin", "watch_commander", "high_curate", "magistrate"}
 	seats := make([]SeatView, 0, len(seatOrder))
 	for _, seatID := range seatOrder {
 		seat := store.Seats[seatID]
 		if seat == nil {
 			continue
 		}
-		instName := ""
+		instName, treasury := "", 0
 		if inst := store.Institutions[seat.InstitutionID]; inst != nil {
-			instName = inst.Name
+			instName, treasury = inst.Name, inst.Treasury
 		}
 		canIssuePermit := seat.ID == "harbor_master" && seat.HolderPlayerID == p.ID && store.Policies.PermitRequiredHighRisk && hasOtherPlayers && highImpactRemaining > 0
 		canConductInquest := seat.ID == "high_curate" && seat.HolderPlayerID == p.ID && hasOtherPlayers && highImpactRemaining > 0
 		canIssueWarrant := seat.ID == "watch_commander" && seat.HolderPlayerID == p.ID && hasOtherPlayers && highImpactRemaining > 0
+		electionOpen := seat.ElectionWindowTicks > 0
+		isCandidate := seatCandidate(seat, p.ID) != nil
+		voteWeight := 0
+		if electionOpen {
+			voteWeight = ballotWeightLocked(store, seat, p)
+		}
 		seats = append(seats, SeatView{
 			ID:                  seat.ID,
 			Name:                seat.Name,
@@ -4591,8 +5136,8 @@ func buildPageDataLocked(store *Store, playerID string, consumeToast bool) PageD
 			HolderName:          seat.HolderName,
 			TenureTicksLeft:     seat.TenureTicksLeft,
 			ElectionWindowTicks: seat.ElectionWindowTicks,
-			IsElectionOpen:      seat.ElectionWindowTicks > 0,
-			CanCampaign:         seat.ElectionWindowTicks > 0,
+			IsElectionOpen:      electionOpen,
+			CanCampaign:         electionOpen && !isCandidate,
 			CanChallenge:        seat.ElectionWindowTicks == 0 && seat.HolderPlayerID != p.ID,
 			CanToggleTaxHigh:    seat.ID == "master_of_coin" && seat.HolderPlayerID == p.ID && store.Policies.TaxRatePct != 20,
 			CanToggleTaxLow:     seat.ID == "master_of_coin" && seat.HolderPlayerID == p.ID && store.Policies.TaxRatePct != 5,
@@ -4601,6 +5146,13 @@ func buildPageDataLocked(store *Store, playerID string, consumeToast bool) PageD
 			CanIssuePermit:      canIssuePermit,
 			CanConductInquest:   canConductInquest,
 			CanIssueWarrant:     canIssueWarrant,
+			Treasury:            treasury,
+			CanSpendTreasury:    seat.HolderPlayerID == p.ID,
+			Candidates:          candidateViewsLocked(seat, p),
+			IsCandidate:         isCandidate,
+			CanVote:             voteWeight > 0,
+			VoteWeight:          voteWeight,
+			VotedFor:            seat.Ballots[p.ID].CandidateName,
 		})
 	}
 
@@ -4640,7 +5192,7 @@ func buildPageDataLocked(store *Store, playerID string, consumeToast bool) PageD
 		locationOptions = append(locationOptions, LocationOption{
 			ID:          def.ID,
 			Name:        def.Name,
-			TravelTicks: travelTicksBetween(p.LocationID, def.ID),
+			TravelTicks: travelTicksLocked(store, p.LocationID, def.ID),
 			Disabled:    disabled,
 			Reason:      reason,
 			IconPath:    optionIconPath,
@@ -4907,6 +5459,10 @@ func buildPageDataLocked(store *Store, playerID string, consumeToast bool) PageD
 		accessStatus = fmt.Sprintf("Bribed (%dt)", p.BribeAccessTicks)
 	}
 	warrantStatus := "Clear"
+	courtStatus := courtStatusLocked(store, p)
+	if courtStatus == "" {
+		courtStatus = "Clear"
+	}
 	if warrant := warrantForPlayerLocked(store, p.ID); warrant != nil {
 		warrantStatus = fmt.Sprintf("Active (%dt)", warrant.TicksLeft)
 	}
@@ -4946,7 +5502,7 @@ func buildPageDataLocked(store *Store, playerID string, consumeToast bool) PageD
 			owner = fmt.Sprintf("%s (%s)", ownerP.Name, reputationTitle(ownerP.Rep))
 		}
 		effectNote := "effects pending"
-		if def, ok := projectDefinitionByType(proj.Type); ok {
+		if def, ok := projectDefinitionFor(proj); ok {
 			effectNote = projectEffectNote(def)
 		}
 		projects = append(projects, ProjectView{
@@ -4968,6 +5524,7 @@ func buildPageDataLocked(store *Store, playerID string, consumeToast bool) PageD
 	playerHasProject := playerHasActiveProjectLocked(store, p.ID)
 	activeProjectCount := len(store.Projects)
 	for _, def := range projectDefinitions() {
+		costs := commodityCostList(def.CostGrain, def.Costs)
 		disabledReason := ""
 		if activeProjectCount >= projectMaxActive {
 			disabledReason = "City project capacity reached."
@@ -4975,10 +5532,8 @@ func buildPageDataLocked(store *Store, playerID string, consumeToast bool) PageD
 			disabledReason = "You already have a project underway."
 		} else if highImpactRemaining == 0 {
 			disabledReason = "Daily high-impact cap reached."
-		} else if p.Gold < def.CostGold {
-			disabledReason = fmt.Sprintf("Need %dg.", def.CostGold)
-		} else if def.CostGrain > 0 && p.Grain < def.CostGrain {
-			disabledReason = fmt.Sprintf("Need %d sacks.", def.CostGrain)
+		} else if _, need := costShortfall(p, def.CostGold, costs); need != "" {
+			disabledReason = need + "."
 		}
 		projectOptions = append(projectOptions, ProjectOption{
 			Type:           def.Type,
@@ -4986,6 +5541,7 @@ func buildPageDataLocked(store *Store, playerID string, consumeToast bool) PageD
 			Description:    def.Description,
 			CostGold:       def.CostGold,
 			CostGrain:      def.CostGrain,
+			CostLabel:      costLabel(def.CostGold, costs),
 			DurationTicks:  def.DurationTicks,
 			Disabled:       disabledReason != "",
 			DisabledReason: disabledReason,
@@ -4994,25 +5550,17 @@ func buildPageDataLocked(store *Store, playerID string, consumeToast bool) PageD
 
 	var crisisView *CrisisView
 	if store.ActiveCrisis != nil {
-		if def, ok := crisisDefinitionByType(store.ActiveCrisis.Type); ok {
-			costParts := []string{}
-			if def.GoldCost > 0 {
-				costParts = append(costParts, fmt.Sprintf("%dg", def.GoldCost))
-			}
-			if def.GrainCost > 0 {
-				costParts = append(costParts, fmt.Sprintf("%d sacks", def.GrainCost))
-			}
-			costLabel := "Cost: none"
-			if len(costParts) > 0 {
-				costLabel = fmt.Sprintf("Cost: %s", strings.Join(costParts, " · "))
+		if def, ok := crisisDefinitionFor(store.ActiveCrisis); ok {
+			costs := commodityCostList(def.GrainCost, def.Costs)
+			responseCost := "Cost: none"
+			if label := costLabel(def.GoldCost, costs); label != "" {
+				responseCost = "Cost: " + label
 			}
 			disabledReason := ""
 			if highImpactRemaining == 0 {
 				disabledReason = "Daily high-impact cap reached."
-			} else if p.Gold < def.GoldCost {
-				disabledReason = fmt.Sprintf("Need %dg.", def.GoldCost)
-			} else if def.GrainCost > 0 && p.Grain < def.GrainCost {
-				disabledReason = fmt.Sprintf("Need %d sacks.", def.GrainCost)
+			} else if _, need := costShortfall(p, def.GoldCost, costs); need != "" {
+				disabledReason = need + "."
 			}
 			crisisView = &CrisisView{
 				Name:                   def.Name,
@@ -5021,24 +5569,21 @@ func buildPageDataLocked(store *Store, playerID string, consumeToast bool) PageD
 				TicksLeft:              store.ActiveCrisis.TicksLeft,
 				TotalTicks:             store.ActiveCrisis.TotalTicks,
 				ResponseLabel:          def.ResponseLabel,
-				ResponseCost:           costLabel,
+				ResponseCost:           responseCost,
 				ResponseDisabled:       disabledReason != "",
 				ResponseDisabledReason: disabledReason,
 			}
 		}
 	}
 
-	marketBase := marketBasePrice(store.World.GrainTier)
-	marketBuy := marketBuyPrice(marketBase, store.Policies.TaxRatePct, store.World.RestrictedMarketsTicks)
-	marketSell := marketSellPrice(marketBase, store.Policies.TaxRatePct, store.World.RestrictedMarketsTicks)
-	marketSupplySacks := store.World.GrainSupply / grainUnitPerSack
-	marketMaxBuy := minInt(marketSupplySacks, p.Gold/marketBuy)
-	if marketMaxBuy < 0 {
-		marketMaxBuy = 0
+	commodities := commodityViewsLocked(store, p)
+	_, marketControls := marketTaxLocked(store, p.LocationID)
+	var grainMarket CommodityView
+	for _, c := range commodities {
+		if c.ID == commodityGrain {
+			grainMarket = c
+		}
 	}
-	marketMaxSell := maxInt(0, p.Grain)
-	marketBuyDisabled := marketMaxBuy <= 0
-	marketSellDisabled := marketMaxSell <= 0
 	reliefDisabled := p.Grain < reliefSackCost
 	reliefLabel := fmt.Sprintf("Fund Relief (%d sacks)", reliefSackCost)
 
@@ -5059,24 +5604,39 @@ func buildPageDataLocked(store *Store, playerID string, consumeToast bool) PageD
 			PermitStatus:    permitStatus,
 			AccessStatus:    accessStatus,
 			WarrantStatus:   warrantStatus,
+			CourtStatus:     courtStatus,
+			Restrictions:    statusViewsLocked(store, p),
 		},
-		World:                   store.World,
+		World:                   world,
+		Calendar:                calendarViewFor(store.World),
 		Situation:               store.World.Situation,
 		HighImpactRemaining:     highImpactRemaining,
 		HighImpactCap:           highImpactDailyCap,
 		InvestigateDisabled:     investigateDisabled,
 		InvestigateLabel:        investigateLabel,
-		MarketBasePrice:         marketBase,
-		MarketBuyPrice:          marketBuy,
-		MarketSellPrice:         marketSell,
-		MarketSupplySacks:       marketSupplySacks,
-		MarketControlsTicks:     store.World.RestrictedMarketsTicks,
-		MarketControlsActive:    store.World.RestrictedMarketsTicks > 0,
-		MarketStockpile:         p.Grain,
-		MarketMaxBuy:            marketMaxBuy,
-		MarketMaxSell:           marketMaxSell,
-		MarketBuyDisabled:       marketBuyDisabled,
-		MarketSellDisabled:      marketSellDisabled,
+		MarketBasePrice:         grainMarket.BasePrice,
+		MarketBuyPrice:          grainMarket.BuyPrice,
+		MarketSellPrice:         grainMarket.SellPrice,
+		MarketSupplySacks:       grainMarket.Supply,
+		MarketControlsTicks:     marketControls,
+		MarketControlsActive:    marketControls > 0,
+		MarketStockpile:         grainMarket.Held,
+		MarketMaxBuy:            grainMarket.MaxBuy,
+		MarketMaxSell:           grainMarket.MaxSell,
+		MarketBuyDisabled:       grainMarket.BuyDisabled,
+		MarketSellDisabled:      grainMarket.SellDisabled,
+		Commodities:             commodities,
+		MarketTaxNote:           marketTaxNote(store, p.LocationID),
+		MarketOrders:            playerOrderViewsLocked(store, p),
+		MarketHistory:           marketHistoryViewsLocked(store, p),
+		Caravans:                caravanViewsLocked(store, p),
+		CaravanGoods:            caravanGoods(p),
+		CaravanGuardCost:        caravanGuardCost,
+		CaravanMaxEscorts:       caravanMaxEscorts,
+		CaravanMaxCargo:         caravanMaxCargo,
+		CaravanMaxGuards:        caravanMaxGuards,
+		Buildings:               buildingViewsLocked(store, p),
+		BuildOptions:            buildingOptionsLocked(store, p),
 		ReliefCost:              reliefSackCost,
 		ReliefDisabled:          reliefDisabled,
 		ReliefLabel:             reliefLabel,
@@ -5094,6 +5654,14 @@ func buildPageDataLocked(store *Store, playerID string, consumeToast bool) PageD
 		VisibleContractN:        len(contracts),
 		TotalContractN:          totalContractN,
 		Seats:                   seats,
+		Treasuries:              treasuryViewsLocked(store),
+		TreasuryLedger:          treasuryLedgerViewsLocked(store, treasuryLedgerShown),
+		TreasuryReliefCost:      treasuryReliefCost,
+		TreasuryPatrolCost:      treasuryPatrolCost,
+		TreasuryBountyMin:       treasuryBountyMin,
+		TreasuryBountyMax:       treasuryBountyMax,
+		ElectionFilingFee:       electionFilingFee,
+		ElectionBribeMin:        electionBribeMin,
 		Policies:                store.Policies,
 		Rumors:                  rumors,
 		Evidence:                evidence,
@@ -5106,6 +5674,9 @@ func buildPageDataLocked(store *Store, playerID string, consumeToast bool) PageD
 		Obligations:             obligations,
 		Permits:                 permits,
 		Warrants:                warrants,
+		Cases:                   caseViewsLocked(store, p, now),
+		TheftClues:              theftClueViewsLocked(store, p),
+		Fight:                   fightViewLocked(store, p),
 		Relics:                  relics,
 		RelicAppraiseCost:       relicAppraiseCost,
 		Projects:                projects,
@@ -5446,16 +6017,7 @@ func buildAdminDiagnosticsLocked(store *Store, now time.Time) AdminDiagnostics {
 }
 
 func grainTierFromSupply(v int) string {
-	switch {
-	case v > 200:
-		return "Stable"
-	case v >= 101:
-		return "Tight"
-	case v >= 41:
-		return "Scarce"
-	default:
-		return "Critical"
-	}
+	return commodityTier(grainCommodity(), v)
 }
 
 func unrestTierFromValue(v int) string {
@@ -5503,6 +6065,16 @@ func unrestTierNarrative(from, to string) string {
 	return "The streets quiet as tensions ease."
 }
 
+// shiftUnrestLocked moves unrest by delta and reports a change of tier.
+func shiftUnrestLocked(store *Store, now time.Time, delta int) {
+	prev := store.World.UnrestTier
+	store.World.UnrestValue = clampInt(store.World.UnrestValue+delta, 0, 100)
+	store.World.UnrestTier = unrestTierFromValue(store.World.UnrestValue)
+	if store.World.UnrestTier != prev {
+		addEventLocked(store, Event{Type: "Unrest", Severity: 2, Text: unrestTierNarrative(prev, store.World.UnrestTier), At: now})
+	}
+}
+
 func deriveSituation(grainTier, unrestTier string) string {
 	switch {
 	case grainTier == "Stable" && unrestTier == "Calm":
@@ -5587,16 +6159,7 @@ func payoutMultiplier(rep int) float64 {
 }
 
 func marketBasePrice(tier string) int {
-	switch tier {
-	case "Tight":
-		return 3
-	case "Scarce":
-		return 5
-	case "Critical":
-		return 7
-	default:
-		return 2
-	}
+	return commodityBasePrice(grainCommodity(), tier)
 }
 
 func marketBuyPrice(base, taxRatePct, controlsTicks int) int {
@@ -5629,47 +6192,7 @@ func applyGrainSupplyDeltaLocked(store *Store, now time.Time, delta int) {
 }
 
 func projectDefinitions() []ProjectDefinition {
-	return []ProjectDefinition{
-		{
-			Type:          "granary_reinforcement",
-			Name:          "Granary Reinforcement",
-			Description:   "Expand storage and repair leakage.",
-			CostGold:      10,
-			CostGrain:     4,
-			DurationTicks: 3,
-			GrainDelta:    60,
-			UnrestDelta:   -4,
-		},
-		{
-			Type:          "civic_patrols",
-			Name:          "Civic Patrols",
-			Description:   "Fund watch patrols to cool hot streets.",
-			CostGold:      8,
-			CostGrain:     0,
-			DurationTicks: 2,
-			UnrestDelta:   -6,
-			HeatDelta:     -2,
-		},
-		{
-			Type:          "public_festival",
-			Name:          "Public Festival",
-			Description:   "Sponsor a sanctioned feast to lift morale.",
-			CostGold:      6,
-			CostGrain:     2,
-			DurationTicks: 2,
-			UnrestDelta:   -5,
-			RepDelta:      2,
-		},
-		{
-			Type:             "ward_lanterns",
-			Name:             "Ward Lanterns",
-			Description:      "Raise luminous wards to dampen rumors and scrying.",
-			CostGold:         7,
-			CostGrain:        1,
-			DurationTicks:    2,
-			WardNetworkTicks: 3,
-		},
-	}
+	return currentContent().Projects
 }
 
 func projectDefinitionByType(projectType string) (ProjectDefinition, bool) {
@@ -5681,6 +6204,15 @@ func projectDefinitionByType(projectType string) (ProjectDefinition, bool) {
 	return ProjectDefinition{}, false
 }
 
+// projectDefinitionFor returns the definition a project was funded under,
+// falling back to current content for projects saved before it was pinned.
+func projectDefinitionFor(proj *Project) (ProjectDefinition, bool) {
+	if proj.Definition != nil {
+		return *proj.Definition, true
+	}
+	return projectDefinitionByType(proj.Type)
+}
+
 func projectEffectNote(def ProjectDefinition) string {
 	parts := make([]string, 0, 4)
 	if def.GrainDelta != 0 {
@@ -5705,56 +6237,7 @@ func projectEffectNote(def ProjectDefinition) string {
 }
 
 func crisisDefinitions() []CrisisDefinition {
-	return []CrisisDefinition{
-		{
-			Type:               "plague",
-			Name:               "Grey Plague",
-			Description:        "Fever grips the wards; healers plead for quarantine supplies.",
-			DurationTicks:      4,
-			BaseSeverity:       2,
-			GoldCost:           4,
-			GrainCost:          1,
-			ResponseLabel:      "Fund Quarantine",
-			TickUnrestDelta:    3,
-			TickGrainDelta:     -4,
-			ResolveRepDelta:    1,
-			ResolveUnrestDelta: 3,
-			FailureUnrestDelta: 6,
-			FailureGrainDelta:  -12,
-		},
-		{
-			Type:               "fire",
-			Name:               "Warehouse Inferno",
-			Description:        "Docks blaze; smoke chokes the market lanes.",
-			DurationTicks:      3,
-			BaseSeverity:       2,
-			GoldCost:           3,
-			GrainCost:          2,
-			ResponseLabel:      "Deploy Bucket Brigade",
-			TickUnrestDelta:    2,
-			TickGrainDelta:     -8,
-			ResolveRepDelta:    1,
-			ResolveUnrestDelta: 2,
-			FailureUnrestDelta: 5,
-			FailureGrainDelta:  -15,
-		},
-		{
-			Type:               "collapse",
-			Name:               "Canal Collapse",
-			Description:        "A canal wall fails; cargo routes grind to a halt.",
-			DurationTicks:      3,
-			BaseSeverity:       3,
-			GoldCost:           6,
-			GrainCost:          0,
-			ResponseLabel:      "Hire Masons",
-			TickUnrestDelta:    3,
-			TickGrainDelta:     -5,
-			ResolveRepDelta:    2,
-			ResolveUnrestDelta: 3,
-			FailureUnrestDelta: 7,
-			FailureGrainDelta:  -10,
-		},
-	}
+	return currentContent().Crises
 }
 
 func crisisDefinitionByType(crisisType string) (CrisisDefinition, bool) {
@@ -5766,6 +6249,15 @@ func crisisDefinitionByType(crisisType string) (CrisisDefinition, bool) {
 	return CrisisDefinition{}, false
 }
 
+// crisisDefinitionFor returns the definition a crisis erupted under, falling
+// back to current content for crises saved before it was pinned.
+func crisisDefinitionFor(crisis *Crisis) (CrisisDefinition, bool) {
+	if crisis.Definition != nil {
+		return *crisis.Definition, true
+	}
+	return crisisDefinitionByType(crisis.Type)
+}
+
 func normalizeContractStance(raw string) string {
 	switch strings.ToLower(strings.TrimSpace(raw)) {
 	case "fast":
@@ -5866,14 +6358,17 @@ func computeDeliverOutcomeLocked(store *Store, p *Player, c *Contract) DeliverOu
 	if p != nil && p.Rumors > 0 {
 		reward += rumorDeliverBonusGold
 	}
+	taxGold := 0
 	if store != nil {
-		reward = reward * (100 - clampInt(store.Policies.TaxRatePct, 0, 40)) / 100
+		taxed := reward * (100 - clampInt(store.Policies.TaxRatePct, 0, 40)) / 100
+		reward, taxGold = taxed, reward-taxed
 		if c != nil && c.Type == "Smuggling" && store.Policies.SmugglingEmbargoTicks > 0 {
 			reward += 6
 		}
 	}
 	return DeliverOutcome{
 		RewardGold: reward,
+		TaxGold:    taxGold,
 		HeatDelta:  heatDelta,
 		RepDelta:   repDelta,
 		Stance:     stance,
@@ -6013,6 +6508,13 @@ func maxInt(a, b int) int {
 	return b
 }
 
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
 func minInt(a, b int) int {
 	if a < b {
 		return a
@@ -154,11 +154,11 @@ func TestDashboardStandingPanelAndStateBasedActions(t *testing.T) {
 		t.Fatalf("issued contract should not show abandon or deliver")
 	}
 
-	s.mu.Lock()
-	s.Contracts["c1"].Status = "Accepted"
-	s.Contracts["c1"].OwnerPlayerID = "p1"
-	s.Contracts["c1"].OwnerName = "Ash Crow (Guest)"
-	s.mu.Unlock()
+	s.write(func() {
+		s.Contracts["c1"].Status = "Accepted"
+		s.Contracts["c1"].OwnerPlayerID = "p1"
+		s.Contracts["c1"].OwnerName = "Ash Crow (Guest)"
+	})
 
 	body = doReq(t, mux, http.MethodGet, "/frag/dashboard", nil, "p1", "127.0.0.1:1111").Body.String()
 	if !strings.Contains(body, ">Abandon<") || !strings.Contains(body, "Deliver (&#43;20g)") {
@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	mathrand "math/rand"
 	"net/http/httptest"
 	"testing"
 	"time"
@@ -10,7 +9,7 @@ import (
 
 func newTestStore() *Store {
 	s := newStore()
-	s.rng = mathrand.New(mathrand.NewSource(1))
+	s.rng = newWorldRNG(13)
 	return s
 }
 
@@ -754,8 +753,8 @@ func TestSupplyContractFlow(t *testing.T) {
 	if contractor.Grain != 1 {
 		t.Fatalf("contractor grain should be consumed, grain=%d", contractor.Grain)
 	}
-	if s.World.GrainSupply != prevSupply+4*grainUnitPerSack {
-		t.Fatalf("world grain supply should increase, got %d want %d", s.World.GrainSupply, prevSupply+4*grainUnitPerSack)
+	if s.World.GrainSupply != prevSupply+4*grainCommodity().PoolPerUnit {
+		t.Fatalf("world grain supply should increase, got %d want %d", s.World.GrainSupply, prevSupply+4*grainCommodity().PoolPerUnit)
 	}
 	if issuer.Rep != 1 {
 		t.Fatalf("issuer should gain reputation, rep=%d", issuer.Rep)
@@ -920,8 +919,15 @@ func TestBountyDeliveryRequiresEvidence(t *testing.T) {
 	if s.Contracts[bountyID].Status != "Completed" {
 		t.Fatalf("bounty should complete after evidence delivery, got %s", s.Contracts[bountyID].Status)
 	}
+	cs := openCaseForLocked(s, target.ID)
+	if cs == nil || len(cs.Exhibits) != 1 || cs.AccuserID != hunter.ID {
+		t.Fatalf("the delivered evidence should go before the court: %+v", cs)
+	}
+	for i := 0; i < courtTrialTicks; i++ {
+		processCourtTickLocked(s, now)
+	}
 	if target.Heat != 7 {
-		t.Fatalf("bounty should reduce target heat, got %d", target.Heat)
+		t.Fatalf("a conviction should reduce target heat, got %d", target.Heat)
 	}
 	if hunter.Gold <= 20 {
 		t.Fatalf("bounty should pay hunter, got %d", hunter.Gold)
@@ -947,7 +953,7 @@ func TestMarketBuySellAndRelief(t *testing.T) {
 	if p.Gold != 20-2*buyPrice {
 		t.Fatalf("gold after buy = %d, want %d", p.Gold, 20-2*buyPrice)
 	}
-	if s.World.GrainSupply != 120-2*grainUnitPerSack {
+	if s.World.GrainSupply != 120-2*grainCommodity().PoolPerUnit {
 		t.Fatalf("grain supply should drop after buy, got %d", s.World.GrainSupply)
 	}
 
@@ -958,7 +964,7 @@ func TestMarketBuySellAndRelief(t *testing.T) {
 	if p.Gold != 20-2*buyPrice+sellPrice {
 		t.Fatalf("gold after sell = %d, want %d", p.Gold, 20-2*buyPrice+sellPrice)
 	}
-	if s.World.GrainSupply != 120-2*grainUnitPerSack+grainUnitPerSack {
+	if s.World.GrainSupply != 120-2*grainCommodity().PoolPerUnit+grainCommodity().PoolPerUnit {
 		t.Fatalf("grain supply should rise after sell, got %d", s.World.GrainSupply)
 	}
 
@@ -1258,12 +1264,10 @@ func TestDeliverIdempotentDoesNotDoubleApply(t *testing.T) {
 	}
 }
 
-func TestSeatElectionChoosesHighestRep(t *testing.T) {
+func TestSeatElectionFallsBackToAppointee(t *testing.T) {
 	s := newTestStore()
 	now := time.Now().UTC()
-	low := &Player{ID: "p1", Name: "Ash Crow (Guest)", Rep: 5, LastSeen: now}
 	high := &Player{ID: "p2", Name: "Bran Vale (Guest)", Rep: 30, LastSeen: now}
-	s.Players[low.ID] = low
 	s.Players[high.ID] = high
 	s.Seats["master_of_coin"].TenureTicksLeft = 1
 
@@ -1274,8 +1278,9 @@ func TestSeatElectionChoosesHighestRep(t *testing.T) {
 
 	runWorldTickLocked(s, now.Add(time.Minute))
 	runWorldTickLocked(s, now.Add(2*time.Minute))
-	if got := s.Seats["master_of_coin"].HolderPlayerID; got != high.ID {
-		t.Fatalf("expected highest rep player to win election, got holder=%q", got)
+	seat := s.Seats["master_of_coin"]
+	if seat.HolderPlayerID != "" || seat.HolderName != seatDefaultHolderName("master_of_coin") || seat.TenureTicksLeft != seatTenureTicks {
+		t.Fatalf("with nobody standing the appointee should keep the seat, got holder=%q", seat.HolderName)
 	}
 }
 
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Market history retention. Every tick is kept for marketHistoryFullTicks;
+// older history keeps one tick in marketHistoryCoarseEvery, with the volume
+// of the ticks it stands for folded in, until marketHistoryTicks have passed.
+const (
+	marketHistoryFullTicks   = 240
+	marketHistoryCoarseEvery = 10
+	marketHistoryTicks       = 2880
+	// marketSparkTicks is how many recent ticks the market panel charts.
+	marketSparkTicks = 48
+)
+
+// MarketTick is every market's closing state for one tick.
+type MarketTick struct {
+	Tick    int64          `json:"tick"`
+	At      time.Time      `json:"at"`
+	Samples []MarketSample `json:"samples"`
+}
+
+// MarketSample is one commodity in one market at the close of a tick.
+// Volume counts units traded with the city or between players since the
+// previous sample.
+type MarketSample struct {
+	Location      string `json:"location"`
+	Commodity     string `json:"commodity"`
+	Tier          string `json:"tier"`
+	BasePrice     int    `json:"base_price"`
+	BuyPrice      int    `json:"buy_price"`
+	SellPrice     int    `json:"sell_price"`
+	Supply        int    `json:"supply"`
+	Volume        int    `json:"volume"`
+	TaxPct        int    `json:"tax_pct"`
+	ControlsTicks int    `json:"controls_ticks"`
+}
+
+// MarketHistoryPoint is one sample of a single series.
+type MarketHistoryPoint struct {
+	Tick          int64     `json:"tick"`
+	At            time.Time `json:"at"`
+	Tier          string    `json:"tier"`
+	BasePrice     int       `json:"base_price"`
+	BuyPrice      int       `json:"buy_price"`
+	SellPrice     int       `json:"sell_price"`
+	Supply        int       `json:"supply"`
+	Volume        int       `json:"volume"`
+	TaxPct        int       `json:"tax_pct"`
+	ControlsTicks int       `json:"controls_ticks"`
+}
+
+// MarketHistorySeries is the history of one commodity in one market.
+type MarketHistorySeries struct {
+	Location  string               `json:"location"`
+	Commodity string               `json:"commodity"`
+	Points    []MarketHistoryPoint `json:"points"`
+}
+
+// recordTradeVolumeLocked counts units traded at locationID toward the next
+// history sample.
+func recordTradeVolumeLocked(store *Store, locationID, commodityID string, units int) {
+	if units <= 0 {
+		return
+	}
+	if store.World.MarketVolume == nil {
+		store.World.MarketVolume = map[string]map[string]int{}
+	}
+	if store.World.MarketVolume[locationID] == nil {
+		store.World.MarketVolume[locationID] = map[string]int{}
+	}
+	store.World.MarketVolume[locationID][commodityID] += units
+}
+
+// recordMarketHistoryLocked samples every market at the close of a tick and
+// applies the retention rules.
+func recordMarketHistoryLocked(store *Store, now time.Time) {
+	entry := MarketTick{Tick: store.TickCount, At: now}
+	for _, loc := range locationDefinitions() {
+		if loc.Market == nil {
+			continue
+		}
+		tax, controls := marketTaxLocked(store, loc.ID)
+		for _, good := range loc.Market.Goods {
+			def, ok := commodityDefinitionByID(good.Commodity)
+			if !ok {
+				continue
+			}
+			base, buy, sell := marketPricesLocked(store, loc.ID, def, good)
+			entry.Samples = append(entry.Samples, MarketSample{
+				Location:      loc.ID,
+				Commodity:     def.ID,
+				Tier:          marketTierLocked(store, loc.ID, def, good),
+				BasePrice:     base,
+				BuyPrice:      buy,
+				SellPrice:     sell,
+				Supply:        marketSupplyLocked(store, loc.ID, good) / def.PoolPerUnit,
+				Volume:        store.World.MarketVolume[loc.ID][def.ID],
+				TaxPct:        tax,
+				ControlsTicks: controls,
+			})
+		}
+	}
+	store.World.MarketVolume = nil
+	store.MarketHistory = append(store.MarketHistory, entry)
+	pruneMarketHistoryLocked(store)
+}
+
+// pruneMarketHistoryLocked drops history past retention and thins the ticks
+// past the full-resolution window, carrying each dropped tick's volume into
+// the next tick that is kept.
+func pruneMarketHistoryLocked(store *Store) {
+	kept := store.MarketHistory[:0]
+	carry := map[string]int{}
+	for _, entry := range store.MarketHistory {
+		age := store.TickCount - entry.Tick
+		if age >= marketHistoryTicks {
+			continue
+		}
+		if age >= marketHistoryFullTicks && entry.Tick%marketHistoryCoarseEvery != 0 {
+			for _, sample := range entry.Samples {
+				carry[sample.Location+"/"+sample.Commodity] += sample.Volume
+			}
+			continue
+		}
+		if len(carry) > 0 {
+			samples := make([]MarketSample, len(entry.Samples))
+			copy(samples, entry.Samples)
+			for i := range samples {
+				key := samples[i].Location + "/" + samples[i].Commodity
+				samples[i].Volume += carry[key]
+				delete(carry, key)
+			}
+			entry.Samples = samples
+		}
+		kept = append(kept, entry)
+	}
+	store.MarketHistory = kept
+}
+
+// marketHistorySeriesLocked returns the series for commodityID at
+// locationID over at most the last ticks ticks, oldest first.
+func marketHistorySeriesLocked(store *Store, locationID, commodityID string, ticks int64) MarketHistorySeries {
+	series := MarketHistorySeries{Location: locationID, Commodity: commodityID, Points: []MarketHistoryPoint{}}
+	for _, entry := range store.MarketHistory {
+		if ticks > 0 && store.TickCount-entry.Tick >= ticks {
+			continue
+		}
+		for _, s := range entry.Samples {
+			if s.Location != locationID || s.Commodity != commodityID {
+				continue
+			}
+			series.Points = append(series.Points, MarketHistoryPoint{
+				Tick:          entry.Tick,
+				At:            entry.At,
+				Tier:          s.Tier,
+				BasePrice:     s.BasePrice,
+				BuyPrice:      s.BuyPrice,
+				SellPrice:     s.SellPrice,
+				Supply:        s.Supply,
+				Volume:        s.Volume,
+				TaxPct:        s.TaxPct,
+				ControlsTicks: s.ControlsTicks,
+			})
+		}
+	}
+	return series
+}
+
+// sparklinePoints scales values into an SVG polyline of width by height,
+// or "" when there are fewer than two values to draw.
+func sparklinePoints(values []int, width, height int) string {
+	if len(values) < 2 {
+		return ""
+	}
+	lo, hi := minMaxInts(values)
+	parts := make([]string, len(values))
+	for i, v := range values {
+		x := i * width / (len(values) - 1)
+		y := height / 2
+		if hi > lo {
+			y = height - (v-lo)*height/(hi-lo)
+		}
+		parts[i] = fmt.Sprintf("%d,%d", x, y)
+	}
+	return strings.Join(parts, " ")
+}
+
+// MarketHistoryView is one commodity's recent history as the market panel
+// charts it.
+type MarketHistoryView struct {
+	Name         string
+	PriceLow     int
+	PriceHigh    int
+	PricePoints  string
+	SupplyLow    int
+	SupplyHigh   int
+	SupplyPoints string
+	Volume       int
+	Ticks        int
+}
+
+// marketHistoryViewsLocked charts the recent history of every good traded
+// where p stands.
+func marketHistoryViewsLocked(store *Store, p *Player) []MarketHistoryView {
+	locationID := marketLocationID(p)
+	views := []MarketHistoryView{}
+	for _, def := range commodityDefinitions() {
+		if _, ok := localGood(locationID, def.ID); !ok {
+			continue
+		}
+		series := marketHistorySeriesLocked(store, locationID, def.ID, marketSparkTicks)
+		if len(series.Points) == 0 {
+			continue
+		}
+		view := MarketHistoryView{Name: def.Name, Ticks: len(series.Points)}
+		prices := make([]int, len(series.Points))
+		supplies := make([]int, len(series.Points))
+		for i, pt := range series.Points {
+			prices[i], supplies[i] = pt.BasePrice, pt.Supply
+			view.Volume += pt.Volume
+		}
+		view.PriceLow, view.PriceHigh = minMaxInts(prices)
+		view.SupplyLow, view.SupplyHigh = minMaxInts(supplies)
+		view.PricePoints = sparklinePoints(prices, 120, 24)
+		view.SupplyPoints = sparklinePoints(supplies, 120, 24)
+		views = append(views, view)
+	}
+	return views
+}
+
+func minMaxInts(values []int) (lo, hi int) {
+	for i, v := range values {
+		if i == 0 || v < lo {
+			lo = v
+		}
+		if i == 0 || v > hi {
+			hi = v
+		}
+	}
+	return lo, hi
+}
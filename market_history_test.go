@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarketHistorySamplesEveryTick(t *testing.T) {
+	s := newTestStore()
+	mux := newMux(s, parseTemplates())
+	now := time.Now().UTC()
+	doReq(t, mux, http.MethodGet, "/", nil, "bot", "")
+	p := s.Players["bot"]
+	p.Gold = 100
+	s.MarketHistory = nil
+
+	handleActionInputLocked(s, p, now, ActionInput{Action: "buy", Commodity: "timber", Amount: 3})
+	runWorldTickLocked(s, now)
+	s.World.RestrictedMarketsTicks = 3
+	runWorldTickLocked(s, now.Add(time.Minute))
+
+	series := marketHistorySeriesLocked(s, locationCapital, "timber", 0)
+	if len(series.Points) != 2 {
+		t.Fatalf("expected a timber sample per tick, got %+v", series.Points)
+	}
+	first, second := series.Points[0], series.Points[1]
+	if first.Volume != 3 || second.Volume != 0 || first.Tick != s.TickCount-1 {
+		t.Fatalf("volume should be counted in the tick it traded: %+v", series.Points)
+	}
+	if second.ControlsTicks == 0 || second.BuyPrice <= first.BuyPrice {
+		t.Fatalf("controls should show in the sample and its prices: %+v", series.Points)
+	}
+	if harbor := marketHistorySeriesLocked(s, locationHarbor, "salt", 0); len(harbor.Points) != 2 || harbor.Points[1].TaxPct != 5 || harbor.Points[1].ControlsTicks != 0 {
+		t.Fatalf("harbor samples should carry local dues: %+v", harbor.Points)
+	}
+
+	s.flush()
+	market := doReq(t, mux, http.MethodGet, "/frag/market", nil, "bot", "").Body.String()
+	if !strings.Contains(market, "<polyline") || !strings.Contains(market, `points="0,`) {
+		t.Fatalf("market panel should chart recent prices:\n%s", market)
+	}
+}
+
+func TestMarketHistoryRetentionThinsAndExpires(t *testing.T) {
+	s := newTestStore()
+	for tick := int64(1); tick <= 3000; tick++ {
+		s.MarketHistory = append(s.MarketHistory, MarketTick{Tick: tick, Samples: []MarketSample{{Location: locationCapital, Commodity: commodityGrain, Volume: 1}}})
+	}
+	s.TickCount = 3000
+	pruneMarketHistoryLocked(s)
+
+	oldest := s.MarketHistory[0]
+	if age := s.TickCount - oldest.Tick; age >= marketHistoryTicks || oldest.Tick%marketHistoryCoarseEvery != 0 {
+		t.Fatalf("oldest kept tick = %d", oldest.Tick)
+	}
+	for i, entry := range s.MarketHistory[1:] {
+		full := s.TickCount-entry.Tick < marketHistoryFullTicks
+		if !full && entry.Tick%marketHistoryCoarseEvery != 0 {
+			t.Fatalf("coarse history should keep one tick in %d, found %d", marketHistoryCoarseEvery, entry.Tick)
+		}
+		if !full && entry.Samples[0].Volume != marketHistoryCoarseEvery {
+			t.Fatalf("tick %d should carry the volume of the ticks before it, got %d", entry.Tick, entry.Samples[0].Volume)
+		}
+		if full && entry.Tick != s.MarketHistory[i].Tick+1 && s.TickCount-s.MarketHistory[i].Tick < marketHistoryFullTicks {
+			t.Fatalf("recent history should keep every tick: %d after %d", entry.Tick, s.MarketHistory[i].Tick)
+		}
+	}
+	if n := len(s.MarketHistory); n != marketHistoryFullTicks+(marketHistoryTicks-marketHistoryFullTicks)/marketHistoryCoarseEvery {
+		t.Fatalf("kept %d ticks", n)
+	}
+}
+
+func TestAPIMarketHistory(t *testing.T) {
+	s := newTestStore()
+	mux := newMux(s, parseTemplates())
+	doReq(t, mux, http.MethodGet, "/", nil, "bot", "")
+	var issued apiTokenResponse
+	_ = json.Unmarshal(doReq(t, mux, http.MethodPost, "/api/v1/token", nil, "bot", "").Body.Bytes(), &issued)
+	now := time.Now().UTC()
+	for i := 0; i < 3; i++ {
+		runWorldTickLocked(s, now.Add(time.Duration(i)*time.Minute))
+	}
+
+	s.flush()
+	rr := doAPIReq(t, mux, http.MethodGet, "/api/v1/markets/history?location=frontier&commodity=iron_ore&ticks=2", issued.Token, "")
+	var got apiMarketHistory
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("history: %d %s", rr.Code, rr.Body.String())
+	}
+	if got.Location != locationFrontier || len(got.Series) != 1 || len(got.Series[0].Points) != 2 || got.Series[0].Points[1].Supply == 0 {
+		t.Fatalf("expected the last two iron ore samples: %+v", got)
+	}
+
+	rr = doAPIReq(t, mux, http.MethodGet, "/api/v1/markets/history", issued.Token, "")
+	_ = json.Unmarshal(rr.Body.Bytes(), &got)
+	if got.Location != locationCapital || len(got.Series) != 7 || int64(len(got.Series[0].Points)) != s.TickCount {
+		t.Fatalf("default history should cover the player's market: %+v", got)
+	}
+	if rr := doAPIReq(t, mux, http.MethodGet, "/api/v1/markets/history?location=moon", issued.Token, ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("unknown location: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := doAPIReq(t, mux, http.MethodGet, "/api/v1/markets/history?ticks=-1", issued.Token, ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("bad ticks: %d %s", rr.Code, rr.Body.String())
+	}
+}
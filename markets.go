@@ -0,0 +1,345 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Market jurisdictions. A city market follows the city's tax policy and
+// market controls; a local market levies its own flat dues and ignores both.
+const (
+	marketJurisdictionCity  = "city"
+	marketJurisdictionLocal = "local"
+)
+
+// LocationMarket is what a location trades and on whose terms.
+type LocationMarket struct {
+	Jurisdiction string `json:"jurisdiction"`
+	// TaxPct is the local dues rate; city markets use the tax policy.
+	TaxPct int `json:"tax_pct"`
+	// Treasury is the institution local dues are paid to; city markets pay
+	// the City Authority. Dues with no treasury stay with local lords.
+	Treasury string      `json:"treasury,omitempty"`
+	Goods    []LocalGood `json:"goods"`
+}
+
+// LocalGood is one commodity as traded at one location.
+type LocalGood struct {
+	Commodity string `json:"commodity"`
+	// Stock is the opening pool and the level imports pull back toward.
+	Stock int `json:"stock"`
+	// MaxStock caps the pool; zero leaves it uncapped.
+	MaxStock int `json:"max_stock"`
+	// Production and Consumption move the pool every tick. Capital grain's
+	// drain, spoilage and windfalls are rolled by the world tick on top.
+	Production  int `json:"production"`
+	Consumption int `json:"consumption"`
+	// ImportPct is the share of the gap to Stock that traders close each
+	// tick, shipping in when short and out when glutted.
+	ImportPct int `json:"import_pct"`
+	// PricePct scales the commodity's base prices here.
+	PricePct int `json:"price_pct"`
+}
+
+// marketLocationID is where p trades. Players saved before locations existed
+// are in the capital.
+func marketLocationID(p *Player) string {
+	if p.LocationID == "" {
+		return locationCapital
+	}
+	return p.LocationID
+}
+
+func locationMarket(locationID string) (*LocationMarket, bool) {
+	def, ok := locationByID(locationID)
+	if !ok || def.Market == nil {
+		return nil, false
+	}
+	return def.Market, true
+}
+
+func localGood(locationID, commodityID string) (LocalGood, bool) {
+	m, ok := locationMarket(locationID)
+	if !ok {
+		return LocalGood{}, false
+	}
+	for _, g := range m.Goods {
+		if g.Commodity == commodityID {
+			return g, true
+		}
+	}
+	return LocalGood{}, false
+}
+
+// isCityGranary reports whether a pool is the capital's grain, which lives on
+// World.GrainSupply because unrest, contracts and crises all read it.
+func isCityGranary(locationID, commodityID string) bool {
+	return locationID == locationCapital && commodityID == commodityGrain
+}
+
+// marketSupplyLocked is the pool of good at locationID. A pool not yet
+// touched, because the world began before content added it, is at its stock.
+func marketSupplyLocked(store *Store, locationID string, good LocalGood) int {
+	if isCityGranary(locationID, good.Commodity) {
+		return store.World.GrainSupply
+	}
+	if v, ok := store.World.Markets[locationID][good.Commodity]; ok {
+		return v
+	}
+	return good.Stock
+}
+
+// localTier places supply in def's tiers scaled to the local stock level.
+func localTier(def CommodityDefinition, good LocalGood, supply int) string {
+	scaled := def
+	if def.ReferenceStock > 0 {
+		scale := func(v int) int { return v * good.Stock / def.ReferenceStock }
+		scaled.Tiers = CommodityTiers{Tight: scale(def.Tiers.Tight), Scarce: scale(def.Tiers.Scarce), Critical: scale(def.Tiers.Critical)}
+	}
+	return commodityTier(scaled, supply)
+}
+
+// marketTierLocked is the current tier of good. The city granary's tier is
+// kept on the world so the rest of the tick can compare against it.
+func marketTierLocked(store *Store, locationID string, def CommodityDefinition, good LocalGood) string {
+	if isCityGranary(locationID, def.ID) {
+		return store.World.GrainTier
+	}
+	return localTier(def, good, marketSupplyLocked(store, locationID, good))
+}
+
+// applyMarketSupplyDeltaLocked moves a pool and reports a tier change.
+func applyMarketSupplyDeltaLocked(store *Store, now time.Time, locationID string, def CommodityDefinition, good LocalGood, delta int) {
+	if delta == 0 {
+		return
+	}
+	if isCityGranary(locationID, def.ID) {
+		applyGrainSupplyDeltaLocked(store, now, delta)
+		return
+	}
+	prev := marketSupplyLocked(store, locationID, good)
+	next := maxInt(0, prev+delta)
+	if good.MaxStock > 0 {
+		next = minInt(next, good.MaxStock)
+	}
+	if store.World.Markets == nil {
+		store.World.Markets = map[string]map[string]int{}
+	}
+	if store.World.Markets[locationID] == nil {
+		store.World.Markets[locationID] = map[string]int{}
+	}
+	store.World.Markets[locationID][def.ID] = next
+	if from, to := localTier(def, good, prev), localTier(def, good, next); from != to {
+		addEventLocked(store, Event{Type: "Market", Severity: 2, Text: marketTierNarrative(def.Name, locationName(locationID), from, to), At: now})
+	}
+}
+
+func marketTierNarrative(name, place, from, to string) string {
+	easing := slices.Index(commodityTierOrder, to) < slices.Index(commodityTierOrder, from)
+	switch {
+	case to == "Stable":
+		return fmt.Sprintf("%s is plentiful again in %s.", name, place)
+	case to == "Critical":
+		return fmt.Sprintf("%s has all but vanished from the stalls of %s.", name, place)
+	case easing:
+		return fmt.Sprintf("%s shipments reach %s; supply eases to %s.", name, place, strings.ToLower(to))
+	default:
+		return fmt.Sprintf("%s runs %s in %s.", name, strings.ToLower(to), place)
+	}
+}
+
+// localGoodDrift is how far a pool moves in one tick from local production
+// and consumption plus imports toward the stock level.
+func localGoodDrift(good LocalGood, supply int) int {
+	delta := good.Production - good.Consumption
+	if gap := good.Stock - (supply + delta); gap != 0 && good.ImportPct > 0 {
+		step := gap * good.ImportPct / 100
+		if step == 0 {
+			step = 1
+			if gap < 0 {
+				step = -1
+			}
+		}
+		delta += step
+	}
+	return delta
+}
+
+// processMarketTickLocked moves every local pool, with the frontier's
+// production following the season's harvest. The city granary is drifted
+// inside the world tick so its tier change is reported once with the rest of
+// the grain roll.
+func processMarketTickLocked(store *Store, now time.Time) {
+	for _, loc := range locationDefinitions() {
+		if loc.Market == nil {
+			continue
+		}
+		for _, good := range loc.Market.Goods {
+			def, ok := commodityDefinitionByID(good.Commodity)
+			if !ok || isCityGranary(loc.ID, good.Commodity) {
+				continue
+			}
+			if loc.ID == locationFrontier {
+				good.Production = good.Production * currentSeason(store.World).HarvestPct / 100
+			}
+			drift := localGoodDrift(good, marketSupplyLocked(store, loc.ID, good))
+			applyMarketSupplyDeltaLocked(store, now, loc.ID, def, good, drift)
+		}
+	}
+}
+
+// marketTaxLocked returns the tax rate and market-control ticks that apply at
+// locationID.
+func marketTaxLocked(store *Store, locationID string) (taxPct, controlsTicks int) {
+	m, ok := locationMarket(locationID)
+	if ok && m.Jurisdiction == marketJurisdictionLocal {
+		return m.TaxPct, 0
+	}
+	return store.Policies.TaxRatePct, store.World.RestrictedMarketsTicks
+}
+
+// marketPricesLocked returns the base, buy and sell price of one unit of good
+// at locationID.
+func marketPricesLocked(store *Store, locationID string, def CommodityDefinition, good LocalGood) (base, buy, sell int) {
+	pct := good.PricePct * seasonPricePct(store.World, def.ID) / 100
+	base = maxInt(1, (commodityBasePrice(def, marketTierLocked(store, locationID, def, good))*pct+50)/100)
+	tax, controls := marketTaxLocked(store, locationID)
+	return base, marketBuyPrice(base, tax, controls), marketSellPrice(base, tax, controls)
+}
+
+// marketTaxNote describes who taxes trade at locationID.
+func marketTaxNote(store *Store, locationID string) string {
+	m, ok := locationMarket(locationID)
+	if !ok {
+		return ""
+	}
+	if m.Jurisdiction == marketJurisdictionLocal {
+		return fmt.Sprintf("Local dues %d%%", m.TaxPct)
+	}
+	return fmt.Sprintf("City tax %d%%", store.Policies.TaxRatePct)
+}
+
+// marketCommodityFor resolves which commodity a buy or sell names. The
+// grain verbs predate the other commodities and always mean grain.
+func marketCommodityFor(in ActionInput) (CommodityDefinition, bool) {
+	id := strings.TrimSpace(in.Commodity)
+	if id == "" || in.Action == "buy_grain" || in.Action == "sell_grain" {
+		id = commodityGrain
+	}
+	return commodityDefinitionByID(id)
+}
+
+// tradedHereLocked finds def in the market where p stands, refusing the
+// request when it is not traded there.
+func tradedHereLocked(store *Store, p *Player, def CommodityDefinition) (string, LocalGood, bool) {
+	locationID := marketLocationID(p)
+	good, ok := localGood(locationID, def.ID)
+	if !ok {
+		rejectLocked(store, p.ID, errCodeNotFound, fmt.Sprintf("%s is not traded in %s.", def.Name, locationName(locationID)))
+		return "", LocalGood{}, false
+	}
+	return locationID, good, true
+}
+
+func buyCommodityLocked(store *Store, p *Player, now time.Time, def CommodityDefinition, amount int) {
+	locationID, good, ok := tradedHereLocked(store, p, def)
+	if !ok {
+		return
+	}
+	_, buyPrice, _ := marketPricesLocked(store, locationID, def, good)
+	supply := marketSupplyLocked(store, locationID, good) / def.PoolPerUnit
+	if supply <= 0 {
+		rejectLocked(store, p.ID, errCodeNotFound, "Market stalls are empty.")
+		return
+	}
+	if amount > supply {
+		rejectLocked(store, p.ID, errCodeInvalidInput, fmt.Sprintf("Market can only supply %d %s.", supply, def.Unit))
+		return
+	}
+	totalCost := amount * buyPrice
+	if p.Gold < totalCost {
+		rejectLocked(store, p.ID, errCodeInsufficientGold, fmt.Sprintf("Need %dg to buy %d %s.", totalCost, amount, def.Unit))
+		return
+	}
+	buyTax, _ := marketTaxPerUnitLocked(store, locationID, def, good)
+	p.Gold -= totalCost
+	collectMarketTaxLocked(store, now, locationID, amount*buyTax, p)
+	addHolding(p, def.ID, amount)
+	applyMarketSupplyDeltaLocked(store, now, locationID, def, good, -amount*def.PoolPerUnit)
+	recordTradeVolumeLocked(store, locationID, def.ID, amount)
+	addEventLocked(store, Event{Type: "Market", Severity: 1, Text: fmt.Sprintf("[%s] buys %d %s from the market.", p.Name, amount, def.Unit), At: now})
+	setToastLocked(store, p.ID, fmt.Sprintf("Bought %d %s for %dg.", amount, def.Unit, totalCost))
+}
+
+func sellCommodityLocked(store *Store, p *Player, now time.Time, def CommodityDefinition, amount int) {
+	locationID, good, ok := tradedHereLocked(store, p, def)
+	if !ok {
+		return
+	}
+	if held := holdingOf(p, def.ID); held < amount {
+		rejectLocked(store, p.ID, insufficientCodeFor(def.ID), fmt.Sprintf("You only hold %d %s.", held, def.Unit))
+		return
+	}
+	_, _, sellPrice := marketPricesLocked(store, locationID, def, good)
+	_, sellTax := marketTaxPerUnitLocked(store, locationID, def, good)
+	totalGain := amount * sellPrice
+	addHolding(p, def.ID, -amount)
+	p.Gold += totalGain
+	collectMarketTaxLocked(store, now, locationID, amount*sellTax, p)
+	applyMarketSupplyDeltaLocked(store, now, locationID, def, good, amount*def.PoolPerUnit)
+	recordTradeVolumeLocked(store, locationID, def.ID, amount)
+	addEventLocked(store, Event{Type: "Market", Severity: 1, Text: fmt.Sprintf("[%s] sells %d %s into the market.", p.Name, amount, def.Unit), At: now})
+	setToastLocked(store, p.ID, fmt.Sprintf("Sold %d %s for %dg.", amount, def.Unit, totalGain))
+}
+
+// commodityViewsLocked lists what is traded where p stands, in registry
+// order.
+func commodityViewsLocked(store *Store, p *Player) []CommodityView {
+	locationID := marketLocationID(p)
+	defs := commodityDefinitions()
+	views := make([]CommodityView, 0, len(defs))
+	for _, def := range defs {
+		good, ok := localGood(locationID, def.ID)
+		if !ok {
+			continue
+		}
+		base, buy, sell := marketPricesLocked(store, locationID, def, good)
+		supply := marketSupplyLocked(store, locationID, good) / def.PoolPerUnit
+		held := holdingOf(p, def.ID)
+		maxBuy := maxInt(0, minInt(supply, p.Gold/buy))
+		maxSell := maxInt(0, held)
+		views = append(views, CommodityView{
+			ID:           def.ID,
+			Name:         def.Name,
+			Unit:         def.Unit,
+			Tier:         marketTierLocked(store, locationID, def, good),
+			BasePrice:    base,
+			BuyPrice:     buy,
+			SellPrice:    sell,
+			Supply:       supply,
+			Held:         held,
+			MaxBuy:       maxBuy,
+			MaxSell:      maxSell,
+			BuyDisabled:  maxBuy <= 0,
+			SellDisabled: maxSell <= 0,
+			Bids:         orderBookLevelsLocked(store, locationID, def.ID, orderSideBid),
+			Asks:         orderBookLevelsLocked(store, locationID, def.ID, orderSideAsk),
+		})
+	}
+	return views
+}
+
+// cloneMarkets copies the per-location pools so a page does not alias them.
+func cloneMarkets(markets map[string]map[string]int) map[string]map[string]int {
+	if markets == nil {
+		return nil
+	}
+	out := make(map[string]map[string]int, len(markets))
+	for id, pools := range markets {
+		out[id] = maps.Clone(pools)
+	}
+	return out
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalMarketsPriceAndStockIndependently(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	p := &Player{ID: "p1", Name: "Ash Crow (Guest)", Gold: 200, LocationID: locationFrontier, LastSeen: now}
+	s.Players[p.ID] = p
+	timber, _ := commodityDefinitionByID("timber")
+	frontier, _ := localGood(locationFrontier, "timber")
+	capital, _ := localGood(locationCapital, "timber")
+
+	_, frontierBuy, _ := marketPricesLocked(s, locationFrontier, timber, frontier)
+	_, _, capitalSell := marketPricesLocked(s, locationCapital, timber, capital)
+	if capitalSell <= frontierBuy {
+		t.Fatalf("frontier timber should be worth hauling: buy %dg there, sell %dg in the capital", frontierBuy, capitalSell)
+	}
+
+	capitalBefore := marketSupplyLocked(s, locationCapital, capital)
+	handleActionInputLocked(s, p, now, ActionInput{Action: "buy", Commodity: "timber", Amount: 5})
+	if holdingOf(p, "timber") != 5 || p.Gold != 200-5*frontierBuy {
+		t.Fatalf("frontier buy: timber %d gold %d", holdingOf(p, "timber"), p.Gold)
+	}
+	if got := marketSupplyLocked(s, locationFrontier, frontier); got != frontier.Stock-5*timber.PoolPerUnit {
+		t.Fatalf("frontier pool = %d, want %d", got, frontier.Stock-5*timber.PoolPerUnit)
+	}
+	if got := marketSupplyLocked(s, locationCapital, capital); got != capitalBefore {
+		t.Fatalf("buying at the frontier must not touch the capital pool, got %d", got)
+	}
+
+	p.LocationID = locationCapital
+	handleActionInputLocked(s, p, now, ActionInput{Action: "sell", Commodity: "timber", Amount: 5})
+	if p.Gold != 200-5*frontierBuy+5*capitalSell {
+		t.Fatalf("capital sell: gold %d", p.Gold)
+	}
+
+	p.LocationID = locationRuins
+	handleActionInputLocked(s, p, now, ActionInput{Action: "buy", Commodity: "timber", Amount: 1})
+	if s.rejections[p.ID] != errCodeNotFound || !strings.Contains(s.ToastByPlayer[p.ID], "not traded in Haunted Ruins") {
+		t.Fatalf("timber is not sold in the ruins: %q %q", s.rejections[p.ID], s.ToastByPlayer[p.ID])
+	}
+	data := buildPageDataLocked(s, p.ID, false)
+	for _, c := range data.Commodities {
+		if _, ok := localGood(locationRuins, c.ID); !ok {
+			t.Fatalf("ruins market lists %s, which it does not trade", c.ID)
+		}
+	}
+	if len(data.Commodities) == 0 || data.MarketTaxNote != "Local dues 0%" {
+		t.Fatalf("ruins market view: %+v %q", data.Commodities, data.MarketTaxNote)
+	}
+}
+
+func TestMarketJurisdictionsTaxSeparately(t *testing.T) {
+	s := newTestStore()
+	grain := grainCommodity()
+	capital, _ := localGood(locationCapital, commodityGrain)
+	harbor, _ := localGood(locationHarbor, commodityGrain)
+	_, capitalBuy, _ := marketPricesLocked(s, locationCapital, grain, capital)
+	_, harborBuy, _ := marketPricesLocked(s, locationHarbor, grain, harbor)
+
+	s.Policies.TaxRatePct = 100
+	s.World.RestrictedMarketsTicks = 2
+	if _, buy, _ := marketPricesLocked(s, locationCapital, grain, capital); buy <= capitalBuy {
+		t.Fatalf("city tax and controls should raise capital prices: %d -> %d", capitalBuy, buy)
+	}
+	if _, buy, _ := marketPricesLocked(s, locationHarbor, grain, harbor); buy != harborBuy {
+		t.Fatalf("harbor dues ignore city policy: %d -> %d", harborBuy, buy)
+	}
+	if tax, controls := marketTaxLocked(s, locationHarbor); tax != 5 || controls != 0 {
+		t.Fatalf("harbor jurisdiction = %d%% with %d control ticks", tax, controls)
+	}
+}
+
+func TestLocalMarketsDriftWithProductionAndImports(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	grain := grainCommodity()
+	harbor, _ := localGood(locationHarbor, commodityGrain)
+
+	critical := grain.Tiers.Critical * harbor.Stock / grain.ReferenceStock
+	s.World.Markets = map[string]map[string]int{locationHarbor: {commodityGrain: critical}}
+	processMarketTickLocked(s, now)
+	after := marketSupplyLocked(s, locationHarbor, harbor)
+	if after <= critical || after >= harbor.Stock {
+		t.Fatalf("imports should refill a bare harbor part way toward %d, got %d", harbor.Stock, after)
+	}
+	if last := s.Events[len(s.Events)-1]; !strings.Contains(last.Text, "Harbor Ward") {
+		t.Fatalf("a local tier change should name the location, got %q", last.Text)
+	}
+	if got := localTier(grain, harbor, harbor.Stock); got != "Stable" {
+		t.Fatalf("a market at its stock level should be stable, got %s", got)
+	}
+
+	frontier, _ := localGood(locationFrontier, "iron_ore")
+	if d := localGoodDrift(frontier, frontier.Stock); d <= 0 || d >= frontier.Production-frontier.Consumption {
+		t.Fatalf("at stock, the mines' surplus should grow the pool less exports, got %+d", d)
+	}
+	if d := localGoodDrift(frontier, frontier.MaxStock*2); d >= 0 {
+		t.Fatalf("a glutted market should ship goods out, got %+d", d)
+	}
+
+	supply := s.World.GrainSupply
+	runWorldTickLocked(s, now)
+	if s.World.Markets[locationCapital][commodityGrain] != 0 || s.World.GrainSupply == supply {
+		t.Fatalf("capital grain must stay on the world granary")
+	}
+}
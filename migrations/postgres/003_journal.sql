@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS journal (
+    id BIGINT PRIMARY KEY,
+    tick BIGINT NOT NULL,
+    at_ts TIMESTAMPTZ NOT NULL,
+    kind TEXT NOT NULL,
+    player_id TEXT,
+    payload JSONB NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS snapshots (
+    id BIGINT PRIMARY KEY,
+    tick BIGINT NOT NULL,
+    journal_id BIGINT NOT NULL,
+    at_ts TIMESTAMPTZ NOT NULL,
+    payload JSONB NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_journal_tick ON journal(tick);
+CREATE INDEX IF NOT EXISTS idx_journal_player ON journal(player_id);
+CREATE INDEX IF NOT EXISTS idx_snapshots_tick ON snapshots(tick, journal_id);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS market_orders (
+    id TEXT PRIMARY KEY,
+    player_id TEXT NOT NULL,
+    expires_tick BIGINT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_market_orders_player ON market_orders(player_id);
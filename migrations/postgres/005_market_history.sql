@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS market_history (
+    tick BIGINT PRIMARY KEY,
+    at_ts TIMESTAMPTZ NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
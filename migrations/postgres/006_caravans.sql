@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS caravans (
+    id TEXT PRIMARY KEY,
+    owner_player_id TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_caravans_owner ON caravans(owner_player_id);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS treasury_ledger (
+    id BIGINT PRIMARY KEY,
+    institution_id TEXT NOT NULL,
+    at_ts TIMESTAMPTZ NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_treasury_ledger_institution ON treasury_ledger(institution_id);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS court_cases (
+    id TEXT PRIMARY KEY,
+    defendant_player_id TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_court_cases_defendant ON court_cases(defendant_player_id);
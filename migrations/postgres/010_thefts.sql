@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS theft_clues (
+    id TEXT PRIMARY KEY,
+    victim_player_id TEXT NOT NULL,
+    expiry_tick BIGINT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_theft_clues_victim ON theft_clues(victim_player_id);
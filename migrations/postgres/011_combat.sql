@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS fights (
+    id TEXT PRIMARY KEY,
+    location_id TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,2 @@
+ALTER TABLE players ADD COLUMN payload_version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE contracts ADD COLUMN payload_version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS journal (
+    id INTEGER PRIMARY KEY,
+    tick INTEGER NOT NULL,
+    at_ts TIMESTAMP NOT NULL,
+    kind TEXT NOT NULL,
+    player_id TEXT,
+    payload TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS snapshots (
+    id INTEGER PRIMARY KEY,
+    tick INTEGER NOT NULL,
+    journal_id INTEGER NOT NULL,
+    at_ts TIMESTAMP NOT NULL,
+    payload TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_journal_tick ON journal(tick);
+CREATE INDEX IF NOT EXISTS idx_journal_player ON journal(player_id);
+CREATE INDEX IF NOT EXISTS idx_snapshots_tick ON snapshots(tick, journal_id);
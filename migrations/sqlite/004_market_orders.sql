@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS market_orders (
+    id TEXT PRIMARY KEY,
+    player_id TEXT NOT NULL,
+    expires_tick INTEGER NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_market_orders_player ON market_orders(player_id);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS market_history (
+    tick INTEGER PRIMARY KEY,
+    at_ts TIMESTAMP NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
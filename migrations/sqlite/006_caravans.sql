@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS caravans (
+    id TEXT PRIMARY KEY,
+    owner_player_id TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_caravans_owner ON caravans(owner_player_id);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS treasury_ledger (
+    id INTEGER PRIMARY KEY,
+    institution_id TEXT NOT NULL,
+    at_ts TIMESTAMP NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_treasury_ledger_institution ON treasury_ledger(institution_id);
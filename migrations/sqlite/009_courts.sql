@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS court_cases (
+    id TEXT PRIMARY KEY,
+    defendant_player_id TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_court_cases_defendant ON court_cases(defendant_player_id);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS theft_clues (
+    id TEXT PRIMARY KEY,
+    victim_player_id TEXT NOT NULL,
+    expiry_tick INTEGER NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_theft_clues_victim ON theft_clues(victim_player_id);
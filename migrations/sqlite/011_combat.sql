@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS fights (
+    id TEXT PRIMARY KEY,
+    location_id TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,256 @@
+package main
+
+import (
+	mathrand "math/rand"
+	"time"
+)
+
+const (
+	npcRoleMerchant  = "merchant"
+	npcRoleInformant = "informant"
+	npcRoleSmuggler  = "smuggler"
+	npcRoleWatch     = "watch"
+
+	// npcPopulationTarget is the population NPC characters fill in for: each
+	// human online past the first retires one, last on the roster first.
+	npcPopulationTarget = 5
+	// npcActPct is the chance an active NPC acts on a given tick.
+	npcActPct = 60
+	// npcLoanPrincipal is what the merchant offers a struggling player.
+	npcLoanPrincipal = 10
+)
+
+// npcCharacter is one of the city's scripted characters. They hold ordinary
+// Player records and act through submitActionLocked like anyone else.
+type npcCharacter struct {
+	ID   string
+	Name string
+	Role string
+	Gold int
+}
+
+var npcRoster = []npcCharacter{
+	{ID: "npc-merchant", Name: "Oda Brisk (NPC)", Role: npcRoleMerchant, Gold: 60},
+	{ID: "npc-watch", Name: "Sergeant Pell (NPC)", Role: npcRoleWatch, Gold: 20},
+	{ID: "npc-informant", Name: "Wren Tallow (NPC)", Role: npcRoleInformant, Gold: 20},
+	{ID: "npc-smuggler", Name: "Ivo Slate (NPC)", Role: npcRoleSmuggler, Gold: 25},
+}
+
+var npcPolicies = map[string]simPolicy{
+	npcRoleMerchant:  npcMerchantPolicy{},
+	npcRoleInformant: npcInformantPolicy{},
+	npcRoleSmuggler:  smugglerPolicy{},
+	npcRoleWatch:     npcWatchPolicy{},
+}
+
+func npcCharacterByID(id string) (npcCharacter, bool) {
+	for _, ch := range npcRoster {
+		if ch.ID == id {
+			return ch, true
+		}
+	}
+	return npcCharacter{}, false
+}
+
+func npcRoleLabel(role string) string {
+	switch role {
+	case npcRoleMerchant:
+		return "Merchant"
+	case npcRoleInformant:
+		return "Informant"
+	case npcRoleSmuggler:
+		return "Smuggler"
+	case npcRoleWatch:
+		return "Watch Officer"
+	}
+	return ""
+}
+
+// humansOnlineLocked counts the online players who are not NPCs.
+func humansOnlineLocked(store *Store, now time.Time) int {
+	n := 0
+	for _, p := range store.Players {
+		if p.NPCRole == "" && now.Sub(lastSeenLocked(store, p)) <= onlineWindow {
+			n++
+		}
+	}
+	return n
+}
+
+// activeNPCCountLocked is how many of the roster play this tick: they step
+// aside one by one as humans arrive.
+func activeNPCCountLocked(store *Store, now time.Time) int {
+	return clampInt(npcPopulationTarget-humansOnlineLocked(store, now), 0, len(npcRoster))
+}
+
+// runNPCAgentsLocked lets the active NPCs act ahead of the world tick; a
+// jailed NPC spends its turns planning an escape. Their joins and actions
+// are journaled like a human's, so replay needs no NPC logic; decisions draw
+// from a generator derived from the tick rather than a world stream for the
+// same reason.
+func runNPCAgentsLocked(store *Store, now time.Time) {
+	active := activeNPCCountLocked(store, now)
+	if active == 0 {
+		return
+	}
+	if store.rng == nil {
+		store.rng = newWorldRNG(worldSeedFromEnv(now))
+	}
+	rng := mathrand.New(mathrand.NewSource(int64(rngValue(store.rng.Seed, "npc", store.TickCount, 0) >> 1)))
+	for _, ch := range npcRoster[:active] {
+		p := ensureNPCLocked(store, ch, now)
+		if p.TravelTicksLeft > 0 || rng.Intn(100) >= npcActPct {
+			continue
+		}
+		p.LastSeen = now
+		if hasStatus(p, statusJailed) {
+			submitActionLocked(store, p, now, ActionInput{Action: "plan_escape"})
+			continue
+		}
+		if in, ok := npcPolicies[ch.Role].Act(store, p, rng); ok {
+			submitActionLocked(store, p, now, in)
+		}
+	}
+}
+
+// ensureNPCLocked returns ch's Player, creating it on first use and bringing
+// a retired or cleaned-up record back into the city.
+func ensureNPCLocked(store *Store, ch npcCharacter, now time.Time) *Player {
+	p := store.Players[ch.ID]
+	if p == nil {
+		recordJournalLocked(store, JournalEntry{Kind: journalKindJoin, PlayerID: ch.ID, Name: ch.Name, Role: ch.Role, At: now})
+		p = addPlayerLocked(store, ch.ID, ch.Name, now)
+		p.NPCRole = ch.Role
+		p.Gold = ch.Gold
+		return p
+	}
+	p.Name = ch.Name
+	p.NPCRole = ch.Role
+	p.SoftDeletedAt, p.HardDeletedAt = time.Time{}, time.Time{}
+	return p
+}
+
+// npcMerchantPolicy trades grain against the local market, fills supply
+// contracts and lends to struggling players.
+type npcMerchantPolicy struct{}
+
+func (npcMerchantPolicy) Act(store *Store, p *Player, rng *mathrand.Rand) (ActionInput, bool) {
+	if c := ownAcceptedContractLocked(store, p.ID); c != nil {
+		return ActionInput{Action: "deliver", ContractID: c.ID}, true
+	}
+	for _, c := range sortedContractsLocked(store) {
+		if c.Status == "Issued" && c.Type == "Supply" && c.IssuerPlayerID != p.ID && p.Grain >= c.SupplySacks {
+			return ActionInput{Action: "accept", ContractID: c.ID}, true
+		}
+	}
+	if rng.Intn(4) == 0 {
+		if borrower := npcLoanCandidateLocked(store, p); borrower != nil && p.Gold >= 2*npcLoanPrincipal {
+			return ActionInput{Action: "loan_offer", TargetID: borrower.ID, Amount: npcLoanPrincipal}, true
+		}
+	}
+	locationID, grain := marketLocationID(p), grainCommodity()
+	good, ok := localGood(locationID, grain.ID)
+	if !ok {
+		return ActionInput{}, false
+	}
+	_, buy, _ := marketPricesLocked(store, locationID, grain, good)
+	tier := marketTierLocked(store, locationID, grain, good)
+	if p.Grain > 0 && tier != "Stable" {
+		return ActionInput{Action: "sell_grain", Amount: p.Grain}, true
+	}
+	if tier == "Stable" && buy > 0 && p.Gold >= 2*buy {
+		return ActionInput{Action: "buy_grain", Amount: minInt(marketMaxTrade, p.Gold/(2*buy))}, true
+	}
+	return ActionInput{}, false
+}
+
+// npcLoanCandidateLocked picks the poorest online human with no loan
+// outstanding from p.
+func npcLoanCandidateLocked(store *Store, p *Player) *Player {
+	var pick *Player
+	for _, id := range sortedKeys(store.Players) {
+		c := store.Players[id]
+		if c.NPCRole != "" || c.Gold >= npcLoanPrincipal || p.LastSeen.Sub(c.LastSeen) > onlineWindow {
+			continue
+		}
+		owed := false
+		for _, loan := range store.Loans {
+			if loan.LenderPlayerID == p.ID && loan.BorrowerPlayerID == c.ID && (loan.Status == "Offered" || loan.Status == "Active") {
+				owed = true
+				break
+			}
+		}
+		if !owed && (pick == nil || c.Gold < pick.Gold) {
+			pick = c
+		}
+	}
+	return pick
+}
+
+// npcInformantPolicy digs into the hottest player and spreads rumors about
+// the richest.
+type npcInformantPolicy struct{}
+
+func (npcInformantPolicy) Act(store *Store, p *Player, rng *mathrand.Rand) (ActionInput, bool) {
+	if rng.Intn(2) == 0 {
+		if target := richestRivalLocked(store, p.ID); target != nil {
+			return ActionInput{Action: "seed_rumor", TargetID: target.ID}, true
+		}
+	}
+	if target := hottestRivalLocked(store, p.ID); target != nil {
+		return ActionInput{Action: "investigate", TargetID: target.ID}, true
+	}
+	return ActionInput{Action: "investigate"}, true
+}
+
+// npcWatchPolicy hunts bounties: it takes one, investigates the target until
+// the evidence will stand, then turns it in. Evidence it holds on anyone on
+// trial goes to the court first, and open theft clues are followed next.
+type npcWatchPolicy struct{}
+
+func (npcWatchPolicy) Act(store *Store, p *Player, rng *mathrand.Rand) (ActionInput, bool) {
+	for _, id := range sortedKeys(store.Cases) {
+		if cs := store.Cases[id]; cs.DefendantID != p.ID && strongestEvidenceForLocked(store, p.ID, cs.DefendantID) != nil {
+			return ActionInput{Action: "submit_evidence", ContractID: cs.ID}, true
+		}
+	}
+	if followableClueLocked(store, p, "") != nil {
+		return ActionInput{Action: "investigate"}, true
+	}
+	if c := ownAcceptedContractLocked(store, p.ID); c != nil {
+		if c.Type != "Bounty" {
+			return ActionInput{Action: "deliver", ContractID: c.ID}, true
+		}
+		required := c.BountyEvidence
+		if required <= 0 {
+			required = bountyEvidenceMin
+		}
+		if ev := strongestEvidenceForLocked(store, p.ID, c.TargetPlayerID); ev != nil && ev.Strength >= required {
+			return ActionInput{Action: "deliver", ContractID: c.ID}, true
+		}
+		return ActionInput{Action: "investigate", TargetID: c.TargetPlayerID}, true
+	}
+	for _, c := range sortedContractsLocked(store) {
+		if c.Status == "Issued" && c.Type == "Bounty" && c.TargetPlayerID != p.ID {
+			return ActionInput{Action: "accept", ContractID: c.ID, Stance: contractStanceCareful}, true
+		}
+	}
+	if target := hottestRivalLocked(store, p.ID); target != nil && target.Heat > 0 {
+		return ActionInput{Action: "investigate", TargetID: target.ID}, true
+	}
+	return ActionInput{Action: "investigate"}, true
+}
+
+func hottestRivalLocked(store *Store, playerID string) *Player {
+	var best *Player
+	for _, id := range sortedKeys(store.Players) {
+		p := store.Players[id]
+		if p.ID == playerID {
+			continue
+		}
+		if best == nil || p.Heat > best.Heat {
+			best = p
+		}
+	}
+	return best
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNPCsFillInAndStepAside(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	addPlayerLocked(s, "h1", "Ash Crow (Guest)", now)
+
+	advanceWorldLocked(s, now, false)
+	for _, ch := range npcRoster {
+		p := s.Players[ch.ID]
+		if p == nil || p.NPCRole != ch.Role || p.Name != ch.Name {
+			t.Fatalf("%s should have joined the city: %+v", ch.ID, p)
+		}
+	}
+	joins := 0
+	for _, e := range s.journalPending {
+		if e.Kind == journalKindJoin && e.Role != "" {
+			joins++
+		}
+	}
+	if joins != len(npcRoster) {
+		t.Fatalf("NPC joins should be journaled, got %d", joins)
+	}
+	if got := humansOnlineLocked(s, now); got != 1 {
+		t.Fatalf("NPCs should not count as humans online, got %d", got)
+	}
+
+	for i := 2; i <= npcPopulationTarget; i++ {
+		addPlayerLocked(s, fmt.Sprintf("h%d", i), fmt.Sprintf("Guest %d", i), now)
+	}
+	if got := activeNPCCountLocked(s, now); got != 0 {
+		t.Fatalf("a full city should retire every NPC, %d still active", got)
+	}
+	later := now.Add(time.Hour)
+	for _, ch := range npcRoster {
+		s.Players[ch.ID].LastSeen = now
+	}
+	for i := 1; i <= npcPopulationTarget; i++ {
+		s.Players[fmt.Sprintf("h%d", i)].LastSeen = later
+	}
+	journaled := len(s.journalPending)
+	advanceWorldLocked(s, later, false)
+	for _, e := range s.journalPending[journaled:] {
+		if e.Kind == journalKindAction {
+			t.Fatalf("retired NPCs should not act: %+v", e)
+		}
+	}
+	s.Players["h5"].LastSeen = now
+	if got := activeNPCCountLocked(s, later); got != 1 || npcRoster[0].Role != npcRoleMerchant {
+		t.Fatalf("the merchant should return first as humans leave, active %d", got)
+	}
+
+	mux := newMux(s, parseTemplates())
+	rr := doReq(t, mux, http.MethodGet, "/", nil, "h1", "")
+	if body := rr.Body.String(); !strings.Contains(body, "Oda Brisk (NPC)") || !strings.Contains(body, "NPC · Merchant") {
+		t.Fatalf("players list should mark NPCs")
+	}
+}
+
+func TestNPCPoliciesUseThePlayerActions(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	watch := &Player{ID: "npc-watch", Name: "Sergeant Pell (NPC)", NPCRole: npcRoleWatch, LocationID: locationCapital, LastSeen: now}
+	merchant := &Player{ID: "npc-merchant", Name: "Oda Brisk (NPC)", NPCRole: npcRoleMerchant, Gold: 60, LocationID: locationCapital, LastSeen: now}
+	wanted := &Player{ID: "h1", Name: "Ash Crow (Guest)", Heat: 12, Gold: 2, LocationID: locationCapital, LastSeen: now}
+	for _, p := range []*Player{watch, merchant, wanted} {
+		s.Players[p.ID] = p
+	}
+	c := issueBountyContractLocked(s, wanted, bountyDeadlineTicks)
+
+	in, _ := npcWatchPolicy{}.Act(s, watch, nil)
+	if in.Action != "accept" || in.ContractID != c.ID {
+		t.Fatalf("the watch officer should take the bounty: %+v", in)
+	}
+	submitActionLocked(s, watch, now, in)
+	if c.Status != "Accepted" || c.OwnerPlayerID != watch.ID {
+		t.Fatalf("the bounty should be accepted: %+v", c)
+	}
+	if in, _ = (npcWatchPolicy{}).Act(s, watch, nil); in.Action != "investigate" || in.TargetID != wanted.ID {
+		t.Fatalf("without evidence the officer should investigate: %+v", in)
+	}
+	submitActionLocked(s, watch, now.Add(time.Second), in)
+	if s.rejections[watch.ID] != errCodeCooldown {
+		t.Fatalf("NPCs should obey the action cooldown, got %q", s.rejections[watch.ID])
+	}
+
+	in, _ = npcMerchantPolicy{}.Act(s, merchant, newWorldRNG(1).stream("npc", 0))
+	if in.Action != "buy_grain" && in.Action != "loan_offer" {
+		t.Fatalf("the merchant should trade or lend: %+v", in)
+	}
+	if borrower := npcLoanCandidateLocked(s, merchant); borrower != wanted {
+		t.Fatalf("the merchant should lend to the struggling human, got %+v", borrower)
+	}
+
+	replayed := newTestStore()
+	applyJournalEntryLocked(replayed, JournalEntry{Kind: journalKindJoin, PlayerID: "npc-merchant", Name: "Oda Brisk (NPC)", Role: npcRoleMerchant, At: now})
+	if p := replayed.Players["npc-merchant"]; p.NPCRole != npcRoleMerchant || p.Gold != 60 {
+		t.Fatalf("a replayed NPC join should restore the character: %+v", p)
+	}
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Order sides. A bid offers gold for goods; an ask offers goods for gold.
+const (
+	orderSideBid = "bid"
+	orderSideAsk = "ask"
+)
+
+const (
+	// marketOrderTicks is how long an order rests on the book before it
+	// expires and its escrow is returned.
+	marketOrderTicks    = 12
+	marketMaxOpenOrders = 6
+	marketMaxOrderSize  = 120
+	// marketMaxOrderPrice bounds a limit price so a bid's escrow stays far
+	// from overflowing.
+	marketMaxOrderPrice = 100000
+	// orderBookDepth is how many price levels each side of a book shows.
+	orderBookDepth = 3
+)
+
+// MarketOrder is a limit order resting on one location's book. While it
+// rests its escrow is held off the player: a bid holds Amount*Price gold and
+// an ask holds Amount units of the commodity.
+type MarketOrder struct {
+	ID         string
+	PlayerID   string
+	PlayerName string
+	LocationID string
+	Commodity  string
+	Side       string
+	// Price is the limit per unit: the most a bid pays or the least an ask
+	// takes.
+	Price int
+	// Amount is what is still unfilled.
+	Amount      int
+	Filled      int
+	PlacedTick  int64
+	ExpiresTick int64
+}
+
+// OrderBookLevel is the total amount resting at one price.
+type OrderBookLevel struct {
+	Price  int `json:"price"`
+	Amount int `json:"amount"`
+}
+
+type MarketOrderView struct {
+	ID        string `json:"id"`
+	Commodity string `json:"commodity"`
+	Name      string `json:"name"`
+	Unit      string `json:"unit"`
+	Location  string `json:"location"`
+	Side      string `json:"side"`
+	Price     int    `json:"price"`
+	Amount    int    `json:"amount"`
+	Filled    int    `json:"filled"`
+	TicksLeft int    `json:"ticks_left"`
+}
+
+// orderSeq orders IDs numerically so "o-10" sorts after "o-9".
+func orderSeq(id string) int64 {
+	n, _ := strconv.ParseInt(strings.TrimPrefix(id, "o-"), 10, 64)
+	return n
+}
+
+func sortedOrderIDs(orders map[string]*MarketOrder) []string {
+	ids := sortedKeys(orders)
+	slices.SortFunc(ids, func(a, b string) int { return cmp.Compare(orderSeq(a), orderSeq(b)) })
+	return ids
+}
+
+func openOrderCountLocked(store *Store, playerID string) int {
+	n := 0
+	for _, o := range store.Orders {
+		if o.PlayerID == playerID {
+			n++
+		}
+	}
+	return n
+}
+
+// marketPriceCapLocked is the highest price any order may trade at while
+// market controls hold at locationID, or 0 when prices are free.
+func marketPriceCapLocked(store *Store, locationID string, def CommodityDefinition, good LocalGood) int {
+	if _, controls := marketTaxLocked(store, locationID); controls == 0 {
+		return 0
+	}
+	_, buy, _ := marketPricesLocked(store, locationID, def, good)
+	return buy
+}
+
+// refundOrderLocked returns whatever escrow o still holds to its owner.
+func refundOrderLocked(store *Store, o *MarketOrder) {
+	p := store.Players[o.PlayerID]
+	if p == nil || o.Amount <= 0 {
+		return
+	}
+	store.dirty.mark(dirtyPlayers, p.ID)
+	if o.Side == orderSideBid {
+		p.Gold += o.Amount * o.Price
+		return
+	}
+	addHolding(p, o.Commodity, o.Amount)
+}
+
+func placeOrderLocked(store *Store, p *Player, now time.Time, def CommodityDefinition, side string, price, amount int) {
+	locationID, good, ok := tradedHereLocked(store, p, def)
+	if !ok {
+		return
+	}
+	if price <= 0 || price > marketMaxOrderPrice {
+		rejectLocked(store, p.ID, errCodeInvalidInput, fmt.Sprintf("Name a price from 1g to %dg.", marketMaxOrderPrice))
+		return
+	}
+	if amount <= 0 || amount > marketMaxOrderSize {
+		rejectLocked(store, p.ID, errCodeInvalidInput, fmt.Sprintf("Orders are for 1 to %d %s.", marketMaxOrderSize, def.Unit))
+		return
+	}
+	if openOrderCountLocked(store, p.ID) >= marketMaxOpenOrders {
+		rejectLocked(store, p.ID, errCodeNotAllowed, fmt.Sprintf("You already have %d open orders.", marketMaxOpenOrders))
+		return
+	}
+	if limit := marketPriceCapLocked(store, locationID, def, good); limit > 0 && price > limit {
+		rejectLocked(store, p.ID, errCodeNotAllowed, fmt.Sprintf("Market controls cap %s at %dg.", strings.ToLower(def.Name), limit))
+		return
+	}
+	if side == orderSideBid {
+		escrow, ok := orderEscrow(price, amount)
+		if !ok {
+			rejectLocked(store, p.ID, errCodeInvalidInput, "That bid is too large to escrow.")
+			return
+		}
+		if p.Gold < escrow {
+			rejectLocked(store, p.ID, errCodeInsufficientGold, fmt.Sprintf("Need %dg to bid for %d %s.", escrow, amount, def.Unit))
+			return
+		}
+		p.Gold -= escrow
+	} else {
+		if held := holdingOf(p, def.ID); held < amount {
+			rejectLocked(store, p.ID, insufficientCodeFor(def.ID), fmt.Sprintf("You only hold %d %s.", held, def.Unit))
+			return
+		}
+		addHolding(p, def.ID, -amount)
+	}
+
+	store.NextOrderID++
+	o := &MarketOrder{
+		ID:          fmt.Sprintf("o-%d", store.NextOrderID),
+		PlayerID:    p.ID,
+		PlayerName:  p.Name,
+		LocationID:  locationID,
+		Commodity:   def.ID,
+		Side:        side,
+		Price:       price,
+		Amount:      amount,
+		PlacedTick:  store.TickCount,
+		ExpiresTick: store.TickCount + marketOrderTicks,
+	}
+	matchOrderLocked(store, now, o)
+	if o.Amount > 0 {
+		store.dirty.mark(dirtyOrders, o.ID)
+		store.Orders[o.ID] = o
+		addEventLocked(store, Event{Type: "Market", Severity: 1, Text: fmt.Sprintf("[%s] posts %s for %d %s at %dg in %s.", p.Name, orderSideNoun(side), o.Amount, def.Unit, price, locationName(locationID)), At: now})
+	}
+	switch {
+	case o.Amount == 0:
+		setToastLocked(store, p.ID, fmt.Sprintf("Order filled: %d %s.", o.Filled, def.Unit))
+	case o.Filled > 0:
+		setToastLocked(store, p.ID, fmt.Sprintf("Filled %d %s; %d rest on the book.", o.Filled, def.Unit, o.Amount))
+	default:
+		setToastLocked(store, p.ID, fmt.Sprintf("Order posted: %d %s at %dg.", o.Amount, def.Unit, price))
+	}
+}
+
+// orderEscrow is the gold a bid of amount at price holds, and false if that
+// would overflow.
+func orderEscrow(price, amount int) (int, bool) {
+	if price < 0 || amount < 0 || (amount > 0 && price > math.MaxInt/amount) {
+		return 0, false
+	}
+	return price * amount, true
+}
+
+func orderSideNoun(side string) string {
+	if side == orderSideBid {
+		return "a bid"
+	}
+	return "an ask"
+}
+
+func cancelOrderLocked(store *Store, p *Player, orderID string) {
+	o := store.Orders[orderID]
+	if o == nil || o.PlayerID != p.ID {
+		rejectLocked(store, p.ID, errCodeNotFound, "No such open order.")
+		return
+	}
+	refundOrderLocked(store, o)
+	store.dirty.mark(dirtyOrders, o.ID)
+	delete(store.Orders, o.ID)
+	setToastLocked(store, p.ID, fmt.Sprintf("Order cancelled; %d %s unfilled.", o.Amount, commodityUnit(o.Commodity)))
+}
+
+// bestCounterOrderLocked finds the resting order o would trade with first:
+// the cheapest ask for a bid or the richest bid for an ask, oldest first at
+// equal prices. Orders never match their owner's, nor trade above cap.
+func bestCounterOrderLocked(store *Store, o *MarketOrder, limit int) *MarketOrder {
+	var best *MarketOrder
+	for _, id := range sortedOrderIDs(store.Orders) {
+		c := store.Orders[id]
+		if c == o || c.Side == o.Side || c.PlayerID == o.PlayerID || c.LocationID != o.LocationID || c.Commodity != o.Commodity || c.Amount <= 0 {
+			continue
+		}
+		if limit > 0 && c.Price > limit {
+			continue
+		}
+		if o.Side == orderSideBid {
+			if c.Price <= o.Price && (best == nil || c.Price < best.Price) {
+				best = c
+			}
+		} else if c.Price >= o.Price && (best == nil || c.Price > best.Price) {
+			best = c
+		}
+	}
+	return best
+}
+
+// matchOrderLocked fills o against the book and then the city, best price
+// first. Player orders trade at the resting order's price; the city makes a
+// market of last resort at its own posted prices, one trade-sized lot at a
+// time so its price follows the pool.
+func matchOrderLocked(store *Store, now time.Time, o *MarketOrder) {
+	def, ok := commodityDefinitionByID(o.Commodity)
+	if !ok {
+		return
+	}
+	good, ok := localGood(o.LocationID, o.Commodity)
+	if !ok {
+		return
+	}
+	for o.Amount > 0 {
+		limit := marketPriceCapLocked(store, o.LocationID, def, good)
+		if limit > 0 && o.Price > limit {
+			return
+		}
+		_, cityBuy, citySell := marketPricesLocked(store, o.LocationID, def, good)
+		cityStock := marketSupplyLocked(store, o.LocationID, good) / def.PoolPerUnit
+		cityCrosses := (o.Side == orderSideBid && cityStock > 0 && cityBuy <= o.Price) || (o.Side == orderSideAsk && citySell >= o.Price)
+		counter := bestCounterOrderLocked(store, o, limit)
+		switch {
+		case counter != nil && (!cityCrosses || (o.Side == orderSideBid && counter.Price <= cityBuy) || (o.Side == orderSideAsk && counter.Price >= citySell)):
+			qty := minInt(o.Amount, counter.Amount)
+			if o.Side == orderSideBid {
+				settleOrderTradeLocked(store, now, def, o, counter, qty, counter.Price)
+			} else {
+				settleOrderTradeLocked(store, now, def, counter, o, qty, counter.Price)
+			}
+			if counter.Amount == 0 {
+				store.dirty.mark(dirtyOrders, counter.ID)
+				delete(store.Orders, counter.ID)
+			}
+		case cityCrosses:
+			qty := minInt(o.Amount, marketMaxTrade)
+			if o.Side == orderSideBid {
+				qty = minInt(qty, cityStock)
+				settleCityTradeLocked(store, now, def, good, o, qty, cityBuy)
+			} else {
+				settleCityTradeLocked(store, now, def, good, o, qty, citySell)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// settleOrderTradeLocked trades qty units from ask to bid at price. The
+// buyer gets back what the bid escrowed above price; the seller's proceeds
+// are taxed by the market's jurisdiction.
+func settleOrderTradeLocked(store *Store, now time.Time, def CommodityDefinition, bid, ask *MarketOrder, qty, price int) {
+	gross := qty * price
+	taxPct, _ := marketTaxLocked(store, bid.LocationID)
+	tax := int(math.Ceil(float64(gross) * float64(taxPct) / 100.0))
+	if buyer := store.Players[bid.PlayerID]; buyer != nil {
+		store.dirty.mark(dirtyPlayers, buyer.ID)
+		addHolding(buyer, def.ID, qty)
+		buyer.Gold += qty * (bid.Price - price)
+	}
+	seller := store.Players[ask.PlayerID]
+	if seller != nil {
+		store.dirty.mark(dirtyPlayers, seller.ID)
+		seller.Gold += gross - tax
+	}
+	collectMarketTaxLocked(store, now, bid.LocationID, tax, seller)
+	recordTradeVolumeLocked(store, bid.LocationID, def.ID, qty)
+	for _, side := range []*MarketOrder{bid, ask} {
+		store.dirty.mark(dirtyOrders, side.ID)
+		side.Amount -= qty
+		side.Filled += qty
+	}
+	addEventLocked(store, Event{Type: "Market", Severity: 1, Text: fmt.Sprintf("[%s] buys %d %s from [%s] at %dg.", bid.PlayerName, qty, def.Unit, ask.PlayerName, price), At: now})
+}
+
+func settleCityTradeLocked(store *Store, now time.Time, def CommodityDefinition, good LocalGood, o *MarketOrder, qty, price int) {
+	p := store.Players[o.PlayerID]
+	buyTax, sellTax := marketTaxPerUnitLocked(store, o.LocationID, def, good)
+	if p != nil {
+		store.dirty.mark(dirtyPlayers, p.ID)
+	}
+	if o.Side == orderSideBid {
+		if p != nil {
+			addHolding(p, def.ID, qty)
+			p.Gold += qty * (o.Price - price)
+		}
+		collectMarketTaxLocked(store, now, o.LocationID, qty*buyTax, p)
+		applyMarketSupplyDeltaLocked(store, now, o.LocationID, def, good, -qty*def.PoolPerUnit)
+	} else {
+		if p != nil {
+			p.Gold += qty * price
+		}
+		collectMarketTaxLocked(store, now, o.LocationID, qty*sellTax, p)
+		applyMarketSupplyDeltaLocked(store, now, o.LocationID, def, good, qty*def.PoolPerUnit)
+	}
+	recordTradeVolumeLocked(store, o.LocationID, def.ID, qty)
+	store.dirty.mark(dirtyOrders, o.ID)
+	o.Amount -= qty
+	o.Filled += qty
+}
+
+// processOrderBookTickLocked expires old orders and lets the rest trade
+// against a city whose prices have moved since they were posted.
+func processOrderBookTickLocked(store *Store, now time.Time) {
+	for _, id := range sortedOrderIDs(store.Orders) {
+		o := store.Orders[id]
+		if o == nil {
+			continue
+		}
+		if store.TickCount >= o.ExpiresTick {
+			refundOrderLocked(store, o)
+			delete(store.Orders, id)
+			setToastLocked(store, o.PlayerID, fmt.Sprintf("Your %s for %s expired with %d unfilled.", o.Side, commodityUnit(o.Commodity), o.Amount))
+			continue
+		}
+		matchOrderLocked(store, now, o)
+		if o.Amount == 0 {
+			delete(store.Orders, id)
+			setToastLocked(store, o.PlayerID, fmt.Sprintf("Your %s for %s filled.", o.Side, commodityUnit(o.Commodity)))
+		}
+	}
+}
+
+// orderBookLevelsLocked aggregates one side of a book into its best
+// orderBookDepth price levels.
+func orderBookLevelsLocked(store *Store, locationID, commodityID, side string) []OrderBookLevel {
+	byPrice := map[int]int{}
+	for _, o := range store.Orders {
+		if o.LocationID == locationID && o.Commodity == commodityID && o.Side == side {
+			byPrice[o.Price] += o.Amount
+		}
+	}
+	prices := sortedKeys(byPrice)
+	if side == orderSideBid {
+		slices.Reverse(prices)
+	}
+	levels := make([]OrderBookLevel, 0, minInt(len(prices), orderBookDepth))
+	for _, price := range prices {
+		if len(levels) == orderBookDepth {
+			break
+		}
+		levels = append(levels, OrderBookLevel{Price: price, Amount: byPrice[price]})
+	}
+	return levels
+}
+
+// playerOrderViewsLocked lists p's open orders, oldest first.
+func playerOrderViewsLocked(store *Store, p *Player) []MarketOrderView {
+	views := []MarketOrderView{}
+	for _, id := range sortedOrderIDs(store.Orders) {
+		o := store.Orders[id]
+		if o.PlayerID != p.ID {
+			continue
+		}
+		name := o.Commodity
+		if def, ok := commodityDefinitionByID(o.Commodity); ok {
+			name = def.Name
+		}
+		views = append(views, MarketOrderView{
+			ID:        o.ID,
+			Commodity: o.Commodity,
+			Name:      name,
+			Unit:      commodityUnit(o.Commodity),
+			Location:  locationName(o.LocationID),
+			Side:      o.Side,
+			Price:     o.Price,
+			Amount:    o.Amount,
+			Filled:    o.Filled,
+			TicksLeft: int(maxInt64(0, o.ExpiresTick-store.TickCount)),
+		})
+	}
+	return views
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestOrderBookMatchesWithEscrowAndPartialFills(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	// A wide city spread leaves room for players to trade inside it.
+	s.Policies.TaxRatePct = 50
+	seller := &Player{ID: "p1", Name: "Ash Crow (Guest)", LastSeen: now, Inventory: map[string]int{"timber": 10}}
+	buyer := &Player{ID: "p2", Name: "Bram Vale (Guest)", Gold: 100, LastSeen: now}
+	s.Players[seller.ID], s.Players[buyer.ID] = seller, buyer
+	timber, _ := commodityDefinitionByID("timber")
+	good, _ := localGood(locationCapital, "timber")
+	_, cityBuy, citySell := marketPricesLocked(s, locationCapital, timber, good)
+	price := cityBuy - 1
+	if price <= citySell {
+		t.Fatalf("test needs a spread: city buys at %d, sells at %d", citySell, cityBuy)
+	}
+
+	handleActionInputLocked(s, seller, now, ActionInput{Action: "ask", Commodity: "timber", Amount: 10, Price: price})
+	if holdingOf(seller, "timber") != 0 || len(s.Orders) != 1 {
+		t.Fatalf("ask should escrow the timber and rest: held %d, %d orders", holdingOf(seller, "timber"), len(s.Orders))
+	}
+	ask := s.Orders["o-1"]
+
+	handleActionInputLocked(s, buyer, now, ActionInput{Action: "bid", Commodity: "timber", Amount: 4, Price: price})
+	tax := (4*price + 1) / 2
+	if holdingOf(buyer, "timber") != 4 || buyer.Gold != 100-4*price || seller.Gold != 4*price-tax {
+		t.Fatalf("first fill: buyer timber %d gold %d, seller gold %d", holdingOf(buyer, "timber"), buyer.Gold, seller.Gold)
+	}
+	if ask.Amount != 6 || ask.Filled != 4 || len(s.Orders) != 1 {
+		t.Fatalf("ask should be partly filled: %+v", ask)
+	}
+
+	// A bid above the resting ask pays the ask's price and gets the rest of
+	// its escrow back; the book beats the city when it is no dearer.
+	gold := buyer.Gold
+	handleActionInputLocked(s, buyer, now, ActionInput{Action: "bid", Commodity: "timber", Amount: 3, Price: cityBuy})
+	if holdingOf(buyer, "timber") != 7 || buyer.Gold != gold-3*price || ask.Amount != 3 {
+		t.Fatalf("second fill: buyer timber %d gold %d, ask %+v", holdingOf(buyer, "timber"), buyer.Gold, ask)
+	}
+
+	handleActionInputLocked(s, buyer, now, ActionInput{Action: "cancel_order", OrderID: ask.ID})
+	if s.rejections[buyer.ID] != errCodeNotFound {
+		t.Fatalf("only the owner may cancel, got %q", s.rejections[buyer.ID])
+	}
+	handleActionInputLocked(s, seller, now, ActionInput{Action: "cancel_order", OrderID: ask.ID})
+	if holdingOf(seller, "timber") != 3 || len(s.Orders) != 0 {
+		t.Fatalf("cancel should return the unfilled timber: held %d, %d orders", holdingOf(seller, "timber"), len(s.Orders))
+	}
+
+	gold = buyer.Gold
+	handleActionInputLocked(s, buyer, now, ActionInput{Action: "bid", Commodity: "timber", Amount: 2, Price: price})
+	if buyer.Gold != gold-2*price || len(s.Orders) != 1 {
+		t.Fatalf("an unmatched bid should escrow its gold and rest: gold %d, %d orders", buyer.Gold, len(s.Orders))
+	}
+	if views := buildPageDataLocked(s, buyer.ID, false).MarketOrders; len(views) != 1 || views[0].TicksLeft != marketOrderTicks {
+		t.Fatalf("page should list the open bid: %+v", views)
+	}
+	s.TickCount += marketOrderTicks
+	processOrderBookTickLocked(s, now)
+	if buyer.Gold != gold || len(s.Orders) != 0 {
+		t.Fatalf("an expired bid should refund its escrow: gold %d, %d orders", buyer.Gold, len(s.Orders))
+	}
+}
+
+func TestCityMakesMarketOfLastResort(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	p := &Player{ID: "p1", Name: "Ash Crow (Guest)", Gold: 1000, LastSeen: now}
+	s.Players[p.ID] = p
+	grain := grainCommodity()
+
+	// A bid larger than the city's stall cap still fills, lot by lot, until
+	// the granary runs dry; the rest waits on the book.
+	s.World.GrainSupply = 20 * grain.PoolPerUnit
+	handleActionInputLocked(s, p, now, ActionInput{Action: "bid", Commodity: commodityGrain, Amount: 30, Price: 30})
+	if p.Grain != 20 || s.World.GrainSupply != 0 {
+		t.Fatalf("bid should sweep the granary: held %d, pool %d", p.Grain, s.World.GrainSupply)
+	}
+	if o := s.Orders["o-1"]; o == nil || o.Amount != 10 || o.Filled != 20 {
+		t.Fatalf("unfilled sacks should rest: %+v", o)
+	}
+	if p.Gold <= 1000-30*30 || p.Gold >= 1000-10*30 {
+		t.Fatalf("city fills should pay city prices and refund the rest of the escrow, gold %d", p.Gold)
+	}
+
+	// A cornered market: a second player can only buy from the hoarder.
+	handleActionInputLocked(s, p, now, ActionInput{Action: "cancel_order", OrderID: "o-1"})
+	rival := &Player{ID: "p2", Name: "Bram Vale (Guest)", Gold: 500, LastSeen: now}
+	s.Players[rival.ID] = rival
+	handleActionInputLocked(s, p, now, ActionInput{Action: "ask", Commodity: commodityGrain, Amount: 20, Price: 25})
+	handleActionInputLocked(s, rival, now, ActionInput{Action: "bid", Commodity: commodityGrain, Amount: 5, Price: 25})
+	if rival.Grain != 5 || rival.Gold != 500-5*25 {
+		t.Fatalf("rival should buy from the hoarder's ask: grain %d gold %d", rival.Grain, rival.Gold)
+	}
+
+	seller := &Player{ID: "p3", Name: "Cora Flint (Guest)", LastSeen: now, Inventory: map[string]int{"salt": 4}}
+	s.Players[seller.ID] = seller
+	salt, _ := commodityDefinitionByID("salt")
+	good, _ := localGood(locationCapital, "salt")
+	_, _, citySell := marketPricesLocked(s, locationCapital, salt, good)
+	handleActionInputLocked(s, seller, now, ActionInput{Action: "ask", Commodity: "salt", Amount: 4, Price: 1})
+	if seller.Gold != 4*citySell || holdingOf(seller, "salt") != 0 {
+		t.Fatalf("a cheap ask should sell to the city at its price: gold %d", seller.Gold)
+	}
+}
+
+func TestMarketControlsCapOrderPrices(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	p := &Player{ID: "p1", Name: "Ash Crow (Guest)", Gold: 500, LastSeen: now}
+	s.Players[p.ID] = p
+	grain := grainCommodity()
+	good, _ := localGood(locationCapital, commodityGrain)
+	s.World.RestrictedMarketsTicks = 2
+	limit := marketPriceCapLocked(s, locationCapital, grain, good)
+	if limit == 0 {
+		t.Fatalf("controls should cap capital prices")
+	}
+
+	handleActionInputLocked(s, p, now, ActionInput{Action: "bid", Commodity: commodityGrain, Amount: 1, Price: limit + 1})
+	if s.rejections[p.ID] != errCodeNotAllowed || len(s.Orders) != 0 || p.Gold != 500 {
+		t.Fatalf("a bid above the cap should be refused, got %q", s.rejections[p.ID])
+	}
+
+	p.LocationID = locationHarbor
+	harbor, _ := localGood(locationHarbor, commodityGrain)
+	if marketPriceCapLocked(s, locationHarbor, grain, harbor) != 0 {
+		t.Fatalf("city controls should not reach the harbor's local market")
+	}
+	s.rejections[p.ID] = ""
+	handleActionInputLocked(s, p, now, ActionInput{Action: "bid", Commodity: commodityGrain, Amount: 1, Price: limit + 1})
+	if s.rejections[p.ID] != "" || p.Grain != 1 {
+		t.Fatalf("harbor bid should fill: %q, grain %d", s.rejections[p.ID], p.Grain)
+	}
+}
+
+func TestOrderPriceCannotOverflowEscrow(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	buyer := &Player{ID: "p1", Name: "Bram Vale (Guest)", Gold: 20, LastSeen: now}
+	s.Players[buyer.ID] = buyer
+
+	// 25 * 737869762948382065 wraps to a small positive int64.
+	handleActionInputLocked(s, buyer, now, ActionInput{Action: "bid", Commodity: commodityGrain, Amount: 25, Price: 737869762948382065})
+	if s.rejections[buyer.ID] != errCodeInvalidInput {
+		t.Fatalf("an absurd bid price should be refused, got %q", s.rejections[buyer.ID])
+	}
+	if buyer.Gold != 20 || holdingOf(buyer, commodityGrain) != 0 || len(s.Orders) != 0 {
+		t.Fatalf("a refused bid must not trade: gold %d, grain %d, %d orders", buyer.Gold, holdingOf(buyer, commodityGrain), len(s.Orders))
+	}
+
+	if _, ok := orderEscrow(math.MaxInt/2+1, 2); ok {
+		t.Fatalf("orderEscrow should report overflow")
+	}
+	if escrow, ok := orderEscrow(marketMaxOrderPrice, marketMaxOrderSize); !ok || escrow != marketMaxOrderPrice*marketMaxOrderSize {
+		t.Fatalf("orderEscrow(max, max) = %d, %v", escrow, ok)
+	}
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// pushHeartbeatEvery keeps proxies from closing idle streams and refreshes
+// LastSeen well inside inactiveWindow, since a pushed client no longer polls.
+const pushHeartbeatEvery = 30 * time.Second
+
+// pushFragment is a bit set of page fragments a client needs re-rendered.
+type pushFragment uint16
+
+const (
+	pushDashboard pushFragment = 1 << iota
+	pushEvents
+	pushChat
+	pushDiplomacy
+	pushPlayers
+	pushInstitutions
+	pushIntel
+	pushLedger
+	pushMarket
+	pushToast
+
+	pushAll = pushDashboard | pushEvents | pushChat | pushDiplomacy | pushPlayers | pushInstitutions | pushIntel | pushLedger | pushMarket | pushToast
+	// pushShared covers fragments that render world state, which any
+	// player's action can change.
+	pushShared = pushDashboard | pushPlayers | pushInstitutions | pushMarket
+	// pushPage is every fragment but the toast. Ticks and installs send
+	// it: a toast only changes when setToastLocked notifies its player, and
+	// reading one means a write per stream.
+	pushPage = pushAll &^ pushToast
+	// pushOwn covers fragments that render a player's own gold, holdings,
+	// cooldowns and standing, which gate what every card offers them.
+	pushOwn = pushShared | pushIntel | pushLedger
+)
+
+// pushTargets maps each fragment to the templates rendered for it and the
+// element ids they replace.
+var pushTargets = []struct {
+	frag     pushFragment
+	template string
+	target   string
+}{
+	{pushDashboard, "dashboard", "dashboard"},
+	{pushDashboard, "header_inner", "realm-header"},
+	{pushEvents, "events_inner", "event-log"},
+	{pushChat, "chat_inner", "chat"},
+	{pushDiplomacy, "diplomacy_inner", "diplomacy"},
+	{pushPlayers, "players_inner", "players"},
+	{pushInstitutions, "institutions_inner", "institutions"},
+	{pushIntel, "intel_inner", "intel"},
+	{pushLedger, "ledger_inner", "ledger"},
+	{pushMarket, "market_inner", "market"},
+}
+
+type pushClient struct {
+	playerID string
+	pending  pushFragment
+	wake     chan struct{}
+}
+
+// pushHub tracks open SSE streams by player. Its lock is only ever taken
+// while store.mu is held or with no other lock, never the other way round.
+type pushHub struct {
+	mu      sync.Mutex
+	clients map[string]map[*pushClient]struct{}
+
+	// closing is closed at shutdown so open streams end and let the server
+	// drain.
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+func newPushHub() *pushHub {
+	return &pushHub{clients: map[string]map[*pushClient]struct{}{}, closing: make(chan struct{})}
+}
+
+// close ends every open stream. Streams opened afterwards end at once.
+func (h *pushHub) close() {
+	if h == nil {
+		return
+	}
+	h.closeOnce.Do(func() { close(h.closing) })
+}
+
+func (h *pushHub) subscribe(playerID string) *pushClient {
+	c := &pushClient{playerID: playerID, wake: make(chan struct{}, 1)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients[playerID] == nil {
+		h.clients[playerID] = map[*pushClient]struct{}{}
+	}
+	h.clients[playerID][c] = struct{}{}
+	return c
+}
+
+func (h *pushHub) unsubscribe(c *pushClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients[c.playerID], c)
+	if len(h.clients[c.playerID]) == 0 {
+		delete(h.clients, c.playerID)
+	}
+}
+
+// take returns and clears the fragments c has been asked to refresh.
+func (h *pushHub) take(c *pushClient) pushFragment {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	frags := c.pending
+	c.pending = 0
+	return frags
+}
+
+func (h *pushHub) mark(c *pushClient, frags pushFragment) {
+	c.pending |= frags
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// notify marks frags dirty for the given players' streams. Repeated marks
+// before a stream wakes coalesce into one render.
+func (h *pushHub) notify(frags pushFragment, playerIDs ...string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, id := range playerIDs {
+		for c := range h.clients[id] {
+			h.mark(c, frags)
+		}
+	}
+}
+
+func (h *pushHub) notifyAll(frags pushFragment) {
+	h.notifyExcept(frags, "")
+}
+
+func (h *pushHub) notifyExcept(frags pushFragment, skipPlayerID string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id, clients := range h.clients {
+		if id == skipPlayerID {
+			continue
+		}
+		for c := range clients {
+			h.mark(c, frags)
+		}
+	}
+}
+
+func (h *pushHub) subscriberCount() int {
+	if h == nil {
+		return 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := 0
+	for _, clients := range h.clients {
+		n += len(clients)
+	}
+	return n
+}
+
+// notifyChangesLocked tells open streams which fragments a tracked batch
+// changed, going by the entries it marked dirty and comparing each with its
+// copy in prev. Events, chat, missives and toasts notify where they are
+// added. Untracked batches are ticks, installs and admin commands, which
+// notify for themselves.
+func notifyChangesLocked(h *pushHub, prev, next *Store) {
+	changes := next.changes
+	if h == nil || prev == nil || changes == nil || changes.all || h.subscriberCount() == 0 {
+		return
+	}
+	var everyone pushFragment
+	players := map[string]pushFragment{}
+	notify := func(frags pushFragment, ids ...string) {
+		for _, id := range ids {
+			if id != "" {
+				players[id] |= frags
+			}
+		}
+	}
+
+	now := time.Now().UTC()
+	forChangedEntries(prev.Players, next.Players, changes, dirtyPlayers, func(id string, was, is *Player) {
+		switch {
+		case was == nil || is == nil:
+			// The roster changed, and with it every player picker.
+			everyone |= pushOwn
+		case sameBesidesLastSeen(was, is):
+			// Page loads stamp LastSeen; the list only shows who is online.
+			if !samePlayerSummary(was, is, now) {
+				everyone |= pushPlayers
+			}
+			return
+		case !samePlayerSummary(was, is, now):
+			everyone |= pushPlayers
+			if reputationTitle(was.Rep) != reputationTitle(is.Rep) {
+				everyone |= pushDashboard
+			}
+		}
+		notify(pushOwn, id)
+	})
+	for _, field := range []string{dirtyLastMessageAt, dirtyLastActionAt, dirtyLastDeliverAt, dirtyLastInvestigateAt, dirtyLastSeatActionAt, dirtyLastIntelActionAt, dirtyLastFieldworkAt, dirtyDailyActionDate, dirtyDailyHighImpactN} {
+		keys, _ := changes.field(field)
+		for key := range keys {
+			notify(pushOwn, key.(string))
+		}
+	}
+	if !reflect.DeepEqual(prev.World, next.World) || !reflect.DeepEqual(prev.Policies, next.Policies) || !reflect.DeepEqual(prev.ActiveCrisis, next.ActiveCrisis) {
+		everyone |= pushOwn
+	}
+
+	for _, shared := range []struct {
+		field string
+		frags pushFragment
+	}{
+		{dirtyContracts, pushDashboard},
+		{dirtyCaravans, pushDashboard},
+		{dirtyBuildings, pushDashboard},
+		{dirtyInstitutions, pushInstitutions},
+		{dirtySeats, pushInstitutions},
+		{dirtyProjects, pushInstitutions},
+		{dirtyTreasuryLedger, pushInstitutions},
+		{dirtyPermits, pushInstitutions},
+		{dirtyWarrants, pushInstitutions | pushPlayers},
+		{dirtyCases, pushInstitutions | pushPlayers},
+		{dirtyRumors, pushIntel},
+		{dirtyClues, pushIntel},
+		{dirtyOrders, pushMarket},
+		{dirtyMarketHistory, pushMarket},
+	} {
+		if keys, whole := changes.field(shared.field); whole || len(keys) > 0 {
+			everyone |= shared.frags
+		}
+	}
+	forChangedEntries(prev.Evidence, next.Evidence, changes, dirtyEvidence, func(_ int64, was, is *Evidence) {
+		for _, ev := range []*Evidence{was, is} {
+			if ev != nil {
+				notify(pushOwn, ev.SourcePlayerID)
+			}
+		}
+	})
+	forChangedEntries(prev.ScryReports, next.ScryReports, changes, dirtyScryReports, func(_ int64, was, is *ScryReport) {
+		for _, r := range []*ScryReport{was, is} {
+			if r != nil {
+				notify(pushIntel, r.OwnerPlayerID)
+			}
+		}
+	})
+	forChangedEntries(prev.Intercepts, next.Intercepts, changes, dirtyIntercepts, func(_ int64, was, is *InterceptedMessage) {
+		for _, m := range []*InterceptedMessage{was, is} {
+			if m != nil {
+				notify(pushIntel, m.OwnerPlayerID)
+			}
+		}
+	})
+	forChangedEntries(prev.Loans, next.Loans, changes, dirtyLoans, func(_ string, was, is *Loan) {
+		for _, l := range []*Loan{was, is} {
+			if l != nil {
+				notify(pushLedger, l.LenderPlayerID, l.BorrowerPlayerID)
+			}
+		}
+	})
+	forChangedEntries(prev.Obligations, next.Obligations, changes, dirtyObligations, func(_ string, was, is *Obligation) {
+		for _, ob := range []*Obligation{was, is} {
+			if ob != nil {
+				notify(pushLedger, ob.CreditorPlayerID, ob.DebtorPlayerID)
+			}
+		}
+	})
+	forChangedEntries(prev.Relics, next.Relics, changes, dirtyRelics, func(_ int64, was, is *Relic) {
+		for _, r := range []*Relic{was, is} {
+			if r != nil {
+				notify(pushDashboard, r.OwnerPlayerID)
+			}
+		}
+	})
+	forChangedEntries(prev.Fights, next.Fights, changes, dirtyFights, func(_ string, was, is *Fight) {
+		for _, f := range []*Fight{was, is} {
+			if f != nil {
+				notify(pushDashboard, f.Attacker.PlayerID, f.Defender.PlayerID)
+			}
+		}
+	})
+
+	if everyone != 0 {
+		h.notifyAll(everyone)
+	}
+	for id, frags := range players {
+		if frags &^= everyone; frags != 0 {
+			h.notify(frags, id)
+		}
+	}
+}
+
+// forChangedEntries calls fn for each marked entry of a map field that
+// differs between prev and next; a missing entry is passed as the zero
+// value.
+func forChangedEntries[K comparable, V any](prev, next map[K]V, changes *dirtySet, field string, fn func(key K, was, is V)) {
+	keys, whole := changes.field(field)
+	if whole {
+		keys = map[any]struct{}{}
+		for k := range prev {
+			keys[k] = struct{}{}
+		}
+		for k := range next {
+			keys[k] = struct{}{}
+		}
+	}
+	for key := range keys {
+		k, ok := key.(K)
+		if !ok {
+			continue
+		}
+		was, is := prev[k], next[k]
+		if !reflect.DeepEqual(was, is) {
+			fn(k, was, is)
+		}
+	}
+}
+
+// sameBesidesLastSeen reports whether a and b differ at most in LastSeen.
+func sameBesidesLastSeen(a, b *Player) bool {
+	x, y := *a, *b
+	x.LastSeen, y.LastSeen = time.Time{}, time.Time{}
+	return reflect.DeepEqual(x, y)
+}
+
+// samePlayerSummary reports whether a and b show the same line in the
+// players list.
+func samePlayerSummary(a, b *Player, now time.Time) bool {
+	return a.Name == b.Name && a.Gold == b.Gold && a.Rep == b.Rep && a.Heat == b.Heat && a.NPCRole == b.NPCRole &&
+		statusSummary(a) == statusSummary(b) &&
+		(now.Sub(a.LastSeen) <= onlineWindow) == (now.Sub(b.LastSeen) <= onlineWindow)
+}
+
+type pushMessage struct {
+	Target string `json:"target"`
+	HTML   string `json:"html"`
+}
+
+// pushPageData builds the page for a push render. A toast is only consumed
+// when the toast fragment is dirty, which needs the writer; every other
+// render reads from the read view.
+func pushPageData(store *Store, playerID string, frags pushFragment) (PageData, bool) {
+	var data PageData
+	found := false
+	build := func(store *Store) {
+		if store.Players[playerID] != nil {
+			data = buildPageDataLocked(store, playerID, frags&pushToast != 0)
+			found = true
+		}
+	}
+	if frags&pushToast != 0 {
+		store.writeTracked(func() { build(store) })
+	} else {
+		build(store.readView())
+	}
+	return data, found
+}
+
+// renderPushMessages renders the dirty fragments. A toast is only sent when
+// one is waiting, so it never blanks one an action response showed.
+func renderPushMessages(tmpl *template.Template, data PageData, frags pushFragment) []pushMessage {
+	var out []pushMessage
+	var buf bytes.Buffer
+	for _, t := range pushTargets {
+		if frags&t.frag == 0 {
+			continue
+		}
+		buf.Reset()
+		if err := tmpl.ExecuteTemplate(&buf, t.template, data); err != nil {
+			continue
+		}
+		out = append(out, pushMessage{Target: t.target, HTML: buf.String()})
+	}
+	if frags&pushToast != 0 && data.Toast != "" {
+		out = append(out, pushMessage{Target: "toast", HTML: template.HTMLEscapeString(data.Toast)})
+	}
+	return out
+}
+
+func writePushMessages(w http.ResponseWriter, msgs []pushMessage) error {
+	for _, m := range msgs {
+		payload, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "event: fragment\ndata: %s\n\n", payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handlePushStream serves /push: a Server-Sent Events stream that sends
+// re-rendered fragments whenever something touching this player changes.
+// An idle stream holds no locks and does no work beyond the heartbeat.
+func handlePushStream(store *Store, tmpl *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		rc := http.NewResponseController(w)
+		// The server's WriteTimeout would otherwise cut every stream short.
+		_ = rc.SetWriteDeadline(time.Time{})
+
+		var pid string
+		store.writeTracked(func() {
+			p := ensurePlayerLocked(store, w, r)
+			p.LastSeen = time.Now().UTC()
+			pid = p.ID
+		})
+		client := store.push.subscribe(pid)
+		defer store.push.unsubscribe(client)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		if _, err := fmt.Fprint(w, "retry: 3000\n: connected\n\n"); err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(pushHeartbeatEvery)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-store.push.closing:
+				return
+			case <-heartbeat.C:
+				store.touch(pid, time.Now().UTC())
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+			case <-client.wake:
+				frags := store.push.take(client)
+				if frags == 0 {
+					continue
+				}
+				data, ok := pushPageData(store, pid, frags)
+				if !ok {
+					continue
+				}
+				if err := writePushMessages(w, renderPushMessages(tmpl, data, frags)); err != nil {
+					return
+				}
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
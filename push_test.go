@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPushHubCoalescesAndRoutes(t *testing.T) {
+	s := newTestStore()
+	a := s.push.subscribe("a")
+	b := s.push.subscribe("b")
+
+	addChatLocked(s, ChatMessage{FromPlayerID: "a", ToPlayerID: "c", Text: "psst", Kind: "whisper"})
+	if got := s.push.take(b); got != 0 {
+		t.Fatalf("bystander should not hear a whisper, pending=%b", got)
+	}
+	if got := s.push.take(a); got != pushChat {
+		t.Fatalf("whisperer should refresh chat, pending=%b", got)
+	}
+
+	<-a.wake
+	addEventLocked(s, Event{Type: "Test", Text: "one"})
+	setToastLocked(s, "a", "hello")
+	if len(a.wake) != 1 {
+		t.Fatalf("several notifications should leave a single wake-up, got %d", len(a.wake))
+	}
+	if got := s.push.take(a); got != pushEvents|pushToast {
+		t.Fatalf("pending should coalesce, got %b", got)
+	}
+
+	s.push.notifyExcept(pushShared, "a")
+	if got := s.push.take(a); got != 0 {
+		t.Fatalf("actor should be skipped, pending=%b", got)
+	}
+	if got := s.push.take(b); got&pushShared != pushShared {
+		t.Fatalf("other players should refresh shared fragments, pending=%b", got)
+	}
+
+	s.push.unsubscribe(a)
+	s.push.unsubscribe(b)
+	if n := s.push.subscriberCount(); n != 0 {
+		t.Fatalf("subscribers after unsubscribe = %d", n)
+	}
+}
+
+func TestPushNotifiesOnlyWhatABatchChanged(t *testing.T) {
+	s := newTestStore()
+	s.Players["a"] = &Player{ID: "a", Name: "Ash Crow", Gold: 10, LocationID: locationCapital}
+	s.Players["b"] = &Player{ID: "b", Name: "Bell Reed", Gold: 10, LocationID: locationCapital}
+	s.write(func() {})
+	a := s.push.subscribe("a")
+	b := s.push.subscribe("b")
+	defer s.push.unsubscribe(a)
+	defer s.push.unsubscribe(b)
+
+	s.writeTracked(func() {
+		s.dirty.mark(dirtyLastDeliverAt, "a")
+		s.LastDeliverAt["a"] = time.Now().UTC()
+	})
+	if got := s.push.take(a); got != pushOwn {
+		t.Fatalf("a private change should refresh its player's own fragments, pending=%b", got)
+	}
+	if got := s.push.take(b); got != 0 {
+		t.Fatalf("bystander should not refresh for another player's cooldown, pending=%b", got)
+	}
+
+	s.writeTracked(func() {
+		s.dirty.mark(dirtyPlayers, "a")
+		s.Players["a"].Gold = 40
+	})
+	if got := s.push.take(b); got != pushPlayers {
+		t.Fatalf("bystander should refresh only the players list, pending=%b", got)
+	}
+	if got := s.push.take(a); got != pushOwn {
+		t.Fatalf("player should refresh their own fragments, pending=%b", got)
+	}
+
+	s.mu.Lock()
+	advanceWorldLocked(s, time.Now().UTC(), false)
+	s.mu.Unlock()
+	if got := s.push.take(b); got != pushPage {
+		t.Fatalf("a tick should refresh every fragment but the toast, pending=%b", got)
+	}
+}
+
+func TestPushStreamSendsFragments(t *testing.T) {
+	s := newTestStore()
+	mux := newMux(s, parseTemplates())
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	doReq(t, mux, http.MethodGet, "/", nil, "listener", "")
+	doReq(t, mux, http.MethodGet, "/", nil, "speaker", "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/push", nil)
+	req.AddCookie(&http.Cookie{Name: cookieName, Value: playerCookieValue("listener")})
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type = %q", ct)
+	}
+
+	lines := bufio.NewScanner(resp.Body)
+	next := func() pushMessage {
+		t.Helper()
+		for lines.Scan() {
+			data, ok := strings.CutPrefix(lines.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var msg pushMessage
+			if err := json.Unmarshal([]byte(data), &msg); err != nil {
+				t.Fatalf("decode %q: %v", data, err)
+			}
+			return msg
+		}
+		t.Fatalf("stream ended: %v", lines.Err())
+		return pushMessage{}
+	}
+	for lines.Scan() && lines.Text() != ": connected" {
+	}
+	if n := s.push.subscriberCount(); n != 1 {
+		t.Fatalf("subscribers = %d, want 1", n)
+	}
+
+	doReq(t, mux, http.MethodPost, "/chat", url.Values{"text": {"grain at dawn"}}, "speaker", "")
+	seen := map[string]string{}
+	for seen["chat"] == "" {
+		msg := next()
+		seen[msg.Target] = msg.HTML
+	}
+	if !strings.Contains(seen["chat"], "grain at dawn") {
+		t.Fatalf("pushed chat missing the new line: %s", seen["chat"])
+	}
+
+	s.mu.Lock()
+	advanceWorldLocked(s, time.Now().UTC(), false)
+	s.mu.Unlock()
+	seen = map[string]string{}
+	for seen["dashboard"] == "" || seen["market"] == "" {
+		msg := next()
+		seen[msg.Target] = msg.HTML
+	}
+}
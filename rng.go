@@ -0,0 +1,193 @@
+package main
+
+import (
+	"hash/fnv"
+	"log"
+	"maps"
+	mathrand "math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	worldSeedEnvName = "WORLD_SEED"
+	rngHistoryTicks  = 96
+
+	rngStreamWorld     = "world"
+	rngStreamCrisis    = "crisis"
+	rngStreamMarket    = "market"
+	rngStreamIntel     = "intel"
+	rngStreamFieldwork = "fieldwork"
+	rngStreamNames     = "names"
+	rngStreamCaravan   = "caravan"
+	rngStreamCombat    = "combat"
+
+	// Draws that are not percent rolls (picks from a list, volumes) go on
+	// their own streams so the streams above stay replayable.
+	rngStreamCrisisPick    = "crisis.pick"
+	rngStreamMarketVolume  = "market.volume"
+	rngStreamFieldworkLoot = "fieldwork.loot"
+	rngStreamCombatLoot    = "combat.loot"
+)
+
+// knownRNGStreams are the streams drawn only through Intn(100), so their
+// rolls can be replayed from the draw counts alone.
+var knownRNGStreams = []string{rngStreamWorld, rngStreamCrisis, rngStreamMarket, rngStreamIntel, rngStreamFieldwork, rngStreamCaravan, rngStreamCombat}
+
+// worldRNG is a counter-based generator split into named streams. The n-th
+// draw of a stream during a tick depends only on (Seed, stream, tick, n), so
+// a roll added to one subsystem never shifts the outcomes of another, and a
+// tick can be replayed from the seed and its draw counts alone.
+type worldRNG struct {
+	Seed    int64
+	Tick    int64
+	Draws   map[string]uint64
+	History map[int64]map[string]uint64
+
+	streams map[string]*mathrand.Rand
+}
+
+type rngStreamSource struct {
+	rng  *worldRNG
+	name string
+}
+
+func (s *rngStreamSource) Uint64() uint64 {
+	n := s.rng.Draws[s.name]
+	s.rng.Draws[s.name] = n + 1
+	return rngValue(s.rng.Seed, s.name, s.rng.Tick, n)
+}
+
+func (s *rngStreamSource) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+func (s *rngStreamSource) Seed(int64) {}
+
+func newWorldRNG(seed int64) *worldRNG {
+	return &worldRNG{
+		Seed:    seed,
+		Draws:   map[string]uint64{},
+		History: map[int64]map[string]uint64{},
+		streams: map[string]*mathrand.Rand{},
+	}
+}
+
+// clone copies the generator's seed and draw counts for a read-only view.
+func (w *worldRNG) clone() *worldRNG {
+	if w == nil {
+		return nil
+	}
+	c := &worldRNG{Seed: w.Seed, Tick: w.Tick, Draws: maps.Clone(w.Draws), History: make(map[int64]map[string]uint64, len(w.History))}
+	for tick, draws := range w.History {
+		c.History[tick] = maps.Clone(draws)
+	}
+	return c
+}
+
+// worldSeedFromEnv reads WORLD_SEED, falling back to the clock when unset or
+// malformed.
+func worldSeedFromEnv(now time.Time) int64 {
+	raw := strings.TrimSpace(os.Getenv(worldSeedEnvName))
+	if raw == "" {
+		return now.UnixNano()
+	}
+	seed, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		log.Printf("warning: ignoring invalid %s=%q: %v", worldSeedEnvName, raw, err)
+		return now.UnixNano()
+	}
+	return seed
+}
+
+func rngValue(seed int64, stream string, tick int64, n uint64) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(stream))
+	x := uint64(seed) ^ h.Sum64()
+	x = splitMix64(x ^ splitMix64(uint64(tick)))
+	return splitMix64(x + n*0x9e3779b97f4a7c15)
+}
+
+func splitMix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// stream returns the generator for name positioned at tick. Moving to a new
+// tick archives the previous tick's draw counts for replay.
+func (w *worldRNG) stream(name string, tick int64) *mathrand.Rand {
+	if tick != w.Tick {
+		if len(w.Draws) > 0 {
+			w.History[w.Tick] = w.Draws
+		}
+		for t := range w.History {
+			if t <= tick-rngHistoryTicks || t > tick {
+				delete(w.History, t)
+			}
+		}
+		w.Tick = tick
+		w.Draws = map[string]uint64{}
+	}
+	if w.streams == nil {
+		w.streams = map[string]*mathrand.Rand{}
+	}
+	r := w.streams[name]
+	if r == nil {
+		r = mathrand.New(&rngStreamSource{rng: w, name: name})
+		w.streams[name] = r
+	}
+	return r
+}
+
+func rngStreamLocked(store *Store, name string) *mathrand.Rand {
+	if store.rng == nil {
+		store.rng = newWorldRNG(worldSeedFromEnv(time.Now().UTC()))
+	}
+	return store.rng.stream(name, store.TickCount)
+}
+
+// RollTrace is the sequence of rollPercent values (0-99) a stream drew during
+// one tick. A roll hits when its value is below the chance being tested.
+type RollTrace struct {
+	Tick   int64  `json:"tick"`
+	Stream string `json:"stream"`
+	Draws  uint64 `json:"draws"`
+	Rolls  []int  `json:"rolls"`
+}
+
+// replayRollsLocked reconstructs the rollPercent values stream produced for
+// ticks from..to. Ticks older than the retained history report no draws.
+func replayRollsLocked(store *Store, stream string, from, to int64) []RollTrace {
+	if store.rng == nil || to < from {
+		return nil
+	}
+	out := []RollTrace{}
+	for tick := from; tick <= to; tick++ {
+		var draws uint64
+		if tick == store.rng.Tick {
+			draws = store.rng.Draws[stream]
+		} else {
+			draws = store.rng.History[tick][stream]
+		}
+		out = append(out, RollTrace{Tick: tick, Stream: stream, Draws: draws, Rolls: replayStreamRolls(store.rng.Seed, stream, tick, draws)})
+	}
+	return out
+}
+
+// replayStreamRolls regenerates Intn(100) values until draws source values
+// are consumed, mirroring how rollPercent reads the stream. It is only
+// meaningful for knownRNGStreams.
+func replayStreamRolls(seed int64, stream string, tick int64, draws uint64) []int {
+	w := newWorldRNG(seed)
+	w.Tick = tick
+	r := w.stream(stream, tick)
+	rolls := []int{}
+	for w.Draws[stream] < draws {
+		rolls = append(rolls, r.Intn(100))
+	}
+	return rolls
+}
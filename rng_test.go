@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWorldRNGStreamsAreIndependent(t *testing.T) {
+	a := newWorldRNG(7)
+	b := newWorldRNG(7)
+
+	// Extra draws on one stream must not shift another stream's sequence.
+	for i := 0; i < 5; i++ {
+		b.stream(rngStreamMarket, 3).Intn(100)
+	}
+	for i := 0; i < 10; i++ {
+		if x, y := a.stream(rngStreamCrisis, 3).Intn(100), b.stream(rngStreamCrisis, 3).Intn(100); x != y {
+			t.Fatalf("crisis draw %d diverged: %d vs %d", i, x, y)
+		}
+	}
+
+	if newWorldRNG(7).stream(rngStreamCrisis, 4).Int63() == newWorldRNG(7).stream(rngStreamCrisis, 5).Int63() {
+		t.Fatalf("different ticks should yield different first draws")
+	}
+	if newWorldRNG(7).stream(rngStreamCrisis, 4).Int63() == newWorldRNG(8).stream(rngStreamCrisis, 4).Int63() {
+		t.Fatalf("different seeds should yield different first draws")
+	}
+}
+
+func TestWorldSeedFromEnv(t *testing.T) {
+	now := time.Date(2026, 2, 12, 12, 0, 0, 0, time.UTC)
+	t.Setenv(worldSeedEnvName, "4242")
+	if got := worldSeedFromEnv(now); got != 4242 {
+		t.Fatalf("expected env seed, got %d", got)
+	}
+	t.Setenv(worldSeedEnvName, "not-a-number")
+	if got := worldSeedFromEnv(now); got != now.UnixNano() {
+		t.Fatalf("invalid seed should fall back to clock, got %d", got)
+	}
+}
+
+func TestReplayRollsMatchesRollPercent(t *testing.T) {
+	s := newTestStore()
+	s.TickCount = 5
+	seen := []bool{}
+	for i := 0; i < 4; i++ {
+		seen = append(seen, rollPercent(rngStreamLocked(s, rngStreamIntel), 50))
+	}
+	s.TickCount = 6
+	rollPercent(rngStreamLocked(s, rngStreamIntel), 50)
+
+	traces := replayRollsLocked(s, rngStreamIntel, 5, 6)
+	if len(traces) != 2 {
+		t.Fatalf("expected two traces, got %+v", traces)
+	}
+	if traces[0].Tick != 5 || len(traces[0].Rolls) != 4 || len(traces[1].Rolls) != 1 {
+		t.Fatalf("unexpected traces: %+v", traces)
+	}
+	for i, roll := range traces[0].Rolls {
+		if (roll < 50) != seen[i] {
+			t.Fatalf("replayed roll %d=%d disagrees with observed hit=%v", i, roll, seen[i])
+		}
+	}
+}
+
+func TestRNGSeedAndCountersPersist(t *testing.T) {
+	t.Setenv("DB_DIALECT", "sqlite")
+	t.Setenv("DB_SQLITE_PATH", filepath.Join(t.TempDir(), "rng.sqlite"))
+	repo, err := openRepositoryFromEnv()
+	if err != nil {
+		t.Fatalf("openRepositoryFromEnv error: %v", err)
+	}
+	defer repo.db.Close()
+
+	s1 := newStore()
+	s1.rng = newWorldRNG(99)
+	s1.TickCount = 3
+	rngStreamLocked(s1, rngStreamCrisis).Intn(100)
+	if err := repo.Save(context.Background(), s1); err != nil {
+		t.Fatalf("save error: %v", err)
+	}
+	next := rngStreamLocked(s1, rngStreamCrisis).Intn(100)
+
+	s2 := newStore()
+	if err := repo.LoadInto(context.Background(), s2); err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if s2.rng.Seed != 99 || s2.rng.Draws[rngStreamCrisis] != 1 {
+		t.Fatalf("rng state not restored: %+v", s2.rng)
+	}
+	if got := rngStreamLocked(s2, rngStreamCrisis).Intn(100); got != next {
+		t.Fatalf("restored stream should continue the sequence: got %d want %d", got, next)
+	}
+}
+
+func TestAdminRNGReplayEndpoint(t *testing.T) {
+	t.Setenv(adminLoopbackEnvName, "true")
+	s := newTestStore()
+	mux := newMux(s, parseTemplates())
+	s.TickCount = 2
+	rollPercent(rngStreamLocked(s, rngStreamCrisis), 10)
+
+	bad := doReq(t, mux, http.MethodGet, "/admin/rng?stream=bogus", nil, "", "127.0.0.1:1111")
+	if bad.Code != http.StatusBadRequest {
+		t.Fatalf("unknown stream should be rejected, got %d", bad.Code)
+	}
+	r := doReq(t, mux, http.MethodGet, "/admin/rng?stream=crisis&from=2&to=2", nil, "", "127.0.0.1:1111")
+	if r.Code != http.StatusOK {
+		t.Fatalf("GET /admin/rng status=%d body=%s", r.Code, r.Body.String())
+	}
+	var payload struct {
+		Seed  int64       `json:"seed"`
+		Ticks []RollTrace `json:"ticks"`
+	}
+	if err := json.Unmarshal(r.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if payload.Seed != 13 || len(payload.Ticks) != 1 || len(payload.Ticks[0].Rolls) != 1 {
+		t.Fatalf("unexpected replay payload: %+v", payload)
+	}
+}
+
+func TestCrisisPickDoesNotShiftCrisisRolls(t *testing.T) {
+	s := newTestStore()
+	// Seed 13 rolls 0 on the crisis stream at tick 94, so a crisis starts.
+	s.TickCount = 94
+	maybeStartCrisisLocked(s, time.Now().UTC())
+	if s.ActiveCrisis == nil {
+		t.Fatalf("expected a crisis to start at tick 94")
+	}
+	if got := s.rng.Draws[rngStreamCrisisPick]; got != 1 {
+		t.Fatalf("crisis pick should draw from its own stream, got %d draws", got)
+	}
+	traces := replayRollsLocked(s, rngStreamCrisis, 94, 94)
+	if len(traces) != 1 || traces[0].Draws != 1 || len(traces[0].Rolls) != 1 || traces[0].Rolls[0] != 0 {
+		t.Fatalf("crisis replay should hold only the start roll, got %+v", traces)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// shutdownTimeout bounds how long in-flight requests may take to finish
+// once shutdown starts; connections still open after it are closed.
+const shutdownTimeout = 15 * time.Second
+
+// runServer serves on ln and runs the schedulers until ctx is done, then
+// shuts down in order: stop the schedulers and stop accepting requests,
+// drain in-flight handlers and push streams, drain the writer, make one last
+// save and close the database. Every tick and action runs whole on the
+// writer and is saved in a single transaction, so stopping at any point
+// never leaves half of one on disk.
+func runServer(ctx context.Context, server *http.Server, ln net.Listener, store *Store) error {
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	tickDone := startTickScheduler(ctx, store)
+	cleanupDone := startCleanupScheduler(ctx, store)
+	server.RegisterOnShutdown(store.push.close)
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.Serve(ln) }()
+
+	var err error
+	select {
+	case <-ctx.Done():
+		log.Printf("shutting down")
+	case err = <-serveErr:
+		err = fmt.Errorf("serve: %w", err)
+		serveErr = nil
+	}
+	stop()
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if shutdownErr := server.Shutdown(drainCtx); shutdownErr != nil {
+		log.Printf("requests did not drain in time, closing connections: %v", shutdownErr)
+		_ = server.Close()
+	}
+	if serveErr != nil {
+		if e := <-serveErr; !errors.Is(e, http.ErrServerClosed) {
+			err = fmt.Errorf("serve: %w", e)
+		}
+	}
+	<-tickDone
+	<-cleanupDone
+
+	return errors.Join(err, closeStore(store))
+}
+
+// closeStore drains the writer, saves whatever it has not yet saved and
+// closes the database. Writes that arrive later run inline and are saved as
+// they happen, but with the database closed they only log.
+func closeStore(store *Store) error {
+	store.closeWriter()
+	if store.repo == nil {
+		return nil
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	mergePresenceLocked(store)
+	if err := store.repo.Save(context.Background(), store); err != nil {
+		return fmt.Errorf("final save: %w", err)
+	}
+	if err := store.repo.db.Close(); err != nil {
+		return fmt.Errorf("close database: %w", err)
+	}
+	return nil
+}
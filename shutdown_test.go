@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunServerDrainsAndSavesOnShutdown(t *testing.T) {
+	t.Setenv("DB_DIALECT", "sqlite")
+	t.Setenv("DB_SQLITE_PATH", filepath.Join(t.TempDir(), "shutdown.sqlite"))
+	s, err := newConfiguredStore()
+	if err != nil {
+		t.Fatalf("newConfiguredStore error: %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	server := &http.Server{Handler: newMux(s, parseTemplates())}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stopped := make(chan error, 1)
+	go func() { stopped <- runServer(ctx, server, ln, s) }()
+	base := "http://" + ln.Addr().String()
+
+	resp, err := http.Get(base + "/frag/events")
+	if err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	resp.Body.Close()
+	var cookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == cookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatalf("expected a player cookie")
+	}
+	pid, _ := parsePlayerCookieValue(cookie.Value)
+
+	// An open push stream must not hold shutdown up.
+	req, _ := http.NewRequest(http.MethodGet, base+"/push", nil)
+	req.AddCookie(cookie)
+	stream, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	defer stream.Body.Close()
+	lines := bufio.NewScanner(stream.Body)
+	for lines.Scan() && lines.Text() != ": connected" {
+	}
+
+	// Cancel while a command is half way through; it must finish and land on
+	// disk whole.
+	halfway := make(chan struct{})
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		s.write(func() {
+			s.Players[pid].Gold = 77
+			close(halfway)
+			time.Sleep(100 * time.Millisecond)
+			s.Players[pid].Grain = 9
+		})
+	}()
+	<-halfway
+	cancel()
+
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Fatalf("runServer error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("shutdown did not finish")
+	}
+	<-written
+	if _, err := io.ReadAll(stream.Body); err != nil {
+		t.Fatalf("push stream should end cleanly: %v", err)
+	}
+	if err := s.repo.db.Ping(); err == nil {
+		t.Fatalf("database should be closed after shutdown")
+	}
+
+	repo, err := openRepositoryFromEnv()
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer repo.db.Close()
+	loaded := newStore()
+	if err := repo.LoadInto(context.Background(), loaded); err != nil {
+		t.Fatalf("LoadInto error: %v", err)
+	}
+	if p := loaded.Players[pid]; p == nil || p.Gold != 77 || p.Grain != 9 {
+		t.Fatalf("interrupted command should be saved whole, got %+v", p)
+	}
+}
@@ -0,0 +1,490 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	simDefaultTicks  = 2000
+	simDefaultAgents = "greedy=2,smuggler=2,rumor=1,seat=1"
+)
+
+var simEpoch = time.Date(2026, 1, 1, 6, 0, 0, 0, time.UTC)
+
+// simPolicy decides one action per tick for a scripted agent. Policies only
+// see the Store and act through handleActionInputLocked, exactly like a
+// player posting to /action.
+type simPolicy interface {
+	Act(store *Store, p *Player, rng *mathrand.Rand) (ActionInput, bool)
+}
+
+var simPolicies = map[string]func() simPolicy{
+	"greedy":   func() simPolicy { return &greedyTraderPolicy{} },
+	"smuggler": func() simPolicy { return smugglerPolicy{} },
+	"rumor":    func() simPolicy { return rumorMongerPolicy{} },
+	"seat":     func() simPolicy { return seatHolderPolicy{} },
+}
+
+type simAgentSpec struct {
+	Policy string
+	Count  int
+}
+
+type simConfig struct {
+	Ticks  int
+	Seed   int64
+	Every  int
+	Agents []simAgentSpec
+}
+
+type simAgent struct {
+	PlayerID string
+	Policy   string
+	policy   simPolicy
+}
+
+// simSample is one row of the time series, cumulative where noted.
+type simSample struct {
+	Tick               int64   `json:"tick"`
+	Day                int     `json:"day"`
+	GrainSupply        int     `json:"grain_supply"`
+	GrainTier          string  `json:"grain_tier"`
+	Unrest             int     `json:"unrest"`
+	UnrestTier         string  `json:"unrest_tier"`
+	GoldTotal          int     `json:"gold_total"`
+	GoldGini           float64 `json:"gold_gini"`
+	Crisis             string  `json:"crisis"`
+	CrisesStarted      int     `json:"crises_started"`
+	ContractsCompleted int     `json:"contracts_completed"`
+	ContractsFailed    int     `json:"contracts_failed"`
+	ContractFailRate   float64 `json:"contract_fail_rate"`
+}
+
+type simSummary struct {
+	Ticks              int            `json:"ticks"`
+	Seed               int64          `json:"seed"`
+	Agents             map[string]int `json:"agents"`
+	CrisesStarted      int            `json:"crises_started"`
+	CrisesPer100Ticks  float64        `json:"crises_per_100_ticks"`
+	ContractsCompleted int            `json:"contracts_completed"`
+	ContractsFailed    int            `json:"contracts_failed"`
+	ContractFailRate   float64        `json:"contract_fail_rate"`
+	FinalGoldGini      float64        `json:"final_gold_gini"`
+	MeanUnrest         float64        `json:"mean_unrest"`
+	MinGrainSupply     int            `json:"min_grain_supply"`
+}
+
+type simReport struct {
+	Summary simSummary  `json:"summary"`
+	Samples []simSample `json:"samples"`
+}
+
+// runSimulateCommand implements `black-granary simulate`.
+func runSimulateCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	ticks := fs.Int("ticks", simDefaultTicks, "number of world ticks to simulate")
+	seed := fs.Int64("seed", 1, "world and agent seed")
+	every := fs.Int("every", 1, "emit a sample every N ticks")
+	agents := fs.String("agents", simDefaultAgents, "comma-separated policy=count list (greedy, smuggler, rumor, seat)")
+	format := fs.String("format", "csv", "output format: csv or json")
+	out := fs.String("out", "", "write output to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	specs, err := parseSimAgents(*agents)
+	if err != nil {
+		fmt.Fprintf(stderr, "simulate: %v\n", err)
+		return 2
+	}
+	if *format != "csv" && *format != "json" {
+		fmt.Fprintf(stderr, "simulate: unknown format %q\n", *format)
+		return 2
+	}
+	report, err := runSimulation(simConfig{Ticks: *ticks, Seed: *seed, Every: *every, Agents: specs})
+	if err != nil {
+		fmt.Fprintf(stderr, "simulate: %v\n", err)
+		return 2
+	}
+
+	w := stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(stderr, "simulate: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = writeSimCSV(w, report.Samples)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "simulate: write output: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stderr, "simulated %d ticks: %d crises, contract fail rate %.2f, final gold gini %.3f\n",
+		report.Summary.Ticks, report.Summary.CrisesStarted, report.Summary.ContractFailRate, report.Summary.FinalGoldGini)
+	return 0
+}
+
+func parseSimAgents(raw string) ([]simAgentSpec, error) {
+	var specs []simAgentSpec
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, countRaw, found := strings.Cut(part, "=")
+		count := 1
+		if found {
+			n, err := strconv.Atoi(strings.TrimSpace(countRaw))
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid agent count in %q", part)
+			}
+			count = n
+		}
+		name = strings.TrimSpace(name)
+		if simPolicies[name] == nil {
+			return nil, fmt.Errorf("unknown agent policy %q", name)
+		}
+		specs = append(specs, simAgentSpec{Policy: name, Count: count})
+	}
+	if len(specs) == 0 {
+		return nil, errors.New("at least one agent is required")
+	}
+	return specs, nil
+}
+
+// runSimulation plays cfg.Ticks world ticks on an in-memory Store. Each tick
+// every agent takes one action, then the world advances one tick on a virtual
+// clock spaced by the store's tick cadence.
+func runSimulation(cfg simConfig) (simReport, error) {
+	if cfg.Ticks <= 0 {
+		return simReport{}, errors.New("ticks must be positive")
+	}
+	if cfg.Every <= 0 {
+		cfg.Every = 1
+	}
+	store := newStore()
+	store.rng = newWorldRNG(cfg.Seed)
+	rng := mathrand.New(mathrand.NewSource(cfg.Seed))
+	now := simEpoch
+	store.LastTickAt = now
+
+	summary := simSummary{Ticks: cfg.Ticks, Seed: cfg.Seed, Agents: map[string]int{}, MinGrainSupply: store.World.GrainSupply}
+	var agents []simAgent
+	for _, spec := range cfg.Agents {
+		for i := 1; i <= spec.Count; i++ {
+			id := fmt.Sprintf("sim-%s-%d", spec.Policy, i)
+			addPlayerLocked(store, id, fmt.Sprintf("%s %d", strings.ToUpper(spec.Policy[:1])+spec.Policy[1:], i), now)
+			agents = append(agents, simAgent{PlayerID: id, Policy: spec.Policy, policy: simPolicies[spec.Policy]()})
+		}
+		summary.Agents[spec.Policy] += spec.Count
+	}
+
+	report := simReport{}
+	var prevCrisis *Crisis
+	unrestSum := 0
+	for step := 0; step < cfg.Ticks; step++ {
+		now = now.Add(store.TickEvery)
+		for _, a := range agents {
+			p := store.Players[a.PlayerID]
+			if p == nil {
+				continue
+			}
+			p.LastSeen = now
+			in, ok := a.policy.Act(store, p, rng)
+			if !ok {
+				continue
+			}
+			store.LastActionAt[p.ID] = now
+			handleActionInputLocked(store, p, now, in)
+		}
+		store.ToastByPlayer = map[string]string{}
+
+		today := now.Format("2006-01-02")
+		applyWorldTickLocked(store, now, store.LastDailyTickDate != today)
+		if store.LastCleanupDate != today {
+			runDailyCleanupLocked(store, now)
+			store.LastCleanupDate = today
+		}
+
+		if store.ActiveCrisis != nil && store.ActiveCrisis != prevCrisis {
+			summary.CrisesStarted++
+		}
+		prevCrisis = store.ActiveCrisis
+		// Terminal contracts are counted and dropped, as a restart would: only
+		// Issued and Accepted contracts are ever persisted.
+		for id, c := range store.Contracts {
+			switch c.Status {
+			case "Completed":
+				summary.ContractsCompleted++
+			case "Failed":
+				summary.ContractsFailed++
+			case "Ignored", "Cancelled":
+			default:
+				continue
+			}
+			delete(store.Contracts, id)
+		}
+		unrestSum += store.World.UnrestValue
+		summary.MinGrainSupply = minInt(summary.MinGrainSupply, store.World.GrainSupply)
+
+		if (step+1)%cfg.Every == 0 || step == cfg.Ticks-1 {
+			report.Samples = append(report.Samples, sampleSimLocked(store, summary))
+		}
+	}
+
+	summary.CrisesPer100Ticks = float64(summary.CrisesStarted) * 100 / float64(cfg.Ticks)
+	summary.ContractFailRate = simFailRate(summary.ContractsCompleted, summary.ContractsFailed)
+	summary.FinalGoldGini = goldGiniLocked(store)
+	summary.MeanUnrest = float64(unrestSum) / float64(cfg.Ticks)
+	report.Summary = summary
+	return report, nil
+}
+
+func sampleSimLocked(store *Store, summary simSummary) simSample {
+	s := simSample{
+		Tick:               store.TickCount,
+		Day:                store.World.DayNumber,
+		GrainSupply:        store.World.GrainSupply,
+		GrainTier:          store.World.GrainTier,
+		Unrest:             store.World.UnrestValue,
+		UnrestTier:         store.World.UnrestTier,
+		GoldGini:           goldGiniLocked(store),
+		CrisesStarted:      summary.CrisesStarted,
+		ContractsCompleted: summary.ContractsCompleted,
+		ContractsFailed:    summary.ContractsFailed,
+		ContractFailRate:   simFailRate(summary.ContractsCompleted, summary.ContractsFailed),
+	}
+	for _, p := range store.Players {
+		s.GoldTotal += p.Gold
+	}
+	if store.ActiveCrisis != nil {
+		s.Crisis = store.ActiveCrisis.Type
+	}
+	return s
+}
+
+func simFailRate(completed, failed int) float64 {
+	if completed+failed == 0 {
+		return 0
+	}
+	return float64(failed) / float64(completed+failed)
+}
+
+// goldGiniLocked is the Gini coefficient of player gold: 0 when everyone
+// holds the same, approaching 1 when one player holds it all.
+func goldGiniLocked(store *Store) float64 {
+	gold := make([]int, 0, len(store.Players))
+	total := 0
+	for _, p := range store.Players {
+		g := maxInt(0, p.Gold)
+		gold = append(gold, g)
+		total += g
+	}
+	if len(gold) == 0 || total == 0 {
+		return 0
+	}
+	sort.Ints(gold)
+	weighted := 0
+	for i, g := range gold {
+		weighted += (i + 1) * g
+	}
+	n := float64(len(gold))
+	return 2*float64(weighted)/(n*float64(total)) - (n+1)/n
+}
+
+func writeSimCSV(w io.Writer, samples []simSample) error {
+	cw := csv.NewWriter(w)
+	header := []string{"tick", "day", "grain_supply", "grain_tier", "unrest", "unrest_tier", "gold_total", "gold_gini", "crisis", "crises_started", "contracts_completed", "contracts_failed", "contract_fail_rate"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, s := range samples {
+		row := []string{
+			strconv.FormatInt(s.Tick, 10),
+			strconv.Itoa(s.Day),
+			strconv.Itoa(s.GrainSupply),
+			s.GrainTier,
+			strconv.Itoa(s.Unrest),
+			s.UnrestTier,
+			strconv.Itoa(s.GoldTotal),
+			strconv.FormatFloat(s.GoldGini, 'f', 4, 64),
+			s.Crisis,
+			strconv.Itoa(s.CrisesStarted),
+			strconv.Itoa(s.ContractsCompleted),
+			strconv.Itoa(s.ContractsFailed),
+			strconv.FormatFloat(s.ContractFailRate, 'f', 4, 64),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func ownAcceptedContractLocked(store *Store, playerID string) *Contract {
+	for _, c := range sortedContractsLocked(store) {
+		if c.OwnerPlayerID == playerID && (c.Status == "Accepted" || c.Status == "Fulfilled") {
+			return c
+		}
+	}
+	return nil
+}
+
+func richestRivalLocked(store *Store, playerID string) *Player {
+	var best *Player
+	for _, id := range sortedKeys(store.Players) {
+		p := store.Players[id]
+		if p.ID == playerID {
+			continue
+		}
+		if best == nil || p.Gold > best.Gold {
+			best = p
+		}
+	}
+	return best
+}
+
+// greedyTraderPolicy buys grain while the local market is calm and sells
+// once scarcity lifts the price above what it paid.
+type greedyTraderPolicy struct {
+	paid int
+}
+
+func (g *greedyTraderPolicy) Act(store *Store, p *Player, rng *mathrand.Rand) (ActionInput, bool) {
+	locationID, grain := marketLocationID(p), grainCommodity()
+	if good, ok := localGood(locationID, grain.ID); ok {
+		_, buy, sell := marketPricesLocked(store, locationID, grain, good)
+		if p.Grain > 0 && sell > g.paid {
+			return ActionInput{Action: "sell_grain", Amount: p.Grain}, true
+		}
+		if marketTierLocked(store, locationID, grain, good) == "Stable" && p.Gold >= buy {
+			amount := minInt(marketMaxTrade, p.Gold/buy)
+			if g.paid == 0 || p.Grain == 0 {
+				g.paid = buy
+			}
+			return ActionInput{Action: "buy_grain", Amount: amount}, true
+		}
+	}
+	if c := ownAcceptedContractLocked(store, p.ID); c != nil {
+		return ActionInput{Action: "deliver", ContractID: c.ID}, true
+	}
+	for _, c := range sortedContractsLocked(store) {
+		if c.Status == "Issued" && c.Type == "Supply" && p.Grain >= c.SupplySacks {
+			return ActionInput{Action: "accept", ContractID: c.ID}, true
+		}
+	}
+	return ActionInput{}, false
+}
+
+// smugglerPolicy takes the riskiest contract on the board and pushes it to
+// delivery as fast as cooldowns allow.
+type smugglerPolicy struct{}
+
+func (smugglerPolicy) Act(store *Store, p *Player, rng *mathrand.Rand) (ActionInput, bool) {
+	if c := ownAcceptedContractLocked(store, p.ID); c != nil {
+		if c.Status == "Fulfilled" || rng.Intn(3) == 0 {
+			return ActionInput{Action: "deliver", ContractID: c.ID}, true
+		}
+		return ActionInput{}, false
+	}
+	var pick *Contract
+	for _, c := range sortedContractsLocked(store) {
+		if c.Status != "Issued" || c.Type == "Supply" || c.Type == "Bounty" {
+			continue
+		}
+		if pick == nil || c.Type == "Smuggling" {
+			pick = c
+		}
+	}
+	if pick == nil {
+		return ActionInput{}, false
+	}
+	stance := contractStanceFast
+	if pick.Type == "Smuggling" {
+		stance = contractStanceQuiet
+	}
+	return ActionInput{Action: "accept", ContractID: pick.ID, Stance: stance}, true
+}
+
+// rumorMongerPolicy targets the richest rival with rumors and forgeries,
+// investigating between operations.
+type rumorMongerPolicy struct{}
+
+func (rumorMongerPolicy) Act(store *Store, p *Player, rng *mathrand.Rand) (ActionInput, bool) {
+	target := richestRivalLocked(store, p.ID)
+	if target == nil {
+		return ActionInput{Action: "investigate"}, true
+	}
+	switch rng.Intn(3) {
+	case 0:
+		return ActionInput{Action: "seed_rumor", TargetID: target.ID}, true
+	case 1:
+		return ActionInput{Action: "forge_evidence", TargetID: target.ID}, true
+	default:
+		return ActionInput{Action: "investigate", TargetID: target.ID}, true
+	}
+}
+
+// seatHolderPolicy stands and votes for itself in open elections, challenges
+// incumbents, answers crises, and sets taxes against unrest once it holds
+// the purse.
+type seatHolderPolicy struct{}
+
+func (seatHolderPolicy) Act(store *Store, p *Player, rng *mathrand.Rand) (ActionInput, bool) {
+	for _, id := range sortedKeys(store.Seats) {
+		seat := store.Seats[id]
+		if seat.ElectionWindowTicks <= 0 {
+			continue
+		}
+		if seatCandidate(seat, p.ID) == nil {
+			if seat.HolderPlayerID != p.ID && p.Gold >= electionFilingFee {
+				return ActionInput{Action: "campaign_seat", ContractID: id}, true
+			}
+			continue
+		}
+		if seat.Ballots[p.ID].CandidateID != p.ID && ballotWeightLocked(store, seat, p) > 0 {
+			return ActionInput{Action: "cast_ballot", ContractID: id, TargetID: p.ID}, true
+		}
+	}
+	if store.ActiveCrisis != nil && !store.ActiveCrisis.Mitigated {
+		if def, ok := crisisDefinitionFor(store.ActiveCrisis); ok {
+			if _, need := costShortfall(p, def.GoldCost, commodityCostList(def.GrainCost, def.Costs)); need == "" {
+				return ActionInput{Action: "respond_crisis"}, true
+			}
+		}
+	}
+	if playerHoldsSeatLocked(store, p.ID, "master_of_coin") {
+		if store.World.UnrestValue >= 50 && store.Policies.TaxRatePct != 5 {
+			return ActionInput{Action: "set_tax_low"}, true
+		}
+		if store.World.UnrestValue < 25 && store.Policies.TaxRatePct != 20 {
+			return ActionInput{Action: "set_tax_high"}, true
+		}
+	}
+	if seats := sortedKeys(store.Seats); len(seats) > 0 && rng.Intn(10) == 0 {
+		return ActionInput{Action: "challenge_seat", ContractID: seats[rng.Intn(len(seats))]}, true
+	}
+	return ActionInput{Action: "investigate"}, true
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseSimAgents(t *testing.T) {
+	specs, err := parseSimAgents("greedy=3, seat ,rumor=0")
+	if err != nil {
+		t.Fatalf("parseSimAgents error: %v", err)
+	}
+	if len(specs) != 3 || specs[0] != (simAgentSpec{Policy: "greedy", Count: 3}) || specs[1].Count != 1 || specs[2].Count != 0 {
+		t.Fatalf("unexpected specs: %+v", specs)
+	}
+	for _, bad := range []string{"", "banker=2", "greedy=x", "greedy=-1"} {
+		if _, err := parseSimAgents(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestRunSimulationIsDeterministic(t *testing.T) {
+	cfg := simConfig{Ticks: 300, Seed: 7, Every: 50, Agents: []simAgentSpec{{"greedy", 2}, {"smuggler", 2}, {"rumor", 1}, {"seat", 1}}}
+	a, err := runSimulation(cfg)
+	if err != nil {
+		t.Fatalf("runSimulation error: %v", err)
+	}
+	b, err := runSimulation(cfg)
+	if err != nil {
+		t.Fatalf("runSimulation error: %v", err)
+	}
+	if asJSON(a) != asJSON(b) {
+		t.Fatalf("same seed should produce the same report")
+	}
+	if len(a.Samples) != 6 || a.Samples[5].Tick != 300 {
+		t.Fatalf("expected a sample every 50 ticks, got %+v", a.Samples)
+	}
+	if a.Summary.ContractsCompleted+a.Summary.ContractsFailed == 0 {
+		t.Fatalf("agents should resolve some contracts: %+v", a.Summary)
+	}
+	if a.Summary.FinalGoldGini < 0 || a.Summary.FinalGoldGini >= 1 {
+		t.Fatalf("gini out of range: %v", a.Summary.FinalGoldGini)
+	}
+
+	cfg.Seed = 8
+	c, err := runSimulation(cfg)
+	if err != nil {
+		t.Fatalf("runSimulation error: %v", err)
+	}
+	if asJSON(a.Samples) == asJSON(c.Samples) {
+		t.Fatalf("different seeds should diverge")
+	}
+}
+
+func TestGoldGini(t *testing.T) {
+	s := newTestStore()
+	if got := goldGiniLocked(s); got != 0 {
+		t.Fatalf("empty city gini = %v", got)
+	}
+	s.Players["a"] = &Player{ID: "a", Gold: 10}
+	s.Players["b"] = &Player{ID: "b", Gold: 10}
+	if got := goldGiniLocked(s); got != 0 {
+		t.Fatalf("equal gold gini = %v", got)
+	}
+	s.Players["a"].Gold = 0
+	if got := goldGiniLocked(s); got != 0.5 {
+		t.Fatalf("one of two holds everything: gini = %v, want 0.5", got)
+	}
+}
+
+func TestSimulateCommandOutputs(t *testing.T) {
+	var out, errOut bytes.Buffer
+	if code := runSimulateCommand([]string{"-ticks", "40", "-every", "10", "-agents", "smuggler=2,seat=1"}, &out, &errOut); code != 0 {
+		t.Fatalf("simulate exited %d: %s", code, errOut.String())
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	if len(rows) != 5 || rows[0][0] != "tick" || rows[0][7] != "gold_gini" {
+		t.Fatalf("unexpected csv: %v", rows)
+	}
+
+	out.Reset()
+	if code := runSimulateCommand([]string{"-ticks", "20", "-format", "json"}, &out, &errOut); code != 0 {
+		t.Fatalf("simulate json exited %d: %s", code, errOut.String())
+	}
+	var report simReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("decode json: %v", err)
+	}
+	if report.Summary.Ticks != 20 || len(report.Samples) != 20 {
+		t.Fatalf("unexpected json report summary: %+v", report.Summary)
+	}
+
+	errOut.Reset()
+	if code := runSimulateCommand([]string{"-agents", "banker=1"}, &out, &errOut); code != 2 || !strings.Contains(errOut.String(), "unknown agent policy") {
+		t.Fatalf("expected usage error, got %d: %s", code, errOut.String())
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	statusJailed      = "jailed"
+	statusExiled      = "exiled"
+	statusHouseArrest = "house_arrest"
+	statusOutlaw      = "outlaw"
+	statusInjured     = "injured"
+
+	// statusHouseArrestTicks and statusOutlawTicks are how long those
+	// states last; jail and exile terms come from the court.
+	statusHouseArrestTicks = 4
+	statusOutlawTicks      = 6
+	// statusGuardBribePerTick is what a guard asks per tick left to serve;
+	// statusGuardBribePct is the chance he takes it rather than reporting it.
+	statusGuardBribePerTick = 3
+	statusGuardBribePct     = 60
+	statusGuardBribeHeat    = 2
+	// An escape needs statusEscapeSteps of planning, then succeeds on
+	// statusEscapePct; a foiled attempt adds statusEscapeFailTicks.
+	statusEscapeSteps     = 2
+	statusEscapePct       = 50
+	statusEscapeFailTicks = 2
+	statusEscapeHeat      = 3
+)
+
+// StatusEffect is a restriction on what a player may do, released by tick.
+type StatusEffect struct {
+	Kind string
+	// LocationID is the place an exile may not enter.
+	LocationID string `json:",omitempty"`
+	TicksLeft  int
+	Reason     string `json:",omitempty"`
+	// Progress counts escape planning while jailed.
+	Progress int `json:",omitempty"`
+}
+
+// StatusView explains a restriction on the dashboard and in the API.
+type StatusView struct {
+	Kind        string `json:"kind"`
+	Label       string `json:"label"`
+	Reason      string `json:"reason,omitempty"`
+	Explanation string `json:"explanation"`
+	TicksLeft   int    `json:"ticks_left"`
+	EndsAtTick  int64  `json:"ends_at_tick"`
+}
+
+// jailVerbs are the only actions open to a jailed player. Missives travel
+// through their own route and are never blocked.
+var jailVerbs = map[string]bool{
+	"bribe_guard": true,
+	"plan_escape": true,
+}
+
+// houseArrestBlocked are the actions that take a player out of their house.
+var houseArrestBlocked = map[string]bool{
+	"travel":            true,
+	"accept":            true,
+	"deliver":           true,
+	"send_caravan":      true,
+	"escort_caravan":    true,
+	"raid_caravan":      true,
+	"scavenge_frontier": true,
+	"explore_ruins":     true,
+	"respond_crisis":    true,
+	"campaign_seat":     true,
+	"campaign_spend":    true,
+}
+
+// outlawBlocked are the civic actions closed to an outlaw. Trading at a
+// city market is refused separately.
+var outlawBlocked = map[string]bool{
+	"campaign_seat":        true,
+	"campaign_spend":       true,
+	"cast_ballot":          true,
+	"challenge_seat":       true,
+	"jury_vote":            true,
+	"petition_institution": true,
+	"post_supply":          true,
+	"launch_project":       true,
+	"donate_relief":        true,
+	"build":                true,
+	"buy_building":         true,
+}
+
+// injuredBlocked are the exertions closed to a wounded player until they
+// mend or the Temple dresses their wounds.
+var injuredBlocked = map[string]bool{
+	"travel":            true,
+	"accept":            true,
+	"escort_caravan":    true,
+	"raid_caravan":      true,
+	"scavenge_frontier": true,
+	"explore_ruins":     true,
+	"challenge_duel":    true,
+	"accept_duel":       true,
+	"ambush":            true,
+	theftPickpocket:     true,
+	theftBurglary:       true,
+	theftRelic:          true,
+}
+
+var marketVerbs = map[string]bool{
+	"buy": true, "buy_grain": true, "sell": true, "sell_grain": true, "bid": true, "ask": true,
+}
+
+func statusLabel(kind string) string {
+	switch kind {
+	case statusJailed:
+		return "Jailed"
+	case statusExiled:
+		return "Exiled"
+	case statusHouseArrest:
+		return "House arrest"
+	case statusOutlaw:
+		return "Outlaw"
+	case statusInjured:
+		return "Injured"
+	}
+	return kind
+}
+
+func statusFor(p *Player, kind string) *StatusEffect {
+	for i := range p.Statuses {
+		if p.Statuses[i].Kind == kind && p.Statuses[i].TicksLeft > 0 {
+			return &p.Statuses[i]
+		}
+	}
+	return nil
+}
+
+func hasStatus(p *Player, kind string) bool {
+	return statusFor(p, kind) != nil
+}
+
+// addStatusLocked puts p under kind for ticks, extending a status already in
+// force rather than stacking a second one.
+func addStatusLocked(p *Player, kind, locationID string, ticks int, reason string) *StatusEffect {
+	if st := statusFor(p, kind); st != nil && st.LocationID == locationID {
+		st.TicksLeft = maxInt(st.TicksLeft, ticks)
+		st.Reason = reason
+		return st
+	}
+	p.Statuses = append(p.Statuses, StatusEffect{Kind: kind, LocationID: locationID, TicksLeft: ticks, Reason: reason})
+	return &p.Statuses[len(p.Statuses)-1]
+}
+
+func clearStatus(p *Player, kind string) {
+	kept := p.Statuses[:0]
+	for _, st := range p.Statuses {
+		if st.Kind != kind {
+			kept = append(kept, st)
+		}
+	}
+	p.Statuses = kept
+	if len(p.Statuses) == 0 {
+		p.Statuses = nil
+	}
+}
+
+// statusLockoutLocked refuses in when one of p's statuses forbids it, the way
+// the travel lockout does, and reports whether it did.
+func statusLockoutLocked(store *Store, p *Player, in ActionInput) bool {
+	for _, st := range p.Statuses {
+		if st.TicksLeft <= 0 {
+			continue
+		}
+		if msg := statusForbids(p, st, in); msg != "" {
+			rejectLocked(store, p.ID, errCodeRestricted, fmt.Sprintf("%s Ends in %d ticks.", msg, st.TicksLeft))
+			return true
+		}
+	}
+	return false
+}
+
+func statusForbids(p *Player, st StatusEffect, in ActionInput) string {
+	switch st.Kind {
+	case statusJailed:
+		if !jailVerbs[in.Action] {
+			return "You are in the city jail: you may bribe a guard, plan an escape or send missives."
+		}
+	case statusExiled:
+		if in.Action == "travel" && in.LocationID == st.LocationID {
+			return fmt.Sprintf("You are exiled from %s.", locationName(st.LocationID))
+		}
+	case statusHouseArrest:
+		if houseArrestBlocked[in.Action] {
+			return "You are under house arrest and cannot leave your house."
+		}
+	case statusOutlaw:
+		if outlawBlocked[in.Action] {
+			return "Outlaws have no standing in the city's affairs."
+		}
+		if marketVerbs[in.Action] && marketTreasuryID(marketLocationID(p)) == "city_authority" {
+			return "City markets will not trade with an outlaw."
+		}
+	case statusInjured:
+		if injuredBlocked[in.Action] {
+			return "You are too badly hurt; the Temple in the Capital can dress your wounds."
+		}
+	}
+	return ""
+}
+
+// jailLocked locks p in the city jail for ticks, bringing them back to the
+// capital if they were elsewhere.
+func jailLocked(p *Player, ticks int, reason string) {
+	p.LocationID = locationCapital
+	p.TravelToID, p.TravelTicksLeft, p.TravelTotalTicks = "", 0, 0
+	addStatusLocked(p, statusJailed, "", ticks, reason)
+}
+
+// declareOutlawLocked strips p of the city's protection for a while.
+func declareOutlawLocked(store *Store, now time.Time, p *Player, reason string) {
+	addStatusLocked(p, statusOutlaw, "", statusOutlawTicks, reason)
+	addEventLocked(store, Event{Type: "Law", Severity: 3, Text: fmt.Sprintf("[%s] is declared an outlaw.", p.Name), At: now})
+	setToastLocked(store, p.ID, fmt.Sprintf("You are an outlaw for %d ticks: %s.", statusOutlawTicks, reason))
+}
+
+// processStatusTickLocked counts p's statuses down and releases the ones
+// whose time has come.
+func processStatusTickLocked(store *Store, p *Player, now time.Time) {
+	if len(p.Statuses) == 0 {
+		return
+	}
+	kept := p.Statuses[:0]
+	for _, st := range p.Statuses {
+		st.TicksLeft--
+		if st.TicksLeft > 0 {
+			kept = append(kept, st)
+			continue
+		}
+		switch st.Kind {
+		case statusJailed:
+			addEventLocked(store, Event{Type: "Law", Severity: 1, Text: fmt.Sprintf("[%s] walks free from the city jail.", p.Name), At: now})
+			setToastLocked(store, p.ID, "You are released from jail.")
+		case statusExiled:
+			setToastLocked(store, p.ID, fmt.Sprintf("Your exile from %s has ended.", locationName(st.LocationID)))
+		case statusHouseArrest:
+			setToastLocked(store, p.ID, "Your house arrest is lifted.")
+		case statusOutlaw:
+			addEventLocked(store, Event{Type: "Law", Severity: 1, Text: fmt.Sprintf("[%s] is no longer an outlaw.", p.Name), At: now})
+			setToastLocked(store, p.ID, "You are no longer an outlaw.")
+		case statusInjured:
+			setToastLocked(store, p.ID, "Your wounds have healed.")
+		}
+	}
+	p.Statuses = kept
+	if len(p.Statuses) == 0 {
+		p.Statuses = nil
+	}
+}
+
+func guardBribeCost(st *StatusEffect) int {
+	return statusGuardBribePerTick * st.TicksLeft
+}
+
+// bribeGuardLocked pays a guard to look away. A guard who refuses reports
+// the bribe: the gold goes to the treasury and the term grows by a tick.
+func bribeGuardLocked(store *Store, p *Player, now time.Time) {
+	st := statusFor(p, statusJailed)
+	if st == nil {
+		rejectLocked(store, p.ID, errCodeNotAllowed, "You are not in jail.")
+		return
+	}
+	cost := guardBribeCost(st)
+	if p.Gold < cost {
+		rejectLocked(store, p.ID, errCodeInsufficientGold, fmt.Sprintf("The guard wants %dg.", cost))
+		return
+	}
+	if !rollPercent(rngStreamLocked(store, rngStreamIntel), statusGuardBribePct) {
+		collectFineLocked(store, now, p, cost, "Reported guard bribe")
+		st.TicksLeft++
+		addEventLocked(store, Event{Type: "Law", Severity: 2, Text: fmt.Sprintf("A guard reports [%s] for offering a bribe.", p.Name), At: now})
+		setToastLocked(store, p.ID, fmt.Sprintf("The guard reports you: %dg seized and a tick added to your term.", cost))
+		return
+	}
+	p.Gold -= cost
+	p.Heat = clampInt(p.Heat+statusGuardBribeHeat, 0, 20)
+	clearStatus(p, statusJailed)
+	addEventLocked(store, Event{Type: "Law", Severity: 2, Text: fmt.Sprintf("[%s] is let out of the city jail early.", p.Name), At: now})
+	setToastLocked(store, p.ID, fmt.Sprintf("The guard takes %dg and leaves the door open.", cost))
+}
+
+// planEscapeLocked advances an escape plan and, once it is ready, attempts
+// it. An escapee is free but declared an outlaw; a foiled attempt adds to
+// the term and starts the plan over.
+func planEscapeLocked(store *Store, p *Player, now time.Time) {
+	st := statusFor(p, statusJailed)
+	if st == nil {
+		rejectLocked(store, p.ID, errCodeNotAllowed, "You are not in jail.")
+		return
+	}
+	st.Progress++
+	if st.Progress < statusEscapeSteps {
+		setToastLocked(store, p.ID, fmt.Sprintf("You study the guards' rounds (%d/%d).", st.Progress, statusEscapeSteps))
+		return
+	}
+	if !rollPercent(rngStreamLocked(store, rngStreamIntel), statusEscapePct) {
+		st.Progress = 0
+		st.TicksLeft += statusEscapeFailTicks
+		addEventLocked(store, Event{Type: "Law", Severity: 2, Text: fmt.Sprintf("An escape by [%s] is foiled at the jail wall.", p.Name), At: now})
+		setToastLocked(store, p.ID, fmt.Sprintf("Caught at the wall: %d ticks added to your term.", statusEscapeFailTicks))
+		return
+	}
+	clearStatus(p, statusJailed)
+	p.Heat = clampInt(p.Heat+statusEscapeHeat, 0, 20)
+	addEventLocked(store, Event{Type: "Law", Severity: 3, Text: fmt.Sprintf("[%s] escapes the city jail.", p.Name), At: now})
+	declareOutlawLocked(store, now, p, "escaped from jail")
+}
+
+// statusSummary is the short form of p's statuses for the players list.
+func statusSummary(p *Player) string {
+	parts := make([]string, 0, len(p.Statuses))
+	for _, st := range p.Statuses {
+		if st.TicksLeft <= 0 {
+			continue
+		}
+		label := statusLabel(st.Kind)
+		if st.Kind == statusExiled {
+			label = fmt.Sprintf("Exiled from %s", locationName(st.LocationID))
+		}
+		parts = append(parts, fmt.Sprintf("%s (%dt)", label, st.TicksLeft))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func statusViewsLocked(store *Store, p *Player) []StatusView {
+	views := make([]StatusView, 0, len(p.Statuses))
+	for _, st := range p.Statuses {
+		if st.TicksLeft <= 0 {
+			continue
+		}
+		v := StatusView{
+			Kind:       st.Kind,
+			Label:      statusLabel(st.Kind),
+			Reason:     st.Reason,
+			TicksLeft:  st.TicksLeft,
+			EndsAtTick: store.TickCount + int64(st.TicksLeft),
+		}
+		switch st.Kind {
+		case statusJailed:
+			v.Explanation = fmt.Sprintf("Only missives, bribing a guard (%dg) or planning an escape (%d/%d).", guardBribeCost(&st), st.Progress, statusEscapeSteps)
+		case statusExiled:
+			v.Label = fmt.Sprintf("Exiled from %s", locationName(st.LocationID))
+			v.Explanation = fmt.Sprintf("You may not travel to %s.", locationName(st.LocationID))
+		case statusHouseArrest:
+			v.Explanation = "No travel, contracts, caravans, fieldwork or campaigning."
+		case statusOutlaw:
+			v.Explanation = "No voting, jury duty, petitions, public works or trade at city markets."
+		case statusInjured:
+			v.Explanation = fmt.Sprintf("No travel, contracts, fieldwork, thefts or fights. The Temple heals for %dg.", combatHealCost)
+		}
+		views = append(views, v)
+	}
+	return views
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJailedPlayersKeepOnlyTheJailVerbs(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	p := &Player{ID: "p1", Name: "Ash Crow (Guest)", Gold: 100, LocationID: locationCapital, LastSeen: now}
+	friend := &Player{ID: "p2", Name: "Bram Vale (Guest)", LastSeen: now}
+	s.Players[p.ID], s.Players[friend.ID] = p, friend
+
+	handleActionInputLocked(s, p, now, ActionInput{Action: "plan_escape"})
+	if s.rejections[p.ID] != errCodeNotAllowed {
+		t.Fatalf("only prisoners plan escapes, got %q", s.rejections[p.ID])
+	}
+	jailLocked(p, 3, "court sentence")
+	for _, action := range []string{"investigate", "buy_grain", "petition_institution"} {
+		handleActionInputLocked(s, p, now, ActionInput{Action: action, Amount: 1})
+		if s.rejections[p.ID] != errCodeRestricted {
+			t.Fatalf("%s should be closed to a prisoner, got %q", action, s.rejections[p.ID])
+		}
+	}
+	if !submitMissiveLocked(s, p, now, MissiveInput{TargetID: friend.ID, Subject: "Bail", Body: "Send coin."}) {
+		t.Fatalf("missives should still leave the jail")
+	}
+
+	mux := newMux(s, parseTemplates())
+	body := doReq(t, mux, http.MethodGet, "/frag/dashboard", nil, "p1", "127.0.0.1:1111").Body.String()
+	if !strings.Contains(body, "Ends at tick 3") || !strings.Contains(body, "bribe_guard") {
+		t.Fatalf("the dashboard should explain the jail term and offer the jail verbs")
+	}
+
+	// Seed 13 opens the intel stream with 99 at tick 16, 96 at tick 6, 7 at
+	// tick 4 and 6 at tick 13.
+	s.TickCount = 16
+	jailLocked(p, 3, "court sentence")
+	cost := guardBribeCost(statusFor(p, statusJailed))
+	handleActionInputLocked(s, p, now, ActionInput{Action: "bribe_guard"})
+	if st := statusFor(p, statusJailed); st == nil || st.TicksLeft != 4 || p.Gold != 100-cost {
+		t.Fatalf("a reported bribe should be seized and lengthen the term: %+v gold %d", st, p.Gold)
+	}
+
+	clearStatus(p, statusJailed)
+	p.Gold = 100
+	s.TickCount = 4
+	jailLocked(p, 3, "court sentence")
+	handleActionInputLocked(s, p, now, ActionInput{Action: "bribe_guard"})
+	if hasStatus(p, statusJailed) || p.Gold != 100-cost || p.Heat != statusGuardBribeHeat {
+		t.Fatalf("a taken bribe should open the door: %+v gold %d heat %d", p.Statuses, p.Gold, p.Heat)
+	}
+
+	s.TickCount = 6
+	jailLocked(p, 3, "court sentence")
+	for step := 0; step < statusEscapeSteps; step++ {
+		handleActionInputLocked(s, p, now, ActionInput{Action: "plan_escape"})
+	}
+	if st := statusFor(p, statusJailed); st == nil || st.TicksLeft != 3+statusEscapeFailTicks || st.Progress != 0 {
+		t.Fatalf("a foiled escape should lengthen the term and start over: %+v", st)
+	}
+
+	clearStatus(p, statusJailed)
+	s.TickCount = 13
+	jailLocked(p, 3, "court sentence")
+	for step := 0; step < statusEscapeSteps; step++ {
+		handleActionInputLocked(s, p, now, ActionInput{Action: "plan_escape"})
+	}
+	if hasStatus(p, statusJailed) || !hasStatus(p, statusOutlaw) {
+		t.Fatalf("an escapee should be an outlaw: %+v", p.Statuses)
+	}
+}
+
+func TestHouseArrestAndOutlawryAreReleasedByTick(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	p := &Player{ID: "p1", Name: "Ash Crow (Guest)", Gold: 40, Heat: 12, LocationID: locationCapital, LastSeen: now}
+	s.Players[p.ID] = p
+
+	applySentenceLocked(s, p, sentenceHouseArrest, now)
+	handleActionInputLocked(s, p, now, ActionInput{Action: "travel", LocationID: nearestLocation(locationCapital)})
+	if s.rejections[p.ID] != errCodeRestricted {
+		t.Fatalf("house arrest should keep the player home, got %q", s.rejections[p.ID])
+	}
+	delete(s.rejections, p.ID)
+	handleActionInputLocked(s, p, now, ActionInput{Action: "buy_grain", Amount: 1})
+	if s.rejections[p.ID] == errCodeRestricted {
+		t.Fatalf("house arrest should not close the market")
+	}
+	for i := 0; i < statusHouseArrestTicks; i++ {
+		processPlayerTickLocked(s, now)
+	}
+	if len(p.Statuses) != 0 {
+		t.Fatalf("house arrest should be lifted on schedule: %+v", p.Statuses)
+	}
+
+	issueWarrantLocked(s, now, "", "Marshal Dain (NPC)", p)
+	c := issueBountyContractLocked(s, p, 1)
+	runWorldTickLocked(s, now)
+	if c.Status != "Failed" || !hasStatus(p, statusOutlaw) {
+		t.Fatalf("a lapsed warranted bounty should make an outlaw: %s %+v", c.Status, p.Statuses)
+	}
+	handleActionInputLocked(s, p, now, ActionInput{Action: "petition_institution"})
+	if s.rejections[p.ID] != errCodeRestricted {
+		t.Fatalf("outlaws cannot petition, got %q", s.rejections[p.ID])
+	}
+	handleActionInputLocked(s, p, now, ActionInput{Action: "sell_grain", Amount: 1})
+	if s.rejections[p.ID] != errCodeRestricted {
+		t.Fatalf("city markets should refuse outlaws, got %q", s.rejections[p.ID])
+	}
+	for i := 0; i < statusOutlawTicks; i++ {
+		processPlayerTickLocked(s, now)
+	}
+	if hasStatus(p, statusOutlaw) || !strings.Contains(lastEventText(s), "is no longer an outlaw") {
+		t.Fatalf("outlawry should lapse on schedule: %+v %q", p.Statuses, lastEventText(s))
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	theftPickpocket = "pickpocket"
+	theftBurglary   = "burgle"
+	theftRelic      = "steal_relic"
+
+	// The base chance of each theft before the victim's surroundings and
+	// the thief's heat are weighed.
+	theftPickpocketPct = 60
+	theftBurglaryPct   = 50
+	theftRelicPct      = 40
+	// theftWatchPenalty is taken off for every Watch officer at the scene,
+	// theftCityWatchPenalty where the city keeps the peace, and
+	// theftWardPenalty while the ward network burns (twice over for relics).
+	theftWatchPenalty     = 15
+	theftCityWatchPenalty = 10
+	theftWardPenalty      = 10
+	// theftDangerBonus is added per point of the location's danger;
+	// theftEmptyHouseBonus when a burglary's victim is away on the road.
+	theftDangerBonus     = 3
+	theftEmptyHouseBonus = 15
+	theftHeatPenalty     = 2
+	// theftPursePct of the victim's purse is lifted, at most
+	// theftPurseMax; a burglary carries off theftBurglaryMax units.
+	theftPursePct    = 25
+	theftPurseMax    = 20
+	theftBurglaryMax = 4
+	// A thief caught in the act hands the victim and the Watch evidence of
+	// theftCaughtStrength; a clean theft leaves a clue for theftClueTicks.
+	theftCaughtStrength = 6
+	theftEvidenceTicks  = 8
+	theftClueTicks      = 12
+	theftCaughtRep      = 3
+	theftCaughtHeat     = 3
+)
+
+// TheftClue is what a successful theft leaves behind. The victim and the
+// Watch can follow it with an investigation, which names the culprit.
+type TheftClue struct {
+	ID          string
+	Kind        string
+	CulpritID   string
+	CulpritName string
+	VictimID    string
+	VictimName  string
+	LocationID  string
+	Loot        string
+	Strength    int
+	Tick        int64
+	ExpiryTick  int64
+}
+
+type TheftClueView struct {
+	ID           string `json:"id"`
+	Kind         string `json:"kind"`
+	VictimName   string `json:"victim_name"`
+	LocationName string `json:"location_name"`
+	Loot         string `json:"loot"`
+	ExpiryIn     int64  `json:"expiry_in"`
+}
+
+// theftAct completes "[thief] is caught ... [victim]".
+func theftAct(kind string) string {
+	switch kind {
+	case theftBurglary:
+		return "burgling"
+	case theftRelic:
+		return "stealing a relic from"
+	}
+	return "picking the pocket of"
+}
+
+func theftLabel(kind string) string {
+	switch kind {
+	case theftPickpocket:
+		return "Pickpocketing"
+	case theftBurglary:
+		return "Burglary"
+	case theftRelic:
+		return "Relic theft"
+	}
+	return kind
+}
+
+// isWatchOfficerLocked is true for the Commander of the Watch and the
+// Watch's NPC officers.
+func isWatchOfficerLocked(store *Store, p *Player) bool {
+	return p.NPCRole == npcRoleWatch || playerHoldsSeatLocked(store, p.ID, "watch_commander")
+}
+
+// watchAtLocationLocked counts the Watch officers standing at locationID,
+// leaving out the thief.
+func watchAtLocationLocked(store *Store, locationID, thiefID string) int {
+	n := 0
+	for _, p := range store.Players {
+		if p.ID != thiefID && p.TravelTicksLeft == 0 && marketLocationID(p) == locationID && isWatchOfficerLocked(store, p) {
+			n++
+		}
+	}
+	return n
+}
+
+// theftChanceLocked weighs a theft against victim where they are: the
+// Watch on hand, the city's peacekeeping, the ward network, the location's
+// danger and the thief's heat.
+func theftChanceLocked(store *Store, thief, victim *Player, kind string) int {
+	locationID := marketLocationID(victim)
+	chance := theftPickpocketPct
+	switch kind {
+	case theftBurglary:
+		chance = theftBurglaryPct
+		if victim.TravelTicksLeft > 0 {
+			chance += theftEmptyHouseBonus
+		}
+	case theftRelic:
+		chance = theftRelicPct
+	}
+	if def, ok := locationByID(locationID); ok {
+		chance += def.Danger * theftDangerBonus
+	}
+	if marketTreasuryID(locationID) == "city_authority" {
+		chance -= theftCityWatchPenalty
+	}
+	if store.World.WardNetworkTicks > 0 {
+		chance -= theftWardPenalty
+		if kind == theftRelic {
+			chance -= theftWardPenalty
+		}
+	}
+	chance -= watchAtLocationLocked(store, locationID, thief.ID) * theftWatchPenalty
+	chance -= thief.Heat * theftHeatPenalty
+	return clampInt(chance, 5, 85)
+}
+
+// attemptTheftLocked carries out a pickpocketing, burglary or relic theft
+// by p on in.TargetID.
+func attemptTheftLocked(store *Store, p *Player, now time.Time, kind string, in ActionInput) {
+	victim := store.Players[in.TargetID]
+	if victim == nil || victim.ID == p.ID {
+		rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a valid mark.")
+		return
+	}
+	if marketLocationID(victim) != marketLocationID(p) {
+		rejectLocked(store, p.ID, errCodeNotAllowed, fmt.Sprintf("%s is not in %s.", victim.Name, locationName(marketLocationID(p))))
+		return
+	}
+	if kind == theftPickpocket && victim.TravelTicksLeft > 0 {
+		rejectLocked(store, p.ID, errCodeNotAllowed, fmt.Sprintf("%s is on the road.", victim.Name))
+		return
+	}
+	var relic *Relic
+	switch kind {
+	case theftPickpocket:
+		if victim.Gold <= 0 {
+			rejectLocked(store, p.ID, errCodeNotAllowed, "Their purse is empty.")
+			return
+		}
+	case theftBurglary:
+		if id, _ := burglaryLootFor(victim); id == "" {
+			rejectLocked(store, p.ID, errCodeNotAllowed, "Their stores are bare.")
+			return
+		}
+	case theftRelic:
+		relic = relicToStealLocked(store, victim, in.RelicID)
+		if relic == nil {
+			rejectLocked(store, p.ID, errCodeNotFound, "They hold no such relic.")
+			return
+		}
+	}
+	if !consumeHighImpactBudgetLocked(store, p.ID, now) {
+		rejectLocked(store, p.ID, errCodeHighImpactCap, "Daily cap reached for high-impact actions.")
+		return
+	}
+
+	if !rollPercent(rngStreamLocked(store, rngStreamIntel), theftChanceLocked(store, p, victim, kind)) {
+		p.Rep = clampInt(p.Rep-theftCaughtRep, -100, 100)
+		p.Heat = clampInt(p.Heat+theftCaughtHeat, 0, 20)
+		addEvidenceLocked(store, victim, p, "theft", theftCaughtStrength, theftEvidenceTicks, false)
+		if officer := watchOfficerLocked(store); officer != nil && officer.ID != victim.ID && officer.ID != p.ID {
+			addEvidenceLocked(store, officer, p, "theft", theftCaughtStrength, theftEvidenceTicks, false)
+		}
+		addEventLocked(store, Event{Type: "Law", Severity: 3, Text: fmt.Sprintf("[%s] is caught %s [%s] in %s.", p.Name, theftAct(kind), victim.Name, locationName(marketLocationID(victim))), At: now})
+		setToastLocked(store, victim.ID, fmt.Sprintf("You catch %s in the act; the evidence is yours.", p.Name))
+		setToastLocked(store, p.ID, "Caught in the act: your mark and the Watch have evidence.")
+		return
+	}
+
+	loot := ""
+	store.dirty.mark(dirtyPlayers, victim.ID)
+	switch kind {
+	case theftPickpocket:
+		take := minInt(theftPurseMax, maxInt(1, victim.Gold*theftPursePct/100))
+		victim.Gold -= take
+		p.Gold += take
+		loot = fmt.Sprintf("%dg", take)
+	case theftBurglary:
+		id, held := burglaryLootFor(victim)
+		take := minInt(theftBurglaryMax, held)
+		addHolding(victim, id, -take)
+		addHolding(p, id, take)
+		loot = fmt.Sprintf("%d %s", take, commodityLootName(id))
+	case theftRelic:
+		store.dirty.mark(dirtyRelics, relic.ID)
+		relic.OwnerPlayerID, relic.OwnerName = p.ID, p.Name
+		loot = relic.Name
+	}
+	p.Heat = clampInt(p.Heat+1, 0, 20)
+	clue := addTheftClueLocked(store, p, victim, kind, loot)
+	addEventLocked(store, Event{Type: "Law", Severity: 2, Text: fmt.Sprintf("%s in %s: [%s] loses %s.", theftLabel(kind), locationName(clue.LocationID), victim.Name, loot), At: now})
+	setToastLocked(store, victim.ID, fmt.Sprintf("%s: you lose %s. Investigate to follow the clues.", theftLabel(kind), loot))
+	setToastLocked(store, p.ID, fmt.Sprintf("You make off with %s.", loot))
+}
+
+// burglaryLootFor is the commodity a burglar carries off: the victim's
+// largest holding.
+func burglaryLootFor(victim *Player) (string, int) {
+	best, held := "", 0
+	if victim.Grain > 0 {
+		best, held = commodityGrain, victim.Grain
+	}
+	for _, id := range sortedKeys(victim.Inventory) {
+		if n := victim.Inventory[id]; n > held {
+			best, held = id, n
+		}
+	}
+	return best, held
+}
+
+// relicToStealLocked is the relic named by relicID, or the victim's most
+// powerful one when none is named.
+func relicToStealLocked(store *Store, victim *Player, relicID string) *Relic {
+	if relicID != "" {
+		id, err := strconv.ParseInt(relicID, 10, 64)
+		if relic := store.Relics[id]; err == nil && relic != nil && relic.OwnerPlayerID == victim.ID {
+			return relic
+		}
+		return nil
+	}
+	var best *Relic
+	for _, id := range sortedKeys(store.Relics) {
+		if relic := store.Relics[id]; relic.OwnerPlayerID == victim.ID && (best == nil || relic.Power > best.Power) {
+			best = relic
+		}
+	}
+	return best
+}
+
+// watchOfficerLocked is who the Watch's evidence goes to: the Commander of
+// the Watch if a player holds the seat, otherwise the Watch's NPC officer.
+func watchOfficerLocked(store *Store) *Player {
+	if seat := store.Seats["watch_commander"]; seat != nil && seat.HolderPlayerID != "" {
+		if p := store.Players[seat.HolderPlayerID]; p != nil {
+			return p
+		}
+	}
+	for _, id := range sortedKeys(store.Players) {
+		if p := store.Players[id]; p.NPCRole == npcRoleWatch {
+			return p
+		}
+	}
+	return nil
+}
+
+func commodityLootName(id string) string {
+	if def, ok := commodityDefinitionByID(id); ok {
+		return def.Name
+	}
+	return id
+}
+
+func addTheftClueLocked(store *Store, thief, victim *Player, kind, loot string) *TheftClue {
+	store.NextClueID++
+	strength := 4
+	switch kind {
+	case theftBurglary:
+		strength = 5
+	case theftRelic:
+		strength = 6
+	}
+	clue := &TheftClue{
+		ID:          fmt.Sprintf("clue-%d", store.NextClueID),
+		Kind:        kind,
+		CulpritID:   thief.ID,
+		CulpritName: thief.Name,
+		VictimID:    victim.ID,
+		VictimName:  victim.Name,
+		LocationID:  marketLocationID(victim),
+		Loot:        loot,
+		Strength:    strength,
+		Tick:        store.TickCount,
+		ExpiryTick:  store.TickCount + theftClueTicks,
+	}
+	store.dirty.mark(dirtyClues, clue.ID)
+	store.Clues[clue.ID] = clue
+	return clue
+}
+
+// canFollowClueLocked is true for the victim and for Watch officers.
+func canFollowClueLocked(store *Store, p *Player, clue *TheftClue) bool {
+	return clue.CulpritID != p.ID && (clue.VictimID == p.ID || isWatchOfficerLocked(store, p))
+}
+
+// followableClueLocked is the strongest, then oldest, clue p may follow.
+// A non-empty culpritID keeps to the clues that lead to that player.
+func followableClueLocked(store *Store, p *Player, culpritID string) *TheftClue {
+	var best *TheftClue
+	for _, id := range sortedKeys(store.Clues) {
+		clue := store.Clues[id]
+		if !canFollowClueLocked(store, p, clue) || store.Players[clue.CulpritID] == nil {
+			continue
+		}
+		if culpritID != "" && clue.CulpritID != culpritID {
+			continue
+		}
+		if best == nil || clue.Strength > best.Strength || (clue.Strength == best.Strength && clue.Tick < best.Tick) {
+			best = clue
+		}
+	}
+	return best
+}
+
+// followClueLocked turns the clue p may follow into evidence against the
+// culprit and reports whether there was one. An investigation aimed at
+// someone only follows clues that lead to them.
+func followClueLocked(store *Store, p *Player, targetID string, now time.Time) bool {
+	clue := followableClueLocked(store, p, targetID)
+	if clue == nil {
+		return false
+	}
+	culprit := store.Players[clue.CulpritID]
+	store.dirty.mark(dirtyClues, clue.ID)
+	delete(store.Clues, clue.ID)
+	addEvidenceLocked(store, p, culprit, "theft", clue.Strength, theftEvidenceTicks, false)
+	addEventLocked(store, Event{Type: "Law", Severity: 2, Text: fmt.Sprintf("[%s] follows the trail of a theft in %s.", p.Name, locationName(clue.LocationID)), At: now})
+	setToastLocked(store, p.ID, fmt.Sprintf("The trail of %s leads to %s; evidence added to your dossier.", clue.Loot, culprit.Name))
+	return true
+}
+
+func expireTheftCluesLocked(store *Store) {
+	for id, clue := range store.Clues {
+		if clue.ExpiryTick <= store.TickCount {
+			delete(store.Clues, id)
+		}
+	}
+}
+
+func theftClueViewsLocked(store *Store, p *Player) []TheftClueView {
+	views := []TheftClueView{}
+	for _, id := range sortedKeys(store.Clues) {
+		clue := store.Clues[id]
+		if !canFollowClueLocked(store, p, clue) {
+			continue
+		}
+		views = append(views, TheftClueView{
+			ID:           clue.ID,
+			Kind:         theftLabel(clue.Kind),
+			VictimName:   clue.VictimName,
+			LocationName: locationName(clue.LocationID),
+			Loot:         clue.Loot,
+			ExpiryIn:     maxInt64(0, clue.ExpiryTick-store.TickCount),
+		})
+	}
+	return views
+}
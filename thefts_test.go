@@ -0,0 +1,102 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTheftsLeaveCluesOrEvidence(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	thief := &Player{ID: "p1", Name: "Ash Crow (Guest)", LocationID: locationHarbor, LastSeen: now}
+	mark := &Player{ID: "p2", Name: "Bram Vale (Guest)", Gold: 1000, LocationID: locationHarbor, LastSeen: now}
+	watch := &Player{ID: "npc-watch", Name: "Sergeant Pell (NPC)", NPCRole: npcRoleWatch, LocationID: locationCapital, LastSeen: now}
+	for _, p := range []*Player{thief, mark, watch} {
+		s.Players[p.ID] = p
+	}
+
+	handleActionInputLocked(s, thief, now, ActionInput{Action: theftPickpocket, TargetID: watch.ID})
+	if s.rejections[thief.ID] != errCodeNotAllowed {
+		t.Fatalf("a mark elsewhere cannot be robbed, got %q", s.rejections[thief.ID])
+	}
+
+	// Seed 13 opens the intel stream with 7 at tick 4 and 99 at tick 16.
+	s.TickCount = 4
+	handleActionInputLocked(s, thief, now, ActionInput{Action: theftPickpocket, TargetID: mark.ID})
+	if thief.Gold != 1000-mark.Gold || thief.Gold == 0 || len(s.Clues) != 1 || len(s.Evidence) != 0 {
+		t.Fatalf("a clean theft should move the gold and leave only a clue: gold %d clues %+v", thief.Gold, s.Clues)
+	}
+	if views := theftClueViewsLocked(s, thief); len(views) != 0 {
+		t.Fatalf("the thief should not see clues to their own theft: %+v", views)
+	}
+	handleActionInputLocked(s, mark, now, ActionInput{Action: "investigate_target", TargetID: thief.ID})
+	if ev := strongestEvidenceForLocked(s, mark.ID, thief.ID); ev == nil || ev.Topic != "theft" || len(s.Clues) != 0 {
+		t.Fatalf("investigating should turn the clue into evidence on the culprit: %+v", s.Evidence)
+	}
+
+	s.TickCount = 16
+	thief.Heat, s.Evidence = 0, map[int64]*Evidence{}
+	gold := mark.Gold
+	handleActionInputLocked(s, thief, now, ActionInput{Action: theftPickpocket, TargetID: mark.ID})
+	if mark.Gold != gold || len(s.Clues) != 0 {
+		t.Fatalf("a caught thief should take nothing and leave no clue: gold %d -> %d", gold, mark.Gold)
+	}
+	if ev := strongestEvidenceForLocked(s, mark.ID, thief.ID); ev == nil || ev.Topic != "theft" || strongestEvidenceForLocked(s, watch.ID, thief.ID) == nil {
+		t.Fatalf("a thief caught in the act should hand the mark and the Watch evidence: %+v", s.Evidence)
+	}
+}
+
+func TestTargetedInvestigationOnlyFollowsItsTargetsClues(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	thief := &Player{ID: "p1", Name: "Ash Crow (Guest)", LocationID: locationHarbor, LastSeen: now}
+	mark := &Player{ID: "p2", Name: "Bram Vale (Guest)", LocationID: locationHarbor, LastSeen: now}
+	rival := &Player{ID: "p3", Name: "Cora Wend (Guest)", LocationID: locationHarbor, LastSeen: now}
+	for _, p := range []*Player{thief, mark, rival} {
+		s.Players[p.ID] = p
+	}
+	s.Clues["clue-1"] = &TheftClue{ID: "clue-1", Kind: theftPickpocket, CulpritID: thief.ID, VictimID: mark.ID, VictimName: mark.Name, LocationID: locationHarbor, Loot: "9g", Strength: 5, ExpiryTick: s.TickCount + theftClueTicks}
+
+	handleActionInputLocked(s, mark, now, ActionInput{Action: "investigate_target", TargetID: rival.ID})
+	if ev := strongestEvidenceForLocked(s, mark.ID, rival.ID); ev == nil || ev.Topic == "theft" {
+		t.Fatalf("investigating the rival should build a case on the rival: %+v", ev)
+	}
+	if strongestEvidenceForLocked(s, mark.ID, thief.ID) != nil || len(s.Clues) != 1 {
+		t.Fatalf("a clue leading elsewhere should be left for later: %+v", s.Clues)
+	}
+
+	delete(s.LastInvestigateAt, mark.ID)
+	handleActionInputLocked(s, mark, now, ActionInput{Action: "investigate_target", TargetID: thief.ID})
+	if ev := strongestEvidenceForLocked(s, mark.ID, thief.ID); ev == nil || ev.Topic != "theft" || len(s.Clues) != 0 {
+		t.Fatalf("investigating the culprit should follow the clue: %+v", s.Evidence)
+	}
+}
+
+func TestTheftOddsFollowTheScene(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	thief := &Player{ID: "p1", Name: "Ash Crow (Guest)", LocationID: locationCapital, LastSeen: now}
+	mark := &Player{ID: "p2", Name: "Bram Vale (Guest)", Grain: 6, LocationID: locationCapital, LastSeen: now}
+	s.Players[thief.ID], s.Players[mark.ID] = thief, mark
+
+	city := theftChanceLocked(s, thief, mark, theftPickpocket)
+	mark.LocationID = locationFrontier
+	if frontier := theftChanceLocked(s, thief, mark, theftPickpocket); frontier <= city {
+		t.Fatalf("the lawless frontier should be easier than the capital: %d vs %d", frontier, city)
+	}
+	mark.LocationID = locationCapital
+	s.Players["npc-watch"] = &Player{ID: "npc-watch", NPCRole: npcRoleWatch, LocationID: locationCapital}
+	if watched := theftChanceLocked(s, thief, mark, theftPickpocket); watched != city-theftWatchPenalty {
+		t.Fatalf("a Watch officer on hand should cost %d, got %d vs %d", theftWatchPenalty, watched, city)
+	}
+	delete(s.Players, "npc-watch")
+	s.World.WardNetworkTicks = 3
+	thief.Heat = 1
+	if got := theftChanceLocked(s, thief, mark, theftRelic); got != theftRelicPct-theftCityWatchPenalty+theftDangerBonus-2*theftWardPenalty-theftHeatPenalty {
+		t.Fatalf("relic theft should suffer the wards twice and the thief's heat, got %d", got)
+	}
+	mark.TravelTicksLeft = 2
+	if got := theftChanceLocked(s, thief, mark, theftBurglary); got != theftBurglaryPct+theftEmptyHouseBonus-theftCityWatchPenalty+theftDangerBonus-theftWardPenalty-theftHeatPenalty {
+		t.Fatalf("an empty house should be easier to burgle, got %d", got)
+	}
+}
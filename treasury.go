@@ -0,0 +1,389 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// treasuryLedgerMax is how many ledger entries the city keeps public.
+	treasuryLedgerMax = 200
+	// treasuryLedgerShown is how many the Institutions card lists.
+	treasuryLedgerShown = 12
+	treasuryReliefCost  = 10
+	treasuryPatrolCost  = 8
+	// A patrol clears treasuryPatrolBanditDrop bandit activity off the roads.
+	treasuryPatrolBanditDrop = 6
+	treasuryBountyMin        = 10
+	treasuryBountyMax        = 60
+	permitFeeGold            = 4
+	sanctionFineGold         = 8
+	// Exposed embezzlement leaves the investigator a dossier this strong,
+	// enough to trigger a sanction when published.
+	embezzlementEvidenceStrength = 8
+	// embezzlementMemo is how a theft from the treasury reads on the public
+	// ledger until an investigation exposes it.
+	embezzlementMemo = "Discretionary expense"
+)
+
+// TreasuryEntry is one line of the public ledger: gold into (positive
+// Amount) or out of an institution's treasury.
+type TreasuryEntry struct {
+	ID            int64
+	Tick          int64
+	DayNumber     int
+	InstitutionID string
+	Amount        int
+	Balance       int
+	Memo          string
+	PlayerID      string `json:",omitempty"`
+	PlayerName    string `json:",omitempty"`
+	// Embezzled marks gold a seat holder took for themself; the ledger shows
+	// it as a discretionary expense until Exposed.
+	Embezzled bool `json:",omitempty"`
+	Exposed   bool `json:",omitempty"`
+	At        time.Time
+}
+
+type TreasuryView struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Balance int    `json:"balance"`
+}
+
+type TreasuryEntryView struct {
+	Institution string `json:"institution"`
+	Day         int    `json:"day"`
+	Amount      int    `json:"amount"`
+	Balance     int    `json:"balance"`
+	Memo        string `json:"memo"`
+	PlayerName  string `json:"player_name,omitempty"`
+	Exposed     bool   `json:"exposed"`
+}
+
+// institutionForSeatLocked returns the institution whose treasury the seat
+// spends.
+func institutionForSeatLocked(store *Store, seatID string) (*Seat, *Institution) {
+	seat := store.Seats[seatID]
+	if seat == nil {
+		return nil, nil
+	}
+	return seat, store.Institutions[seat.InstitutionID]
+}
+
+// recordTreasuryLocked moves amount into (or, when negative, out of) an
+// institution's treasury and writes the ledger line. Market taxes from the
+// same payer in the same tick share a line.
+func recordTreasuryLocked(store *Store, now time.Time, inst *Institution, amount int, memo string, p *Player) *TreasuryEntry {
+	if inst == nil || amount == 0 {
+		return nil
+	}
+	inst.Treasury += amount
+	store.dirty.mark(dirtyInstitutions, inst.ID)
+	store.dirty.markField(dirtyTreasuryLedger)
+	playerID, playerName := "", ""
+	if p != nil {
+		playerID, playerName = p.ID, p.Name
+	}
+	if n := len(store.TreasuryLedger); n > 0 && amount > 0 {
+		last := &store.TreasuryLedger[n-1]
+		if last.Tick == store.TickCount && last.InstitutionID == inst.ID && last.Memo == memo && last.PlayerID == playerID && last.Amount > 0 {
+			last.Amount += amount
+			last.Balance = inst.Treasury
+			return last
+		}
+	}
+	store.NextTreasuryID++
+	store.TreasuryLedger = append(store.TreasuryLedger, TreasuryEntry{
+		ID:            store.NextTreasuryID,
+		Tick:          store.TickCount,
+		DayNumber:     store.World.DayNumber,
+		InstitutionID: inst.ID,
+		Amount:        amount,
+		Balance:       inst.Treasury,
+		Memo:          memo,
+		PlayerID:      playerID,
+		PlayerName:    playerName,
+		At:            now,
+	})
+	if len(store.TreasuryLedger) > treasuryLedgerMax {
+		store.TreasuryLedger = store.TreasuryLedger[len(store.TreasuryLedger)-treasuryLedgerMax:]
+	}
+	return &store.TreasuryLedger[len(store.TreasuryLedger)-1]
+}
+
+// depositTreasuryLocked pays taxes, fees and fines into institutionID's
+// treasury.
+func depositTreasuryLocked(store *Store, now time.Time, institutionID string, amount int, memo string, payer *Player) {
+	if amount <= 0 {
+		return
+	}
+	recordTreasuryLocked(store, now, store.Institutions[institutionID], amount, memo, payer)
+}
+
+// marketTreasuryID is the institution that collects tax at locationID: the
+// City Authority for city markets, the market's named treasury otherwise.
+func marketTreasuryID(locationID string) string {
+	m, ok := locationMarket(locationID)
+	if !ok {
+		return ""
+	}
+	if m.Jurisdiction == marketJurisdictionCity {
+		return "city_authority"
+	}
+	return m.Treasury
+}
+
+// marketTaxPerUnitLocked is the tax inside one unit's buy and sell price at
+// locationID; market-control surcharges are not tax and go nowhere.
+func marketTaxPerUnitLocked(store *Store, locationID string, def CommodityDefinition, good LocalGood) (buyTax, sellTax int) {
+	base, buy, sell := marketPricesLocked(store, locationID, def, good)
+	_, controls := marketTaxLocked(store, locationID)
+	return buy - marketBuyPrice(base, 0, controls), marketSellPrice(base, 0, controls) - sell
+}
+
+// collectMarketTaxLocked pays tax levied on a trade at locationID into its
+// treasury.
+func collectMarketTaxLocked(store *Store, now time.Time, locationID string, tax int, payer *Player) {
+	if id := marketTreasuryID(locationID); id != "" {
+		depositTreasuryLocked(store, now, id, tax, "Market tax", payer)
+	}
+}
+
+// collectFineLocked takes up to amount from p for the City Authority and
+// returns what was paid.
+func collectFineLocked(store *Store, now time.Time, p *Player, amount int, memo string) int {
+	fine := minInt(amount, maxInt(0, p.Gold))
+	if fine <= 0 {
+		return 0
+	}
+	store.dirty.mark(dirtyPlayers, p.ID)
+	p.Gold -= fine
+	depositTreasuryLocked(store, now, "city_authority", fine, memo, p)
+	return fine
+}
+
+// spendTreasuryLocked checks p holds seatID and its institution can afford
+// cost, rejecting the action otherwise.
+func spendTreasuryLocked(store *Store, p *Player, seatID string, cost int) (*Seat, *Institution, bool) {
+	seat, inst := institutionForSeatLocked(store, seatID)
+	if seat == nil || inst == nil {
+		rejectLocked(store, p.ID, errCodeNotFound, "Seat not found.")
+		return nil, nil, false
+	}
+	if seat.HolderPlayerID != p.ID {
+		rejectLocked(store, p.ID, errCodeNotAllowed, fmt.Sprintf("Only the %s can spend the %s treasury.", seat.Name, inst.Name))
+		return nil, nil, false
+	}
+	if inst.Treasury < cost {
+		rejectLocked(store, p.ID, errCodeInsufficientGold, fmt.Sprintf("The %s treasury holds only %dg; %dg needed.", inst.Name, inst.Treasury, cost))
+		return nil, nil, false
+	}
+	return seat, inst, true
+}
+
+func handleTreasuryActionLocked(store *Store, p *Player, now time.Time, in ActionInput) {
+	seatID := strings.TrimSpace(in.ContractID)
+	switch in.Action {
+	case "treasury_relief":
+		_, inst, ok := spendTreasuryLocked(store, p, seatID, treasuryReliefCost)
+		if !ok {
+			return
+		}
+		fundReliefLocked(store, now, inst, p.Name, p)
+		setToastLocked(store, p.ID, fmt.Sprintf("Relief funded from the treasury (%dg).", treasuryReliefCost))
+	case "treasury_patrol":
+		_, inst, ok := spendTreasuryLocked(store, p, seatID, treasuryPatrolCost)
+		if !ok {
+			return
+		}
+		fundPatrolLocked(store, now, inst, p.Name, p)
+		setToastLocked(store, p.ID, fmt.Sprintf("Patrols funded (%dg).", treasuryPatrolCost))
+	case "treasury_bounty":
+		target := store.Players[in.TargetID]
+		if target == nil || target.ID == p.ID {
+			rejectLocked(store, p.ID, errCodeInvalidInput, "Choose a valid bounty target.")
+			return
+		}
+		if hasActiveBountyForTargetLocked(store, target.ID) {
+			rejectLocked(store, p.ID, errCodeNotAllowed, "A bounty on that target is already posted.")
+			return
+		}
+		reward := clampInt(in.Amount, treasuryBountyMin, treasuryBountyMax)
+		_, inst, ok := spendTreasuryLocked(store, p, seatID, reward)
+		if !ok {
+			return
+		}
+		if !consumeHighImpactBudgetLocked(store, p.ID, now) {
+			rejectLocked(store, p.ID, errCodeHighImpactCap, "Daily cap reached for high-impact actions.")
+			return
+		}
+		recordTreasuryLocked(store, now, inst, -reward, fmt.Sprintf("Bounty on %s", target.Name), p)
+		c := issueBountyContractLocked(store, target, bountyDeadlineTicks)
+		c.BountyReward = reward
+		c.TreasuryID = inst.ID
+		addEventLocked(store, Event{Type: "Law", Severity: 3, Text: fmt.Sprintf("[%s] posts a %dg bounty on [%s] from the %s treasury.", p.Name, reward, target.Name, inst.Name), At: now})
+		setToastLocked(store, p.ID, fmt.Sprintf("Bounty posted on %s.", target.Name))
+	case "treasury_project":
+		def, ok := projectDefinitionByType(in.ProjectType)
+		if !ok {
+			rejectLocked(store, p.ID, errCodeNotFound, "Project type not found.")
+			return
+		}
+		if len(def.Costs) > 0 {
+			rejectLocked(store, p.ID, errCodeNotAllowed, fmt.Sprintf("The treasury cannot supply the goods %s needs.", def.Name))
+			return
+		}
+		if len(store.Projects) >= projectMaxActive {
+			rejectLocked(store, p.ID, errCodeNotAllowed, "City project capacity reached.")
+			return
+		}
+		_, inst, ok := spendTreasuryLocked(store, p, seatID, def.CostGold)
+		if !ok {
+			return
+		}
+		if !consumeHighImpactBudgetLocked(store, p.ID, now) {
+			rejectLocked(store, p.ID, errCodeHighImpactCap, "Daily cap reached for high-impact actions.")
+			return
+		}
+		commissionProjectLocked(store, now, inst, def, p.Name, p)
+		setToastLocked(store, p.ID, fmt.Sprintf("%s commissioned.", def.Name))
+	case "embezzle":
+		amount := in.Amount
+		if amount <= 0 {
+			rejectLocked(store, p.ID, errCodeInvalidInput, "Choose an amount.")
+			return
+		}
+		_, inst, ok := spendTreasuryLocked(store, p, seatID, amount)
+		if !ok {
+			return
+		}
+		if !consumeHighImpactBudgetLocked(store, p.ID, now) {
+			rejectLocked(store, p.ID, errCodeHighImpactCap, "Daily cap reached for high-impact actions.")
+			return
+		}
+		entry := recordTreasuryLocked(store, now, inst, -amount, embezzlementMemo, p)
+		entry.Embezzled = true
+		if p.Embezzled == nil {
+			p.Embezzled = map[string]int{}
+		}
+		p.Embezzled[inst.ID] += amount
+		p.Gold += amount
+		setToastLocked(store, p.ID, fmt.Sprintf("%dg quietly leaves the %s treasury.", amount, inst.Name))
+	}
+}
+
+// fundReliefLocked pays relief wagons out of inst's treasury. actor is the
+// seat holder's name; p is nil when an appointee spends.
+func fundReliefLocked(store *Store, now time.Time, inst *Institution, actor string, p *Player) {
+	recordTreasuryLocked(store, now, inst, -treasuryReliefCost, "Relief wagons", p)
+	applyGrainSupplyDeltaLocked(store, now, reliefSackCost*grainCommodity().PoolPerUnit)
+	shiftUnrestLocked(store, now, -6)
+	addEventLocked(store, Event{Type: "Relief", Severity: 2, Text: fmt.Sprintf("[%s] sends relief wagons paid by the %s.", actor, inst.Name), At: now})
+}
+
+func fundPatrolLocked(store *Store, now time.Time, inst *Institution, actor string, p *Player) {
+	recordTreasuryLocked(store, now, inst, -treasuryPatrolCost, "Watch patrols", p)
+	store.World.BanditActivity = maxInt(0, store.World.BanditActivity-treasuryPatrolBanditDrop)
+	shiftUnrestLocked(store, now, -2)
+	addEventLocked(store, Event{Type: "Law", Severity: 2, Text: fmt.Sprintf("[%s] pays for extra Watch patrols on the roads.", actor), At: now})
+}
+
+// commissionProjectLocked starts def paid from inst's treasury, its grain
+// drawn from the city granary. A project an appointee commissions has no
+// owner to reward.
+func commissionProjectLocked(store *Store, now time.Time, inst *Institution, def ProjectDefinition, actor string, p *Player) {
+	recordTreasuryLocked(store, now, inst, -def.CostGold, def.Name, p)
+	applyGrainSupplyDeltaLocked(store, now, -def.CostGrain*grainCommodity().PoolPerUnit)
+	ownerID := ""
+	if p != nil {
+		ownerID = p.ID
+	}
+	store.NextProjectID++
+	id := fmt.Sprintf("p-%d", store.NextProjectID)
+	store.dirty.mark(dirtyProjects, id)
+	store.Projects[id] = &Project{
+		ID:            id,
+		Type:          def.Type,
+		Name:          def.Name,
+		OwnerPlayerID: ownerID,
+		OwnerName:     actor,
+		CostGold:      def.CostGold,
+		CostGrain:     def.CostGrain,
+		TicksLeft:     def.DurationTicks,
+		TotalTicks:    def.DurationTicks,
+		Definition:    &def,
+	}
+	addEventLocked(store, Event{Type: "Civic", Severity: 2, Text: fmt.Sprintf("[%s] commissions %s from the %s treasury (%d ticks).", actor, def.Name, inst.Name, def.DurationTicks), At: now})
+}
+
+// exposeEmbezzlementLocked is an investigation of target turning up the
+// gold they took from a treasury. The investigator gets a dossier, the
+// ledger names the theft, and whatever target can repay goes back.
+func exposeEmbezzlementLocked(store *Store, investigator, target *Player, now time.Time) bool {
+	stolen := target.Embezzled
+	if len(stolen) == 0 {
+		return false
+	}
+	store.dirty.mark(dirtyPlayers, target.ID)
+	store.dirty.markField(dirtyTreasuryLedger)
+	target.Embezzled = nil
+	for i := range store.TreasuryLedger {
+		if e := &store.TreasuryLedger[i]; e.Embezzled && e.PlayerID == target.ID {
+			e.Exposed = true
+		}
+	}
+	total := 0
+	for _, id := range sortedKeys(stolen) {
+		total += stolen[id]
+		repaid := minInt(stolen[id], maxInt(0, target.Gold))
+		target.Gold -= repaid
+		depositTreasuryLocked(store, now, id, repaid, "Restitution", target)
+	}
+	target.Heat = clampInt(target.Heat+3, 0, 20)
+	target.Rep = clampInt(target.Rep-5, -100, 100)
+	addEvidenceLocked(store, investigator, target, "embezzlement", embezzlementEvidenceStrength, 8, false)
+	addEventLocked(store, Event{Type: "Institution", Severity: 4, Text: fmt.Sprintf("[%s] uncovers %dg embezzled from the treasury by [%s].", investigator.Name, total, target.Name), At: now})
+	setToastLocked(store, target.ID, "Your embezzlement has been exposed.")
+	return true
+}
+
+func treasuryViewsLocked(store *Store) []TreasuryView {
+	views := make([]TreasuryView, 0, len(institutionDefinitions))
+	for _, def := range institutionDefinitions {
+		if inst := store.Institutions[def.ID]; inst != nil {
+			views = append(views, TreasuryView{ID: inst.ID, Name: inst.Name, Balance: inst.Treasury})
+		}
+	}
+	return views
+}
+
+// treasuryLedgerViewsLocked lists the latest ledger lines, newest first.
+// Embezzlement reads as an unsigned discretionary expense until exposed.
+func treasuryLedgerViewsLocked(store *Store, limit int) []TreasuryEntryView {
+	views := make([]TreasuryEntryView, 0, limit)
+	for i := len(store.TreasuryLedger) - 1; i >= 0 && len(views) < limit; i-- {
+		e := store.TreasuryLedger[i]
+		name := e.InstitutionID
+		if inst := store.Institutions[e.InstitutionID]; inst != nil {
+			name = inst.Name
+		}
+		memo, playerName := e.Memo, e.PlayerName
+		if e.Embezzled && e.Exposed {
+			memo = "Embezzled"
+		} else if e.Embezzled {
+			playerName = ""
+		}
+		views = append(views, TreasuryEntryView{
+			Institution: name,
+			Day:         e.DayNumber,
+			Amount:      e.Amount,
+			Balance:     e.Balance,
+			Memo:        memo,
+			PlayerName:  playerName,
+			Exposed:     e.Embezzled && e.Exposed,
+		})
+	}
+	return views
+}
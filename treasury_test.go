@@ -0,0 +1,190 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTaxesAndFeesFillTheTreasuries(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	trader := &Player{ID: "p1", Name: "Ash Crow (Guest)", Gold: 100, Rep: 30, LocationID: locationCapital, LastSeen: now}
+	harbor := &Player{ID: "p2", Name: "Bram Vale (Guest)", Gold: 20, LocationID: locationHarbor, LastSeen: now}
+	s.Players[trader.ID], s.Players[harbor.ID] = trader, harbor
+	city, league := s.Institutions["city_authority"], s.Institutions["merchant_league"]
+	cityOpening, leagueOpening := city.Treasury, league.Treasury
+
+	s.Policies.TaxRatePct = 20
+	salt, _ := commodityDefinitionByID("salt")
+	good, _ := localGood(locationCapital, "salt")
+	buyTax, _ := marketTaxPerUnitLocked(s, locationCapital, salt, good)
+	handleActionInputLocked(s, trader, now, ActionInput{Action: "buy", Commodity: "salt", Amount: 4})
+	if buyTax <= 0 || city.Treasury != cityOpening+4*buyTax {
+		t.Fatalf("city tax should reach the City Authority: %d -> %d (tax %d a unit)", cityOpening, city.Treasury, buyTax)
+	}
+	handleActionInputLocked(s, harbor, now, ActionInput{Action: "buy", Commodity: "salt", Amount: 2})
+	if league.Treasury <= leagueOpening || harbor.Gold == 20 {
+		t.Fatalf("harbor dues should reach the Merchant League: %d -> %d", leagueOpening, league.Treasury)
+	}
+
+	s.Contracts["c1"] = &Contract{ID: "c1", Type: "Emergency", Status: "Fulfilled", OwnerPlayerID: trader.ID, Stance: contractStanceCareful}
+	outcome := computeDeliverOutcomeLocked(s, trader, s.Contracts["c1"])
+	before := city.Treasury
+	finalizeDeliveredContractLocked(s, trader, s.Contracts["c1"], now)
+	if outcome.TaxGold <= 0 || city.Treasury != before+outcome.TaxGold {
+		t.Fatalf("contract tax should be paid in: %+v treasury %d -> %d", outcome, before, city.Treasury)
+	}
+
+	s.Seats["harbor_master"].HolderPlayerID = trader.ID
+	s.Policies.PermitRequiredHighRisk = true
+	before, gold := league.Treasury, harbor.Gold
+	handleActionInputLocked(s, trader, now, ActionInput{Action: "issue_permit", TargetID: harbor.ID})
+	if !hasActivePermitLocked(s, harbor.ID) || harbor.Gold != gold-permitFeeGold || league.Treasury != before+permitFeeGold {
+		t.Fatalf("the permit fee should go to the Merchant League: gold %d treasury %d", harbor.Gold, league.Treasury)
+	}
+
+	ledger := treasuryLedgerViewsLocked(s, treasuryLedgerMax)
+	memos := map[string]bool{}
+	for _, e := range ledger {
+		memos[e.Memo] = true
+	}
+	for _, want := range []string{"Market tax", "Contract tax", "Permit fee"} {
+		if !memos[want] {
+			t.Fatalf("ledger should record %q: %+v", want, ledger)
+		}
+	}
+	if ledger[0].Memo != "Permit fee" || ledger[0].Institution != "Merchant League" {
+		t.Fatalf("the ledger should list the newest entry first: %+v", ledger[0])
+	}
+}
+
+func TestSeatHoldersSpendTheTreasury(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	coin := &Player{ID: "p1", Name: "Ash Crow (Guest)", LocationID: locationCapital, LastSeen: now}
+	other := &Player{ID: "p2", Name: "Bram Vale (Guest)", Heat: 6, LocationID: locationCapital, LastSeen: now}
+	s.Players[coin.ID], s.Players[other.ID] = coin, other
+	city := s.Institutions["city_authority"]
+	city.Treasury = 100
+
+	handleActionInputLocked(s, other, now, ActionInput{Action: "treasury_relief", ContractID: "master_of_coin"})
+	if s.rejections[other.ID] != errCodeNotAllowed || city.Treasury != 100 {
+		t.Fatalf("only the seat holder may spend, got %q", s.rejections[other.ID])
+	}
+	s.Seats["master_of_coin"].HolderPlayerID = coin.ID
+	s.World.UnrestValue = 40
+	handleActionInputLocked(s, coin, now, ActionInput{Action: "treasury_relief", ContractID: "master_of_coin"})
+	if city.Treasury != 100-treasuryReliefCost || s.World.UnrestValue != 34 {
+		t.Fatalf("relief should be paid from the treasury: %d, unrest %d", city.Treasury, s.World.UnrestValue)
+	}
+	s.World.BanditActivity = 10
+	handleActionInputLocked(s, coin, now, ActionInput{Action: "treasury_patrol", ContractID: "master_of_coin"})
+	if s.World.BanditActivity != 10-treasuryPatrolBanditDrop {
+		t.Fatalf("patrols should clear the roads, activity %d", s.World.BanditActivity)
+	}
+	handleActionInputLocked(s, coin, now, ActionInput{Action: "treasury_project", ContractID: "master_of_coin", ProjectType: "civic_patrols"})
+	if len(s.Projects) != 1 || coin.Gold != 0 {
+		t.Fatalf("the treasury should commission the project: %+v", s.Projects)
+	}
+
+	balance := city.Treasury
+	handleActionInputLocked(s, coin, now, ActionInput{Action: "treasury_bounty", ContractID: "master_of_coin", TargetID: other.ID, Amount: 25})
+	var bounty *Contract
+	for _, c := range s.Contracts {
+		if c.Type == "Bounty" && c.TargetPlayerID == other.ID {
+			bounty = c
+		}
+	}
+	if bounty == nil || bounty.BountyReward != 25 || bounty.TreasuryID != "city_authority" || city.Treasury != balance-25 {
+		t.Fatalf("the bounty should be escrowed from the treasury: %+v treasury %d", bounty, city.Treasury)
+	}
+	bounty.DeadlineTicks = 1
+	runWorldTickLocked(s, now)
+	if bounty.Status != "Failed" || city.Treasury != balance {
+		t.Fatalf("a lapsed bounty should return to the treasury: %s treasury %d", bounty.Status, city.Treasury)
+	}
+
+	city.Treasury = 3
+	handleActionInputLocked(s, coin, now, ActionInput{Action: "treasury_relief", ContractID: "master_of_coin"})
+	if s.rejections[coin.ID] != errCodeInsufficientGold {
+		t.Fatalf("an empty treasury cannot pay, got %q", s.rejections[coin.ID])
+	}
+}
+
+func TestEmbezzlementIsExposedByInvestigation(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	coin := &Player{ID: "p1", Name: "Ash Crow (Guest)", LocationID: locationCapital, LastSeen: now}
+	sleuth := &Player{ID: "p2", Name: "Bram Vale (Guest)", LocationID: locationCapital, LastSeen: now}
+	s.Players[coin.ID], s.Players[sleuth.ID] = coin, sleuth
+	s.Seats["master_of_coin"].HolderPlayerID = coin.ID
+	city := s.Institutions["city_authority"]
+	opening := city.Treasury
+
+	handleActionInputLocked(s, coin, now, ActionInput{Action: "embezzle", ContractID: "master_of_coin", Amount: 12})
+	if coin.Gold != 12 || city.Treasury != opening-12 {
+		t.Fatalf("embezzlement should move gold to the holder: gold %d treasury %d", coin.Gold, city.Treasury)
+	}
+	if got := treasuryLedgerViewsLocked(s, 1)[0]; got.Memo != embezzlementMemo || got.PlayerName != "" || got.Exposed {
+		t.Fatalf("the theft should pass as an unsigned discretionary expense: %+v", got)
+	}
+	for _, e := range s.Events {
+		if strings.Contains(e.Text, "embezzle") {
+			t.Fatalf("embezzlement should be quiet: %q", e.Text)
+		}
+	}
+
+	s.DailyHighImpactN[coin.ID] = highImpactDailyCap
+	handleActionInputLocked(s, coin, now, ActionInput{Action: "embezzle", ContractID: "master_of_coin", Amount: 12})
+	if s.rejections[coin.ID] != errCodeHighImpactCap || city.Treasury != opening-12 {
+		t.Fatalf("embezzlement should spend the daily high-impact budget, got %q treasury %d", s.rejections[coin.ID], city.Treasury)
+	}
+
+	coin.Gold = 5
+	handleActionInputLocked(s, sleuth, now, ActionInput{Action: "investigate", TargetID: coin.ID})
+	if got := treasuryLedgerViewsLocked(s, 2); got[1].Memo != "Embezzled" || !got[1].Exposed || got[1].PlayerName != coin.Name || got[0].Memo != "Restitution" || got[0].Amount != 5 {
+		t.Fatalf("investigation should expose the theft and claw back what it can: %+v", got)
+	}
+	if coin.Gold != 0 || city.Treasury != opening-12+5 {
+		t.Fatalf("restitution should repay the treasury: gold %d treasury %d", coin.Gold, city.Treasury)
+	}
+	ev := strongestEvidenceForLocked(s, sleuth.ID, coin.ID)
+	if ev == nil || ev.Topic != "embezzlement" || ev.Strength != embezzlementEvidenceStrength {
+		t.Fatalf("the investigator should hold a dossier: %+v", ev)
+	}
+	if last := s.Events[len(s.Events)-1]; !strings.Contains(last.Text, "uncovers 12g embezzled") {
+		t.Fatalf("exposure should be public, got %q", last.Text)
+	}
+}
+
+func TestEmbezzlementOutlivesTheLedgerCap(t *testing.T) {
+	s := newTestStore()
+	now := time.Now().UTC()
+	coin := &Player{ID: "p1", Name: "Ash Crow (Guest)", LocationID: locationCapital, LastSeen: now}
+	sleuth := &Player{ID: "p2", Name: "Bram Vale (Guest)", LocationID: locationCapital, LastSeen: now}
+	s.Players[coin.ID], s.Players[sleuth.ID] = coin, sleuth
+	s.Seats["master_of_coin"].HolderPlayerID = coin.ID
+	city := s.Institutions["city_authority"]
+
+	handleActionInputLocked(s, coin, now, ActionInput{Action: "embezzle", ContractID: "master_of_coin", Amount: 12})
+	for i := 0; i <= treasuryLedgerMax; i++ {
+		recordTreasuryLocked(s, now, city, -1, "Upkeep", nil)
+	}
+	for _, e := range s.TreasuryLedger {
+		if e.Embezzled {
+			t.Fatalf("the theft should have scrolled off the ledger")
+		}
+	}
+
+	handleActionInputLocked(s, sleuth, now, ActionInput{Action: "investigate", TargetID: coin.ID})
+	if last := s.Events[len(s.Events)-1]; !strings.Contains(last.Text, "uncovers 12g embezzled") {
+		t.Fatalf("investigation should still expose the theft, got %q", last.Text)
+	}
+	if coin.Gold != 0 || len(coin.Embezzled) != 0 {
+		t.Fatalf("the theft should be repaid and closed: gold %d embezzled %v", coin.Gold, coin.Embezzled)
+	}
+	if exposeEmbezzlementLocked(s, sleuth, coin, now) {
+		t.Fatalf("an exposed theft should not be exposed twice")
+	}
+}